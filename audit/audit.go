@@ -0,0 +1,160 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/crunchydata/crunchy-proxy/common"
+	"github.com/crunchydata/crunchy-proxy/config"
+	"github.com/crunchydata/crunchy-proxy/util/log"
+)
+
+/* The types of audited messages. */
+const (
+	TypeQuery string = "query"
+	TypeParse string = "parse"
+	TypeBind  string = "bind"
+)
+
+/* Replaces bind parameter values when redaction is enabled. */
+const redacted = "<redacted>"
+
+// Entry is a single audited message from a client.
+type Entry struct {
+	Time       time.Time `json:"time"`
+	Client     string    `json:"client"`
+	Database   string    `json:"database"`
+	User       string    `json:"user"`
+	Backend    string    `json:"backend"`
+	Type       string    `json:"type"`
+	Statement  string    `json:"statement,omitempty"`
+	Query      string    `json:"query,omitempty"`
+	Parameters []*string `json:"parameters,omitempty"`
+}
+
+// Sink is a destination for audit entries.
+type Sink interface {
+	// Write writes a single entry, formatted as a line of JSON.
+	Write(line []byte) error
+
+	Close() error
+}
+
+var (
+	sink   Sink
+	redact bool
+	lock   sync.Mutex
+)
+
+// Setup opens the sink configured in the 'audit' section, replacing any sink
+// that is already open. If auditing is disabled, then the current sink is
+// closed and nothing is logged.
+func Setup() error {
+	auditConfig := config.GetAuditConfig()
+
+	var newSink Sink
+	var err error
+
+	if auditConfig.Enable {
+		switch auditConfig.Sink {
+		case common.AUDIT_SINK_FILE:
+			if auditConfig.File.Path == "" {
+				return errors.New("audit: a file path is required")
+			}
+
+			newSink, err = newFileSink(auditConfig.File)
+		case common.AUDIT_SINK_SYSLOG:
+			newSink, err = newSyslogSink(auditConfig.Syslog)
+		default:
+			err = fmt.Errorf("audit: unknown sink '%s'", auditConfig.Sink)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	if sink != nil {
+		sink.Close()
+	}
+
+	sink = newSink
+	redact = auditConfig.Redact
+
+	return nil
+}
+
+// Enabled returns true if audit entries are being logged.
+func Enabled() bool {
+	lock.Lock()
+	defer lock.Unlock()
+
+	return sink != nil
+}
+
+// Log writes an entry to the audit log. Bind parameter values are replaced
+// if redaction is enabled.
+func Log(entry Entry) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	if sink == nil {
+		return
+	}
+
+	if redact {
+		for i := range entry.Parameters {
+			if entry.Parameters[i] != nil {
+				value := redacted
+				entry.Parameters[i] = &value
+			}
+		}
+	}
+
+	/* Leave comparison operators in queries unescaped. */
+	var line bytes.Buffer
+
+	encoder := json.NewEncoder(&line)
+	encoder.SetEscapeHTML(false)
+
+	if err := encoder.Encode(entry); err != nil {
+		log.Errorf("audit: error encoding entry: %s", err.Error())
+		return
+	}
+
+	if err := sink.Write(line.Bytes()); err != nil {
+		log.Errorf("audit: error writing entry: %s", err.Error())
+	}
+}
+
+// Close closes the audit log.
+func Close() {
+	lock.Lock()
+	defer lock.Unlock()
+
+	if sink != nil {
+		sink.Close()
+		sink = nil
+	}
+}
@@ -0,0 +1,113 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/crunchydata/crunchy-proxy/config"
+)
+
+const megabyte = 1024 * 1024
+
+// fileSink writes audit entries to a file. When the file reaches its maximum
+// size it is rotated: the file is renamed with the suffix '.1', any previous
+// backups are shifted up by one and the oldest is removed.
+type fileSink struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+	file       *os.File
+	size       int64
+}
+
+func newFileSink(fileConfig config.AuditFileConfig) (*fileSink, error) {
+	sink := &fileSink{
+		path:       fileConfig.Path,
+		maxSize:    int64(fileConfig.MaxSize) * megabyte,
+		maxBackups: fileConfig.MaxBackups,
+	}
+
+	if err := sink.open(); err != nil {
+		return nil, err
+	}
+
+	return sink, nil
+}
+
+func (s *fileSink) open() error {
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	s.file = file
+	s.size = info.Size()
+
+	return nil
+}
+
+func (s *fileSink) Write(line []byte) error {
+	if s.maxSize > 0 && s.size > 0 && s.size+int64(len(line)) > s.maxSize {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := s.file.Write(line)
+	s.size += int64(n)
+
+	return err
+}
+
+/* Rotate the file. Without any backups, the file is simply truncated. */
+func (s *fileSink) rotate() error {
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+
+	if s.maxBackups > 0 {
+		os.Remove(backupName(s.path, s.maxBackups))
+
+		for i := s.maxBackups - 1; i > 0; i-- {
+			os.Rename(backupName(s.path, i), backupName(s.path, i+1))
+		}
+
+		if err := os.Rename(s.path, backupName(s.path, 1)); err != nil {
+			return err
+		}
+	} else if err := os.Truncate(s.path, 0); err != nil {
+		return err
+	}
+
+	return s.open()
+}
+
+func backupName(path string, index int) string {
+	return fmt.Sprintf("%s.%d", path, index)
+}
+
+func (s *fileSink) Close() error {
+	return s.file.Close()
+}
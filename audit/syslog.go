@@ -0,0 +1,47 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"log/syslog"
+
+	"github.com/crunchydata/crunchy-proxy/config"
+)
+
+// syslogSink sends audit entries to syslog. If no address is configured,
+// then the local syslog server is used.
+type syslogSink struct {
+	writer *syslog.Writer
+}
+
+func newSyslogSink(syslogConfig config.AuditSyslogConfig) (*syslogSink, error) {
+	writer, err := syslog.Dial(syslogConfig.Network, syslogConfig.Address,
+		syslog.LOG_INFO|syslog.LOG_LOCAL0, syslogConfig.Tag)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &syslogSink{writer: writer}, nil
+}
+
+func (s *syslogSink) Write(line []byte) error {
+	_, err := s.writer.Write(line)
+	return err
+}
+
+func (s *syslogSink) Close() error {
+	return s.writer.Close()
+}
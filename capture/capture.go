@@ -0,0 +1,192 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capture
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/crunchydata/crunchy-proxy/config"
+	"github.com/crunchydata/crunchy-proxy/util/log"
+)
+
+/* The types of captured records. */
+const (
+	TypeStart   string = "start"
+	TypeMessage string = "message"
+	TypeEnd     string = "end"
+)
+
+const megabyte = 1024 * 1024
+
+// Record is a single event of a client session in a capture file. A session
+// starts with a 'start' record naming its database and user, followed by a
+// 'message' record for each chunk of the message stream that the client sent,
+// and an 'end' record once the client has disconnected.
+type Record struct {
+	Time     time.Time `json:"time"`
+	Session  int32     `json:"session"`
+	Type     string    `json:"type"`
+	Database string    `json:"database,omitempty"`
+	User     string    `json:"user,omitempty"`
+	Data     []byte    `json:"data,omitempty"`
+}
+
+var (
+	file    *os.File
+	size    int64
+	maxSize int64
+	full    bool
+	lock    sync.Mutex
+)
+
+// Setup opens the capture file configured in the 'capture' section, replacing
+// any file that is already open. Records are appended to the file. If the
+// capture is disabled, then the current file is closed and nothing is
+// recorded.
+func Setup() error {
+	captureConfig := config.GetCaptureConfig()
+
+	var newFile *os.File
+	var newSize int64
+
+	if captureConfig.Enable {
+		if captureConfig.Path == "" {
+			return errors.New("capture: a file path is required")
+		}
+
+		var err error
+
+		if newFile, err = os.OpenFile(captureConfig.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600); err != nil {
+			return err
+		}
+
+		info, err := newFile.Stat()
+
+		if err != nil {
+			newFile.Close()
+			return err
+		}
+
+		newSize = info.Size()
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	if file != nil {
+		file.Close()
+	}
+
+	file = newFile
+	size = newSize
+	maxSize = int64(captureConfig.MaxSize) * megabyte
+	full = false
+
+	return nil
+}
+
+// Enabled returns true if client sessions are being captured.
+func Enabled() bool {
+	lock.Lock()
+	defer lock.Unlock()
+
+	return file != nil && !full
+}
+
+// Start records the start of a client session.
+func Start(session int32, database string, user string) {
+	write(Record{Session: session, Type: TypeStart, Database: database, User: user})
+}
+
+// Message records a chunk of the message stream sent by a client.
+func Message(session int32, data []byte) {
+	write(Record{Session: session, Type: TypeMessage, Data: data})
+}
+
+// End records the end of a client session.
+func End(session int32) {
+	write(Record{Session: session, Type: TypeEnd})
+}
+
+/*
+ * Append a record to the capture file, unless the file has reached its
+ * maximum size, at which point the capture stops.
+ */
+func write(record Record) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	if file == nil || full {
+		return
+	}
+
+	record.Time = time.Now()
+
+	line, err := json.Marshal(record)
+
+	if err != nil {
+		log.Errorf("capture: error encoding record: %s", err.Error())
+		return
+	}
+
+	line = append(line, '\n')
+
+	if maxSize > 0 && size+int64(len(line)) > maxSize {
+		log.Infof("capture: %s has reached its maximum size, stopping the capture", file.Name())
+		full = true
+		return
+	}
+
+	n, err := file.Write(line)
+	size += int64(n)
+
+	if err != nil {
+		log.Errorf("capture: error writing record: %s", err.Error())
+	}
+}
+
+// Close closes the capture file.
+func Close() {
+	lock.Lock()
+	defer lock.Unlock()
+
+	if file != nil {
+		file.Close()
+		file = nil
+	}
+}
+
+// Reader reads the records of a capture file in the order they were written.
+type Reader struct {
+	decoder *json.Decoder
+}
+
+// NewReader returns a reader of the records in r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{decoder: json.NewDecoder(r)}
+}
+
+// Next returns the next record, or io.EOF once there are no more.
+func (r *Reader) Next() (Record, error) {
+	var record Record
+
+	err := r.decoder.Decode(&record)
+
+	return record, err
+}
@@ -0,0 +1,237 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capture
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/crunchydata/crunchy-proxy/connect"
+	"github.com/crunchydata/crunchy-proxy/protocol"
+	"github.com/crunchydata/crunchy-proxy/util/log"
+)
+
+/* The messages a replayed session may have waiting before the replay waits for it. */
+const replayBacklog = 1000
+
+/* How long a replayed session waits for the target to finish once it is done. */
+const replayCloseTimeout = 30 * time.Second
+
+// ReplayOptions configures the replay of a capture.
+type ReplayOptions struct {
+	Target   string  // the host:port to replay the sessions against
+	User     string  // replaces the user of every session, if set
+	Database string  // replaces the database of every session, if set
+	Password string  // the password to authenticate with
+	Speed    float64 // the factor to speed the replay up by, 0 for no delays
+}
+
+// ReplayResult summarizes a replay.
+type ReplayResult struct {
+	Sessions int           // the sessions replayed
+	Failed   int           // the sessions that could not connect or were cut short
+	Messages int           // the chunks of messages sent
+	Duration time.Duration // the time the replay took
+}
+
+// Replay replays the sessions of a capture against a target. Each session is
+// opened on its own connection and sends its messages at the times they were
+// captured, relative to the start of the capture and divided by the speed.
+// Responses are read and discarded. Messages of sessions whose start was not
+// captured are skipped.
+func Replay(r io.Reader, options ReplayOptions) (ReplayResult, error) {
+	var result ReplayResult
+	var resultLock sync.Mutex
+	var wait sync.WaitGroup
+
+	reader := NewReader(r)
+	sessions := make(map[int32]chan []byte)
+	start := time.Now()
+
+	var first time.Time
+
+	for {
+		record, err := reader.Next()
+
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return result, err
+		}
+
+		if first.IsZero() {
+			first = record.Time
+		}
+
+		/* Wait until the record is due, at the speed of the replay. */
+		if options.Speed > 0 {
+			due := start.Add(time.Duration(float64(record.Time.Sub(first)) / options.Speed))
+			time.Sleep(time.Until(due))
+		}
+
+		switch record.Type {
+		case TypeStart:
+			/* Session IDs are reused when the proxy restarts. */
+			if messages, ok := sessions[record.Session]; ok {
+				close(messages)
+			}
+
+			messages := make(chan []byte, replayBacklog)
+			sessions[record.Session] = messages
+
+			user, database := record.User, record.Database
+
+			if options.User != "" {
+				user = options.User
+			}
+
+			if options.Database != "" {
+				database = options.Database
+			}
+
+			wait.Add(1)
+
+			go func(session int32) {
+				defer wait.Done()
+
+				sent, err := replaySession(options, user, database, messages)
+
+				if err != nil {
+					log.Errorf("Error replaying session %d", session)
+					log.Errorf("Error: %s", err.Error())
+				}
+
+				resultLock.Lock()
+				result.Sessions++
+				result.Messages += sent
+
+				if err != nil {
+					result.Failed++
+				}
+
+				resultLock.Unlock()
+			}(record.Session)
+		case TypeMessage:
+			if messages, ok := sessions[record.Session]; ok {
+				messages <- record.Data
+			}
+		case TypeEnd:
+			if messages, ok := sessions[record.Session]; ok {
+				close(messages)
+				delete(sessions, record.Session)
+			}
+		}
+	}
+
+	/* Sessions that were still open when the capture ended end now. */
+	for _, messages := range sessions {
+		close(messages)
+	}
+
+	wait.Wait()
+
+	result.Duration = time.Since(start)
+
+	return result, nil
+}
+
+// replaySession opens a connection to the target as the user, sends it each
+// of the messages as they arrive, and closes it once there are no more. The
+// number of chunks of messages sent is returned. If the connection fails,
+// then the remaining messages are drained and discarded.
+func replaySession(options ReplayOptions, user string, database string, messages chan []byte) (int, error) {
+	var sent int
+
+	connection, err := login(options.Target, user, database, options.Password)
+
+	if err != nil {
+		for range messages {
+		}
+
+		return sent, err
+	}
+
+	defer connection.Close()
+
+	/* Read and discard the responses until the target closes the connection. */
+	closed := make(chan struct{})
+
+	go func() {
+		io.Copy(ioutil.Discard, connection)
+		close(closed)
+	}()
+
+	for message := range messages {
+		if err != nil {
+			continue
+		}
+
+		if _, err = connect.Send(connection, message); err == nil {
+			sent++
+		}
+	}
+
+	/*
+	 * End the session in case the client's Terminate message was not
+	 * captured, and let the target respond to the last messages before the
+	 * connection is closed.
+	 */
+	if err == nil {
+		connect.Send(connection, []byte{protocol.TerminateMessageType, 0, 0, 0, 4})
+
+		select {
+		case <-closed:
+		case <-time.After(replayCloseTimeout):
+		}
+	}
+
+	return sent, err
+}
+
+/* Open a connection to the target and authenticate as the user. */
+func login(target string, user string, database string, password string) (net.Conn, error) {
+	connection, err := connect.Connect(target)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = connect.Send(connection, protocol.CreateStartupMessage(user, database, nil)); err != nil {
+		connection.Close()
+		return nil, err
+	}
+
+	message, length, err := connect.Receive(connection)
+
+	if err != nil {
+		connection.Close()
+		return nil, err
+	}
+
+	if protocol.GetMessageType(message) == protocol.ErrorMessageType {
+		connection.Close()
+		return nil, fmt.Errorf("%s", protocol.ParseError(message[:length]).Message)
+	}
+
+	if authenticated, _ := connect.HandleAuthenticationRequest(connection, message[:length], user, password); !authenticated {
+		connection.Close()
+		return nil, fmt.Errorf("authentication as '%s' failed", user)
+	}
+
+	return connection, nil
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+
+	pb "github.com/crunchydata/crunchy-proxy/server/serverpb"
+)
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache [options]",
+	Short: "show statistics of the query result cache",
+	RunE:  runCache,
+}
+
+func init() {
+	flags := cacheCmd.Flags()
+
+	stringFlag(flags, &host, FlagAdminHost)
+	stringFlag(flags, &port, FlagAdminPort)
+	adminClientFlags(flags)
+	outputFlags(flags)
+}
+
+func runCache(cmd *cobra.Command, args []string) error {
+	address := net.JoinHostPort(host, port)
+
+	dialOptions, err := adminDialOptions()
+
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return err
+	}
+
+	conn, err := grpc.Dial(address, dialOptions...)
+
+	if err != nil {
+		fmt.Println(err)
+	}
+
+	defer conn.Close()
+
+	c := pb.NewAdminClient(conn)
+
+	response, err := c.CacheStatistics(context.Background(), &pb.CacheStatisticsRequest{})
+
+	if err != nil {
+		fmt.Println(err)
+		return nil
+	}
+
+	t := newTable("STATISTIC", "VALUE")
+
+	t.add("entries", response.GetEntries())
+	t.add("size", response.GetSize())
+	t.add("hits", response.GetHits())
+	t.add("misses", response.GetMisses())
+	t.add("evictions", response.GetEvictions())
+	t.add("expirations", response.GetExpirations())
+
+	printResults(response, t, func() string {
+		return fmt.Sprintf("* entries - %d\n* size - %d\n* hits - %d\n* misses - %d\n"+
+			"* evictions - %d\n* expirations - %d\n",
+			response.GetEntries(), response.GetSize(), response.GetHits(),
+			response.GetMisses(), response.GetEvictions(), response.GetExpirations())
+	})
+
+	return nil
+}
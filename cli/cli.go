@@ -40,9 +40,20 @@ func init() {
 	crunchyproxyCmd.AddCommand(
 		startCmd,
 		stopCmd,
+		configCmd,
+		reloadCmd,
 		nodeCmd,
 		statsCmd,
+		poolsCmd,
+		statementsCmd,
+		cacheCmd,
+		sessionsCmd,
+		terminateCmd,
+		readOnlyCmd,
+		pauseCmd,
+		resumeCmd,
 		healthCmd,
+		replayCmd,
 		versionCmd,
 	)
 }
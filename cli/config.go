@@ -0,0 +1,178 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+
+	"github.com/crunchydata/crunchy-proxy/config"
+	pb "github.com/crunchydata/crunchy-proxy/server/serverpb"
+)
+
+/* How long to wait for each node when validating with --dial. */
+const validateDialTimeout = 5 * time.Second
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "check or show a proxy configuration",
+}
+
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "show the configuration that the proxy is running with",
+	Args:  cobra.NoArgs,
+	RunE:  runConfigShow,
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "validate a configuration file before deploying it",
+	Args:  cobra.NoArgs,
+	RunE:  runConfigValidate,
+}
+
+func init() {
+	flags := configValidateCmd.Flags()
+
+	stringFlag(flags, &configPath, FlagConfigPath)
+	stringArrayFlag(flags, &overrides, FlagSet)
+	boolFlag(flags, &validateDial, FlagValidateDial)
+	stringFlag(flags, &format, FlagOutputFormat)
+
+	flags = configShowCmd.Flags()
+
+	stringFlag(flags, &host, FlagAdminHost)
+	stringFlag(flags, &port, FlagAdminPort)
+	stringFlag(flags, &configFormat, FlagConfigFormat)
+	adminClientFlags(flags)
+
+	configCmd.AddCommand(configValidateCmd, configShowCmd)
+}
+
+func runConfigShow(cmd *cobra.Command, args []string) error {
+	address := net.JoinHostPort(host, port)
+
+	dialOptions, err := adminDialOptions()
+
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return err
+	}
+
+	conn, err := grpc.Dial(address, dialOptions...)
+
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return err
+	}
+
+	defer conn.Close()
+
+	c := pb.NewAdminClient(conn)
+
+	response, err := c.GetConfig(context.Background(), &pb.ConfigRequest{Format: configFormat})
+
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return err
+	}
+
+	fmt.Print(response.Config)
+
+	return nil
+}
+
+func runConfigValidate(cmd *cobra.Command, args []string) error {
+	if configPath != "" {
+		config.SetConfigPath(configPath)
+	}
+
+	if err := config.SetOverrides(overrides); err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return err
+	}
+
+	c, err := config.Load()
+
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return err
+	}
+
+	problems := config.Validate(c)
+
+	if validateDial {
+		problems = append(problems, config.DialNodes(c, validateDialTimeout)...)
+	}
+
+	var errors, warnings int
+
+	for _, problem := range problems {
+		if problem.Warning {
+			warnings++
+		} else {
+			errors++
+		}
+	}
+
+	switch format {
+	case "json":
+		result := make([]map[string]string, 0, len(problems))
+
+		for _, problem := range problems {
+			result = append(result, map[string]string{
+				"severity": severity(problem),
+				"key":      problem.Key,
+				"message":  problem.Message,
+			})
+		}
+
+		j, _ := json.Marshal(result)
+		fmt.Println(string(j))
+	case "plain":
+		for _, problem := range problems {
+			fmt.Printf("%s: %s: %s\n", severity(problem), problem.Key, problem.Message)
+		}
+
+		if errors == 0 && warnings == 0 {
+			fmt.Printf("%s is valid\n", config.GetConfigPath())
+		} else {
+			fmt.Printf("%s: %d error(s), %d warning(s)\n", config.GetConfigPath(), errors, warnings)
+		}
+	default:
+		fmt.Printf("Error: Unsupported format '%s'\n", format)
+	}
+
+	if errors > 0 {
+		return fmt.Errorf("the configuration has %d error(s)", errors)
+	}
+
+	return nil
+}
+
+func severity(problem config.Problem) string {
+	if problem.Warning {
+		return "warning"
+	}
+
+	return "error"
+}
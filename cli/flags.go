@@ -15,6 +15,8 @@ limitations under the License.
 package cli
 
 import (
+	"time"
+
 	"github.com/spf13/pflag"
 )
 
@@ -22,6 +24,39 @@ var host string
 var port string
 
 var format string
+var output string
+var noColor bool
+
+var nodeRole string
+var nodePoolMode string
+var nodeWeight int
+var nodeCluster string
+
+var statementLimit int
+
+var readOnlyDisable bool
+
+var pauseTimeout int
+
+var drainTimeout int
+
+var statsWatch time.Duration
+
+var validateDial bool
+
+var configFormat string
+
+var replayTarget string
+var replayUser string
+var replayDatabase string
+var replayPassword string
+var replaySpeed float64
+
+var adminToken string
+var adminSSL bool
+var adminCA string
+var adminCert string
+var adminKey string
 
 type flagInfoString struct {
 	Name        string
@@ -30,6 +65,19 @@ type flagInfoString struct {
 	Default     string
 }
 
+type flagInfoInt struct {
+	Name        string
+	Shorthand   string
+	Description string
+	Default     int
+}
+
+type flagInfoStringArray struct {
+	Name        string
+	Shorthand   string
+	Description string
+}
+
 type flagInfoBool struct {
 	Name        string
 	Shorthand   string
@@ -37,6 +85,20 @@ type flagInfoBool struct {
 	Default     bool
 }
 
+type flagInfoFloat struct {
+	Name        string
+	Shorthand   string
+	Description string
+	Default     float64
+}
+
+type flagInfoDuration struct {
+	Name        string
+	Shorthand   string
+	Description string
+	Default     time.Duration
+}
+
 var (
 	FlagAdminHost = flagInfoString{
 		Name:        "host",
@@ -50,12 +112,103 @@ var (
 		Default:     "8000",
 	}
 
+	FlagAdminToken = flagInfoString{
+		Name:        "token",
+		Description: "proxy admin server token (default $CRUNCHY_PROXY_TOKEN)",
+	}
+
+	FlagAdminSSL = flagInfoBool{
+		Name:        "ssl",
+		Description: "connect to the proxy admin server using SSL",
+		Default:     false,
+	}
+
+	FlagAdminCA = flagInfoString{
+		Name:        "ca",
+		Description: "CA certificate to verify the proxy admin server with",
+	}
+
+	FlagAdminCert = flagInfoString{
+		Name:        "cert",
+		Description: "client certificate for the proxy admin server",
+	}
+
+	FlagAdminKey = flagInfoString{
+		Name:        "key",
+		Description: "client certificate key for the proxy admin server",
+	}
+
+	FlagNodeRole = flagInfoString{
+		Name:        "role",
+		Description: "the role of the node, 'master' or 'replica'",
+		Default:     "replica",
+	}
+
+	FlagNodePoolMode = flagInfoString{
+		Name:        "poolmode",
+		Description: "overrides the pool mode for the node's pools",
+	}
+
+	FlagNodeWeight = flagInfoInt{
+		Name:        "weight",
+		Description: "the relative weight of the node for the weighted balancer",
+	}
+
+	FlagNodeCluster = flagInfoString{
+		Name:        "cluster",
+		Description: "the cluster to add the node to, if not the top-level nodes",
+	}
+
+	FlagStatementLimit = flagInfoInt{
+		Name:        "limit",
+		Description: "the number of statements to show",
+		Default:     20,
+	}
+
+	FlagReadOnlyDisable = flagInfoBool{
+		Name:        "disable",
+		Description: "take the proxy out of read-only mode",
+	}
+
+	FlagPauseTimeout = flagInfoInt{
+		Name:        "timeout",
+		Description: "seconds to wait for clients to finish their transactions",
+	}
+
+	FlagDrainTimeout = flagInfoInt{
+		Name:        "timeout",
+		Description: "seconds to wait for clients to release the node's connections",
+	}
+
+	FlagStatsWatch = flagInfoDuration{
+		Name:        "watch",
+		Shorthand:   "w",
+		Description: "redraw a dashboard of the nodes and pools at this interval, e.g. 2s",
+	}
+
 	FlagOutputFormat = flagInfoString{
 		Name:        "format",
 		Description: "the output format",
 		Default:     "plain",
 	}
 
+	FlagOutput = flagInfoString{
+		Name:        "output",
+		Shorthand:   "o",
+		Description: "the output format, 'table', 'json' or 'yaml'; overrides --format",
+	}
+
+	FlagNoColor = flagInfoBool{
+		Name:        "no-color",
+		Description: "do not color table output",
+	}
+
+	FlagConfigFormat = flagInfoString{
+		Name:        "format",
+		Description: "the output format, 'yaml' or 'json'",
+		Default:     "yaml",
+	}
+
 	FlagConfigPath = flagInfoString{
 		Name:        "config",
 		Shorthand:   "c",
@@ -68,6 +221,43 @@ var (
 		Default:     "info",
 	}
 
+	FlagSet = flagInfoStringArray{
+		Name:        "set",
+		Description: "override a configuration key, as key=value",
+	}
+
+	FlagValidateDial = flagInfoBool{
+		Name:        "dial",
+		Description: "check that each node accepts connections",
+	}
+
+	FlagReplayTarget = flagInfoString{
+		Name:        "target",
+		Description: "the host:port of the proxy or server to replay the capture against",
+		Default:     "localhost:5432",
+	}
+
+	FlagReplayUser = flagInfoString{
+		Name:        "user",
+		Description: "the user to replay every session as, instead of the captured user",
+	}
+
+	FlagReplayDatabase = flagInfoString{
+		Name:        "database",
+		Description: "the database to replay every session in, instead of the captured database",
+	}
+
+	FlagReplayPassword = flagInfoString{
+		Name:        "password",
+		Description: "the password to authenticate with (default $PGPASSWORD)",
+	}
+
+	FlagReplaySpeed = flagInfoFloat{
+		Name:        "speed",
+		Description: "the factor to speed the replay up by, e.g. 2 for twice the original speed, or 0 for no delays",
+		Default:     1,
+	}
+
 	FlagBackground = flagInfoBool{
 		Name:        "background",
 		Description: "run process in background",
@@ -83,6 +273,22 @@ func stringFlag(f *pflag.FlagSet, valPtr *string, flagInfo flagInfoString) {
 		flagInfo.Description)
 }
 
+func intFlag(f *pflag.FlagSet, valPtr *int, flagInfo flagInfoInt) {
+	f.IntVarP(valPtr,
+		flagInfo.Name,
+		flagInfo.Shorthand,
+		flagInfo.Default,
+		flagInfo.Description)
+}
+
+func stringArrayFlag(f *pflag.FlagSet, valPtr *[]string, flagInfo flagInfoStringArray) {
+	f.StringArrayVarP(valPtr,
+		flagInfo.Name,
+		flagInfo.Shorthand,
+		nil,
+		flagInfo.Description)
+}
+
 func boolFlag(f *pflag.FlagSet, valPtr *bool, flagInfo flagInfoBool) {
 	f.BoolVarP(valPtr,
 		flagInfo.Name,
@@ -90,3 +296,36 @@ func boolFlag(f *pflag.FlagSet, valPtr *bool, flagInfo flagInfoBool) {
 		flagInfo.Default,
 		flagInfo.Description)
 }
+
+func floatFlag(f *pflag.FlagSet, valPtr *float64, flagInfo flagInfoFloat) {
+	f.Float64VarP(valPtr,
+		flagInfo.Name,
+		flagInfo.Shorthand,
+		flagInfo.Default,
+		flagInfo.Description)
+}
+
+func durationFlag(f *pflag.FlagSet, valPtr *time.Duration, flagInfo flagInfoDuration) {
+	f.DurationVarP(valPtr,
+		flagInfo.Name,
+		flagInfo.Shorthand,
+		flagInfo.Default,
+		flagInfo.Description)
+}
+
+// adminClientFlags adds the flags used to authenticate with the admin server.
+func adminClientFlags(f *pflag.FlagSet) {
+	stringFlag(f, &adminToken, FlagAdminToken)
+	boolFlag(f, &adminSSL, FlagAdminSSL)
+	stringFlag(f, &adminCA, FlagAdminCA)
+	stringFlag(f, &adminCert, FlagAdminCert)
+	stringFlag(f, &adminKey, FlagAdminKey)
+}
+
+// outputFlags adds the flags that select how the results of an inspection
+// command are printed.
+func outputFlags(f *pflag.FlagSet) {
+	stringFlag(f, &format, FlagOutputFormat)
+	stringFlag(f, &output, FlagOutput)
+	boolFlag(f, &noColor, FlagNoColor)
+}
@@ -15,8 +15,8 @@ limitations under the License.
 package cli
 
 import (
-	"encoding/json"
 	"fmt"
+	"net"
 
 	"github.com/spf13/cobra"
 	"golang.org/x/net/context"
@@ -36,16 +36,18 @@ func init() {
 
 	stringFlag(flags, &host, FlagAdminHost)
 	stringFlag(flags, &port, FlagAdminPort)
-	stringFlag(flags, &format, FlagOutputFormat)
+	adminClientFlags(flags)
+	outputFlags(flags)
 }
 
 func runHealth(cmd *cobra.Command, args []string) error {
-	var result string
-	address := fmt.Sprintf("%s:%s", host, port)
+	address := net.JoinHostPort(host, port)
+
+	dialOptions, err := adminDialOptions()
 
-	dialOptions := []grpc.DialOption{
-		grpc.WithDialer(adminServerDialer),
-		grpc.WithInsecure(),
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return err
 	}
 
 	conn, err := grpc.Dial(address, dialOptions...)
@@ -67,17 +69,20 @@ func runHealth(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	switch format {
-	case "json":
-		j, _ := json.Marshal(response.GetHealth())
-		result = string(j)
-	case "plain":
-		result = formatPlain(response.GetHealth())
-	default:
-		result = fmt.Sprintf("Error: Unsupported format '%s'", format)
+	health := response.GetHealth()
+	t := newTable("NODE", "HEALTHY")
+
+	for _, name := range sortedKeys(health) {
+		if health[name] {
+			t.add(name, colored("yes", colorGreen))
+		} else {
+			t.add(name, colored("no", colorRed))
+		}
 	}
 
-	fmt.Println(result)
+	printResults(health, t, func() string {
+		return formatPlain(health)
+	})
 
 	return nil
 }
@@ -15,8 +15,9 @@ limitations under the License.
 package cli
 
 import (
-	"encoding/json"
 	"fmt"
+	"io"
+	"net"
 
 	"github.com/spf13/cobra"
 	"golang.org/x/net/context"
@@ -27,26 +28,89 @@ import (
 
 var nodeCmd = &cobra.Command{
 	Use:   "node",
-	Short: "show information about configured nodes",
+	Short: "show and manage the configured nodes",
 	RunE:  runNode,
 }
 
 var nodeListCmd = &cobra.Command{}
 
+var nodeAddCmd = &cobra.Command{
+	Use:   "add <name> <host:port>",
+	Short: "add a node to a running instance of a proxy",
+	Args:  cobra.ExactArgs(2),
+	RunE:  runNodeAdd,
+}
+
+var nodeRemoveCmd = &cobra.Command{
+	Use:   "remove <name>",
+	Short: "remove a node from a running instance of a proxy",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runNodeRemove,
+}
+
+var nodeEnableCmd = &cobra.Command{
+	Use:   "enable <name>",
+	Short: "enable a disabled node",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runNodeEnable,
+}
+
+var nodeDrainCmd = &cobra.Command{
+	Use:   "drain <name>",
+	Short: "disable a node and wait for its clients to finish, for maintenance",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runNodeDrain,
+}
+
+var nodeDisableCmd = &cobra.Command{
+	Use:   "disable <name>",
+	Short: "stop sending queries to a node without removing it",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runNodeDisable,
+}
+
 func init() {
 	flags := nodeCmd.Flags()
 
 	stringFlag(flags, &host, FlagAdminHost)
 	stringFlag(flags, &port, FlagAdminPort)
-	stringFlag(flags, &format, FlagOutputFormat)
+	adminClientFlags(flags)
+	outputFlags(flags)
+
+	for _, cmd := range []*cobra.Command{nodeAddCmd, nodeRemoveCmd, nodeEnableCmd, nodeDisableCmd, nodeDrainCmd} {
+		flags := cmd.Flags()
+
+		stringFlag(flags, &host, FlagAdminHost)
+		stringFlag(flags, &port, FlagAdminPort)
+		adminClientFlags(flags)
+	}
+
+	flags = nodeAddCmd.Flags()
+
+	stringFlag(flags, &nodeRole, FlagNodeRole)
+	stringFlag(flags, &nodePoolMode, FlagNodePoolMode)
+	intFlag(flags, &nodeWeight, FlagNodeWeight)
+	stringFlag(flags, &nodeCluster, FlagNodeCluster)
+
+	intFlag(nodeDrainCmd.Flags(), &drainTimeout, FlagDrainTimeout)
+
+	nodeCmd.AddCommand(
+		nodeAddCmd,
+		nodeRemoveCmd,
+		nodeEnableCmd,
+		nodeDisableCmd,
+		nodeDrainCmd,
+	)
 }
 
 func runNode(cmd *cobra.Command, args []string) error {
-	address := fmt.Sprintf("%s:%s", host, port)
+	address := net.JoinHostPort(host, port)
+
+	dialOptions, err := adminDialOptions()
 
-	dialOptions := []grpc.DialOption{
-		grpc.WithDialer(adminServerDialer),
-		grpc.WithInsecure(),
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return err
 	}
 
 	conn, err := grpc.Dial(address, dialOptions...)
@@ -65,22 +129,131 @@ func runNode(cmd *cobra.Command, args []string) error {
 		fmt.Println(err.Error())
 	}
 
-	var result string
 	nodes := response.GetNodes()
+	disabled := make(map[string]bool)
+
+	for _, name := range response.GetDisabled() {
+		disabled[name] = true
+	}
+
+	t := newTable("NAME", "ADDRESS", "STATUS")
+
+	for _, name := range sortedKeys(nodes) {
+		if disabled[name] {
+			t.add(name, nodes[name], colored("disabled", colorYellow))
+		} else {
+			t.add(name, nodes[name], colored("enabled", colorGreen))
+		}
+	}
+
+	printResults(nodes, t, func() string {
+		var result string
 
-	switch format {
-	case "json":
-		j, _ := json.Marshal(nodes)
-		result = string(j)
-	case "plain":
 		for name, node := range nodes {
+			if disabled[name] {
+				node += " (disabled)"
+			}
+
 			result += fmt.Sprintf("* %s - %s\n", name, node)
 		}
-	default:
-		result = fmt.Sprintf("Error: Unsupported format - '%s'", format)
+
+		return result
+	})
+
+	return nil
+}
+
+func runNodeAdd(cmd *cobra.Command, args []string) error {
+	return updateNode(func(c pb.AdminClient) error {
+		_, err := c.AddNode(context.Background(), &pb.AddNodeRequest{
+			Name:     args[0],
+			HostPort: args[1],
+			Role:     nodeRole,
+			PoolMode: nodePoolMode,
+			Weight:   int32(nodeWeight),
+			Cluster:  nodeCluster,
+		})
+
+		return err
+	}, fmt.Sprintf("Node '%s' added", args[0]))
+}
+
+func runNodeRemove(cmd *cobra.Command, args []string) error {
+	return updateNode(func(c pb.AdminClient) error {
+		_, err := c.RemoveNode(context.Background(), &pb.RemoveNodeRequest{Name: args[0]})
+		return err
+	}, fmt.Sprintf("Node '%s' removed", args[0]))
+}
+
+func runNodeEnable(cmd *cobra.Command, args []string) error {
+	return updateNode(func(c pb.AdminClient) error {
+		_, err := c.EnableNode(context.Background(), &pb.EnableNodeRequest{Name: args[0]})
+		return err
+	}, fmt.Sprintf("Node '%s' enabled", args[0]))
+}
+
+func runNodeDisable(cmd *cobra.Command, args []string) error {
+	return updateNode(func(c pb.AdminClient) error {
+		_, err := c.DisableNode(context.Background(), &pb.DisableNodeRequest{Name: args[0]})
+		return err
+	}, fmt.Sprintf("Node '%s' disabled", args[0]))
+}
+
+// runNodeDrain drains a node, printing its progress until it is done.
+func runNodeDrain(cmd *cobra.Command, args []string) error {
+	return updateNode(func(c pb.AdminClient) error {
+		stream, err := c.DrainNode(context.Background(),
+			&pb.DrainNodeRequest{Name: args[0], Timeout: int32(drainTimeout)})
+
+		if err != nil {
+			return err
+		}
+
+		for {
+			progress, err := stream.Recv()
+
+			if err == io.EOF {
+				return nil
+			} else if err != nil {
+				return err
+			}
+
+			if progress.Terminated > 0 {
+				fmt.Printf("Timed out, disconnected %d clients\n", progress.Terminated)
+			} else if !progress.Done {
+				fmt.Printf("Waiting for %d connections to be released\n", progress.Active)
+			}
+		}
+	}, fmt.Sprintf("Node '%s' drained", args[0]))
+}
+
+// updateNode connects to the admin server and makes a request that changes
+// the nodes, printing the message if it succeeds.
+func updateNode(request func(pb.AdminClient) error, message string) error {
+	address := net.JoinHostPort(host, port)
+
+	dialOptions, err := adminDialOptions()
+
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return err
+	}
+
+	conn, err := grpc.Dial(address, dialOptions...)
+
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
+	}
+
+	defer conn.Close()
+
+	if err = request(pb.NewAdminClient(conn)); err != nil {
+		fmt.Printf("Error: %s\n", grpc.ErrorDesc(err))
+		return err
 	}
 
-	fmt.Println(result)
+	fmt.Println(message)
 
 	return nil
 }
@@ -0,0 +1,215 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	colorBold   = "1"
+	colorRed    = "31"
+	colorGreen  = "32"
+	colorYellow = "33"
+)
+
+// cell is a value in a table, printed in color when color is enabled.
+type cell struct {
+	text  string
+	color string
+}
+
+/* colored creates a cell that is printed in the given color. */
+func colored(text string, color string) cell {
+	return cell{text: text, color: color}
+}
+
+// table collects the results of an inspection command to print as aligned
+// columns under a header.
+type table struct {
+	header []cell
+	rows   [][]cell
+}
+
+func newTable(header ...string) *table {
+	t := &table{}
+
+	for _, name := range header {
+		t.header = append(t.header, colored(name, colorBold))
+	}
+
+	return t
+}
+
+/* add appends a row, formatting any values that are not cells with %v. */
+func (t *table) add(values ...interface{}) {
+	row := make([]cell, len(values))
+
+	for i, value := range values {
+		switch v := value.(type) {
+		case cell:
+			row[i] = v
+		case float64:
+			row[i] = cell{text: fmt.Sprintf("%.3f", v)}
+		default:
+			row[i] = cell{text: fmt.Sprintf("%v", v)}
+		}
+	}
+
+	t.rows = append(t.rows, row)
+}
+
+// String renders the table. The columns are padded to the width of their
+// widest value before any color is applied, so that the escape sequences do
+// not upset the alignment.
+func (t *table) String() string {
+	var buffer bytes.Buffer
+
+	rows := append([][]cell{t.header}, t.rows...)
+	widths := make([]int, len(t.header))
+
+	for _, row := range rows {
+		for i, c := range row {
+			if n := utf8.RuneCountInString(c.text); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+
+	color := useColor()
+
+	for _, row := range rows {
+		for i, c := range row {
+			if color && c.color != "" {
+				buffer.WriteString("\x1b[" + c.color + "m" + c.text + "\x1b[0m")
+			} else {
+				buffer.WriteString(c.text)
+			}
+
+			if i < len(row)-1 {
+				padding := widths[i] - utf8.RuneCountInString(c.text) + 2
+				buffer.WriteString(strings.Repeat(" ", padding))
+			}
+		}
+
+		buffer.WriteString("\n")
+	}
+
+	return buffer.String()
+}
+
+// useColor returns whether tables should be colored: only when writing to a
+// terminal, and unless disabled by --no-color or the NO_COLOR environment
+// variable.
+func useColor() bool {
+	if noColor || os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+
+	info, err := os.Stdout.Stat()
+
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+/* sortedKeys returns the keys of a map with string keys, in order. */
+func sortedKeys(m interface{}) []string {
+	var keys []string
+
+	for _, key := range reflect.ValueOf(m).MapKeys() {
+		keys = append(keys, key.String())
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+/* outputFormat returns the selected output format, --output if given. */
+func outputFormat() string {
+	if output != "" {
+		return output
+	}
+
+	return format
+}
+
+// printResults prints the results of an inspection command in the selected
+// output format: value is marshalled for 'json' and 'yaml', t is printed for
+// 'table' and plain is called for the original 'plain' format.
+func printResults(value interface{}, t *table, plain func() string) {
+	var result string
+
+	switch f := outputFormat(); f {
+	case "json":
+		j, _ := json.Marshal(value)
+		result = string(j)
+	case "yaml":
+		y, err := toYAML(value)
+
+		if err != nil {
+			result = fmt.Sprintf("Error: %s", err.Error())
+		} else {
+			result = strings.TrimSuffix(y, "\n")
+		}
+	case "table":
+		result = t.String()
+	case "plain":
+		result = plain()
+	default:
+		result = fmt.Sprintf("Error: Unsupported format - '%s'", f)
+	}
+
+	fmt.Println(result)
+}
+
+// toYAML marshals a value to yaml with the same field names as json, by way
+// of its json encoding, as the admin API's messages only carry json tags.
+func toYAML(value interface{}) (string, error) {
+	var generic interface{}
+
+	j, err := json.Marshal(value)
+
+	if err != nil {
+		return "", err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(j))
+	decoder.UseNumber()
+
+	if err := decoder.Decode(&generic); err != nil {
+		return "", err
+	}
+
+	y, err := yaml.Marshal(generic)
+
+	if err != nil {
+		return "", err
+	}
+
+	return string(y), nil
+}
@@ -0,0 +1,119 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+
+	pb "github.com/crunchydata/crunchy-proxy/server/serverpb"
+)
+
+var pauseCmd = &cobra.Command{
+	Use:   "pause",
+	Short: "hold client queries at the proxy during backend maintenance",
+	RunE:  runPause,
+}
+
+var resumeCmd = &cobra.Command{
+	Use:   "resume",
+	Short: "release the client queries held by pause",
+	RunE:  runResume,
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{pauseCmd, resumeCmd} {
+		flags := cmd.Flags()
+
+		stringFlag(flags, &host, FlagAdminHost)
+		stringFlag(flags, &port, FlagAdminPort)
+		adminClientFlags(flags)
+	}
+
+	intFlag(pauseCmd.Flags(), &pauseTimeout, FlagPauseTimeout)
+}
+
+func runPause(cmd *cobra.Command, args []string) error {
+	address := net.JoinHostPort(host, port)
+
+	dialOptions, err := adminDialOptions()
+
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return err
+	}
+
+	conn, err := grpc.Dial(address, dialOptions...)
+
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
+	}
+
+	defer conn.Close()
+
+	c := pb.NewAdminClient(conn)
+
+	response, err := c.Pause(context.Background(),
+		&pb.PauseRequest{Timeout: int32(pauseTimeout)})
+
+	if err != nil {
+		fmt.Printf("Error: %s\n", grpc.ErrorDesc(err))
+		return err
+	}
+
+	if response.Active > 0 {
+		fmt.Printf("Paused, but %d clients still hold a backend\n", response.Active)
+	} else {
+		fmt.Println("Paused")
+	}
+
+	return nil
+}
+
+func runResume(cmd *cobra.Command, args []string) error {
+	address := net.JoinHostPort(host, port)
+
+	dialOptions, err := adminDialOptions()
+
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return err
+	}
+
+	conn, err := grpc.Dial(address, dialOptions...)
+
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
+	}
+
+	defer conn.Close()
+
+	c := pb.NewAdminClient(conn)
+
+	if _, err = c.Resume(context.Background(), &pb.ResumeRequest{}); err != nil {
+		fmt.Printf("Error: %s\n", grpc.ErrorDesc(err))
+		return err
+	}
+
+	fmt.Println("Resumed")
+
+	return nil
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+
+	pb "github.com/crunchydata/crunchy-proxy/server/serverpb"
+)
+
+var poolsCmd = &cobra.Command{
+	Use:   "pools [options]",
+	Short: "show statistics for each pool",
+	RunE:  runPools,
+}
+
+func init() {
+	flags := poolsCmd.Flags()
+
+	stringFlag(flags, &host, FlagAdminHost)
+	stringFlag(flags, &port, FlagAdminPort)
+	adminClientFlags(flags)
+	outputFlags(flags)
+}
+
+func runPools(cmd *cobra.Command, args []string) error {
+	address := net.JoinHostPort(host, port)
+
+	dialOptions, err := adminDialOptions()
+
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return err
+	}
+
+	conn, err := grpc.Dial(address, dialOptions...)
+
+	if err != nil {
+		fmt.Println(err)
+	}
+
+	defer conn.Close()
+
+	c := pb.NewAdminClient(conn)
+
+	response, err := c.ShowPools(context.Background(), &pb.ShowPoolsRequest{})
+
+	if err != nil {
+		fmt.Println(err)
+		return nil
+	}
+
+	pools := response.GetPools()
+	t := newTable("NODE", "DATABASE", "USER", "TOTAL", "IN USE", "IDLE",
+		"WAITING", "AVG WAIT", "MAX WAIT", "TIMEOUTS", "REJECTED", "AVG AGE")
+
+	for _, pool := range pools {
+		t.add(pool.GetNode(), pool.GetDatabase(), pool.GetUser(), pool.GetTotal(),
+			pool.GetInUse(), pool.GetIdle(), pool.GetWaiting(),
+			pool.GetAverageWait(), pool.GetMaxWait(), pool.GetTimeouts(),
+			pool.GetRejected(), fmt.Sprintf("%.0f", pool.GetAverageAge()))
+	}
+
+	printResults(pools, t, func() string {
+		var result string
+
+		for _, pool := range pools {
+			result += fmt.Sprintf("* %s (%s/%s) - total: %d, in use: %d, idle: %d, "+
+				"waiting: %d, avg wait: %.3fs, max wait: %.3fs, timeouts: %d, "+
+				"avg age: %.0fs\n",
+				pool.GetNode(), pool.GetDatabase(), pool.GetUser(), pool.GetTotal(),
+				pool.GetInUse(), pool.GetIdle(), pool.GetWaiting(),
+				pool.GetAverageWait(), pool.GetMaxWait(), pool.GetTimeouts(),
+				pool.GetAverageAge())
+		}
+
+		return result
+	})
+
+	return nil
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+
+	pb "github.com/crunchydata/crunchy-proxy/server/serverpb"
+)
+
+var readOnlyCmd = &cobra.Command{
+	Use:   "readonly",
+	Short: "reject queries for the master, e.g. during maintenance",
+	RunE:  runReadOnly,
+}
+
+func init() {
+	flags := readOnlyCmd.Flags()
+
+	stringFlag(flags, &host, FlagAdminHost)
+	stringFlag(flags, &port, FlagAdminPort)
+	adminClientFlags(flags)
+	boolFlag(flags, &readOnlyDisable, FlagReadOnlyDisable)
+}
+
+func runReadOnly(cmd *cobra.Command, args []string) error {
+	address := net.JoinHostPort(host, port)
+
+	dialOptions, err := adminDialOptions()
+
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return err
+	}
+
+	conn, err := grpc.Dial(address, dialOptions...)
+
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
+	}
+
+	defer conn.Close()
+
+	c := pb.NewAdminClient(conn)
+
+	response, err := c.SetReadOnly(context.Background(),
+		&pb.ReadOnlyRequest{Enable: !readOnlyDisable})
+
+	if err != nil {
+		fmt.Printf("Error: %s\n", grpc.ErrorDesc(err))
+		return err
+	}
+
+	if response.ReadOnly {
+		fmt.Println("Read-only mode enabled")
+	} else {
+		fmt.Println("Read-only mode disabled")
+	}
+
+	return nil
+}
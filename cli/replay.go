@@ -0,0 +1,81 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/crunchydata/crunchy-proxy/capture"
+)
+
+var replayCmd = &cobra.Command{
+	Use:   "replay <capture file>",
+	Short: "replay the client sessions of a capture file against a proxy or server",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runReplay,
+}
+
+func init() {
+	flags := replayCmd.Flags()
+
+	stringFlag(flags, &replayTarget, FlagReplayTarget)
+	stringFlag(flags, &replayUser, FlagReplayUser)
+	stringFlag(flags, &replayDatabase, FlagReplayDatabase)
+	stringFlag(flags, &replayPassword, FlagReplayPassword)
+	floatFlag(flags, &replaySpeed, FlagReplaySpeed)
+}
+
+func runReplay(cmd *cobra.Command, args []string) error {
+	if replaySpeed < 0 {
+		err := fmt.Errorf("invalid speed %g", replaySpeed)
+		fmt.Printf("Error: %s\n", err.Error())
+		return err
+	}
+
+	file, err := os.Open(args[0])
+
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return err
+	}
+
+	defer file.Close()
+
+	password := replayPassword
+
+	if password == "" {
+		password = os.Getenv("PGPASSWORD")
+	}
+
+	result, err := capture.Replay(file, capture.ReplayOptions{
+		Target:   replayTarget,
+		User:     replayUser,
+		Database: replayDatabase,
+		Password: password,
+		Speed:    replaySpeed,
+	})
+
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return err
+	}
+
+	fmt.Printf("Replayed %d sessions, %d failed, sending %d messages in %s\n",
+		result.Sessions, result.Failed, result.Messages, result.Duration)
+
+	return nil
+}
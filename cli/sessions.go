@@ -0,0 +1,121 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+
+	pb "github.com/crunchydata/crunchy-proxy/server/serverpb"
+)
+
+var sessionsCmd = &cobra.Command{
+	Use:   "sessions [options]",
+	Short: "show the client sessions",
+	RunE:  runSessions,
+}
+
+func init() {
+	flags := sessionsCmd.Flags()
+
+	stringFlag(flags, &host, FlagAdminHost)
+	stringFlag(flags, &port, FlagAdminPort)
+	adminClientFlags(flags)
+	outputFlags(flags)
+}
+
+func runSessions(cmd *cobra.Command, args []string) error {
+	address := net.JoinHostPort(host, port)
+
+	dialOptions, err := adminDialOptions()
+
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return err
+	}
+
+	conn, err := grpc.Dial(address, dialOptions...)
+
+	if err != nil {
+		fmt.Println(err)
+	}
+
+	defer conn.Close()
+
+	c := pb.NewAdminClient(conn)
+
+	response, err := c.ListSessions(context.Background(), &pb.ListSessionsRequest{})
+
+	if err != nil {
+		fmt.Println(err)
+		return nil
+	}
+
+	sessions := response.GetSessions()
+	t := newTable("ID", "USER", "DATABASE", "CLIENT", "STATE", "BACKEND",
+		"CONNECTED", "LAST REQUEST", "RECEIVED", "SENT")
+
+	for _, session := range sessions {
+		backend := session.GetBackend()
+
+		if backend == "" {
+			backend = "none"
+		}
+
+		t.add(session.GetId(), session.GetUser(), session.GetDatabase(),
+			session.GetClientAddr(), session.GetState(), backend,
+			formatUnixTime(session.GetConnectTime()),
+			formatUnixTime(session.GetRequestTime()),
+			session.GetReceived(), session.GetSent())
+	}
+
+	printResults(sessions, t, func() string {
+		var result string
+
+		for _, session := range sessions {
+			backend := session.GetBackend()
+
+			if backend == "" {
+				backend = "none"
+			}
+
+			result += fmt.Sprintf("* %d - %s@%s from %s, %s, backend: %s, "+
+				"connected: %s, last request: %s, received: %d, sent: %d\n",
+				session.GetId(), session.GetUser(), session.GetDatabase(),
+				session.GetClientAddr(), session.GetState(), backend,
+				formatUnixTime(session.GetConnectTime()),
+				formatUnixTime(session.GetRequestTime()),
+				session.GetReceived(), session.GetSent())
+		}
+
+		return result
+	})
+
+	return nil
+}
+
+/* formatUnixTime formats a time in seconds since the epoch, if it is set. */
+func formatUnixTime(seconds int64) string {
+	if seconds == 0 {
+		return "never"
+	}
+
+	return time.Unix(seconds, 0).Format(time.RFC3339)
+}
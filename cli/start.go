@@ -29,6 +29,7 @@ import (
 var background bool
 var configPath string
 var logLevel string
+var overrides []string
 
 var startCmd = &cobra.Command{
 	Use:     "start",
@@ -43,6 +44,7 @@ func init() {
 	boolFlag(flags, &background, FlagBackground)
 	stringFlag(flags, &configPath, FlagConfigPath)
 	stringFlag(flags, &logLevel, FlagLogLevel)
+	stringArrayFlag(flags, &overrides, FlagSet)
 }
 
 func runStart(cmd *cobra.Command, args []string) error {
@@ -69,6 +71,10 @@ func runStart(cmd *cobra.Command, args []string) error {
 		config.SetConfigPath(configPath)
 	}
 
+	if err := config.SetOverrides(overrides); err != nil {
+		return err
+	}
+
 	config.ReadConfig()
 
 	s := server.NewServer()
@@ -0,0 +1,94 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+
+	pb "github.com/crunchydata/crunchy-proxy/server/serverpb"
+)
+
+var statementsCmd = &cobra.Command{
+	Use:   "statements [options]",
+	Short: "show the statements with the largest total execution time",
+	RunE:  runStatements,
+}
+
+func init() {
+	flags := statementsCmd.Flags()
+
+	stringFlag(flags, &host, FlagAdminHost)
+	stringFlag(flags, &port, FlagAdminPort)
+	adminClientFlags(flags)
+	outputFlags(flags)
+	intFlag(flags, &statementLimit, FlagStatementLimit)
+}
+
+func runStatements(cmd *cobra.Command, args []string) error {
+	address := net.JoinHostPort(host, port)
+
+	dialOptions, err := adminDialOptions()
+
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return err
+	}
+
+	conn, err := grpc.Dial(address, dialOptions...)
+
+	if err != nil {
+		fmt.Println(err)
+	}
+
+	defer conn.Close()
+
+	c := pb.NewAdminClient(conn)
+
+	response, err := c.TopStatements(context.Background(),
+		&pb.TopStatementsRequest{Limit: int32(statementLimit)})
+
+	if err != nil {
+		fmt.Println(err)
+		return nil
+	}
+
+	statements := response.GetStatements()
+	t := newTable("NODE", "CALLS", "TOTAL", "MEAN", "MAX", "QUERY")
+
+	for _, statement := range statements {
+		t.add(statement.GetNode(), statement.GetCalls(), statement.GetTotalTime(),
+			statement.GetMeanTime(), statement.GetMaxTime(), statement.GetQuery())
+	}
+
+	printResults(statements, t, func() string {
+		var result string
+
+		for _, statement := range statements {
+			result += fmt.Sprintf("* %s - calls: %d, total: %.3fs, mean: %.3fs, "+
+				"max: %.3fs\n  %s\n",
+				statement.GetNode(), statement.GetCalls(), statement.GetTotalTime(),
+				statement.GetMeanTime(), statement.GetMaxTime(), statement.GetQuery())
+		}
+
+		return result
+	})
+
+	return nil
+}
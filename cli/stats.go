@@ -15,8 +15,8 @@ limitations under the License.
 package cli
 
 import (
-	"encoding/json"
 	"fmt"
+	"net"
 
 	"github.com/spf13/cobra"
 	"golang.org/x/net/context"
@@ -36,15 +36,19 @@ func init() {
 
 	stringFlag(flags, &host, FlagAdminHost)
 	stringFlag(flags, &port, FlagAdminPort)
-	stringFlag(flags, &format, FlagOutputFormat)
+	adminClientFlags(flags)
+	outputFlags(flags)
+	durationFlag(flags, &statsWatch, FlagStatsWatch)
 }
 
 func runStats(cmd *cobra.Command, args []string) error {
-	address := fmt.Sprintf("%s:%s", host, port)
+	address := net.JoinHostPort(host, port)
 
-	dialOptions := []grpc.DialOption{
-		grpc.WithDialer(adminServerDialer),
-		grpc.WithInsecure(),
+	dialOptions, err := adminDialOptions()
+
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return err
 	}
 
 	conn, err := grpc.Dial(address, dialOptions...)
@@ -57,28 +61,33 @@ func runStats(cmd *cobra.Command, args []string) error {
 
 	c := pb.NewAdminClient(conn)
 
+	if statsWatch > 0 {
+		watchStats(c, address, statsWatch)
+		return nil
+	}
+
 	response, err := c.Statistics(context.Background(), &pb.StatisticsRequest{})
 
 	if err != nil {
 		fmt.Println(err)
 	}
 
-	var result string
 	queries := response.GetQueries()
+	t := newTable("NODE", "QUERIES")
+
+	for _, name := range sortedKeys(queries) {
+		t.add(name, queries[name])
+	}
+
+	printResults(queries, t, func() string {
+		var result string
 
-	switch format {
-	case "json":
-		j, _ := json.Marshal(queries)
-		result = string(j)
-	case "plain":
 		for name, query := range queries {
 			result += fmt.Sprintf("* %s - %d\n", name, query)
 		}
-	default:
-		result = fmt.Sprintf("Error: Unsupported format - '%s'", format)
-	}
 
-	fmt.Println(result)
+		return result
+	})
 
 	return nil
 }
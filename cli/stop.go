@@ -16,12 +16,14 @@ package cli
 
 import (
 	"fmt"
+	"net"
 
 	"github.com/spf13/cobra"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
 
 	pb "github.com/crunchydata/crunchy-proxy/server/serverpb"
+	"github.com/crunchydata/crunchy-proxy/util/grpcutil"
 )
 
 var stopCmd = &cobra.Command{
@@ -35,14 +37,17 @@ func init() {
 
 	stringFlag(flags, &host, FlagAdminHost)
 	stringFlag(flags, &port, FlagAdminPort)
+	adminClientFlags(flags)
 }
 
 func runStop(cmd *cobra.Command, args []string) error {
-	address := fmt.Sprintf("%s:%s", host, port)
+	address := net.JoinHostPort(host, port)
 
-	dialOptions := []grpc.DialOption{
-		grpc.WithDialer(adminServerDialer),
-		grpc.WithInsecure(),
+	dialOptions, err := adminDialOptions()
+
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return err
 	}
 
 	conn, err := grpc.Dial(address, dialOptions...)
@@ -56,8 +61,19 @@ func runStop(cmd *cobra.Command, args []string) error {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	client.Shutdown(ctx, &pb.ShutdownRequest{})
+	stream, err := client.Shutdown(ctx, &pb.ShutdownRequest{})
+
+	/* The stream ends when the proxy stops, an error means it was refused. */
+	if err == nil {
+		_, err = stream.Recv()
+	}
+
 	conn.Close()
 
+	if err != nil && !grpcutil.IsClosedConnection(err) {
+		fmt.Printf("Error: %s\n", err.Error())
+		return err
+	}
+
 	return nil
 }
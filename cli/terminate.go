@@ -0,0 +1,83 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+
+	pb "github.com/crunchydata/crunchy-proxy/server/serverpb"
+)
+
+var terminateCmd = &cobra.Command{
+	Use:   "terminate <session id>",
+	Short: "disconnect a client session, as identified in SHOW CLIENTS",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runTerminate,
+}
+
+func init() {
+	flags := terminateCmd.Flags()
+
+	stringFlag(flags, &host, FlagAdminHost)
+	stringFlag(flags, &port, FlagAdminPort)
+	adminClientFlags(flags)
+}
+
+func runTerminate(cmd *cobra.Command, args []string) error {
+	sessionID, err := strconv.ParseInt(args[0], 10, 32)
+
+	if err != nil {
+		fmt.Printf("Error: invalid session id '%s'\n", args[0])
+		return err
+	}
+
+	address := net.JoinHostPort(host, port)
+
+	dialOptions, err := adminDialOptions()
+
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return err
+	}
+
+	conn, err := grpc.Dial(address, dialOptions...)
+
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
+	}
+
+	defer conn.Close()
+
+	c := pb.NewAdminClient(conn)
+
+	_, err = c.TerminateSession(context.Background(),
+		&pb.TerminateSessionRequest{SessionId: int32(sessionID)})
+
+	if err != nil {
+		fmt.Printf("Error: %s\n", grpc.ErrorDesc(err))
+		return err
+	}
+
+	fmt.Printf("Session %d terminated\n", sessionID)
+
+	return nil
+}
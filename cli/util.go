@@ -15,10 +15,17 @@ limitations under the License.
 package cli
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"io/ioutil"
 	"net"
 	"os"
 	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
 // Custom dialer for use with grpc dialer.
@@ -32,3 +39,69 @@ func adminServerDialer(address string, timeout time.Duration) (net.Conn, error)
 
 	return conn, err
 }
+
+// adminDialOptions creates the options for connecting to the admin server,
+// using SSL and authenticating with a token or client certificate if the
+// corresponding flags are given.
+func adminDialOptions() ([]grpc.DialOption, error) {
+	dialOptions := []grpc.DialOption{
+		grpc.WithDialer(adminServerDialer),
+	}
+
+	if adminSSL || adminCA != "" || adminCert != "" {
+		tlsConfig := &tls.Config{}
+
+		if adminCA != "" {
+			ca, err := ioutil.ReadFile(adminCA)
+
+			if err != nil {
+				return nil, err
+			}
+
+			tlsConfig.RootCAs = x509.NewCertPool()
+
+			if !tlsConfig.RootCAs.AppendCertsFromPEM(ca) {
+				return nil, fmt.Errorf("no certificates found in '%s'", adminCA)
+			}
+		}
+
+		if adminCert != "" {
+			cert, err := tls.LoadX509KeyPair(adminCert, adminKey)
+
+			if err != nil {
+				return nil, err
+			}
+
+			tlsConfig.Certificates = []tls.Certificate{cert}
+		}
+
+		dialOptions = append(dialOptions,
+			grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
+	} else {
+		dialOptions = append(dialOptions, grpc.WithInsecure())
+	}
+
+	token := adminToken
+
+	if token == "" {
+		token = os.Getenv("CRUNCHY_PROXY_TOKEN")
+	}
+
+	if token != "" {
+		dialOptions = append(dialOptions,
+			grpc.WithPerRPCCredentials(tokenCredentials(token)))
+	}
+
+	return dialOptions, nil
+}
+
+// tokenCredentials sends a bearer token with each request to the admin server.
+type tokenCredentials string
+
+func (t tokenCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + string(t)}, nil
+}
+
+func (t tokenCredentials) RequireTransportSecurity() bool {
+	return false
+}
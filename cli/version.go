@@ -16,6 +16,7 @@ package cli
 
 import (
 	"fmt"
+	"net"
 
 	"github.com/spf13/cobra"
 	"golang.org/x/net/context"
@@ -37,14 +38,17 @@ func init() {
 
 	stringFlag(flags, &host, FlagAdminHost)
 	stringFlag(flags, &port, FlagAdminPort)
+	adminClientFlags(flags)
 }
 
 func runVersion(cmd *cobra.Command, args []string) error {
-	address := fmt.Sprintf("%s:%s", host, port)
+	address := net.JoinHostPort(host, port)
 
-	dialOptions := []grpc.DialOption{
-		grpc.WithDialer(adminServerDialer),
-		grpc.WithInsecure(),
+	dialOptions, err := adminDialOptions()
+
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return err
 	}
 
 	conn, err := grpc.Dial(address, dialOptions...)
@@ -0,0 +1,223 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/context"
+
+	pb "github.com/crunchydata/crunchy-proxy/server/serverpb"
+)
+
+/* clearScreen moves the cursor home and clears the terminal. */
+const clearScreen = "\x1b[H\x1b[2J"
+
+// snapshot is one sample of the admin API taken by the stats dashboard.
+type snapshot struct {
+	taken    time.Time
+	nodes    map[string]string
+	disabled map[string]bool
+	health   map[string]bool
+	queries  map[string]int32
+	pools    []*pb.PoolStatistics
+}
+
+// watchStats redraws a dashboard of the health, query rate and pool
+// utilization of each node every interval until it is interrupted. A sample
+// that fails, for instance while the proxy restarts during a failover, is
+// reported on the dashboard and the next one is tried at the next interval.
+func watchStats(c pb.AdminClient, address string, interval time.Duration) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var previous *snapshot
+
+	for {
+		current, err := takeSnapshot(c, interval)
+
+		if useColor() {
+			fmt.Print(clearScreen)
+		}
+
+		fmt.Printf("crunchy-proxy %s - every %s - %s\n\n", address, interval,
+			time.Now().Format("15:04:05"))
+
+		if err != nil {
+			fmt.Printf("Error: %s\n", err.Error())
+		} else {
+			fmt.Print(renderDashboard(current, previous))
+			previous = current
+		}
+
+		select {
+		case <-signals:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+/* takeSnapshot samples the nodes, health, statistics and pools of the proxy. */
+func takeSnapshot(c pb.AdminClient, timeout time.Duration) (*snapshot, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	s := &snapshot{taken: time.Now(), disabled: make(map[string]bool)}
+
+	nodes, err := c.Nodes(ctx, &pb.NodeRequest{})
+
+	if err != nil {
+		return nil, err
+	}
+
+	s.nodes = nodes.GetNodes()
+
+	for _, name := range nodes.GetDisabled() {
+		s.disabled[name] = true
+	}
+
+	health, err := c.Health(ctx, &pb.HealthRequest{})
+
+	if err != nil {
+		return nil, err
+	}
+
+	s.health = health.GetHealth()
+
+	statistics, err := c.Statistics(ctx, &pb.StatisticsRequest{})
+
+	if err != nil {
+		return nil, err
+	}
+
+	s.queries = statistics.GetQueries()
+
+	pools, err := c.ShowPools(ctx, &pb.ShowPoolsRequest{})
+
+	if err != nil {
+		return nil, err
+	}
+
+	s.pools = pools.GetPools()
+
+	return s, nil
+}
+
+// renderDashboard formats a sample as a table of nodes and a table of pools.
+// The query rate of a node is the change in its query count since the
+// previous sample, so it is only shown from the second sample on, and not
+// after the count has gone backwards because the proxy was restarted.
+func renderDashboard(current *snapshot, previous *snapshot) string {
+	var buffer bytes.Buffer
+
+	nodes := newTable("NODE", "ADDRESS", "HEALTH", "STATUS", "QUERIES", "QPS")
+
+	for _, name := range dashboardNodes(current) {
+		health := colored("down", colorRed)
+
+		if current.health[name] {
+			health = colored("up", colorGreen)
+		}
+
+		status := colored("enabled", colorGreen)
+
+		if current.disabled[name] {
+			status = colored("disabled", colorYellow)
+		}
+
+		qps := "-"
+
+		if previous != nil {
+			elapsed := current.taken.Sub(previous.taken).Seconds()
+			count, seen := previous.queries[name]
+
+			if seen && elapsed > 0 && current.queries[name] >= count {
+				qps = fmt.Sprintf("%.1f", float64(current.queries[name]-count)/elapsed)
+			}
+		}
+
+		nodes.add(name, current.nodes[name], health, status,
+			current.queries[name], qps)
+	}
+
+	buffer.WriteString(nodes.String())
+	buffer.WriteString("\n")
+
+	pools := newTable("NODE", "DATABASE", "USER", "TOTAL", "IN USE", "IDLE",
+		"WAITING", "UTIL", "MAX WAIT", "TIMEOUTS")
+
+	for _, pool := range current.pools {
+		var utilization float64
+
+		if pool.GetTotal() > 0 {
+			utilization = 100 * float64(pool.GetInUse()) / float64(pool.GetTotal())
+		}
+
+		util := cell{text: fmt.Sprintf("%.0f%%", utilization)}
+
+		switch {
+		case utilization >= 90:
+			util.color = colorRed
+		case utilization >= 75:
+			util.color = colorYellow
+		}
+
+		waiting := cell{text: fmt.Sprintf("%d", pool.GetWaiting())}
+
+		if pool.GetWaiting() > 0 {
+			waiting.color = colorYellow
+		}
+
+		pools.add(pool.GetNode(), pool.GetDatabase(), pool.GetUser(),
+			pool.GetTotal(), pool.GetInUse(), pool.GetIdle(), waiting, util,
+			pool.GetMaxWait(), pool.GetTimeouts())
+	}
+
+	buffer.WriteString(pools.String())
+
+	return buffer.String()
+}
+
+/* dashboardNodes returns the names of the nodes in a sample, in order. */
+func dashboardNodes(s *snapshot) []string {
+	seen := make(map[string]bool)
+
+	for _, m := range []interface{}{s.nodes, s.health, s.queries} {
+		for _, name := range sortedKeys(m) {
+			seen[name] = true
+		}
+	}
+
+	names := make([]string, 0, len(seen))
+
+	for name := range seen {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
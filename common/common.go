@@ -23,11 +23,96 @@ const (
 	NODE_ROLE_REPLICA string = "replica"
 )
 
+const (
+	TARGET_SESSION_ANY        string = "any"
+	TARGET_SESSION_READ_WRITE string = "read-write"
+)
+
+const (
+	POOL_MODE_STATEMENT   string = "statement"
+	POOL_MODE_TRANSACTION string = "transaction"
+	POOL_MODE_SESSION     string = "session"
+)
+
+const (
+	IDLE_WAIT_READ string = "read"
+	IDLE_WAIT_POLL string = "poll"
+)
+
+const (
+	MIRROR_QUERIES_READ string = "read"
+	MIRROR_QUERIES_ALL  string = "all"
+)
+
+const (
+	RETRY_NODE_SAME  string = "same"
+	RETRY_NODE_OTHER string = "other"
+)
+
+const (
+	POOL_WARMUP_EAGER string = "eager"
+	POOL_WARMUP_LAZY  string = "lazy"
+)
+
+const (
+	POOL_VALIDATE_TCP   string = "tcp"
+	POOL_VALIDATE_SYNC  string = "sync"
+	POOL_VALIDATE_QUERY string = "query"
+)
+
+const (
+	CONSISTENCY_NONE string = "none"
+	CONSISTENCY_LSN  string = "lsn"
+)
+
+const (
+	BALANCER_ROUND_ROBIN       string = "round-robin"
+	BALANCER_LEAST_CONNECTIONS string = "least-connections"
+	BALANCER_WEIGHTED          string = "weighted"
+	BALANCER_LATENCY           string = "latency"
+)
+
+const (
+	AUDIT_SINK_FILE   string = "file"
+	AUDIT_SINK_SYSLOG string = "syslog"
+)
+
+const (
+	LOG_OUTPUT_STDOUT   string = "stdout"
+	LOG_OUTPUT_FILE     string = "file"
+	LOG_OUTPUT_SYSLOG   string = "syslog"
+	LOG_OUTPUT_JOURNALD string = "journald"
+)
+
+const (
+	PROBE_TCP    string = "tcp"
+	PROBE_SQL    string = "sql"
+	PROBE_LAG    string = "lag"
+	PROBE_SCRIPT string = "script"
+)
+
+const (
+	AUTH_METHOD_MD5   string = "md5"
+	AUTH_METHOD_SCRAM string = "scram-sha-256"
+)
+
+const (
+	ADMIN_ROLE_ADMIN    string = "admin"
+	ADMIN_ROLE_READONLY string = "readonly"
+)
+
 type Node struct {
-	HostPort string            `mapstructure:"hostport"` //remote host:port
-	Role     string            `mapstructure:"role"`
-	Metadata map[string]string `mapstructure:"metadata"`
-	Healthy  bool              `mapstructure:"-"`
+	HostPort           string             `mapstructure:"hostport"` //remote host:port, or several separated by commas
+	Role               string             `mapstructure:"role"`
+	Metadata           map[string]string  `mapstructure:"metadata"`
+	PoolMode           string             `mapstructure:"poolmode,omitempty"`           //overrides pool.mode
+	Weight             int                `mapstructure:"weight,omitempty"`             //used by the weighted balancer
+	HealthCheck        *HealthCheckConfig `mapstructure:"healthcheck,omitempty"`        //overrides healthcheck
+	Disabled           bool               `mapstructure:"disabled,omitempty"`           //no pools are created
+	TargetSessionAttrs string             `mapstructure:"targetsessionattrs,omitempty"` //'any' or 'read-write'
+	Cluster            string             `mapstructure:"-"`                            //empty for the top-level nodes
+	Discovered         bool               `mapstructure:"-"`                            //added by discovery
+	Healthy            bool               `mapstructure:"-"`
 }
 
 type Pool struct {
@@ -44,6 +129,10 @@ type SSLConfig struct {
 	SSLServerCert string `mapstructure:"sslservercert,omitempty"`
 	SSLServerKey  string `mapstructure:"sslserverkey,omitempty"`
 	SSLServerCA   string `mapstructure:"sslserverca,omitempty"`
+
+	/* Client certificate verification for connections to the proxy. */
+	ClientCert string            `mapstructure:"clientcert,omitempty"`
+	UserMap    map[string]string `mapstructure:"usermap,omitempty"` //certificate CN to role
 }
 
 type Credentials struct {
@@ -55,6 +144,12 @@ type Credentials struct {
 }
 
 type HealthCheckConfig struct {
-	Delay int    `mapstructure:"delay"`
-	Query string `mapstructure:"query"`
+	Delay        int    `mapstructure:"delay"`
+	MasterDelay  int    `mapstructure:"masterdelay,omitempty"`  //overrides delay for the master
+	ReplicaDelay int    `mapstructure:"replicadelay,omitempty"` //overrides delay for replicas
+	Probe        string `mapstructure:"probe,omitempty"`
+	Query        string `mapstructure:"query"`
+	Timeout      int    `mapstructure:"timeout,omitempty"` //seconds
+	MaxLag       int64  `mapstructure:"maxlag,omitempty"`  //bytes
+	Script       string `mapstructure:"script,omitempty"`
 }
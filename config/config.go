@@ -15,47 +15,1191 @@ limitations under the License.
 package config
 
 import (
+	"fmt"
+	"math"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
 	"github.com/spf13/viper"
 
-	"github.com/crunchydata/crunchy-proxy/common"
-	"github.com/crunchydata/crunchy-proxy/util/log"
-)
+	"github.com/crunchydata/crunchy-proxy/common"
+	"github.com/crunchydata/crunchy-proxy/protocol"
+	"github.com/crunchydata/crunchy-proxy/util/log"
+)
+
+var c Config
+
+const defaultDrainTimeout = 30 * time.Second
+
+const defaultPauseTimeout = 60 * time.Second
+
+const defaultConsoleDatabase = "pgproxy"
+
+/* The largest message PostgreSQL accepts, i.e. MaxAllocSize. */
+const defaultMaxMessageSize = 0x3fffffff
+
+const defaultProbeTimeout = 5 * time.Second
+
+const defaultResetQuery = "DISCARD ALL"
+
+/* The transient errors retried by default, and the response held meanwhile. */
+var defaultRetryCodes = []string{
+	protocol.ErrorCodeSerializationFailure,
+	protocol.ErrorCodeDeadlockDetected,
+	protocol.ErrorCodeCannotConnectNow,
+}
+
+const defaultRetryMaxBuffer = 64 * 1024
+
+const defaultAuditTag = "crunchy-proxy"
+
+/* The identifier and facility of messages sent to syslog or the journal. */
+const (
+	defaultLogTag      = "crunchy-proxy"
+	defaultLogFacility = "local0"
+)
+
+const defaultMaxStatements = 5000
+
+const defaultStatsSaveInterval = 60
+
+/* The default startup and authentication timeouts, as in PostgreSQL. */
+const defaultPhaseTimeout = 60
+
+const (
+	defaultCacheMaxEntries    = 1000
+	defaultCacheMaxResultSize = 1024 * 1024
+)
+
+const defaultLDAPSearchAttribute = "uid"
+
+const defaultWatchInterval = 10 * time.Second
+
+const defaultLDAPTimeout = 10 * time.Second
+
+const (
+	defaultTracingEndpoint = "http://localhost:4318/v1/traces"
+	defaultServiceName     = "crunchy-proxy"
+	defaultTracingTimeout  = 10 * time.Second
+)
+
+const defaultEventTimeout = 10 * time.Second
+
+const defaultReturnTimeout = 10 * time.Second
+
+const defaultVirtualNodes = 100
+
+const (
+	defaultMirrorQueue   = 1000
+	defaultMirrorWorkers = 4
+	defaultMirrorTimeout = 30 * time.Second
+)
+
+const (
+	defaultDiscoveryInterval = 10 * time.Second
+	defaultRoleLabel         = "role"
+	defaultPort              = 5432
+)
+
+const (
+	defaultBackoff      = 100 * time.Millisecond
+	defaultMaxBackoff   = 10 * time.Second
+	defaultResetTimeout = 30 * time.Second
+)
+
+/* Protects the configuration which may change at runtime. */
+var lock sync.RWMutex
+
+func init() {
+	viper.SetConfigType("yaml")
+	viper.SetConfigName("config")
+	viper.AddConfigPath("/etc/crunchy-proxy")
+	viper.AddConfigPath(".")
+	viper.SetEnvPrefix(envPrefix)
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+}
+
+func GetConfig() Config {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return c
+}
+
+func GetNodes() map[string]common.Node {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	nodes := make(map[string]common.Node, len(c.Nodes))
+
+	for name, node := range c.Nodes {
+		nodes[name] = node
+	}
+
+	return nodes
+}
+
+// GetMasterNode returns the name and configuration of the node currently
+// designated as the master of the cluster. The top-level nodes are the
+// cluster with an empty name.
+func GetMasterNode(cluster string) (string, common.Node) {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	for name, node := range c.Nodes {
+		if node.Cluster == cluster && node.Role == common.NODE_ROLE_MASTER {
+			return name, node
+		}
+	}
+
+	return "", common.Node{}
+}
+
+// SetMaster designates the named node as the master of its cluster. The
+// previous master, if any, is designated as a replica.
+func SetMaster(name string) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	cluster := c.Nodes[name].Cluster
+
+	for n, node := range c.Nodes {
+		if node.Cluster != cluster {
+			continue
+		}
+
+		if n == name {
+			node.Role = common.NODE_ROLE_MASTER
+		} else if node.Role == common.NODE_ROLE_MASTER {
+			node.Role = common.NODE_ROLE_REPLICA
+		}
+
+		c.Nodes[n] = node
+	}
+}
+
+// GetClusters returns the names of all of the clusters, starting with the
+// cluster of the top-level nodes, which has an empty name.
+func GetClusters() []string {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	clusters := []string{""}
+
+	for name := range c.Clusters {
+		clusters = append(clusters, name)
+	}
+
+	return clusters
+}
+
+// GetClusterConfig returns the configuration of the named cluster.
+func GetClusterConfig(cluster string) (ClusterConfig, bool) {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	clusterConfig, ok := c.Clusters[cluster]
+
+	return clusterConfig, ok
+}
+
+// GetTenantCluster returns the cluster that the tenant routes send a client
+// with the given startup parameters to. The first route whose pattern matches
+// the value of the tenant parameter, or the database if no parameter is
+// configured, is used. It returns false if no route matches.
+func GetTenantCluster(parameters map[string]string) (string, bool) {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	name := c.Tenants.Parameter
+
+	if name == "" {
+		name = "database"
+	}
+
+	value, ok := parameters[name]
+
+	if !ok {
+		return "", false
+	}
+
+	for _, route := range c.Tenants.Routes {
+		if matched, _ := path.Match(route.Match, value); matched {
+			return route.Cluster, true
+		}
+	}
+
+	return "", false
+}
+
+// GetShardingConfig returns the clusters that queries annotated with a shard
+// key are spread over. Each shard has 100 points on the hash ring by default.
+func GetShardingConfig() ShardingConfig {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	sharding := c.Sharding
+
+	if sharding.VirtualNodes <= 0 {
+		sharding.VirtualNodes = defaultVirtualNodes
+	}
+
+	return sharding
+}
+
+// GetDatabaseCluster returns the cluster that serves the database to clients
+// of the proxy's own listener. Databases that are not mapped to a cluster are
+// served by the top-level nodes.
+func GetDatabaseCluster(database string) string {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	for name, cluster := range c.Clusters {
+		for _, d := range cluster.Databases {
+			if d == database {
+				return name
+			}
+		}
+	}
+
+	return ""
+}
+
+// AddNode adds a node to the running configuration. Nodes added this way are
+// not written to the configuration file, so they are lost when it is
+// reloaded.
+func AddNode(name string, node common.Node) error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	if _, ok := c.Nodes[name]; ok {
+		return fmt.Errorf("node '%s' already exists", name)
+	}
+
+	if _, ok := c.Clusters[node.Cluster]; node.Cluster != "" && !ok {
+		return fmt.Errorf("unknown cluster '%s'", node.Cluster)
+	}
+
+	switch node.Role {
+	case common.NODE_ROLE_MASTER:
+		for n, existing := range c.Nodes {
+			if existing.Cluster == node.Cluster && existing.Role == common.NODE_ROLE_MASTER {
+				return fmt.Errorf("node '%s' is already the master", n)
+			}
+		}
+	case common.NODE_ROLE_REPLICA:
+	default:
+		return fmt.Errorf("invalid role '%s'", node.Role)
+	}
+
+	if c.Nodes == nil {
+		c.Nodes = make(map[string]common.Node)
+	}
+
+	c.Nodes[name] = node
+
+	return nil
+}
+
+// RemoveNode removes a node from the running configuration. The master cannot
+// be removed.
+func RemoveNode(name string) error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	node, ok := c.Nodes[name]
+
+	if !ok {
+		return fmt.Errorf("unknown node '%s'", name)
+	}
+
+	if node.Role == common.NODE_ROLE_MASTER {
+		return fmt.Errorf("cannot remove the master '%s'", name)
+	}
+
+	delete(c.Nodes, name)
+
+	return nil
+}
+
+// SetNodeDisabled disables or re-enables a node in the running configuration.
+// The master cannot be disabled.
+func SetNodeDisabled(name string, disabled bool) error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	node, ok := c.Nodes[name]
+
+	if !ok {
+		return fmt.Errorf("unknown node '%s'", name)
+	}
+
+	if disabled && node.Role == common.NODE_ROLE_MASTER {
+		return fmt.Errorf("cannot disable the master '%s'", name)
+	}
+
+	node.Disabled = disabled
+	c.Nodes[name] = node
+
+	return nil
+}
+
+func GetProxyConfig() ProxyConfig {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return c.Server.Proxy
+}
+
+// GetStartupTimeout returns how long a new client has to send its startup
+// message, including any SSL negotiation. It is 60 seconds by default, and
+// zero, which means no limit, if the configured value is negative.
+func GetStartupTimeout() time.Duration {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return phaseTimeout(c.Server.Proxy.Timeouts.Startup, defaultPhaseTimeout)
+}
+
+// GetAuthTimeout returns how long the authentication of a client, or of a
+// new pool connection, may take. It is 60 seconds by default, and zero, which
+// means no limit, if the configured value is negative.
+func GetAuthTimeout() time.Duration {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return phaseTimeout(c.Server.Proxy.Timeouts.Auth, defaultPhaseTimeout)
+}
+
+// GetRelayTimeout returns how long each write to a client or backend may
+// take while queries are relayed, and how long the rest of a message may take
+// to arrive once it has begun. Zero, the default, means no limit.
+func GetRelayTimeout() time.Duration {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return phaseTimeout(c.Server.Proxy.Timeouts.Relay, 0)
+}
+
+/* phaseTimeout converts a configured timeout, applying its default. */
+func phaseTimeout(seconds int, defaultSeconds int) time.Duration {
+	if seconds == 0 {
+		seconds = defaultSeconds
+	}
+
+	if seconds < 0 {
+		return 0
+	}
+
+	return time.Duration(seconds) * time.Second
+}
+
+// GetAuthConfig returns how the proxy authenticates clients itself. Clients
+// are authenticated against the master node unless a userlist file or a
+// lookup query is set, or an LDAP server is configured for the user and
+// database. Clear text passwords are verified with SCRAM-SHA-256 by default.
+func GetAuthConfig() AuthConfig {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	auth := c.Server.Proxy.Auth
+
+	if auth.Method == "" {
+		auth.Method = common.AUTH_METHOD_SCRAM
+	}
+
+	auth.LDAP = make([]LDAPConfig, len(c.Server.Proxy.Auth.LDAP))
+
+	for i, ldap := range c.Server.Proxy.Auth.LDAP {
+		if ldap.SearchAttribute == "" {
+			ldap.SearchAttribute = defaultLDAPSearchAttribute
+		}
+
+		if ldap.Timeout <= 0 {
+			ldap.Timeout = int(defaultLDAPTimeout / time.Second)
+		}
+
+		auth.LDAP[i] = ldap
+	}
+
+	return auth
+}
+
+// GetRateLimitConfig returns the rates at which new clients are accepted, in
+// total and from each source address. A rate of zero means no limit. If a
+// burst is not set, then it is one second's worth of connections.
+func GetRateLimitConfig() RateLimitConfig {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	rateLimit := c.Server.Proxy.RateLimit
+
+	if rateLimit.Burst <= 0 {
+		rateLimit.Burst = int(math.Ceil(rateLimit.Rate))
+	}
+
+	if rateLimit.ClientBurst <= 0 {
+		rateLimit.ClientBurst = int(math.Ceil(rateLimit.ClientRate))
+	}
+
+	return rateLimit
+}
+
+// GetDrainTimeout returns how long clients are given to finish their
+// transactions when the proxy shuts down. Defaults to 30 seconds.
+func GetDrainTimeout() time.Duration {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	if c.Server.Proxy.DrainTimeout > 0 {
+		return time.Duration(c.Server.Proxy.DrainTimeout) * time.Second
+	}
+
+	return defaultDrainTimeout
+}
+
+// GetPauseTimeout returns how long pausing the proxy waits for clients to
+// finish their transactions.
+func GetPauseTimeout() time.Duration {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	if c.Server.Proxy.PauseTimeout > 0 {
+		return time.Duration(c.Server.Proxy.PauseTimeout) * time.Second
+	}
+
+	return defaultPauseTimeout
+}
+
+// GetMaxMessageSize returns the size of the largest message that a client may
+// send, including its length field. Defaults to the 1GB limit of PostgreSQL.
+func GetMaxMessageSize() int {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	if c.Server.Proxy.MaxMessageSize > 0 {
+		return c.Server.Proxy.MaxMessageSize
+	}
+
+	return defaultMaxMessageSize
+}
+
+// GetIdleWait returns how sessions wait for the next message from an idle
+// client that holds no backend, 'read' by default.
+func GetIdleWait() string {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	if c.Server.Proxy.IdleWait != "" {
+		return c.Server.Proxy.IdleWait
+	}
+
+	return common.IDLE_WAIT_READ
+}
+
+// GetErrorContext returns whether each error that a backend returns to a
+// client is followed by a notice naming the node and the proxy session.
+func GetErrorContext() bool {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return c.Server.Proxy.ErrorContext
+}
+
+func GetAdminConfig() AdminConfig {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return c.Server.Admin
+}
+
+func GetMetricsConfig() MetricsConfig {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return c.Server.Metrics
+}
+
+// GetWatchConfig returns whether the configuration file is watched for
+// changes, and how often. The file is checked every 10 seconds by default.
+func GetWatchConfig() WatchConfig {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	watch := c.Server.Watch
+
+	if watch.Interval <= 0 {
+		watch.Interval = int(defaultWatchInterval / time.Second)
+	}
+
+	return watch
+}
+
+func GetPoolCapacity() int {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return c.Pool.Capacity
+}
+
+// GetPartitions returns the database and user combinations that pools are
+// created for in the cluster. The first is always the database and user from
+// the cluster's 'credentials' section. A partition that does not set a
+// capacity or query timeout uses that of the pool, and one that does not set a
+// database uses the database with the same name as the user.
+func GetPartitions(cluster string) []PartitionConfig {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	credentials := c.Credentials
+	additional := c.Pool.Partitions
+
+	if cluster != "" {
+		credentials = c.Clusters[cluster].Credentials
+		additional = c.Clusters[cluster].Partitions
+	}
+
+	partitions := []PartitionConfig{
+		{
+			Username:     credentials.Username,
+			Password:     credentials.Password,
+			Database:     credentials.Database,
+			Capacity:     c.Pool.Capacity,
+			QueryTimeout: c.Pool.QueryTimeout,
+		},
+	}
+
+	for _, partition := range additional {
+		if partition.Capacity <= 0 {
+			partition.Capacity = c.Pool.Capacity
+		}
+
+		if partition.QueryTimeout <= 0 {
+			partition.QueryTimeout = c.Pool.QueryTimeout
+		}
+
+		if partition.Database == "" {
+			partition.Database = partition.Username
+		}
+
+		/* Allow the settings of the default partition to be overridden. */
+		if partition.Username == partitions[0].Username &&
+			partition.Database == partitions[0].Database {
+			partitions[0].Capacity = partition.Capacity
+			partitions[0].QueryTimeout = partition.QueryTimeout
+			continue
+		}
+
+		partitions = append(partitions, partition)
+	}
+
+	return partitions
+}
+
+// GetPoolMode returns the pooling mode for the named node. A mode configured
+// on the node itself takes precedence over the global pool mode. If neither is
+// set, then statement pooling is used.
+func GetPoolMode(name string) string {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	if mode := c.Nodes[name].PoolMode; mode != "" {
+		return mode
+	}
+
+	if c.Pool.Mode != "" {
+		return c.Pool.Mode
+	}
+
+	return common.POOL_MODE_STATEMENT
+}
+
+// GetBalancer returns the strategy used to select a replica for read queries.
+// If it is not set, then replicas are selected round-robin.
+func GetBalancer() string {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	if c.Pool.Balancer != "" {
+		return c.Pool.Balancer
+	}
+
+	return common.BALANCER_ROUND_ROBIN
+}
+
+// GetAffinity returns the name of the startup parameter whose value pins a
+// client's read queries to a replica, or an empty string if it is not set.
+func GetAffinity() string {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return c.Pool.Affinity
+}
+
+// GetResetConfig returns the configuration of the query that is run on pool
+// connections when they are returned to their pool. The query defaults to
+// 'DISCARD ALL'.
+func GetResetConfig() ResetConfig {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	reset := c.Pool.Reset
+
+	if reset.Query == "" {
+		reset.Query = defaultResetQuery
+	}
+
+	if reset.Timeout <= 0 {
+		reset.Timeout = int(defaultReturnTimeout / time.Second)
+	}
+
+	return reset
+}
+
+// GetRetryPolicy returns how read queries that fail with a transient error are
+// retried. Serialization failures, deadlocks and nodes that cannot accept
+// connections are retried by default, on another node if there is one, once
+// the number of attempts is set.
+func GetRetryPolicy() RetryConfig {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	retry := c.Pool.Retry
+
+	if len(retry.Codes) == 0 {
+		retry.Codes = defaultRetryCodes
+	}
+
+	if retry.Node == "" {
+		retry.Node = common.RETRY_NODE_OTHER
+	}
+
+	if retry.MaxBuffer <= 0 {
+		retry.MaxBuffer = defaultRetryMaxBuffer
+	}
+
+	return retry
+}
+
+// GetCanary returns the replica that only receives a share of the read
+// queries of its cluster, if one is designated.
+func GetCanary() CanaryConfig {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return c.Pool.Canary
+}
+
+// GetPassThrough returns whether sessions that hold a backend until they
+// disconnect may relay their messages in a reduced-parsing mode.
+func GetPassThrough() bool {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return c.Pool.PassThrough
+}
+
+// GetMultiplex returns whether clients of 'session' mode pools give their
+// backend back while they are idle, unless their session depends on it.
+func GetMultiplex() bool {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return c.Pool.Multiplex
+}
+
+// GetAcquireTimeout returns how long a client may wait for a pool connection
+// before its query fails. Zero means that clients wait indefinitely.
+func GetAcquireTimeout() time.Duration {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return time.Duration(c.Pool.AcquireTimeout) * time.Second
+}
+
+// GetMaxWaiting returns how many clients may wait for a connection of each
+// pool before further clients are turned away. Zero means no limit.
+func GetMaxWaiting() int {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return c.Pool.MaxWaiting
+}
+
+// GetPoolWarmUp returns how pools are filled when they are created, and the
+// number of idle connections that each pool keeps. Pools are filled eagerly,
+// to their capacity, unless lazy warm-up is configured.
+func GetPoolWarmUp() (string, int) {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	if c.Pool.WarmUp == common.POOL_WARMUP_LAZY {
+		return common.POOL_WARMUP_LAZY, c.Pool.MinIdle
+	}
+
+	return common.POOL_WARMUP_EAGER, c.Pool.MinIdle
+}
+
+// GetPingInterval returns how often idle pool connections are checked. Zero
+// means that they are not checked.
+func GetPingInterval() time.Duration {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return time.Duration(c.Pool.PingInterval) * time.Second
+}
+
+// GetPoolValidation returns how pool connections are validated before they
+// are handed to a client. Empty means that they are not validated.
+func GetPoolValidation() string {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return c.Pool.Validate
+}
+
+// GetConnectionTimeouts returns how long a pool connection may exist and how
+// long it may be idle before it is replaced. Zero means no limit.
+func GetConnectionTimeouts() (time.Duration, time.Duration) {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return time.Duration(c.Pool.MaxLifetime) * time.Second,
+		time.Duration(c.Pool.IdleTimeout) * time.Second
+}
+
+// GetMaxLag returns how far behind the master a replica may be before read
+// queries are no longer routed to it.
+func GetMaxLag() LagConfig {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return c.Pool.MaxLag
+}
+
+// GetStickyReads returns how long after a write the reads of the same session
+// are only routed to the master, or to replicas that have replayed the write.
+// Zero means that reads are routed as usual.
+func GetStickyReads() time.Duration {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return time.Duration(c.Pool.StickyReads) * time.Second
+}
+
+// GetConsistency returns how the reads of a session are kept from replicas that
+// have not replayed its writes.
+func GetConsistency() string {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	if c.Pool.Consistency == common.CONSISTENCY_LSN {
+		return common.CONSISTENCY_LSN
+	}
+
+	return common.CONSISTENCY_NONE
+}
+
+func GetCredentials() common.Credentials {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return c.Credentials
+}
+
+// GetClusterCredentials returns the credentials for the nodes of the cluster.
+// The SSL settings are always those of the top-level 'credentials' section, as
+// they apply to every connection the proxy makes to a node.
+func GetClusterCredentials(cluster string) common.Credentials {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	if cluster == "" {
+		return c.Credentials
+	}
+
+	credentials := c.Clusters[cluster].Credentials
+	credentials.SSL = c.Credentials.SSL
+
+	return credentials
+}
+
+func GetHealthCheckConfig() common.HealthCheckConfig {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return c.HealthCheck
+}
+
+// GetNodeHealthCheckConfig returns the health check configuration for the
+// named node. Settings configured on the node itself take precedence over the
+// global 'healthcheck' section. If the node does not set a delay, then the
+// master or replica delay is used according to its role, falling back to the
+// global delay. The probe defaults to 'sql' and its timeout to 5 seconds.
+func GetNodeHealthCheckConfig(name string) common.HealthCheckConfig {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	hcConfig := c.HealthCheck
+	node := c.Nodes[name]
+
+	switch {
+	case node.Role == common.NODE_ROLE_MASTER && hcConfig.MasterDelay > 0:
+		hcConfig.Delay = hcConfig.MasterDelay
+	case node.Role != common.NODE_ROLE_MASTER && hcConfig.ReplicaDelay > 0:
+		hcConfig.Delay = hcConfig.ReplicaDelay
+	}
+
+	if override := node.HealthCheck; override != nil {
+		if override.Delay > 0 {
+			hcConfig.Delay = override.Delay
+		}
+
+		if override.Probe != "" {
+			hcConfig.Probe = override.Probe
+		}
+
+		if override.Query != "" {
+			hcConfig.Query = override.Query
+		}
+
+		if override.Timeout > 0 {
+			hcConfig.Timeout = override.Timeout
+		}
+
+		if override.MaxLag > 0 {
+			hcConfig.MaxLag = override.MaxLag
+		}
 
-var c Config
+		if override.Script != "" {
+			hcConfig.Script = override.Script
+		}
+	}
 
-func init() {
-	viper.SetConfigType("yaml")
-	viper.SetConfigName("config")
-	viper.AddConfigPath("/etc/crunchy-proxy")
-	viper.AddConfigPath(".")
+	if hcConfig.Probe == "" {
+		hcConfig.Probe = common.PROBE_SQL
+	}
+
+	if hcConfig.Timeout <= 0 {
+		hcConfig.Timeout = int(defaultProbeTimeout / time.Second)
+	}
+
+	return hcConfig
 }
 
-func GetConfig() Config {
-	return c
+func GetFailoverConfig() FailoverConfig {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return c.Failover
 }
 
-func GetNodes() map[string]common.Node {
-	return c.Nodes
+// GetConsoleConfig returns the configuration of the admin console. The console
+// database defaults to 'pgproxy' and, if no users are configured, only the
+// user from the 'credentials' section may connect to it.
+func GetConsoleConfig() ConsoleConfig {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	console := c.Console
+
+	if console.Database == "" {
+		console.Database = defaultConsoleDatabase
+	}
+
+	if len(console.Users) == 0 {
+		console.Users = []string{c.Credentials.Username}
+	}
+
+	return console
 }
 
-func GetProxyConfig() ProxyConfig {
-	return c.Server.Proxy
+// GetConnectConfig returns how connections to the backends are retried. By
+// default, a failed connection is not retried and the circuit breaker is
+// disabled. The first retry waits 100 milliseconds, doubling with each
+// attempt up to 10 seconds, and an open circuit is reset after 30 seconds.
+func GetConnectConfig() ConnectConfig {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	connect := c.Connect
+
+	if connect.Backoff <= 0 {
+		connect.Backoff = int(defaultBackoff / time.Millisecond)
+	}
+
+	if connect.MaxBackoff <= 0 {
+		connect.MaxBackoff = int(defaultMaxBackoff / time.Millisecond)
+	}
+
+	if connect.ResetTimeout <= 0 {
+		connect.ResetTimeout = int(defaultResetTimeout / time.Second)
+	}
+
+	return connect
 }
 
-func GetAdminConfig() AdminConfig {
-	return c.Server.Admin
+// GetKubernetesConfig returns the configuration of Kubernetes discovery. The
+// role label defaults to 'role', the value that identifies the master to
+// 'master' and the interval to 10 seconds.
+func GetKubernetesConfig() KubernetesConfig {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	kubernetes := c.Discovery.Kubernetes
+
+	if kubernetes.RoleLabel == "" {
+		kubernetes.RoleLabel = defaultRoleLabel
+	}
+
+	if kubernetes.MasterValue == "" {
+		kubernetes.MasterValue = common.NODE_ROLE_MASTER
+	}
+
+	if kubernetes.Interval <= 0 {
+		kubernetes.Interval = int(defaultDiscoveryInterval / time.Second)
+	}
+
+	return kubernetes
 }
 
-func GetPoolCapacity() int {
-	return c.Pool.Capacity
+// GetDNSConfig returns the configuration of DNS discovery. The port defaults
+// to 5432 and the interval to 10 seconds.
+func GetDNSConfig() DNSConfig {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	dns := c.Discovery.DNS
+
+	if dns.Port <= 0 {
+		dns.Port = defaultPort
+	}
+
+	if dns.Interval <= 0 {
+		dns.Interval = int(defaultDiscoveryInterval / time.Second)
+	}
+
+	return dns
 }
 
-func GetCredentials() common.Credentials {
-	return c.Credentials
+// SetDiscoveredNodes replaces the nodes that were previously discovered with
+// the given nodes. Nodes from the configuration file take precedence over
+// discovered nodes with the same name. Returns whether the nodes changed.
+func SetDiscoveredNodes(nodes map[string]common.Node) bool {
+	lock.Lock()
+	defer lock.Unlock()
+
+	var changed bool
+
+	for name, node := range c.Nodes {
+		if _, ok := nodes[name]; node.Discovered && !ok {
+			delete(c.Nodes, name)
+			changed = true
+		}
+	}
+
+	if c.Nodes == nil {
+		c.Nodes = make(map[string]common.Node)
+	}
+
+	for name, node := range nodes {
+		existing, ok := c.Nodes[name]
+
+		if ok && !existing.Discovered {
+			continue
+		}
+
+		if !ok || existing.HostPort != node.HostPort || existing.Role != node.Role {
+			node.Discovered = true
+			c.Nodes[name] = node
+			changed = true
+		}
+	}
+
+	return changed
 }
 
-func GetHealthCheckConfig() common.HealthCheckConfig {
-	return c.HealthCheck
+// GetStatsConfig returns the configuration of the statistics. At most 5000
+// statements are tracked by default, and if the counters are saved to a file
+// they are saved every minute by default.
+func GetStatsConfig() StatsConfig {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	stats := c.Stats
+
+	if stats.MaxStatements <= 0 {
+		stats.MaxStatements = defaultMaxStatements
+	}
+
+	if stats.SaveInterval <= 0 {
+		stats.SaveInterval = defaultStatsSaveInterval
+	}
+
+	return stats
+}
+
+// GetCacheConfig returns the configuration of the query result cache. By
+// default, at most 1000 results of up to 1MB each are cached.
+func GetCacheConfig() CacheConfig {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	cache := c.Cache
+
+	if cache.MaxEntries <= 0 {
+		cache.MaxEntries = defaultCacheMaxEntries
+	}
+
+	if cache.MaxResultSize <= 0 {
+		cache.MaxResultSize = defaultCacheMaxResultSize
+	}
+
+	return cache
+}
+
+// GetFirewallConfig returns the query firewall rules.
+func GetFirewallConfig() FirewallConfig {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return c.Firewall
+}
+
+// GetLogConfig returns the logging configuration. The output defaults to
+// 'file' if a file is configured and to 'stdout' otherwise, the tag to
+// 'crunchy-proxy' and the syslog facility to 'local0'.
+func GetLogConfig() LogConfig {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	logConfig := c.Log
+
+	if logConfig.Output == "" {
+		if logConfig.File != "" {
+			logConfig.Output = common.LOG_OUTPUT_FILE
+		} else {
+			logConfig.Output = common.LOG_OUTPUT_STDOUT
+		}
+	}
+
+	if logConfig.Tag == "" {
+		logConfig.Tag = defaultLogTag
+	}
+
+	if logConfig.Syslog.Facility == "" {
+		logConfig.Syslog.Facility = defaultLogFacility
+	}
+
+	return logConfig
+}
+
+// GetTracingConfig returns the configuration of tracing. The endpoint defaults
+// to a collector on the local host, the service name to 'crunchy-proxy', the
+// sample ratio to 1 and the timeout to 10 seconds.
+func GetTracingConfig() TracingConfig {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	tracing := c.Tracing
+
+	if tracing.Endpoint == "" {
+		tracing.Endpoint = defaultTracingEndpoint
+	}
+
+	if tracing.ServiceName == "" {
+		tracing.ServiceName = defaultServiceName
+	}
+
+	if tracing.SampleRatio <= 0 || tracing.SampleRatio > 1 {
+		tracing.SampleRatio = 1
+	}
+
+	if tracing.Timeout <= 0 {
+		tracing.Timeout = int(defaultTracingTimeout / time.Second)
+	}
+
+	return tracing
+}
+
+// GetEventsConfig returns the configuration of event notifications. The
+// webhook and exec sinks time out after 10 seconds by default.
+func GetEventsConfig() EventsConfig {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	events := c.Events
+
+	if events.Webhook.Timeout <= 0 {
+		events.Webhook.Timeout = int(defaultEventTimeout / time.Second)
+	}
+
+	if events.Exec.Timeout <= 0 {
+		events.Exec.Timeout = int(defaultEventTimeout / time.Second)
+	}
+
+	return events
+}
+
+// GetCaptureConfig returns the configuration of the capture of the messages
+// that clients send.
+func GetCaptureConfig() CaptureConfig {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return c.Capture
+}
+
+// GetMirrorConfig returns the configuration of the mirroring of queries to a
+// shadow cluster. Read queries are mirrored by default, by 4 workers that
+// queue up to 1000 transactions and give up on those that take longer than
+// 30 seconds.
+func GetMirrorConfig() MirrorConfig {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	mirror := c.Mirror
+
+	if mirror.Queries == "" {
+		mirror.Queries = common.MIRROR_QUERIES_READ
+	}
+
+	if mirror.Queue <= 0 {
+		mirror.Queue = defaultMirrorQueue
+	}
+
+	if mirror.Workers <= 0 {
+		mirror.Workers = defaultMirrorWorkers
+	}
+
+	if mirror.Timeout <= 0 {
+		mirror.Timeout = int(defaultMirrorTimeout / time.Second)
+	}
+
+	return mirror
+}
+
+// GetAuditConfig returns the configuration of the audit log. The sink
+// defaults to 'file' and the syslog tag to 'crunchy-proxy'.
+func GetAuditConfig() AuditConfig {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	audit := c.Audit
+
+	if audit.Sink == "" {
+		audit.Sink = common.AUDIT_SINK_FILE
+	}
+
+	if audit.Syslog.Tag == "" {
+		audit.Syslog.Tag = defaultAuditTag
+	}
+
+	return audit
 }
 
 func Get(key string) interface{} {
@@ -95,20 +1239,191 @@ func Set(key string, value interface{}) {
 }
 
 type ProxyConfig struct {
-	HostPort string `mapstructure:"hostport"`
+	HostPort                 string              `mapstructure:"hostport"`
+	ReadHostPort             string              `mapstructure:"readhostport"`
+	WriteHostPort            string              `mapstructure:"writehostport"`
+	SSL                      common.SSLConfig    `mapstructure:"ssl"`
+	DrainTimeout             int                 `mapstructure:"draintimeout"`
+	PauseTimeout             int                 `mapstructure:"pausetimeout"` //seconds
+	QueryAnalysis            bool                `mapstructure:"queryanalysis"`
+	MaxClients               int                 `mapstructure:"maxclients"`
+	QueueTimeout             int                 `mapstructure:"queuetimeout"`             //seconds
+	ClientIdleTimeout        int                 `mapstructure:"clientidletimeout"`        //seconds
+	IdleInTransactionTimeout int                 `mapstructure:"idleintransactiontimeout"` //seconds
+	Socket                   SocketConfig        `mapstructure:"socket"`
+	ProxyProtocol            ProxyProtocolConfig `mapstructure:"proxyprotocol"`
+	Timeouts                 TimeoutsConfig      `mapstructure:"timeouts"`
+	Auth                     AuthConfig          `mapstructure:"auth"`
+	RateLimit                RateLimitConfig     `mapstructure:"ratelimit"`
+	Parameters               map[string]string   `mapstructure:"parameters"`     //startup parameters
+	MaxMessageSize           int                 `mapstructure:"maxmessagesize"` //bytes
+	IdleWait                 string              `mapstructure:"idlewait"`       //'read' or 'poll'
+	ErrorContext             bool                `mapstructure:"errorcontext"`   //notice after backend errors
+	TCP                      TCPConfig           `mapstructure:"tcp"`            //client connections
+	Plugins                  []PluginConfig      `mapstructure:"plugins"`        //loaded at startup
+	Listeners                []ListenerConfig    `mapstructure:"listeners"`      //in addition to hostport
+}
+
+// ListenerConfig is an additional listener for clients of the proxy, with its
+// own address, SSL and PROXY protocol settings and routing.
+type ListenerConfig struct {
+	Name          string               `mapstructure:"name"`
+	HostPort      string               `mapstructure:"hostport"`
+	Socket        SocketConfig         `mapstructure:"socket"`                  //instead of hostport
+	SSL           *common.SSLConfig    `mapstructure:"ssl,omitempty"`           //overrides server.proxy.ssl
+	ProxyProtocol *ProxyProtocolConfig `mapstructure:"proxyprotocol,omitempty"` //overrides server.proxy.proxyprotocol
+	Cluster       string               `mapstructure:"cluster"`
+	Role          string               `mapstructure:"role"` //routes every query to the master or the replicas
+}
+
+type PluginConfig struct {
+	Path    string            `mapstructure:"path"`
+	Options map[string]string `mapstructure:"options"`
+}
+
+type RateLimitConfig struct {
+	Rate        float64 `mapstructure:"rate"`       //connections per second
+	Burst       int     `mapstructure:"burst"`      //connections
+	ClientRate  float64 `mapstructure:"clientrate"` //per source address
+	ClientBurst int     `mapstructure:"clientburst"`
+}
+
+type AuthConfig struct {
+	File     string       `mapstructure:"file"`
+	Query    string       `mapstructure:"query"`
+	User     string       `mapstructure:"user"`     //runs the query, defaults to credentials
+	Password string       `mapstructure:"password"` //of the query user
+	Method   string       `mapstructure:"method"`   //for clear text passwords
+	LDAP     []LDAPConfig `mapstructure:"ldap"`
+}
+
+type LDAPConfig struct {
+	Users           []string `mapstructure:"users"`     //patterns, all users if empty
+	Databases       []string `mapstructure:"databases"` //patterns, all databases if empty
+	URL             string   `mapstructure:"url"`
+	StartTLS        bool     `mapstructure:"starttls"`
+	Prefix          string   `mapstructure:"prefix"` //simple bind
+	Suffix          string   `mapstructure:"suffix"` //simple bind
+	BaseDN          string   `mapstructure:"basedn"` //search+bind
+	BindDN          string   `mapstructure:"binddn"`
+	BindPassword    string   `mapstructure:"bindpassword"`
+	SearchAttribute string   `mapstructure:"searchattribute"`
+	SearchFilter    string   `mapstructure:"searchfilter"`
+	Timeout         int      `mapstructure:"timeout"` //seconds
+}
+
+// TimeoutsConfig limits how long each phase of a client's connection may wait
+// on its peers, so that a client or backend that hangs cannot hold the proxy's
+// resources forever.
+type TimeoutsConfig struct {
+	Startup int `mapstructure:"startup"` //seconds
+	Auth    int `mapstructure:"auth"`    //seconds
+	Relay   int `mapstructure:"relay"`   //seconds
+}
+
+type ProxyProtocolConfig struct {
+	Enable  bool     `mapstructure:"enable"`
+	Trusted []string `mapstructure:"trusted"`
+}
+
+type TCPConfig struct {
+	KeepAliveIdle     int   `mapstructure:"keepaliveidle"`     //seconds
+	KeepAliveInterval int   `mapstructure:"keepaliveinterval"` //seconds
+	KeepAliveCount    int   `mapstructure:"keepalivecount"`
+	NoDelay           *bool `mapstructure:"nodelay"`       //unset leaves it enabled
+	SendBuffer        int   `mapstructure:"sendbuffer"`    //bytes
+	ReceiveBuffer     int   `mapstructure:"receivebuffer"` //bytes
+}
+
+type SocketConfig struct {
+	Path string      `mapstructure:"path"`
+	Mode os.FileMode `mapstructure:"mode"`
 }
 
 type AdminConfig struct {
+	HostPort string           `mapstructure:"hostport"`
+	SSL      common.SSLConfig `mapstructure:"ssl"`
+	Users    []AdminUser      `mapstructure:"users"`
+}
+
+// AdminUser is a client of the admin server. A client is identified either by
+// its token, or by the common name of its certificate matching the name.
+type AdminUser struct {
+	Name  string `mapstructure:"name"`
+	Token string `mapstructure:"token"`
+	Role  string `mapstructure:"role"`
+}
+
+type MetricsConfig struct {
 	HostPort string `mapstructure:"hostport"`
 }
 
 type ServerConfig struct {
-	Admin AdminConfig `mapstructure:"admin"`
-	Proxy ProxyConfig `mapstructure:"proxy"`
+	Admin   AdminConfig   `mapstructure:"admin"`
+	Proxy   ProxyConfig   `mapstructure:"proxy"`
+	Metrics MetricsConfig `mapstructure:"metrics"`
+	Watch   WatchConfig   `mapstructure:"watch"`
+}
+
+type WatchConfig struct {
+	Enable   bool `mapstructure:"enable"`
+	Interval int  `mapstructure:"interval"` //seconds
 }
 
 type PoolConfig struct {
-	Capacity int `mapstructure:"capacity"`
+	Capacity       int               `mapstructure:"capacity"`
+	Mode           string            `mapstructure:"mode"`
+	Balancer       string            `mapstructure:"balancer"`
+	Affinity       string            `mapstructure:"affinity"` //startup parameter
+	Partitions     []PartitionConfig `mapstructure:"partitions"`
+	MaxLag         LagConfig         `mapstructure:"maxlag"`
+	StickyReads    int               `mapstructure:"stickyreads"` //seconds
+	Consistency    string            `mapstructure:"consistency"` //'none' or 'lsn'
+	Reset          ResetConfig       `mapstructure:"reset"`
+	Retry          RetryConfig       `mapstructure:"retry"`
+	Canary         CanaryConfig      `mapstructure:"canary"`
+	MaxLifetime    int               `mapstructure:"maxlifetime"`    //seconds
+	IdleTimeout    int               `mapstructure:"idletimeout"`    //seconds
+	QueryTimeout   int               `mapstructure:"querytimeout"`   //seconds
+	AcquireTimeout int               `mapstructure:"acquiretimeout"` //seconds
+	MaxWaiting     int               `mapstructure:"maxwaiting"`     //clients per pool
+	PassThrough    bool              `mapstructure:"passthrough"`
+	Multiplex      bool              `mapstructure:"multiplex"`    //session mode
+	MinIdle        int               `mapstructure:"minidle"`      //connections
+	WarmUp         string            `mapstructure:"warmup"`       //'eager' or 'lazy'
+	PingInterval   int               `mapstructure:"pinginterval"` //seconds
+	Validate       string            `mapstructure:"validate"`     //'tcp', 'sync' or 'query'
+}
+
+type ResetConfig struct {
+	Enable  bool   `mapstructure:"enable"`
+	Query   string `mapstructure:"query"`
+	Timeout int    `mapstructure:"timeout"` //seconds
+}
+
+type RetryConfig struct {
+	Attempts  int      `mapstructure:"attempts"`
+	Codes     []string `mapstructure:"codes"`     //SQLSTATEs
+	Node      string   `mapstructure:"node"`      //'same' or 'other'
+	MaxBuffer int      `mapstructure:"maxbuffer"` //bytes
+}
+
+type CanaryConfig struct {
+	Node    string  `mapstructure:"node"`
+	Percent float64 `mapstructure:"percent"` //of read queries
+}
+
+type LagConfig struct {
+	Bytes   int64 `mapstructure:"bytes"`
+	Seconds int   `mapstructure:"seconds"`
+}
+
+type PartitionConfig struct {
+	Username     string `mapstructure:"username"`
+	Password     string `mapstructure:"password,omitempty"`
+	Database     string `mapstructure:"database"`
+	Capacity     int    `mapstructure:"capacity,omitempty"`     //overrides pool.capacity
+	QueryTimeout int    `mapstructure:"querytimeout,omitempty"` //overrides pool.querytimeout
 }
 
 type Adapter struct {
@@ -116,6 +1431,109 @@ type Adapter struct {
 	Metadata    map[string]interface{} `mapstructure:"metadata"`
 }
 
+type FailoverConfig struct {
+	Enable bool   `mapstructure:"enable"`
+	Hook   string `mapstructure:"hook,omitempty"`
+}
+
+type ConsoleConfig struct {
+	Enable   bool     `mapstructure:"enable"`
+	Database string   `mapstructure:"database"`
+	Users    []string `mapstructure:"users"`
+}
+
+type ConnectConfig struct {
+	Retries          int       `mapstructure:"retries"`
+	Backoff          int       `mapstructure:"backoff"`    //milliseconds
+	MaxBackoff       int       `mapstructure:"maxbackoff"` //milliseconds
+	FailureThreshold int       `mapstructure:"failurethreshold"`
+	ResetTimeout     int       `mapstructure:"resettimeout"` //seconds
+	TCP              TCPConfig `mapstructure:"tcp"`          //backend connections
+}
+
+type AuditConfig struct {
+	Enable bool              `mapstructure:"enable"`
+	Sink   string            `mapstructure:"sink"`
+	Redact bool              `mapstructure:"redact"`
+	File   AuditFileConfig   `mapstructure:"file"`
+	Syslog AuditSyslogConfig `mapstructure:"syslog"`
+}
+
+type CaptureConfig struct {
+	Enable  bool   `mapstructure:"enable"`
+	Path    string `mapstructure:"path"`
+	MaxSize int    `mapstructure:"maxsize"` //megabytes
+}
+
+type StatsConfig struct {
+	Statements    bool   `mapstructure:"statements"`
+	MaxStatements int    `mapstructure:"maxstatements"`
+	File          string `mapstructure:"file"`         //counters are saved to and restored from
+	SaveInterval  int    `mapstructure:"saveinterval"` //seconds
+}
+
+type CacheConfig struct {
+	Enable        bool `mapstructure:"enable"`
+	MaxEntries    int  `mapstructure:"maxentries"`
+	MaxResultSize int  `mapstructure:"maxresultsize"` //bytes
+}
+
+type FirewallConfig struct {
+	Enable bool           `mapstructure:"enable"`
+	Rules  []FirewallRule `mapstructure:"rules"`
+}
+
+type FirewallRule struct {
+	Name        string   `mapstructure:"name"`
+	Pattern     string   `mapstructure:"pattern"`     //regular expression
+	Fingerprint string   `mapstructure:"fingerprint"` //normalized query
+	NoWhere     []string `mapstructure:"nowhere"`     //tables
+	Message     string   `mapstructure:"message"`
+	Users       []string `mapstructure:"users"`     //patterns, all users if empty
+	Databases   []string `mapstructure:"databases"` //patterns, all databases if empty
+}
+
+type LogConfig struct {
+	SlowQueryThreshold int               `mapstructure:"slowquerythreshold"` //milliseconds
+	SlowQueryText      bool              `mapstructure:"slowquerytext"`
+	Output             string            `mapstructure:"output"`
+	File               string            `mapstructure:"file"`
+	MaxSize            int               `mapstructure:"maxsize"`        //megabytes
+	RotateInterval     int               `mapstructure:"rotateinterval"` //hours
+	MaxBackups         int               `mapstructure:"maxbackups"`
+	Compress           bool              `mapstructure:"compress"`
+	Levels             map[string]string `mapstructure:"levels"` //by component
+	Tag                string            `mapstructure:"tag"`
+	Syslog             LogSyslogConfig   `mapstructure:"syslog"`
+}
+
+type LogSyslogConfig struct {
+	Network  string `mapstructure:"network,omitempty"`
+	Address  string `mapstructure:"address,omitempty"`
+	Facility string `mapstructure:"facility"`
+}
+
+type TracingConfig struct {
+	Enable      bool              `mapstructure:"enable"`
+	Endpoint    string            `mapstructure:"endpoint"`
+	Headers     map[string]string `mapstructure:"headers"`
+	ServiceName string            `mapstructure:"servicename"`
+	SampleRatio float64           `mapstructure:"sampleratio"`
+	Timeout     int               `mapstructure:"timeout"` //seconds
+}
+
+type AuditFileConfig struct {
+	Path       string `mapstructure:"path"`
+	MaxSize    int    `mapstructure:"maxsize"` //megabytes
+	MaxBackups int    `mapstructure:"maxbackups"`
+}
+
+type AuditSyslogConfig struct {
+	Network string `mapstructure:"network,omitempty"`
+	Address string `mapstructure:"address,omitempty"`
+	Tag     string `mapstructure:"tag"`
+}
+
 type Config struct {
 	//Nodes       map[string]common.Node `mapstructure:"nodes"`
 	Server      ServerConfig             `mapstructure:"server"`
@@ -123,24 +1541,233 @@ type Config struct {
 	Nodes       map[string]common.Node   `mapstructure:"nodes"`
 	Credentials common.Credentials       `mapstructure:"credentials"`
 	HealthCheck common.HealthCheckConfig `mapstructure:"healthcheck"`
+	Failover    FailoverConfig           `mapstructure:"failover"`
+	Console     ConsoleConfig            `mapstructure:"console"`
+	Connect     ConnectConfig            `mapstructure:"connect"`
+	Audit       AuditConfig              `mapstructure:"audit"`
+	Tracing     TracingConfig            `mapstructure:"tracing"`
+	Log         LogConfig                `mapstructure:"log"`
+	Stats       StatsConfig              `mapstructure:"stats"`
+	Cache       CacheConfig              `mapstructure:"cache"`
+	Firewall    FirewallConfig           `mapstructure:"firewall"`
+	Events      EventsConfig             `mapstructure:"events"`
+	Mirror      MirrorConfig             `mapstructure:"mirror"`
+	Capture     CaptureConfig            `mapstructure:"capture"`
+	Clusters    map[string]ClusterConfig `mapstructure:"clusters"`
+	Tenants     TenantsConfig            `mapstructure:"tenants"`
+	Sharding    ShardingConfig           `mapstructure:"sharding"`
+	Discovery   DiscoveryConfig          `mapstructure:"discovery"`
+}
+
+type EventsConfig struct {
+	Enable  bool               `mapstructure:"enable"`
+	Types   []string           `mapstructure:"types"` //all types if empty
+	Webhook EventWebhookConfig `mapstructure:"webhook"`
+	Exec    EventExecConfig    `mapstructure:"exec"`
+}
+
+type EventWebhookConfig struct {
+	URL     string            `mapstructure:"url"`
+	Headers map[string]string `mapstructure:"headers"`
+	Timeout int               `mapstructure:"timeout"` //seconds
+}
+
+type EventExecConfig struct {
+	Command string `mapstructure:"command"`
+	Timeout int    `mapstructure:"timeout"` //seconds
+}
+
+type MirrorConfig struct {
+	Enable  bool   `mapstructure:"enable"`
+	Cluster string `mapstructure:"cluster"`
+	Queries string `mapstructure:"queries"` //'read' or 'all'
+	Queue   int    `mapstructure:"queue"`   //transactions
+	Workers int    `mapstructure:"workers"`
+	Timeout int    `mapstructure:"timeout"` //seconds
+}
+
+type DiscoveryConfig struct {
+	Kubernetes KubernetesConfig `mapstructure:"kubernetes"`
+	DNS        DNSConfig        `mapstructure:"dns"`
+}
+
+// DNSConfig configures the discovery of nodes from DNS names that resolve to
+// the addresses of the master and of the replicas.
+type DNSConfig struct {
+	Enable   bool   `mapstructure:"enable"`
+	Master   string `mapstructure:"master"`
+	Replicas string `mapstructure:"replicas"`
+	SRV      bool   `mapstructure:"srv"`      //look up SRV records rather than addresses
+	Port     int    `mapstructure:"port"`     //used with addresses
+	Interval int    `mapstructure:"interval"` //seconds
+}
+
+// KubernetesConfig configures the discovery of nodes from the endpoints of a
+// Kubernetes service. If the API server is not set, then the proxy is assumed
+// to run in a pod and uses its service account.
+type KubernetesConfig struct {
+	Enable      bool   `mapstructure:"enable"`
+	APIServer   string `mapstructure:"apiserver"`
+	TokenFile   string `mapstructure:"tokenfile"`
+	CAFile      string `mapstructure:"cafile"`
+	Namespace   string `mapstructure:"namespace"`
+	Service     string `mapstructure:"service"`
+	Port        string `mapstructure:"port"` //name of the endpoint port
+	RoleLabel   string `mapstructure:"rolelabel"`
+	MasterValue string `mapstructure:"mastervalue"`
+	Interval    int    `mapstructure:"interval"` //seconds
+}
+
+// ClusterConfig is an additional cluster served by the proxy. Clients reach a
+// cluster either through its own listener, or by connecting to one of its
+// databases through the proxy's listener. Its nodes are merged with the
+// top-level nodes when the configuration is loaded.
+// TenantsConfig routes the clients of the proxy's own listeners to clusters by
+// the value of a startup parameter, or by their database if none is named.
+type TenantsConfig struct {
+	Parameter string        `mapstructure:"parameter"` //startup parameter
+	Routes    []TenantRoute `mapstructure:"routes"`
+}
+
+type TenantRoute struct {
+	Match   string `mapstructure:"match"`   //shell pattern
+	Cluster string `mapstructure:"cluster"` //the top-level nodes if empty
+}
+
+type ShardingConfig struct {
+	Enable       bool     `mapstructure:"enable"`
+	Shards       []string `mapstructure:"shards"`       //clusters
+	VirtualNodes int      `mapstructure:"virtualnodes"` //per shard
+}
+
+type ClusterConfig struct {
+	HostPort    string                 `mapstructure:"hostport"`
+	Databases   []string               `mapstructure:"databases"`
+	Nodes       map[string]common.Node `mapstructure:"nodes"`
+	Credentials common.Credentials     `mapstructure:"credentials"`
+	Partitions  []PartitionConfig      `mapstructure:"partitions"`
 }
 
 func SetConfigPath(path string) {
 	viper.SetConfigFile(path)
 }
 
+// GetConfigPath returns the path of the configuration file.
+func GetConfigPath() string {
+	return viper.ConfigFileUsed()
+}
+
 func ReadConfig() {
+	config, err := load()
+
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	lock.Lock()
+	c = config
+	lock.Unlock()
+}
+
+// Reload re-reads the configuration file. If the file cannot be read, then
+// the current configuration is kept and an error is returned.
+//
+// If the file defines the same nodes in the same clusters as before, then the
+// current node roles are kept, so that a master promoted at runtime is not
+// replaced by the master named in the file.
+func Reload() error {
+	config, err := load()
+
+	if err != nil {
+		return err
+	}
+
+	lock.Lock()
+	if sameNodes(c.Nodes, config.Nodes) {
+		for name, node := range config.Nodes {
+			node.Role = c.Nodes[name].Role
+			config.Nodes[name] = node
+		}
+	}
+	c = config
+	lock.Unlock()
+
+	return nil
+}
+
+// sameNodes reports whether both sets contain the same node names, each in
+// the same cluster.
+func sameNodes(current, nodes map[string]common.Node) bool {
+	if len(current) != len(nodes) {
+		return false
+	}
+
+	for name, node := range nodes {
+		if existing, ok := current[name]; !ok || existing.Cluster != node.Cluster {
+			return false
+		}
+	}
+
+	return true
+}
+
+func load() (Config, error) {
+	var config Config
+
 	err := viper.ReadInConfig()
 	log.Debugf("Using configuration file: %s", viper.ConfigFileUsed())
 
 	if err != nil {
-		log.Fatal(err.Error())
+		return config, err
 	}
 
-	err = viper.Unmarshal(&c)
+	bindEnv()
+
+	err = decode(&config)
 
 	if err != nil {
 		log.Errorf("Error unmarshaling configuration file: %s", viper.ConfigFileUsed())
-		log.Fatalf(err.Error())
+		return config, err
+	}
+
+	/*
+	 * Merge the nodes of each cluster with the top-level nodes, so that nodes
+	 * can be referred to by name alone. Node names must therefore be unique
+	 * across all of the clusters.
+	 */
+	for name, cluster := range config.Clusters {
+		for nodeName, node := range cluster.Nodes {
+			if _, ok := config.Nodes[nodeName]; ok {
+				return config, fmt.Errorf("node '%s' of cluster '%s' is already defined",
+					nodeName, name)
+			}
+
+			if config.Nodes == nil {
+				config.Nodes = make(map[string]common.Node)
+			}
+
+			node.Cluster = name
+			config.Nodes[nodeName] = node
+		}
+
+		cluster.Nodes = nil
+		config.Clusters[name] = cluster
 	}
+
+	/*
+	 * A firewall rule whose pattern cannot be compiled would block nothing,
+	 * so such a configuration is rejected rather than loaded.
+	 */
+	for _, rule := range config.Firewall.Rules {
+		if rule.Pattern == "" {
+			continue
+		}
+
+		if _, err := regexp.Compile(rule.Pattern); err != nil {
+			return config, fmt.Errorf("invalid pattern in firewall rule '%s': %s",
+				rule.Name, err.Error())
+		}
+	}
+
+	return config, nil
 }
@@ -0,0 +1,137 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+/* Replaces the value of a secret when the configuration is shown. */
+const maskedValue = "********"
+
+/* The configuration keys whose values are secrets. */
+var secretKeys = map[string]bool{
+	"password":     true,
+	"bindpassword": true,
+	"token":        true,
+}
+
+/* The configuration keys whose entries may all hold secrets, e.g. API keys. */
+var secretMaps = map[string]bool{
+	"headers": true,
+}
+
+// Dump returns the configuration as nested maps and lists, keyed as in the
+// configuration file, so that it can be shown as YAML or JSON. Secrets, such
+// as passwords and tokens, are masked.
+func Dump(config Config) map[string]interface{} {
+	return dumpValue(reflect.ValueOf(config)).(map[string]interface{})
+}
+
+// GetDump returns the running configuration as Dump does, including changes
+// made at runtime, such as nodes that were added, removed or discovered.
+func GetDump() map[string]interface{} {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return Dump(c)
+}
+
+func dumpValue(v reflect.Value) interface{} {
+	if v.Type() == reflect.TypeOf(time.Duration(0)) {
+		return v.Interface().(time.Duration).String()
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+
+		return dumpValue(v.Elem())
+	case reflect.Struct:
+		fields := make(map[string]interface{}, v.NumField())
+
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+
+			if field.PkgPath != "" {
+				continue
+			}
+
+			key := strings.Split(field.Tag.Get("mapstructure"), ",")[0]
+
+			if key == "" {
+				key = strings.ToLower(field.Name)
+			}
+
+			if secretKeys[key] && v.Field(i).Kind() == reflect.String {
+				if v.Field(i).String() != "" {
+					fields[key] = maskedValue
+				} else {
+					fields[key] = ""
+				}
+
+				continue
+			}
+
+			if secretMaps[key] && v.Field(i).Kind() == reflect.Map && !v.Field(i).IsNil() {
+				entries := make(map[string]interface{}, v.Field(i).Len())
+
+				for _, name := range v.Field(i).MapKeys() {
+					entries[fmt.Sprint(name.Interface())] = maskedValue
+				}
+
+				fields[key] = entries
+				continue
+			}
+
+			fields[key] = dumpValue(v.Field(i))
+		}
+
+		return fields
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+
+		entries := make(map[string]interface{}, v.Len())
+
+		for _, key := range v.MapKeys() {
+			entries[fmt.Sprint(key.Interface())] = dumpValue(v.MapIndex(key))
+		}
+
+		return entries
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil
+		}
+
+		fallthrough
+	case reflect.Array:
+		items := make([]interface{}, v.Len())
+
+		for i := range items {
+			items[i] = dumpValue(v.Index(i))
+		}
+
+		return items
+	}
+
+	return v.Interface()
+}
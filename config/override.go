@@ -0,0 +1,123 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/viper"
+)
+
+/*
+ * The prefix of the environment variables that override configuration keys.
+ * The rest of the name is the key in upper case with its dots replaced by
+ * underscores, so that 'credentials.password' is set by
+ * CP_CREDENTIALS_PASSWORD.
+ */
+const envPrefix = "CP"
+
+// SetOverrides sets configuration keys from 'key=value' pairs, such as those
+// given with the --set flag. They take precedence over the environment and the
+// configuration file, including when it is reloaded.
+func SetOverrides(overrides []string) error {
+	for _, override := range overrides {
+		i := strings.Index(override, "=")
+
+		if i <= 0 {
+			return fmt.Errorf("invalid override '%s', expected key=value", override)
+		}
+
+		viper.Set(strings.ToLower(strings.TrimSpace(override[:i])), override[i+1:])
+	}
+
+	return nil
+}
+
+/*
+ * Bind an environment variable to every key of the configuration, so that keys
+ * missing from the file can still be set from the environment. The keys of
+ * maps, such as the names of nodes, are only known if they are in the file.
+ */
+func bindEnv() {
+	keys := configKeys(reflect.TypeOf(Config{}), "")
+	keys = append(keys, viper.AllKeys()...)
+
+	for _, key := range keys {
+		viper.BindEnv(key)
+
+		/*
+		 * The keys of secret files are bound already, and binding their own
+		 * secret files would add another key on every reload.
+		 */
+		if !strings.HasSuffix(key, secretFileSuffix) {
+			viper.BindEnv(key + secretFileSuffix)
+		}
+	}
+}
+
+/* Collect the keys of the fields of a configuration struct and its children. */
+func configKeys(t reflect.Type, prefix string) []string {
+	var keys []string
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("mapstructure"), ",")[0]
+
+		if name == "" || name == "-" {
+			continue
+		}
+
+		key := prefix + name
+
+		if field.Type.Kind() == reflect.Struct {
+			keys = append(keys, configKeys(field.Type, key+".")...)
+		} else {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
+
+/*
+ * Decode the merged settings into the configuration, after reading any secrets
+ * they refer to. Values from the environment and from overrides are strings,
+ * so lists are split on commas and durations are parsed.
+ */
+func decode(config *Config) error {
+	settings, err := readSecretFiles(viper.AllSettings(), "")
+
+	if err != nil {
+		return err
+	}
+
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		Result:           config,
+		WeaklyTypedInput: true,
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(
+			mapstructure.StringToTimeDurationHookFunc(),
+			mapstructure.StringToSliceHookFunc(","),
+		),
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return decoder.Decode(settings)
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+/* The suffix of a key whose value is read from the file it names. */
+const secretFileSuffix = "_file"
+
+/*
+ * Replace each key with the suffix '_file', such as 'password_file', with the
+ * key without the suffix, whose value is the content of the named file less
+ * any trailing newline. This lets secrets mounted by Docker or Kubernetes be
+ * used without writing them into the configuration. The files are read every
+ * time the configuration is loaded, so that rotated secrets are picked up on
+ * reload. The settings are copied rather than changed, since nested values
+ * belong to viper.
+ */
+func readSecretFiles(settings map[string]interface{}, prefix string) (map[string]interface{}, error) {
+	resolved := make(map[string]interface{}, len(settings))
+
+	for key, value := range settings {
+		value, err := readNestedSecretFiles(value, prefix+key)
+
+		if err != nil {
+			return nil, err
+		}
+
+		resolved[key] = value
+	}
+
+	for key, value := range settings {
+		path, ok := value.(string)
+
+		if !ok || !strings.HasSuffix(key, secretFileSuffix) {
+			continue
+		}
+
+		name := strings.TrimSuffix(key, secretFileSuffix)
+
+		if _, ok := settings[name]; ok {
+			return nil, fmt.Errorf("both '%s%s' and '%s%s' are set", prefix, name, prefix, key)
+		}
+
+		content, err := ioutil.ReadFile(path)
+
+		if err != nil {
+			return nil, fmt.Errorf("cannot read the file of '%s%s': %s", prefix, key, err.Error())
+		}
+
+		resolved[name] = strings.TrimRight(string(content), "\r\n")
+		delete(resolved, key)
+	}
+
+	return resolved, nil
+}
+
+/* Look for secret files within maps and lists, such as nodes and partitions. */
+func readNestedSecretFiles(value interface{}, key string) (interface{}, error) {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		return readSecretFiles(value, key+".")
+	case map[interface{}]interface{}:
+		settings := make(map[string]interface{}, len(value))
+
+		for k, v := range value {
+			settings[strings.ToLower(fmt.Sprint(k))] = v
+		}
+
+		return readSecretFiles(settings, key+".")
+	case []interface{}:
+		items := make([]interface{}, len(value))
+
+		for i, item := range value {
+			item, err := readNestedSecretFiles(item, fmt.Sprintf("%s[%d]", key, i))
+
+			if err != nil {
+				return nil, err
+			}
+
+			items[i] = item
+		}
+
+		return items, nil
+	}
+
+	return value, nil
+}
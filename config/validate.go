@@ -0,0 +1,681 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/crunchydata/crunchy-proxy/common"
+)
+
+/* The logging levels accepted by the 'log:levels' section. */
+var logLevels = []string{"debug", "info", "warn", "warning", "error", "fatal", "panic"}
+
+/* The syslog facilities accepted by the 'log:syslog' section. */
+var syslogFacilities = []string{"kern", "user", "mail", "daemon", "auth", "syslog",
+	"lpr", "news", "uucp", "cron", "authpriv", "ftp", "local0", "local1", "local2",
+	"local3", "local4", "local5", "local6", "local7"}
+
+// Problem is an issue found in a configuration by Validate. Errors prevent
+// the proxy from working as configured, while warnings point out settings
+// that are likely to be mistakes.
+type Problem struct {
+	Key     string
+	Message string
+	Warning bool
+}
+
+type validator struct {
+	problems []Problem
+}
+
+func (v *validator) errorf(key string, format string, args ...interface{}) {
+	v.problems = append(v.problems, Problem{Key: key, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) warnf(key string, format string, args ...interface{}) {
+	v.problems = append(v.problems, Problem{Key: key, Message: fmt.Sprintf(format, args...), Warning: true})
+}
+
+// Load reads and parses the configuration file without applying it, so that
+// it can be validated.
+func Load() (Config, error) {
+	return load()
+}
+
+// Validate checks a configuration for missing required settings, malformed
+// addresses and credentials, unknown modes and missing files, and returns
+// the problems found in the order of the sections of the file.
+func Validate(config Config) []Problem {
+	v := &validator{}
+
+	v.checkServer(config.Server)
+
+	for i, listener := range config.Server.Proxy.Listeners {
+		if _, ok := config.Clusters[listener.Cluster]; listener.Cluster != "" && !ok {
+			v.errorf(fmt.Sprintf("server.proxy.listeners[%d].cluster", i), "unknown cluster '%s'", listener.Cluster)
+		}
+	}
+	v.checkNodes(config)
+	v.checkCredentials("credentials", config.Credentials)
+
+	clusters := make([]string, 0, len(config.Clusters))
+
+	for name := range config.Clusters {
+		clusters = append(clusters, name)
+	}
+
+	sort.Strings(clusters)
+
+	for _, name := range clusters {
+		cluster := config.Clusters[name]
+		key := "clusters." + name
+
+		if cluster.HostPort != "" {
+			v.checkAddress(key+".hostport", cluster.HostPort)
+		}
+
+		if cluster.Credentials.Username != "" {
+			v.checkCredentials(key+".credentials", cluster.Credentials)
+		}
+
+		v.checkPartitions(key+".partitions", cluster.Partitions)
+	}
+
+	v.checkPool(config.Pool)
+	v.checkCanary(config)
+	v.checkHealthCheck("healthcheck", config.HealthCheck)
+	v.checkAudit(config.Audit)
+	v.checkLog(config.Log)
+	v.checkFirewall(config.Firewall)
+	v.checkEvents(config.Events)
+	v.checkMirror(config.Mirror, config.Clusters)
+	v.checkTenants(config.Tenants, config.Clusters)
+	v.checkSharding(config.Sharding, config.Clusters)
+
+	if config.Capture.Enable && config.Capture.Path == "" {
+		v.errorf("capture.path", "a file path is required")
+	}
+	v.checkStats(config.Stats)
+
+	if config.Console.Enable && len(config.Console.Users) == 0 {
+		v.warnf("console.users", "the console is enabled but no users may connect to it")
+	}
+
+	if config.Tracing.Enable && (config.Tracing.SampleRatio < 0 || config.Tracing.SampleRatio > 1) {
+		v.warnf("tracing.sampleratio", "%g is not between 0 and 1, all requests will be sampled",
+			config.Tracing.SampleRatio)
+	}
+
+	return v.problems
+}
+
+func (v *validator) checkServer(server ServerConfig) {
+	if server.Proxy.HostPort == "" && server.Proxy.Socket.Path == "" {
+		v.errorf("server.proxy.hostport", "an address or socket for clients is required")
+	} else if server.Proxy.HostPort != "" {
+		v.checkAddress("server.proxy.hostport", server.Proxy.HostPort)
+	}
+
+	v.checkListeners(server.Proxy.Listeners)
+
+	if server.Proxy.ReadHostPort != "" {
+		v.checkAddress("server.proxy.readhostport", server.Proxy.ReadHostPort)
+	}
+
+	if server.Proxy.WriteHostPort != "" {
+		v.checkAddress("server.proxy.writehostport", server.Proxy.WriteHostPort)
+	}
+
+	if server.Admin.HostPort == "" {
+		v.errorf("server.admin.hostport", "an address for the admin server is required")
+	} else {
+		v.checkAddress("server.admin.hostport", server.Admin.HostPort)
+	}
+
+	if server.Metrics.HostPort != "" {
+		v.checkAddress("server.metrics.hostport", server.Metrics.HostPort)
+	}
+
+	v.checkSSL("server.proxy.ssl", server.Proxy.SSL)
+	v.checkSSL("server.admin.ssl", server.Admin.SSL)
+
+	if server.Proxy.IdleWait != "" {
+		v.checkOneOf("server.proxy.idlewait", server.Proxy.IdleWait, common.IDLE_WAIT_READ,
+			common.IDLE_WAIT_POLL)
+	}
+
+	auth := server.Proxy.Auth
+
+	if auth.Method != "" && auth.Method != common.AUTH_METHOD_MD5 && auth.Method != common.AUTH_METHOD_SCRAM {
+		v.errorf("server.proxy.auth.method", "unknown method '%s', expected '%s' or '%s'",
+			auth.Method, common.AUTH_METHOD_MD5, common.AUTH_METHOD_SCRAM)
+	}
+
+	if auth.File != "" {
+		v.checkFile("server.proxy.auth.file", auth.File)
+	}
+}
+
+func (v *validator) checkListeners(listeners []ListenerConfig) {
+	for i, listener := range listeners {
+		key := fmt.Sprintf("server.proxy.listeners[%d]", i)
+
+		if listener.HostPort == "" && listener.Socket.Path == "" {
+			v.errorf(key+".hostport", "an address or socket is required")
+		} else if listener.HostPort != "" {
+			v.checkAddress(key+".hostport", listener.HostPort)
+		}
+
+		if listener.Role != "" {
+			v.checkOneOf(key+".role", listener.Role, common.NODE_ROLE_MASTER, common.NODE_ROLE_REPLICA)
+		}
+
+		if listener.SSL != nil {
+			v.checkSSL(key+".ssl", *listener.SSL)
+		}
+	}
+}
+
+func (v *validator) checkNodes(config Config) {
+	discovery := config.Discovery.DNS.Enable || config.Discovery.Kubernetes.Enable
+
+	if len(config.Nodes) == 0 && !discovery {
+		v.errorf("nodes", "no nodes are configured")
+		return
+	}
+
+	masters := make(map[string][]string)
+
+	for _, name := range sortedNodes(config) {
+		node := config.Nodes[name]
+		key := nodeKey(name, node)
+
+		if node.HostPort == "" {
+			v.errorf(key+".hostport", "an address is required")
+		}
+
+		for _, hostPort := range strings.Split(node.HostPort, ",") {
+			if hostPort != "" {
+				v.checkAddress(key+".hostport", strings.TrimSpace(hostPort))
+			}
+		}
+
+		switch node.Role {
+		case common.NODE_ROLE_MASTER:
+			masters[node.Cluster] = append(masters[node.Cluster], name)
+		case common.NODE_ROLE_REPLICA:
+		default:
+			v.errorf(key+".role", "unknown role '%s', expected '%s' or '%s'",
+				node.Role, common.NODE_ROLE_MASTER, common.NODE_ROLE_REPLICA)
+		}
+
+		if node.PoolMode != "" {
+			v.checkOneOf(key+".poolmode", node.PoolMode, common.POOL_MODE_STATEMENT,
+				common.POOL_MODE_TRANSACTION, common.POOL_MODE_SESSION)
+		}
+
+		if node.TargetSessionAttrs != "" {
+			v.checkOneOf(key+".targetsessionattrs", node.TargetSessionAttrs,
+				common.TARGET_SESSION_ANY, common.TARGET_SESSION_READ_WRITE)
+		}
+
+		if node.Weight < 0 {
+			v.errorf(key+".weight", "the weight may not be negative")
+		}
+
+		if node.HealthCheck != nil {
+			v.checkHealthCheck(key+".healthcheck", *node.HealthCheck)
+		}
+	}
+
+	if discovery {
+		return
+	}
+
+	clusters := make([]string, 0, len(config.Clusters))
+
+	for name := range config.Clusters {
+		clusters = append(clusters, name)
+	}
+
+	sort.Strings(clusters)
+
+	for _, cluster := range append([]string{""}, clusters...) {
+		key := "nodes"
+
+		if cluster != "" {
+			key = fmt.Sprintf("clusters.%s.nodes", cluster)
+		}
+
+		switch len(masters[cluster]) {
+		case 0:
+			if cluster != "" || len(config.Nodes) > 0 {
+				v.warnf(key, "no master is configured, so writes will fail")
+			}
+		case 1:
+		default:
+			v.errorf(key, "more than one master is configured: %s",
+				strings.Join(masters[cluster], ", "))
+		}
+	}
+}
+
+// DialNodes opens a TCP connection to each address of each node and returns
+// an error for each address that cannot be reached within the timeout.
+func DialNodes(config Config, timeout time.Duration) []Problem {
+	v := &validator{}
+
+	for _, name := range sortedNodes(config) {
+		node := config.Nodes[name]
+
+		for _, hostPort := range strings.Split(node.HostPort, ",") {
+			hostPort = strings.TrimSpace(hostPort)
+
+			/* Malformed addresses are already reported by Validate. */
+			if _, _, err := net.SplitHostPort(hostPort); err != nil {
+				continue
+			}
+
+			conn, err := net.DialTimeout("tcp", hostPort, timeout)
+
+			if err != nil {
+				v.errorf(nodeKey(name, node)+".hostport", "cannot connect to '%s': %s", hostPort, err.Error())
+				continue
+			}
+
+			conn.Close()
+		}
+	}
+
+	return v.problems
+}
+
+/* The names of the nodes, in order. */
+func sortedNodes(config Config) []string {
+	names := make([]string, 0, len(config.Nodes))
+
+	for name := range config.Nodes {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+/* The key of a node, which is within its cluster if it has one. */
+func nodeKey(name string, node common.Node) string {
+	if node.Cluster != "" {
+		return fmt.Sprintf("clusters.%s.nodes.%s", node.Cluster, name)
+	}
+
+	return "nodes." + name
+}
+
+/*
+ * Check the credentials used to connect to the nodes, which must have a clear
+ * text password since the proxy authenticates on the client's behalf.
+ */
+func (v *validator) checkCredentials(key string, credentials common.Credentials) {
+	if credentials.Username == "" {
+		v.errorf(key+".username", "a username is required")
+	}
+
+	password := credentials.Password
+
+	if strings.HasPrefix(password, "SCRAM-SHA-256$") {
+		v.errorf(key+".password", "a SCRAM verifier cannot be used to log in to the nodes, the clear text password is required")
+	} else if len(password) == 35 && strings.HasPrefix(password, "md5") {
+		v.warnf(key+".password", "the password looks like an MD5 hash, but the clear text password is required")
+	}
+
+	v.checkSSL(key+".ssl", credentials.SSL)
+}
+
+func (v *validator) checkPartitions(key string, partitions []PartitionConfig) {
+	for i, partition := range partitions {
+		partitionKey := fmt.Sprintf("%s[%d]", key, i)
+
+		if partition.Username == "" {
+			v.errorf(partitionKey+".username", "a username is required")
+		}
+
+		if partition.Capacity < 0 {
+			v.errorf(partitionKey+".capacity", "the capacity may not be negative")
+		}
+	}
+}
+
+func (v *validator) checkPool(pool PoolConfig) {
+	if pool.Capacity < 0 {
+		v.errorf("pool.capacity", "the capacity may not be negative")
+	}
+
+	if pool.Mode != "" {
+		v.checkOneOf("pool.mode", pool.Mode, common.POOL_MODE_STATEMENT,
+			common.POOL_MODE_TRANSACTION, common.POOL_MODE_SESSION)
+	}
+
+	if pool.Balancer != "" {
+		v.checkOneOf("pool.balancer", pool.Balancer, common.BALANCER_ROUND_ROBIN,
+			common.BALANCER_LEAST_CONNECTIONS, common.BALANCER_WEIGHTED, common.BALANCER_LATENCY)
+	}
+
+	if pool.WarmUp != "" {
+		v.checkOneOf("pool.warmup", pool.WarmUp, common.POOL_WARMUP_EAGER, common.POOL_WARMUP_LAZY)
+	}
+
+	if pool.Validate != "" {
+		v.checkOneOf("pool.validate", pool.Validate, common.POOL_VALIDATE_TCP,
+			common.POOL_VALIDATE_SYNC, common.POOL_VALIDATE_QUERY)
+	}
+
+	if pool.Consistency != "" {
+		v.checkOneOf("pool.consistency", pool.Consistency, common.CONSISTENCY_NONE,
+			common.CONSISTENCY_LSN)
+	}
+
+	if pool.StickyReads < 0 {
+		v.errorf("pool.stickyreads", "the window may not be negative")
+	}
+
+	if pool.MaxWaiting < 0 {
+		v.errorf("pool.maxwaiting", "the number of clients may not be negative")
+	}
+
+	if pool.Retry.Attempts < 0 {
+		v.errorf("pool.retry.attempts", "the number of attempts may not be negative")
+	}
+
+	if pool.Retry.Node != "" {
+		v.checkOneOf("pool.retry.node", pool.Retry.Node, common.RETRY_NODE_SAME, common.RETRY_NODE_OTHER)
+	}
+
+	for i, code := range pool.Retry.Codes {
+		if len(code) != 5 {
+			v.errorf(fmt.Sprintf("pool.retry.codes[%d]", i), "'%s' is not a SQLSTATE", code)
+		}
+	}
+
+	if pool.Multiplex && pool.PassThrough {
+		v.warnf("pool.passthrough", "multiplexed sessions are never passed through")
+	}
+
+	if pool.Capacity > 0 && pool.MinIdle > pool.Capacity {
+		v.warnf("pool.minidle", "%d is more than the capacity of %d", pool.MinIdle, pool.Capacity)
+	}
+
+	v.checkPartitions("pool.partitions", pool.Partitions)
+}
+
+func (v *validator) checkHealthCheck(key string, healthCheck common.HealthCheckConfig) {
+	if healthCheck.Probe == "" {
+		return
+	}
+
+	v.checkOneOf(key+".probe", healthCheck.Probe, common.PROBE_TCP, common.PROBE_SQL,
+		common.PROBE_LAG, common.PROBE_SCRIPT)
+
+	if healthCheck.Probe == common.PROBE_SCRIPT {
+		if healthCheck.Script == "" {
+			v.errorf(key+".script", "a script is required by the 'script' probe")
+		} else {
+			v.checkFile(key+".script", healthCheck.Script)
+		}
+	}
+}
+
+func (v *validator) checkAudit(audit AuditConfig) {
+	if !audit.Enable {
+		return
+	}
+
+	switch audit.Sink {
+	case "", common.AUDIT_SINK_FILE:
+		if audit.File.Path == "" {
+			v.errorf("audit.file.path", "a file path is required")
+		}
+	case common.AUDIT_SINK_SYSLOG:
+	default:
+		v.checkOneOf("audit.sink", audit.Sink, common.AUDIT_SINK_FILE, common.AUDIT_SINK_SYSLOG)
+	}
+}
+
+func (v *validator) checkLog(logConfig LogConfig) {
+	switch logConfig.Output {
+	case "", common.LOG_OUTPUT_STDOUT, common.LOG_OUTPUT_JOURNALD:
+	case common.LOG_OUTPUT_FILE:
+		if logConfig.File == "" {
+			v.errorf("log.file", "a file path is required")
+		}
+	case common.LOG_OUTPUT_SYSLOG:
+		if logConfig.Syslog.Facility != "" {
+			v.checkOneOf("log.syslog.facility", strings.ToLower(logConfig.Syslog.Facility), syslogFacilities...)
+		}
+	default:
+		v.checkOneOf("log.output", logConfig.Output, common.LOG_OUTPUT_STDOUT,
+			common.LOG_OUTPUT_FILE, common.LOG_OUTPUT_SYSLOG, common.LOG_OUTPUT_JOURNALD)
+	}
+
+	components := make([]string, 0, len(logConfig.Levels))
+
+	for component := range logConfig.Levels {
+		components = append(components, component)
+	}
+
+	sort.Strings(components)
+
+	for _, component := range components {
+		v.checkOneOf("log.levels."+component, strings.ToLower(logConfig.Levels[component]), logLevels...)
+	}
+}
+
+func (v *validator) checkStats(stats StatsConfig) {
+	if stats.SaveInterval < 0 {
+		v.errorf("stats.saveinterval", "the interval may not be negative")
+	}
+
+	if stats.File != "" {
+		v.checkFile("stats.file", filepath.Dir(stats.File))
+	}
+}
+
+func (v *validator) checkFirewall(firewall FirewallConfig) {
+	for i, rule := range firewall.Rules {
+		key := fmt.Sprintf("firewall.rules[%d]", i)
+
+		if rule.Name == "" {
+			v.warnf(key+".name", "the rule has no name to report when it blocks a query")
+		}
+
+		if rule.Pattern != "" {
+			if _, err := regexp.Compile(rule.Pattern); err != nil {
+				v.errorf(key+".pattern", "invalid regular expression: %s", err.Error())
+			}
+		}
+
+		if rule.Pattern == "" && rule.Fingerprint == "" && len(rule.NoWhere) == 0 {
+			v.warnf(key, "the rule has no pattern, fingerprint or tables, so it blocks every query")
+		}
+	}
+}
+
+func (v *validator) checkEvents(events EventsConfig) {
+	if !events.Enable {
+		return
+	}
+
+	if events.Webhook.URL == "" && events.Exec.Command == "" {
+		v.warnf("events", "events are enabled but neither a webhook nor a command is configured")
+	}
+
+	if events.Webhook.URL != "" {
+		if u, err := url.Parse(events.Webhook.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			v.errorf("events.webhook.url", "'%s' is not an HTTP or HTTPS URL", events.Webhook.URL)
+		}
+	}
+}
+
+func (v *validator) checkCanary(config Config) {
+	canary := config.Pool.Canary
+
+	if canary.Node == "" {
+		return
+	}
+
+	if canary.Percent < 0 || canary.Percent > 100 {
+		v.errorf("pool.canary.percent", "%g is not between 0 and 100", canary.Percent)
+	}
+
+	/* Discovered nodes are not known until the proxy runs. */
+	if config.Discovery.DNS.Enable || config.Discovery.Kubernetes.Enable {
+		return
+	}
+
+	if node, ok := config.Nodes[canary.Node]; !ok {
+		v.errorf("pool.canary.node", "'%s' is not a configured node", canary.Node)
+	} else if node.Role != common.NODE_ROLE_REPLICA {
+		v.errorf("pool.canary.node", "'%s' is not a replica", canary.Node)
+	}
+}
+
+func (v *validator) checkTenants(tenants TenantsConfig, clusters map[string]ClusterConfig) {
+	for i, route := range tenants.Routes {
+		key := fmt.Sprintf("tenants.routes[%d]", i)
+
+		if _, err := path.Match(route.Match, ""); err != nil || route.Match == "" {
+			v.errorf(key+".match", "'%s' is not a valid pattern", route.Match)
+		}
+
+		if _, ok := clusters[route.Cluster]; route.Cluster != "" && !ok {
+			v.errorf(key+".cluster", "unknown cluster '%s'", route.Cluster)
+		}
+	}
+}
+
+func (v *validator) checkSharding(sharding ShardingConfig, clusters map[string]ClusterConfig) {
+	if !sharding.Enable {
+		return
+	}
+
+	if len(sharding.Shards) == 0 {
+		v.warnf("sharding.shards", "sharding is enabled but no shards are configured")
+	}
+
+	seen := make(map[string]bool, len(sharding.Shards))
+
+	for i, shard := range sharding.Shards {
+		key := fmt.Sprintf("sharding.shards[%d]", i)
+
+		if _, ok := clusters[shard]; !ok {
+			v.errorf(key, "unknown cluster '%s'", shard)
+		} else if seen[shard] {
+			v.errorf(key, "the cluster '%s' is listed more than once", shard)
+		}
+
+		seen[shard] = true
+	}
+}
+
+func (v *validator) checkMirror(mirror MirrorConfig, clusters map[string]ClusterConfig) {
+	if !mirror.Enable {
+		return
+	}
+
+	if mirror.Queries != "" {
+		v.checkOneOf("mirror.queries", mirror.Queries, common.MIRROR_QUERIES_READ, common.MIRROR_QUERIES_ALL)
+	}
+
+	cluster, ok := clusters[mirror.Cluster]
+
+	if !ok {
+		v.errorf("mirror.cluster", "'%s' is not a configured cluster", mirror.Cluster)
+	} else if len(cluster.Databases) > 0 {
+		v.warnf("mirror.cluster", "the shadow cluster '%s' also serves clients", mirror.Cluster)
+	}
+}
+
+/* Check the certificate and key files of an SSL section that is enabled. */
+func (v *validator) checkSSL(key string, ssl common.SSLConfig) {
+	if !ssl.Enable {
+		return
+	}
+
+	files := []struct {
+		name string
+		path string
+	}{
+		{"sslcert", ssl.SSLCert},
+		{"sslkey", ssl.SSLKey},
+		{"sslrootca", ssl.SSLRootCA},
+		{"sslservercert", ssl.SSLServerCert},
+		{"sslserverkey", ssl.SSLServerKey},
+		{"sslserverca", ssl.SSLServerCA},
+	}
+
+	for _, file := range files {
+		if file.path != "" {
+			v.checkFile(key+"."+file.name, file.path)
+		}
+	}
+}
+
+/*
+ * Check that an address has a host, which may be empty, and a valid port. IPv6
+ * addresses must be enclosed in brackets to be told apart from the port.
+ */
+func (v *validator) checkAddress(key string, address string) {
+	_, port, err := net.SplitHostPort(address)
+
+	if err != nil && strings.Count(address, ":") > 1 && !strings.HasPrefix(address, "[") {
+		v.errorf(key, "'%s' is not a valid host:port address, IPv6 addresses must be enclosed in brackets, as in '[::1]:5432'", address)
+		return
+	} else if err != nil {
+		v.errorf(key, "'%s' is not a valid host:port address", address)
+		return
+	}
+
+	if _, err := net.LookupPort("tcp", port); err != nil {
+		v.errorf(key, "'%s' is not a valid port", port)
+	}
+}
+
+func (v *validator) checkFile(key string, path string) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		v.errorf(key, "'%s' does not exist", path)
+	} else if err != nil {
+		v.errorf(key, "cannot read '%s': %s", path, err.Error())
+	}
+}
+
+func (v *validator) checkOneOf(key string, value string, allowed ...string) {
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+
+	v.errorf(key, "unknown value '%s', expected one of '%s'", value, strings.Join(allowed, "', '"))
+}
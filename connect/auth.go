@@ -29,11 +29,16 @@ import (
 
 /*
  * Handle authentication requests that are sent by the backend to the client.
+ * On success, the response from the backend that contained the
+ * AuthenticationOk message is returned as well. It usually also contains the
+ * rest of the startup messages, such as BackendKeyData and ReadyForQuery.
  *
  * connection - the connection to authenticate against.
  * message - the authentication message sent by the backend.
+ * username - the user to authenticate as.
+ * password - the password of the user.
  */
-func HandleAuthenticationRequest(connection net.Conn, message []byte) bool {
+func HandleAuthenticationRequest(connection net.Conn, message []byte, username string, password string) (bool, []byte) {
 	var msgLength int32
 	var authType int32
 
@@ -50,10 +55,10 @@ func HandleAuthenticationRequest(connection net.Conn, message []byte) bool {
 		log.Error("KerberosV5 authentication is not currently supported.")
 	case protocol.AuthenticationClearText:
 		log.Info("Authenticating with clear text password.")
-		return handleAuthClearText(connection)
+		return handleAuthClearText(connection, password)
 	case protocol.AuthenticationMD5:
 		log.Info("Authenticating with MD5 password.")
-		return handleAuthMD5(connection, message)
+		return handleAuthMD5(connection, message, username, password)
 	case protocol.AuthenticationSCM:
 		log.Error("SCM authentication is not currently supported.")
 	case protocol.AuthenticationGSS:
@@ -62,14 +67,17 @@ func HandleAuthenticationRequest(connection net.Conn, message []byte) bool {
 		log.Error("GSS authentication is not currently supported.")
 	case protocol.AuthenticationSSPI:
 		log.Error("SSPI authentication is not currently supported.")
+	case protocol.AuthenticationSASL:
+		log.Info("Authenticating with SCRAM-SHA-256.")
+		return handleAuthSCRAM(connection, message, password)
 	case protocol.AuthenticationOk:
 		/* Covers the case where the authentication type is 'cert' or 'trust' */
-		return true
+		return true, message
 	default:
 		log.Errorf("Unknown authentication method: %d", authType)
 	}
 
-	return false
+	return false, nil
 }
 
 func createMD5Password(username string, password string, salt string) string {
@@ -84,11 +92,7 @@ func createMD5Password(username string, password string, salt string) string {
 	return fmt.Sprintf("md5%x", md5.Sum([]byte(passwordString)))
 }
 
-func handleAuthMD5(connection net.Conn, message []byte) bool {
-	// Get the authentication credentials.
-	creds := config.GetCredentials()
-	username := creds.Username
-	password := creds.Password
+func handleAuthMD5(connection net.Conn, message []byte, username string, password string) (bool, []byte) {
 	salt := string(message[9:13])
 
 	password = createMD5Password(username, password, salt)
@@ -106,19 +110,19 @@ func handleAuthMD5(connection net.Conn, message []byte) bool {
 	}
 
 	// Read response from password message.
-	message, _, err = Receive(connection)
+	message, err = ReceiveMessage(connection)
 
 	// Check that read was successful.
 	if err != nil {
 		log.Error("Error receiving authentication response from the backend.")
 		log.Errorf("Error: %s", err.Error())
+		return false, nil
 	}
 
-	return protocol.IsAuthenticationOk(message)
+	return protocol.IsAuthenticationOk(message), message
 }
 
-func handleAuthClearText(connection net.Conn) bool {
-	password := config.GetString("credentials.password")
+func handleAuthClearText(connection net.Conn, password string) (bool, []byte) {
 	passwordMessage := protocol.CreatePasswordMessage(password)
 
 	_, err := connection.Write(passwordMessage)
@@ -128,43 +132,201 @@ func handleAuthClearText(connection net.Conn) bool {
 		log.Errorf("Error: %s", err.Error())
 	}
 
-	response := make([]byte, 4096)
-	_, err = connection.Read(response)
+	response, err := ReceiveMessage(connection)
 
 	if err != nil {
 		log.Error("Error receiving clear text authentication response.")
 		log.Errorf("Error: %s", err.Error())
+		return false, nil
 	}
 
-	return protocol.IsAuthenticationOk(response)
+	return protocol.IsAuthenticationOk(response), response
+}
+
+func handleAuthSCRAM(connection net.Conn, message []byte, password string) (bool, []byte) {
+	var supported bool
+
+	/* Make sure the backend offers a mechanism supported by the proxy. */
+	for _, mechanism := range protocol.GetSASLMechanisms(message) {
+		if mechanism == protocol.SASLMechanismSCRAMSHA256 {
+			supported = true
+		}
+	}
+
+	if !supported {
+		log.Error("The backend does not offer the SCRAM-SHA-256 mechanism.")
+		return false, nil
+	}
+
+	scram, err := newSCRAMClient(password)
+
+	if err != nil {
+		log.Error("Error creating SCRAM client nonce.")
+		log.Errorf("Error: %s", err.Error())
+		return false, nil
+	}
+
+	/* Send the client-first-message to the backend. */
+	initialResponse := protocol.CreateSASLInitialResponseMessage(
+		protocol.SASLMechanismSCRAMSHA256, scram.clientFirstMessage())
+
+	if _, err = Send(connection, initialResponse); err != nil {
+		log.Error("Error sending SASL initial response to the backend.")
+		log.Errorf("Error: %s", err.Error())
+		return false, nil
+	}
+
+	/* Receive the server-first-message. */
+	message, err = ReceiveMessage(connection)
+
+	if !isSASLMessage(message, protocol.AuthenticationSASLContinue, err) {
+		return false, nil
+	}
+
+	/* Send the client-final-message to the backend. */
+	clientFinal, err := scram.clientFinalMessage(protocol.GetSASLData(message))
+
+	if err != nil {
+		log.Errorf("Error: %s", err.Error())
+		return false, nil
+	}
+
+	if _, err = Send(connection, protocol.CreateSASLResponseMessage(clientFinal)); err != nil {
+		log.Error("Error sending SASL response to the backend.")
+		log.Errorf("Error: %s", err.Error())
+		return false, nil
+	}
+
+	/* Receive and verify the server-final-message. */
+	message, err = ReceiveMessage(connection)
+
+	if !isSASLMessage(message, protocol.AuthenticationSASLFinal, err) {
+		return false, nil
+	}
+
+	if err = scram.verifyServerFinal(protocol.GetSASLData(message)); err != nil {
+		log.Errorf("Error: %s", err.Error())
+		return false, nil
+	}
+
+	/* The AuthenticationOk message follows the server-final-message. */
+	if message, err = ReceiveMessage(connection); err != nil {
+		log.Error("Error receiving authentication response from the backend.")
+		log.Errorf("Error: %s", err.Error())
+		return false, nil
+	}
+
+	return protocol.IsAuthenticationOk(message), message
+}
+
+/*
+ * Check that a message received during a SASL exchange is of the expected
+ * authentication type, logging the reason if it is not.
+ */
+func isSASLMessage(message []byte, authType int32, err error) bool {
+	if err != nil {
+		log.Error("Error receiving SASL message from the backend.")
+		log.Errorf("Error: %s", err.Error())
+		return false
+	}
+
+	if protocol.GetMessageType(message) == protocol.ErrorMessageType {
+		log.Errorf("Error: %s", protocol.ParseError(message).Error())
+		return false
+	}
+
+	if protocol.GetMessageType(message) != protocol.AuthenticationMessageType ||
+		protocol.GetAuthenticationType(message) != authType {
+		log.Error("Unexpected message received during SASL authentication.")
+		return false
+	}
+
+	return true
+}
+
+/*
+ * Remove the channel binding mechanisms from an AuthenticationSASL message.
+ *
+ * The TLS connection between the client and the proxy is not the same as the
+ * one between the proxy and the backend, so SCRAM channel binding can never
+ * succeed through the proxy. Only offering the plain mechanism keeps clients
+ * from attempting it.
+ */
+func removeChannelBinding(message []byte) []byte {
+	var mechanisms []string
+
+	for _, mechanism := range protocol.GetSASLMechanisms(message) {
+		if mechanism != protocol.SASLMechanismSCRAMSHA256Plus {
+			mechanisms = append(mechanisms, mechanism)
+		}
+	}
+
+	return protocol.CreateAuthenticationSASLMessage(mechanisms)
+}
+
+// MasterError is returned by AuthenticateClient when the client could not be
+// authenticated because the master node could not be reached or failed
+// partway through, rather than because it rejected the client.
+type MasterError struct {
+	Node string
+	Err  error
+}
+
+func (e *MasterError) Error() string {
+	return fmt.Sprintf("master node '%s': %s", e.Node, e.Err.Error())
 }
 
 // AuthenticateClient - Establish and authenticate client connection to the backend.
 //
 //  This function simply handles the passing of messages from the client to the
 //  backend necessary for startup/authentication of a connection. All
-//  communication is between the client and the master node. If the client
-//  authenticates successfully with the master node, then 'true' is returned and
-//  the authenticating connection is terminated.
-func AuthenticateClient(client net.Conn, message []byte, length int) (bool, error) {
+//  communication is between the client and the master node of the client's
+//  cluster. If the client authenticates successfully with the master node, then
+//  'true' is returned and the authenticating connection is terminated.
+//
+//  The processID and secretKey are sent to the client in place of the
+//  BackendKeyData of the authenticating connection.
+//
+//  If an LDAP server, userlist file or lookup query is configured, then the
+//  proxy verifies the client's password itself and logs into the master node
+//  with the backend credentials instead.
+func AuthenticateClient(client net.Conn, cluster string, message []byte, length int, processID int32, secretKey int32) (bool, error) {
 	var err error
 
-	nodes := config.GetNodes()
+	authConfig := config.GetAuthConfig()
+	parameters := GetStartupParameters(message[:length])
 
-	node := nodes["master"]
+	if ldapConfig, ok := findLDAPConfig(authConfig.LDAP, parameters["user"],
+		parameters["database"]); ok {
+		return authenticateClientLDAP(client, cluster, parameters, processID,
+			secretKey, ldapConfig)
+	}
+
+	if authConfig.File != "" || authConfig.Query != "" {
+		return authenticateClientLocally(client, cluster, message, length, processID,
+			secretKey, authConfig)
+	}
+
+	name, node := config.GetMasterNode(cluster)
 
 	/* Establish a connection with the master node. */
-	log.Debug("client auth: connecting to 'master' node")
+	log.Debugf("client auth: connecting to master node '%s'", name)
 	master, err := Connect(node.HostPort)
 
 	if err != nil {
 		log.Error("An error occurred connecting to the master node")
 		log.Errorf("Error %s", err.Error())
-		return false, err
+		return false, &MasterError{name, err}
 	}
 
 	defer master.Close()
 
+	/*
+	 * A master that hangs while the client authenticates fails the
+	 * authentication after the auth timeout, as the client would.
+	 */
+	master.SetDeadline(Deadline(config.GetAuthTimeout()))
+
 	/* Relay the startup message to master node. */
 	log.Debug("client auth: relay startup message to 'master' node")
 	_, err = master.Write(message[:length])
@@ -176,7 +338,22 @@ func AuthenticateClient(client net.Conn, message []byte, length int) (bool, erro
 	if err != nil {
 		log.Error("An error occurred receiving startup response.")
 		log.Errorf("Error %s", err.Error())
-		return false, err
+		return false, &MasterError{name, err}
+	}
+
+	/* Relay the master's answer to the client's protocol options, if any. */
+	negotiation, message, err := splitNegotiation(master, message[:length])
+
+	if err != nil {
+		log.Error("An error occurred receiving startup response.")
+		log.Errorf("Error %s", err.Error())
+		return false, &MasterError{name, err}
+	}
+
+	length = len(message)
+
+	if negotiation != nil {
+		Send(client, negotiation)
 	}
 
 	/*
@@ -187,6 +364,35 @@ func AuthenticateClient(client net.Conn, message []byte, length int) (bool, erro
 
 	for !protocol.IsAuthenticationOk(message) &&
 		(messageType != protocol.ErrorMessageType) {
+		if messageType == protocol.AuthenticationMessageType {
+			switch protocol.GetAuthenticationType(message) {
+			case protocol.AuthenticationSASL:
+				message = removeChannelBinding(message)
+				length = len(message)
+			case protocol.AuthenticationSASLFinal:
+				/*
+				 * The client does not respond to the server-final-message, so
+				 * relay it and continue with the next message from the master
+				 * node. This is usually AuthenticationOk, which might have been
+				 * received in the same read.
+				 */
+				finalLength := int(protocol.GetMessageLength(message)) + 1
+				Send(client, message[:finalLength])
+
+				if finalLength < length {
+					message = message[finalLength:length]
+					length = length - finalLength
+				} else if message, length, err = Receive(master); err != nil {
+					log.Error("An error occurred receiving SASL response.")
+					log.Errorf("Error %s", err.Error())
+					return false, &MasterError{name, err}
+				}
+
+				messageType = protocol.GetMessageType(message)
+				continue
+			}
+		}
+
 		Send(client, message[:length])
 		message, length, err = Receive(client)
 
@@ -205,11 +411,17 @@ func AuthenticateClient(client net.Conn, message []byte, length int) (bool, erro
 			log.Debug("If the client is 'psql' and the authentication method " +
 				"was 'password', then this behavior is expected.")
 			return false, err
+		} else if err != nil {
+			return false, err
 		}
 
 		Send(master, message[:length])
 
-		message, length, err = Receive(master)
+		if message, length, err = Receive(master); err != nil {
+			log.Error("An error occurred receiving authentication response.")
+			log.Errorf("Error %s", err.Error())
+			return false, &MasterError{name, err}
+		}
 
 		messageType = protocol.GetMessageType(message)
 	}
@@ -223,6 +435,14 @@ func AuthenticateClient(client net.Conn, message []byte, length int) (bool, erro
 	if protocol.IsAuthenticationOk(message) {
 		termMsg := protocol.GetTerminateMessage()
 		Send(master, termMsg)
+
+		/*
+		 * The key data belongs to the authenticating connection, which is
+		 * about to be terminated. Replace it with the key data for the client
+		 * session so that cancel requests can be routed by the proxy.
+		 */
+		protocol.SetBackendKeyData(message[:length], processID, secretKey)
+
 		Send(client, message[:length])
 		return true, nil
 	}
@@ -240,11 +460,46 @@ func AuthenticateClient(client net.Conn, message []byte, length int) (bool, erro
 	return false, err
 }
 
-func ValidateClient(message []byte) bool {
-	var clientUser string
-	var clientDatabase string
+// splitNegotiation separates a NegotiateProtocolVersion message at the start
+// of the master's first response from the messages that follow it. The master
+// sends one before anything else if the client asked for protocol options
+// that it does not recognize, and the client does not respond to it. If
+// nothing follows it, then the next response of the master is read.
+func splitNegotiation(master net.Conn, message []byte) ([]byte, []byte, error) {
+	if len(message) < 5 || protocol.GetMessageType(message) != protocol.NegotiateMessageType {
+		return nil, message, nil
+	}
+
+	negotiationLength := int(protocol.GetMessageLength(message)) + 1
 
-	creds := config.GetCredentials()
+	if negotiationLength >= len(message) {
+		rest, length, err := Receive(master)
+		return message, rest[:length], err
+	}
+
+	return message[:negotiationLength], message[negotiationLength:], nil
+}
+
+// ValidateClient checks that the user and database of the client are one of
+// the partitions of the cluster.
+func ValidateClient(message []byte, cluster string) bool {
+	parameters := GetStartupParameters(message)
+
+	for _, partition := range config.GetPartitions(cluster) {
+		if parameters["user"] == partition.Username &&
+			parameters["database"] == partition.Database {
+			return true
+		}
+	}
+
+	return false
+}
+
+// GetStartupParameters reads the parameters provided by a client startup
+// message. If the client did not provide a database, then the database with
+// the same name as the user is used.
+func GetStartupParameters(message []byte) map[string]string {
+	parameters := make(map[string]string)
 
 	startup := protocol.NewMessageBuffer(message)
 
@@ -253,17 +508,22 @@ func ValidateClient(message []byte) bool {
 	for {
 		param, err := startup.ReadString()
 
-		if err == io.EOF || param == "\x00" {
+		if err == io.EOF || param == "\x00" || param == "" {
 			break
 		}
 
-		switch param {
-		case "user":
-			clientUser, err = startup.ReadString()
-		case "database":
-			clientDatabase, err = startup.ReadString()
+		value, err := startup.ReadString()
+
+		if err != nil {
+			break
 		}
+
+		parameters[param] = value
+	}
+
+	if parameters["database"] == "" {
+		parameters["database"] = parameters["user"]
 	}
 
-	return (clientUser == creds.Username && clientDatabase == creds.Database)
+	return parameters
 }
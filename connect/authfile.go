@@ -0,0 +1,427 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package connect
+
+import (
+	"bufio"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/crunchydata/crunchy-proxy/common"
+	"github.com/crunchydata/crunchy-proxy/config"
+	"github.com/crunchydata/crunchy-proxy/protocol"
+	"github.com/crunchydata/crunchy-proxy/util/log"
+)
+
+/*
+ * Authenticate a client against the password from the userlist file or the
+ * lookup query rather than the master node. Once the client's password has
+ * been verified, the master node is logged into with the backend credentials
+ * of the client's partition, and its startup response, which carries the
+ * server parameters, is relayed to the client in the same way as when
+ * authenticating against the master node.
+ */
+func authenticateClientLocally(client net.Conn, cluster string, message []byte, length int, processID int32, secretKey int32, authConfig config.AuthConfig) (bool, error) {
+	parameters := GetStartupParameters(message[:length])
+	username := parameters["user"]
+
+	password, found, err := lookupPassword(cluster, username, authConfig)
+
+	if err != nil {
+		log.Error("An error occurred looking up the password of the client.")
+		log.Errorf("Error: %s", err.Error())
+		sendAuthError(client, protocol.ErrorCodeInvalidAuthorizationSpecification,
+			"the proxy could not look up the password of the user")
+		return false, err
+	}
+
+	/*
+	 * A user that is not found still goes through the exchange with a random
+	 * password, so that clients cannot tell which users exist.
+	 */
+	if !found {
+		password = randomPassword()
+	}
+
+	var verified bool
+
+	if strings.HasPrefix(password, protocol.SASLMechanismSCRAMSHA256+"$") ||
+		(!isMD5Hash(password) && authConfig.Method == common.AUTH_METHOD_SCRAM) {
+		verified, err = verifySCRAM(client, username, password)
+	} else {
+		verified, err = verifyMD5(client, username, password)
+	}
+
+	if err != nil || !verified || !found {
+		if err == nil {
+			err = fmt.Errorf("password authentication failed for user '%s'", username)
+		}
+
+		sendAuthError(client, protocol.ErrorCodeInvalidPassword,
+			fmt.Sprintf("password authentication failed for user \"%s\"", username))
+		return false, err
+	}
+
+	return loginBackend(client, cluster, parameters, processID, secretKey)
+}
+
+/*
+ * Look up the password of a user, first in the userlist file and then, for
+ * users that are not in the file, with the lookup query.
+ */
+func lookupPassword(cluster string, username string, authConfig config.AuthConfig) (string, bool, error) {
+	if authConfig.File != "" {
+		users, err := readUserlist(authConfig.File)
+
+		if err != nil {
+			return "", false, err
+		}
+
+		if password, ok := users[username]; ok {
+			return password, true, nil
+		}
+	}
+
+	if authConfig.Query != "" {
+		return queryPassword(cluster, username, authConfig)
+	}
+
+	return "", false, nil
+}
+
+/*
+ * Read a userlist file in the format used by PgBouncer's auth_file. Each line
+ * holds a double quoted user name followed by a double quoted password, which
+ * is either an MD5 hash, a SCRAM-SHA-256 verifier or clear text. A double
+ * quote inside a value is written as two double quotes. Blank lines and lines
+ * starting with ';' or '#' are ignored.
+ */
+func readUserlist(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	users := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+
+		if text == "" || text[0] == ';' || text[0] == '#' {
+			continue
+		}
+
+		username, rest, ok := readQuoted(text)
+
+		if !ok {
+			return nil, fmt.Errorf("malformed user name on line %d of %s", line, path)
+		}
+
+		password, _, ok := readQuoted(strings.TrimSpace(rest))
+
+		if !ok {
+			return nil, fmt.Errorf("malformed password on line %d of %s", line, path)
+		}
+
+		users[username] = password
+	}
+
+	return users, scanner.Err()
+}
+
+/*
+ * Read a double quoted value from the start of the text, returning the value
+ * and the rest of the text.
+ */
+func readQuoted(text string) (string, string, bool) {
+	if len(text) == 0 || text[0] != '"' {
+		return "", text, false
+	}
+
+	var value []byte
+
+	for i := 1; i < len(text); i++ {
+		if text[i] != '"' {
+			value = append(value, text[i])
+		} else if i+1 < len(text) && text[i+1] == '"' {
+			value = append(value, '"')
+			i++
+		} else {
+			return string(value), text[i+1:], true
+		}
+	}
+
+	return "", text, false
+}
+
+func isMD5Hash(password string) bool {
+	return len(password) == 35 && strings.HasPrefix(password, "md5")
+}
+
+func randomPassword() string {
+	random := make([]byte, scramNonceLength)
+	rand.Read(random)
+
+	return fmt.Sprintf("%x", random)
+}
+
+/*
+ * Verify the client's password with an MD5 challenge. The stored password is
+ * either the MD5 hash of the password and user name, or clear text from which
+ * that hash is computed.
+ */
+func verifyMD5(client net.Conn, username string, password string) (bool, error) {
+	salt := make([]byte, 4)
+
+	if _, err := rand.Read(salt); err != nil {
+		return false, err
+	}
+
+	if !isMD5Hash(password) {
+		password = fmt.Sprintf("md5%x", md5.Sum([]byte(password+username)))
+	}
+
+	expected := fmt.Sprintf("md5%x", md5.Sum(append([]byte(password[3:]), salt...)))
+
+	if _, err := Send(client, protocol.CreateAuthenticationMD5Message(salt)); err != nil {
+		return false, err
+	}
+
+	response, length, err := Receive(client)
+
+	if err != nil {
+		return false, err
+	}
+
+	if protocol.GetMessageType(response[:length]) != protocol.PasswordMessageType {
+		return false, errors.New("expected a password message from the client")
+	}
+
+	actual := protocol.GetPassword(response[:length])
+
+	return subtle.ConstantTimeCompare([]byte(actual), []byte(expected)) == 1, nil
+}
+
+/*
+ * Verify the client's password with a SCRAM-SHA-256 exchange. The stored
+ * password is either a SCRAM-SHA-256 verifier or clear text from which one is
+ * derived. Channel binding is never offered, as the proxy does not share the
+ * TLS connection of the backend.
+ */
+func verifySCRAM(client net.Conn, username string, password string) (bool, error) {
+	var verifier *scramVerifier
+	var err error
+
+	if strings.HasPrefix(password, protocol.SASLMechanismSCRAMSHA256+"$") {
+		verifier, err = parseSCRAMVerifier(password)
+	} else {
+		verifier, err = newSCRAMVerifier(password)
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("invalid password for user '%s': %s", username, err.Error())
+	}
+
+	scram := newSCRAMServer(verifier)
+
+	mechanisms := []string{protocol.SASLMechanismSCRAMSHA256}
+
+	if _, err = Send(client, protocol.CreateAuthenticationSASLMessage(mechanisms)); err != nil {
+		return false, err
+	}
+
+	/* Receive the mechanism selected by the client and its first message. */
+	response, length, err := Receive(client)
+
+	if err != nil {
+		return false, err
+	}
+
+	if protocol.GetMessageType(response[:length]) != protocol.PasswordMessageType {
+		return false, errors.New("expected a SASL initial response from the client")
+	}
+
+	mechanism, clientFirst := protocol.GetSASLInitialResponse(response[:length])
+
+	if mechanism != protocol.SASLMechanismSCRAMSHA256 {
+		return false, fmt.Errorf("unsupported SASL mechanism '%s'", mechanism)
+	}
+
+	serverFirst, err := scram.serverFirstMessage(clientFirst)
+
+	if err != nil {
+		return false, err
+	}
+
+	if _, err = Send(client, protocol.CreateAuthenticationSASLContinueMessage(serverFirst)); err != nil {
+		return false, err
+	}
+
+	/* Receive and verify the client's proof. */
+	response, length, err = Receive(client)
+
+	if err != nil {
+		return false, err
+	}
+
+	if protocol.GetMessageType(response[:length]) != protocol.PasswordMessageType {
+		return false, errors.New("expected a SASL response from the client")
+	}
+
+	serverFinal, err := scram.serverFinalMessage(protocol.GetSASLResponse(response[:length]))
+
+	if err != nil {
+		log.Debugf("SCRAM authentication of user '%s' failed: %s", username, err.Error())
+		return false, nil
+	}
+
+	if _, err = Send(client, protocol.CreateAuthenticationSASLFinalMessage(serverFinal)); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+/*
+ * Log into the master node with the backend credentials for the client's
+ * user and database, and relay the startup response to the client once the
+ * backend is ready for queries. The backend credentials are those of the
+ * partition, or of the cluster for a user without one, such as a console
+ * user.
+ */
+func loginBackend(client net.Conn, cluster string, parameters map[string]string, processID int32, secretKey int32) (bool, error) {
+	credentials := config.GetClusterCredentials(cluster)
+	username, password := credentials.Username, credentials.Password
+
+	for _, partition := range config.GetPartitions(cluster) {
+		if parameters["user"] == partition.Username &&
+			parameters["database"] == partition.Database {
+			username, password = partition.Username, partition.Password
+		}
+	}
+
+	options := make(map[string]string)
+
+	for name, value := range parameters {
+		if name != "user" && name != "database" {
+			options[name] = value
+		}
+	}
+
+	name, node := config.GetMasterNode(cluster)
+
+	log.Debugf("client auth: logging into master node '%s' as '%s'", name, username)
+	master, err := Connect(node.HostPort)
+
+	if err != nil {
+		log.Error("An error occurred connecting to the master node")
+		log.Errorf("Error %s", err.Error())
+		return false, &MasterError{name, err}
+	}
+
+	defer master.Close()
+
+	/* A master that hangs during the login fails it after the auth timeout. */
+	master.SetDeadline(Deadline(config.GetAuthTimeout()))
+
+	startup := protocol.CreateStartupMessage(username, parameters["database"], options)
+
+	if _, err = Send(master, startup); err != nil {
+		return false, &MasterError{name, err}
+	}
+
+	message, length, err := Receive(master)
+
+	if err == nil {
+		var negotiation []byte
+
+		if negotiation, message, err = splitNegotiation(master, message[:length]); negotiation != nil {
+			Send(client, negotiation)
+		}
+
+		length = len(message)
+	}
+
+	if err != nil {
+		log.Error("An error occurred receiving startup response.")
+		log.Errorf("Error %s", err.Error())
+		return false, &MasterError{name, err}
+	}
+
+	authenticated, response := HandleAuthenticationRequest(master, message[:length],
+		username, password)
+
+	if !authenticated {
+		if response != nil && protocol.GetMessageType(response) == protocol.ErrorMessageType {
+			err = protocol.ParseError(response)
+		} else {
+			err = fmt.Errorf("the backend credentials of user '%s' were rejected", username)
+		}
+
+		log.Error("Error occurred logging into the master node.")
+		log.Errorf("Error: %s", err.Error())
+		sendAuthError(client, protocol.ErrorCodeInvalidAuthorizationSpecification,
+			"the proxy could not log into the master node")
+		return false, err
+	}
+
+	/*
+	 * The rest of the startup response, up to ReadyForQuery, may arrive in
+	 * more than one read.
+	 */
+	if response, err = ReceiveUntilReady(master, response); err != nil {
+		log.Error("An error occurred receiving startup response.")
+		log.Errorf("Error %s", err.Error())
+		return false, &MasterError{name, err}
+	}
+
+	Send(master, protocol.GetTerminateMessage())
+
+	protocol.SetBackendKeyData(response, processID, secretKey)
+
+	Send(client, response)
+
+	return true, nil
+}
+
+/* Check whether the last complete message of a response is ReadyForQuery. */
+func endsWithReadyForQuery(response []byte) bool {
+	var last byte
+
+	for offset := 0; offset+5 <= len(response); {
+		last = protocol.GetMessageType(response[offset:])
+		offset += int(protocol.GetMessageLength(response[offset:])) + 1
+
+		if offset > len(response) {
+			return false
+		}
+	}
+
+	return last == protocol.ReadyForQueryMessageType
+}
+
+func sendAuthError(client net.Conn, code string, message string) {
+	Send(client, protocol.CreateErrorMessage(protocol.ErrorSeverityFatal, code, message, "", ""))
+}
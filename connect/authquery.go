@@ -0,0 +1,145 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package connect
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	_ "github.com/lib/pq" // required
+
+	"github.com/crunchydata/crunchy-proxy/config"
+	"github.com/crunchydata/crunchy-proxy/util/log"
+)
+
+/* How long the lookup query may take before the client is rejected. */
+const authQueryTimeout = 10 * time.Second
+
+/* The connection used to run the lookup query against a cluster's master. */
+type authConnection struct {
+	connectionString string
+	db               *sql.DB
+}
+
+/* The lookup connection of each cluster, replaced when its master changes. */
+var authConnections = make(map[string]authConnection)
+var authLock sync.Mutex
+
+/*
+ * Look up the password of a user by running the lookup query on the master
+ * node of the cluster, with the user name as its only parameter. The query
+ * returns the user name and password, such as the MD5 hash or SCRAM-SHA-256
+ * verifier from 'pg_shadow'. A user without a row, or with a NULL password,
+ * is not found.
+ */
+func queryPassword(cluster string, username string, authConfig config.AuthConfig) (string, bool, error) {
+	db, err := getAuthConnection(cluster, authConfig)
+
+	if err != nil {
+		return "", false, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), authQueryTimeout)
+	defer cancel()
+
+	var name string
+	var password sql.NullString
+
+	err = db.QueryRowContext(ctx, authConfig.Query, username).Scan(&name, &password)
+
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	} else if err != nil {
+		return "", false, err
+	}
+
+	return password.String, password.Valid && password.String != "", nil
+}
+
+/*
+ * Get the dedicated connection to the master node of the cluster that the
+ * lookup query runs on. It logs in as the configured auth user, or as the
+ * user from the cluster's 'credentials' section.
+ */
+func getAuthConnection(cluster string, authConfig config.AuthConfig) (*sql.DB, error) {
+	_, node := config.GetMasterNode(cluster)
+	creds := config.GetClusterCredentials(cluster)
+
+	if node.HostPort == "" {
+		return nil, fmt.Errorf("no master node for cluster '%s'", cluster)
+	}
+
+	if authConfig.User != "" {
+		creds.Username = authConfig.User
+		creds.Password = authConfig.Password
+	}
+
+	/* The lookups are made at a single address of a master that has several. */
+	address := AvailableHost(node.HostPort)
+	host, port, err := net.SplitHostPort(address)
+
+	if err != nil {
+		return nil, CheckAddress(address)
+	}
+
+	connectionString := fmt.Sprintf("host=%s port=%s", host, port)
+	connectionString += fmt.Sprintf(" user=%s", creds.Username)
+	connectionString += fmt.Sprintf(" database=%s", creds.Database)
+	connectionString += fmt.Sprintf(" sslmode=%s", creds.SSL.SSLMode)
+	connectionString += " application_name=proxy_auth"
+
+	if creds.Password != "" {
+		connectionString += fmt.Sprintf(" password=%s", creds.Password)
+	}
+
+	if creds.SSL.Enable {
+		connectionString += fmt.Sprintf(" sslcert=%s", creds.SSL.SSLCert)
+		connectionString += fmt.Sprintf(" sslkey=%s", creds.SSL.SSLKey)
+		connectionString += fmt.Sprintf(" sslrootcert=%s", creds.SSL.SSLRootCA)
+	}
+
+	authLock.Lock()
+	defer authLock.Unlock()
+
+	current, ok := authConnections[cluster]
+
+	if ok && current.connectionString == connectionString {
+		return current.db, nil
+	}
+
+	/* The master or the auth user has changed, so reconnect. */
+	if ok {
+		current.db.Close()
+	}
+
+	log.Debugf("client auth: opening lookup connection to %s", node.HostPort)
+
+	db, err := sql.Open("postgres", connectionString)
+
+	if err != nil {
+		return nil, err
+	}
+
+	db.SetMaxOpenConns(1)
+	db.SetMaxIdleConns(1)
+
+	authConnections[cluster] = authConnection{connectionString: connectionString, db: db}
+
+	return db, nil
+}
@@ -0,0 +1,47 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package connect
+
+import (
+	"sync"
+)
+
+/* The size of the buffers that messages are received into. */
+const bufferSize = 4096
+
+/*
+ * Receive buffers are reused across connections so that a buffer is not
+ * allocated for every message that is relayed.
+ */
+var buffers = sync.Pool{
+	New: func() interface{} {
+		return make([]byte, bufferSize)
+	},
+}
+
+// GetBuffer returns a buffer to receive messages into. It should be given
+// back with PutBuffer once no slice of it is in use.
+func GetBuffer() []byte {
+	return buffers.Get().([]byte)
+}
+
+// PutBuffer gives a buffer obtained from GetBuffer back for reuse.
+func PutBuffer(buffer []byte) {
+	if cap(buffer) < bufferSize {
+		return
+	}
+
+	buffers.Put(buffer[:bufferSize])
+}
@@ -0,0 +1,107 @@
+/*
+Copyright 2016 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package connect
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+func TestGetBuffer(t *testing.T) {
+	tests := []struct {
+		name   string
+		put    []byte // the buffer given back before the next one is taken
+		reused bool
+	}{
+		{name: "nothing given back"},
+		{name: "full size buffer", put: make([]byte, bufferSize), reused: true},
+		{name: "resliced buffer", put: make([]byte, bufferSize)[:10], reused: true},
+		{name: "small buffer", put: make([]byte, 10)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.put != nil {
+				PutBuffer(test.put)
+			}
+
+			buffer := GetBuffer()
+			defer PutBuffer(buffer)
+
+			/* Any buffer that is handed out is of the full size. */
+			if len(buffer) != bufferSize {
+				t.Fatalf("expected a buffer of %d bytes, got %d", bufferSize, len(buffer))
+			}
+
+			if !test.reused && test.put != nil && &buffer[0] == &test.put[0] {
+				t.Fatal("expected a small buffer not to be reused")
+			}
+		})
+	}
+}
+
+func TestReceiveBuffer(t *testing.T) {
+	tests := []struct {
+		name   string
+		writes [][]byte
+	}{
+		{name: "single message", writes: [][]byte{[]byte("Q\x00\x00\x00\x0dselect 1\x00")}},
+		{name: "several messages", writes: [][]byte{[]byte("S\x00\x00\x00\x04"), []byte("X\x00\x00\x00\x04")}},
+		{name: "larger than the buffer", writes: [][]byte{bytes.Repeat([]byte("d"), 3*bufferSize)}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client, server := net.Pipe()
+			defer server.Close()
+
+			expected := bytes.Join(test.writes, nil)
+
+			go func() {
+				defer client.Close()
+
+				for _, w := range test.writes {
+					client.Write(w)
+				}
+			}()
+
+			buffer := GetBuffer()
+			defer PutBuffer(buffer)
+
+			var received []byte
+
+			for {
+				message, length, err := ReceiveBuffer(server, buffer)
+
+				if length > 0 {
+					if &message[0] != &buffer[0] {
+						t.Fatal("expected the message to be read into the buffer")
+					}
+
+					received = append(received, message[:length]...)
+				}
+
+				if err != nil {
+					break
+				}
+			}
+
+			if !bytes.Equal(received, expected) {
+				t.Fatalf("expected %d bytes, received %d", len(expected), len(received))
+			}
+		})
+	}
+}
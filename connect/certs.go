@@ -0,0 +1,160 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package connect
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/crunchydata/crunchy-proxy/util/log"
+)
+
+/*
+ * The certificates and CA pools loaded from files are cached until the files
+ * change, so that rotated certificates, such as short-lived ones renewed by an
+ * ACME client or Vault agent, are used for new connections without a restart.
+ */
+type fileVersion struct {
+	modTime time.Time
+	size    int64
+}
+
+type cachedCertificate struct {
+	versions    [2]fileVersion
+	certificate *tls.Certificate
+}
+
+type cachedCertPool struct {
+	version fileVersion
+	pool    *x509.CertPool
+}
+
+var certificates = make(map[[2]string]*cachedCertificate)
+var certPools = make(map[string]*cachedCertPool)
+var certLock sync.Mutex
+
+/* Get the version of a file, following symlinks as they are often swapped. */
+func statFile(path string) (fileVersion, error) {
+	info, err := os.Stat(path)
+
+	if err != nil {
+		return fileVersion{}, err
+	}
+
+	return fileVersion{modTime: info.ModTime(), size: info.Size()}, nil
+}
+
+// loadCertificate returns the certificate and key from the files, reloading
+// them if either file has changed since they were last loaded. If the files
+// cannot be loaded, for example because only one of them has been replaced
+// so far, then the previously loaded certificate is returned.
+func loadCertificate(certFile string, keyFile string) (*tls.Certificate, error) {
+	certVersion, err := statFile(certFile)
+
+	if err != nil {
+		return cachedCertificateOr(certFile, keyFile, err)
+	}
+
+	keyVersion, err := statFile(keyFile)
+
+	if err != nil {
+		return cachedCertificateOr(certFile, keyFile, err)
+	}
+
+	versions := [2]fileVersion{certVersion, keyVersion}
+
+	certLock.Lock()
+	cached, ok := certificates[[2]string{certFile, keyFile}]
+	certLock.Unlock()
+
+	if ok && cached.versions == versions {
+		return cached.certificate, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+
+	if err != nil {
+		return cachedCertificateOr(certFile, keyFile, err)
+	}
+
+	if ok {
+		log.Infof("Reloaded certificate '%s'", certFile)
+	}
+
+	certLock.Lock()
+	certificates[[2]string{certFile, keyFile}] = &cachedCertificate{
+		versions:    versions,
+		certificate: &cert,
+	}
+	certLock.Unlock()
+
+	return &cert, nil
+}
+
+func cachedCertificateOr(certFile string, keyFile string, err error) (*tls.Certificate, error) {
+	certLock.Lock()
+	cached, ok := certificates[[2]string{certFile, keyFile}]
+	certLock.Unlock()
+
+	if !ok {
+		return nil, err
+	}
+
+	log.Errorf("Error reloading certificate '%s', using the previous one: %s",
+		certFile, err.Error())
+
+	return cached.certificate, nil
+}
+
+// loadCertPool returns a pool of the certificates in the CA file, reloading
+// it if the file has changed since it was last loaded.
+func loadCertPool(caFile string) (*x509.CertPool, error) {
+	version, err := statFile(caFile)
+
+	if err != nil {
+		return nil, err
+	}
+
+	certLock.Lock()
+	cached, ok := certPools[caFile]
+	certLock.Unlock()
+
+	if ok && cached.version == version {
+		return cached.pool, nil
+	}
+
+	rootCA, err := ioutil.ReadFile(caFile)
+
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+
+	if !pool.AppendCertsFromPEM(rootCA) {
+		return nil, fmt.Errorf("no certificates found in '%s'", caFile)
+	}
+
+	certLock.Lock()
+	certPools[caFile] = &cachedCertPool{version: version, pool: pool}
+	certLock.Unlock()
+
+	return pool, nil
+}
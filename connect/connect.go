@@ -15,30 +15,190 @@ limitations under the License.
 package connect
 
 import (
+	"encoding/binary"
+	"fmt"
+	"io"
 	"net"
+	"strings"
+	"time"
 
 	"github.com/crunchydata/crunchy-proxy/config"
 	"github.com/crunchydata/crunchy-proxy/protocol"
 	"github.com/crunchydata/crunchy-proxy/util/log"
 )
 
+// Deadline returns when an operation that is limited by the timeout must be
+// done, or the zero time, which means no deadline, if the timeout is zero.
+func Deadline(timeout time.Duration) time.Time {
+	if timeout <= 0 {
+		return time.Time{}
+	}
+
+	return time.Now().Add(timeout)
+}
+
 func Send(connection net.Conn, message []byte) (int, error) {
 	return connection.Write(message)
 }
 
+// SendBuffers writes several messages, or chunks of a response, to the
+// connection with as few writes as possible, using a vectored write where the
+// connection supports it, so that they need not be copied into one buffer.
+func SendBuffers(connection net.Conn, buffers [][]byte) (int64, error) {
+	/*
+	 * Writing consumes the vector, including the element that is partially
+	 * written, so it is built from a copy to leave the caller's intact.
+	 */
+	vector := make(net.Buffers, len(buffers))
+	copy(vector, buffers)
+
+	return vector.WriteTo(connection)
+}
+
 func Receive(connection net.Conn) ([]byte, int, error) {
-	buffer := make([]byte, 4096)
+	return ReceiveBuffer(connection, make([]byte, bufferSize))
+}
+
+// ReceiveBuffer reads from the connection into the provided buffer instead of
+// allocating a new one, and returns it along with the number of bytes read.
+// The contents are overwritten by the next read into the same buffer.
+func ReceiveBuffer(connection net.Conn, buffer []byte) ([]byte, int, error) {
 	length, err := connection.Read(buffer)
 	return buffer, length, err
 }
 
+// ReceiveMessage reads exactly one message from a backend, which may arrive
+// in several reads, without reading anything that follows it. Its length is
+// validated before the rest of it is read, and an invalid length is returned
+// as a protocol.ViolationError.
+func ReceiveMessage(connection net.Conn) ([]byte, error) {
+	header := make([]byte, 5)
+
+	if _, err := io.ReadFull(connection, header); err != nil {
+		return nil, err
+	}
+
+	length := int32(binary.BigEndian.Uint32(header[1:]))
+
+	if err := protocol.ValidateMessageLength(length, config.GetMaxMessageSize()); err != nil {
+		return nil, err
+	}
+
+	message := make([]byte, length+1)
+	copy(message, header)
+
+	if _, err := io.ReadFull(connection, message[5:]); err != nil {
+		return nil, err
+	}
+
+	return message, nil
+}
+
+// ReceiveUntilReady reads the rest of a backend's startup response, up to
+// ReadyForQuery, which may arrive in more than one read, and returns it
+// appended to the part of the response already received.
+func ReceiveUntilReady(connection net.Conn, response []byte) ([]byte, error) {
+	for !endsWithReadyForQuery(response) {
+		message, length, err := Receive(connection)
+
+		if err != nil {
+			return nil, err
+		}
+
+		response = append(response, message[:length]...)
+	}
+
+	return response, nil
+}
+
+// ReceiveStartup reads exactly one startup message from a client, which may
+// arrive in several reads, without reading anything that follows it. Its
+// length is validated before the rest of it is read, and an invalid length is
+// returned as a protocol.ViolationError.
+func ReceiveStartup(connection net.Conn) ([]byte, int, error) {
+	header := make([]byte, 4)
+
+	if _, err := io.ReadFull(connection, header); err != nil {
+		return nil, 0, err
+	}
+
+	length := int32(binary.BigEndian.Uint32(header))
+
+	if err := protocol.ValidateStartupLength(length); err != nil {
+		return nil, 0, err
+	}
+
+	message := make([]byte, length)
+	copy(message, header)
+
+	if _, err := io.ReadFull(connection, message[4:]); err != nil {
+		return nil, 0, err
+	}
+
+	return message, int(length), nil
+}
+
+// SplitHosts returns the addresses listed in the host:port of a node. As with
+// libpq, several addresses may be given, separated by commas, for a node that
+// can be reached at any of them.
+func SplitHosts(hostPort string) []string {
+	var hosts []string
+
+	for _, host := range strings.Split(hostPort, ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+
+	if len(hosts) == 0 {
+		return []string{hostPort}
+	}
+
+	return hosts
+}
+
+// CheckAddress checks that an address has a host and a port. IPv6 addresses
+// must be enclosed in brackets, as in '[::1]:5432'.
+func CheckAddress(address string) error {
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		if strings.Count(address, ":") > 1 && !strings.HasPrefix(address, "[") {
+			return fmt.Errorf("'%s' is not a valid host:port address, IPv6 addresses must be enclosed in brackets, as in '[::1]:5432'", address)
+		}
+
+		return fmt.Errorf("'%s' is not a valid host:port address", address)
+	}
+
+	return nil
+}
+
+// Connect opens a connection to the backend at host, upgrading it to SSL if
+// enabled. Failed connection attempts are retried as configured in the
+// 'connect' section. If the backend has several addresses, then they are
+// tried in order until a connection is made.
 func Connect(host string) (net.Conn, error) {
-	connection, err := net.Dial("tcp", host)
+	var err error
+
+	for _, address := range SplitHosts(host) {
+		var connection net.Conn
+
+		if connection, err = connectHost(address); err == nil {
+			return connection, nil
+		}
+	}
+
+	return nil, err
+}
+
+/* connectHost opens a connection to a single address of a backend. */
+func connectHost(host string) (net.Conn, error) {
+	connection, err := dial(host)
 
 	if err != nil {
 		return nil, err
 	}
 
+	SetTCPOptions(connection, config.GetConnectConfig().TCP)
+
 	if config.GetBool("credentials.ssl.enable") {
 		log.Info("SSL connections are enabled.")
 
@@ -64,29 +224,39 @@ func Connect(host string) (net.Conn, error) {
 			return nil, err
 		}
 
-		/* Receive SSL response message. */
-		response := make([]byte, 4096)
-		_, err = connection.Read(response)
+		/*
+		 * Receive the SSL response, reading only its single byte, as anything
+		 * after it must be part of the SSL handshake.
+		 */
+		response := make([]byte, 1)
 
-		if err != nil {
+		if _, err = io.ReadFull(connection, response); err != nil {
 			log.Error("Error receiving SSL response from backend.")
 			log.Errorf("Error: %s", err.Error())
+			connection.Close()
 			return nil, err
 		}
 
 		/*
 		 * If SSL is not allowed by the backend then close the connection and
-		 * throw an error.
+		 * throw an error. A server that does not understand the request at
+		 * all replies with an error message instead.
 		 */
-		if len(response) > 0 && response[0] != 'S' {
+		if response[0] != protocol.SSLAllowed {
 			log.Error("The backend does not allow SSL connections.")
 			connection.Close()
-		} else {
-			log.Debug("SSL connections are allowed by PostgreSQL.")
-			log.Debug("Attempting to upgrade connection.")
-			connection = UpgradeClientConnection(host, connection)
-			log.Debug("Connection successfully upgraded.")
+
+			if response[0] != protocol.SSLNotAllowed {
+				return nil, fmt.Errorf("unexpected SSL response '%c' from %s", response[0], host)
+			}
+
+			return nil, fmt.Errorf("%s does not allow SSL connections", host)
 		}
+
+		log.Debug("SSL connections are allowed by PostgreSQL.")
+		log.Debug("Attempting to upgrade connection.")
+		connection = UpgradeClientConnection(host, connection)
+		log.Debug("Connection successfully upgraded.")
 	}
 
 	return connection, nil
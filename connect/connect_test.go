@@ -0,0 +1,94 @@
+/*
+Copyright 2016 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package connect
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/crunchydata/crunchy-proxy/protocol"
+)
+
+/* Write the stream to a pipe in chunks that end at the offsets. */
+func writeChunks(stream []byte, offsets ...int) net.Conn {
+	client, server := net.Pipe()
+
+	go func() {
+		defer client.Close()
+
+		start := 0
+
+		for _, offset := range append(offsets, len(stream)) {
+			client.Write(stream[start:offset])
+			start = offset
+		}
+	}()
+
+	return server
+}
+
+func TestReceiveMessage(t *testing.T) {
+	auth := protocol.CreateAuthenticationMD5Message([]byte("salt"))
+	ready := protocol.CreateReadyForQueryMessage(protocol.TransactionIdle)
+	stream := append(append([]byte(nil), auth...), ready...)
+
+	for _, offset := range []int{1, 3, 5, len(auth) - 1, len(auth) + 2} {
+		connection := writeChunks(stream, offset)
+
+		message, err := ReceiveMessage(connection)
+
+		if err != nil || !bytes.Equal(message, auth) {
+			t.Fatalf("split at %d: expected the first message, got %q, %v", offset, message, err)
+		}
+
+		/* Nothing that follows the message is read with it. */
+		if message, err = ReceiveMessage(connection); err != nil || !bytes.Equal(message, ready) {
+			t.Fatalf("split at %d: expected the second message, got %q, %v", offset, message, err)
+		}
+
+		connection.Close()
+	}
+}
+
+func TestReceiveMessageInvalidLength(t *testing.T) {
+	connection := writeChunks([]byte{'R', 0, 0, 0, 2})
+	defer connection.Close()
+
+	if _, err := ReceiveMessage(connection); err == nil {
+		t.Fatal("expected an invalid length to be rejected")
+	} else if _, ok := err.(*protocol.ViolationError); !ok {
+		t.Fatalf("expected a protocol violation, got %v", err)
+	}
+}
+
+func TestReceiveUntilReady(t *testing.T) {
+	auth := protocol.CreateAuthenticationClearTextMessage()
+	status := protocol.CreateParameterStatusMessage("TimeZone", "UTC")
+	ready := protocol.CreateReadyForQueryMessage(protocol.TransactionIdle)
+	rest := append(append([]byte(nil), status...), ready...)
+
+	for _, offset := range []int{2, len(status), len(status) + 3} {
+		connection := writeChunks(rest, offset)
+
+		response, err := ReceiveUntilReady(connection, auth)
+
+		if err != nil || !bytes.Equal(response, append(append([]byte(nil), auth...), rest...)) {
+			t.Fatalf("split at %d: expected the whole response, got %q, %v", offset, response, err)
+		}
+
+		connection.Close()
+	}
+}
@@ -0,0 +1,204 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package connect
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+
+	"github.com/crunchydata/crunchy-proxy/config"
+	"github.com/crunchydata/crunchy-proxy/protocol"
+	"github.com/crunchydata/crunchy-proxy/util/log"
+)
+
+/*
+ * Find the first LDAP server configured for the user and database. A server
+ * without user or database patterns applies to all of them.
+ */
+func findLDAPConfig(servers []config.LDAPConfig, username string, database string) (config.LDAPConfig, bool) {
+	for _, server := range servers {
+		if matchesPattern(server.Users, username) && matchesPattern(server.Databases, database) {
+			return server, true
+		}
+	}
+
+	return config.LDAPConfig{}, false
+}
+
+func matchesPattern(patterns []string, value string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, value); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+/*
+ * Authenticate a client against an LDAP server. LDAP needs the password
+ * itself, so the client is asked for its password in clear text. Once the
+ * LDAP server accepts it, the master node is logged into with the backend
+ * credentials in the same way as for a userlist file.
+ */
+func authenticateClientLDAP(client net.Conn, cluster string, parameters map[string]string, processID int32, secretKey int32, ldapConfig config.LDAPConfig) (bool, error) {
+	username := parameters["user"]
+
+	if _, err := Send(client, protocol.CreateAuthenticationClearTextMessage()); err != nil {
+		return false, err
+	}
+
+	response, length, err := Receive(client)
+
+	if err != nil {
+		return false, err
+	}
+
+	if protocol.GetMessageType(response[:length]) != protocol.PasswordMessageType {
+		return false, errors.New("expected a password message from the client")
+	}
+
+	verified, err := verifyLDAP(ldapConfig, username, protocol.GetPassword(response[:length]))
+
+	if err != nil {
+		log.Error("An error occurred authenticating against the LDAP server.")
+		log.Errorf("Error: %s", err.Error())
+	}
+
+	if !verified {
+		if err == nil {
+			err = fmt.Errorf("LDAP authentication failed for user '%s'", username)
+		}
+
+		sendAuthError(client, protocol.ErrorCodeInvalidPassword,
+			fmt.Sprintf("LDAP authentication failed for user \"%s\"", username))
+		return false, err
+	}
+
+	return loginBackend(client, cluster, parameters, processID, secretKey)
+}
+
+/*
+ * Verify a user's password by binding to the LDAP server as the user. In
+ * simple bind mode the user's DN is the prefix, user name and suffix. In
+ * search+bind mode the user's DN is found by searching under the base DN,
+ * binding as the search user first if one is configured. A wrong password is
+ * reported as false without an error.
+ */
+func verifyLDAP(ldapConfig config.LDAPConfig, username string, password string) (bool, error) {
+	/*
+	 * An LDAP bind with an empty password is an unauthenticated bind, which
+	 * servers accept for any DN, so it must never be taken as proof.
+	 */
+	if password == "" {
+		return false, nil
+	}
+
+	timeout := time.Duration(ldapConfig.Timeout) * time.Second
+
+	conn, err := ldap.DialURL(ldapConfig.URL,
+		ldap.DialWithDialer(&net.Dialer{Timeout: timeout}))
+
+	if err != nil {
+		return false, err
+	}
+
+	defer conn.Close()
+
+	conn.SetTimeout(timeout)
+
+	if ldapConfig.StartTLS {
+		serverURL, err := url.Parse(ldapConfig.URL)
+
+		if err != nil {
+			return false, err
+		}
+
+		if err = conn.StartTLS(&tls.Config{ServerName: serverURL.Hostname()}); err != nil {
+			return false, err
+		}
+	}
+
+	userDN := ldapConfig.Prefix + ldap.EscapeDN(username) + ldapConfig.Suffix
+
+	if ldapConfig.BaseDN != "" {
+		if userDN, err = searchLDAP(conn, ldapConfig, username); err != nil || userDN == "" {
+			return false, err
+		}
+	}
+
+	err = conn.Bind(userDN, password)
+
+	if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+/*
+ * Search for the DN of the user under the base DN. The search filter may
+ * refer to the user name as '$username'; by default the search attribute is
+ * compared with the user name. No DN is returned unless exactly one entry
+ * matches.
+ */
+func searchLDAP(conn *ldap.Conn, ldapConfig config.LDAPConfig, username string) (string, error) {
+	if ldapConfig.BindDN != "" {
+		if err := conn.Bind(ldapConfig.BindDN, ldapConfig.BindPassword); err != nil {
+			return "", fmt.Errorf("could not bind as the search user: %s", err.Error())
+		}
+	}
+
+	filter := fmt.Sprintf("(%s=%s)", ldapConfig.SearchAttribute, ldap.EscapeFilter(username))
+
+	if ldapConfig.SearchFilter != "" {
+		filter = strings.Replace(ldapConfig.SearchFilter, "$username",
+			ldap.EscapeFilter(username), -1)
+	}
+
+	request := ldap.NewSearchRequest(ldapConfig.BaseDN, ldap.ScopeWholeSubtree,
+		ldap.NeverDerefAliases, 2, ldapConfig.Timeout, false, filter,
+		[]string{"dn"}, nil)
+
+	result, err := conn.Search(request)
+
+	if ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+		log.Errorf("LDAP search for user '%s' matched more than one entry", username)
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+
+	if len(result.Entries) != 1 {
+		log.Debugf("LDAP search for user '%s' matched %d entries", username, len(result.Entries))
+		return "", nil
+	}
+
+	return result.Entries[0].DN, nil
+}
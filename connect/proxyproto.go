@@ -0,0 +1,276 @@
+/*
+ Copyright 2017 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package connect
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/crunchydata/crunchy-proxy/config"
+)
+
+/* PROXY protocol constants. */
+const (
+	/* The maximum length of a version 1 header, including the CRLF. */
+	PROXY_V1_MAX_LENGTH int = 107
+
+	/* Version 2 commands. */
+	PROXY_V2_LOCAL byte = 0x00
+	PROXY_V2_PROXY byte = 0x01
+
+	/* Version 2 address families. */
+	PROXY_V2_INET  byte = 0x01
+	PROXY_V2_INET6 byte = 0x02
+
+	/* How long a client has to send the header. */
+	proxyHeaderTimeout = 5 * time.Second
+)
+
+var proxyV1Prefix = []byte("PROXY ")
+var proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// proxyConn is a client connection that was received through a load balancer
+// using the PROXY protocol. It reports the address of the original client,
+// rather than that of the load balancer.
+type proxyConn struct {
+	net.Conn
+	reader *bufio.Reader
+	remote net.Addr
+}
+
+func (c *proxyConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
+
+func (c *proxyConn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+// ReadProxyHeader reads the PROXY protocol (version 1 or 2) header that a load
+// balancer sends ahead of the client's startup message. The returned
+// connection reports the original client's address as its remote address.
+//
+// Connections over a Unix socket are returned unchanged. If trusted networks
+// are configured, then a header from any other address is rejected.
+func ReadProxyHeader(client net.Conn, cfg config.ProxyProtocolConfig) (net.Conn, error) {
+	peer, ok := client.RemoteAddr().(*net.TCPAddr)
+
+	if !ok {
+		return client, nil
+	}
+
+	if !isTrustedProxy(peer.IP, cfg.Trusted) {
+		return nil, fmt.Errorf("PROXY header from untrusted address %s", peer.IP)
+	}
+
+	client.SetReadDeadline(time.Now().Add(proxyHeaderTimeout))
+	defer client.SetReadDeadline(time.Time{})
+
+	reader := bufio.NewReader(client)
+
+	/*
+	 * A version 2 header starts with a 12 byte signature, which is shorter
+	 * than the shortest valid version 1 header, so it is safe to wait for
+	 * that many bytes before deciding which version was sent.
+	 */
+	signature, err := reader.Peek(len(proxyV2Signature))
+
+	if err != nil {
+		return nil, err
+	}
+
+	var remote net.Addr
+
+	switch {
+	case bytes.Equal(signature, proxyV2Signature):
+		remote, err = readProxyV2Header(reader)
+	case bytes.HasPrefix(signature, proxyV1Prefix):
+		remote, err = readProxyV1Header(reader)
+	default:
+		err = errors.New("missing PROXY header")
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	/* LOCAL and UNKNOWN headers keep the address of the connection itself. */
+	if remote == nil {
+		remote = peer
+	}
+
+	conn := &proxyConn{
+		Conn:   client,
+		reader: reader,
+		remote: remote,
+	}
+
+	return conn, nil
+}
+
+/*
+ * readProxyV1Header parses a human readable header of the form:
+ *
+ *   PROXY TCP4 192.168.0.1 192.168.0.11 56324 5432\r\n
+ */
+func readProxyV1Header(reader *bufio.Reader) (net.Addr, error) {
+	var line []byte
+
+	for len(line) < PROXY_V1_MAX_LENGTH {
+		b, err := reader.ReadByte()
+
+		if err != nil {
+			return nil, err
+		}
+
+		line = append(line, b)
+
+		if b == '\n' {
+			break
+		}
+	}
+
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, errors.New("invalid PROXY header: missing CRLF")
+	}
+
+	fields := strings.Split(string(line[:len(line)-2]), " ")
+
+	if len(fields) < 2 {
+		return nil, errors.New("invalid PROXY header")
+	}
+
+	switch fields[1] {
+	case "UNKNOWN":
+		return nil, nil
+	case "TCP4", "TCP6":
+	default:
+		return nil, fmt.Errorf("invalid PROXY header: unsupported protocol %s",
+			fields[1])
+	}
+
+	if len(fields) != 6 {
+		return nil, errors.New("invalid PROXY header")
+	}
+
+	ip := net.ParseIP(fields[2])
+
+	if ip == nil {
+		return nil, fmt.Errorf("invalid PROXY header: bad source address %s",
+			fields[2])
+	}
+
+	port, err := strconv.ParseUint(fields[4], 10, 16)
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid PROXY header: bad source port %s",
+			fields[4])
+	}
+
+	return &net.TCPAddr{IP: ip, Port: int(port)}, nil
+}
+
+/*
+ * readProxyV2Header parses a binary header, which consists of the signature,
+ * the version and command, the address family and transport, the length of
+ * the addresses, and then the addresses themselves.
+ */
+func readProxyV2Header(reader *bufio.Reader) (net.Addr, error) {
+	header := make([]byte, len(proxyV2Signature)+4)
+
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, err
+	}
+
+	versionCommand := header[12]
+	family := header[13] >> 4
+	length := binary.BigEndian.Uint16(header[14:16])
+
+	if versionCommand>>4 != 2 {
+		return nil, fmt.Errorf("invalid PROXY header: unsupported version %d",
+			versionCommand>>4)
+	}
+
+	/* Read the addresses, including any TLVs, so they are not seen as data. */
+	addresses := make([]byte, length)
+
+	if _, err := io.ReadFull(reader, addresses); err != nil {
+		return nil, err
+	}
+
+	switch versionCommand & 0x0f {
+	case PROXY_V2_LOCAL:
+		return nil, nil
+	case PROXY_V2_PROXY:
+	default:
+		return nil, fmt.Errorf("invalid PROXY header: unsupported command %d",
+			versionCommand&0x0f)
+	}
+
+	var size int
+
+	switch family {
+	case PROXY_V2_INET:
+		size = net.IPv4len
+	case PROXY_V2_INET6:
+		size = net.IPv6len
+	default:
+		/* Unix and unspecified addresses are of no use to the proxy. */
+		return nil, nil
+	}
+
+	/* Source address, destination address, source port, destination port. */
+	if len(addresses) < 2*size+4 {
+		return nil, errors.New("invalid PROXY header: address block too short")
+	}
+
+	ip := make(net.IP, size)
+	copy(ip, addresses[:size])
+	port := binary.BigEndian.Uint16(addresses[2*size : 2*size+2])
+
+	return &net.TCPAddr{IP: ip, Port: int(port)}, nil
+}
+
+/*
+ * isTrustedProxy determines whether the address is allowed to send a PROXY
+ * header. Trusted entries may be either addresses or networks in CIDR
+ * notation. If none are configured, then every address is trusted.
+ */
+func isTrustedProxy(ip net.IP, trusted []string) bool {
+	if len(trusted) == 0 {
+		return true
+	}
+
+	for _, entry := range trusted {
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			if network.Contains(ip) {
+				return true
+			}
+		} else if trustedIP := net.ParseIP(entry); trustedIP != nil && trustedIP.Equal(ip) {
+			return true
+		}
+	}
+
+	return false
+}
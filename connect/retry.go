@@ -0,0 +1,213 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package connect
+
+import (
+	"errors"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/crunchydata/crunchy-proxy/config"
+	"github.com/crunchydata/crunchy-proxy/util/log"
+)
+
+// ErrCircuitOpen is returned when connecting to a backend that has failed too
+// many consecutive connection attempts.
+var ErrCircuitOpen = errors.New("backend unavailable: too many consecutive connection failures")
+
+/*
+ * A circuit breaker for a single backend. Once 'failures' reaches the
+ * configured threshold, the circuit is opened and connections fail
+ * immediately until the reset timeout has passed. A single attempt is then
+ * allowed through; if it succeeds the circuit is closed, otherwise it is
+ * opened again.
+ */
+type breaker struct {
+	failures int
+	opened   time.Time
+	probing  bool
+}
+
+var (
+	breakers    = make(map[string]*breaker)
+	breakerLock sync.Mutex
+)
+
+// IsAvailable returns false if the circuit breaker for the backend at host is
+// open and the backend should not be used. A backend with several addresses is
+// available if any of them is.
+func IsAvailable(host string) bool {
+	for _, address := range SplitHosts(host) {
+		if isAvailable(address) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AvailableHost returns the first address of the backend at host whose
+// circuit breaker is not open, or its first address if all of them are.
+func AvailableHost(host string) string {
+	addresses := SplitHosts(host)
+
+	for _, address := range addresses {
+		if isAvailable(address) {
+			return address
+		}
+	}
+
+	return addresses[0]
+}
+
+/* Determine whether the circuit breaker for a single address is closed. */
+func isAvailable(host string) bool {
+	breakerLock.Lock()
+	defer breakerLock.Unlock()
+
+	b, ok := breakers[host]
+
+	if !ok || b.opened.IsZero() {
+		return true
+	}
+
+	resetTimeout := time.Duration(config.GetConnectConfig().ResetTimeout) * time.Second
+
+	return time.Since(b.opened) >= resetTimeout
+}
+
+/*
+ * Determine whether an attempt to connect to the host may be made. Only one
+ * attempt is allowed through a circuit once its reset timeout has passed.
+ */
+func allowAttempt(host string, connectConfig config.ConnectConfig) bool {
+	breakerLock.Lock()
+	defer breakerLock.Unlock()
+
+	b, ok := breakers[host]
+
+	if !ok || b.opened.IsZero() {
+		return true
+	}
+
+	resetTimeout := time.Duration(connectConfig.ResetTimeout) * time.Second
+
+	if b.probing || time.Since(b.opened) < resetTimeout {
+		return false
+	}
+
+	b.probing = true
+
+	return true
+}
+
+func recordSuccess(host string) {
+	breakerLock.Lock()
+	defer breakerLock.Unlock()
+
+	if b, ok := breakers[host]; ok {
+		if !b.opened.IsZero() {
+			log.Infof("Backend at %s is available again", host)
+		}
+
+		delete(breakers, host)
+	}
+}
+
+func recordFailure(host string, connectConfig config.ConnectConfig) {
+	/* A threshold of zero disables the circuit breaker. */
+	if connectConfig.FailureThreshold <= 0 {
+		return
+	}
+
+	breakerLock.Lock()
+	defer breakerLock.Unlock()
+
+	b, ok := breakers[host]
+
+	if !ok {
+		b = &breaker{}
+		breakers[host] = b
+	}
+
+	b.failures++
+	b.probing = false
+
+	if b.failures >= connectConfig.FailureThreshold {
+		if b.opened.IsZero() {
+			log.Errorf("Backend at %s failed %d consecutive connection attempts, marking it unavailable",
+				host, b.failures)
+		}
+
+		b.opened = time.Now()
+	}
+}
+
+/*
+ * Calculate how long to wait before the given retry. The delay doubles with
+ * each attempt up to the maximum, and a random jitter of up to half the delay
+ * is subtracted so that connections to a recovering backend are spread out.
+ */
+func backoff(attempt int, connectConfig config.ConnectConfig) time.Duration {
+	delay := time.Duration(connectConfig.Backoff) * time.Millisecond
+	maxDelay := time.Duration(connectConfig.MaxBackoff) * time.Millisecond
+
+	for i := 1; i < attempt && delay < maxDelay; i++ {
+		delay *= 2
+	}
+
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+
+	return delay - time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+/*
+ * Dial the backend at host, retrying failed attempts with exponential
+ * backoff. No attempts are made while the backend's circuit is open.
+ */
+func dial(host string) (net.Conn, error) {
+	connectConfig := config.GetConnectConfig()
+
+	var err error
+
+	for attempt := 0; attempt <= connectConfig.Retries; attempt++ {
+		if attempt > 0 {
+			delay := backoff(attempt, connectConfig)
+			log.Debugf("Retrying connection to %s in %s", host, delay)
+			time.Sleep(delay)
+		}
+
+		if !allowAttempt(host, connectConfig) {
+			return nil, ErrCircuitOpen
+		}
+
+		var connection net.Conn
+		connection, err = net.Dial("tcp", host)
+
+		if err == nil {
+			recordSuccess(host)
+			return connection, nil
+		}
+
+		log.Errorf("Error connecting to %s: %s", host, err.Error())
+		recordFailure(host, connectConfig)
+	}
+
+	return nil, err
+}
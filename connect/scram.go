@@ -0,0 +1,369 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package connect
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/crunchydata/crunchy-proxy/protocol"
+)
+
+/* SCRAM constants. */
+const (
+	scramNonceLength = 18
+
+	/*
+	 * The GS2 header used by the proxy. Channel binding is not supported, so
+	 * the header is always 'n,,'.
+	 */
+	scramGS2Header = "n,,"
+)
+
+// scramClient holds the state of a SCRAM-SHA-256 exchange (RFC 5802 and RFC
+// 7677) performed by the proxy against a backend.
+type scramClient struct {
+	password        string
+	clientNonce     string
+	clientFirstBare string
+	serverSignature []byte
+}
+
+func newSCRAMClient(password string) (*scramClient, error) {
+	nonce := make([]byte, scramNonceLength)
+
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return &scramClient{
+		password:    password,
+		clientNonce: base64.StdEncoding.EncodeToString(nonce),
+	}, nil
+}
+
+// clientFirstMessage creates the client-first-message.
+//
+// The username is left empty as PostgreSQL uses the user provided in the
+// startup message.
+func (c *scramClient) clientFirstMessage() []byte {
+	c.clientFirstBare = fmt.Sprintf("n=,r=%s", c.clientNonce)
+	return []byte(scramGS2Header + c.clientFirstBare)
+}
+
+// clientFinalMessage creates the client-final-message from the
+// server-first-message sent by the backend.
+func (c *scramClient) clientFinalMessage(serverFirst []byte) ([]byte, error) {
+	var nonce, salt string
+	var iterations int
+	var err error
+
+	for _, attribute := range strings.Split(string(serverFirst), ",") {
+		if len(attribute) < 2 || attribute[1] != '=' {
+			return nil, fmt.Errorf("scram: malformed server-first-message")
+		}
+
+		value := attribute[2:]
+
+		switch attribute[0] {
+		case 'r':
+			nonce = value
+		case 's':
+			salt = value
+		case 'i':
+			if iterations, err = strconv.Atoi(value); err != nil {
+				return nil, fmt.Errorf("scram: invalid iteration count '%s'", value)
+			}
+		}
+	}
+
+	/* The server nonce must extend the nonce that was sent by the client. */
+	if !strings.HasPrefix(nonce, c.clientNonce) || len(nonce) == len(c.clientNonce) {
+		return nil, errors.New("scram: invalid server nonce")
+	}
+
+	if iterations < 1 {
+		return nil, errors.New("scram: invalid iteration count")
+	}
+
+	decodedSalt, err := base64.StdEncoding.DecodeString(salt)
+
+	if err != nil {
+		return nil, fmt.Errorf("scram: invalid salt: %s", err.Error())
+	}
+
+	channelBinding := base64.StdEncoding.EncodeToString([]byte(scramGS2Header))
+	finalWithoutProof := fmt.Sprintf("c=%s,r=%s", channelBinding, nonce)
+
+	authMessage := []byte(c.clientFirstBare + "," + string(serverFirst) + "," +
+		finalWithoutProof)
+
+	saltedPassword := scramHi([]byte(c.password), decodedSalt, iterations)
+
+	clientKey := scramHMAC(saltedPassword, []byte("Client Key"))
+	storedKey := sha256.Sum256(clientKey)
+	clientSignature := scramHMAC(storedKey[:], authMessage)
+
+	clientProof := make([]byte, len(clientKey))
+	for i := range clientKey {
+		clientProof[i] = clientKey[i] ^ clientSignature[i]
+	}
+
+	serverKey := scramHMAC(saltedPassword, []byte("Server Key"))
+	c.serverSignature = scramHMAC(serverKey, authMessage)
+
+	return []byte(fmt.Sprintf("%s,p=%s", finalWithoutProof,
+		base64.StdEncoding.EncodeToString(clientProof))), nil
+}
+
+// verifyServerFinal validates the server signature provided in the
+// server-final-message.
+func (c *scramClient) verifyServerFinal(serverFinal []byte) error {
+	message := string(serverFinal)
+
+	if strings.HasPrefix(message, "e=") {
+		return fmt.Errorf("scram: server error: %s", message[2:])
+	}
+
+	if !strings.HasPrefix(message, "v=") {
+		return errors.New("scram: malformed server-final-message")
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(message[2:])
+
+	if err != nil {
+		return fmt.Errorf("scram: invalid server signature: %s", err.Error())
+	}
+
+	if !hmac.Equal(signature, c.serverSignature) {
+		return errors.New("scram: server signature does not match")
+	}
+
+	return nil
+}
+
+/* The iteration count of verifiers the proxy derives from clear text passwords. */
+const scramIterations = 4096
+
+// scramVerifier holds the SCRAM-SHA-256 secrets of a user, as stored by
+// PostgreSQL in the form 'SCRAM-SHA-256$<iterations>:<salt>$<StoredKey>:<ServerKey>'.
+type scramVerifier struct {
+	iterations int
+	salt       []byte
+	storedKey  []byte
+	serverKey  []byte
+}
+
+// parseSCRAMVerifier parses a stored SCRAM-SHA-256 verifier.
+func parseSCRAMVerifier(verifier string) (*scramVerifier, error) {
+	parts := strings.Split(verifier, "$")
+
+	if len(parts) != 3 || parts[0] != protocol.SASLMechanismSCRAMSHA256 {
+		return nil, errors.New("scram: malformed verifier")
+	}
+
+	iterationsSalt := strings.SplitN(parts[1], ":", 2)
+	keys := strings.SplitN(parts[2], ":", 2)
+
+	if len(iterationsSalt) != 2 || len(keys) != 2 {
+		return nil, errors.New("scram: malformed verifier")
+	}
+
+	iterations, err := strconv.Atoi(iterationsSalt[0])
+
+	if err != nil || iterations < 1 {
+		return nil, errors.New("scram: invalid iteration count in verifier")
+	}
+
+	v := &scramVerifier{iterations: iterations}
+
+	if v.salt, err = base64.StdEncoding.DecodeString(iterationsSalt[1]); err != nil {
+		return nil, fmt.Errorf("scram: invalid salt in verifier: %s", err.Error())
+	}
+
+	if v.storedKey, err = base64.StdEncoding.DecodeString(keys[0]); err != nil {
+		return nil, fmt.Errorf("scram: invalid stored key in verifier: %s", err.Error())
+	}
+
+	if v.serverKey, err = base64.StdEncoding.DecodeString(keys[1]); err != nil {
+		return nil, fmt.Errorf("scram: invalid server key in verifier: %s", err.Error())
+	}
+
+	return v, nil
+}
+
+// newSCRAMVerifier derives a verifier with a random salt from a clear text
+// password.
+func newSCRAMVerifier(password string) (*scramVerifier, error) {
+	salt := make([]byte, scramNonceLength)
+
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	saltedPassword := scramHi([]byte(password), salt, scramIterations)
+	storedKey := sha256.Sum256(scramHMAC(saltedPassword, []byte("Client Key")))
+
+	return &scramVerifier{
+		iterations: scramIterations,
+		salt:       salt,
+		storedKey:  storedKey[:],
+		serverKey:  scramHMAC(saltedPassword, []byte("Server Key")),
+	}, nil
+}
+
+// scramServer holds the state of a SCRAM-SHA-256 exchange performed by the
+// proxy against a client, verifying the client's proof with the verifier.
+type scramServer struct {
+	verifier        *scramVerifier
+	nonce           string
+	clientFirstBare string
+	serverFirst     string
+}
+
+func newSCRAMServer(verifier *scramVerifier) *scramServer {
+	return &scramServer{verifier: verifier}
+}
+
+// serverFirstMessage creates the server-first-message from the
+// client-first-message sent by the client.
+func (s *scramServer) serverFirstMessage(clientFirst []byte) ([]byte, error) {
+	/* The GS2 header is followed by the client-first-message-bare. */
+	parts := strings.SplitN(string(clientFirst), ",", 3)
+
+	if len(parts) != 3 {
+		return nil, errors.New("scram: malformed client-first-message")
+	}
+
+	switch {
+	case parts[0] == "n" || parts[0] == "y":
+	case strings.HasPrefix(parts[0], "p="):
+		return nil, errors.New("scram: channel binding is not supported")
+	default:
+		return nil, errors.New("scram: malformed client-first-message")
+	}
+
+	var clientNonce string
+
+	for _, attribute := range strings.Split(parts[2], ",") {
+		if strings.HasPrefix(attribute, "r=") {
+			clientNonce = attribute[2:]
+		}
+	}
+
+	if clientNonce == "" {
+		return nil, errors.New("scram: missing client nonce")
+	}
+
+	nonce := make([]byte, scramNonceLength)
+
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	s.clientFirstBare = parts[2]
+	s.nonce = clientNonce + base64.StdEncoding.EncodeToString(nonce)
+	s.serverFirst = fmt.Sprintf("r=%s,s=%s,i=%d", s.nonce,
+		base64.StdEncoding.EncodeToString(s.verifier.salt), s.verifier.iterations)
+
+	return []byte(s.serverFirst), nil
+}
+
+// serverFinalMessage verifies the client proof in the client-final-message
+// and creates the server-final-message that proves the server's identity.
+func (s *scramServer) serverFinalMessage(clientFinal []byte) ([]byte, error) {
+	message := string(clientFinal)
+	proofIndex := strings.LastIndex(message, ",p=")
+
+	if proofIndex < 0 {
+		return nil, errors.New("scram: malformed client-final-message")
+	}
+
+	finalWithoutProof := message[:proofIndex]
+
+	var nonce string
+
+	for _, attribute := range strings.Split(finalWithoutProof, ",") {
+		if strings.HasPrefix(attribute, "r=") {
+			nonce = attribute[2:]
+		}
+	}
+
+	if nonce != s.nonce {
+		return nil, errors.New("scram: invalid client nonce")
+	}
+
+	proof, err := base64.StdEncoding.DecodeString(message[proofIndex+3:])
+
+	if err != nil || len(proof) != sha256.Size {
+		return nil, errors.New("scram: invalid client proof")
+	}
+
+	authMessage := []byte(s.clientFirstBare + "," + s.serverFirst + "," +
+		finalWithoutProof)
+
+	clientSignature := scramHMAC(s.verifier.storedKey, authMessage)
+
+	clientKey := make([]byte, len(proof))
+	for i := range proof {
+		clientKey[i] = proof[i] ^ clientSignature[i]
+	}
+
+	storedKey := sha256.Sum256(clientKey)
+
+	if !hmac.Equal(storedKey[:], s.verifier.storedKey) {
+		return nil, errors.New("scram: client proof does not match")
+	}
+
+	serverSignature := scramHMAC(s.verifier.serverKey, authMessage)
+
+	return []byte("v=" + base64.StdEncoding.EncodeToString(serverSignature)), nil
+}
+
+func scramHMAC(key []byte, message []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(message)
+	return mac.Sum(nil)
+}
+
+// scramHi is the Hi() function as defined by RFC 5802, which is PBKDF2 with
+// HMAC-SHA-256 producing a single block of output.
+func scramHi(password []byte, salt []byte, iterations int) []byte {
+	mac := hmac.New(sha256.New, password)
+	mac.Write(salt)
+	mac.Write([]byte{0, 0, 0, 1})
+	u := mac.Sum(nil)
+
+	result := make([]byte, len(u))
+	copy(result, u)
+
+	for i := 1; i < iterations; i++ {
+		mac.Reset()
+		mac.Write(u)
+		u = mac.Sum(u[:0])
+
+		for j := range result {
+			result[j] ^= u[j]
+		}
+	}
+
+	return result
+}
@@ -18,9 +18,11 @@ package connect
 import (
 	"crypto/tls"
 	"crypto/x509"
+	"errors"
 	"io/ioutil"
 	"net"
 
+	"github.com/crunchydata/crunchy-proxy/common"
 	"github.com/crunchydata/crunchy-proxy/config"
 	"github.com/crunchydata/crunchy-proxy/util/log"
 )
@@ -34,31 +36,142 @@ const (
 	SSL_NOT_ALLOWED byte = 'N'
 
 	/* SSL Modes */
+	SSL_MODE_ALLOW       string = "allow"
+	SSL_MODE_PREFER      string = "prefer"
 	SSL_MODE_REQUIRE     string = "require"
 	SSL_MODE_VERIFY_CA   string = "verify-ca"
 	SSL_MODE_VERIFY_FULL string = "verify-full"
 	SSL_MODE_DISABLE     string = "disable"
 )
 
-/*
- *
- */
-func UpgradeServerConnection(client net.Conn) net.Conn {
-	creds := config.GetCredentials()
+// GetServerSSLConfig returns the SSL configuration for client connections to
+// the proxy.
+//
+// If SSL has not been configured for the proxy listener, but it has been
+// enabled for the pool connections along with a server certificate, then
+// the server certificate from the 'credentials' section is used.
+func GetServerSSLConfig() common.SSLConfig {
+	sslConfig := config.GetProxyConfig().SSL
 
-	if creds.SSL.Enable {
-		tlsConfig := tls.Config{}
+	if !sslConfig.Enable {
+		creds := config.GetCredentials()
 
-		cert, _ := tls.LoadX509KeyPair(
-			creds.SSL.SSLServerCert,
-			creds.SSL.SSLServerKey)
+		if creds.SSL.Enable && creds.SSL.SSLServerCert != "" {
+			sslConfig = common.SSLConfig{
+				Enable:    true,
+				SSLCert:   creds.SSL.SSLServerCert,
+				SSLKey:    creds.SSL.SSLServerKey,
+				SSLRootCA: creds.SSL.SSLServerCA,
+			}
+		}
+	}
 
-		tlsConfig.Certificates = []tls.Certificate{cert}
+	if sslConfig.SSLMode == "" {
+		sslConfig.SSLMode = SSL_MODE_PREFER
+	}
 
-		client = tls.Server(client, &tlsConfig)
+	return sslConfig
+}
+
+// GetListenerSSLConfig returns the SSL configuration for the clients of a
+// listener, which overrides that of the proxy if it is given.
+func GetListenerSSLConfig(override *common.SSLConfig) common.SSLConfig {
+	if override == nil {
+		return GetServerSSLConfig()
 	}
 
-	return client
+	sslConfig := *override
+
+	if sslConfig.SSLMode == "" {
+		sslConfig.SSLMode = SSL_MODE_PREFER
+	}
+
+	return sslConfig
+}
+
+// GetServerTLSConfig creates the TLS configuration used to upgrade client
+// connections to the proxy. The certificate is looked up for each handshake,
+// so that a certificate that has been replaced on disk is used for new
+// connections.
+func GetServerTLSConfig(sslConfig common.SSLConfig) (*tls.Config, error) {
+	if _, err := loadCertificate(sslConfig.SSLCert, sslConfig.SSLKey); err != nil {
+		return nil, err
+	}
+
+	tlsConfig := &tls.Config{
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return loadCertificate(sslConfig.SSLCert, sslConfig.SSLKey)
+		},
+	}
+
+	/*
+	 * If client certificates are to be verified, then require that every
+	 * client presents a certificate signed by the configured root CA. Clients
+	 * that do not will fail the TLS handshake.
+	 */
+	if sslConfig.ClientCert != "" {
+		if sslConfig.SSLRootCA == "" {
+			return nil, errors.New("sslrootca is required to verify client certificates")
+		}
+
+		clientCAs, err := loadCertPool(sslConfig.SSLRootCA)
+
+		if err != nil {
+			return nil, err
+		}
+
+		tlsConfig.ClientCAs = clientCAs
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return tlsConfig, nil
+}
+
+// ValidateClientCertificate checks the certificate presented by a client
+// against the user provided in its startup message. When 'clientcert' is set
+// to 'verify-full', the common name of the certificate, or the role it is
+// mapped to by 'usermap', must match the user.
+func ValidateClientCertificate(client net.Conn, message []byte, sslConfig common.SSLConfig) bool {
+	if sslConfig.ClientCert != SSL_MODE_VERIFY_FULL {
+		return true
+	}
+
+	conn, ok := client.(*tls.Conn)
+
+	if !ok {
+		return false
+	}
+
+	certificates := conn.ConnectionState().PeerCertificates
+
+	if len(certificates) == 0 {
+		return false
+	}
+
+	role := certificates[0].Subject.CommonName
+
+	if mapped, ok := sslConfig.UserMap[role]; ok {
+		role = mapped
+	}
+
+	return role == GetStartupParameters(message)["user"]
+}
+
+/*
+ * Upgrade a client connection to the proxy to a TLS connection and perform the
+ * TLS handshake.
+ *
+ * client - the client connection.
+ * tlsConfig - the server TLS configuration.
+ */
+func UpgradeServerConnection(client net.Conn, tlsConfig *tls.Config) (net.Conn, error) {
+	conn := tls.Server(client, tlsConfig)
+
+	if err := conn.Handshake(); err != nil {
+		return client, err
+	}
+
+	return conn, nil
 }
 
 /*
@@ -102,8 +215,9 @@ func UpgradeClientConnection(hostPort string, connection net.Conn) net.Conn {
 
 	/* Add client SSL certificate and key. */
 	log.Debug("Loading SSL certificate and key")
-	cert, _ := tls.LoadX509KeyPair(creds.SSL.SSLCert, creds.SSL.SSLKey)
-	tlsConfig.Certificates = []tls.Certificate{cert}
+	if cert, err := loadCertificate(creds.SSL.SSLCert, creds.SSL.SSLKey); err == nil {
+		tlsConfig.Certificates = []tls.Certificate{*cert}
+	}
 
 	/* Add root CA certificate. */
 	log.Debug("Loading root CA.")
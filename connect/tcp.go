@@ -0,0 +1,65 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package connect
+
+import (
+	"net"
+	"time"
+
+	"github.com/crunchydata/crunchy-proxy/config"
+	"github.com/crunchydata/crunchy-proxy/util/log"
+)
+
+// SetTCPOptions applies the configured socket options to a client or backend
+// connection. Options that are not set keep the defaults of Go and the
+// operating system, under which keepalives are sent after 15 seconds of
+// inactivity and TCP_NODELAY is enabled. Connections that are not TCP, such as
+// those over a Unix socket, are left as they are.
+func SetTCPOptions(connection net.Conn, tcpConfig config.TCPConfig) {
+	tcp, ok := connection.(*net.TCPConn)
+
+	if !ok {
+		return
+	}
+
+	var err error
+
+	if tcpConfig.KeepAliveIdle > 0 || tcpConfig.KeepAliveInterval > 0 ||
+		tcpConfig.KeepAliveCount > 0 {
+		err = tcp.SetKeepAliveConfig(net.KeepAliveConfig{
+			Enable:   true,
+			Idle:     time.Duration(tcpConfig.KeepAliveIdle) * time.Second,
+			Interval: time.Duration(tcpConfig.KeepAliveInterval) * time.Second,
+			Count:    tcpConfig.KeepAliveCount,
+		})
+	}
+
+	if err == nil && tcpConfig.NoDelay != nil {
+		err = tcp.SetNoDelay(*tcpConfig.NoDelay)
+	}
+
+	if err == nil && tcpConfig.SendBuffer > 0 {
+		err = tcp.SetWriteBuffer(tcpConfig.SendBuffer)
+	}
+
+	if err == nil && tcpConfig.ReceiveBuffer > 0 {
+		err = tcp.SetReadBuffer(tcpConfig.ReceiveBuffer)
+	}
+
+	if err != nil {
+		log.Errorf("Error setting TCP options on connection %s", connection.RemoteAddr())
+		log.Errorf("Error: %s", err.Error())
+	}
+}
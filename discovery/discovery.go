@@ -0,0 +1,98 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package discovery
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/crunchydata/crunchy-proxy/common"
+	"github.com/crunchydata/crunchy-proxy/config"
+	"github.com/crunchydata/crunchy-proxy/util/log"
+)
+
+// Source finds the nodes of the proxy.
+type Source interface {
+	// Discover returns the nodes that are currently available, by name.
+	Discover() (map[string]common.Node, error)
+
+	// Interval returns how long to wait between discoveries.
+	Interval() time.Duration
+}
+
+// Enabled returns whether a discovery source is configured.
+func Enabled() bool {
+	return config.GetKubernetesConfig().Enable || config.GetDNSConfig().Enable
+}
+
+// NewSource creates the configured discovery source.
+func NewSource() (Source, error) {
+	kubernetesConfig := config.GetKubernetesConfig()
+	dnsConfig := config.GetDNSConfig()
+
+	switch {
+	case kubernetesConfig.Enable && dnsConfig.Enable:
+		return nil, errors.New("only one discovery source may be enabled")
+	case kubernetesConfig.Enable:
+		return newKubernetesClient(kubernetesConfig)
+	case dnsConfig.Enable:
+		return newDNSResolver(dnsConfig)
+	}
+
+	return nil, errors.New("no discovery source is enabled")
+}
+
+// Run periodically discovers the nodes and replaces the previously discovered
+// nodes whenever they change, after which update is called to apply them. It
+// does not return unless discovery could not be set up.
+func Run(update func()) {
+	source, err := NewSource()
+
+	if err != nil {
+		log.Error("discovery: could not set up discovery")
+		log.Errorf("discovery: %s", err.Error())
+		return
+	}
+
+	for {
+		nodes, err := source.Discover()
+
+		if err != nil {
+			log.Error("discovery: could not discover nodes")
+			log.Errorf("discovery: %s", err.Error())
+		} else if config.SetDiscoveredNodes(nodes) {
+			log.Infof("discovery: found nodes %s", describeNodes(nodes))
+			update()
+		}
+
+		time.Sleep(source.Interval())
+	}
+}
+
+/* describeNodes lists the nodes and their roles for logging. */
+func describeNodes(nodes map[string]common.Node) string {
+	var names []string
+
+	for name, node := range nodes {
+		names = append(names, fmt.Sprintf("%s (%s, %s)", name, node.Role, node.HostPort))
+	}
+
+	sort.Strings(names)
+
+	return strings.Join(names, ", ")
+}
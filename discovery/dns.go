@@ -0,0 +1,122 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package discovery
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/crunchydata/crunchy-proxy/common"
+	"github.com/crunchydata/crunchy-proxy/config"
+)
+
+const lookupTimeout = 10 * time.Second
+
+// dnsResolver discovers the nodes by resolving the DNS names of the master
+// and of the replicas. Names are resolved again on every discovery, so that
+// changes are picked up once their records expire.
+type dnsResolver struct{}
+
+func newDNSResolver(dnsConfig config.DNSConfig) (*dnsResolver, error) {
+	if dnsConfig.Master == "" && dnsConfig.Replicas == "" {
+		return nil, errors.New("no DNS names are configured")
+	}
+
+	return &dnsResolver{}, nil
+}
+
+func (r *dnsResolver) Interval() time.Duration {
+	return time.Duration(config.GetDNSConfig().Interval) * time.Second
+}
+
+// Discover resolves the names of the replicas and of the master. Each address
+// is a node named after its host and port. An address that both names
+// resolve to is the master.
+func (r *dnsResolver) Discover() (map[string]common.Node, error) {
+	dnsConfig := config.GetDNSConfig()
+	nodes := make(map[string]common.Node)
+
+	names := []struct {
+		name string
+		role string
+	}{
+		{dnsConfig.Replicas, common.NODE_ROLE_REPLICA},
+		{dnsConfig.Master, common.NODE_ROLE_MASTER},
+	}
+
+	for _, n := range names {
+		if n.name == "" {
+			continue
+		}
+
+		hostPorts, err := resolve(n.name, dnsConfig)
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, hostPort := range hostPorts {
+			nodes[hostPort] = common.Node{
+				HostPort: hostPort,
+				Role:     n.role,
+			}
+		}
+	}
+
+	return nodes, nil
+}
+
+/*
+ * resolve looks up the host:port of each backend behind a name, either from
+ * its SRV records or from its addresses and the configured port.
+ */
+func resolve(name string, dnsConfig config.DNSConfig) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), lookupTimeout)
+	defer cancel()
+
+	var hostPorts []string
+
+	if dnsConfig.SRV {
+		_, records, err := net.DefaultResolver.LookupSRV(ctx, "", "", name)
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, record := range records {
+			host := strings.TrimSuffix(record.Target, ".")
+			hostPorts = append(hostPorts, net.JoinHostPort(host, strconv.Itoa(int(record.Port))))
+		}
+
+		return hostPorts, nil
+	}
+
+	addresses, err := net.DefaultResolver.LookupHost(ctx, name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, address := range addresses {
+		hostPorts = append(hostPorts, net.JoinHostPort(address, strconv.Itoa(dnsConfig.Port)))
+	}
+
+	return hostPorts, nil
+}
@@ -0,0 +1,268 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package discovery
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/crunchydata/crunchy-proxy/common"
+	"github.com/crunchydata/crunchy-proxy/config"
+)
+
+/* The service account of a pod, used when running inside Kubernetes. */
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+const requestTimeout = 10 * time.Second
+
+/* The parts of the Kubernetes API objects that are used. */
+type service struct {
+	Spec struct {
+		Selector map[string]string `json:"selector"`
+	} `json:"spec"`
+}
+
+type endpoints struct {
+	Subsets []struct {
+		Addresses []struct {
+			IP        string `json:"ip"`
+			TargetRef *struct {
+				Kind string `json:"kind"`
+				Name string `json:"name"`
+			} `json:"targetRef"`
+		} `json:"addresses"`
+		Ports []struct {
+			Name string `json:"name"`
+			Port int    `json:"port"`
+		} `json:"ports"`
+	} `json:"subsets"`
+}
+
+type podList struct {
+	Items []struct {
+		Metadata struct {
+			Name   string            `json:"name"`
+			Labels map[string]string `json:"labels"`
+		} `json:"metadata"`
+	} `json:"items"`
+}
+
+type kubernetesClient struct {
+	client    *http.Client
+	server    string
+	tokenFile string
+	namespace string
+}
+
+// newKubernetesClient creates a client for the Kubernetes API that discovers
+// the nodes behind the configured service.
+func newKubernetesClient(kubernetesConfig config.KubernetesConfig) (*kubernetesClient, error) {
+	if kubernetesConfig.Service == "" {
+		return nil, errors.New("no service is configured")
+	}
+
+	k := &kubernetesClient{
+		server:    kubernetesConfig.APIServer,
+		tokenFile: kubernetesConfig.TokenFile,
+		namespace: kubernetesConfig.Namespace,
+	}
+
+	caFile := kubernetesConfig.CAFile
+
+	/* Fall back to the pod's service account. */
+	if k.server == "" {
+		host := os.Getenv("KUBERNETES_SERVICE_HOST")
+		port := os.Getenv("KUBERNETES_SERVICE_PORT")
+
+		if host == "" || port == "" {
+			return nil, errors.New("not running in Kubernetes and no API server is configured")
+		}
+
+		k.server = "https://" + net.JoinHostPort(host, port)
+
+		if k.tokenFile == "" {
+			k.tokenFile = serviceAccountDir + "/token"
+		}
+
+		if caFile == "" {
+			caFile = serviceAccountDir + "/ca.crt"
+		}
+	}
+
+	if k.namespace == "" {
+		namespace, err := ioutil.ReadFile(serviceAccountDir + "/namespace")
+
+		if err != nil {
+			return nil, errors.New("no namespace is configured")
+		}
+
+		k.namespace = strings.TrimSpace(string(namespace))
+	}
+
+	tlsConfig := &tls.Config{}
+
+	if caFile != "" {
+		ca, err := ioutil.ReadFile(caFile)
+
+		if err != nil {
+			return nil, err
+		}
+
+		tlsConfig.RootCAs = x509.NewCertPool()
+
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates found in '%s'", caFile)
+		}
+	}
+
+	k.client = &http.Client{
+		Timeout:   requestTimeout,
+		Transport: &http.Transport{TLSClientConfig: tlsConfig},
+	}
+
+	return k, nil
+}
+
+/* get reads an object from the Kubernetes API. */
+func (k *kubernetesClient) get(path string, object interface{}) error {
+	request, err := http.NewRequest("GET", k.server+path, nil)
+
+	if err != nil {
+		return err
+	}
+
+	/* The token is re-read each time as service account tokens are rotated. */
+	if k.tokenFile != "" {
+		token, err := ioutil.ReadFile(k.tokenFile)
+
+		if err != nil {
+			return err
+		}
+
+		request.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+
+	request.Header.Set("Accept", "application/json")
+
+	response, err := k.client.Do(request)
+
+	if err != nil {
+		return err
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", path, response.Status)
+	}
+
+	return json.NewDecoder(response.Body).Decode(object)
+}
+
+func (k *kubernetesClient) Interval() time.Duration {
+	return time.Duration(config.GetKubernetesConfig().Interval) * time.Second
+}
+
+// Discover finds the nodes behind the service. Each ready endpoint is a node,
+// named after its pod, and the pod's role label determines whether it is the
+// master or a replica.
+func (k *kubernetesClient) Discover() (map[string]common.Node, error) {
+	kubernetesConfig := config.GetKubernetesConfig()
+	prefix := "/api/v1/namespaces/" + url.PathEscape(k.namespace)
+
+	var svc service
+
+	if err := k.get(prefix+"/services/"+url.PathEscape(kubernetesConfig.Service), &svc); err != nil {
+		return nil, err
+	}
+
+	var eps endpoints
+
+	if err := k.get(prefix+"/endpoints/"+url.PathEscape(kubernetesConfig.Service), &eps); err != nil {
+		return nil, err
+	}
+
+	/* The labels of the service's pods, by pod name. */
+	labels := make(map[string]map[string]string)
+
+	if len(svc.Spec.Selector) > 0 {
+		var selector []string
+
+		for key, value := range svc.Spec.Selector {
+			selector = append(selector, key+"="+value)
+		}
+
+		var pods podList
+
+		query := "?labelSelector=" + url.QueryEscape(strings.Join(selector, ","))
+
+		if err := k.get(prefix+"/pods"+query, &pods); err != nil {
+			return nil, err
+		}
+
+		for _, pod := range pods.Items {
+			labels[pod.Metadata.Name] = pod.Metadata.Labels
+		}
+	}
+
+	nodes := make(map[string]common.Node)
+
+	for _, subset := range eps.Subsets {
+		port := 0
+
+		for _, p := range subset.Ports {
+			if kubernetesConfig.Port == "" || p.Name == kubernetesConfig.Port {
+				port = p.Port
+				break
+			}
+		}
+
+		if port == 0 {
+			continue
+		}
+
+		for _, address := range subset.Addresses {
+			name := address.IP
+
+			if address.TargetRef != nil && address.TargetRef.Kind == "Pod" {
+				name = address.TargetRef.Name
+			}
+
+			role := common.NODE_ROLE_REPLICA
+
+			if labels[name][kubernetesConfig.RoleLabel] == kubernetesConfig.MasterValue {
+				role = common.NODE_ROLE_MASTER
+			}
+
+			nodes[name] = common.Node{
+				HostPort: net.JoinHostPort(address.IP, strconv.Itoa(port)),
+				Role:     role,
+			}
+		}
+	}
+
+	return nodes, nil
+}
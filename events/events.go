@@ -0,0 +1,147 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/crunchydata/crunchy-proxy/config"
+	"github.com/crunchydata/crunchy-proxy/util/log"
+)
+
+/* The types of events. */
+const (
+	TypeNodeDown       string = "node_down"
+	TypeNodeUp         string = "node_up"
+	TypeFailover       string = "failover"
+	TypePoolExhausted  string = "pool_exhausted"
+	TypeClientRejected string = "client_rejected"
+)
+
+/* The number of events that may wait to be delivered before more are dropped. */
+const queueSize = 1000
+
+// Event is something that happened in the proxy that operators may want to be
+// alerted to or act on.
+type Event struct {
+	Time    time.Time `json:"time"`
+	Type    string    `json:"type"`
+	Node    string    `json:"node,omitempty"`
+	Client  string    `json:"client,omitempty"`
+	Message string    `json:"message"`
+}
+
+// Sink is a destination for events.
+type Sink interface {
+	// Send delivers a single event, along with its JSON encoding.
+	Send(event Event, payload []byte) error
+}
+
+var (
+	sinks []Sink
+	types map[string]bool // the types of events delivered, all if empty
+	queue chan Event
+	lock  sync.Mutex
+)
+
+// Setup creates the sinks configured in the 'events' section, replacing the
+// current ones. If events are disabled, then they are discarded.
+func Setup() error {
+	eventsConfig := config.GetEventsConfig()
+
+	var newSinks []Sink
+
+	if eventsConfig.Enable {
+		if eventsConfig.Webhook.URL != "" {
+			newSinks = append(newSinks, newWebhookSink(eventsConfig.Webhook))
+		}
+
+		if eventsConfig.Exec.Command != "" {
+			newSinks = append(newSinks, newExecSink(eventsConfig.Exec))
+		}
+
+		if len(newSinks) == 0 {
+			return errors.New("events: a webhook url or exec command is required")
+		}
+	}
+
+	newTypes := make(map[string]bool, len(eventsConfig.Types))
+
+	for _, eventType := range eventsConfig.Types {
+		newTypes[eventType] = true
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	sinks = newSinks
+	types = newTypes
+
+	if queue == nil {
+		queue = make(chan Event, queueSize)
+		go deliver(queue)
+	}
+
+	return nil
+}
+
+// Emit records an event. Events are delivered to the sinks in the background,
+// so that the proxy is never held up by them, and are dropped if the sinks
+// fall too far behind.
+func Emit(event Event) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	if len(sinks) == 0 || (len(types) > 0 && !types[event.Type]) {
+		return
+	}
+
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	select {
+	case queue <- event:
+	default:
+		log.Errorf("events: too many events waiting, dropped '%s' event", event.Type)
+	}
+}
+
+/* deliver sends each queued event to the current sinks in turn. */
+func deliver(queue chan Event) {
+	for event := range queue {
+		lock.Lock()
+		current := sinks
+		lock.Unlock()
+
+		payload, err := json.Marshal(event)
+
+		if err != nil {
+			log.Errorf("events: error encoding '%s' event", event.Type)
+			log.Errorf("Error: %s", err.Error())
+			continue
+		}
+
+		for _, sink := range current {
+			if err := sink.Send(event, payload); err != nil {
+				log.Errorf("events: error delivering '%s' event", event.Type)
+				log.Errorf("Error: %s", err.Error())
+			}
+		}
+	}
+}
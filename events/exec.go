@@ -0,0 +1,62 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package events
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/crunchydata/crunchy-proxy/config"
+)
+
+// execSink runs a command for each event. The command is passed the type of
+// the event as its argument and the event as JSON on its standard input, and
+// the fields of the event are also set in its environment.
+type execSink struct {
+	command string
+	timeout time.Duration
+}
+
+func newExecSink(execConfig config.EventExecConfig) *execSink {
+	return &execSink{
+		command: execConfig.Command,
+		timeout: time.Duration(execConfig.Timeout) * time.Second,
+	}
+}
+
+func (s *execSink) Send(event Event, payload []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, s.command, event.Type)
+	cmd.Stdin = bytes.NewReader(payload)
+	cmd.Env = append(os.Environ(),
+		"PROXY_EVENT_TYPE="+event.Type,
+		"PROXY_EVENT_NODE="+event.Node,
+		"PROXY_EVENT_CLIENT="+event.Client,
+		"PROXY_EVENT_MESSAGE="+event.Message)
+
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("events: '%s' failed: %s %s", s.command, err.Error(),
+			strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package events
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/crunchydata/crunchy-proxy/config"
+)
+
+// webhookSink posts each event as JSON to an HTTP endpoint.
+type webhookSink struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+func newWebhookSink(webhookConfig config.EventWebhookConfig) *webhookSink {
+	return &webhookSink{
+		url:     webhookConfig.URL,
+		headers: webhookConfig.Headers,
+		client: &http.Client{
+			Timeout: time.Duration(webhookConfig.Timeout) * time.Second,
+		},
+	}
+}
+
+func (s *webhookSink) Send(event Event, payload []byte) error {
+	request, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(payload))
+
+	if err != nil {
+		return err
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+
+	for name, value := range s.headers {
+		request.Header.Set(name, value)
+	}
+
+	response, err := s.client.Do(request)
+
+	if err != nil {
+		return err
+	}
+
+	response.Body.Close()
+
+	if response.StatusCode/100 != 2 {
+		return fmt.Errorf("events: webhook returned '%s'", response.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,242 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/crunchydata/crunchy-proxy/pool"
+)
+
+const namespace = "crunchy_proxy"
+
+/* Directions used to label the bytes proxied. */
+const (
+	DirectionClientToBackend string = "client_to_backend"
+	DirectionBackendToClient string = "backend_to_client"
+)
+
+/* Routes used to label query durations. */
+const (
+	RouteRead  string = "read"
+	RouteWrite string = "write"
+)
+
+/* Cohorts and results used to label the read queries of a canary. */
+const (
+	CohortCanary   string = "canary"
+	CohortBaseline string = "baseline"
+
+	ResultSuccess string = "success"
+	ResultError   string = "error"
+)
+
+/* Results used to label mirrored queries. */
+const (
+	MirrorRun     string = "run"
+	MirrorDropped string = "dropped"
+	MirrorFailed  string = "failed"
+)
+
+var (
+	ClientConnections = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "client_connections",
+		Help:      "Number of active client connections.",
+	})
+
+	BytesProxied = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "bytes_proxied_total",
+		Help:      "Number of bytes relayed between clients and backends.",
+	}, []string{"direction"})
+
+	Queries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "queries_total",
+		Help:      "Number of queries routed to each node.",
+	}, []string{"node", "role"})
+
+	BackendErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "backend_connection_errors_total",
+		Help:      "Number of errors connecting to or communicating with a node.",
+	}, []string{"node"})
+
+	QueryRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "query_retries_total",
+		Help:      "Number of read queries retried after a node failed them with a transient error.",
+	}, []string{"node", "code"})
+
+	MirroredQueries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "mirrored_queries_total",
+		Help:      "Number of queries mirrored to the shadow cluster, by whether they were run, dropped or failed.",
+	}, []string{"result"})
+
+	QueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "query_duration_seconds",
+		Help:      "Time from sending a query to a node until the node is ready for the next one.",
+		Buckets:   prometheus.ExponentialBuckets(0.0001, 2, 20),
+	}, []string{"node", "route"})
+
+	CohortQueries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cohort_queries_total",
+		Help:      "Number of read queries run by the canary replica and by the other replicas, by result.",
+	}, []string{"cohort", "result"})
+
+	CohortQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "cohort_query_duration_seconds",
+		Help:      "Duration of the read queries run by the canary replica and by the other replicas.",
+		Buckets:   prometheus.ExponentialBuckets(0.0001, 2, 20),
+	}, []string{"cohort"})
+
+	NodeHealthy = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "node_healthy",
+		Help:      "Result of the last health check for each node (1 = healthy).",
+	}, []string{"node"})
+)
+
+/* The collectors registered for a pool. */
+type poolCollector struct {
+	pool       *pool.Pool
+	collectors []prometheus.Collector
+}
+
+/* The collectors registered for each pool, keyed by node, database and user. */
+var poolCollectors = make(map[string]poolCollector)
+var poolLock sync.Mutex
+
+func init() {
+	prometheus.MustRegister(
+		ClientConnections,
+		BytesProxied,
+		Queries,
+		BackendErrors,
+		QueryRetries,
+		MirroredQueries,
+		QueryDuration,
+		CohortQueries,
+		CohortQueryDuration,
+		NodeHealthy,
+	)
+}
+
+// RegisterPool registers gauges reporting the capacity and utilization of the
+// provided pool. Any gauges previously registered for a pool with the same
+// node, database and user are replaced.
+func RegisterPool(p *pool.Pool) {
+	labels := prometheus.Labels{
+		"node":     p.Name,
+		"database": p.Database,
+		"user":     p.Username,
+	}
+
+	collectors := []prometheus.Collector{
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Name:        "pool_capacity",
+			Help:        "Number of connections in the pool for each node.",
+			ConstLabels: labels,
+		}, func() float64 {
+			return float64(p.Capacity)
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Name:        "pool_connections_in_use",
+			Help:        "Number of pool connections currently held by clients.",
+			ConstLabels: labels,
+		}, func() float64 {
+			return float64(p.Stats().InUse)
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Name:        "pool_clients_waiting",
+			Help:        "Number of clients waiting for a pool connection.",
+			ConstLabels: labels,
+		}, func() float64 {
+			return float64(p.Stats().Waiting)
+		}),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Name:        "pool_acquire_timeouts_total",
+			Help:        "Number of clients that timed out waiting for a pool connection.",
+			ConstLabels: labels,
+		}, func() float64 {
+			return float64(p.Stats().Timeouts)
+		}),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Name:        "pool_queue_full_total",
+			Help:        "Number of clients turned away because too many were waiting for a pool connection.",
+			ConstLabels: labels,
+		}, func() float64 {
+			return float64(p.Stats().Rejected)
+		}),
+	}
+
+	poolLock.Lock()
+	defer poolLock.Unlock()
+
+	key := poolKey(p)
+
+	for _, collector := range poolCollectors[key].collectors {
+		prometheus.Unregister(collector)
+	}
+
+	prometheus.MustRegister(collectors...)
+	poolCollectors[key] = poolCollector{pool: p, collectors: collectors}
+}
+
+// UnregisterPool removes the gauges registered for the pool. Nothing is
+// removed if the gauges have since been replaced by those of another pool.
+func UnregisterPool(p *pool.Pool) {
+	poolLock.Lock()
+	defer poolLock.Unlock()
+
+	key := poolKey(p)
+
+	if poolCollectors[key].pool != p {
+		return
+	}
+
+	for _, collector := range poolCollectors[key].collectors {
+		prometheus.Unregister(collector)
+	}
+
+	delete(poolCollectors, key)
+}
+
+func poolKey(p *pool.Pool) string {
+	return p.Name + "/" + p.Database + "/" + p.Username
+}
+
+// SetNodeHealth records the result of a health check for a node.
+func SetNodeHealth(node string, healthy bool) {
+	var value float64
+
+	if healthy {
+		value = 1
+	}
+
+	NodeHealthy.WithLabelValues(node).Set(value)
+}
@@ -15,35 +15,455 @@ limitations under the License.
 package pool
 
 import (
+	"container/list"
+	"errors"
 	"net"
+	"sync"
+	"time"
 )
 
+// ErrAcquireTimeout is returned when no connection becomes available within
+// the time a client may wait for one.
+var ErrAcquireTimeout = errors.New("timed out waiting for a pool connection")
+
+// ErrQueueFull is returned when no connection is idle and the queue of
+// clients waiting for one is already as long as allowed.
+var ErrQueueFull = errors.New("too many clients waiting for a pool connection")
+
+// ErrPoolClosed is returned when the pool is closed, for example because its
+// node was removed or reconfigured, before a connection could be handed out.
+var ErrPoolClosed = errors.New("the pool was closed")
+
 type Pool struct {
 	connections chan net.Conn
 	Name        string
+	Database    string
+	Username    string
 	Capacity    int
+	Mode        string
+	closed      bool
+	opening     int        // connections reserved by Reserve that are being opened
+	waiters     *list.List // clients waiting for a connection, oldest first
+	lock        *sync.Mutex
+
+	/* Statistics */
+	created   map[net.Conn]time.Time // when each connection was added
+	returned  map[net.Conn]time.Time // when each connection was last idle
+	inUse     map[net.Conn]bool      // connections held by clients
+	waits     int64                  // connections handed out
+	totalWait time.Duration          // time spent waiting for connections
+	timeouts  int64                  // clients that gave up waiting
+	rejected  int64                  // clients turned away by a full queue
 }
 
-func NewPool(name string, capacity int) *Pool {
+/*
+ * A client waiting for a connection, which is handed to it directly. The
+ * channel is closed if the pool is closed first.
+ */
+type waiter struct {
+	connection chan net.Conn
+	since      time.Time
+}
+
+// Server describes a connection in a pool.
+type Server struct {
+	Connection net.Conn
+	Created    time.Time
+	InUse      bool
+}
+
+// Stats is a snapshot of the state of a pool.
+type Stats struct {
+	Name        string
+	Database    string
+	Username    string
+	Total       int
+	InUse       int
+	Idle        int
+	Waiting     int
+	AverageWait time.Duration
+	MaxWait     time.Duration // of the clients currently waiting
+	Timeouts    int64
+	Rejected    int64
+	AverageAge  time.Duration
+}
+
+func NewPool(name string, database string, username string, capacity int, mode string) *Pool {
 	return &Pool{
 		connections: make(chan net.Conn, capacity),
 		Name:        name,
+		Database:    database,
+		Username:    username,
 		Capacity:    capacity,
+		Mode:        mode,
+		waiters:     list.New(),
+		lock:        &sync.Mutex{},
+		created:     make(map[net.Conn]time.Time),
+		returned:    make(map[net.Conn]time.Time),
+		inUse:       make(map[net.Conn]bool),
 	}
 }
 
+// Reserve reserves room in the pool for a new connection. It returns false if
+// the pool has been closed or already has its capacity of connections,
+// counting those that are still being opened. The reservation ends when the
+// connection is added, or with Unreserve if it could not be opened.
+func (p *Pool) Reserve() bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.closed || len(p.created)+p.opening >= p.Capacity {
+		return false
+	}
+
+	p.opening++
+
+	return true
+}
+
+// Unreserve ends a reservation for a connection that could not be opened.
+func (p *Pool) Unreserve() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.opening > 0 {
+		p.opening--
+	}
+}
+
+// Add adds a new connection to the pool, ending its reservation. If the pool
+// has been closed or already has its capacity of connections, then the
+// connection is closed instead.
 func (p *Pool) Add(connection net.Conn) {
-	p.connections <- connection
+	p.lock.Lock()
+
+	if p.opening > 0 {
+		p.opening--
+	}
+
+	if p.closed || len(p.created) >= p.Capacity {
+		p.lock.Unlock()
+		connection.Close()
+		return
+	}
+
+	now := time.Now()
+	p.created[connection] = now
+	p.returned[connection] = now
+	p.release(connection)
+	p.lock.Unlock()
 }
 
+// Next returns a connection from the pool, waiting as long as it takes for
+// one to become available.
 func (p *Pool) Next() net.Conn {
-	return <-p.connections
+	connection, _ := p.Acquire(0, 0)
+
+	return connection
 }
 
-func (p *Pool) Return(connection net.Conn) {
+// Acquire returns a connection from the pool. If none is idle, then the
+// client joins the back of the queue of waiting clients and connections are
+// handed to them in the order they arrived. If no connection is handed to
+// the client within the timeout, then ErrAcquireTimeout is returned. A zero
+// timeout waits indefinitely. If maxWaiting clients are already waiting, then
+// ErrQueueFull is returned at once rather than joining the queue; zero allows
+// any number of clients to wait. If the pool is closed, or is closed while
+// the client waits, then ErrPoolClosed is returned.
+func (p *Pool) Acquire(timeout time.Duration, maxWaiting int) (net.Conn, error) {
+	start := time.Now()
+
+	p.lock.Lock()
+
+	if p.closed {
+		p.lock.Unlock()
+		return nil, ErrPoolClosed
+	}
+
+	/*
+	 * Take an idle connection, unless other clients are already waiting, in
+	 * which case they are served first.
+	 */
+	if p.waiters.Len() == 0 {
+		select {
+		case connection := <-p.connections:
+			p.acquired(connection, start)
+			p.lock.Unlock()
+			return connection, nil
+		default:
+		}
+	}
+
+	if maxWaiting > 0 && p.waiters.Len() >= maxWaiting {
+		p.rejected++
+		p.lock.Unlock()
+		return nil, ErrQueueFull
+	}
+
+	w := &waiter{connection: make(chan net.Conn, 1), since: start}
+	element := p.waiters.PushBack(w)
+	p.lock.Unlock()
+
+	var expired <-chan time.Time
+
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	select {
+	case connection, ok := <-w.connection:
+		if !ok {
+			return nil, ErrPoolClosed
+		}
+
+		p.lock.Lock()
+		p.acquired(connection, start)
+		p.lock.Unlock()
+		return connection, nil
+	case <-expired:
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	/*
+	 * A connection may have been handed to the client just as it gave up, in
+	 * which case it is used rather than returned.
+	 */
+	select {
+	case connection, ok := <-w.connection:
+		if !ok {
+			return nil, ErrPoolClosed
+		}
+
+		p.acquired(connection, start)
+		return connection, nil
+	default:
+	}
+
+	p.waiters.Remove(element)
+	p.timeouts++
+
+	return nil, ErrAcquireTimeout
+}
+
+/* Record that a connection was handed to a client. The lock must be held. */
+func (p *Pool) acquired(connection net.Conn, start time.Time) {
+	p.inUse[connection] = true
+	p.waits++
+	p.totalWait += time.Since(start)
+}
+
+/*
+ * Hand a connection to the client that has waited longest for one, or make it
+ * idle if no client is waiting. The lock must be held.
+ */
+func (p *Pool) release(connection net.Conn) {
+	if front := p.waiters.Front(); front != nil {
+		p.waiters.Remove(front)
+		front.Value.(*waiter).connection <- connection
+		return
+	}
+
 	p.connections <- connection
 }
 
+// Return gives a connection back to the pool. If the pool has been closed then
+// the connection is closed instead.
+func (p *Pool) Return(connection net.Conn) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	delete(p.inUse, connection)
+
+	if p.closed {
+		delete(p.created, connection)
+		delete(p.returned, connection)
+		connection.Close()
+		return
+	}
+
+	p.returned[connection] = time.Now()
+	p.release(connection)
+}
+
+// Take removes an idle connection from the pool without handing it to a
+// client, for example to check that it still works, and returns nil if none is
+// idle. The connection must be given back with Restore or removed with Discard.
+func (p *Pool) Take() net.Conn {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	select {
+	case connection := <-p.connections:
+		p.inUse[connection] = true
+		return connection
+	default:
+		return nil
+	}
+}
+
+// Restore gives back a connection removed with Take, without counting it as
+// having been used. If the pool has been closed then the connection is closed
+// instead.
+func (p *Pool) Restore(connection net.Conn) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	delete(p.inUse, connection)
+
+	if p.closed {
+		delete(p.created, connection)
+		delete(p.returned, connection)
+		connection.Close()
+		return
+	}
+
+	p.release(connection)
+}
+
+// Discard removes a connection that is in use from the pool and closes it,
+// rather than returning it, when its state can no longer be trusted.
+func (p *Pool) Discard(connection net.Conn) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	delete(p.inUse, connection)
+	delete(p.created, connection)
+	delete(p.returned, connection)
+	connection.Close()
+}
+
 func (p *Pool) Len() int {
 	return len(p.connections)
 }
+
+// Size returns the number of connections that belong to the pool, whether
+// they are idle or in use.
+func (p *Pool) Size() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return len(p.created)
+}
+
+// Expire removes the idle connections that were added more than maxLifetime
+// ago or have been idle for longer than idleTimeout, and returns them so that
+// they can be closed and replaced. A duration of zero disables that check.
+func (p *Pool) Expire(maxLifetime time.Duration, idleTimeout time.Duration) []net.Conn {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	var expired, idle []net.Conn
+
+	now := time.Now()
+
+	for done := false; !done; {
+		select {
+		case connection := <-p.connections:
+			if (maxLifetime > 0 && now.Sub(p.created[connection]) > maxLifetime) ||
+				(idleTimeout > 0 && now.Sub(p.returned[connection]) > idleTimeout) {
+				delete(p.created, connection)
+				delete(p.returned, connection)
+				expired = append(expired, connection)
+			} else {
+				idle = append(idle, connection)
+			}
+		default:
+			done = true
+		}
+	}
+
+	for _, connection := range idle {
+		p.connections <- connection
+	}
+
+	return expired
+}
+
+// Close closes all of the idle connections in the pool. Connections that are
+// currently in use are closed as they are returned. Clients waiting for a
+// connection are woken, and fail with ErrPoolClosed.
+func (p *Pool) Close() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.closed = true
+
+	for front := p.waiters.Front(); front != nil; front = p.waiters.Front() {
+		p.waiters.Remove(front)
+		close(front.Value.(*waiter).connection)
+	}
+
+	for {
+		select {
+		case connection := <-p.connections:
+			delete(p.created, connection)
+			delete(p.returned, connection)
+			connection.Close()
+		default:
+			return
+		}
+	}
+}
+
+// Stats returns the current statistics of the pool.
+func (p *Pool) Stats() Stats {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	idle := len(p.connections)
+
+	stats := Stats{
+		Name:     p.Name,
+		Database: p.Database,
+		Username: p.Username,
+		Total:    len(p.created),
+		Idle:     idle,
+		InUse:    len(p.created) - idle,
+		Waiting:  p.waiters.Len(),
+		Timeouts: p.timeouts,
+		Rejected: p.rejected,
+	}
+
+	if front := p.waiters.Front(); front != nil {
+		stats.MaxWait = time.Since(front.Value.(*waiter).since)
+	}
+
+	if p.waits > 0 {
+		stats.AverageWait = p.totalWait / time.Duration(p.waits)
+	}
+
+	if len(p.created) > 0 {
+		var age time.Duration
+		now := time.Now()
+
+		for _, created := range p.created {
+			age += now.Sub(created)
+		}
+
+		stats.AverageAge = age / time.Duration(len(p.created))
+	}
+
+	return stats
+}
+
+// Servers returns the connections in the pool.
+func (p *Pool) Servers() []Server {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	servers := make([]Server, 0, len(p.created))
+
+	for connection, created := range p.created {
+		servers = append(servers, Server{
+			Connection: connection,
+			Created:    created,
+			InUse:      p.inUse[connection],
+		})
+	}
+
+	return servers
+}
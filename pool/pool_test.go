@@ -0,0 +1,216 @@
+/*
+Copyright 2016 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pool
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+/* How long a test waits for a client that should have been woken. */
+const wakeTimeout = 5 * time.Second
+
+func newConnection(t *testing.T) net.Conn {
+	client, server := net.Pipe()
+
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+
+	return client
+}
+
+/* Start a client waiting on the pool and report the result of its Acquire. */
+func acquireAsync(p *Pool, timeout time.Duration, maxWaiting int) <-chan error {
+	result := make(chan error, 1)
+
+	go func() {
+		_, err := p.Acquire(timeout, maxWaiting)
+		result <- err
+	}()
+
+	return result
+}
+
+/* Wait until the pool has the number of clients waiting on it. */
+func waitForWaiters(t *testing.T, p *Pool, count int) {
+	deadline := time.Now().Add(wakeTimeout)
+
+	for p.Stats().Waiting != count {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d waiting clients, have %d", count, p.Stats().Waiting)
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestAcquire(t *testing.T) {
+	tests := []struct {
+		name       string
+		idle       int // connections in the pool
+		waiting    int // clients already waiting
+		maxWaiting int
+		closed     bool
+		expected   error
+	}{
+		{name: "idle connection", idle: 1},
+		{name: "no idle connection", expected: ErrAcquireTimeout},
+		{name: "queue full", waiting: 1, maxWaiting: 1, expected: ErrQueueFull},
+		{name: "queue not full", waiting: 1, maxWaiting: 2, expected: ErrAcquireTimeout},
+		{name: "closed", idle: 1, closed: true, expected: ErrPoolClosed},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := NewPool("node", "db", "user", 2, "")
+
+			for i := 0; i < test.idle; i++ {
+				p.Add(newConnection(t))
+			}
+
+			for i := 0; i < test.waiting; i++ {
+				acquireAsync(p, wakeTimeout, 0)
+			}
+
+			waitForWaiters(t, p, test.waiting)
+
+			if test.closed {
+				p.Close()
+			}
+
+			connection, err := p.Acquire(10*time.Millisecond, test.maxWaiting)
+
+			if err != test.expected {
+				t.Fatalf("expected error %v, got %v", test.expected, err)
+			}
+
+			if err == nil && connection == nil {
+				t.Fatal("expected a connection")
+			}
+
+			p.Close()
+		})
+	}
+}
+
+func TestReturnServesOldestWaiter(t *testing.T) {
+	p := NewPool("node", "db", "user", 1, "")
+	connection := newConnection(t)
+	p.Add(connection)
+
+	held, err := p.Acquire(0, 0)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first := make(chan net.Conn, 1)
+
+	go func() {
+		c, _ := p.Acquire(wakeTimeout, 0)
+		first <- c
+	}()
+
+	waitForWaiters(t, p, 1)
+	second := acquireAsync(p, 50*time.Millisecond, 0)
+	waitForWaiters(t, p, 2)
+
+	p.Return(held)
+
+	select {
+	case c := <-first:
+		if c != connection {
+			t.Fatal("the oldest waiter did not receive the returned connection")
+		}
+	case <-time.After(wakeTimeout):
+		t.Fatal("the oldest waiter was not woken")
+	}
+
+	if err := <-second; err != ErrAcquireTimeout {
+		t.Fatalf("expected the second waiter to time out, got %v", err)
+	}
+}
+
+func TestCloseWakesWaiters(t *testing.T) {
+	tests := []struct {
+		name    string
+		waiting int
+		timeout time.Duration
+	}{
+		{name: "one waiter", waiting: 1, timeout: time.Minute},
+		{name: "several waiters", waiting: 3, timeout: time.Minute},
+		{name: "no timeout", waiting: 2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := NewPool("node", "db", "user", 1, "")
+
+			var results []<-chan error
+
+			for i := 0; i < test.waiting; i++ {
+				results = append(results, acquireAsync(p, test.timeout, 0))
+			}
+
+			waitForWaiters(t, p, test.waiting)
+
+			p.Close()
+
+			for _, result := range results {
+				select {
+				case err := <-result:
+					if err != ErrPoolClosed {
+						t.Fatalf("expected ErrPoolClosed, got %v", err)
+					}
+				case <-time.After(wakeTimeout):
+					t.Fatal("a waiting client was not woken by Close")
+				}
+			}
+
+			if waiting := p.Stats().Waiting; waiting != 0 {
+				t.Fatalf("expected no waiting clients, have %d", waiting)
+			}
+		})
+	}
+}
+
+func TestReturnAfterClose(t *testing.T) {
+	p := NewPool("node", "db", "user", 1, "")
+	client, server := net.Pipe()
+	defer server.Close()
+
+	p.Add(client)
+
+	connection, err := p.Acquire(0, 0)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p.Close()
+	p.Return(connection)
+
+	/* The connection is closed, so writing to it fails. */
+	if _, err := client.Write([]byte{0}); err == nil {
+		t.Fatal("expected the returned connection to be closed")
+	}
+
+	if stats := p.Stats(); stats.Total != 0 {
+		t.Fatalf("expected no connections, have %d", stats.Total)
+	}
+}
@@ -31,3 +31,182 @@ func CreatePasswordMessage(password string) []byte {
 
 	return message.Bytes()
 }
+
+/* SASL authentication mechanisms. */
+const (
+	SASLMechanismSCRAMSHA256     string = "SCRAM-SHA-256"
+	SASLMechanismSCRAMSHA256Plus string = "SCRAM-SHA-256-PLUS"
+)
+
+// CreateSASLInitialResponseMessage creates a SASLInitialResponse message. This
+// message selects the SASL mechanism and carries the client's first message
+// for that mechanism.
+func CreateSASLInitialResponseMessage(mechanism string, data []byte) []byte {
+	message := NewMessageBuffer([]byte{})
+
+	/* Set the message type */
+	message.WriteByte(PasswordMessageType)
+
+	/* Initialize the message length to zero. */
+	message.WriteInt32(0)
+
+	/* Add the selected mechanism and the initial response to the message. */
+	message.WriteString(mechanism)
+	message.WriteInt32(int32(len(data)))
+	message.WriteBytes(data)
+
+	/* Update the message length */
+	message.ResetLength(PGMessageLengthOffset)
+
+	return message.Bytes()
+}
+
+// CreateSASLResponseMessage creates a SASLResponse message which carries any
+// subsequent client data for the selected SASL mechanism.
+func CreateSASLResponseMessage(data []byte) []byte {
+	message := NewMessageBuffer([]byte{})
+
+	/* Set the message type */
+	message.WriteByte(PasswordMessageType)
+
+	/* Initialize the message length to zero. */
+	message.WriteInt32(0)
+
+	/* Add the SASL data to the message. */
+	message.WriteBytes(data)
+
+	/* Update the message length */
+	message.ResetLength(PGMessageLengthOffset)
+
+	return message.Bytes()
+}
+
+// GetSASLMechanisms gets the list of SASL mechanisms offered by the backend in
+// an AuthenticationSASL message.
+func GetSASLMechanisms(message []byte) []string {
+	var mechanisms []string
+
+	buffer := NewMessageBuffer(message)
+	buffer.Seek(9) // Seek past the message type, length and auth type.
+
+	for {
+		mechanism, err := buffer.ReadString()
+
+		if err != nil || mechanism == "" {
+			break
+		}
+
+		mechanisms = append(mechanisms, mechanism)
+	}
+
+	return mechanisms
+}
+
+// CreateAuthenticationSASLMessage creates an AuthenticationSASL message that
+// offers the provided list of SASL mechanisms.
+func CreateAuthenticationSASLMessage(mechanisms []string) []byte {
+	message := NewMessageBuffer([]byte{})
+
+	message.WriteByte(AuthenticationMessageType)
+	message.WriteInt32(0)
+	message.WriteInt32(AuthenticationSASL)
+
+	for _, mechanism := range mechanisms {
+		message.WriteString(mechanism)
+	}
+
+	/* The list of mechanisms is terminated by an empty string. */
+	message.WriteByte(0x00)
+
+	message.ResetLength(PGMessageLengthOffset)
+
+	return message.Bytes()
+}
+
+// GetSASLData gets the mechanism specific data carried by an
+// AuthenticationSASLContinue or AuthenticationSASLFinal message.
+func GetSASLData(message []byte) []byte {
+	length := int(GetMessageLength(message))
+
+	/* The data follows the message type, length and auth type. */
+	return message[9 : length+1]
+}
+
+// CreateAuthenticationClearTextMessage creates an
+// AuthenticationCleartextPassword message that asks the client for its
+// password in clear text.
+func CreateAuthenticationClearTextMessage() []byte {
+	return createAuthenticationMessage(AuthenticationClearText, nil)
+}
+
+// CreateAuthenticationMD5Message creates an AuthenticationMD5Password message
+// that asks the client for its password hashed with the provided salt.
+func CreateAuthenticationMD5Message(salt []byte) []byte {
+	return createAuthenticationMessage(AuthenticationMD5, salt)
+}
+
+// CreateAuthenticationSASLContinueMessage creates an
+// AuthenticationSASLContinue message carrying the server's SASL data.
+func CreateAuthenticationSASLContinueMessage(data []byte) []byte {
+	return createAuthenticationMessage(AuthenticationSASLContinue, data)
+}
+
+// CreateAuthenticationSASLFinalMessage creates an AuthenticationSASLFinal
+// message carrying the server's final SASL data.
+func CreateAuthenticationSASLFinalMessage(data []byte) []byte {
+	return createAuthenticationMessage(AuthenticationSASLFinal, data)
+}
+
+func createAuthenticationMessage(authType int32, data []byte) []byte {
+	message := NewMessageBuffer([]byte{})
+
+	message.WriteByte(AuthenticationMessageType)
+	message.WriteInt32(0)
+	message.WriteInt32(authType)
+	message.WriteBytes(data)
+
+	message.ResetLength(PGMessageLengthOffset)
+
+	return message.Bytes()
+}
+
+// GetPassword gets the password carried by a PasswordMessage.
+func GetPassword(message []byte) string {
+	buffer := NewMessageBuffer(message)
+	buffer.Seek(5) // Seek past the message type and length.
+
+	password, _ := buffer.ReadString()
+
+	return password
+}
+
+// GetSASLInitialResponse gets the mechanism selected by the client and the
+// data carried by a SASLInitialResponse message.
+func GetSASLInitialResponse(message []byte) (string, []byte) {
+	buffer := NewMessageBuffer(message)
+	buffer.Seek(5) // Seek past the message type and length.
+
+	mechanism, err := buffer.ReadString()
+
+	if err != nil {
+		return "", nil
+	}
+
+	length, err := buffer.ReadInt32()
+
+	if err != nil || length < 0 {
+		return mechanism, nil
+	}
+
+	data, _ := buffer.ReadBytes(int(length))
+
+	return mechanism, data
+}
+
+// GetSASLResponse gets the data carried by a SASLResponse message.
+func GetSASLResponse(message []byte) []byte {
+	length := int(GetMessageLength(message))
+
+	/* The data follows the message type and length. */
+	return message[5 : length+1]
+}
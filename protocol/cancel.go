@@ -0,0 +1,94 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package protocol
+
+import (
+	"encoding/binary"
+)
+
+// CreateCancelRequestMessage creates a CancelRequest message for the backend
+// process identified by the provided process ID and secret key.
+func CreateCancelRequestMessage(processID int32, secretKey int32) []byte {
+	message := NewMessageBuffer([]byte{})
+
+	/* Initialize the message length to zero. */
+	message.WriteInt32(0)
+
+	message.WriteInt32(CancelRequestCode)
+	message.WriteInt32(processID)
+	message.WriteInt32(secretKey)
+
+	/* Update the message length */
+	message.ResetLength(PGMessageLengthOffsetStartup)
+
+	return message.Bytes()
+}
+
+// GetCancelRequest gets the process ID and secret key from the provided
+// CancelRequest message.
+func GetCancelRequest(message []byte) (int32, int32) {
+	processID := int32(binary.BigEndian.Uint32(message[8:12]))
+	secretKey := int32(binary.BigEndian.Uint32(message[12:16]))
+
+	return processID, secretKey
+}
+
+// GetBackendKeyData finds the BackendKeyData message among the messages in the
+// buffer and returns the process ID and secret key it contains. If there is no
+// BackendKeyData message, then 'ok' is false.
+func GetBackendKeyData(buffer []byte) (processID int32, secretKey int32, ok bool) {
+	for start := 0; start+5 <= len(buffer); {
+		messageLength := int(GetMessageLength(buffer[start:]))
+
+		if messageLength < 4 {
+			break
+		}
+
+		if GetMessageType(buffer[start:]) == BackendKeyDataMessageType &&
+			start+13 <= len(buffer) {
+			processID = int32(binary.BigEndian.Uint32(buffer[start+5 : start+9]))
+			secretKey = int32(binary.BigEndian.Uint32(buffer[start+9 : start+13]))
+			return processID, secretKey, true
+		}
+
+		start = (start + messageLength + 1)
+	}
+
+	return 0, 0, false
+}
+
+// SetBackendKeyData replaces the process ID and secret key of the
+// BackendKeyData message among the messages in the buffer. It returns false if
+// there is no BackendKeyData message.
+func SetBackendKeyData(buffer []byte, processID int32, secretKey int32) bool {
+	for start := 0; start+5 <= len(buffer); {
+		messageLength := int(GetMessageLength(buffer[start:]))
+
+		if messageLength < 4 {
+			break
+		}
+
+		if GetMessageType(buffer[start:]) == BackendKeyDataMessageType &&
+			start+13 <= len(buffer) {
+			binary.BigEndian.PutUint32(buffer[start+5:start+9], uint32(processID))
+			binary.BigEndian.PutUint32(buffer[start+9:start+13], uint32(secretKey))
+			return true
+		}
+
+		start = (start + messageLength + 1)
+	}
+
+	return false
+}
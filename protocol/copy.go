@@ -0,0 +1,35 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package protocol
+
+// CreateCopyFailMessage creates a CopyFail message with the provided reason
+// for the failure.
+func CreateCopyFailMessage(reason string) []byte {
+	message := NewMessageBuffer([]byte{})
+
+	/* Set the message type */
+	message.WriteByte(CopyFailMessageType)
+
+	/* Initialize the message length to zero. */
+	message.WriteInt32(0)
+
+	/* Add the reason to the message. */
+	message.WriteString(reason)
+
+	/* Update the message length */
+	message.ResetLength(PGMessageLengthOffset)
+
+	return message.Bytes()
+}
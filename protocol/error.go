@@ -20,6 +20,7 @@ import (
 
 /* PG Error Severity Levels */
 const (
+	ErrorSeverityError   string = "ERROR"
 	ErrorSeverityFatal   string = "FATAL"
 	ErrorSeverityPanic   string = "PANIC"
 	ErrorSeverityWarning string = "WARNING"
@@ -75,9 +76,19 @@ func (e *Error) Error() string {
 }
 
 func (e *Error) GetMessage() []byte {
+	return e.getMessage(ErrorMessageType)
+}
+
+// GetNoticeMessage creates a NoticeResponse with the fields of the error, for
+// an error whose severity is that of a notice, such as NOTICE or WARNING.
+func (e *Error) GetNoticeMessage() []byte {
+	return e.getMessage(NoticeMessageType)
+}
+
+func (e *Error) getMessage(messageType byte) []byte {
 	msg := NewMessageBuffer([]byte{})
 
-	msg.WriteByte(ErrorMessageType)
+	msg.WriteByte(messageType)
 	msg.WriteInt32(0)
 
 	msg.WriteByte(ErrorFieldSeverity)
@@ -106,6 +117,33 @@ func (e *Error) GetMessage() []byte {
 	return msg.Bytes()
 }
 
+// CreateErrorMessage creates an ErrorResponse with the given severity, such as
+// ERROR or FATAL, SQLSTATE code and message. The detail and hint are left out
+// if they are empty.
+func CreateErrorMessage(severity string, code string, message string, detail string, hint string) []byte {
+	e := &Error{
+		Severity: severity,
+		Code:     code,
+		Message:  message,
+		Detail:   detail,
+		Hint:     hint,
+	}
+
+	return e.GetMessage()
+}
+
+// GetLegacyMessage creates the ErrorResponse of protocol 2.0 for the error,
+// which holds nothing but the message text. It is only used to turn away
+// clients of the old protocol in a way that they can understand.
+func (e *Error) GetLegacyMessage() []byte {
+	msg := NewMessageBuffer([]byte{})
+
+	msg.WriteByte(ErrorMessageType)
+	msg.WriteString(e.Severity + ":  " + e.Message + "\n")
+
+	return msg.Bytes()
+}
+
 // ParseError parses a PG error message
 func ParseError(e []byte) *Error {
 	msg := NewMessageBuffer(e)
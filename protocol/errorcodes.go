@@ -248,6 +248,7 @@ const (
 	ErrorCodeCrashShutdown        = "57P02" // crash_shutdown
 	ErrorCodeCannotConnectNow     = "57P03" // cannot_connect_now
 	ErrorCodeDatabaseDropped      = "57P04" // database_dropped
+	ErrorCodeIdleSessionTimeout   = "57P05" // idle_session_timeout
 	// Class 58 — System Error (errors external to PostgreSQL itself)
 	ErrorCodeSystemError   = "58000" // system_error
 	ErrorCodeIOError       = "58030" // io_error
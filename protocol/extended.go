@@ -0,0 +1,48 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package protocol
+
+/* The kinds of object that Describe and Close messages refer to. */
+const (
+	DescribeStatement byte = 'S'
+	DescribePortal    byte = 'P'
+)
+
+// CreateCloseMessage creates a Close message for the named prepared statement
+// or portal.
+func CreateCloseMessage(kind byte, name string) []byte {
+	message := NewMessageBuffer([]byte{})
+
+	message.WriteByte(CloseMessageType)
+	message.WriteInt32(0)
+	message.WriteByte(kind)
+	message.WriteString(name)
+
+	message.ResetLength(PGMessageLengthOffset)
+
+	return message.Bytes()
+}
+
+// CreateSyncMessage creates a Sync message.
+func CreateSyncMessage() []byte {
+	message := NewMessageBuffer([]byte{})
+
+	message.WriteByte(SyncMessageType)
+	message.WriteInt32(0)
+
+	message.ResetLength(PGMessageLengthOffset)
+
+	return message.Bytes()
+}
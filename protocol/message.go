@@ -18,6 +18,7 @@ package protocol
 import (
 	"bytes"
 	"encoding/binary"
+	"io"
 	"strings"
 )
 
@@ -85,7 +86,13 @@ func (message *MessageBuffer) ReadByte() (byte, error) {
 // buffer.
 //
 // This function will read and return the number of bytes as specified by count.
+// As count usually comes from the message itself, it is checked against the
+// bytes remaining before anything is allocated.
 func (message *MessageBuffer) ReadBytes(count int) ([]byte, error) {
+	if count < 0 || count > message.buffer.Len() {
+		return nil, io.ErrUnexpectedEOF
+	}
+
 	value := make([]byte, count)
 
 	if _, err := message.buffer.Read(value); err != nil {
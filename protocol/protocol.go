@@ -21,12 +21,17 @@ import (
 
 /* PostgreSQL Protocol Version/Code constants */
 const (
-	ProtocolVersion int32 = 196608
-	SSLRequestCode  int32 = 80877103
+	ProtocolVersion   int32 = 196608
+	SSLRequestCode    int32 = 80877103
+	CancelRequestCode int32 = 80877102
+	GSSENCRequestCode int32 = 80877104
 
 	/* SSL Responses */
 	SSLAllowed    byte = 'S'
 	SSLNotAllowed byte = 'N'
+
+	/* GSSAPI Encryption Responses */
+	GSSENCNotAllowed byte = 'N'
 )
 
 /* PostgreSQL Message Type constants. */
@@ -43,20 +48,74 @@ const (
 	NoticeMessageType          byte = 'N'
 	PasswordMessageType        byte = 'p'
 	ReadyForQueryMessageType   byte = 'Z'
+	BackendKeyDataMessageType  byte = 'K'
+	ParameterStatusMessageType byte = 'S'
+	NegotiateMessageType       byte = 'v'
+	NotificationMessageType    byte = 'A'
+)
+
+/* PostgreSQL Extended Query Message Type constants. */
+const (
+	ParseMessageType   byte = 'P'
+	BindMessageType    byte = 'B'
+	ExecuteMessageType byte = 'E'
+	SyncMessageType    byte = 'S'
+	FlushMessageType   byte = 'H'
+	CloseMessageType   byte = 'C'
+)
+
+/* PostgreSQL Extended Query Response Message Type constants. */
+const (
+	ParseCompleteMessageType        byte = '1'
+	BindCompleteMessageType         byte = '2'
+	CloseCompleteMessageType        byte = '3'
+	NoDataMessageType               byte = 'n'
+	PortalSuspendedMessageType      byte = 's'
+	ParameterDescriptionMessageType byte = 't'
+)
+
+/* PostgreSQL COPY Message Type constants. */
+const (
+	CopyInResponseMessageType   byte = 'G'
+	CopyOutResponseMessageType  byte = 'H'
+	CopyBothResponseMessageType byte = 'W'
+	CopyDataMessageType         byte = 'd'
+	CopyDoneMessageType         byte = 'c'
+	CopyFailMessageType         byte = 'f'
+)
+
+/* PostgreSQL ReadyForQuery transaction status constants. */
+const (
+	TransactionIdle   byte = 'I'
+	TransactionActive byte = 'T'
+	TransactionFailed byte = 'E'
 )
 
 /* PostgreSQL Authentication Method constants. */
 const (
-	AuthenticationOk          int32 = 0
-	AuthenticationKerberosV5  int32 = 2
-	AuthenticationClearText   int32 = 3
-	AuthenticationMD5         int32 = 5
-	AuthenticationSCM         int32 = 6
-	AuthenticationGSS         int32 = 7
-	AuthenticationGSSContinue int32 = 8
-	AuthenticationSSPI        int32 = 9
+	AuthenticationOk           int32 = 0
+	AuthenticationKerberosV5   int32 = 2
+	AuthenticationClearText    int32 = 3
+	AuthenticationMD5          int32 = 5
+	AuthenticationSCM          int32 = 6
+	AuthenticationGSS          int32 = 7
+	AuthenticationGSSContinue  int32 = 8
+	AuthenticationSSPI         int32 = 9
+	AuthenticationSASL         int32 = 10
+	AuthenticationSASLContinue int32 = 11
+	AuthenticationSASLFinal    int32 = 12
 )
 
+// MajorVersion returns the major number of a protocol version.
+func MajorVersion(version int32) int32 {
+	return version >> 16
+}
+
+// MinorVersion returns the minor number of a protocol version.
+func MinorVersion(version int32) int32 {
+	return version & 0xffff
+}
+
 func GetVersion(message []byte) int32 {
 	var code int32
 
@@ -115,6 +174,23 @@ func IsAuthenticationOk(message []byte) bool {
 	return (messageLength == 8 && messageValue == AuthenticationOk)
 }
 
+// GetAuthenticationType gets the authentication method requested by the
+// provided Authentication message.
+func GetAuthenticationType(message []byte) int32 {
+	var authType int32
+
+	reader := bytes.NewReader(message[5:9])
+	binary.Read(reader, binary.BigEndian, &authType)
+
+	return authType
+}
+
+// GetTransactionStatus gets the backend transaction status indicator from the
+// provided ReadyForQuery message.
+func GetTransactionStatus(message []byte) byte {
+	return message[5]
+}
+
 func GetTerminateMessage() []byte {
 	var buffer []byte
 	buffer = append(buffer, 'X')
@@ -0,0 +1,131 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package protocol
+
+/* PostgreSQL data type object IDs. */
+const (
+	Int8OID int32 = 20
+	TextOID int32 = 25
+)
+
+// Column describes a column of a result set sent by the proxy.
+type Column struct {
+	Name string
+	Type int32
+}
+
+// CreateRowDescriptionMessage creates a RowDescription message for the
+// provided columns. All columns are sent in text format.
+func CreateRowDescriptionMessage(columns []Column) []byte {
+	message := NewMessageBuffer([]byte{})
+
+	/* Set the message type */
+	message.WriteByte(RowDescriptionMessageType)
+
+	/* Initialize the message length to zero. */
+	message.WriteInt32(0)
+
+	message.WriteInt16(int16(len(columns)))
+
+	for _, column := range columns {
+		var size int16 = -1
+
+		if column.Type == Int8OID {
+			size = 8
+		}
+
+		message.WriteString(column.Name)
+		message.WriteInt32(0) // table OID
+		message.WriteInt16(0) // column attribute number
+		message.WriteInt32(column.Type)
+		message.WriteInt16(size)
+		message.WriteInt32(-1) // type modifier
+		message.WriteInt16(0)  // text format
+	}
+
+	/* Update the message length */
+	message.ResetLength(PGMessageLengthOffset)
+
+	return message.Bytes()
+}
+
+// CreateDataRowMessage creates a DataRow message containing the provided
+// values in text format. The message is built in a buffer of its exact size,
+// as result sets may have many rows.
+func CreateDataRowMessage(values []string) []byte {
+	size := 7 // the message type, length and number of values
+
+	for _, value := range values {
+		size += 4 + len(value)
+	}
+
+	message := NewMessageBuffer(make([]byte, 0, size))
+
+	/* Set the message type */
+	message.WriteByte(DataRowMessageType)
+
+	/* Initialize the message length to zero. */
+	message.WriteInt32(0)
+
+	message.WriteInt16(int16(len(values)))
+
+	for _, value := range values {
+		message.WriteInt32(int32(len(value)))
+		message.WriteBytes([]byte(value))
+	}
+
+	/* Update the message length */
+	message.ResetLength(PGMessageLengthOffset)
+
+	return message.Bytes()
+}
+
+// CreateCommandCompleteMessage creates a CommandComplete message with the
+// provided command tag.
+func CreateCommandCompleteMessage(tag string) []byte {
+	message := NewMessageBuffer([]byte{})
+
+	/* Set the message type */
+	message.WriteByte(CommandCompleteMessageType)
+
+	/* Initialize the message length to zero. */
+	message.WriteInt32(0)
+
+	message.WriteString(tag)
+
+	/* Update the message length */
+	message.ResetLength(PGMessageLengthOffset)
+
+	return message.Bytes()
+}
+
+// CreateReadyForQueryMessage creates a ReadyForQuery message with the
+// provided transaction status.
+func CreateReadyForQueryMessage(status byte) []byte {
+	message := NewMessageBuffer([]byte{})
+
+	/* Set the message type */
+	message.WriteByte(ReadyForQueryMessageType)
+
+	/* Initialize the message length to zero. */
+	message.WriteInt32(0)
+
+	message.WriteByte(status)
+
+	/* Update the message length */
+	message.ResetLength(PGMessageLengthOffset)
+
+	return message.Bytes()
+}
@@ -57,3 +57,49 @@ func CreateStartupMessage(username string, database string, options map[string]s
 
 	return message.Bytes()
 }
+
+// CreateParameterStatusMessage creates a PG ParameterStatus message, which
+// reports the current value of a run-time parameter to the client.
+func CreateParameterStatusMessage(name string, value string) []byte {
+	message := NewMessageBuffer([]byte{})
+
+	/* Set the message type */
+	message.WriteByte(ParameterStatusMessageType)
+
+	/* Initialize the message length to zero. */
+	message.WriteInt32(0)
+
+	message.WriteString(name)
+	message.WriteString(value)
+
+	/* Update the message length */
+	message.ResetLength(PGMessageLengthOffset)
+
+	return message.Bytes()
+}
+
+// CreateNegotiateProtocolVersionMessage creates a PG NegotiateProtocolVersion
+// message. It tells a client that asked for a newer minor protocol version, or
+// for protocol options, the newest minor version that is supported and the
+// options that were not recognized.
+func CreateNegotiateProtocolVersionMessage(minor int32, options []string) []byte {
+	message := NewMessageBuffer([]byte{})
+
+	/* Set the message type */
+	message.WriteByte(NegotiateMessageType)
+
+	/* Initialize the message length to zero. */
+	message.WriteInt32(0)
+
+	message.WriteInt32(minor)
+	message.WriteInt32(int32(len(options)))
+
+	for _, option := range options {
+		message.WriteString(option)
+	}
+
+	/* Update the message length */
+	message.ResetLength(PGMessageLengthOffset)
+
+	return message.Bytes()
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package protocol
+
+import (
+	"fmt"
+)
+
+/* Message length limits. */
+const (
+	// MinStartupMessageLength is the length of the smallest startup message,
+	// which holds only its length and the protocol version or request code.
+	MinStartupMessageLength = 8
+
+	// MaxStartupMessageLength is the length of the largest startup message,
+	// the same limit that PostgreSQL applies.
+	MaxStartupMessageLength = 10000
+
+	/* The length of a message includes the length field itself. */
+	minMessageLength = 4
+)
+
+// ViolationError reports a message that breaks the protocol, after which the
+// rest of the stream cannot be trusted. The connection it was read from is
+// terminated with a protocol_violation error.
+type ViolationError struct {
+	Message string
+}
+
+func (e *ViolationError) Error() string {
+	return e.Message
+}
+
+func violation(format string, args ...interface{}) error {
+	return &ViolationError{Message: fmt.Sprintf(format, args...)}
+}
+
+// ValidateStartupLength checks the length of a startup, SSL, GSSAPI
+// encryption or cancel request read from a client before it is authenticated.
+// Startup messages have no message type, so the length comes first.
+func ValidateStartupLength(length int32) error {
+	if length < MinStartupMessageLength || length > MaxStartupMessageLength {
+		return violation("invalid length of startup packet")
+	}
+
+	return nil
+}
+
+// ValidateMessageLength checks the length field of a message header. The
+// length includes the field itself, so it can be no less than 4, and the
+// message may be no larger than maxSize bytes.
+func ValidateMessageLength(length int32, maxSize int) error {
+	if length < minMessageLength {
+		return violation("invalid message length %d", length)
+	}
+
+	if int64(length) > int64(maxSize) {
+		return violation("message length %d exceeds the maximum of %d", length, maxSize)
+	}
+
+	return nil
+}
@@ -0,0 +1,256 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package proxy
+
+import (
+	"strings"
+	"unicode"
+)
+
+/*
+ * Keywords and functions that make a statement ineligible to be routed to a
+ * replica, either because they write or lock data or because their result
+ * depends on state that only exists on the master.
+ */
+var writeKeywords = map[string]bool{
+	"insert":       true,
+	"update":       true,
+	"delete":       true,
+	"merge":        true,
+	"into":         true,
+	"share":        true,
+	"create":       true,
+	"drop":         true,
+	"alter":        true,
+	"truncate":     true,
+	"grant":        true,
+	"revoke":       true,
+	"lock":         true,
+	"nextval":      true,
+	"setval":       true,
+	"currval":      true,
+	"lastval":      true,
+	"set_config":   true,
+	"txid_current": true,
+	"pg_notify":    true,
+}
+
+/*
+ * Prefixes of functions that make a statement ineligible to be routed to a
+ * replica. These cover every advisory lock function, including the shared,
+ * transaction-level and unlock variants.
+ */
+var writePrefixes = []string{
+	"pg_advisory",
+	"pg_try_advisory",
+}
+
+// isWriteKeyword determines whether a keyword or function name makes a
+// statement ineligible to be routed to a replica.
+func isWriteKeyword(token string) bool {
+	if writeKeywords[token] {
+		return true
+	}
+
+	for _, prefix := range writePrefixes {
+		if strings.HasPrefix(token, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isReadOnlyQuery determines whether a simple query can safely be routed to a
+// replica. Only a single SELECT statement, optionally with a WITH clause, that
+// does not write or lock any data is considered read-only. Anything that
+// cannot be classified with certainty is treated as a write.
+func isReadOnlyQuery(query string) bool {
+	tokens, ok := getKeywords(query)
+
+	if !ok || len(tokens) == 0 {
+		return false
+	}
+
+	if tokens[0] != "select" && tokens[0] != "with" {
+		return false
+	}
+
+	for _, token := range tokens {
+		if isWriteKeyword(token) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// getKeywords splits a query into its lower case words, skipping over
+// comments, string literals and quoted identifiers. It returns false if the
+// query contains more than one statement or cannot be tokenized.
+func getKeywords(query string) ([]string, bool) {
+	statements, ok := splitStatements(query)
+
+	if !ok || len(statements) > 1 {
+		return nil, false
+	}
+
+	if len(statements) == 0 {
+		return nil, true
+	}
+
+	return statements[0].keywords, true
+}
+
+// statement is a single statement of a query along with its lower case words.
+type statement struct {
+	text     string
+	keywords []string
+}
+
+// splitStatements splits a query into its statements, ignoring any that do
+// not contain a word. It returns false if the query cannot be tokenized.
+func splitStatements(query string) ([]statement, bool) {
+	var statements []statement
+	var tokens []string
+	var start int // the start of the current statement
+
+	runes := []rune(query)
+
+	/* Complete the statement that ends at the given position. */
+	end := func(i int) {
+		if len(tokens) > 0 {
+			statements = append(statements, statement{
+				text:     strings.TrimSpace(string(runes[start:i])),
+				keywords: tokens,
+			})
+		}
+
+		tokens = nil
+		start = i + 1
+	}
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '-' && i+1 < len(runes) && runes[i+1] == '-':
+			/* Line comment. */
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+		case r == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			/* Block comment, which may be nested. */
+			depth := 0
+
+			for i < len(runes) {
+				if runes[i] == '/' && i+1 < len(runes) && runes[i+1] == '*' {
+					depth++
+					i += 2
+				} else if runes[i] == '*' && i+1 < len(runes) && runes[i+1] == '/' {
+					depth--
+					i += 2
+
+					if depth == 0 {
+						break
+					}
+				} else {
+					i++
+				}
+			}
+
+			if depth != 0 {
+				return nil, false
+			}
+		case r == '\'' || r == '"':
+			/*
+			 * String literal or quoted identifier. Backslash escapes are only
+			 * recognized in escape strings, e.g. E'...'.
+			 */
+			escapes := r == '\'' && i > 0 && (runes[i-1] == 'e' || runes[i-1] == 'E')
+			closed := false
+
+			for i++; i < len(runes); i++ {
+				if escapes && runes[i] == '\\' {
+					i++
+				} else if runes[i] == r {
+					if i+1 < len(runes) && runes[i+1] == r {
+						i++
+					} else {
+						closed = true
+						i++
+						break
+					}
+				}
+			}
+
+			if !closed {
+				return nil, false
+			}
+		case r == '$' && i+1 < len(runes) && unicode.IsDigit(runes[i+1]):
+			/* Positional parameter, e.g. $1, as a tag cannot start with a digit. */
+			for i++; i < len(runes) && unicode.IsDigit(runes[i]); i++ {
+			}
+		case r == '$':
+			/* Dollar quoted string, e.g. $$...$$ or $tag$...$tag$. */
+			tagEnd := indexRunes(runes, i+1, []rune{'$'})
+
+			if tagEnd < 0 {
+				return nil, false
+			}
+
+			tag := runes[i : tagEnd+1]
+			end := indexRunes(runes, tagEnd+1, tag)
+
+			if end < 0 {
+				return nil, false
+			}
+
+			i = end + len(tag)
+		case r == ';':
+			end(i)
+			i++
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+
+			for i < len(runes) && (unicode.IsLetter(runes[i]) ||
+				unicode.IsDigit(runes[i]) || runes[i] == '_' || runes[i] == '$') {
+				i++
+			}
+
+			tokens = append(tokens, strings.ToLower(string(runes[start:i])))
+		default:
+			i++
+		}
+	}
+
+	end(len(runes))
+
+	return statements, true
+}
+
+// indexRunes returns the index of the first instance of sub in runes at or
+// after from, or -1 if it is not present.
+func indexRunes(runes []rune, from int, sub []rune) int {
+	for i := from; i+len(sub) <= len(runes); i++ {
+		if string(runes[i:i+len(sub)]) == string(sub) {
+			return i
+		}
+	}
+
+	return -1
+}
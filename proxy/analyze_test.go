@@ -0,0 +1,91 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIsReadOnlyQuery(t *testing.T) {
+	tests := []struct {
+		query    string
+		readOnly bool
+	}{
+		{query: "select 1", readOnly: true},
+		{query: "  SELECT * FROM orders WHERE id = 1;", readOnly: true},
+		{query: "with recent as (select * from orders) select * from recent", readOnly: true},
+		{query: "select * from orders where id = $1", readOnly: true},
+		{query: "select 'insert into t values (1)'", readOnly: true},
+		{query: `select "update" from t`, readOnly: true},
+		{query: "select $$delete$$, $tag$drop$tag$", readOnly: true},
+		{query: "-- insert\nselect 1", readOnly: true},
+		{query: "/* update /* nested */ */ select 1", readOnly: true},
+		{query: ""},
+		{query: "insert into orders values (1)"},
+		{query: "with deleted as (delete from orders returning *) select * from deleted"},
+		{query: "select * into archive from orders"},
+		{query: "select * from orders for update"},
+		{query: "select * from orders for share"},
+		{query: "select nextval('orders_id_seq')"},
+		{query: "select pg_advisory_lock(1)"},
+		{query: "select pg_try_advisory_xact_lock(1)"},
+		{query: "select set_config('work_mem', '64MB', false)"},
+		{query: "select 1; select 2"},
+		{query: "select 'unterminated"},
+		{query: "select /* unterminated"},
+		{query: "show work_mem"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			if readOnly := isReadOnlyQuery(test.query); readOnly != test.readOnly {
+				t.Fatalf("expected read-only to be %v", test.readOnly)
+			}
+		})
+	}
+}
+
+func TestIsWriteKeyword(t *testing.T) {
+	for _, keyword := range []string{"insert", "update", "lock", "pg_advisory_unlock", "pg_try_advisory_lock_shared"} {
+		if !isWriteKeyword(keyword) {
+			t.Fatalf("expected '%s' to be a write keyword", keyword)
+		}
+	}
+
+	for _, keyword := range []string{"select", "from", "pg_sleep", "updated_at"} {
+		if isWriteKeyword(keyword) {
+			t.Fatalf("expected '%s' not to be a write keyword", keyword)
+		}
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	statements, ok := splitStatements("set a = 1; ; select ';' -- ;\n; select $1::text")
+
+	if !ok {
+		t.Fatal("expected the query to be split")
+	}
+
+	expected := []statement{
+		{text: "set a = 1", keywords: []string{"set", "a"}},
+		{text: "select ';' -- ;", keywords: []string{"select"}},
+		{text: "select $1::text", keywords: []string{"select", "text"}},
+	}
+
+	if !reflect.DeepEqual(statements, expected) {
+		t.Fatalf("expected %v, got %v", expected, statements)
+	}
+}
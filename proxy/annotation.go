@@ -18,13 +18,19 @@ const (
 	ReadAnnotation AnnotationType = iota
 	StartAnnotation
 	EndAnnotation
+	CacheAnnotation
+	PrimaryAnnotation
+	ShardKeyAnnotation
 )
 
 const (
-	readAnnotationString    string = "read"
-	startAnnotationString   string = "start"
-	endAnnotationString     string = "end"
-	unknownAnnotationString string = ""
+	readAnnotationString     string = "read"
+	startAnnotationString    string = "start"
+	endAnnotationString      string = "end"
+	cacheAnnotationString    string = "cache"
+	primaryAnnotationString  string = "primary"
+	shardKeyAnnotationString string = "shard_key"
+	unknownAnnotationString  string = ""
 )
 
 const (
@@ -42,6 +48,12 @@ func (a AnnotationType) String() string {
 		return startAnnotationString
 	case EndAnnotation:
 		return endAnnotationString
+	case CacheAnnotation:
+		return cacheAnnotationString
+	case PrimaryAnnotation:
+		return primaryAnnotationString
+	case ShardKeyAnnotation:
+		return shardKeyAnnotationString
 	}
 
 	return unknownAnnotationString
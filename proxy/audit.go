@@ -0,0 +1,64 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package proxy
+
+import (
+	"net"
+	"time"
+
+	"github.com/crunchydata/crunchy-proxy/audit"
+	"github.com/crunchydata/crunchy-proxy/protocol"
+)
+
+// auditMessages logs the Query, Parse and Bind messages in a batch from a
+// client to the audit log, along with the node that the batch is routed to.
+func auditMessages(client net.Conn, part partition, nodeName string, batch []byte) {
+	if !audit.Enabled() {
+		return
+	}
+
+	now := time.Now()
+	address := client.RemoteAddr().String()
+
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		address = host
+	}
+
+	for _, m := range getMessages(batch) {
+		entry := audit.Entry{
+			Time:     now,
+			Client:   address,
+			Database: part.database,
+			User:     part.username,
+			Backend:  nodeName,
+		}
+
+		switch protocol.GetMessageType(m) {
+		case protocol.QueryMessageType:
+			entry.Type = audit.TypeQuery
+			entry.Query = getQuery(m)
+		case protocol.ParseMessageType:
+			entry.Type = audit.TypeParse
+			entry.Statement, entry.Query = getParse(m)
+		case protocol.BindMessageType:
+			entry.Type = audit.TypeBind
+			entry.Statement, entry.Parameters = getBind(m)
+		default:
+			continue
+		}
+
+		audit.Log(entry)
+	}
+}
@@ -0,0 +1,173 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package proxy
+
+import (
+	"hash/fnv"
+	"time"
+
+	"github.com/crunchydata/crunchy-proxy/common"
+	"github.com/crunchydata/crunchy-proxy/pool"
+)
+
+// Backend describes a node that a balancer may select for a read query.
+type Backend struct {
+	Name    string
+	Pool    *pool.Pool
+	Weight  int
+	Latency time.Duration
+}
+
+// Balancer selects the node that the next read query is routed to.
+//
+// Next is always called with the proxy's pool lock held, so implementations
+// do not need to provide their own locking.
+type Balancer interface {
+	Next(backends []Backend) string
+}
+
+// NewBalancer creates the balancer for the named strategy. Round-robin is used
+// if the strategy is not recognized.
+func NewBalancer(strategy string) Balancer {
+	switch strategy {
+	case common.BALANCER_LEAST_CONNECTIONS:
+		return &leastConnectionsBalancer{}
+	case common.BALANCER_WEIGHTED:
+		return &weightedBalancer{current: make(map[string]int)}
+	case common.BALANCER_LATENCY:
+		return &latencyBalancer{}
+	}
+
+	return &roundRobinBalancer{}
+}
+
+/* Select each backend in turn. */
+type roundRobinBalancer struct {
+	next int
+}
+
+func (b *roundRobinBalancer) Next(backends []Backend) string {
+	backend := backends[b.next%len(backends)]
+	b.next++
+	return backend.Name
+}
+
+/*
+ * Select the backend with the fewest connections in use. Ties are broken
+ * round-robin so that idle backends share the load.
+ */
+type leastConnectionsBalancer struct {
+	next int
+}
+
+func (b *leastConnectionsBalancer) Next(backends []Backend) string {
+	selected := -1
+	least := 0
+
+	for i := range backends {
+		index := (b.next + i) % len(backends)
+		p := backends[index].Pool
+		inUse := p.Capacity - p.Len()
+
+		if selected < 0 || inUse < least {
+			selected = index
+			least = inUse
+		}
+	}
+
+	b.next++
+
+	return backends[selected].Name
+}
+
+/*
+ * Select backends in proportion to their configured weight, using the smooth
+ * weighted round-robin algorithm so that selections are interleaved. Backends
+ * without a weight have a weight of 1.
+ */
+type weightedBalancer struct {
+	current map[string]int
+}
+
+func (b *weightedBalancer) Next(backends []Backend) string {
+	var selected string
+	total := 0
+
+	for _, backend := range backends {
+		weight := backend.Weight
+
+		if weight <= 0 {
+			weight = 1
+		}
+
+		total += weight
+		b.current[backend.Name] += weight
+
+		if selected == "" || b.current[backend.Name] > b.current[selected] {
+			selected = backend.Name
+		}
+	}
+
+	b.current[selected] -= total
+
+	return selected
+}
+
+/*
+ * Select the backend with the lowest latency measured by the health check.
+ * Backends with the same latency are selected round-robin.
+ */
+type latencyBalancer struct {
+	next int
+}
+
+func (b *latencyBalancer) Next(backends []Backend) string {
+	selected := -1
+
+	for i := range backends {
+		index := (b.next + i) % len(backends)
+
+		if selected < 0 || backends[index].Latency < backends[selected].Latency {
+			selected = index
+		}
+	}
+
+	b.next++
+
+	return backends[selected].Name
+}
+
+// affinityBackend selects the backend for an affinity value, so that all of
+// the clients that share the value are routed to the same backend. It uses
+// rendezvous hashing, so that when a backend becomes unavailable only the
+// values that were routed to it move, and they move back once it returns.
+func affinityBackend(backends []Backend, value string) string {
+	var selected string
+	var highest uint64
+
+	for _, backend := range backends {
+		hash := fnv.New64a()
+		hash.Write([]byte(backend.Name))
+		hash.Write([]byte{0})
+		hash.Write([]byte(value))
+
+		if score := hash.Sum64(); selected == "" || score > highest {
+			selected = backend.Name
+			highest = score
+		}
+	}
+
+	return selected
+}
@@ -0,0 +1,225 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+import (
+	"strings"
+	"time"
+
+	"github.com/crunchydata/crunchy-proxy/config"
+	"github.com/crunchydata/crunchy-proxy/protocol"
+)
+
+/* The backend recorded in the audit log for queries answered by the cache. */
+const cacheNodeName = "cache"
+
+// cacheKey identifies the results of a query. Queries share results if they
+// have the same normalized text and parameters, and are run by the same user
+// on the same database with the same session parameters, which include the
+// role, the search path and any settings that row security policies read.
+type cacheKey struct {
+	part       partition
+	settings   string // the statements that restore the session parameters
+	query      string
+	parameters string
+}
+
+// cacheEntry holds the response to a query until it expires. The response
+// is kept in the chunks it was read from the backend in, which are written
+// back to clients together.
+type cacheEntry struct {
+	response [][]byte
+	size     int // bytes
+	expires  time.Time
+}
+
+// CacheStats holds the statistics of the query result cache, as reported by
+// the admin server and console.
+type CacheStats struct {
+	Entries     int
+	Size        int64 // bytes
+	Hits        int64
+	Misses      int64
+	Evictions   int64
+	Expirations int64
+}
+
+// getCacheKey determines the key of the results of a batch of messages from
+// a client. Only batches that run a single query and wait for its results
+// can be answered from the cache, i.e. a simple Query, or a Parse of the
+// unnamed statement followed by the Bind, Execute and Sync messages to run
+// it. The parameters of a simple query are the literal values in its text,
+// while those of an extended query are the messages that follow the Parse,
+// which hold the parameter values and the formats of the results.
+func getCacheKey(part partition, settings string, messages [][]byte) (cacheKey, bool) {
+	var query string
+	var queries int
+	var executed, sync bool
+	var parameters []string
+
+	for _, m := range messages {
+		switch protocol.GetMessageType(m) {
+		case protocol.QueryMessageType:
+			query = getQuery(m)
+			queries++
+			executed = true
+			sync = true
+		case protocol.ParseMessageType:
+			name, q := getParse(m)
+
+			/* A named statement could be used later without the Parse. */
+			if name != "" {
+				return cacheKey{}, false
+			}
+
+			query = q
+			queries++
+		case protocol.ExecuteMessageType:
+			executed = true
+			parameters = append(parameters, string(m))
+		case protocol.SyncMessageType:
+			sync = true
+			parameters = append(parameters, string(m))
+		case protocol.BindMessageType, protocol.DescribeMessageType:
+			parameters = append(parameters, string(m))
+		default:
+			return cacheKey{}, false
+		}
+	}
+
+	if queries != 1 || !executed || !sync {
+		return cacheKey{}, false
+	}
+
+	normalized, literals := splitQuery(query)
+
+	return cacheKey{
+		part:       part,
+		settings:   settings,
+		query:      normalized,
+		parameters: strings.Join(append(literals, parameters...), "\x00"),
+	}, true
+}
+
+// getCachedResult returns the response to a query from the cache, if it is
+// enabled and holds a response that has not expired.
+func (p *Proxy) getCachedResult(key cacheKey) ([][]byte, bool) {
+	if !config.GetCacheConfig().Enable {
+		return nil, false
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	entry, ok := p.results[key]
+
+	if ok && time.Now().After(entry.expires) {
+		p.removeResult(key)
+		p.cacheStats.Expirations++
+		ok = false
+	}
+
+	if !ok {
+		p.cacheStats.Misses++
+		return nil, false
+	}
+
+	p.cacheStats.Hits++
+
+	return entry.response, true
+}
+
+// cacheResult stores the response to a query in the cache until the TTL has
+// passed. Responses larger than the configured maximum are not cached. Once
+// the configured number of results are cached, expired results are discarded
+// and then those closest to expiring, to make room for the new one.
+func (p *Proxy) cacheResult(key cacheKey, response [][]byte, ttl time.Duration) {
+	cacheConfig := config.GetCacheConfig()
+
+	var size int
+
+	for _, chunk := range response {
+		size += len(chunk)
+	}
+
+	if !cacheConfig.Enable || ttl <= 0 || size > cacheConfig.MaxResultSize {
+		return
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	now := time.Now()
+
+	if _, ok := p.results[key]; ok {
+		p.removeResult(key)
+	} else if len(p.results) >= cacheConfig.MaxEntries {
+		p.expireResults(now)
+	}
+
+	for len(p.results) >= cacheConfig.MaxEntries {
+		p.evictResult()
+	}
+
+	p.results[key] = &cacheEntry{response: response, size: size, expires: now.Add(ttl)}
+	p.cacheStats.Size += int64(size)
+}
+
+// expireResults discards the cached results that have expired. The caller
+// must hold the proxy lock.
+func (p *Proxy) expireResults(now time.Time) {
+	for key, entry := range p.results {
+		if now.After(entry.expires) {
+			p.removeResult(key)
+			p.cacheStats.Expirations++
+		}
+	}
+}
+
+// evictResult discards the cached result that is closest to expiring. The
+// caller must hold the proxy lock.
+func (p *Proxy) evictResult() {
+	var victim cacheKey
+	var earliest time.Time
+
+	for key, entry := range p.results {
+		if earliest.IsZero() || entry.expires.Before(earliest) {
+			victim = key
+			earliest = entry.expires
+		}
+	}
+
+	p.removeResult(victim)
+	p.cacheStats.Evictions++
+}
+
+/* removeResult discards a cached result. The caller must hold the proxy lock. */
+func (p *Proxy) removeResult(key cacheKey) {
+	if entry, ok := p.results[key]; ok {
+		p.cacheStats.Size -= int64(entry.size)
+		delete(p.results, key)
+	}
+}
+
+// CacheStats returns the statistics of the query result cache.
+func (p *Proxy) CacheStats() CacheStats {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	stats := p.cacheStats
+	stats.Entries = len(p.results)
+
+	return stats
+}
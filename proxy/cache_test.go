@@ -0,0 +1,175 @@
+/*
+Copyright 2016 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+import (
+	"testing"
+
+	"github.com/crunchydata/crunchy-proxy/protocol"
+)
+
+func TestGetCacheKeyCacheable(t *testing.T) {
+	syncMessage := protocol.CreateSyncMessage()
+
+	tests := []struct {
+		name      string
+		messages  [][]byte
+		cacheable bool
+	}{
+		{
+			name:      "simple query",
+			messages:  [][]byte{protocol.CreateQueryMessage("select * from t where id = 1")},
+			cacheable: true,
+		},
+		{
+			name:      "unnamed statement",
+			messages:  [][]byte{newParse("", "select $1"), newBind("", ""), newExecute(""), syncMessage},
+			cacheable: true,
+		},
+		{
+			name:      "described unnamed statement",
+			messages:  [][]byte{newParse("", "select $1"), newBind("", ""), newDescribe(protocol.DescribePortal, ""), newExecute(""), syncMessage},
+			cacheable: true,
+		},
+		{
+			name:     "named statement",
+			messages: [][]byte{newParse("s1", "select 1"), newBind("", "s1"), newExecute(""), syncMessage},
+		},
+		{
+			name:     "not executed",
+			messages: [][]byte{newParse("", "select 1"), syncMessage},
+		},
+		{
+			name:     "without sync",
+			messages: [][]byte{newParse("", "select 1"), newBind("", ""), newExecute("")},
+		},
+		{
+			name:     "several queries",
+			messages: [][]byte{protocol.CreateQueryMessage("select 1"), protocol.CreateQueryMessage("select 2")},
+		},
+		{
+			name:     "other message",
+			messages: [][]byte{protocol.CreateQueryMessage("select 1"), protocol.CreateCloseMessage(protocol.DescribePortal, "")},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, cacheable := getCacheKey(partition{}, "", test.messages); cacheable != test.cacheable {
+				t.Fatalf("expected cacheable to be %v", test.cacheable)
+			}
+		})
+	}
+}
+
+func TestGetCacheKeyEquality(t *testing.T) {
+	part := partition{database: "db", username: "user"}
+	query := [][]byte{protocol.CreateQueryMessage("select * from t where id = 1")}
+
+	key, _ := getCacheKey(part, "SET search_path TO 'a'", query)
+
+	tests := []struct {
+		name     string
+		part     partition
+		settings string
+		messages [][]byte
+		same     bool
+	}{
+		{
+			name:     "same query",
+			part:     part,
+			settings: "SET search_path TO 'a'",
+			messages: query,
+			same:     true,
+		},
+		{
+			name:     "different spacing",
+			part:     part,
+			settings: "SET search_path TO 'a'",
+			messages: [][]byte{protocol.CreateQueryMessage("select *  from t\nwhere id = 1")},
+			same:     true,
+		},
+		{
+			name:     "different literal",
+			part:     part,
+			settings: "SET search_path TO 'a'",
+			messages: [][]byte{protocol.CreateQueryMessage("select * from t where id = 2")},
+		},
+		{
+			name:     "different session parameters",
+			part:     part,
+			settings: "SET search_path TO 'b'",
+			messages: query,
+		},
+		{
+			name:     "different role",
+			part:     part,
+			settings: "SET search_path TO 'a'; SET ROLE other",
+			messages: query,
+		},
+		{
+			name:     "different user",
+			part:     partition{database: "db", username: "other"},
+			settings: "SET search_path TO 'a'",
+			messages: query,
+		},
+		{
+			name:     "different database",
+			part:     partition{database: "other", username: "user"},
+			settings: "SET search_path TO 'a'",
+			messages: query,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			other, ok := getCacheKey(test.part, test.settings, test.messages)
+
+			if !ok {
+				t.Fatal("expected the query to be cacheable")
+			}
+
+			if same := other == key; same != test.same {
+				t.Fatalf("expected the keys to be equal: %v", test.same)
+			}
+		})
+	}
+}
+
+func TestGetCacheKeyParameters(t *testing.T) {
+	syncMessage := protocol.CreateSyncMessage()
+	parse := newParse("", "select * from t where id = $1")
+
+	/* A Bind with a single text parameter. */
+	bind := func(value string) []byte {
+		m := newMessage(protocol.BindMessageType, "", "")
+		m = append(m, 0, 0, 0, 1, 0, 0, 0, byte(len(value)))
+		m = append(m, value...)
+
+		return appendLength(m, 0, 0)
+	}
+
+	first, _ := getCacheKey(partition{}, "", [][]byte{parse, bind("1"), newExecute(""), syncMessage})
+	same, _ := getCacheKey(partition{}, "", [][]byte{parse, bind("1"), newExecute(""), syncMessage})
+	other, _ := getCacheKey(partition{}, "", [][]byte{parse, bind("2"), newExecute(""), syncMessage})
+
+	if first != same {
+		t.Fatal("expected the same parameters to give the same key")
+	}
+
+	if first == other {
+		t.Fatal("expected different parameters to give different keys")
+	}
+}
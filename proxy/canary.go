@@ -0,0 +1,68 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package proxy
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"time"
+
+	"github.com/crunchydata/crunchy-proxy/config"
+	"github.com/crunchydata/crunchy-proxy/metrics"
+)
+
+// inCanary returns whether a read query is sent to the canary replica, for
+// the given percentage of read queries. Queries with an affinity value are
+// assigned by the value, so that the clients sharing it stay together on the
+// canary or away from it.
+func inCanary(percent float64, affinity string) bool {
+	if percent <= 0 {
+		return false
+	}
+
+	if affinity == "" {
+		return rand.Float64()*100 < percent
+	}
+
+	hash := fnv.New64a()
+	hash.Write([]byte(affinity))
+
+	return float64(hash.Sum64()%10000) < percent*100
+}
+
+// recordCohort counts a read query, and how long it took, for the canary
+// cohort if it ran on the canary replica and for the baseline otherwise, when
+// a canary is designated.
+func recordCohort(node string, failed bool, duration time.Duration) {
+	canary := config.GetCanary()
+
+	if canary.Node == "" {
+		return
+	}
+
+	cohort := metrics.CohortBaseline
+
+	if node == canary.Node {
+		cohort = metrics.CohortCanary
+	}
+
+	result := metrics.ResultSuccess
+
+	if failed {
+		result = metrics.ResultError
+	}
+
+	metrics.CohortQueries.WithLabelValues(cohort, result).Inc()
+	metrics.CohortQueryDuration.WithLabelValues(cohort).Observe(duration.Seconds())
+}
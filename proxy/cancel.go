@@ -0,0 +1,158 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package proxy
+
+import (
+	"net"
+	"sync/atomic"
+
+	"github.com/crunchydata/crunchy-proxy/connect"
+	"github.com/crunchydata/crunchy-proxy/protocol"
+	"github.com/crunchydata/crunchy-proxy/util/log"
+)
+
+// backendConn is a pool connection along with the key data needed to cancel
+// queries running on it, the session parameters that were last applied to it
+// and the prepared statements that it has.
+type backendConn struct {
+	net.Conn
+	hostPort   string
+	processID  int32
+	secretKey  int32
+	parameters string
+	status     map[string]string // the parameters reported by the backend
+	prepared   map[string]bool
+	listening  bool // whether a client ran LISTEN on the connection
+
+	/*
+	 * The process ID of the session that was last given the connection, by
+	 * which clients know its backend process. Accessed atomically.
+	 */
+	sessionID int32
+}
+
+func newBackendConn(connection net.Conn, hostPort string, response []byte) net.Conn {
+	processID, secretKey, ok := protocol.GetBackendKeyData(response)
+
+	if !ok {
+		log.Debugf("No key data received from backend %s", hostPort)
+	}
+
+	return &backendConn{
+		Conn:      connection,
+		hostPort:  hostPort,
+		processID: processID,
+		secretKey: secretKey,
+		status:    getParameterStatus(response),
+		prepared:  make(map[string]bool),
+	}
+}
+
+// cancel forwards a cancel request from a client to the backend that is
+// currently running a query for the session identified by the request. If
+// the session holds no backend, then there is nothing to cancel.
+func (p *Proxy) cancel(message []byte) {
+	processID, secretKey := protocol.GetCancelRequest(message)
+
+	p.lock.Lock()
+	s, ok := p.sessions[processID]
+	p.lock.Unlock()
+
+	if !ok || s.secretKey != secretKey {
+		log.Infof("Ignoring cancel request for unknown session %d", processID)
+		return
+	}
+
+	backend, ok := s.getBackend().(*backendConn)
+
+	if !ok {
+		log.Debugf("Session %d has no running query to cancel", processID)
+		return
+	}
+
+	log.Infof("Forwarding cancel request for session %d to %s", processID, backend.hostPort)
+
+	cancelBackend(s, backend)
+}
+
+// cancelBackend cancels the query that is running on the backend of a
+// session, if any. The request is only sent if the session still holds the
+// backend once the connection for it is made, so that a query of another
+// client that has since been given the backend is not canceled.
+func cancelBackend(s *session, backend *backendConn) {
+	connection, err := connect.Connect(backend.hostPort)
+
+	if err != nil {
+		log.Errorf("Error connecting to %s to cancel query", backend.hostPort)
+		log.Errorf("Error: %s", err.Error())
+		return
+	}
+
+	defer connection.Close()
+
+	if s.getBackend() != net.Conn(backend) {
+		log.Debugf("Session %d released backend %s before it could be canceled",
+			s.processID, backend.hostPort)
+		return
+	}
+
+	request := protocol.CreateCancelRequestMessage(backend.processID, backend.secretKey)
+
+	if _, err = connect.Send(connection, request); err != nil {
+		log.Errorf("Error sending cancel request to %s", backend.hostPort)
+		log.Errorf("Error: %s", err.Error())
+	}
+}
+
+// clientProcessID returns the process ID that clients know a backend process
+// by, given the pool connection that a message naming the process arrived on.
+// The process of a pool connection is known by the process ID of the session
+// that was last given the connection, while the IDs of other processes, which
+// are not connected through the proxy, are returned unchanged.
+func (p *Proxy) clientProcessID(via net.Conn, processID int32) int32 {
+	conn, ok := via.(*backendConn)
+
+	if !ok {
+		return processID
+	}
+
+	/* The client most often hears from its own backend. */
+	if conn.processID == processID {
+		if id := atomic.LoadInt32(&conn.sessionID); id != 0 {
+			return id
+		}
+
+		return processID
+	}
+
+	p.poolLock.Lock()
+	defer p.poolLock.Unlock()
+
+	for _, cp := range p.pools {
+		for _, server := range cp.Servers() {
+			other, ok := server.Connection.(*backendConn)
+
+			if !ok || other.hostPort != conn.hostPort || other.processID != processID {
+				continue
+			}
+
+			if id := atomic.LoadInt32(&other.sessionID); id != 0 {
+				return id
+			}
+		}
+	}
+
+	return processID
+}
@@ -0,0 +1,273 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/crunchydata/crunchy-proxy/common"
+	"github.com/crunchydata/crunchy-proxy/config"
+	"github.com/crunchydata/crunchy-proxy/connect"
+	"github.com/crunchydata/crunchy-proxy/util/log"
+)
+
+/* Reads the master's current WAL position as a number of bytes. */
+const currentLSNQuery = "SELECT pg_wal_lsn_diff(pg_current_wal_lsn(), '0/0')::bigint"
+
+/* Reads the server_version_num of a node, such as 90624 or 120004. */
+const versionQuery = "SHOW server_version_num"
+
+/*
+ * PostgreSQL 10 renamed the functions that report WAL positions. This maps
+ * each of the current names to the name used by earlier versions.
+ */
+var walFunctionsBefore10 = strings.NewReplacer(
+	"pg_current_wal_lsn", "pg_current_xlog_location",
+	"pg_last_wal_receive_lsn", "pg_last_xlog_receive_location",
+	"pg_last_wal_replay_lsn", "pg_last_xlog_replay_location",
+	"pg_wal_lsn_diff", "pg_xlog_location_diff",
+)
+
+// WALQuery returns a query that uses the WAL functions of PostgreSQL 10 and
+// later, with the functions renamed to match the node's server_version_num.
+func WALQuery(query string, versionNum string) (string, error) {
+	version, err := strconv.Atoi(versionNum)
+
+	if err != nil {
+		return "", fmt.Errorf("invalid server_version_num '%s'", versionNum)
+	}
+
+	if version < 100000 {
+		return walFunctionsBefore10.Replace(query), nil
+	}
+
+	return query, nil
+}
+
+// WALPosition is how far a node has got in the WAL: the position written on the
+// master, or the position replayed on a replica.
+type WALPosition struct {
+	LSN      int64     // bytes since the start of the WAL
+	Measured time.Time // when the measurement was started
+}
+
+// writeMark identifies a write of a client on the master, which the client's
+// later reads must see.
+type writeMark struct {
+	written time.Time // when the query finished
+	lsn     int64     // the master's WAL position afterwards, zero if unknown
+}
+
+// lsnConn is a connection to a master that is used to read its current WAL
+// position after the writes of clients. Only one reading is run at a time,
+// and the clients whose writes finish while it runs share the next one.
+type lsnConn struct {
+	conn  net.Conn
+	query string     // currentLSNQuery as spelled for the master's version
+	lock  sync.Mutex // held while the connection is used
+
+	rounds  sync.Mutex // protects next and running
+	next    *lsnRound  // the reading that has yet to start, if any
+	running *lsnRound  // the reading that started last
+}
+
+// lsnRound is a reading of the master's WAL position. It is shared by the
+// clients that asked for it before it started, as it is at or past the
+// position of any write that had finished by then.
+type lsnRound struct {
+	done chan struct{}
+	lsn  int64
+	err  error
+}
+
+/* Close the connection, if it is open. */
+func (lc *lsnConn) close() {
+	lc.lock.Lock()
+	defer lc.lock.Unlock()
+
+	if lc.conn != nil {
+		lc.conn.Close()
+		lc.conn = nil
+	}
+}
+
+/* Whether the writes of clients are tracked so that their reads see them. */
+func tracksWrites() bool {
+	return config.GetStickyReads() > 0 || config.GetConsistency() == common.CONSISTENCY_LSN
+}
+
+// SetPosition records the WAL position of a node as measured by the health
+// check. A nil position means that it could not be measured.
+func (p *Proxy) SetPosition(name string, position *WALPosition) {
+	p.poolLock.Lock()
+	defer p.poolLock.Unlock()
+
+	p.positions[name] = position
+}
+
+/*
+ * Determine whether a replica is known to have replayed a client's write. If
+ * the master's WAL position after the write is known, then the replica must have
+ * replayed the WAL up to it. Otherwise the replica must have replayed the WAL up
+ * to a position that the master had reached after the write finished.
+ */
+func (p *Proxy) hasReplayed(name string, master string, mark *writeMark) bool {
+	replayed := p.positions[name]
+
+	if replayed == nil {
+		return false
+	}
+
+	if mark.lsn > 0 {
+		return replayed.LSN >= mark.lsn
+	}
+
+	written := p.positions[master]
+
+	return written != nil && written.Measured.After(mark.written) &&
+		replayed.LSN >= written.LSN
+}
+
+// recordWrite remembers a query of the client that may have written on the
+// master of the cluster. If reads are routed by WAL position and the write is
+// committed, then the master's current position is read as well.
+func (p *Proxy) recordWrite(s *session, cluster string, committed bool) {
+	mark := &writeMark{written: time.Now()}
+
+	if committed && config.GetConsistency() == common.CONSISTENCY_LSN {
+		lsn, err := p.currentLSN(cluster)
+
+		if err != nil {
+			log.Errorf("Could not read the WAL position of the master of cluster '%s'", cluster)
+			log.Errorf("Error: %s", err.Error())
+		}
+
+		mark.lsn = lsn
+	}
+
+	s.lastWrite = mark
+}
+
+// currentLSN reads the current WAL position of the master of the cluster, which
+// is at or past that of any write that has finished on it. A connection with
+// the cluster's credentials is kept open to each master for this, and is
+// reopened if it fails. Clients that ask while a reading runs share the next
+// one, so that only one query at a time runs on each master.
+func (p *Proxy) currentLSN(cluster string) (int64, error) {
+	p.poolLock.Lock()
+
+	name := p.masters[cluster]
+	node := p.nodes[name]
+	credentials := p.credentials[cluster]
+
+	lc, ok := p.lsnConns[name]
+
+	if !ok && name != "" {
+		lc = &lsnConn{}
+		p.lsnConns[name] = lc
+	}
+
+	p.poolLock.Unlock()
+
+	if name == "" {
+		return 0, fmt.Errorf("cluster '%s' has no master", cluster)
+	}
+
+	/* Join the reading that has yet to start, or start the next one. */
+	lc.rounds.Lock()
+
+	if round := lc.next; round != nil {
+		lc.rounds.Unlock()
+		<-round.done
+		return round.lsn, round.err
+	}
+
+	round := &lsnRound{done: make(chan struct{})}
+	lc.next = round
+	previous := lc.running
+	lc.rounds.Unlock()
+
+	if previous != nil {
+		<-previous.done
+	}
+
+	lc.rounds.Lock()
+	lc.next, lc.running = nil, round
+	lc.rounds.Unlock()
+
+	round.lsn, round.err = lc.read(name, node, credentials)
+	close(round.done)
+
+	return round.lsn, round.err
+}
+
+// read reads the current WAL position of the master, opening the connection
+// first if needed. The query must finish within the health check timeout, or
+// the connection is closed, so that a master that hangs does not hold up the
+// writes of clients for longer.
+func (lc *lsnConn) read(name string, node common.Node, credentials common.Credentials) (int64, error) {
+	lc.lock.Lock()
+	defer lc.lock.Unlock()
+
+	if lc.conn == nil {
+		conn, err := connectBackend(name, node, config.PartitionConfig{
+			Username: credentials.Username,
+			Password: credentials.Password,
+			Database: credentials.Database,
+		})
+
+		if err != nil {
+			return 0, err
+		}
+
+		lc.conn = conn
+		lc.query = ""
+	}
+
+	timeout := time.Duration(config.GetHealthCheckConfig().Timeout) * time.Second
+
+	lc.conn.SetDeadline(connect.Deadline(timeout))
+
+	if lc.query == "" {
+		version, err := queryValue(lc.conn, versionQuery)
+
+		if err == nil {
+			lc.query, err = WALQuery(currentLSNQuery, version)
+		}
+
+		if err != nil {
+			lc.conn.Close()
+			lc.conn = nil
+			return 0, err
+		}
+	}
+
+	value, err := queryValue(lc.conn, lc.query)
+
+	if err != nil {
+		lc.conn.Close()
+		lc.conn = nil
+		return 0, err
+	}
+
+	lc.conn.SetDeadline(time.Time{})
+
+	return strconv.ParseInt(value, 10, 64)
+}
@@ -0,0 +1,644 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package proxy
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/crunchydata/crunchy-proxy/config"
+	"github.com/crunchydata/crunchy-proxy/connect"
+	"github.com/crunchydata/crunchy-proxy/metrics"
+	"github.com/crunchydata/crunchy-proxy/pool"
+	"github.com/crunchydata/crunchy-proxy/protocol"
+	"github.com/crunchydata/crunchy-proxy/util/log"
+)
+
+/* The format of the times reported by the admin console. */
+const consoleTimeFormat = "2006-01-02 15:04:05 MST"
+
+/* The number of statements reported by 'SHOW STATEMENTS' without a limit. */
+const defaultStatementLimit = 20
+
+// handleConsole serves the admin console to a client that connected to the
+// console database. The console accepts the PgBouncer 'SHOW POOLS', 'SHOW
+// STATS', 'SHOW CLIENTS' and 'SHOW SERVERS' commands and returns results in
+// the same format, so that existing monitoring tools can be used. It also
+// accepts 'SHOW STATEMENTS [n]', which reports the top statements by time,
+// 'SHOW STATS_LATENCY', which reports query latency percentiles by node and
+// route, and 'SHOW CACHE', which reports the statistics of the query result
+// cache.
+// 'PAUSE' and 'RESUME' hold and release client traffic, as in PgBouncer.
+//
+// Only the configured console users may connect. They are authenticated
+// against the master node using the database from the 'credentials' section,
+// since the console database does not exist on the backends.
+func (p *Proxy) handleConsole(client net.Conn, parameters map[string]string, negotiated *negotiation) {
+	consoleConfig := config.GetConsoleConfig()
+	user := parameters["user"]
+
+	var allowed bool
+
+	for _, consoleUser := range consoleConfig.Users {
+		if consoleUser == user {
+			allowed = true
+		}
+	}
+
+	if !allowed {
+		pgError := protocol.Error{
+			Severity: protocol.ErrorSeverityFatal,
+			Code:     protocol.ErrorCodeInvalidAuthorizationSpecification,
+			Message:  "not allowed to connect to the admin console",
+		}
+
+		connect.Send(client, pgError.GetMessage())
+		log.Errorf("Client: %s - user '%s' not allowed to use the console",
+			client.RemoteAddr(), user)
+		return
+	}
+
+	options := make(map[string]string)
+
+	for name, value := range parameters {
+		if name != "user" && name != "database" {
+			options[name] = value
+		}
+	}
+
+	startup := protocol.CreateStartupMessage(user, config.GetCredentials().Database, options)
+
+	s := p.newSession(client, partition{database: consoleConfig.Database, username: user})
+	defer p.removeSession(s)
+
+	authenticated, err := connect.AuthenticateClient(negotiate(client, negotiated), "", startup, len(startup),
+		s.processID, s.secretKey)
+
+	if err == io.EOF {
+		return
+	} else if !authenticated {
+		log.Errorf("Client: %s - console authentication failed", client.RemoteAddr())
+		return
+	}
+
+	log.Infof("Client: %s - connected to the admin console", client.RemoteAddr())
+
+	for {
+		message, length, err := connect.Receive(client)
+
+		if err != nil {
+			return
+		}
+
+		s.setRequested()
+
+		switch protocol.GetMessageType(message[:length]) {
+		case protocol.TerminateMessageType:
+			return
+		case protocol.QueryMessageType:
+			connect.SendBuffers(client, p.runConsoleCommand(getQuery(message[:length])))
+		default:
+			connect.Send(client, consoleError("only simple queries are supported by the admin console"))
+		}
+	}
+}
+
+// runConsoleCommand runs a console command and returns the response for the
+// client, which always ends with a ReadyForQuery message. The messages are
+// kept separate, to be written together, rather than copied into one buffer.
+func (p *Proxy) runConsoleCommand(query string) [][]byte {
+	command := strings.ToLower(strings.Join(strings.Fields(strings.TrimRight(
+		strings.TrimSpace(query), ";")), " "))
+
+	var columns []protocol.Column
+	var rows [][]string
+
+	switch command {
+	case "pause":
+		return p.consolePause()
+	case "resume":
+		p.Resume()
+
+		return [][]byte{
+			protocol.CreateCommandCompleteMessage("RESUME"),
+			protocol.CreateReadyForQueryMessage(protocol.TransactionIdle),
+		}
+	case "show pools":
+		columns, rows = p.showPools()
+	case "show stats":
+		columns, rows = p.showStats()
+	case "show stats_latency":
+		columns, rows = p.showLatency()
+	case "show clients":
+		columns, rows = p.showClients()
+	case "show servers":
+		columns, rows = p.showServers()
+	case "show cache":
+		columns, rows = p.showCache()
+	default:
+		/* SHOW STATEMENTS takes an optional limit on the number of rows. */
+		limit, ok := parseStatementsCommand(command)
+
+		if !ok {
+			return [][]byte{consoleError(fmt.Sprintf("invalid command '%s'", strings.TrimSpace(query)))}
+		}
+
+		columns, rows = p.showStatements(limit)
+	}
+
+	response := make([][]byte, 0, len(rows)+3)
+	response = append(response, protocol.CreateRowDescriptionMessage(columns))
+
+	for _, row := range rows {
+		response = append(response, protocol.CreateDataRowMessage(row))
+	}
+
+	response = append(response, protocol.CreateCommandCompleteMessage("SHOW"))
+	response = append(response, protocol.CreateReadyForQueryMessage(protocol.TransactionIdle))
+
+	return response
+}
+
+// consolePause pauses the proxy, waiting for clients to finish their
+// transactions. An error is returned if some have not finished in time, though
+// the proxy remains paused.
+func (p *Proxy) consolePause() [][]byte {
+	if active := p.Pause(config.GetPauseTimeout()); active > 0 {
+		pgError := protocol.Error{
+			Severity: protocol.ErrorSeverityError,
+			Code:     protocol.ErrorCodeObjectNotInPrerequisiteState,
+			Message:  fmt.Sprintf("%d clients still hold a backend", active),
+			Hint:     "The proxy remains paused. Run PAUSE again to keep waiting, or RESUME.",
+		}
+
+		return [][]byte{
+			pgError.GetMessage(),
+			protocol.CreateReadyForQueryMessage(protocol.TransactionIdle),
+		}
+	}
+
+	return [][]byte{
+		protocol.CreateCommandCompleteMessage("PAUSE"),
+		protocol.CreateReadyForQueryMessage(protocol.TransactionIdle),
+	}
+}
+
+func consoleError(message string) []byte {
+	pgError := protocol.Error{
+		Severity: protocol.ErrorSeverityError,
+		Code:     protocol.ErrorCodeSyntaxError,
+		Message:  message,
+	}
+
+	return append(pgError.GetMessage(),
+		protocol.CreateReadyForQueryMessage(protocol.TransactionIdle)...)
+}
+
+func textColumns(names ...string) []protocol.Column {
+	columns := make([]protocol.Column, 0, len(names))
+
+	for _, name := range names {
+		columns = append(columns, protocol.Column{Name: name, Type: protocol.TextOID})
+	}
+
+	return columns
+}
+
+func intColumns(names ...string) []protocol.Column {
+	columns := make([]protocol.Column, 0, len(names))
+
+	for _, name := range names {
+		columns = append(columns, protocol.Column{Name: name, Type: protocol.Int8OID})
+	}
+
+	return columns
+}
+
+func itoa(value int64) string {
+	return strconv.FormatInt(value, 10)
+}
+
+func microseconds(duration time.Duration) string {
+	return itoa(int64(duration / time.Microsecond))
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.Format(consoleTimeFormat)
+}
+
+func splitAddr(addr net.Addr) (string, string) {
+	if addr == nil {
+		return "", ""
+	}
+
+	host, port, err := net.SplitHostPort(addr.String())
+
+	if err != nil {
+		return addr.String(), ""
+	}
+
+	return host, port
+}
+
+// showPools reports the clients and servers of each database and user. The
+// pools of a database and user on all of the nodes are reported together.
+func (p *Proxy) showPools() ([]protocol.Column, [][]string) {
+	type poolRow struct {
+		clActive, clWaiting, svActive, svIdle int64
+		maxWait                               time.Duration
+		mode                                  string
+	}
+
+	pools := make(map[partition]*poolRow)
+	var partitions []partition
+
+	p.poolLock.Lock()
+	for key, cp := range p.pools {
+		row, ok := pools[key.partition]
+
+		if !ok {
+			row = &poolRow{}
+			pools[key.partition] = row
+			partitions = append(partitions, key.partition)
+		}
+
+		stats := cp.Stats()
+		row.svActive += int64(stats.InUse)
+		row.svIdle += int64(stats.Idle)
+		row.mode = cp.Mode
+	}
+	p.poolLock.Unlock()
+
+	now := time.Now()
+
+	for _, s := range p.getSessions() {
+		row, ok := pools[partition{s.cluster, s.database, s.user}]
+
+		if !ok {
+			continue
+		}
+
+		if s.waiting.IsZero() {
+			row.clActive++
+		} else {
+			row.clWaiting++
+
+			if wait := now.Sub(s.waiting); wait > row.maxWait {
+				row.maxWait = wait
+			}
+		}
+	}
+
+	sort.Slice(partitions, func(i, j int) bool {
+		if partitions[i].database == partitions[j].database {
+			return partitions[i].username < partitions[j].username
+		}
+
+		return partitions[i].database < partitions[j].database
+	})
+
+	columns := append(textColumns("database", "user"), intColumns("cl_active",
+		"cl_waiting", "sv_active", "sv_idle", "sv_used", "sv_tested", "sv_login",
+		"maxwait", "maxwait_us")...)
+	columns = append(columns, textColumns("pool_mode")...)
+
+	var rows [][]string
+
+	for _, part := range partitions {
+		row := pools[part]
+
+		rows = append(rows, []string{
+			part.database,
+			part.username,
+			itoa(row.clActive),
+			itoa(row.clWaiting),
+			itoa(row.svActive),
+			itoa(row.svIdle),
+			"0",
+			"0",
+			"0",
+			itoa(int64(row.maxWait / time.Second)),
+			itoa(int64((row.maxWait % time.Second) / time.Microsecond)),
+			row.mode,
+		})
+	}
+
+	return columns, rows
+}
+
+// showStats reports the traffic of each database. Averages are calculated
+// over the uptime of the proxy, which includes its earlier runs if the
+// statistics are saved to a file.
+func (p *Proxy) showStats() ([]protocol.Column, [][]string) {
+	stats := p.getStats()
+	seconds := int64(p.Uptime().Total / time.Second)
+
+	if seconds < 1 {
+		seconds = 1
+	}
+
+	databases := make([]string, 0, len(stats))
+
+	for database := range stats {
+		databases = append(databases, database)
+	}
+
+	sort.Strings(databases)
+
+	columns := append(textColumns("database"), intColumns("total_xact_count",
+		"total_query_count", "total_received", "total_sent", "total_xact_time",
+		"total_query_time", "total_wait_time", "avg_xact_count",
+		"avg_query_count", "avg_recv", "avg_sent", "avg_xact_time",
+		"avg_query_time", "avg_wait_time")...)
+
+	var rows [][]string
+
+	for _, database := range databases {
+		s := stats[database]
+
+		var avgXactTime, avgQueryTime time.Duration
+
+		if s.xactCount > 0 {
+			avgXactTime = s.xactTime / time.Duration(s.xactCount)
+		}
+
+		if s.queryCount > 0 {
+			avgQueryTime = s.queryTime / time.Duration(s.queryCount)
+		}
+
+		rows = append(rows, []string{
+			database,
+			itoa(s.xactCount),
+			itoa(s.queryCount),
+			itoa(s.received),
+			itoa(s.sent),
+			microseconds(s.xactTime),
+			microseconds(s.queryTime),
+			microseconds(s.waitTime),
+			itoa(s.xactCount / seconds),
+			itoa(s.queryCount / seconds),
+			itoa(s.received / seconds),
+			itoa(s.sent / seconds),
+			microseconds(avgXactTime),
+			microseconds(avgQueryTime),
+			microseconds(s.waitTime / time.Duration(seconds)),
+		})
+	}
+
+	return columns, rows
+}
+
+// showLatency reports the latency percentiles of the read and write queries
+// sent to each node, so that slow replicas can be told from a slow master.
+func (p *Proxy) showLatency() ([]protocol.Column, [][]string) {
+	columns := append(textColumns("node", "route"), intColumns("query_count",
+		"avg_query_time", "p50_query_time", "p95_query_time", "p99_query_time")...)
+
+	var rows [][]string
+
+	for _, latency := range p.QueryLatencies() {
+		route := metrics.RouteWrite
+
+		if latency.Read {
+			route = metrics.RouteRead
+		}
+
+		rows = append(rows, []string{
+			latency.Node,
+			route,
+			itoa(latency.Count),
+			microseconds(latency.Average),
+			microseconds(latency.P50),
+			microseconds(latency.P95),
+			microseconds(latency.P99),
+		})
+	}
+
+	return columns, rows
+}
+
+// parseStatementsCommand parses a SHOW STATEMENTS command, which may be
+// followed by the number of statements to report, and returns that number.
+func parseStatementsCommand(command string) (int, bool) {
+	fields := strings.Fields(command)
+
+	if len(fields) < 2 || len(fields) > 3 || fields[0] != "show" || fields[1] != "statements" {
+		return 0, false
+	}
+
+	if len(fields) == 2 {
+		return defaultStatementLimit, true
+	}
+
+	limit, err := strconv.Atoi(fields[2])
+
+	if err != nil || limit < 1 {
+		return 0, false
+	}
+
+	return limit, true
+}
+
+// showStatements reports the normalized queries with the largest total
+// execution time on each node.
+func (p *Proxy) showStatements(limit int) ([]protocol.Column, [][]string) {
+	columns := append(textColumns("query", "node"), intColumns("calls",
+		"total_time", "mean_time", "max_time")...)
+
+	var rows [][]string
+
+	for _, statement := range p.TopStatements(limit) {
+		rows = append(rows, []string{
+			statement.Query,
+			statement.Node,
+			itoa(statement.Calls),
+			microseconds(statement.TotalTime),
+			microseconds(statement.MeanTime),
+			microseconds(statement.MaxTime),
+		})
+	}
+
+	return columns, rows
+}
+
+// showCache reports the statistics of the query result cache.
+func (p *Proxy) showCache() ([]protocol.Column, [][]string) {
+	columns := intColumns("entries", "size", "hits", "misses", "evictions", "expirations")
+
+	stats := p.CacheStats()
+
+	rows := [][]string{{
+		itoa(int64(stats.Entries)),
+		itoa(stats.Size),
+		itoa(stats.Hits),
+		itoa(stats.Misses),
+		itoa(stats.Evictions),
+		itoa(stats.Expirations),
+	}}
+
+	return columns, rows
+}
+
+var connectionColumns = append(append(append(textColumns("type", "user", "database",
+	"state", "addr", "port", "local_addr", "local_port", "connect_time",
+	"request_time"), intColumns("wait", "wait_us")...),
+	textColumns("ptr", "link")...), intColumns("id")...)
+
+// showClients reports each client connection.
+func (p *Proxy) showClients() ([]protocol.Column, [][]string) {
+	var rows [][]string
+
+	now := time.Now()
+
+	sessions := p.getSessions()
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].processID < sessions[j].processID
+	})
+
+	for _, s := range sessions {
+		var wait time.Duration
+		state := "active"
+
+		if !s.waiting.IsZero() {
+			state = "waiting"
+			wait = now.Sub(s.waiting)
+		}
+
+		addr, port := splitAddr(s.client.RemoteAddr())
+		localAddr, localPort := splitAddr(s.client.LocalAddr())
+
+		var link string
+
+		if s.backend != nil {
+			link = fmt.Sprintf("%p", s.backend)
+		}
+
+		rows = append(rows, []string{
+			"C",
+			s.user,
+			s.database,
+			state,
+			addr,
+			port,
+			localAddr,
+			localPort,
+			formatTime(s.connected),
+			formatTime(s.requested),
+			itoa(int64(wait / time.Second)),
+			itoa(int64((wait % time.Second) / time.Microsecond)),
+			fmt.Sprintf("%p", s.client),
+			link,
+			itoa(int64(s.processID)),
+		})
+	}
+
+	return connectionColumns, rows
+}
+
+// showServers reports each pool connection.
+func (p *Proxy) showServers() ([]protocol.Column, [][]string) {
+	var rows [][]string
+
+	/* Find the client holding each backend connection. */
+	clients := make(map[net.Conn]net.Conn)
+
+	for _, s := range p.getSessions() {
+		if s.backend != nil {
+			clients[s.backend] = s.client
+		}
+	}
+
+	p.poolLock.Lock()
+	keys := make([]poolKey, 0, len(p.pools))
+
+	for key := range p.pools {
+		keys = append(keys, key)
+	}
+
+	servers := make(map[poolKey][]pool.Server, len(keys))
+
+	for key, cp := range p.pools {
+		servers[key] = cp.Servers()
+	}
+	p.poolLock.Unlock()
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].database != keys[j].database {
+			return keys[i].database < keys[j].database
+		}
+
+		if keys[i].username != keys[j].username {
+			return keys[i].username < keys[j].username
+		}
+
+		return keys[i].node < keys[j].node
+	})
+
+	for _, key := range keys {
+		for _, server := range servers[key] {
+			state := "idle"
+
+			if server.InUse {
+				state = "active"
+			}
+
+			addr, port := splitAddr(server.Connection.RemoteAddr())
+			localAddr, localPort := splitAddr(server.Connection.LocalAddr())
+
+			var link string
+
+			if client, ok := clients[server.Connection]; ok {
+				link = fmt.Sprintf("%p", client)
+			}
+
+			/* The process ID of the backend serving the connection. */
+			var processID int32
+
+			if conn, ok := server.Connection.(*backendConn); ok {
+				processID = conn.processID
+			}
+
+			rows = append(rows, []string{
+				"S",
+				key.username,
+				key.database,
+				state,
+				addr,
+				port,
+				localAddr,
+				localPort,
+				formatTime(server.Created),
+				"",
+				"0",
+				"0",
+				fmt.Sprintf("%p", server.Connection),
+				link,
+				itoa(int64(processID)),
+			})
+		}
+	}
+
+	return connectionColumns, rows
+}
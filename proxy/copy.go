@@ -0,0 +1,193 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package proxy
+
+import (
+	"net"
+
+	"github.com/crunchydata/crunchy-proxy/config"
+	"github.com/crunchydata/crunchy-proxy/connect"
+	"github.com/crunchydata/crunchy-proxy/metrics"
+	"github.com/crunchydata/crunchy-proxy/protocol"
+	"github.com/crunchydata/crunchy-proxy/util/log"
+)
+
+/* The size of the chunks that COPY data is streamed in. */
+const copyChunkSize = 32 * 1024
+
+// messageTracker follows the message boundaries in a stream of protocol
+// messages that is read in arbitrary chunks, so that a message may be split
+// across reads. Only the message headers are retained, which allows messages
+// of any size to be relayed without buffering them.
+//
+// The bodies of messages of the capture type, if set, are retained as well so
+// that they can be examined once they are complete.
+//
+// If maxSize is set, then the length of each message is validated, and the
+// first invalid one stops the scan and is reported in err. Nothing more of
+// the stream can be made sense of after it.
+type messageTracker struct {
+	header    []byte // the type and length of the current message
+	remaining int    // the number of body bytes of the current message not yet seen
+	first     byte   // the first body byte of the current message
+	capture   byte   // the type of the messages whose bodies are retained
+	also      byte   // another type of message whose bodies are retained
+	body      []byte // the body of the current message, if it is captured
+	end       int    // the offset in the chunk after the message that completed
+	maxSize   int    // the largest message accepted, if validated
+	err       error  // the reason the stream was rejected
+}
+
+// scan processes the next chunk of the stream and calls complete for each
+// message that ends in the chunk, with the message type and the first byte of
+// the message body. The body of a captured message is available in t.body,
+// and the offset in the chunk at which the message ends in t.end, while
+// complete is called.
+func (t *messageTracker) scan(chunk []byte, complete func(messageType byte, first byte)) {
+	size := len(chunk)
+
+	for len(chunk) > 0 && t.err == nil {
+		/* Read the message header, which might be split across chunks. */
+		if len(t.header) < 5 {
+			n := 5 - len(t.header)
+
+			if n > len(chunk) {
+				n = len(chunk)
+			}
+
+			t.header = append(t.header, chunk[:n]...)
+			chunk = chunk[n:]
+
+			if len(t.header) < 5 {
+				return
+			}
+
+			length := protocol.GetMessageLength(t.header)
+
+			if t.maxSize > 0 {
+				if t.err = protocol.ValidateMessageLength(length, t.maxSize); t.err != nil {
+					return
+				}
+			}
+
+			t.remaining = int(length) - 4
+			t.first = 0
+			t.body = t.body[:0]
+
+			if t.remaining <= 0 {
+				t.end = size - len(chunk)
+				complete(t.header[0], t.first)
+				t.header = t.header[:0]
+			}
+
+			continue
+		}
+
+		/* Skip over the message body. */
+		if t.remaining == int(protocol.GetMessageLength(t.header))-4 {
+			t.first = chunk[0]
+		}
+
+		n := t.remaining
+
+		if n > len(chunk) {
+			n = len(chunk)
+		}
+
+		if (t.capture != 0 && t.header[0] == t.capture) || (t.also != 0 && t.header[0] == t.also) {
+			t.body = append(t.body, chunk[:n]...)
+		}
+
+		t.remaining -= n
+		chunk = chunk[n:]
+
+		if t.remaining == 0 {
+			t.end = size - len(chunk)
+			complete(t.header[0], t.first)
+			t.header = t.header[:0]
+		}
+	}
+}
+
+/* partial returns whether the stream has ended partway through a message. */
+func (t *messageTracker) partial() bool {
+	return len(t.header) > 0
+}
+
+// padding returns the bytes needed to complete the current message, so that
+// another message can follow it in the stream. Nothing can be returned if the
+// stream ends within a message header, or after an invalid one.
+func (t *messageTracker) padding() []byte {
+	if len(t.header) < 5 || t.err != nil {
+		return nil
+	}
+
+	return make([]byte, t.remaining)
+}
+
+// copyFromClient streams COPY data from the client to the backend in fixed
+// size chunks until the client sends CopyDone or CopyFail. If the client
+// connection fails, then the COPY is failed on the backend so that the
+// backend completes its response. If the client sends an invalid message,
+// then a protocol.ViolationError is returned and the backend is left mid-COPY.
+func copyFromClient(client net.Conn, backend net.Conn) error {
+	buffer := make([]byte, copyChunkSize)
+	messages := &messageTracker{maxSize: config.GetMaxMessageSize()}
+
+	for {
+		var finished bool
+
+		length, err := client.Read(buffer)
+
+		if length > 0 {
+			messages.scan(buffer[:length], func(messageType byte, first byte) {
+				switch messageType {
+				case protocol.CopyDoneMessageType, protocol.CopyFailMessageType:
+					finished = true
+				}
+			})
+
+			/*
+			 * The position of the backend in the stream is lost along with
+			 * the client's, so the COPY cannot be failed cleanly.
+			 */
+			if messages.err != nil {
+				return messages.err
+			}
+
+			if _, err := connect.Send(backend, buffer[:length]); err != nil {
+				return err
+			}
+
+			metrics.BytesProxied.WithLabelValues(metrics.DirectionClientToBackend).Add(float64(length))
+		}
+
+		if finished {
+			return nil
+		}
+
+		if err != nil {
+			/*
+			 * If the client stopped part way through a CopyData message, then
+			 * complete the message with padding before failing the COPY. The
+			 * data is discarded by the backend anyway.
+			 */
+			log.Debugf("Failing COPY on backend %s", backend.RemoteAddr())
+			connect.Send(backend, messages.padding())
+			connect.Send(backend, protocol.CreateCopyFailMessage("client connection lost"))
+			return err
+		}
+	}
+}
@@ -0,0 +1,266 @@
+/*
+Copyright 2016 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+
+	"github.com/crunchydata/crunchy-proxy/protocol"
+)
+
+/* Build a message of the type whose body is the null terminated strings. */
+func newMessage(messageType byte, fields ...string) []byte {
+	message := protocol.NewMessageBuffer([]byte{})
+
+	message.WriteByte(messageType)
+	message.WriteInt32(0)
+
+	for _, field := range fields {
+		message.WriteString(field)
+	}
+
+	message.ResetLength(protocol.PGMessageLengthOffset)
+
+	return message.Bytes()
+}
+
+/* Build a Parse message for a statement without parameter types. */
+func newParse(name string, query string) []byte {
+	return appendLength(newMessage(protocol.ParseMessageType, name, query), 0, 0)
+}
+
+/* Build a Bind message for a statement without parameters or result formats. */
+func newBind(portal string, name string) []byte {
+	return appendLength(newMessage(protocol.BindMessageType, portal, name), 0, 0, 0, 0, 0, 0)
+}
+
+/* Build an Execute message that fetches every row of the portal. */
+func newExecute(portal string) []byte {
+	return appendLength(newMessage(protocol.ExecuteMessageType, portal), 0, 0, 0, 0)
+}
+
+/* Build a Describe message for the named statement or portal. */
+func newDescribe(kind byte, name string) []byte {
+	return appendLength(newMessage(protocol.DescribeMessageType), append([]byte{kind}, name+"\x00"...)...)
+}
+
+/* Append bytes to a message, updating its length. */
+func appendLength(m []byte, body ...byte) []byte {
+	m = append(m, body...)
+	binary.BigEndian.PutUint32(m[1:], uint32(len(m)-1))
+
+	return m
+}
+
+/* Split a stream into chunks that end at the offsets. */
+func splitAt(stream []byte, offsets ...int) [][]byte {
+	var chunks [][]byte
+	start := 0
+
+	for _, offset := range offsets {
+		chunks = append(chunks, stream[start:offset])
+		start = offset
+	}
+
+	return append(chunks, stream[start:])
+}
+
+func TestMessageTrackerScan(t *testing.T) {
+	query := protocol.CreateQueryMessage("select 1")
+	syncMessage := protocol.CreateSyncMessage()
+	batch := bytes.Join([][]byte{newParse("", "select $1"), newBind("", ""), newExecute(""), syncMessage}, nil)
+
+	type completed struct {
+		messageType byte
+		first       byte
+		end         int // offset in the chunk the message ended in
+		chunk       int // index of that chunk
+	}
+
+	tests := []struct {
+		name     string
+		stream   []byte
+		offsets  []int // where the stream is split into chunks
+		expected []completed
+	}{
+		{
+			name:     "single message",
+			stream:   query,
+			expected: []completed{{'Q', 's', len(query), 0}},
+		},
+		{
+			name:    "batch in one read",
+			stream:  batch,
+			offsets: nil,
+			expected: []completed{
+				{'P', 0, len(newParse("", "select $1")), 0},
+				{'B', 0, len(newParse("", "select $1")) + len(newBind("", "")), 0},
+				{'E', 0, len(batch) - len(syncMessage), 0},
+				{'S', 0, len(batch), 0},
+			},
+		},
+		{
+			name:     "split within the header",
+			stream:   query,
+			offsets:  []int{3},
+			expected: []completed{{'Q', 's', len(query) - 3, 1}},
+		},
+		{
+			name:     "split after the header",
+			stream:   query,
+			offsets:  []int{5},
+			expected: []completed{{'Q', 's', len(query) - 5, 1}},
+		},
+		{
+			name:     "split within the body",
+			stream:   query,
+			offsets:  []int{1, 7, 9},
+			expected: []completed{{'Q', 's', len(query) - 9, 3}},
+		},
+		{
+			name:    "batch split between and within messages",
+			stream:  append(append([]byte(nil), query...), syncMessage...),
+			offsets: []int{len(query) - 1, len(query) + 2},
+			expected: []completed{
+				{'Q', 's', 1, 1},
+				{'S', 0, len(syncMessage) - 2, 2},
+			},
+		},
+		{
+			name:     "byte at a time",
+			stream:   syncMessage,
+			offsets:  []int{1, 2, 3, 4},
+			expected: []completed{{'S', 0, 1, 4}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var tracker messageTracker
+			var got []completed
+
+			for i, chunk := range splitAt(test.stream, test.offsets...) {
+				tracker.scan(chunk, func(messageType byte, first byte) {
+					got = append(got, completed{messageType, first, tracker.end, i})
+				})
+			}
+
+			if !reflect.DeepEqual(got, test.expected) {
+				t.Fatalf("expected %v, got %v", test.expected, got)
+			}
+
+			if tracker.partial() {
+				t.Fatal("expected the stream to end on a message boundary")
+			}
+		})
+	}
+}
+
+func TestMessageTrackerPartial(t *testing.T) {
+	query := protocol.CreateQueryMessage("select 1")
+
+	tests := []struct {
+		name    string
+		length  int // bytes of the message received
+		padding int // bytes needed to complete the message, -1 if unknown
+	}{
+		{name: "nothing", length: 0, padding: 0},
+		{name: "within the header", length: 3, padding: -1},
+		{name: "header only", length: 5, padding: len(query) - 5},
+		{name: "within the body", length: 8, padding: len(query) - 8},
+		{name: "whole message", length: len(query), padding: 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var tracker messageTracker
+
+			tracker.scan(query[:test.length], func(byte, byte) {})
+
+			partial := test.length > 0 && test.length < len(query)
+
+			if tracker.partial() != partial {
+				t.Fatalf("expected partial() to be %v", partial)
+			}
+
+			padding := tracker.padding()
+
+			if test.padding < 0 {
+				if padding != nil {
+					t.Fatalf("expected no padding, got %d bytes", len(padding))
+				}
+			} else if len(padding) != test.padding {
+				t.Fatalf("expected %d bytes of padding, got %d", test.padding, len(padding))
+			}
+		})
+	}
+}
+
+func TestMessageTrackerCapture(t *testing.T) {
+	status := protocol.CreateParameterStatusMessage("TimeZone", "UTC")
+	ready := protocol.CreateReadyForQueryMessage(protocol.TransactionIdle)
+	stream := append(append([]byte(nil), status...), ready...)
+
+	for _, offset := range []int{1, 5, 9, len(status), len(status) + 3} {
+		tracker := messageTracker{capture: protocol.ParameterStatusMessageType}
+		var bodies [][]byte
+
+		for _, chunk := range splitAt(stream, offset) {
+			tracker.scan(chunk, func(messageType byte, first byte) {
+				if messageType == protocol.ParameterStatusMessageType {
+					bodies = append(bodies, append([]byte(nil), tracker.body...))
+				} else if len(tracker.body) != 0 {
+					t.Fatalf("split at %d: captured the body of a '%c' message", offset, messageType)
+				}
+			})
+		}
+
+		if len(bodies) != 1 || !bytes.Equal(bodies[0], status[5:]) {
+			t.Fatalf("split at %d: expected the ParameterStatus body, got %q", offset, bodies)
+		}
+	}
+}
+
+func TestMessageTrackerMaxSize(t *testing.T) {
+	tests := []struct {
+		name    string
+		message []byte
+		valid   bool
+	}{
+		{name: "within the limit", message: protocol.CreateQueryMessage("select 1"), valid: true},
+		{name: "too large", message: protocol.CreateQueryMessage(string(make([]byte, 64)))},
+		{name: "invalid length", message: []byte{'Q', 0, 0, 0, 2}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tracker := messageTracker{maxSize: 32}
+			var completed int
+
+			tracker.scan(test.message, func(byte, byte) { completed++ })
+
+			if valid := tracker.err == nil; valid != test.valid {
+				t.Fatalf("expected valid to be %v, got error %v", test.valid, tracker.err)
+			}
+
+			if !test.valid && completed != 0 {
+				t.Fatal("expected no messages after an invalid header")
+			}
+		})
+	}
+}
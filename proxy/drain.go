@@ -0,0 +1,117 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+import (
+	"net"
+	"time"
+
+	"github.com/crunchydata/crunchy-proxy/config"
+	"github.com/crunchydata/crunchy-proxy/pool"
+	"github.com/crunchydata/crunchy-proxy/util/log"
+)
+
+/* How often the progress of draining a node is reported. */
+const drainPollInterval = time.Second
+
+// DrainProgress reports the progress of draining a node.
+type DrainProgress struct {
+	Active     int  // pool connections of the node still held by clients
+	Terminated int  // clients disconnected when the timeout expired
+	Done       bool // whether the node has been drained
+}
+
+// DrainNode takes a node out of service for maintenance. The node is disabled,
+// so that it receives no new sessions or queries, and its idle pool
+// connections are closed. It then waits up to the timeout for the clients that
+// hold one of its connections to release it, reporting progress as it goes,
+// and disconnects the clients that still hold one when the timeout expires.
+// The node remains disabled until it is enabled again.
+func (p *Proxy) DrainNode(name string, timeout time.Duration, report func(DrainProgress)) error {
+	var pools []*pool.Pool
+
+	p.poolLock.Lock()
+
+	for key, nodePool := range p.pools {
+		if key.node == name {
+			pools = append(pools, nodePool)
+		}
+	}
+
+	p.poolLock.Unlock()
+
+	if err := config.SetNodeDisabled(name, true); err != nil {
+		return err
+	}
+
+	log.Infof("Draining node '%s', timeout: %s", name, timeout)
+
+	/* Reloading removes the node's pools and closes their idle connections. */
+	p.Reload()
+
+	deadline := time.Now().Add(timeout)
+
+	for {
+		held := heldConnections(pools)
+
+		if len(held) == 0 {
+			log.Infof("Node '%s' has been drained", name)
+			report(DrainProgress{Done: true})
+			return nil
+		}
+
+		if !time.Now().Before(deadline) {
+			terminated := p.terminateHolders(held)
+
+			log.Infof("Drain timeout of node '%s' expired, terminated %d clients",
+				name, terminated)
+			report(DrainProgress{Terminated: terminated, Done: true})
+			return nil
+		}
+
+		report(DrainProgress{Active: len(held)})
+		time.Sleep(drainPollInterval)
+	}
+}
+
+/* heldConnections returns the connections of the pools that are in use. */
+func heldConnections(pools []*pool.Pool) map[net.Conn]bool {
+	held := make(map[net.Conn]bool)
+
+	for _, nodePool := range pools {
+		for _, server := range nodePool.Servers() {
+			if server.InUse {
+				held[server.Connection] = true
+			}
+		}
+	}
+
+	return held
+}
+
+/* terminateHolders disconnects the clients that hold one of the connections. */
+func (p *Proxy) terminateHolders(held map[net.Conn]bool) int {
+	var terminated int
+
+	for _, s := range p.getSessions() {
+		if s.backend != nil && held[s.backend] {
+			if err := p.TerminateSession(s.processID); err == nil {
+				terminated++
+			}
+		}
+	}
+
+	return terminated
+}
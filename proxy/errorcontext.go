@@ -0,0 +1,59 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package proxy
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/crunchydata/crunchy-proxy/protocol"
+)
+
+/*
+ * An error that a client receives from a backend does not say which node it
+ * came from, which makes it hard to match with the logs of the nodes when the
+ * client's queries are spread over several of them. If error context is
+ * enabled, then each error from a backend is followed by a notice naming the
+ * node and the client's session in the proxy.
+ */
+
+// errorContextNotice creates the notice that follows the errors that a
+// backend returns to the client of a session.
+func errorContextNotice(s *session, nodeName string, backend net.Conn) []byte {
+	notice := &protocol.Error{
+		Severity: protocol.ErrorSeverityNotice,
+		Code:     protocol.ErrorCodeSuccessfulCompletion,
+		Message: fmt.Sprintf("the error was returned by node '%s' at %s",
+			nodeName, backend.RemoteAddr()),
+		Detail: fmt.Sprintf("Proxy session %d of client %s on %s.",
+			s.processID, s.client.RemoteAddr(), s.client.LocalAddr()),
+	}
+
+	return notice.GetNoticeMessage()
+}
+
+// insertMessage returns a chunk of a stream of messages with a message
+// inserted at each of the offsets, which must be message boundaries.
+func insertMessage(chunk []byte, offsets []int, message []byte) []byte {
+	result := make([]byte, 0, len(chunk)+len(offsets)*len(message))
+	start := 0
+
+	for _, offset := range offsets {
+		result = append(result, chunk[start:offset]...)
+		result = append(result, message...)
+		start = offset
+	}
+
+	return append(result, chunk[start:]...)
+}
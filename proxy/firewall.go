@@ -0,0 +1,263 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/crunchydata/crunchy-proxy/config"
+	"github.com/crunchydata/crunchy-proxy/protocol"
+	"github.com/crunchydata/crunchy-proxy/util/log"
+)
+
+/*
+ * The table that an UPDATE or DELETE statement modifies, in a normalized
+ * statement, e.g. 'DELETE FROM ONLY "Sales".orders'.
+ */
+var modifiedTable = regexp.MustCompile(
+	`(?i)^(?:update|delete from) (?:only )?((?:"[^"]+"|[\w$]+)(?: ?\. ?(?:"[^"]+"|[\w$]+))?)`)
+
+/*
+ * The compiled patterns of the firewall rules, by pattern, so that they are
+ * only compiled once. An invalid pattern is kept as nil.
+ */
+var (
+	firewallPatterns    = make(map[string]*regexp.Regexp)
+	firewallPatternLock sync.Mutex
+)
+
+// checkFirewall checks the queries of a batch of messages from a client
+// against the rules of the query firewall that apply to the client's user and
+// database. The query of every Query and Parse message in the batch is
+// checked, and each statement of a query separately. An error to reject the
+// batch with is returned if a statement matches a rule, or if the batch ends
+// partway through a message, which could not be checked.
+func checkFirewall(part partition, request []byte) error {
+	firewallConfig := config.GetFirewallConfig()
+
+	if !firewallConfig.Enable || len(firewallConfig.Rules) == 0 {
+		return nil
+	}
+
+	var statements []statement
+	var size int
+
+	for _, m := range getMessages(request) {
+		if len(m) != int(protocol.GetMessageLength(m))+1 {
+			break
+		}
+
+		size += len(m)
+
+		var query string
+
+		switch protocol.GetMessageType(m) {
+		case protocol.QueryMessageType:
+			query = getQuery(m)
+		case protocol.ParseMessageType:
+			_, query = getParse(m)
+		}
+
+		if query == "" {
+			continue
+		}
+
+		/* A query that cannot be split is checked as a whole. */
+		split, ok := splitStatements(query)
+
+		if !ok {
+			split = []statement{{text: query}}
+		}
+
+		statements = append(statements, split...)
+	}
+
+	if size != len(request) {
+		return &protocol.Error{
+			Severity: protocol.ErrorSeverityError,
+			Code:     protocol.ErrorCodeProtocolViolation,
+			Message:  "the batch ends partway through a message",
+		}
+	}
+
+	for _, rule := range firewallConfig.Rules {
+		if !matchesScope(rule.Users, part.username) ||
+			!matchesScope(rule.Databases, part.database) {
+			continue
+		}
+
+		for _, stmt := range statements {
+			if matchesRule(rule, stmt) {
+				return firewallError(rule)
+			}
+		}
+	}
+
+	return nil
+}
+
+// firewallApplies returns whether any rule of the query firewall applies to
+// the user and database of a partition, so that its queries must be checked.
+func firewallApplies(part partition) bool {
+	firewallConfig := config.GetFirewallConfig()
+
+	if !firewallConfig.Enable {
+		return false
+	}
+
+	for _, rule := range firewallConfig.Rules {
+		if matchesScope(rule.Users, part.username) && matchesScope(rule.Databases, part.database) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchesRule determines whether a statement matches all of the conditions
+// that a firewall rule sets. A rule without conditions matches nothing, and a
+// pattern that cannot be compiled matches every statement, so that the rule
+// fails closed.
+func matchesRule(rule config.FirewallRule, stmt statement) bool {
+	var matched bool
+
+	if rule.Pattern != "" {
+		pattern := compileFirewallPattern(rule)
+
+		if pattern != nil && !pattern.MatchString(stmt.text) {
+			return false
+		}
+
+		matched = true
+	}
+
+	if rule.Fingerprint != "" {
+		if !strings.EqualFold(normalizeQuery(stmt.text), normalizeQuery(rule.Fingerprint)) {
+			return false
+		}
+
+		matched = true
+	}
+
+	if len(rule.NoWhere) > 0 {
+		if !modifiesWithoutWhere(stmt, rule.NoWhere) {
+			return false
+		}
+
+		matched = true
+	}
+
+	return matched
+}
+
+/* compileFirewallPattern returns the compiled pattern of a rule. */
+func compileFirewallPattern(rule config.FirewallRule) *regexp.Regexp {
+	firewallPatternLock.Lock()
+	defer firewallPatternLock.Unlock()
+
+	pattern, ok := firewallPatterns[rule.Pattern]
+
+	if !ok {
+		var err error
+
+		if pattern, err = regexp.Compile(rule.Pattern); err != nil {
+			log.Errorf("Invalid pattern in firewall rule '%s'", rule.Name)
+			log.Errorf("Error: %s", err.Error())
+		}
+
+		firewallPatterns[rule.Pattern] = pattern
+	}
+
+	return pattern
+}
+
+// modifiesWithoutWhere determines whether a statement is an UPDATE or DELETE
+// of one of the tables without a WHERE clause. A table given without a schema
+// matches the table in any schema, and a table with a schema also matches an
+// unqualified reference to it, as the schema may be on the search path.
+func modifiesWithoutWhere(stmt statement, tables []string) bool {
+	for _, keyword := range stmt.keywords {
+		if keyword == "where" {
+			return false
+		}
+	}
+
+	match := modifiedTable.FindStringSubmatch(normalizeQuery(stmt.text))
+
+	if match == nil {
+		return false
+	}
+
+	schema, table := splitTableName(match[1])
+
+	for _, t := range tables {
+		ruleSchema, ruleTable := splitTableName(t)
+
+		if ruleTable == table && (ruleSchema == "" || schema == "" || ruleSchema == schema) {
+			return true
+		}
+	}
+
+	return false
+}
+
+/* splitTableName splits a possibly qualified table name into its parts. */
+func splitTableName(name string) (string, string) {
+	name = strings.ToLower(strings.Replace(strings.Replace(name, "\"", "", -1), " ", "", -1))
+
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		return name[:i], name[i+1:]
+	}
+
+	return "", name
+}
+
+/* matchesScope determines whether a user or database is in a rule's scope. */
+func matchesScope(patterns []string, value string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, value); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+/* firewallError creates the error that a batch blocked by a rule fails with. */
+func firewallError(rule config.FirewallRule) error {
+	pgError := &protocol.Error{
+		Severity: protocol.ErrorSeverityError,
+		Code:     protocol.ErrorCodeInsufficientPrivilege,
+		Message:  rule.Message,
+	}
+
+	if pgError.Message == "" {
+		pgError.Message = "statement is not allowed by the proxy"
+	}
+
+	if rule.Name != "" {
+		pgError.Detail = fmt.Sprintf("Blocked by firewall rule '%s'.", rule.Name)
+	}
+
+	return pgError
+}
@@ -0,0 +1,222 @@
+/*
+Copyright 2016 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/crunchydata/crunchy-proxy/config"
+	"github.com/crunchydata/crunchy-proxy/protocol"
+)
+
+const firewallTestConfig = `
+firewall:
+  enable: true
+  rules:
+    - name: no-drop
+      pattern: '(?i)^drop\s'
+    - name: no-bulk-delete
+      nowhere: [orders]
+    - name: reports-read-only
+      pattern: '(?i)^(insert|update)\s'
+      users: [report*]
+`
+
+/* Load a configuration from the YAML. */
+func loadTestConfig(t *testing.T, yaml string) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+
+	if err := os.WriteFile(path, []byte(yaml), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	config.SetConfigPath(path)
+
+	if err := config.Reload(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCheckFirewall(t *testing.T) {
+	loadTestConfig(t, firewallTestConfig)
+
+	syncMessage := protocol.CreateSyncMessage()
+	user := partition{database: "db", username: "app"}
+	reporter := partition{database: "db", username: "reports"}
+
+	tests := []struct {
+		name    string
+		part    partition
+		batch   [][]byte
+		blocked string // the rule that blocks the batch, if any
+	}{
+		{
+			name:  "allowed query",
+			part:  user,
+			batch: [][]byte{protocol.CreateQueryMessage("select 1")},
+		},
+		{
+			name:    "blocked query",
+			part:    user,
+			batch:   [][]byte{protocol.CreateQueryMessage("drop table orders")},
+			blocked: "no-drop",
+		},
+		{
+			name:    "blocked statement after an allowed one",
+			part:    user,
+			batch:   [][]byte{protocol.CreateQueryMessage("select 1; drop table orders")},
+			blocked: "no-drop",
+		},
+		{
+			name: "blocked query after an allowed one",
+			part: user,
+			batch: [][]byte{
+				protocol.CreateQueryMessage("select 1"),
+				protocol.CreateQueryMessage("drop table orders"),
+			},
+			blocked: "no-drop",
+		},
+		{
+			name:    "blocked Parse after other messages",
+			part:    user,
+			batch:   [][]byte{newParse("", "select 1"), newBind("", ""), newExecute(""), newParse("s1", "drop table orders"), syncMessage},
+			blocked: "no-drop",
+		},
+		{
+			name:    "delete without where",
+			part:    user,
+			batch:   [][]byte{protocol.CreateQueryMessage("delete from public.orders")},
+			blocked: "no-bulk-delete",
+		},
+		{
+			name:  "delete with where",
+			part:  user,
+			batch: [][]byte{protocol.CreateQueryMessage("delete from orders where id = 1")},
+		},
+		{
+			name:  "rule for other users",
+			part:  user,
+			batch: [][]byte{protocol.CreateQueryMessage("insert into t values (1)")},
+		},
+		{
+			name:    "rule for the user",
+			part:    reporter,
+			batch:   [][]byte{protocol.CreateQueryMessage("insert into t values (1)")},
+			blocked: "reports-read-only",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := checkFirewall(test.part, bytes.Join(test.batch, nil))
+
+			if test.blocked == "" {
+				if err != nil {
+					t.Fatalf("expected the batch to be allowed, got %v", err)
+				}
+
+				return
+			}
+
+			pgError, ok := err.(*protocol.Error)
+
+			if !ok || pgError.Code != protocol.ErrorCodeInsufficientPrivilege ||
+				pgError.Detail != "Blocked by firewall rule '"+test.blocked+"'." {
+				t.Fatalf("expected the batch to be blocked by '%s', got %v", test.blocked, err)
+			}
+		})
+	}
+}
+
+func TestCheckFirewallPartialBatch(t *testing.T) {
+	loadTestConfig(t, firewallTestConfig)
+
+	batch := bytes.Join([][]byte{
+		protocol.CreateQueryMessage("select 1"),
+		protocol.CreateQueryMessage("drop table orders"),
+	}, nil)
+
+	/* Batches that end partway through a message, as a single read may. */
+	for _, length := range []int{3, 5, 12, len(batch) - 4, len(batch) - 1} {
+		err := checkFirewall(partition{username: "app"}, batch[:length])
+		pgError, ok := err.(*protocol.Error)
+
+		if !ok || pgError.Code != protocol.ErrorCodeProtocolViolation {
+			t.Fatalf("batch of %d bytes: expected a protocol violation, got %v", length, err)
+		}
+	}
+}
+
+func TestFirewallApplies(t *testing.T) {
+	loadTestConfig(t, `
+firewall:
+  enable: true
+  rules:
+    - pattern: '(?i)^drop\s'
+      users: [report*]
+    - pattern: '(?i)^truncate\s'
+      databases: [sales]
+`)
+
+	tests := []struct {
+		name    string
+		part    partition
+		applies bool
+	}{
+		{name: "rule for the user", part: partition{database: "db", username: "reports"}, applies: true},
+		{name: "rule for the database", part: partition{database: "sales", username: "app"}, applies: true},
+		{name: "no rule", part: partition{database: "db", username: "app"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if applies := firewallApplies(test.part); applies != test.applies {
+				t.Fatalf("expected the firewall to apply: %v", test.applies)
+			}
+		})
+	}
+}
+
+func TestInvalidFirewallPattern(t *testing.T) {
+	loadTestConfig(t, firewallTestConfig)
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	invalid := "firewall:\n  enable: true\n  rules:\n    - name: broken\n      pattern: '(drop'\n"
+
+	if err := os.WriteFile(path, []byte(invalid), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	config.SetConfigPath(path)
+
+	if err := config.Reload(); err == nil {
+		t.Fatal("expected a configuration with an invalid pattern to be rejected")
+	}
+
+	/* The current rules are kept. */
+	if rules := config.GetFirewallConfig().Rules; len(rules) != 3 {
+		t.Fatalf("expected the current rules to be kept, have %d", len(rules))
+	}
+
+	/* A pattern that cannot be compiled blocks every statement. */
+	rule := config.FirewallRule{Name: "broken", Pattern: "(drop"}
+
+	if !matchesRule(rule, statement{text: "select 1"}) {
+		t.Fatal("expected a rule with an invalid pattern to match")
+	}
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package proxy
+
+import (
+	"net"
+	"syscall"
+
+	"github.com/crunchydata/crunchy-proxy/common"
+	"github.com/crunchydata/crunchy-proxy/config"
+	"github.com/crunchydata/crunchy-proxy/connect"
+)
+
+/*
+ * Each session holds a receive buffer while it waits for the next message
+ * from its client, which for tens of thousands of mostly idle clients adds up
+ * to most of the memory of the proxy. With the 'poll' idle wait, a session
+ * whose client is idle and holds no backend gives its buffer back and waits
+ * for the client to become readable on the runtime's network poller (epoll
+ * on Linux) instead, and takes a buffer again once there is something to
+ * read. The session keeps its goroutine, whose stack the runtime shrinks
+ * while it waits.
+ */
+
+// canPark reports whether the session of a client may wait for its next
+// message without holding a receive buffer. Only plain TCP and Unix socket
+// clients can, as SSL and PROXY protocol clients may have data buffered in
+// the proxy that the poller does not know about.
+func canPark(client net.Conn) bool {
+	if !pollSupported || config.GetIdleWait() != common.IDLE_WAIT_POLL {
+		return false
+	}
+
+	switch client.(type) {
+	case *net.TCPConn, *net.UnixConn:
+		return true
+	}
+
+	return false
+}
+
+// parkClient gives buffer back and waits until the client has something to
+// read, has closed its connection or has passed its read deadline. It returns
+// a buffer to receive the client's next message into.
+func parkClient(client net.Conn, buffer []byte) ([]byte, error) {
+	raw, err := client.(syscall.Conn).SyscallConn()
+
+	if err != nil {
+		return buffer, err
+	}
+
+	connect.PutBuffer(buffer)
+
+	err = waitReadable(raw)
+
+	return connect.GetBuffer(), err
+}
@@ -0,0 +1,40 @@
+//go:build !windows
+// +build !windows
+
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+import (
+	"syscall"
+)
+
+/* Idle clients may be waited for on the network poller. */
+const pollSupported = true
+
+/*
+ * waitReadable waits on the network poller until a connection can be read.
+ * The poller only reports when data arrives, so the connection is peeked at
+ * first, in case data that was already there is still unread.
+ */
+func waitReadable(raw syscall.RawConn) error {
+	var peek [1]byte
+
+	return raw.Read(func(fd uintptr) bool {
+		_, _, err := syscall.Recvfrom(int(fd), peek[:], syscall.MSG_PEEK|syscall.MSG_DONTWAIT)
+
+		return err != syscall.EAGAIN
+	})
+}
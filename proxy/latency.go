@@ -0,0 +1,158 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+import (
+	"sort"
+	"time"
+
+	"github.com/crunchydata/crunchy-proxy/metrics"
+)
+
+/*
+ * The upper bounds of the buckets of the query latency histograms, doubling
+ * from 100 microseconds to about 52 seconds. Slower queries fall in a final,
+ * unbounded bucket.
+ */
+var latencyBuckets = func() []time.Duration {
+	buckets := make([]time.Duration, 20)
+
+	for i := range buckets {
+		buckets[i] = 100 * time.Microsecond << uint(i)
+	}
+
+	return buckets
+}()
+
+// latencyKey identifies the queries of one route on a node.
+type latencyKey struct {
+	node string
+	read bool
+}
+
+// latencyHistogram counts the durations of queries by bucket.
+type latencyHistogram struct {
+	counts []int64 // by bucket, the last one being unbounded
+	count  int64
+	total  time.Duration
+}
+
+// QueryLatency summarizes the durations of the queries of one route on a
+// node, as reported by the console.
+type QueryLatency struct {
+	Node    string
+	Read    bool
+	Count   int64
+	Average time.Duration
+	P50     time.Duration
+	P95     time.Duration
+	P99     time.Duration
+}
+
+// recordLatency adds the duration of a query to the histogram of its node and
+// route, and to the query duration metrics.
+func (p *Proxy) recordLatency(node string, read bool, duration time.Duration) {
+	route := metrics.RouteWrite
+
+	if read {
+		route = metrics.RouteRead
+	}
+
+	metrics.QueryDuration.WithLabelValues(node, route).Observe(duration.Seconds())
+
+	key := latencyKey{node: node, read: read}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	histogram, ok := p.queryLatency[key]
+
+	if !ok {
+		histogram = &latencyHistogram{counts: make([]int64, len(latencyBuckets)+1)}
+		p.queryLatency[key] = histogram
+	}
+
+	bucket := len(latencyBuckets)
+
+	for i, bound := range latencyBuckets {
+		if duration <= bound {
+			bucket = i
+			break
+		}
+	}
+
+	histogram.counts[bucket]++
+	histogram.count++
+	histogram.total += duration
+}
+
+// QueryLatencies returns the latency percentiles of the queries of each route
+// on each node since the proxy started, ordered by node and with reads first.
+func (p *Proxy) QueryLatencies() []QueryLatency {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	latencies := make([]QueryLatency, 0, len(p.queryLatency))
+
+	for key, histogram := range p.queryLatency {
+		latencies = append(latencies, QueryLatency{
+			Node:    key.node,
+			Read:    key.read,
+			Count:   histogram.count,
+			Average: histogram.total / time.Duration(histogram.count),
+			P50:     histogram.quantile(0.50),
+			P95:     histogram.quantile(0.95),
+			P99:     histogram.quantile(0.99),
+		})
+	}
+
+	sort.Slice(latencies, func(i, j int) bool {
+		if latencies[i].Node != latencies[j].Node {
+			return latencies[i].Node < latencies[j].Node
+		}
+
+		return latencies[i].Read && !latencies[j].Read
+	})
+
+	return latencies
+}
+
+// quantile estimates the duration below which the given fraction of queries
+// completed, interpolating linearly within the bucket it falls in. Queries in
+// the unbounded bucket are taken to be as fast as its lower bound.
+func (h *latencyHistogram) quantile(q float64) time.Duration {
+	rank := q * float64(h.count)
+
+	var seen int64
+	var lower time.Duration
+
+	for i, count := range h.counts {
+		if i == len(latencyBuckets) {
+			return lower
+		}
+
+		upper := latencyBuckets[i]
+
+		if count > 0 && float64(seen+count) >= rank {
+			fraction := (rank - float64(seen)) / float64(count)
+			return lower + time.Duration(fraction*float64(upper-lower))
+		}
+
+		seen += count
+		lower = upper
+	}
+
+	return lower
+}
@@ -0,0 +1,192 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+import (
+	"encoding/binary"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/crunchydata/crunchy-proxy/connect"
+	"github.com/crunchydata/crunchy-proxy/protocol"
+	"github.com/crunchydata/crunchy-proxy/util/log"
+)
+
+// isListenQuery determines whether any of the statements of a query is a
+// LISTEN, after which the client must keep its backend to receive the
+// notifications.
+func isListenQuery(query string) bool {
+	statements, ok := splitStatements(query)
+
+	if !ok {
+		return false
+	}
+
+	for _, stmt := range statements {
+		if stmt.keywords[0] == "listen" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// notificationRelay forwards the messages that a backend sends on its own
+// while the client is idle, such as the NotificationResponse messages of the
+// channels that the client listens on, along with any notices and parameter
+// changes.
+type notificationRelay struct {
+	client        net.Conn
+	backend       net.Conn
+	notifications *notificationRewriter
+	stopping      int32
+	done          chan struct{}
+}
+
+// startNotificationRelay starts relaying the messages of the backend to the
+// client until stop is called.
+func startNotificationRelay(client net.Conn, backend net.Conn, notifications *notificationRewriter) *notificationRelay {
+	r := &notificationRelay{
+		client:        client,
+		backend:       backend,
+		notifications: notifications,
+		done:          make(chan struct{}),
+	}
+
+	go r.relay()
+
+	return r
+}
+
+func (r *notificationRelay) relay() {
+	defer close(r.done)
+
+	buffer := connect.GetBuffer()
+	defer connect.PutBuffer(buffer)
+
+	messages := &messageTracker{}
+
+	for {
+		message, length, err := connect.ReceiveBuffer(r.backend, buffer)
+
+		if length > 0 {
+			messages.scan(message[:length], func(messageType byte, first byte) {})
+
+			if _, err := connect.Send(r.client, r.notifications.rewrite(message[:length])); err != nil {
+				log.Debugf("Error sending notification to client %s", r.client.RemoteAddr())
+				log.Debugf("Error: %s", err.Error())
+			}
+		}
+
+		/*
+		 * Once stopped, the relay ends at a message boundary, so that the
+		 * response to the client's next message is not mixed up with the
+		 * rest of a message relayed here.
+		 */
+		stopping := atomic.LoadInt32(&r.stopping) == 1
+
+		if stopping && len(messages.header) == 0 {
+			return
+		}
+
+		if err == nil {
+			continue
+		}
+
+		/* Finish relaying a message that the stop interrupted. */
+		if netErr, ok := err.(net.Error); ok && netErr.Timeout() && stopping {
+			r.backend.SetReadDeadline(time.Time{})
+			continue
+		}
+
+		return
+	}
+}
+
+// stop stops relaying once the client has sent its next message, so that the
+// backend can be used for it.
+func (r *notificationRelay) stop() {
+	atomic.StoreInt32(&r.stopping, 1)
+	r.backend.SetReadDeadline(time.Now())
+
+	<-r.done
+
+	r.backend.SetReadDeadline(time.Time{})
+}
+
+// notificationRewriter replaces the process ID of the backend that sent each
+// NotificationResponse in a stream of backend messages with the process ID
+// that clients know the backend by, so that the process IDs of the backends
+// are not revealed and a client can recognize its own notifications.
+type notificationRewriter struct {
+	remaining int    // the number of body bytes of the current message not yet seen
+	held      []byte // the start of a message that is held back until it is complete enough
+	resolve   func(processID int32) int32
+}
+
+func (p *Proxy) newNotificationRewriter(backend net.Conn) *notificationRewriter {
+	return &notificationRewriter{
+		resolve: func(processID int32) int32 {
+			return p.clientProcessID(backend, processID)
+		},
+	}
+}
+
+// rewrite rewrites the notifications in the next chunk of the stream, which
+// is changed in place, and returns what may be sent to the client. A message
+// header, or the process ID of a notification, that is split across chunks is
+// held back until the rest of it arrives.
+func (r *notificationRewriter) rewrite(chunk []byte) []byte {
+	if len(r.held) > 0 {
+		chunk = append(r.held, chunk...)
+		r.held = nil
+	}
+
+	for i := 0; i < len(chunk); {
+		if r.remaining > 0 {
+			n := len(chunk) - i
+
+			if n > r.remaining {
+				n = r.remaining
+			}
+
+			i += n
+			r.remaining -= n
+			continue
+		}
+
+		need := 5
+
+		if chunk[i] == protocol.NotificationMessageType {
+			need = 9
+		}
+
+		if len(chunk)-i < need {
+			r.held = append([]byte(nil), chunk[i:]...)
+			return chunk[:i]
+		}
+
+		if chunk[i] == protocol.NotificationMessageType {
+			processID := int32(binary.BigEndian.Uint32(chunk[i+5 : i+9]))
+			binary.BigEndian.PutUint32(chunk[i+5:i+9], uint32(r.resolve(processID)))
+		}
+
+		r.remaining = int(protocol.GetMessageLength(chunk[i:])) - 4
+		i += 5
+	}
+
+	return chunk
+}
@@ -0,0 +1,171 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+import (
+	"net"
+	"sync"
+
+	"github.com/crunchydata/crunchy-proxy/protocol"
+)
+
+// Routing is how middleware asks for a batch of messages to be routed.
+type Routing int
+
+const (
+	RouteDefault Routing = iota // by annotations, query analysis and listener
+	RouteRead                   // to a replica, or the master if none is available
+	RouteWrite                  // to the master
+)
+
+// Request is a batch of query messages received from a client, as seen by
+// middleware. A batch is what the client sent at once, such as a single Query
+// message or a Parse/Bind/Describe/Execute/Sync sequence.
+type Request struct {
+	Client   net.Addr
+	User     string
+	Database string
+
+	// Messages holds the messages of the batch, each including its type and
+	// length. Middleware may replace them to rewrite the batch, for example
+	// to change its query. The messages should not be kept once the
+	// middleware returns, as they may refer to a buffer that is reused.
+	Messages [][]byte
+
+	// Routing overrides how the batch is routed, if it is not RouteDefault.
+	// It has no effect while the client holds a backend, e.g. in a
+	// transaction.
+	Routing Routing
+}
+
+// Query returns the text of the query in the batch, from its Query or Parse
+// message, or an empty string if it has neither.
+func (r *Request) Query() string {
+	return batchQuery(r.Messages)
+}
+
+/* batchQuery returns the query of a batch from its Query or Parse message. */
+func batchQuery(messages [][]byte) string {
+	for _, m := range messages {
+		switch protocol.GetMessageType(m) {
+		case protocol.QueryMessageType:
+			return getQuery(m)
+		case protocol.ParseMessageType:
+			_, query := getParse(m)
+			return query
+		}
+	}
+
+	return ""
+}
+
+// Middleware inspects each batch of query messages from the clients of the
+// proxy before the proxy acts on it, which allows custom routing, query
+// rewriting and policy enforcement to be compiled into the proxy. Middleware
+// is registered with Use, usually from the init function of its package, and
+// is called in the order it was registered.
+//
+// Handle is called concurrently for different clients.
+type Middleware interface {
+	// Handle may change the messages of the request or how it is routed. If
+	// it returns a response, then the response is sent to the client in
+	// place of a backend's and the rest of the middleware is skipped. The
+	// response must be complete, ending with a ReadyForQuery message if the
+	// batch includes a Query or Sync message. If it returns an error, then
+	// the batch is rejected with the error, which is sent to the client as
+	// is if it is a *protocol.Error, or as an insufficient_privilege error
+	// otherwise.
+	Handle(request *Request) (response []byte, err error)
+}
+
+var (
+	middleware     []Middleware
+	middlewareLock sync.RWMutex
+)
+
+// Use registers middleware to be called for every batch of query messages.
+func Use(m Middleware) {
+	middlewareLock.Lock()
+	defer middlewareLock.Unlock()
+
+	middleware = append(middleware, m)
+}
+
+/* hasMiddleware returns whether any middleware, or plugin, is registered. */
+func hasMiddleware() bool {
+	middlewareLock.RLock()
+	defer middlewareLock.RUnlock()
+
+	return len(middleware) > 0
+}
+
+// handleRequest passes a batch of messages through the registered middleware.
+// It returns the batch to relay, which is the original one if there is no
+// middleware, along with the routing, response or error the middleware
+// returned.
+func handleRequest(request *Request, batch []byte) ([]byte, Routing, []byte, error) {
+	middlewareLock.RLock()
+	chain := middleware
+	middlewareLock.RUnlock()
+
+	if len(chain) == 0 {
+		return batch, RouteDefault, nil, nil
+	}
+
+	request.Messages = getMessages(batch)
+
+	for _, m := range chain {
+		if response, err := m.Handle(request); response != nil || err != nil {
+			return batch, request.Routing, response, err
+		}
+	}
+
+	/*
+	 * The batch is rebuilt from the messages, as the middleware may have
+	 * replaced them, and as the original is in a buffer that is reused.
+	 */
+	rebuilt := make([]byte, 0, len(batch))
+
+	for _, m := range request.Messages {
+		rebuilt = append(rebuilt, m...)
+	}
+
+	return rebuilt, request.Routing, nil, nil
+}
+
+// rejectRequest creates the response to a batch that is rejected with an
+// error. A batch that does not end the client's query cycle is answered with
+// the error alone, and the client's messages must be discarded until it sends a
+// Sync, which is reported by the returned flag.
+func rejectRequest(request []byte, err error, txStatus byte) ([]byte, bool) {
+	pgError, ok := err.(*protocol.Error)
+
+	if !ok {
+		pgError = &protocol.Error{
+			Severity: protocol.ErrorSeverityError,
+			Code:     protocol.ErrorCodeInsufficientPrivilege,
+			Message:  err.Error(),
+		}
+	}
+
+	response := pgError.GetMessage()
+
+	if containsMessageType(request, protocol.QueryMessageType) ||
+		containsMessageType(request, protocol.SyncMessageType) {
+		return append(response, protocol.CreateReadyForQueryMessage(txStatus)...), false
+	}
+
+	return response, true
+}
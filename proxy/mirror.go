@@ -0,0 +1,180 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package proxy
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/crunchydata/crunchy-proxy/common"
+	"github.com/crunchydata/crunchy-proxy/config"
+	"github.com/crunchydata/crunchy-proxy/connect"
+	"github.com/crunchydata/crunchy-proxy/metrics"
+	"github.com/crunchydata/crunchy-proxy/protocol"
+	"github.com/crunchydata/crunchy-proxy/util/log"
+)
+
+/* The most statements of a transaction that are held to be mirrored together. */
+const maxMirroredStatements = 1000
+
+/* A transaction of a client to be run on the shadow cluster. */
+type mirrorJob struct {
+	part    partition
+	read    bool
+	queries []string
+}
+
+// startMirror starts the workers that run mirrored queries on the shadow
+// cluster, if mirroring is enabled. The size of the queue and the number of
+// workers are fixed when the proxy starts.
+func (p *Proxy) startMirror() {
+	mirror := config.GetMirrorConfig()
+
+	if !mirror.Enable {
+		return
+	}
+
+	p.mirrors = make(chan mirrorJob, mirror.Queue)
+
+	for i := 0; i < mirror.Workers; i++ {
+		go p.runMirror()
+	}
+}
+
+// mirrorQuery queues a simple query that a client has run to be run on the
+// shadow cluster too. In 'all' mode, the statements of a transaction are
+// collected in pending until the transaction ends, so that they are run
+// together on one backend, and the statements still pending are returned. In
+// 'read' mode, only read queries run outside a transaction are mirrored. If
+// the queue is full, then the queries are dropped rather than delaying the
+// client.
+func (p *Proxy) mirrorQuery(part partition, read bool, query string, txStatus byte, pending []string) []string {
+	mirror := config.GetMirrorConfig()
+
+	if p.mirrors == nil || !mirror.Enable || mirror.Cluster == part.cluster {
+		return nil
+	}
+
+	if mirror.Queries == common.MIRROR_QUERIES_READ {
+		if !read || txStatus != protocol.TransactionIdle || len(pending) > 0 {
+			return nil
+		}
+	}
+
+	pending = append(pending, query)
+
+	/* A transaction too long to hold is not mirrored at all. */
+	if len(pending) > maxMirroredStatements {
+		metrics.MirroredQueries.WithLabelValues(metrics.MirrorDropped).Add(float64(len(pending)))
+		return nil
+	}
+
+	if txStatus != protocol.TransactionIdle {
+		return pending
+	}
+
+	/* Only a read run on its own may go to a replica of the shadow cluster. */
+	job := mirrorJob{part: part, read: read && len(pending) == 1, queries: pending}
+
+	select {
+	case p.mirrors <- job:
+	default:
+		metrics.MirroredQueries.WithLabelValues(metrics.MirrorDropped).Add(float64(len(pending)))
+	}
+
+	return nil
+}
+
+/* runMirror runs the queued transactions on the shadow cluster. */
+func (p *Proxy) runMirror() {
+	for job := range p.mirrors {
+		if err := p.runMirrorJob(job); err != nil {
+			log.Debugf("Error mirroring %d queries for database '%s'", len(job.queries), job.part.database)
+			log.Debugf("Error: %s", err.Error())
+
+			metrics.MirroredQueries.WithLabelValues(metrics.MirrorFailed).Add(float64(len(job.queries)))
+		} else {
+			metrics.MirroredQueries.WithLabelValues(metrics.MirrorRun).Add(float64(len(job.queries)))
+		}
+	}
+}
+
+// runMirrorJob runs the queries of a transaction, one after the other, on a
+// backend of the shadow cluster for the same database and user, and discards
+// the responses. The backend is given up on if the queries do not complete
+// within the mirror timeout, and any transaction left open is rolled back
+// before it is returned to its pool.
+func (p *Proxy) runMirrorJob(job mirrorJob) error {
+	mirror := config.GetMirrorConfig()
+	shadow := partition{mirror.Cluster, job.part.database, job.part.username}
+
+	cp := p.getPool(job.read, shadow, "", "", nil)
+
+	if cp == nil {
+		return fmt.Errorf("no pool for database '%s' and user '%s' on cluster '%s'",
+			shadow.database, shadow.username, shadow.cluster)
+	}
+
+	backend, err := p.acquireBackend(cp, shadow)
+
+	if err != nil {
+		return err
+	}
+
+	buffer := connect.GetBuffer()
+	defer connect.PutBuffer(buffer)
+
+	backend.SetDeadline(time.Now().Add(time.Duration(mirror.Timeout) * time.Second))
+
+	var txStatus byte = protocol.TransactionIdle
+
+	for _, query := range job.queries {
+		if _, err = connect.Send(backend, protocol.CreateQueryMessage(query)); err != nil {
+			break
+		}
+
+		responses := &messageTracker{}
+		done := false
+
+		for !done && err == nil {
+			var message []byte
+			var length int
+
+			if message, length, err = connect.ReceiveBuffer(backend, buffer); err != nil {
+				break
+			}
+
+			responses.scan(message[:length], func(messageType byte, first byte) {
+				if messageType == protocol.ReadyForQueryMessageType {
+					txStatus = first
+					done = true
+				}
+			})
+		}
+
+		if err != nil {
+			break
+		}
+	}
+
+	if err != nil {
+		p.discardBackend(cp, backend, cp.Name, shadow)
+		return err
+	}
+
+	backend.SetDeadline(time.Time{})
+	p.releaseBackend(cp, backend, shadow, txStatus)
+
+	return nil
+}
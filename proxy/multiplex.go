@@ -0,0 +1,84 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package proxy
+
+/*
+ * With multiplexing, clients of 'session' mode pools give their backend back
+ * whenever they are idle, as in 'transaction' mode, so that many mostly idle
+ * sessions share a few backends. Session parameters and named prepared
+ * statements follow the client from one backend to the next, but some state
+ * only exists on the backend it was created on. A client that creates such
+ * state is pinned to its backend for the rest of its session.
+ */
+
+/* The functions that take advisory locks held until the session ends. */
+var sessionLockFunctions = map[string]bool{
+	"pg_advisory_lock":            true,
+	"pg_advisory_lock_shared":     true,
+	"pg_try_advisory_lock":        true,
+	"pg_try_advisory_lock_shared": true,
+}
+
+// pinsSession determines whether any of the statements of a query leaves
+// state on the backend that cannot be moved to another, returning what the
+// state is.
+func pinsSession(query string) (string, bool) {
+	statements, ok := splitStatements(query)
+
+	if !ok {
+		return "", false
+	}
+
+	for _, stmt := range statements {
+		if reason, ok := pinningStatement(stmt.keywords); ok {
+			return reason, true
+		}
+	}
+
+	return "", false
+}
+
+/* pinningStatement examines the words of a single statement. */
+func pinningStatement(keywords []string) (string, bool) {
+	switch keywords[0] {
+	case "prepare":
+		if len(keywords) > 1 && keywords[1] != "transaction" {
+			return "prepared a statement", true
+		}
+	case "declare":
+		for i := 1; i+1 < len(keywords); i++ {
+			if keywords[i] == "with" && keywords[i+1] == "hold" {
+				return "declared a cursor WITH HOLD", true
+			}
+		}
+	case "load":
+		return "loaded a library", true
+	}
+
+	for i, keyword := range keywords {
+		if sessionLockFunctions[keyword] {
+			return "took an advisory lock", true
+		}
+
+		/* CREATE TEMP TABLE, CREATE LOCAL TEMPORARY VIEW, SELECT INTO TEMP... */
+		if (keyword == "temp" || keyword == "temporary") && i > 0 {
+			switch keywords[i-1] {
+			case "create", "local", "global", "replace", "into":
+				return "created a temporary object", true
+			}
+		}
+	}
+
+	return "", false
+}
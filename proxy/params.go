@@ -0,0 +1,539 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package proxy
+
+import (
+	"net"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/crunchydata/crunchy-proxy/common"
+	"github.com/crunchydata/crunchy-proxy/config"
+	"github.com/crunchydata/crunchy-proxy/protocol"
+	"github.com/crunchydata/crunchy-proxy/util/log"
+)
+
+/*
+ * Restores a pool connection to the state it had when it was established,
+ * before the parameters of a session are replayed on it. The role and session
+ * authorization are not reset by 'RESET ALL'.
+ */
+const resetParameters = "SET SESSION AUTHORIZATION DEFAULT; RESET ROLE; RESET ALL"
+
+/* Resets all session state, including the session parameters. */
+const discardAll = "DISCARD ALL"
+
+/* Marks a pool connection whose parameters are not known. */
+const unknownParameters = "?"
+
+/* The session parameter with which a client pins its session to the master. */
+const routeParameter = "proxy.route"
+
+/*
+ * Parameters reported by the backend that cannot be set, or whose reported
+ * value cannot be used to set them.
+ */
+var untrackedParameters = map[string]bool{
+	"server_version":        true,
+	"server_encoding":       true,
+	"integer_datetimes":     true,
+	"is_superuser":          true,
+	"in_hot_standby":        true,
+	"session_authorization": true,
+	"search_path":           true,
+}
+
+/* Startup parameters that are not session parameters. */
+var startupOnlyParameters = map[string]bool{
+	"user":        true,
+	"database":    true,
+	"options":     true,
+	"replication": true,
+}
+
+var (
+	parameterName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.$]*$`)
+
+	discardAllStatement     = regexp.MustCompile(`(?is)^discard\s+all$`)
+	rollbackStatement       = regexp.MustCompile(`(?is)^(?:rollback|abort)(?:\s+(?:work|transaction))?(?:\s+and\s+(?:no\s+)?chain)?$`)
+	resetAllStatement       = regexp.MustCompile(`(?is)^reset\s+all$`)
+	resetAuthStatement      = regexp.MustCompile(`(?is)^reset\s+session\s+authorization$`)
+	resetStatement          = regexp.MustCompile(`(?is)^reset\s+([a-z_][a-z0-9_.$]*)$`)
+	setTimeZoneStatement    = regexp.MustCompile(`(?is)^set\s+(?:session\s+)?time\s+zone\s+(.+)$`)
+	setNamesStatement       = regexp.MustCompile(`(?is)^set\s+(?:session\s+)?names\s+(.+)$`)
+	setRoleStatement        = regexp.MustCompile(`(?is)^set\s+(?:session\s+)?role\s+(.+)$`)
+	setAuthStatement        = regexp.MustCompile(`(?is)^set\s+session\s+authorization\s+(.+)$`)
+	setTransactionStatement = regexp.MustCompile(`(?is)^set\s+session\s+characteristics\s+as\s+transaction\s+.+$`)
+	setStatement            = regexp.MustCompile(`(?is)^set\s+(?:session\s+)?([a-z_][a-z0-9_.$]*)(?:\s*=\s*|\s+to\s+)(.+)$`)
+)
+
+// parameterChange is a change to a session parameter made by a SET or RESET
+// statement. The statement is replayed to restore the parameter, and a reset
+// without a name resets all parameters. A discard also resets the session
+// authorization and role, and a rollback undoes the changes made earlier in
+// the transaction. A reported change is a value that the backend reported,
+// which is only recorded if the parameter is not already tracked.
+type parameterChange struct {
+	name      string
+	statement string
+	reset     bool
+	discard   bool
+	rollback  bool
+	reported  bool
+}
+
+/* The statements that may change the session parameters. */
+var parameterKeywords = map[string]bool{
+	"set":      true,
+	"reset":    true,
+	"discard":  true,
+	"rollback": true,
+	"abort":    true,
+}
+
+// getParameterChanges returns the changes to session parameters made by the
+// statements of a simple query. SET LOCAL is ignored as it only lasts until
+// the end of the transaction.
+func getParameterChanges(query string) []parameterChange {
+	var changes []parameterChange
+
+	statements, ok := splitStatements(query)
+
+	if !ok {
+		return nil
+	}
+
+	for _, stmt := range statements {
+		if !parameterKeywords[stmt.keywords[0]] {
+			continue
+		}
+
+		text := stripLeadingComments(stmt.text)
+
+		var match []string
+
+		switch {
+		case rollbackStatement.MatchString(text):
+			changes = append(changes, parameterChange{rollback: true})
+		case discardAllStatement.MatchString(text):
+			changes = append(changes, parameterChange{reset: true, discard: true})
+		case resetAllStatement.MatchString(text):
+			changes = append(changes, parameterChange{reset: true})
+		case resetAuthStatement.MatchString(text):
+			changes = append(changes, parameterChange{name: "session_authorization", reset: true})
+		case resetStatement.MatchString(text):
+			match = resetStatement.FindStringSubmatch(text)
+			changes = append(changes, parameterChange{name: strings.ToLower(match[1]), reset: true})
+		case setTimeZoneStatement.MatchString(text):
+			changes = append(changes, setChange("timezone", text,
+				setTimeZoneStatement.FindStringSubmatch(text)[1]))
+		case setNamesStatement.MatchString(text):
+			changes = append(changes, setChange("client_encoding", text,
+				setNamesStatement.FindStringSubmatch(text)[1]))
+		case setAuthStatement.MatchString(text):
+			changes = append(changes, setChange("session_authorization", text,
+				setAuthStatement.FindStringSubmatch(text)[1]))
+		case setTransactionStatement.MatchString(text):
+			changes = append(changes, parameterChange{name: "transaction_characteristics", statement: text})
+		case setRoleStatement.MatchString(text):
+			changes = append(changes, setChange("role", text,
+				setRoleStatement.FindStringSubmatch(text)[1]))
+		case setStatement.MatchString(text):
+			match = setStatement.FindStringSubmatch(text)
+			changes = append(changes, setChange(strings.ToLower(match[1]), text, match[2]))
+		}
+	}
+
+	return changes
+}
+
+/* Setting a parameter to its default is the same as resetting it. */
+func setChange(name string, statement string, value string) parameterChange {
+	value = strings.ToLower(strings.TrimSpace(value))
+
+	if value == "default" || (name == "timezone" && value == "local") ||
+		(name == "role" && value == "none") {
+		return parameterChange{name: name, reset: true}
+	}
+
+	return parameterChange{name: name, statement: statement}
+}
+
+func stripLeadingComments(text string) string {
+	for {
+		text = strings.TrimSpace(text)
+
+		switch {
+		case strings.HasPrefix(text, "--"):
+			if i := strings.Index(text, "\n"); i >= 0 {
+				text = text[i+1:]
+			} else {
+				return ""
+			}
+		case strings.HasPrefix(text, "/*"):
+			if i := strings.Index(text, "*/"); i >= 0 {
+				text = text[i+2:]
+			} else {
+				return ""
+			}
+		default:
+			return text
+		}
+	}
+}
+
+/* Create a statement that sets a parameter to a literal value. */
+func setLiteral(name string, value string) string {
+	return "SET " + name + " TO '" + strings.Replace(value, "'", "''", -1) + "'"
+}
+
+// setStartupParameters records the parameters that the client connected with
+// as session parameters, so that they are also applied to pool connections.
+// Settings given with '-c' in the 'options' parameter are recorded as well,
+// unless the parameter is also given on its own.
+func (s *session) setStartupParameters(parameters map[string]string) {
+	for name, value := range parseOptions(parameters["options"]) {
+		if _, ok := parameters[name]; ok || !parameterName.MatchString(name) {
+			continue
+		}
+
+		s.parameters[strings.ToLower(name)] = setLiteral(name, value)
+	}
+
+	for name, value := range parameters {
+		if startupOnlyParameters[name] || !parameterName.MatchString(name) ||
+			strings.HasPrefix(name, protocolOptionPrefix) {
+			continue
+		}
+
+		s.parameters[strings.ToLower(name)] = setLiteral(name, value)
+	}
+}
+
+// startupSettings returns the startup parameters along with the settings
+// given with '-c' in the 'options' parameter, unless the setting is also given
+// on its own.
+func startupSettings(parameters map[string]string) map[string]string {
+	settings := parseOptions(parameters["options"])
+
+	for name, value := range parameters {
+		settings[name] = value
+	}
+
+	return settings
+}
+
+// parseOptions returns the settings in the 'options' startup parameter, which
+// are given as '-c name=value' or '--name=value'. As with PostgreSQL, the
+// options are separated by white space, and a backslash includes the next
+// character in an option. Other command line switches are ignored.
+func parseOptions(options string) map[string]string {
+	var args []string
+	var arg []rune
+	var escaped, started bool
+
+	for _, r := range options {
+		switch {
+		case escaped:
+			arg = append(arg, r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+			started = true
+		case unicode.IsSpace(r):
+			if started {
+				args = append(args, string(arg))
+				arg = nil
+				started = false
+			}
+
+			continue
+		default:
+			arg = append(arg, r)
+		}
+
+		started = true
+	}
+
+	if started {
+		args = append(args, string(arg))
+	}
+
+	settings := make(map[string]string)
+
+	for i := 0; i < len(args); i++ {
+		var setting string
+
+		switch {
+		case args[i] == "-c" && i+1 < len(args):
+			i++
+			setting = args[i]
+		case strings.HasPrefix(args[i], "-c"):
+			setting = args[i][2:]
+		case strings.HasPrefix(args[i], "--"):
+			setting = args[i][2:]
+		default:
+			continue
+		}
+
+		/* PostgreSQL accepts dashes in place of underscores in the name. */
+		if name := strings.SplitN(setting, "=", 2); len(name) == 2 {
+			settings[strings.Replace(name[0], "-", "_", -1)] = name[1]
+		}
+	}
+
+	return settings
+}
+
+// overrideStartupParameters applies the startup parameters from the
+// configuration to those the client connected with, replacing any the client
+// sent. In each value, '${client_addr}' is replaced with the address of the
+// client, and '${name}' with the value the client sent for the parameter
+// 'name', if any. The user and database cannot be overridden.
+func overrideStartupParameters(client net.Conn, parameters map[string]string) bool {
+	overrides := config.GetProxyConfig().Parameters
+
+	if len(overrides) == 0 {
+		return false
+	}
+
+	address := client.RemoteAddr().String()
+
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		address = host
+	}
+
+	/* Values refer to the parameters as the client sent them. */
+	original := make(map[string]string, len(parameters))
+
+	for name, value := range parameters {
+		original[name] = value
+	}
+
+	for name, value := range overrides {
+		if name == "user" || name == "database" {
+			continue
+		}
+
+		parameters[name] = os.Expand(value, func(key string) string {
+			if key == "client_addr" {
+				return address
+			}
+
+			return original[key]
+		})
+	}
+
+	return true
+}
+
+// pinnedToPrimary returns whether the client pinned its session to the master
+// with 'SET proxy.route = primary'. The pin holds until the parameter is reset
+// or set to any other value.
+func (s *session) pinnedToPrimary() bool {
+	statement, ok := s.parameters[routeParameter]
+	if !ok {
+		return false
+	}
+
+	match := setStatement.FindStringSubmatch(statement)
+	if match == nil {
+		return false
+	}
+
+	value := strings.ToLower(strings.Trim(strings.TrimSpace(match[2]), "'\""))
+
+	return value == primaryAnnotationString || value == common.NODE_ROLE_MASTER
+}
+
+// stageParameterChanges records the changes made by a client's statements
+// that succeeded, and the values reported by the backend. They only take
+// effect once the transaction they were made in ends, as a rollback undoes
+// them. Only the last value reported for a parameter is kept, as the backend
+// reports the value again when a rollback restores it.
+func (s *session) stageParameterChanges(changes []parameterChange) {
+	for _, change := range changes {
+		switch {
+		case change.rollback:
+			s.staged = nil
+		case change.reported:
+			staged := s.staged[:0]
+
+			for _, other := range s.staged {
+				if !other.reported || other.name != change.name {
+					staged = append(staged, other)
+				}
+			}
+
+			s.staged = append(staged, change)
+		default:
+			s.staged = append(s.staged, change)
+		}
+	}
+}
+
+// endTransaction applies the staged changes once the backend reports that
+// the transaction has committed, and drops them if it has failed, in which
+// case it can only be rolled back. It returns whether the parameters of the
+// session changed.
+func (s *session) endTransaction(txStatus byte) bool {
+	changes := s.staged
+
+	switch txStatus {
+	case protocol.TransactionIdle:
+		s.staged = nil
+		s.applyParameterChanges(changes)
+		return len(changes) > 0
+	case protocol.TransactionFailed:
+		s.staged = nil
+	}
+
+	return false
+}
+
+// applyParameterChanges records the changes made by a client's statements.
+// 'RESET ALL' keeps the session authorization and role, which only 'DISCARD
+// ALL' resets as well.
+func (s *session) applyParameterChanges(changes []parameterChange) {
+	for _, change := range changes {
+		switch {
+		case change.discard:
+			s.parameters = make(map[string]string)
+		case change.reset && change.name == "":
+			for name := range s.parameters {
+				if name != "session_authorization" && name != "role" {
+					delete(s.parameters, name)
+				}
+			}
+		case change.reported:
+			if _, ok := s.parameters[change.name]; !ok {
+				s.parameters[change.name] = change.statement
+			}
+		case change.reset:
+			delete(s.parameters, change.name)
+
+			/* Changing the session authorization also resets the role. */
+			if change.name == "session_authorization" {
+				delete(s.parameters, "role")
+			}
+		default:
+			s.parameters[change.name] = change.statement
+
+			if change.name == "session_authorization" {
+				delete(s.parameters, "role")
+			}
+		}
+	}
+}
+
+// getReportedChange returns the change that records a parameter reported by
+// the backend in a ParameterStatus message. This catches parameters that were
+// changed by means other than a simple query, such as a prepared SET
+// statement. Like other changes, it must be staged until the transaction ends.
+func getReportedChange(body []byte) (parameterChange, bool) {
+	name, value, ok := parseParameterStatus(body)
+
+	if !ok {
+		return parameterChange{}, false
+	}
+
+	key := strings.ToLower(name)
+
+	if untrackedParameters[key] || !parameterName.MatchString(name) {
+		return parameterChange{}, false
+	}
+
+	return parameterChange{name: key, statement: setLiteral(name, value), reported: true}, true
+}
+
+// parameterStatements returns the statements that restore the parameters of
+// the session on a pool connection. The session authorization must be set
+// before the role, as setting it resets the role.
+func (s *session) parameterStatements() string {
+	names := make([]string, 0, len(s.parameters))
+
+	for name := range s.parameters {
+		if name != "session_authorization" && name != "role" {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+
+	for _, name := range []string{"role", "session_authorization"} {
+		if _, ok := s.parameters[name]; ok {
+			names = append([]string{name}, names...)
+		}
+	}
+
+	statements := make([]string, 0, len(names))
+
+	for _, name := range names {
+		statements = append(statements, s.parameters[name])
+	}
+
+	return strings.Join(statements, "; ")
+}
+
+// syncParameters records that the backend held by the client has the
+// session's current parameters.
+func (s *session) syncParameters(backend net.Conn) {
+	if conn, ok := backend.(*backendConn); ok {
+		conn.parameters = s.parameterStatements()
+	}
+}
+
+// replayParameters applies the parameters of the session to a pool
+// connection that has just been assigned to the client. The connection is
+// first reset, so that no settings are carried over from the client that used
+// it before. Nothing is sent if the connection already has the session's
+// parameters.
+func (p *Proxy) replayParameters(s *session, backend net.Conn) {
+	conn, ok := backend.(*backendConn)
+
+	if !ok {
+		return
+	}
+
+	statements := s.parameterStatements()
+
+	if statements == conn.parameters {
+		return
+	}
+
+	query := resetParameters
+
+	if statements != "" {
+		query += "; " + statements
+	}
+
+	log.Debugf("Replaying session parameters on backend %s", backend.RemoteAddr())
+
+	/*
+	 * If the parameters could not be applied, then the connection is in an
+	 * unknown state and must be reset for the next client as well.
+	 */
+	if err := execute(backend, query); err != nil {
+		log.Errorf("Error replaying session parameters on backend %s", backend.RemoteAddr())
+		log.Errorf("Error: %s", err.Error())
+		conn.parameters = unknownParameters
+		return
+	}
+
+	conn.parameters = statements
+}
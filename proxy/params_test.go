@@ -0,0 +1,212 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/crunchydata/crunchy-proxy/protocol"
+)
+
+func TestGetParameterChanges(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected []parameterChange
+	}{
+		{query: "select 1"},
+		{query: "set local work_mem = '64MB'"},
+		{
+			query:    "SET work_mem = '64MB'",
+			expected: []parameterChange{{name: "work_mem", statement: "SET work_mem = '64MB'"}},
+		},
+		{
+			query:    "set session TimeZone to 'UTC'",
+			expected: []parameterChange{{name: "timezone", statement: "set session TimeZone to 'UTC'"}},
+		},
+		{
+			query:    "set time zone 'UTC'",
+			expected: []parameterChange{{name: "timezone", statement: "set time zone 'UTC'"}},
+		},
+		{
+			query:    "set names 'UTF8'",
+			expected: []parameterChange{{name: "client_encoding", statement: "set names 'UTF8'"}},
+		},
+		{query: "set role none", expected: []parameterChange{{name: "role", reset: true}}},
+		{query: "set work_mem to default", expected: []parameterChange{{name: "work_mem", reset: true}}},
+		{query: "reset work_mem", expected: []parameterChange{{name: "work_mem", reset: true}}},
+		{query: "reset all", expected: []parameterChange{{reset: true}}},
+		{query: "discard all", expected: []parameterChange{{reset: true, discard: true}}},
+		{
+			query: "begin; set work_mem = '64MB'; rollback",
+			expected: []parameterChange{
+				{name: "work_mem", statement: "set work_mem = '64MB'"},
+				{rollback: true},
+			},
+		},
+		{
+			query:    "/* comment */ set work_mem = '64MB'",
+			expected: []parameterChange{{name: "work_mem", statement: "set work_mem = '64MB'"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			if changes := getParameterChanges(test.query); !reflect.DeepEqual(changes, test.expected) {
+				t.Fatalf("expected %v, got %v", test.expected, changes)
+			}
+		})
+	}
+}
+
+/* Build the body of a ParameterStatus message. */
+func parameterStatusBody(name string, value string) []byte {
+	return protocol.CreateParameterStatusMessage(name, value)[5:]
+}
+
+/* Get the change that records a reported parameter. */
+func reportedChange(t *testing.T, name string, value string) parameterChange {
+	change, ok := getReportedChange(parameterStatusBody(name, value))
+
+	if !ok {
+		t.Fatalf("expected '%s' to be tracked", name)
+	}
+
+	return change
+}
+
+func TestGetReportedChange(t *testing.T) {
+	change := reportedChange(t, "DateStyle", "ISO, MDY")
+
+	if change.name != "datestyle" || change.statement != "SET DateStyle TO 'ISO, MDY'" || !change.reported {
+		t.Fatalf("unexpected change %v", change)
+	}
+
+	for _, name := range []string{"server_version", "is_superuser", "bad name"} {
+		if _, ok := getReportedChange(parameterStatusBody(name, "x")); ok {
+			t.Fatalf("expected '%s' not to be tracked", name)
+		}
+	}
+}
+
+func TestParameterStaging(t *testing.T) {
+	set := func(query string) []parameterChange { return getParameterChanges(query) }
+
+	tests := []struct {
+		name     string
+		steps    func(t *testing.T, s *session)
+		expected map[string]string
+	}{
+		{
+			name: "outside a transaction",
+			steps: func(t *testing.T, s *session) {
+				s.stageParameterChanges(set("set work_mem = '64MB'"))
+				s.endTransaction(protocol.TransactionIdle)
+			},
+			expected: map[string]string{"work_mem": "set work_mem = '64MB'"},
+		},
+		{
+			name: "committed transaction",
+			steps: func(t *testing.T, s *session) {
+				s.stageParameterChanges(set("set work_mem = '64MB'"))
+				s.endTransaction(protocol.TransactionActive)
+				s.endTransaction(protocol.TransactionIdle)
+			},
+			expected: map[string]string{"work_mem": "set work_mem = '64MB'"},
+		},
+		{
+			name: "rolled back transaction",
+			steps: func(t *testing.T, s *session) {
+				s.stageParameterChanges(set("set work_mem = '64MB'"))
+				s.endTransaction(protocol.TransactionActive)
+				s.stageParameterChanges(set("rollback"))
+				s.endTransaction(protocol.TransactionIdle)
+			},
+			expected: map[string]string{},
+		},
+		{
+			name: "failed transaction",
+			steps: func(t *testing.T, s *session) {
+				s.stageParameterChanges(set("set work_mem = '64MB'"))
+				s.endTransaction(protocol.TransactionFailed)
+				s.endTransaction(protocol.TransactionIdle)
+			},
+			expected: map[string]string{},
+		},
+		{
+			name: "reported value of a rolled back transaction",
+			steps: func(t *testing.T, s *session) {
+				s.stageParameterChanges([]parameterChange{reportedChange(t, "DateStyle", "SQL, DMY")})
+				s.endTransaction(protocol.TransactionActive)
+				s.stageParameterChanges(set("rollback"))
+				s.stageParameterChanges([]parameterChange{reportedChange(t, "DateStyle", "ISO, MDY")})
+				s.endTransaction(protocol.TransactionIdle)
+			},
+			expected: map[string]string{"datestyle": "SET DateStyle TO 'ISO, MDY'"},
+		},
+		{
+			name: "values reported in one query",
+			steps: func(t *testing.T, s *session) {
+				s.stageParameterChanges([]parameterChange{
+					reportedChange(t, "DateStyle", "SQL, DMY"),
+					reportedChange(t, "DateStyle", "ISO, MDY"),
+				})
+				s.endTransaction(protocol.TransactionIdle)
+			},
+			expected: map[string]string{"datestyle": "SET DateStyle TO 'ISO, MDY'"},
+		},
+		{
+			name: "reported value of a tracked parameter",
+			steps: func(t *testing.T, s *session) {
+				s.stageParameterChanges(set("set datestyle = 'SQL'"))
+				s.stageParameterChanges([]parameterChange{reportedChange(t, "DateStyle", "SQL, DMY")})
+				s.endTransaction(protocol.TransactionIdle)
+			},
+			expected: map[string]string{"datestyle": "set datestyle = 'SQL'"},
+		},
+		{
+			name: "reset all keeps the role",
+			steps: func(t *testing.T, s *session) {
+				s.stageParameterChanges(set("set role app; set work_mem = '64MB'; reset all"))
+				s.endTransaction(protocol.TransactionIdle)
+			},
+			expected: map[string]string{"role": "set role app"},
+		},
+		{
+			name: "discard all",
+			steps: func(t *testing.T, s *session) {
+				s.stageParameterChanges(set("set role app; discard all"))
+				s.endTransaction(protocol.TransactionIdle)
+			},
+			expected: map[string]string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newTestProxy().newSession(nil, partition{})
+
+			test.steps(t, s)
+
+			if !reflect.DeepEqual(s.parameters, test.expected) {
+				t.Fatalf("expected %v, got %v", test.expected, s.parameters)
+			}
+
+			if len(s.staged) != 0 {
+				t.Fatalf("expected no staged changes, have %v", s.staged)
+			}
+		})
+	}
+}
@@ -16,7 +16,9 @@ limitations under the License.
 package proxy
 
 import (
+	"encoding/hex"
 	"strings"
+	"time"
 
 	"github.com/crunchydata/crunchy-proxy/protocol"
 )
@@ -25,15 +27,9 @@ import (
 // assume a write if there is no comment in the SQL
 // or if there are no keywords in the comment
 // return (write, start, finish) booleans
-func getAnnotations(m []byte) map[AnnotationType]bool {
-	message := protocol.NewMessageBuffer(m)
+func getAnnotations(query string) map[AnnotationType]bool {
 	annotations := make(map[AnnotationType]bool, 0)
 
-	/* Get the query string */
-	message.ReadByte()  // read past the message type
-	message.ReadInt32() // read past the message length
-	query, _ := message.ReadString()
-
 	/* Find the start and end position of the annotations. */
 	startPos := strings.Index(query, AnnotationStartToken)
 	endPos := strings.Index(query, AnnotationEndToken)
@@ -50,7 +46,32 @@ func getAnnotations(m []byte) map[AnnotationType]bool {
 	keywords := strings.Split(query[startPos+2:endPos], ",")
 
 	for i := 0; i < len(keywords); i++ {
-		switch strings.TrimSpace(keywords[i]) {
+		keyword := strings.TrimSpace(keywords[i])
+
+		/* The cache annotation takes options, e.g. 'cache: ttl=30s'. */
+		if name, _ := splitAnnotation(keyword); name == cacheAnnotationString {
+			annotations[CacheAnnotation] = true
+			continue
+		}
+
+		/* The shard key annotation takes the key, e.g. 'shard_key: 42'. */
+		if name, _ := splitAnnotation(keyword); name == shardKeyAnnotationString {
+			annotations[ShardKeyAnnotation] = true
+			continue
+		}
+
+		/*
+		 * A block started with 'start: primary' is routed to the master, for
+		 * read-your-writes sequences that span multiple statements.
+		 */
+		if name, option := splitAnnotation(keyword); name == startAnnotationString &&
+			option == primaryAnnotationString {
+			annotations[StartAnnotation] = true
+			annotations[PrimaryAnnotation] = true
+			continue
+		}
+
+		switch keyword {
 		case readAnnotationString:
 			annotations[ReadAnnotation] = true
 		case startAnnotationString:
@@ -62,3 +83,182 @@ func getAnnotations(m []byte) map[AnnotationType]bool {
 
 	return annotations
 }
+
+// getCacheTTL gets the time for which the results of a query may be cached,
+// as given by a 'cache: ttl=<duration>' annotation, e.g. 'cache: ttl=30s'.
+// It returns zero if the query is not annotated or the duration is invalid.
+func getCacheTTL(query string) time.Duration {
+	startPos := strings.Index(query, AnnotationStartToken)
+	endPos := strings.Index(query, AnnotationEndToken)
+
+	if startPos < 0 || endPos < startPos {
+		return 0
+	}
+
+	for _, keyword := range strings.Split(query[startPos+2:endPos], ",") {
+		name, options := splitAnnotation(strings.TrimSpace(keyword))
+
+		if name != cacheAnnotationString {
+			continue
+		}
+
+		for _, option := range strings.Fields(options) {
+			if !strings.HasPrefix(option, "ttl=") {
+				continue
+			}
+
+			ttl, err := time.ParseDuration(strings.TrimPrefix(option, "ttl="))
+
+			if err != nil || ttl < 0 {
+				return 0
+			}
+
+			return ttl
+		}
+	}
+
+	return 0
+}
+
+// getShardKey returns the key of a query annotated with 'shard_key', e.g.
+// '/* shard_key: 42 */', and whether it has one.
+func getShardKey(query string) (string, bool) {
+	startPos := strings.Index(query, AnnotationStartToken)
+	endPos := strings.Index(query, AnnotationEndToken)
+
+	if startPos < 0 || endPos < startPos {
+		return "", false
+	}
+
+	for _, keyword := range strings.Split(query[startPos+2:endPos], ",") {
+		name, key := splitAnnotation(strings.TrimSpace(keyword))
+
+		if name == shardKeyAnnotationString && key != "" {
+			return key, true
+		}
+	}
+
+	return "", false
+}
+
+/* splitAnnotation splits an annotation into its name and its options. */
+func splitAnnotation(keyword string) (string, string) {
+	if i := strings.Index(keyword, ":"); i >= 0 {
+		return strings.TrimSpace(keyword[:i]), strings.TrimSpace(keyword[i+1:])
+	}
+
+	return keyword, ""
+}
+
+// getQuery gets the query string from a simple Query message.
+func getQuery(m []byte) string {
+	message := protocol.NewMessageBuffer(m)
+
+	/* Get the query string */
+	message.ReadByte()  // read past the message type
+	message.ReadInt32() // read past the message length
+	query, _ := message.ReadString()
+
+	return query
+}
+
+// getParse gets the prepared statement name and query string from a Parse
+// message. An empty name refers to the unnamed prepared statement.
+func getParse(m []byte) (string, string) {
+	message := protocol.NewMessageBuffer(m)
+
+	message.ReadByte()  // read past the message type
+	message.ReadInt32() // read past the message length
+	name, _ := message.ReadString()
+	query, _ := message.ReadString()
+
+	return name, query
+}
+
+// getBind gets the prepared statement name and parameter values from a Bind
+// message. Values sent in binary format are returned in hex, prefixed with
+// '\x', and null values are returned as nil.
+func getBind(m []byte) (string, []*string) {
+	message := protocol.NewMessageBuffer(m)
+
+	message.ReadByte()   // read past the message type
+	message.ReadInt32()  // read past the message length
+	message.ReadString() // read past the portal name
+	name, _ := message.ReadString()
+
+	formatCount, _ := message.ReadInt16()
+
+	if formatCount < 0 {
+		return name, nil
+	}
+
+	formats := make([]int16, formatCount)
+
+	for i := range formats {
+		formats[i], _ = message.ReadInt16()
+	}
+
+	valueCount, _ := message.ReadInt16()
+
+	if valueCount < 0 {
+		return name, nil
+	}
+
+	values := make([]*string, 0, valueCount)
+
+	for i := 0; i < int(valueCount); i++ {
+		length, err := message.ReadInt32()
+
+		if err != nil {
+			break
+		}
+
+		if length < 0 {
+			values = append(values, nil)
+			continue
+		}
+
+		data, err := message.ReadBytes(int(length))
+
+		if err != nil {
+			break
+		}
+
+		var format int16
+
+		if len(formats) == 1 {
+			format = formats[0]
+		} else if i < len(formats) {
+			format = formats[i]
+		}
+
+		value := string(data)
+
+		if format != 0 {
+			value = "\\x" + hex.EncodeToString(data)
+		}
+
+		values = append(values, &value)
+	}
+
+	return name, values
+}
+
+// getMessages splits a buffer read from a connection into the individual
+// messages that it contains.
+func getMessages(buffer []byte) [][]byte {
+	var messages [][]byte
+
+	for start := 0; start+5 <= len(buffer); {
+		end := start + int(protocol.GetMessageLength(buffer[start:])) + 1
+
+		if end > len(buffer) || end <= start {
+			end = len(buffer)
+		}
+
+		messages = append(messages, buffer[start:end])
+		start = end
+	}
+
+	return messages
+}
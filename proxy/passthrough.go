@@ -0,0 +1,221 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+import (
+	"io"
+	"net"
+	"time"
+
+	"github.com/crunchydata/crunchy-proxy/audit"
+	"github.com/crunchydata/crunchy-proxy/capture"
+	"github.com/crunchydata/crunchy-proxy/common"
+	"github.com/crunchydata/crunchy-proxy/config"
+	"github.com/crunchydata/crunchy-proxy/connect"
+	"github.com/crunchydata/crunchy-proxy/metrics"
+	"github.com/crunchydata/crunchy-proxy/pool"
+	"github.com/crunchydata/crunchy-proxy/protocol"
+	"github.com/crunchydata/crunchy-proxy/util/log"
+)
+
+/* The Terminate message that a client sends when it disconnects. */
+var terminateMessage = []byte{protocol.TerminateMessageType, 0, 0, 0, 4}
+
+// canPassThrough returns whether the rest of a session may be relayed in the
+// reduced-parsing mode of passThrough. This requires pass-through to be enabled and the
+// backend to be held until the client disconnects, and nothing that depends
+// on the messages, such as auditing, capture, timeouts, the query firewall,
+// middleware and plugins or the renaming of prepared statements, to be in use.
+// Nor may the proxy be paused or in read-only mode, which a client that passes
+// through would no longer notice.
+func (p *Proxy) canPassThrough(s *session, cp *pool.Pool, part partition) bool {
+	if !config.GetPassThrough() || cp.Mode != common.POOL_MODE_SESSION || config.GetMultiplex() {
+		return false
+	}
+
+	proxyConfig := config.GetProxyConfig()
+
+	return !audit.Enabled() && !capture.Enabled() &&
+		proxyConfig.ClientIdleTimeout <= 0 &&
+		proxyConfig.IdleInTransactionTimeout <= 0 &&
+		p.queryTimeout(part) == 0 &&
+		config.GetRelayTimeout() == 0 &&
+		!firewallApplies(part) &&
+		!hasMiddleware() &&
+		!p.isPaused() &&
+		!p.isReadOnly() &&
+		len(s.statements) == 0
+}
+
+// passThrough relays messages between the client and its backend, which is
+// ready for a query with the given transaction status, until either of them
+// disconnects. This is a reduced-parsing mode rather than a splice: every
+// byte is still read and written by the proxy, but only message headers are
+// followed. Responses are copied from the backend to the client as they
+// arrive, with the process ID of notifications replaced as for other clients,
+// and messages from the client are followed only closely enough to keep its
+// Terminate message from closing the backend. It returns the transaction status of the backend and true if the
+// backend is between responses and can be returned to its pool, which is the
+// case when the client sent Terminate after the last response it waited for
+// had arrived in full. Otherwise, the backend may still be sending responses
+// that nobody reads, and must be discarded.
+func passThrough(client net.Conn, backend net.Conn, txStatus byte, notifications *notificationRewriter) (byte, bool) {
+	log.Debugf("Client: %s - passing through to backend %s", client.RemoteAddr(),
+		backend.RemoteAddr())
+
+	source := backend
+
+	if conn, ok := backend.(*backendConn); ok {
+		source = conn.Conn
+	}
+
+	done := make(chan struct{})
+	var sent int64
+
+	responses := &responseWriter{
+		client:        client,
+		notifications: notifications,
+		ready:         true,
+		txStatus:      txStatus,
+	}
+
+	go func() {
+		defer close(done)
+
+		sent, _ = io.Copy(responses, source)
+
+		/* Stop reading from the client once the backend has gone away. */
+		client.SetReadDeadline(time.Now())
+	}()
+
+	requests := relayClient(client, backend)
+
+	/* Stop copying responses once the client has gone away. */
+	backend.SetReadDeadline(time.Now())
+	<-done
+
+	client.SetReadDeadline(time.Time{})
+	backend.SetReadDeadline(time.Time{})
+
+	metrics.BytesProxied.WithLabelValues(metrics.DirectionClientToBackend).Add(float64(requests.received))
+	metrics.BytesProxied.WithLabelValues(metrics.DirectionBackendToClient).Add(float64(sent))
+
+	/*
+	 * The session may have changed parameters and prepared statements that the
+	 * proxy has not seen, so they must be reset for the next client.
+	 */
+	if conn, ok := backend.(*backendConn); ok {
+		conn.parameters = unknownParameters
+	}
+
+	idle := requests.terminated && requests.synced && responses.ready &&
+		responses.readies == requests.syncs
+
+	return responses.txStatus, idle
+}
+
+// responseWriter writes the responses of the backend to the client, and
+// follows their message boundaries to know whether the backend has finished
+// responding. A chunk read from the backend counts as received even if the
+// client does not accept it.
+type responseWriter struct {
+	client        net.Conn
+	notifications *notificationRewriter // replaces the process ID of notifications
+	messages      messageTracker
+	readies       int64 // the number of ReadyForQuery messages received
+	ready         bool  // the last message received is a ReadyForQuery message
+	txStatus      byte  // the transaction status of the last ReadyForQuery message
+}
+
+func (w *responseWriter) Write(chunk []byte) (int, error) {
+	received := len(chunk)
+
+	/* The rewriter may hold back the start of a message until it is complete. */
+	chunk = w.notifications.rewrite(chunk)
+
+	w.messages.scan(chunk, func(messageType byte, first byte) {
+		w.ready = messageType == protocol.ReadyForQueryMessageType
+
+		if w.ready {
+			w.readies++
+			w.txStatus = first
+		}
+	})
+
+	if w.messages.partial() || len(w.notifications.held) > 0 {
+		w.ready = false
+	}
+
+	if _, err := w.client.Write(chunk); err != nil {
+		return 0, err
+	}
+
+	return received, nil
+}
+
+/* clientStream describes the messages that the client passed through. */
+type clientStream struct {
+	received   int64 // bytes
+	syncs      int64 // the number of Query and Sync messages, each answered by ReadyForQuery
+	synced     bool  // the last message before Terminate is a Query or Sync message
+	terminated bool  // the client sent Terminate
+}
+
+/*
+ * relayClient sends messages from the client to the backend until the client
+ * disconnects or sends Terminate.
+ */
+func relayClient(client net.Conn, backend net.Conn) clientStream {
+	stream := clientStream{synced: true}
+
+	buffer := connect.GetBuffer()
+	defer connect.PutBuffer(buffer)
+
+	messages := &messageTracker{}
+
+	for {
+		message, length, err := connect.ReceiveBuffer(client, buffer)
+
+		if length > 0 {
+			messages.scan(message[:length], func(messageType byte, first byte) {
+				switch messageType {
+				case protocol.TerminateMessageType:
+					stream.terminated = true
+				case protocol.QueryMessageType, protocol.SyncMessageType:
+					stream.syncs++
+					stream.synced = true
+				default:
+					stream.synced = false
+				}
+			})
+
+			/* Terminate is the last message a client sends. */
+			if stream.terminated && length >= len(terminateMessage) {
+				length -= len(terminateMessage)
+			}
+
+			if _, err := connect.Send(backend, message[:length]); err != nil {
+				stream.terminated = false
+				return stream
+			}
+
+			stream.received += int64(length)
+		}
+
+		if stream.terminated || err != nil {
+			return stream
+		}
+	}
+}
@@ -0,0 +1,154 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/crunchydata/crunchy-proxy/common"
+	"github.com/crunchydata/crunchy-proxy/pool"
+	"github.com/crunchydata/crunchy-proxy/protocol"
+)
+
+/* Middleware that lets every batch through unchanged. */
+type passMiddleware struct{}
+
+func (passMiddleware) Handle(request *Request) ([]byte, error) {
+	return nil, nil
+}
+
+func TestCanPassThrough(t *testing.T) {
+	const passThroughConfig = `
+pool:
+  passthrough: true
+`
+
+	tests := []struct {
+		name   string
+		config string
+		mode   string
+		setup  func(p *Proxy, s *session)
+		passes bool
+	}{
+		{name: "session mode", config: passThroughConfig, mode: common.POOL_MODE_SESSION, passes: true},
+		{name: "disabled", config: "pool:\n  passthrough: false\n", mode: common.POOL_MODE_SESSION},
+		{name: "transaction mode", config: passThroughConfig, mode: common.POOL_MODE_TRANSACTION},
+		{name: "multiplexed", config: passThroughConfig + "  multiplex: true\n", mode: common.POOL_MODE_SESSION},
+		{
+			name:   "firewall rule",
+			config: passThroughConfig + "firewall:\n  enable: true\n  rules:\n    - pattern: drop\n",
+			mode:   common.POOL_MODE_SESSION,
+		},
+		{
+			name:   "prepared statements",
+			config: passThroughConfig,
+			mode:   common.POOL_MODE_SESSION,
+			setup:  func(p *Proxy, s *session) { p.newStatement(s, "s1") },
+		},
+		{
+			name:   "read-only mode",
+			config: passThroughConfig,
+			mode:   common.POOL_MODE_SESSION,
+			setup:  func(p *Proxy, s *session) { p.SetReadOnly(true) },
+		},
+		{
+			name:   "paused",
+			config: passThroughConfig,
+			mode:   common.POOL_MODE_SESSION,
+			setup:  func(p *Proxy, s *session) { p.resumed = make(chan struct{}) },
+		},
+		{
+			name:   "middleware",
+			config: passThroughConfig,
+			mode:   common.POOL_MODE_SESSION,
+			setup: func(p *Proxy, s *session) {
+				Use(passMiddleware{})
+
+				t.Cleanup(func() {
+					middlewareLock.Lock()
+					middleware = nil
+					middlewareLock.Unlock()
+				})
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			loadTestConfig(t, test.config)
+
+			p := newTestProxy()
+			s := p.newSession(nil, partition{database: "db", username: "app"})
+			cp := pool.NewPool("node", "db", "app", 1, test.mode)
+
+			if test.setup != nil {
+				test.setup(p, s)
+			}
+
+			if passes := p.canPassThrough(s, cp, partition{database: "db", username: "app"}); passes != test.passes {
+				t.Fatalf("expected the session to pass through: %v", test.passes)
+			}
+		})
+	}
+}
+
+func TestResponseWriterNotifications(t *testing.T) {
+	notification := appendLength(newMessage(protocol.NotificationMessageType), 0, 0, 0, 7)
+	notification = appendLength(notification, []byte("channel\x00payload\x00")...)
+	ready := protocol.CreateReadyForQueryMessage(protocol.TransactionIdle)
+	stream := append(append([]byte(nil), notification...), ready...)
+
+	for _, offset := range []int{3, 7, len(notification), len(notification) + 2} {
+		client, peer := net.Pipe()
+		received := make(chan []byte)
+
+		go func() {
+			data, _ := io.ReadAll(peer)
+			received <- data
+		}()
+
+		w := &responseWriter{
+			client: client,
+			notifications: &notificationRewriter{
+				resolve: func(processID int32) int32 { return processID + 100 },
+			},
+		}
+
+		for _, chunk := range splitAt(append([]byte(nil), stream...), offset) {
+			if n, err := w.Write(chunk); err != nil || n != len(chunk) {
+				t.Fatalf("split at %d: expected %d bytes to be written, got %d, %v", offset, len(chunk), n, err)
+			}
+		}
+
+		client.Close()
+		data := <-received
+
+		if !w.ready || w.readies != 1 {
+			t.Fatalf("split at %d: expected the backend to be ready for a query", offset)
+		}
+
+		if len(data) != len(stream) || binary.BigEndian.Uint32(data[5:9]) != 107 {
+			t.Fatalf("split at %d: expected the process ID to be rewritten, got %q", offset, data)
+		}
+
+		if !bytes.Equal(data[len(notification):], ready) {
+			t.Fatalf("split at %d: expected the ReadyForQuery message to be relayed", offset)
+		}
+	}
+}
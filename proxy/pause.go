@@ -0,0 +1,100 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+import (
+	"time"
+
+	"github.com/crunchydata/crunchy-proxy/util/log"
+)
+
+/* How often pausing checks whether clients have finished their transactions. */
+const pausePollInterval = 100 * time.Millisecond
+
+// Pause holds the queries of clients that do not hold a backend at the proxy
+// until Resume is called. It then waits up to the timeout for the clients that
+// hold a backend, e.g. in a transaction, to release it, and returns the number
+// of clients that still hold one. Once none do, backends can be restarted or
+// switched over without clients seeing errors.
+func (p *Proxy) Pause(timeout time.Duration) int {
+	p.lock.Lock()
+
+	if p.resumed == nil {
+		p.resumed = make(chan struct{})
+		log.Info("Pausing client traffic")
+	}
+
+	p.lock.Unlock()
+
+	deadline := time.Now().Add(timeout)
+
+	for {
+		active := p.activeClients()
+
+		if active == 0 || !p.isPaused() || !time.Now().Before(deadline) {
+			return active
+		}
+
+		time.Sleep(pausePollInterval)
+	}
+}
+
+// Resume releases the queries held since the proxy was paused.
+func (p *Proxy) Resume() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.resumed != nil {
+		close(p.resumed)
+		p.resumed = nil
+		log.Info("Resuming client traffic")
+	}
+}
+
+/* isPaused returns whether the proxy is paused. */
+func (p *Proxy) isPaused() bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return p.resumed != nil
+}
+
+/* waitResume blocks the client of a session while the proxy is paused. */
+func (p *Proxy) waitResume(s *session) {
+	p.lock.Lock()
+	resumed := p.resumed
+	p.lock.Unlock()
+
+	if resumed == nil {
+		return
+	}
+
+	s.setWaiting()
+	<-resumed
+	s.clearWaiting()
+}
+
+/* activeClients returns the number of clients that hold a backend. */
+func (p *Proxy) activeClients() int {
+	var active int
+
+	for _, s := range p.getSessions() {
+		if s.backend != nil {
+			active++
+		}
+	}
+
+	return active
+}
@@ -0,0 +1,209 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/crunchydata/crunchy-proxy/config"
+	"github.com/crunchydata/crunchy-proxy/util/log"
+)
+
+// Uptime describes how long the proxy has been counting its statistics. If
+// the statistics are saved to a file, this spans restarts of the proxy.
+type Uptime struct {
+	Since  time.Time     // when the counters were first recorded
+	Starts int           // the number of times the proxy has started since
+	Total  time.Duration // the time the proxy has been running since
+}
+
+// savedStats is the content of the statistics file: the cumulative counters
+// of the proxy and the time it ran for before its current start.
+type savedStats struct {
+	Since     time.Time                     `json:"since"`
+	Starts    int                           `json:"starts"`
+	Uptime    int64                         `json:"uptime"` //seconds
+	Queries   map[string]int32              `json:"queries"`
+	Databases map[string]savedDatabaseStats `json:"databases"`
+}
+
+type savedDatabaseStats struct {
+	XactCount  int64 `json:"xact_count"`
+	QueryCount int64 `json:"query_count"`
+	Received   int64 `json:"received"`
+	Sent       int64 `json:"sent"`
+	XactTime   int64 `json:"xact_time"`  //microseconds
+	QueryTime  int64 `json:"query_time"` //microseconds
+	WaitTime   int64 `json:"wait_time"`  //microseconds
+}
+
+// Uptime returns how long the proxy has been counting its statistics.
+func (p *Proxy) Uptime() Uptime {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return Uptime{
+		Since:  p.since,
+		Starts: p.starts,
+		Total:  p.priorUptime + time.Since(p.started),
+	}
+}
+
+// QueryCounts returns a copy of the number of queries sent to each node.
+func (p *Proxy) QueryCounts() map[string]int32 {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	counts := make(map[string]int32, len(p.Stats))
+
+	for name, count := range p.Stats {
+		counts[name] = count
+	}
+
+	return counts
+}
+
+// loadStats restores the counters saved by an earlier run of the proxy, if
+// the statistics are saved to a file. A missing file starts the counters
+// afresh; a file that cannot be read is logged and also starts afresh, rather
+// than preventing the proxy from starting.
+func (p *Proxy) loadStats() {
+	p.since = p.started
+	p.starts = 1
+
+	path := config.GetStatsConfig().File
+
+	if path == "" {
+		return
+	}
+
+	data, err := ioutil.ReadFile(path)
+
+	if os.IsNotExist(err) {
+		return
+	}
+
+	var saved savedStats
+
+	if err == nil {
+		err = json.Unmarshal(data, &saved)
+	}
+
+	if err != nil {
+		log.Errorf("Could not restore statistics from '%s'", path)
+		log.Errorf("Error: %s", err.Error())
+		return
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if !saved.Since.IsZero() {
+		p.since = saved.Since
+	}
+
+	p.starts = saved.Starts + 1
+	p.priorUptime = time.Duration(saved.Uptime) * time.Second
+
+	for name, count := range saved.Queries {
+		p.Stats[name] = count
+	}
+
+	for database, s := range saved.Databases {
+		p.databaseStats[database] = &databaseStats{
+			xactCount:  s.XactCount,
+			queryCount: s.QueryCount,
+			received:   s.Received,
+			sent:       s.Sent,
+			xactTime:   time.Duration(s.XactTime) * time.Microsecond,
+			queryTime:  time.Duration(s.QueryTime) * time.Microsecond,
+			waitTime:   time.Duration(s.WaitTime) * time.Microsecond,
+		}
+	}
+
+	log.Infof("Restored statistics from '%s', counting since %s", path,
+		p.since.Format(time.RFC3339))
+}
+
+// saveStats writes the counters to the statistics file, if one is configured.
+// The file is replaced by renaming a temporary file over it, so that a crash
+// while saving leaves the previous counters intact.
+func (p *Proxy) saveStats() error {
+	path := config.GetStatsConfig().File
+
+	if path == "" {
+		return nil
+	}
+
+	uptime := p.Uptime()
+
+	saved := savedStats{
+		Since:     uptime.Since,
+		Starts:    uptime.Starts,
+		Uptime:    int64(uptime.Total / time.Second),
+		Queries:   p.QueryCounts(),
+		Databases: make(map[string]savedDatabaseStats),
+	}
+
+	for database, s := range p.getStats() {
+		saved.Databases[database] = savedDatabaseStats{
+			XactCount:  s.xactCount,
+			QueryCount: s.queryCount,
+			Received:   s.received,
+			Sent:       s.sent,
+			XactTime:   int64(s.xactTime / time.Microsecond),
+			QueryTime:  int64(s.queryTime / time.Microsecond),
+			WaitTime:   int64(s.waitTime / time.Microsecond),
+		}
+	}
+
+	data, err := json.MarshalIndent(saved, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	temporary := path + ".tmp"
+
+	if err := ioutil.WriteFile(temporary, data, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(temporary, path)
+}
+
+// persistStats runs until the proxy is drained, saving the counters every
+// 'saveinterval'. They are saved a final time when the proxy is drained.
+func (p *Proxy) persistStats() {
+	for {
+		time.Sleep(time.Duration(config.GetStatsConfig().SaveInterval) * time.Second)
+
+		p.lock.Lock()
+		draining := p.draining
+		p.lock.Unlock()
+
+		if draining {
+			return
+		}
+
+		if err := p.saveStats(); err != nil {
+			log.Errorf("Could not save statistics")
+			log.Errorf("Error: %s", err.Error())
+		}
+	}
+}
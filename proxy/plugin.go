@@ -0,0 +1,69 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+import (
+	"fmt"
+	"plugin"
+
+	"github.com/crunchydata/crunchy-proxy/config"
+	"github.com/crunchydata/crunchy-proxy/util/log"
+)
+
+/* The function a plugin exports to create its middleware. */
+const pluginSymbol = "NewMiddleware"
+
+// PluginFactory is the type of the NewMiddleware function that a Go plugin
+// must export. It is passed the options configured for the plugin and returns
+// the middleware to register.
+type PluginFactory = func(options map[string]string) (Middleware, error)
+
+// LoadPlugins opens the Go plugins listed in the configuration and registers
+// the middleware each of them creates, in the order they are listed. Plugins
+// cannot be unloaded, so they are only loaded when the proxy starts.
+func LoadPlugins() error {
+	for _, pluginConfig := range config.GetProxyConfig().Plugins {
+		p, err := plugin.Open(pluginConfig.Path)
+
+		if err != nil {
+			return fmt.Errorf("could not load plugin %s: %s", pluginConfig.Path, err.Error())
+		}
+
+		symbol, err := p.Lookup(pluginSymbol)
+
+		if err != nil {
+			return fmt.Errorf("plugin %s: %s", pluginConfig.Path, err.Error())
+		}
+
+		factory, ok := symbol.(PluginFactory)
+
+		if !ok {
+			return fmt.Errorf("plugin %s: %s is %T, not a proxy.PluginFactory",
+				pluginConfig.Path, pluginSymbol, symbol)
+		}
+
+		m, err := factory(pluginConfig.Options)
+
+		if err != nil {
+			return fmt.Errorf("plugin %s: %s", pluginConfig.Path, err.Error())
+		}
+
+		Use(m)
+
+		log.Infof("Loaded plugin %s", pluginConfig.Path)
+	}
+
+	return nil
+}
@@ -0,0 +1,299 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package proxy
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+
+	"github.com/crunchydata/crunchy-proxy/connect"
+	"github.com/crunchydata/crunchy-proxy/protocol"
+	"github.com/crunchydata/crunchy-proxy/util/log"
+)
+
+/*
+ * Named prepared statements only exist on the backend that prepared them, but
+ * a client may use a different backend for each transaction. To allow this,
+ * each named statement of a client is given a name that is unique across the
+ * proxy, and the Parse message is kept so that the statement can be prepared
+ * again on any backend the client is given. The names in the messages sent by
+ * the client are replaced with the unique names.
+ */
+
+// preparedStatement is a named prepared statement of a client.
+type preparedStatement struct {
+	name  string // the name of the statement on the backends
+	parse []byte // the Parse message that prepares the statement
+}
+
+/* The offset of the first field of a message. */
+const messageBodyOffset = 5
+
+/*
+ * Replace the null terminated string at the offset of a message, updating
+ * the message length.
+ */
+func replaceString(m []byte, offset int, value string) []byte {
+	end := bytes.IndexByte(m[offset:], 0)
+
+	if end < 0 {
+		return m
+	}
+
+	result := make([]byte, 0, len(m)+len(value))
+	result = append(result, m[:offset]...)
+	result = append(result, value...)
+	result = append(result, m[offset+end:]...)
+
+	binary.BigEndian.PutUint32(result[1:], uint32(len(result)-1))
+
+	return result
+}
+
+/* Get the null terminated string at the offset of a message. */
+func readString(m []byte, offset int) (string, int) {
+	end := bytes.IndexByte(m[offset:], 0)
+
+	if end < 0 {
+		return "", -1
+	}
+
+	return string(m[offset : offset+end]), offset + end + 1
+}
+
+// rewriteStatements replaces the names of the client's prepared statements in
+// a batch of messages with their names on the backends, recording statements
+// that are prepared and forgetting those that are closed. If the batch does
+// not refer to any named statements, then it is returned unchanged. A batch
+// that ends partway through a message cannot be rewritten, and is returned
+// unchanged along with false, in which case the client must keep the backend
+// for the rest of its session, as its statements only exist there.
+func (p *Proxy) rewriteStatements(s *session, backend net.Conn, batch []byte) ([]byte, bool) {
+	messages := getMessages(batch)
+	var size int
+
+	for _, m := range messages {
+		if len(m) != int(protocol.GetMessageLength(m))+1 {
+			return batch, false
+		}
+
+		size += len(m)
+	}
+
+	if size != len(batch) {
+		return batch, false
+	}
+
+	var rewritten bool
+
+	for i, m := range messages {
+		if len(m) <= messageBodyOffset {
+			continue
+		}
+
+		switch protocol.GetMessageType(m) {
+		case protocol.ParseMessageType:
+			name, _ := readString(m, messageBodyOffset)
+
+			if name == "" {
+				continue
+			}
+
+			statement := p.newStatement(s, name)
+			statement.parse = replaceString(m, messageBodyOffset, statement.name)
+			messages[i] = statement.parse
+
+			if conn, ok := backend.(*backendConn); ok {
+				conn.prepared[statement.name] = true
+			}
+		case protocol.BindMessageType:
+			_, offset := readString(m, messageBodyOffset)
+
+			if offset < 0 {
+				continue
+			}
+
+			name, _ := readString(m, offset)
+
+			if statement, ok := s.statements[name]; ok && name != "" {
+				messages[i] = replaceString(m, offset, statement.name)
+			}
+		case protocol.DescribeMessageType, protocol.CloseMessageType:
+			if m[messageBodyOffset] != protocol.DescribeStatement {
+				continue
+			}
+
+			name, _ := readString(m, messageBodyOffset+1)
+			statement, ok := s.statements[name]
+
+			if !ok || name == "" {
+				continue
+			}
+
+			messages[i] = replaceString(m, messageBodyOffset+1, statement.name)
+
+			if protocol.GetMessageType(m) == protocol.CloseMessageType {
+				p.closeStatement(s, name)
+
+				if conn, ok := backend.(*backendConn); ok {
+					delete(conn.prepared, statement.name)
+				}
+			}
+		default:
+			continue
+		}
+
+		rewritten = true
+	}
+
+	if !rewritten {
+		return batch, true
+	}
+
+	return bytes.Join(messages, nil), true
+}
+
+// newStatement records a named prepared statement of the client, replacing
+// any previous statement with the same name.
+func (p *Proxy) newStatement(s *session, name string) *preparedStatement {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if previous, ok := s.statements[name]; ok {
+		delete(p.statements, previous.name)
+	}
+
+	s.nextStatement++
+
+	statement := &preparedStatement{
+		name: fmt.Sprintf("crunchy_%d_%d", s.processID, s.nextStatement),
+	}
+
+	s.statements[name] = statement
+	p.statements[statement.name] = true
+
+	return statement
+}
+
+// closeStatement forgets a named prepared statement of the client. It is
+// closed on any other backends the next time they are assigned to a client.
+func (p *Proxy) closeStatement(s *session, name string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if statement, ok := s.statements[name]; ok {
+		delete(p.statements, statement.name)
+		delete(s.statements, name)
+	}
+}
+
+// prepareStatements makes a backend that has just been assigned to a client
+// ready for it. The statements of clients that have closed them or
+// disconnected are closed, and the client's statements that the backend does
+// not have are prepared. Each statement is prepared separately, so that a
+// statement that cannot be prepared does not prevent the others from being
+// prepared. Such a statement is forgotten, so that the client receives an
+// error when it uses it.
+func (p *Proxy) prepareStatements(s *session, backend net.Conn) {
+	conn, ok := backend.(*backendConn)
+
+	if !ok {
+		return
+	}
+
+	var request []byte
+	var names []string // the client names of the statements being prepared
+
+	p.lock.Lock()
+	for name := range conn.prepared {
+		if !p.statements[name] {
+			request = append(request, protocol.CreateCloseMessage(protocol.DescribeStatement, name)...)
+			delete(conn.prepared, name)
+		}
+	}
+	p.lock.Unlock()
+
+	if len(request) > 0 {
+		request = append(request, protocol.CreateSyncMessage()...)
+		names = append(names, "")
+	}
+
+	for name, statement := range s.statements {
+		if conn.prepared[statement.name] {
+			continue
+		}
+
+		/* The statement may remain from before a reset, so close it first. */
+		request = append(request, protocol.CreateCloseMessage(protocol.DescribeStatement, statement.name)...)
+		request = append(request, statement.parse...)
+		request = append(request, protocol.CreateSyncMessage()...)
+		names = append(names, name)
+	}
+
+	if len(names) == 0 {
+		return
+	}
+
+	log.Debugf("Preparing %d statements on backend %s", len(names), backend.RemoteAddr())
+
+	if _, err := connect.Send(backend, request); err != nil {
+		log.Errorf("Error preparing statements on backend %s", backend.RemoteAddr())
+		log.Errorf("Error: %s", err.Error())
+		return
+	}
+
+	/* Each Sync is answered by a ReadyForQuery message. */
+	var current int
+	var failed bool
+
+	responses := &messageTracker{}
+
+	buffer := connect.GetBuffer()
+	defer connect.PutBuffer(buffer)
+
+	for current < len(names) {
+		message, length, err := connect.ReceiveBuffer(backend, buffer)
+
+		if err != nil {
+			log.Errorf("Error receiving prepare response from backend %s", backend.RemoteAddr())
+			log.Errorf("Error: %s", err.Error())
+			return
+		}
+
+		responses.scan(message[:length], func(messageType byte, first byte) {
+			switch messageType {
+			case protocol.ErrorMessageType:
+				failed = true
+			case protocol.ReadyForQueryMessageType:
+				name := names[current]
+
+				if name != "" {
+					if failed {
+						log.Errorf("Could not prepare statement '%s' on backend %s",
+							name, backend.RemoteAddr())
+						p.closeStatement(s, name)
+					} else {
+						conn.prepared[s.statements[name].name] = true
+					}
+				}
+
+				failed = false
+				current++
+			}
+		})
+	}
+}
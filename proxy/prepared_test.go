@@ -0,0 +1,152 @@
+/*
+Copyright 2016 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+
+	"github.com/crunchydata/crunchy-proxy/config"
+	"github.com/crunchydata/crunchy-proxy/protocol"
+)
+
+/* Create a proxy with just enough state to track the sessions of clients. */
+func newTestProxy() *Proxy {
+	return &Proxy{
+		sessions:   make(map[int32]*session),
+		statements: make(map[string]bool),
+		results:    make(map[cacheKey]*cacheEntry),
+		partitions: make(map[partition]config.PartitionConfig),
+		lock:       &sync.Mutex{},
+		poolLock:   &sync.Mutex{},
+	}
+}
+
+func TestRewriteStatements(t *testing.T) {
+	syncMessage := protocol.CreateSyncMessage()
+
+	tests := []struct {
+		name     string
+		prepared []string // statements the client prepared before the batch
+		batch    [][]byte
+		expected [][]byte
+		known    []string // statements of the client after the batch
+	}{
+		{
+			name:     "simple query",
+			batch:    [][]byte{protocol.CreateQueryMessage("select 1")},
+			expected: [][]byte{protocol.CreateQueryMessage("select 1")},
+		},
+		{
+			name:     "unnamed statement",
+			batch:    [][]byte{newParse("", "select 1"), newBind("", ""), newExecute(""), syncMessage},
+			expected: [][]byte{newParse("", "select 1"), newBind("", ""), newExecute(""), syncMessage},
+		},
+		{
+			name:     "named statement prepared and used in one batch",
+			batch:    [][]byte{newParse("s1", "select 1"), newBind("", "s1"), newExecute(""), syncMessage},
+			expected: [][]byte{newParse("crunchy_1_1", "select 1"), newBind("", "crunchy_1_1"), newExecute(""), syncMessage},
+			known:    []string{"s1"},
+		},
+		{
+			name:     "statement prepared earlier",
+			prepared: []string{"s1", "s2"},
+			batch:    [][]byte{newDescribe(protocol.DescribeStatement, "s1"), newBind("p", "s2"), newExecute("p"), syncMessage},
+			expected: [][]byte{newDescribe(protocol.DescribeStatement, "crunchy_1_1"), newBind("p", "crunchy_1_2"), newExecute("p"), syncMessage},
+			known:    []string{"s1", "s2"},
+		},
+		{
+			name:     "unknown statement",
+			batch:    [][]byte{newBind("", "missing"), newExecute(""), syncMessage},
+			expected: [][]byte{newBind("", "missing"), newExecute(""), syncMessage},
+		},
+		{
+			name:     "closed statement",
+			prepared: []string{"s1"},
+			batch:    [][]byte{protocol.CreateCloseMessage(protocol.DescribeStatement, "s1"), syncMessage},
+			expected: [][]byte{protocol.CreateCloseMessage(protocol.DescribeStatement, "crunchy_1_1"), syncMessage},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := newTestProxy()
+			s := p.newSession(nil, partition{})
+
+			for _, name := range test.prepared {
+				p.rewriteStatements(s, nil, newParse(name, "select 1"))
+			}
+
+			batch, renamed := p.rewriteStatements(s, nil, bytes.Join(test.batch, nil))
+
+			if !renamed {
+				t.Fatal("expected a whole batch to be rewritten")
+			}
+
+			if expected := bytes.Join(test.expected, nil); !bytes.Equal(batch, expected) {
+				t.Fatalf("expected %q, got %q", expected, batch)
+			}
+
+			if len(s.statements) != len(test.known) {
+				t.Fatalf("expected %d statements, have %d", len(test.known), len(s.statements))
+			}
+
+			for _, name := range test.known {
+				if _, ok := s.statements[name]; !ok {
+					t.Fatalf("expected statement '%s' to be known", name)
+				}
+			}
+		})
+	}
+}
+
+func TestRewriteStatementsSplitAcrossReads(t *testing.T) {
+	batch := bytes.Join([][]byte{
+		newParse("s1", "select 1"),
+		newBind("", "s1"),
+		newExecute(""),
+		protocol.CreateSyncMessage(),
+	}, nil)
+
+	for _, length := range []int{3, 5, len(newParse("s1", "select 1")) + 2, len(batch) - 1} {
+		p := newTestProxy()
+		s := p.newSession(nil, partition{})
+
+		/* The part of the batch received by the first read. */
+		received := batch[:length]
+
+		rewritten, renamed := p.rewriteStatements(s, nil, received)
+
+		if renamed {
+			t.Fatalf("read of %d bytes: expected a partial batch not to be rewritten", length)
+		}
+
+		if !bytes.Equal(rewritten, received) {
+			t.Fatalf("read of %d bytes: expected a partial batch to be unchanged", length)
+		}
+
+		if len(s.statements) != 0 {
+			t.Fatalf("read of %d bytes: expected no statements to be recorded", length)
+		}
+
+		/* Once the rest has been received, the whole batch is rewritten. */
+		rewritten, renamed = p.rewriteStatements(s, nil, batch)
+
+		if !renamed || !bytes.Contains(rewritten, []byte("crunchy_1_1\x00")) {
+			t.Fatalf("read of %d bytes: expected the assembled batch to be rewritten", length)
+		}
+	}
+}
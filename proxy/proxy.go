@@ -15,312 +15,2866 @@ limitations under the License.
 package proxy
 
 import (
+	"context"
+	"crypto/tls"
+	"fmt"
 	"io"
 	"net"
+	"reflect"
+	"strings"
 	"sync"
+	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/crunchydata/crunchy-proxy/capture"
 	"github.com/crunchydata/crunchy-proxy/common"
 	"github.com/crunchydata/crunchy-proxy/config"
 	"github.com/crunchydata/crunchy-proxy/connect"
+	"github.com/crunchydata/crunchy-proxy/events"
+	"github.com/crunchydata/crunchy-proxy/metrics"
 	"github.com/crunchydata/crunchy-proxy/pool"
 	"github.com/crunchydata/crunchy-proxy/protocol"
+	"github.com/crunchydata/crunchy-proxy/tracing"
 	"github.com/crunchydata/crunchy-proxy/util/log"
 )
 
+// partition identifies the cluster, database and user that a pool connects
+// as. The top-level nodes are the cluster with an empty name.
+type partition struct {
+	cluster  string
+	database string
+	username string
+}
+
+// poolKey identifies the pool for a partition on a node.
+type poolKey struct {
+	node string
+	partition
+}
+
 type Proxy struct {
-	writePools chan *pool.Pool
-	readPools  chan *pool.Pool
-	master     common.Node
-	clients    []net.Conn
-	Stats      map[string]int32
-	lock       *sync.Mutex
+	pools            map[poolKey]*pool.Pool
+	partitions       map[partition]config.PartitionConfig
+	nodes            map[string]common.Node
+	credentials      map[string]common.Credentials // by cluster
+	masters          map[string]string             // by cluster
+	replicas         map[string][]string           // by cluster
+	balancer         Balancer
+	strategy         string
+	ring             *shardRing            // spreads shard keys over the shards, if enabled
+	sharding         config.ShardingConfig // the configuration the ring was built from
+	latency          map[string]time.Duration
+	lag              map[string]*Lag
+	positions        map[string]*WALPosition
+	lsnConns         map[string]*lsnConn
+	clients          map[net.Conn]bool // whether each client is idle
+	sessions         map[int32]*session
+	statements       map[string]bool         // the prepared statements of all clients
+	mirrors          chan mirrorJob          // transactions to run on the shadow cluster
+	slots            chan struct{}           // limits the number of clients, if set
+	connectionBucket *tokenBucket            // limits the rate of new clients
+	clientBuckets    map[string]*tokenBucket // by source address
+	bucketsPruned    time.Time
+	nextSession      int32
+	draining         bool
+	readOnly         bool          // rejects queries for the master
+	resumed          chan struct{} // closed when the proxy resumes, if paused
+	active           sync.WaitGroup
+	Stats            map[string]int32
+	databaseStats    map[string]*databaseStats
+	queryStats       map[statementKey]*statementStats
+	queryLatency     map[latencyKey]*latencyHistogram
+	results          map[cacheKey]*cacheEntry // cached query results
+	cacheStats       CacheStats
+	started          time.Time
+	since            time.Time     // when the statistics were first counted
+	starts           int           // the number of starts since then
+	priorUptime      time.Duration // the time run before this start
+	lock             *sync.Mutex
+	poolLock         *sync.Mutex
+	reloadLock       *sync.Mutex // serializes reloads
 }
 
 func NewProxy() *Proxy {
 	p := &Proxy{
-		Stats: make(map[string]int32),
-		lock:  &sync.Mutex{},
+		pools:         make(map[poolKey]*pool.Pool),
+		partitions:    make(map[partition]config.PartitionConfig),
+		latency:       make(map[string]time.Duration),
+		lag:           make(map[string]*Lag),
+		positions:     make(map[string]*WALPosition),
+		lsnConns:      make(map[string]*lsnConn),
+		clients:       make(map[net.Conn]bool),
+		sessions:      make(map[int32]*session),
+		clientBuckets: make(map[string]*tokenBucket),
+		statements:    make(map[string]bool),
+		Stats:         make(map[string]int32),
+		databaseStats: make(map[string]*databaseStats),
+		queryStats:    make(map[statementKey]*statementStats),
+		queryLatency:  make(map[latencyKey]*latencyHistogram),
+		results:       make(map[cacheKey]*cacheEntry),
+		started:       time.Now(),
+		lock:          &sync.Mutex{},
+		poolLock:      &sync.Mutex{},
+		reloadLock:    &sync.Mutex{},
+	}
+
+	if maxClients := config.GetProxyConfig().MaxClients; maxClients > 0 {
+		p.slots = make(chan struct{}, maxClients)
 	}
 
 	p.setupPools()
 
+	p.loadStats()
+
+	p.startMirror()
+
+	go p.reap()
+
+	go p.persistStats()
+
 	return p
 }
 
 func (p *Proxy) setupPools() {
-	nodes := config.GetNodes()
-	capacity := config.GetPoolCapacity()
+	nodes := enabledNodes(config.GetNodes())
 
-	/* Initialize pool structures */
-	numNodes := len(nodes)
-	p.writePools = make(chan *pool.Pool, numNodes)
-	p.readPools = make(chan *pool.Pool, numNodes)
+	p.partitions, p.credentials = getPartitions()
 
-	for name, node := range nodes {
-		/* Create Pool for Node */
-		newPool := pool.NewPool(name, capacity)
+	for part, partitionConfig := range p.partitions {
+		for name, node := range nodes {
+			if node.Cluster == part.cluster {
+				p.pools[poolKey{name, part}] = p.createPool(name, node, partitionConfig)
+			}
+		}
+	}
+
+	p.setNodes(nodes)
+	p.setBalancer(config.GetBalancer())
+	p.setShards(config.GetShardingConfig())
+}
+
+// getPartitions returns the partitions and the credentials of every cluster.
+func getPartitions() (map[partition]config.PartitionConfig, map[string]common.Credentials) {
+	partitions := make(map[partition]config.PartitionConfig)
+	credentials := make(map[string]common.Credentials)
+
+	for _, cluster := range config.GetClusters() {
+		credentials[cluster] = config.GetClusterCredentials(cluster)
+
+		for _, partitionConfig := range config.GetPartitions(cluster) {
+			part := partition{cluster, partitionConfig.Database, partitionConfig.Username}
+			partitions[part] = partitionConfig
+		}
+	}
+
+	return partitions, credentials
+}
+
+// setBalancer sets the balancer used to select replicas. The current balancer
+// is kept if the strategy has not changed.
+func (p *Proxy) setBalancer(strategy string) {
+	if p.balancer != nil && p.strategy == strategy {
+		return
+	}
+
+	p.balancer = NewBalancer(strategy)
+	p.strategy = strategy
+}
+
+// SetLatency records the latency of a node as measured by the health check.
+func (p *Proxy) SetLatency(name string, latency time.Duration) {
+	p.poolLock.Lock()
+	defer p.poolLock.Unlock()
+
+	p.latency[name] = latency
+}
 
+// Lag is the replication lag of a replica.
+type Lag struct {
+	Bytes int64         // received WAL that has not been replayed
+	Delay time.Duration // time since the last replayed transaction
+}
+
+// SetLag records the replication lag of a replica as measured by the health
+// check. A nil lag means that it could not be measured.
+func (p *Proxy) SetLag(name string, lag *Lag) {
+	p.poolLock.Lock()
+	defer p.poolLock.Unlock()
+
+	p.lag[name] = lag
+}
+
+/*
+ * Determine whether a replica is too far behind the master to serve reads.
+ * Replicas that have not been measured yet are assumed to be up to date, but
+ * those whose lag could not be measured are not.
+ */
+func (p *Proxy) isLagging(name string, maxLag config.LagConfig) bool {
+	if maxLag.Bytes <= 0 && maxLag.Seconds <= 0 {
+		return false
+	}
+
+	lag, ok := p.lag[name]
+
+	if !ok {
+		return false
+	}
+
+	if lag == nil {
+		return true
+	}
+
+	if maxLag.Bytes > 0 && lag.Bytes > maxLag.Bytes {
+		return true
+	}
+
+	return maxLag.Seconds > 0 && lag.Delay > time.Duration(maxLag.Seconds)*time.Second
+}
+
+// setNodes records the nodes that the pools were created for and determines
+// which of them is the master and which are replicas of each cluster.
+func (p *Proxy) setNodes(nodes map[string]common.Node) {
+	p.nodes = nodes
+	p.masters = make(map[string]string)
+	p.replicas = make(map[string][]string)
+
+	for name, node := range nodes {
 		if node.Role == common.NODE_ROLE_MASTER {
-			p.writePools <- newPool
+			p.masters[node.Cluster] = name
 		} else {
-			p.readPools <- newPool
+			p.replicas[node.Cluster] = append(p.replicas[node.Cluster], name)
+		}
+	}
+}
+
+// enabledNodes returns the nodes that have not been disabled. Disabled nodes
+// have no pools and do not receive any queries.
+func enabledNodes(nodes map[string]common.Node) map[string]common.Node {
+	for name, node := range nodes {
+		if node.Disabled {
+			delete(nodes, name)
 		}
+	}
 
-		/* Create connections and add to pool. */
-		for i := 0; i < capacity; i++ {
-			/* Connect and authenticate */
-			log.Infof("Connecting to node '%s' at %s...", name, node.HostPort)
-			connection, err := connect.Connect(node.HostPort)
+	return nodes
+}
 
-			username := config.GetString("credentials.username")
-			database := config.GetString("credentials.database")
-			options := config.GetStringMapString("credentials.options")
+// Reload applies the current configuration to the pools.
+//
+// Pools are created for new and re-enabled nodes and partitions and closed for
+// those that have been removed or disabled. A pool is rebuilt if its node's address, its capacity,
+// the pool mode, the partition's password or the credentials have changed.
+// Clients that hold a connection from a pool that is replaced keep using it
+// until it is released, at which point the connection is closed.
+func (p *Proxy) Reload() {
+	p.reloadLock.Lock()
+	defer p.reloadLock.Unlock()
 
-			startupMessage := protocol.CreateStartupMessage(username, database, options)
+	nodes := enabledNodes(config.GetNodes())
+	partitions, credentials := getPartitions()
 
-			connection.Write(startupMessage)
+	/* Determine which pools need to be created or removed. */
+	var create []poolKey
+	var remove []poolKey
 
-			response := make([]byte, 4096)
-			connection.Read(response)
+	p.poolLock.Lock()
 
-			authenticated := connect.HandleAuthenticationRequest(connection, response)
+	for part, partitionConfig := range partitions {
+		rebuild := !reflect.DeepEqual(credentials[part.cluster], p.credentials[part.cluster])
 
-			if !authenticated {
-				log.Error("Authentication failed")
+		for name, node := range nodes {
+			if node.Cluster != part.cluster {
+				continue
 			}
 
-			if err != nil {
-				log.Errorf("Error establishing connection to node '%s'", name)
-				log.Errorf("Error: %s", err.Error())
-			} else {
-				log.Infof("Successfully connected to '%s' at '%s'", name, node.HostPort)
-				newPool.Add(connection)
+			key := poolKey{name, part}
+			existing, ok := p.pools[key]
+
+			if !ok || rebuild ||
+				existing.Capacity != partitionConfig.Capacity ||
+				existing.Mode != config.GetPoolMode(name) ||
+				p.nodes[name].HostPort != node.HostPort ||
+				p.partitions[part].Password != partitionConfig.Password {
+				create = append(create, key)
 			}
 		}
 	}
+
+	for key := range p.pools {
+		if node, ok := nodes[key.node]; !ok || node.Cluster != key.cluster {
+			remove = append(remove, key)
+		} else if _, ok := partitions[key.partition]; !ok {
+			remove = append(remove, key)
+		}
+	}
+
+	p.poolLock.Unlock()
+
+	/* Establish the new pools before switching over to them. */
+	newPools := make(map[poolKey]*pool.Pool, len(create))
+
+	for _, key := range create {
+		newPools[key] = p.createPool(key.node, nodes[key.node], partitions[key.partition])
+	}
+
+	var oldPools []*pool.Pool
+
+	p.poolLock.Lock()
+
+	for key, newPool := range newPools {
+		if oldPool, ok := p.pools[key]; ok {
+			oldPools = append(oldPools, oldPool)
+		}
+
+		p.pools[key] = newPool
+	}
+
+	for _, key := range remove {
+		log.Infof("Removed pool for node '%s', database '%s', user '%s'",
+			key.node, key.database, key.username)
+		oldPools = append(oldPools, p.pools[key])
+		delete(p.pools, key)
+	}
+
+	p.setNodes(nodes)
+	p.setBalancer(config.GetBalancer())
+	p.setShards(config.GetShardingConfig())
+	p.partitions = partitions
+	p.credentials = credentials
+
+	p.poolLock.Unlock()
+
+	for _, oldPool := range oldPools {
+		metrics.UnregisterPool(oldPool)
+		oldPool.Close()
+	}
 }
 
-// Get the next pool. If read is set to true, then a 'read-only' pool will be
-// returned. Otherwise, a 'read-write' pool will be returned.
-func (p *Proxy) getPool(read bool) *pool.Pool {
-	if read {
-		return <-p.readPools
+// createPool creates a new pool for the node and fills it with connections
+// that connect to the partition's database as the partition's user.
+func (p *Proxy) createPool(name string, node common.Node, partitionConfig config.PartitionConfig) *pool.Pool {
+	capacity := partitionConfig.Capacity
+
+	/* Create Pool for Node */
+	newPool := pool.NewPool(name, partitionConfig.Database, partitionConfig.Username,
+		capacity, config.GetPoolMode(name))
+	metrics.RegisterPool(newPool)
+
+	/*
+	 * Fill the pool with connections or, if it is warmed up lazily, with just
+	 * its idle connections, in which case more are opened as clients need them.
+	 */
+	count := capacity
+
+	if warmUp, minIdle := config.GetPoolWarmUp(); warmUp == common.POOL_WARMUP_LAZY {
+		count = minIdle
 	}
-	return <-p.writePools
+
+	addConnections(newPool, name, node, partitionConfig, count)
+
+	return newPool
 }
 
-// Return the pool. If read is 'true' then, the pool will be returned to the
-// 'read-only' collection of pools. Otherwise, it will be returned to the
-// 'read-write' collection of pools.
-func (p *Proxy) returnPool(pl *pool.Pool, read bool) {
-	if read {
-		p.readPools <- pl
-	} else {
-		p.writePools <- pl
+// discardBackend closes a backend connection instead of returning it to its
+// pool, and adds a new connection to the pool in its place.
+func (p *Proxy) discardBackend(cp *pool.Pool, backend net.Conn, name string, part partition) {
+	cp.Discard(backend)
+
+	p.poolLock.Lock()
+	node := p.nodes[name]
+	partitionConfig := p.partitions[part]
+	p.poolLock.Unlock()
+
+	addConnections(cp, name, node, partitionConfig, 1)
+}
+
+/* nodeRole returns the current role of a node. */
+func (p *Proxy) nodeRole(name string) string {
+	p.poolLock.Lock()
+	defer p.poolLock.Unlock()
+
+	return p.nodes[name].Role
+}
+
+// connectBackend opens a new pool connection to the node and authenticates
+// it as the partition's user. If the node has several addresses, then they
+// are tried in order until one of them accepts the connection and, if the
+// node requires it, accepts writes.
+func connectBackend(name string, node common.Node, partitionConfig config.PartitionConfig) (net.Conn, error) {
+	var err error
+
+	for _, address := range connect.SplitHosts(node.HostPort) {
+		var connection net.Conn
+
+		if connection, err = connectAddress(name, node, address, partitionConfig); err == nil {
+			return connection, nil
+		}
 	}
+
+	return nil, err
 }
 
-// HandleConnection handle an incoming connection to the proxy
-func (p *Proxy) HandleConnection(client net.Conn) {
-	/* Get the client startup message. */
-	message, length, err := connect.Receive(client)
+/* connectAddress opens a new pool connection to one address of the node. */
+func connectAddress(name string, node common.Node, address string, partitionConfig config.PartitionConfig) (net.Conn, error) {
+	/* Connect and authenticate */
+	log.Infof("Connecting to node '%s' at %s...", name, address)
+	connection, err := connect.Connect(address)
 
 	if err != nil {
-		log.Error("Error receiving startup message from client.")
+		metrics.BackendErrors.WithLabelValues(name).Inc()
+		log.Errorf("Error establishing connection to node '%s'", name)
 		log.Errorf("Error: %s", err.Error())
+		return nil, err
 	}
 
-	/* Get the protocol from the startup message.*/
-	version := protocol.GetVersion(message)
+	username := partitionConfig.Username
+	database := partitionConfig.Database
+	options := config.GetClusterCredentials(node.Cluster).Options
 
-	/* Handle the case where the startup message was an SSL request. */
-	if version == protocol.SSLRequestCode {
-		sslResponse := protocol.NewMessageBuffer([]byte{})
+	startupMessage := protocol.CreateStartupMessage(username, database, options)
 
-		/* Determine which SSL response to send to client. */
-		creds := config.GetCredentials()
-		if creds.SSL.Enable {
-			sslResponse.WriteByte(protocol.SSLAllowed)
-		} else {
-			sslResponse.WriteByte(protocol.SSLNotAllowed)
-		}
+	/* A node that hangs during the login fails it after the auth timeout. */
+	connection.SetDeadline(connect.Deadline(config.GetAuthTimeout()))
 
-		/*
-		 * Send the SSL response back to the client and wait for it to send the
-		 * regular startup packet.
-		 */
-		connect.Send(client, sslResponse.Bytes())
+	/*
+	 * An address that does not complete the login is given up on, so that
+	 * the next address of the node is tried. The authentication request is
+	 * read whole, and the rest of the response up to ReadyForQuery once the
+	 * login has succeeded, however many reads they arrive in.
+	 */
+	var response []byte
 
-		/* Upgrade the client connection if required. */
-		client = connect.UpgradeServerConnection(client)
+	if _, err = connection.Write(startupMessage); err == nil {
+		response, err = connect.ReceiveMessage(connection)
+	}
 
-		/*
-		 * Re-read the startup message from the client. It is possible that the
-		 * client might not like the response given and as a result it might
-		 * close the connection. This is not an 'error' condition as this is an
-		 * expected behavior from a client.
-		 */
-		if message, length, err = connect.Receive(client); err == io.EOF {
-			log.Info("The client closed the connection.")
-			return
+	if err == nil && protocol.GetMessageType(response) == protocol.ErrorMessageType {
+		err = fmt.Errorf("%s", protocol.ParseError(response).Message)
+	}
+
+	if err == nil {
+		var authenticated bool
+
+		authenticated, response = connect.HandleAuthenticationRequest(connection,
+			response, username, partitionConfig.Password)
+
+		if !authenticated {
+			err = fmt.Errorf("authentication as '%s' failed", username)
+		} else {
+			response, err = connect.ReceiveUntilReady(connection, response)
 		}
 	}
 
+	if err != nil {
+		connection.Close()
+		metrics.BackendErrors.WithLabelValues(name).Inc()
+		log.Errorf("Error logging in to node '%s' at %s", name, address)
+		log.Errorf("Error: %s", err.Error())
+		return nil, err
+	}
+
 	/*
-	 * Validate that the client username and database are the same as that
-	 * which is configured for the proxy connections.
-	 *
-	 * If the the client cannot be validated then send an appropriate PG error
-	 * message back to the client.
+	 * Like libpq's 'target_session_attrs=read-write', skip an address whose
+	 * server is a standby, such as the former master of a pair of servers
+	 * that fail over between their addresses.
 	 */
-	if !connect.ValidateClient(message) {
-		pgError := protocol.Error{
-			Severity: protocol.ErrorSeverityFatal,
-			Code:     protocol.ErrorCodeInvalidAuthorizationSpecification,
-			Message:  "could not validate user/database",
+	if node.TargetSessionAttrs == common.TARGET_SESSION_READ_WRITE {
+		inRecovery, err := queryValue(connection, "SELECT pg_is_in_recovery()")
+
+		if err == nil && inRecovery != "f" {
+			err = fmt.Errorf("server at %s is in recovery", address)
 		}
 
-		connect.Send(client, pgError.GetMessage())
-		log.Error("Could not validate client")
-		return
+		if err != nil {
+			connection.Close()
+			log.Errorf("Node '%s' at %s does not accept writes", name, address)
+			log.Errorf("Error: %s", err.Error())
+			return nil, err
+		}
 	}
 
-	/* Authenticate the client against the appropriate backend. */
-	log.Infof("Client: %s - authenticating", client.RemoteAddr())
-	authenticated, err := connect.AuthenticateClient(client, message, length)
+	connection.SetDeadline(time.Time{})
 
-	/* If the client could not authenticate then go no further. */
-	if err == io.EOF {
-		return
-	} else if !authenticated {
-		log.Errorf("Client: %s - authentication failed", client.RemoteAddr())
-		log.Errorf("Error: %s", err.Error())
-		return
-	} else {
-		log.Debugf("Client: %s - authentication successful", client.RemoteAddr())
-	}
+	log.Infof("Successfully connected to '%s' at '%s'", name, address)
 
-	/* Process the client messages for the life of the connection. */
-	var statementBlock bool
-	var cp *pool.Pool    // The connection pool in use
-	var backend net.Conn // The backend connection in use
-	var read bool
-	var end bool
-	var nodeName string
+	return newBackendConn(connection, address, response), nil
+}
 
-	for {
-		var done bool // for message processing loop.
+// Get the next pool for the partition. If read is set to true, then a
+// 'read-only' pool will be returned. Otherwise, a 'read-write' pool will be
+// returned. Read-only pools are selected by the configured balancer from the
+// replicas that are not lagging too far behind. If there are no such
+// read-only pools, then the 'read-write' pool is returned. If there is
+// no pool for the partition, then nil is returned.
+//
+// If an affinity value is given, then the replica is selected by the value
+// instead of the balancer, so that clients sharing the value read from the
+// same replica while it is available. If a node to exclude is given, then that
+// replica is not selected, e.g. because its connection just failed. If a write
+// is given, then only replicas that have replayed it are selected, so that a
+// client reads its own writes. A canary replica is selected for its
+// percentage of the reads, and never by the balancer.
+func (p *Proxy) getPool(read bool, part partition, affinity string, exclude string, after *writeMark) *pool.Pool {
+	p.poolLock.Lock()
+	defer p.poolLock.Unlock()
 
-		message, length, err = connect.Receive(client)
+	replicas := p.replicas[part.cluster]
 
-		if err != nil {
-			switch err {
-			case io.EOF:
-				log.Infof("Client: %s - closed the connection", client.RemoteAddr())
-			default:
-				log.Errorf("Error reading from client connection %s", client.RemoteAddr())
-				log.Errorf("Error: %s", err.Error())
-			}
-			break
-		}
+	if read && len(replicas) > 0 {
+		backends := make([]Backend, 0, len(replicas))
+		maxLag := config.GetMaxLag()
+		canary := config.GetCanary()
 
-		messageType := protocol.GetMessageType(message)
+		var canaryPool *pool.Pool
 
-		/*
-		 * If the message is a simple query, then it can have read/write
-		 * annotations attached to it. Therefore, we need to process it and
-		 * determine which backend we need to send it to.
-		 */
-		if messageType == protocol.TerminateMessageType {
-			log.Infof("Client: %s - disconnected", client.RemoteAddr())
-			return
-		} else if messageType == protocol.QueryMessageType {
-			annotations := getAnnotations(message)
+		for _, name := range replicas {
+			if name == exclude {
+				continue
+			}
 
-			if annotations[StartAnnotation] {
-				statementBlock = true
-			} else if annotations[EndAnnotation] {
-				end = true
-				statementBlock = false
+			/* Skip replicas that are refusing connections. */
+			if !connect.IsAvailable(p.nodes[name].HostPort) {
+				continue
 			}
 
-			read = annotations[ReadAnnotation]
+			/* Skip replicas that are too far behind the master. */
+			if p.isLagging(name, maxLag) {
+				continue
+			}
 
-			/*
-			 * If not in a statement block or if the pool or backend are not already
-			 * set, then fetch a new backend to receive the message.
-			 */
-			if !statementBlock && !end || cp == nil || backend == nil {
-				cp = p.getPool(read)
-				backend = cp.Next()
-				nodeName = cp.Name
-				p.returnPool(cp, read)
+			/* Skip replicas that may not have the client's recent writes. */
+			if after != nil && !p.hasReplayed(name, p.masters[part.cluster], after) {
+				continue
 			}
 
-			/* Update the query count for the node being used. */
-			p.lock.Lock()
-			p.Stats[nodeName] += 1
-			p.lock.Unlock()
+			if cp, ok := p.pools[poolKey{name, part}]; ok {
+				if name == canary.Node {
+					canaryPool = cp
+					continue
+				}
 
-			/* Relay message to client and backend */
-			if _, err = connect.Send(backend, message[:length]); err != nil {
-				log.Debugf("Error sending message to backend %s", backend.RemoteAddr())
-				log.Debugf("Error: %s", err.Error())
+				backends = append(backends, Backend{
+					Name:    name,
+					Pool:    cp,
+					Weight:  p.nodes[name].Weight,
+					Latency: p.latency[name],
+				})
 			}
+		}
 
-			/*
-			 * Continue to read from the backend until a 'ReadyForQuery' message is
-			 * is found.
-			 */
-			for !done {
-				if message, length, err = connect.Receive(backend); err != nil {
-					log.Debugf("Error receiving response from backend %s", backend.RemoteAddr())
-					log.Debugf("Error: %s", err.Error())
-					done = true
-				}
+		if canaryPool != nil && inCanary(canary.Percent, affinity) {
+			return canaryPool
+		}
 
-				messageType := protocol.GetMessageType(message[:length])
+		if len(backends) > 0 && affinity != "" {
+			return p.pools[poolKey{affinityBackend(backends, affinity), part}]
+		} else if len(backends) > 0 {
+			return p.pools[poolKey{p.balancer.Next(backends), part}]
+		}
+	}
 
-				/*
-				 * Examine all of the messages in the buffer and determine if any of
-				 * them are a ReadyForQuery message.
-				 */
-				for start := 0; start < length; {
-					messageType = protocol.GetMessageType(message[start:])
-					messageLength := protocol.GetMessageLength(message[start:])
+	return p.pools[poolKey{p.masters[part.cluster], part}]
+}
 
-					/*
-					 * Calculate the next start position, add '1' to the message
-					 * length to account for the message type.
-					 */
-					start = (start + int(messageLength) + 1)
-				}
+// queryTimeout returns how long a query may run on the partition's pools
+// before it is canceled. Zero means that queries are never canceled.
+func (p *Proxy) queryTimeout(part partition) time.Duration {
+	p.poolLock.Lock()
+	defer p.poolLock.Unlock()
 
-				if _, err = connect.Send(client, message[:length]); err != nil {
-					log.Debugf("Error sending response to client %s", client.RemoteAddr())
-					log.Debugf("Error: %s", err.Error())
-					done = true
-				}
+	return time.Duration(p.partitions[part].QueryTimeout) * time.Second
+}
 
-				done = (messageType == protocol.ReadyForQueryMessageType)
-			}
+// PoolStats returns the statistics of each pool.
+func (p *Proxy) PoolStats() []pool.Stats {
+	p.poolLock.Lock()
+	defer p.poolLock.Unlock()
 
-			/*
-			 * If at the end of a statement block or not part of statment block,
-			 * then return the connection to the pool.
-			 */
-			if !statementBlock {
-				/*
-				 * Toggle 'end' such that a new connection will be fetched on the
-				 * next query.
-				 */
-				if end {
-					end = false
-				}
+	stats := make([]pool.Stats, 0, len(p.pools))
 
-				/* Return the backend to the pool it belongs to. */
-				cp.Return(backend)
-			}
+	for _, cp := range p.pools {
+		stats = append(stats, cp.Stats())
+	}
+
+	return stats
+}
+
+// Promote designates the named node as the master of its cluster and replaces
+// its pools with new pools connected to it. The pools for the previous master
+// are closed and that node no longer receives any queries.
+func (p *Proxy) Promote(name string) error {
+	node, ok := config.GetNodes()[name]
+
+	if !ok {
+		return fmt.Errorf("unknown node '%s'", name)
+	}
+
+	log.Infof("Promoting node '%s' to master", name)
+
+	cluster := node.Cluster
+
+	p.poolLock.Lock()
+
+	partitions := make(map[partition]config.PartitionConfig)
+
+	for part, partitionConfig := range p.partitions {
+		if part.cluster == cluster {
+			partitions[part] = partitionConfig
+		}
+	}
+
+	p.poolLock.Unlock()
+
+	/* Establish the new write pools before switching over to them. */
+	newPools := make(map[poolKey]*pool.Pool, len(partitions))
+
+	for part, partitionConfig := range partitions {
+		newPools[poolKey{name, part}] = p.createPool(name, node, partitionConfig)
+	}
+
+	p.poolLock.Lock()
+
+	oldMaster := p.masters[cluster]
+
+	var oldPools []*pool.Pool
+
+	for key, oldPool := range p.pools {
+		if key.node == oldMaster || key.node == name {
+			oldPools = append(oldPools, oldPool)
+			delete(p.pools, key)
+		}
+	}
+
+	for key, newPool := range newPools {
+		p.pools[key] = newPool
+	}
+
+	p.masters[cluster] = name
+
+	replicas := make([]string, 0, len(p.replicas[cluster]))
+	for _, replica := range p.replicas[cluster] {
+		if replica != name && replica != oldMaster {
+			replicas = append(replicas, replica)
 		}
 	}
+	p.replicas[cluster] = replicas
+
+	p.poolLock.Unlock()
+
+	config.SetMaster(name)
+
+	for _, oldPool := range oldPools {
+		metrics.UnregisterPool(oldPool)
+		oldPool.Close()
+	}
+
+	log.Infof("Node '%s' is now the master", name)
+
+	return nil
+}
+
+// Route determines how the clients of a listener are routed, along with any
+// settings of the listener that override those of the proxy.
+type Route struct {
+	// Cluster is the cluster that serves the clients. If empty, then clients
+	// are served by the cluster their database is mapped to, if any.
+	Cluster string
+
+	// Role forces every query to the master or to the replicas. If empty, then
+	// queries are routed by their annotations.
+	Role string
+
+	// SSL is the SSL configuration of the listener. If nil, then the proxy's
+	// is used.
+	SSL *common.SSLConfig
+
+	// ProxyProtocol determines whether the listener's clients send a PROXY
+	// protocol header. If nil, then the proxy's setting is used.
+	ProxyProtocol *config.ProxyProtocolConfig
+}
+
+// HandleConnection handle an incoming connection to the proxy, routing it as
+// configured for the listener that accepted it.
+func (p *Proxy) HandleConnection(client net.Conn, route Route) {
+	metrics.ClientConnections.Inc()
+	defer metrics.ClientConnections.Dec()
+
+	defer func() {
+		client.Close()
+	}()
+
+	/* Refuse new clients once the proxy has started shutting down. */
+	if !p.startClient() {
+		pgError := protocol.Error{
+			Severity: protocol.ErrorSeverityFatal,
+			Code:     protocol.ErrorCodeCannotConnectNow,
+			Message:  "the proxy is shutting down",
+		}
+
+		connect.Send(client, pgError.GetMessage())
+		return
+	}
+
+	defer p.active.Done()
+
+	/*
+	 * If the proxy is behind a load balancer, then read the PROXY protocol
+	 * header so that the original client's address is reported instead of the
+	 * load balancer's.
+	 */
+	proxyProtocol := config.GetProxyConfig().ProxyProtocol
+
+	if route.ProxyProtocol != nil {
+		proxyProtocol = *route.ProxyProtocol
+	}
+
+	if proxyProtocol.Enable {
+		conn, err := connect.ReadProxyHeader(client, proxyProtocol)
+
+		if err != nil {
+			log.Errorf("Client: %s - rejected, invalid PROXY header", client.RemoteAddr())
+			log.Errorf("Error: %s", err.Error())
+			return
+		}
+
+		client = conn
+	}
+
+	/*
+	 * Turn the client away if clients are connecting faster than allowed,
+	 * either in total or from its address, before any SSL handshake or other
+	 * work is done for it. The address is taken from the PROXY header, if
+	 * the listener expects one.
+	 */
+	if !p.allowConnection(client) {
+		pgError := protocol.Error{
+			Severity: protocol.ErrorSeverityFatal,
+			Code:     protocol.ErrorCodeTooManyConnections,
+			Message:  "too many connection attempts, try again later",
+		}
+
+		connect.Send(client, pgError.GetMessage())
+		log.Errorf("Client: %s - rejected, connection rate limit exceeded", client.RemoteAddr())
+		emitRejected(client, "connection rate limit exceeded")
+		return
+	}
+
+	/*
+	 * A client that connects but does not complete its startup message and
+	 * any SSL negotiation within the startup timeout is disconnected, so that
+	 * it cannot hold the connection open indefinitely.
+	 */
+	client.SetDeadline(connect.Deadline(config.GetStartupTimeout()))
+
+	/* Trace the client's connection up to its authentication. */
+	connectCtx, connectSpan := tracing.Start(context.Background(), "client.connect",
+		attribute.String("net.peer.address", client.RemoteAddr().String()))
+	defer connectSpan.End()
+
+	/* Get the client startup message. */
+	message, length, ok := receiveStartup(client)
+
+	if !ok {
+		return
+	}
+
+	/* Get the protocol from the startup message.*/
+	version := protocol.GetVersion(message)
+
+	/*
+	 * A client that prefers GSSAPI encryption asks for it before anything
+	 * else. The proxy does not support it, so it declines, after which the
+	 * client goes on to send an SSL request or its startup message, as it
+	 * would to a server without GSSAPI support.
+	 */
+	if version == protocol.GSSENCRequestCode {
+		log.Debugf("Client: %s - declining GSSAPI encryption", client.RemoteAddr())
+
+		connect.Send(client, []byte{protocol.GSSENCNotAllowed})
+
+		if message, length, ok = receiveStartup(client); !ok {
+			return
+		}
+
+		version = protocol.GetVersion(message)
+	}
+
+	sslConfig := connect.GetListenerSSLConfig(route.SSL)
+
+	/* Handle the case where the startup message was an SSL request. */
+	if version == protocol.SSLRequestCode {
+		var tlsConfig *tls.Config
+		var err error
+
+		sslResponse := protocol.NewMessageBuffer([]byte{})
+
+		if sslConfig.Enable && sslConfig.SSLMode != connect.SSL_MODE_DISABLE {
+			if tlsConfig, err = connect.GetServerTLSConfig(sslConfig); err != nil {
+				log.Error("Error loading the proxy SSL certificate and key.")
+				log.Errorf("Error: %s", err.Error())
+			}
+		}
+
+		/* Determine which SSL response to send to client. */
+		if tlsConfig != nil {
+			sslResponse.WriteByte(protocol.SSLAllowed)
+		} else {
+			sslResponse.WriteByte(protocol.SSLNotAllowed)
+		}
+
+		/*
+		 * Send the SSL response back to the client and wait for it to send the
+		 * regular startup packet.
+		 */
+		connect.Send(client, sslResponse.Bytes())
+
+		/* Upgrade the client connection if required. */
+		if tlsConfig != nil {
+			if client, err = connect.UpgradeServerConnection(client, tlsConfig); err != nil {
+				log.Errorf("Client: %s - SSL handshake failed", client.RemoteAddr())
+				log.Errorf("Error: %s", err.Error())
+				return
+			}
+		}
+
+		/*
+		 * Re-read the startup message from the client. It is possible that the
+		 * client might not like the response given and as a result it might
+		 * close the connection. This is not an 'error' condition as this is an
+		 * expected behavior from a client.
+		 */
+		if message, length, ok = receiveStartup(client); !ok {
+			return
+		}
+	}
+
+	/*
+	 * A cancel request is sent on a new connection and does not expect a
+	 * response, so forward it and close the connection.
+	 */
+	if protocol.GetVersion(message) == protocol.CancelRequestCode {
+		p.cancel(message[:length])
+		return
+	}
+
+	/* Only protocol 3.0 is spoken, so settle on it with the client. */
+	negotiated, ok := negotiateVersion(client, message[:length])
+
+	if !ok {
+		return
+	}
+
+	client.SetDeadline(time.Time{})
+
+	/*
+	 * Wait for a client slot if the maximum number of clients are connected,
+	 * or reject the client if none becomes free in time.
+	 */
+	if !p.admitClient() {
+		pgError := protocol.Error{
+			Severity: protocol.ErrorSeverityFatal,
+			Code:     protocol.ErrorCodeTooManyConnections,
+			Message:  "too many clients already",
+		}
+
+		connect.Send(client, pgError.GetMessage())
+		log.Errorf("Client: %s - rejected, too many clients", client.RemoteAddr())
+		emitRejected(client, "too many clients")
+		return
+	}
+
+	defer p.releaseClient()
+
+	/*
+	 * If the proxy requires SSL connections, then reject clients that did not
+	 * upgrade their connection. Verifying client certificates implies that
+	 * SSL is required. As with PostgreSQL, clients connecting over a Unix
+	 * socket are not required to use SSL.
+	 */
+	_, local := client.(*net.UnixConn)
+
+	if _, ok := client.(*tls.Conn); !ok && !local && sslConfig.Enable &&
+		(sslConfig.SSLMode == connect.SSL_MODE_REQUIRE || sslConfig.ClientCert != "") {
+		pgError := protocol.Error{
+			Severity: protocol.ErrorSeverityFatal,
+			Code:     protocol.ErrorCodeInvalidAuthorizationSpecification,
+			Message:  "SSL connection is required",
+		}
+
+		connect.Send(client, pgError.GetMessage())
+		log.Errorf("Client: %s - rejected non-SSL connection", client.RemoteAddr())
+		return
+	}
+
+	/*
+	 * Validate that the client certificate matches the user it is connecting
+	 * as, if required.
+	 */
+	if !local && !connect.ValidateClientCertificate(client, message, sslConfig) {
+		pgError := protocol.Error{
+			Severity: protocol.ErrorSeverityFatal,
+			Code:     protocol.ErrorCodeInvalidAuthorizationSpecification,
+			Message:  "certificate authentication failed",
+		}
+
+		connect.Send(client, pgError.GetMessage())
+		log.Errorf("Client: %s - certificate does not match user", client.RemoteAddr())
+		return
+	}
+
+	/* Clients connecting to the console database are served by the proxy. */
+	parameters := connect.GetStartupParameters(message)
+
+	if console := config.GetConsoleConfig(); console.Enable &&
+		parameters["database"] == console.Database {
+		p.handleConsole(client, parameters, negotiated)
+		return
+	}
+
+	/*
+	 * Clients of the proxy's own listeners are served by the cluster that
+	 * their tenant is routed to, or else that their database is mapped to,
+	 * if any.
+	 */
+	cluster := route.Cluster
+
+	if cluster == "" {
+		if tenantCluster, ok := config.GetTenantCluster(startupSettings(parameters)); ok {
+			cluster = tenantCluster
+		} else {
+			cluster = config.GetDatabaseCluster(parameters["database"])
+		}
+	}
+
+	/*
+	 * Validate that the client username and database are the same as that
+	 * which is configured for the proxy connections.
+	 *
+	 * If the the client cannot be validated then send an appropriate PG error
+	 * message back to the client.
+	 */
+	if !connect.ValidateClient(message, cluster) {
+		pgError := protocol.Error{
+			Severity: protocol.ErrorSeverityFatal,
+			Code:     protocol.ErrorCodeInvalidAuthorizationSpecification,
+			Message:  "could not validate user/database",
+		}
+
+		connect.Send(client, pgError.GetMessage())
+		log.Error("Could not validate client")
+		return
+	}
+
+	/*
+	 * Apply the startup parameters from the configuration, and relay the
+	 * resulting parameters to the master when the client is authenticated.
+	 */
+	if overrideStartupParameters(client, parameters) {
+		options := make(map[string]string, len(parameters))
+
+		for name, value := range parameters {
+			if name != "user" && name != "database" {
+				options[name] = value
+			}
+		}
+
+		message = protocol.CreateStartupMessage(parameters["user"], parameters["database"], options)
+		length = len(message)
+	}
+
+	/* Determine the partition of the pools that the client will use. */
+	part := partition{cluster, parameters["database"], parameters["user"]}
+
+	/*
+	 * Clients that send the same value of the affinity parameter read from
+	 * the same replica.
+	 */
+	var affinity string
+
+	if name := config.GetAffinity(); name != "" {
+		affinity = parameters[name]
+	}
+
+	/* Register the session so that the client can cancel its queries. */
+	s := p.newSession(client, part)
+	defer p.removeSession(s)
+
+	attributes := traceAttributes(client, part)
+	connectSpan.SetAttributes(attributes...)
+
+	/* Authenticate the client against the appropriate backend. */
+	log.Infof("Client: %s - authenticating", client.RemoteAddr())
+	_, authSpan := tracing.Start(connectCtx, "client.authenticate")
+	recorder := newStatusRecorder(negotiate(client, negotiated))
+
+	client.SetDeadline(connect.Deadline(config.GetAuthTimeout()))
+	authenticated, err := connect.AuthenticateClient(recorder, cluster, message, length,
+		s.processID, s.secretKey)
+	client.SetDeadline(time.Time{})
+
+	if !authenticated {
+		authSpan.SetStatus(codes.Error, "authentication failed")
+		connectSpan.SetStatus(codes.Error, "authentication failed")
+	}
+
+	authSpan.End()
+
+	/*
+	 * If the client could not authenticate then go no further. A client that
+	 * was not rejected by the master is told why it is being disconnected.
+	 */
+	if err == io.EOF {
+		return
+	} else if masterErr, ok := err.(*connect.MasterError); ok {
+		log.Errorf("Client: %s - authentication failed, master node unavailable", client.RemoteAddr())
+		log.Errorf("Error: %s", err.Error())
+		sendFatal(client, masterUnavailableError(masterErr))
+		emitRejected(client, "master node unavailable")
+		return
+	} else if isTimeout(err) {
+		log.Errorf("Client: %s - authentication timed out", client.RemoteAddr())
+		sendFatal(client, protocol.Error{
+			Code:    protocol.ErrorCodeQueryCanceled,
+			Message: "canceling authentication due to timeout",
+			Hint:    fmt.Sprintf("Authentication must complete within %s.", config.GetAuthTimeout()),
+		})
+		emitRejected(client, "authentication timed out")
+		return
+	} else if !authenticated {
+		log.Errorf("Client: %s - authentication failed", client.RemoteAddr())
+		log.Errorf("Error: %s", err.Error())
+		emitRejected(client, "authentication failed")
+		return
+	} else {
+		log.Debugf("Client: %s - authentication successful", client.RemoteAddr())
+	}
+
+	connectSpan.End()
+
+	s.setStartupParameters(parameters)
+	s.status = recorder.status
+
+	/* Record the messages of the session if client traffic is captured. */
+	captured := capture.Enabled()
+
+	if captured {
+		capture.Start(s.processID, part.database, part.username)
+		defer capture.End(s.processID)
+	}
+
+	/* Process the client messages for the life of the connection. */
+	var statementBlock bool
+	var primaryBlock bool
+	var cp *pool.Pool    // The connection pool in use
+	var backend net.Conn // The backend connection in use
+	var read bool
+	var nodeName string
+	var nodeRole string // The role of the node when the backend was assigned
+	var txStatus byte = protocol.TransactionIdle
+	var pending bool    // An unnamed statement is waiting to be executed
+	var listening bool  // The client has run LISTEN and keeps its backend
+	var pinned bool     // A multiplexed client keeps its backend for its session
+	var discarding bool // Messages are ignored until Sync after a rejected batch
+	var unanswered int  // Extended query messages sent since Sync that await a response
+	var xactStart time.Time
+	var mirrored []string // Statements of a transaction waiting to be mirrored
+
+	/*
+	 * The partition of the backend held by the client, or of the next one it
+	 * acquires, is that of a shard while the client uses the shard's backend.
+	 */
+	sessionPart := part
+
+	/*
+	 * Messages from the client and responses from the backend are received
+	 * into the same buffer, as each is relayed before the next is read. The
+	 * buffer may be swapped for another while the client is parked.
+	 */
+	buffer := connect.GetBuffer()
+	defer func() {
+		connect.PutBuffer(buffer)
+	}()
+
+	parkable := canPark(client)
+
+	/*
+	 * The length of each message from the client is validated as the message
+	 * arrives, as messages may be split across reads.
+	 */
+	requests := &messageTracker{maxSize: config.GetMaxMessageSize()}
+
+	/*
+	 * When the client goes away, make sure that a backend that is still held
+	 * by this client is given back to its pool.
+	 */
+	defer func() {
+		if backend != nil {
+			s.setBackend(nil)
+
+			if s.terminated() {
+				p.discardBackend(cp, backend, nodeName, part)
+			} else {
+				p.releaseBackend(cp, backend, part, txStatus)
+			}
+		}
+	}()
+
+	defer p.removeClient(client)
+
+	for {
+		var done bool // for message processing loop.
+
+		/*
+		 * The client is idle while it is not in a transaction. If the proxy is
+		 * draining, then idle clients are disconnected rather than waiting for
+		 * their next message.
+		 */
+		idle := (txStatus == protocol.TransactionIdle && !statementBlock)
+
+		if !p.setIdle(client, idle) {
+			log.Infof("Client: %s - terminating for shutdown", client.RemoteAddr())
+			terminateClient(client)
+			return
+		}
+
+		/*
+		 * Close clients that have been idle, or idle in a transaction, for too
+		 * long, so that abandoned sessions do not keep client slots and
+		 * backends.
+		 */
+		client.SetReadDeadline(idleDeadline(idle))
+
+		/*
+		 * A client that listens for notifications receives them while it is
+		 * waiting to send its next message, not only along with a response.
+		 */
+		var relay *notificationRelay
+
+		if listening && backend != nil {
+			relay = startNotificationRelay(client, backend, p.newNotificationRewriter(backend))
+		}
+
+		/*
+		 * A client that holds no backend and is between messages waits
+		 * without a buffer, if the idle wait is 'poll'.
+		 */
+		parked := parkable && backend == nil
+
+		if parked {
+			buffer, err = parkClient(client, buffer)
+		}
+
+		if !parked || err == nil {
+			message, length, err = connect.ReceiveBuffer(client, buffer)
+		}
+
+		if relay != nil {
+			relay.stop()
+		}
+
+		/*
+		 * A batch is only acted on once each message in it is complete, so
+		 * that every message can be inspected and rewritten. The rest of a
+		 * message that was split across reads must arrive within the relay
+		 * timeout, if there is one.
+		 */
+		var midMessage bool
+
+		if err == nil {
+			requests.scan(message[:length], func(messageType byte, first byte) {})
+
+			if requests.err == nil && requests.partial() {
+				midMessage = true
+				message, err = receiveRest(client, requests, message[:length])
+				length = len(message)
+			}
+		}
+
+		client.SetReadDeadline(time.Time{})
+		p.setIdle(client, false)
+		s.setRequested()
+
+		if err != nil {
+			if isTimeout(err) && midMessage {
+				log.Infof("Client: %s - stopped sending partway through a message",
+					client.RemoteAddr())
+
+				sendFatal(client, protocol.Error{
+					Code:    protocol.ErrorCodeConnectionFailure,
+					Message: "terminating connection because a message was not completed in time",
+					Hint:    fmt.Sprintf("Each message must be sent within %s once it has begun.", config.GetRelayTimeout()),
+				})
+				return
+			}
+
+			if isTimeout(err) {
+				log.Infof("Client: %s - terminating idle session", client.RemoteAddr())
+				terminateIdleClient(client, idle)
+				return
+			}
+
+			switch err {
+			case io.EOF:
+				log.Infof("Client: %s - closed the connection", client.RemoteAddr())
+			default:
+				log.Errorf("Error reading from client connection %s", client.RemoteAddr())
+				log.Errorf("Error: %s", err.Error())
+			}
+			return
+		}
+
+		/*
+		 * Nothing more of the stream can be trusted after an invalid message,
+		 * and a backend held by the client may be partway through a batch, so
+		 * it is discarded rather than returned to its pool.
+		 */
+		if requests.err != nil {
+			log.Errorf("Client: %s - protocol violation", client.RemoteAddr())
+			log.Errorf("Error: %s", requests.err.Error())
+			terminateProtocolViolation(client, requests.err)
+
+			if backend != nil {
+				s.setBackend(nil)
+				p.discardBackend(cp, backend, nodeName, part)
+				backend = nil
+			}
+			return
+		}
+
+		if captured {
+			capture.Message(s.processID, message[:length])
+		}
+
+		messageType := protocol.GetMessageType(message)
+
+		if messageType == protocol.TerminateMessageType {
+			log.Infof("Client: %s - disconnected", client.RemoteAddr())
+			return
+		} else if isQueryMessage(messageType) {
+			/*
+			 * After middleware rejects a batch that does not end with a Sync,
+			 * the client's messages are ignored until it sends one, as the
+			 * backend would do after an error.
+			 */
+			if discarding {
+				if containsMessageType(message[:length], protocol.SyncMessageType) {
+					discarding = false
+					connect.Send(client, protocol.CreateReadyForQueryMessage(txStatus))
+				}
+				continue
+			}
+
+			/*
+			 * Pass the batch through the middleware, which may rewrite it,
+			 * choose how it is routed, or answer or reject it itself.
+			 */
+			req := &Request{
+				Client:   client.RemoteAddr(),
+				User:     part.username,
+				Database: part.database,
+			}
+
+			request, routing, response, rejected := handleRequest(req, message[:length])
+
+			if rejected != nil {
+				log.Infof("Client: %s - batch rejected by middleware: %s",
+					client.RemoteAddr(), rejected.Error())
+			} else if response == nil {
+				/* Batches that break a rule of the query firewall are rejected too. */
+				if rejected = checkFirewall(part, request); rejected != nil {
+					log.Infof("Client: %s - batch blocked by the firewall: %s",
+						client.RemoteAddr(), rejected.Error())
+				}
+			}
+
+			if rejected != nil {
+				response, discarding = rejectRequest(request, rejected, txStatus)
+			}
+
+			if response != nil {
+				if _, err = connect.Send(client, response); err != nil {
+					log.Debugf("Error sending response to client %s", client.RemoteAddr())
+					log.Debugf("Error: %s", err.Error())
+				}
+
+				s.addTraffic(int64(len(request)), int64(len(response)))
+				continue
+			}
+
+			var query string
+			var simple, parsed, executed, sync, flush bool
+			var changes []parameterChange
+			var cacheTTL time.Duration
+			var key cacheKey
+			var cacheable bool
+
+			/*
+			 * A single read from the client may contain several extended query
+			 * messages, e.g. Parse/Bind/Describe/Execute/Sync. Examine each of
+			 * them to determine how the batch should be routed.
+			 */
+			messages := getMessages(request)
+
+			for _, m := range messages {
+				switch protocol.GetMessageType(m) {
+				case protocol.QueryMessageType:
+					query = getQuery(m)
+					simple = true
+					executed = true
+					sync = true
+				case protocol.ParseMessageType:
+					_, q := getParse(m)
+
+					if query == "" {
+						query = q
+					}
+
+					parsed = true
+				case protocol.ExecuteMessageType:
+					executed = true
+				case protocol.SyncMessageType:
+					sync = true
+				case protocol.FlushMessageType:
+					flush = true
+				}
+			}
+
+			/*
+			 * The unnamed prepared statement must be bound and executed on the
+			 * same backend it was parsed on.
+			 */
+			if executed {
+				pending = false
+			} else if parsed {
+				pending = true
+			}
+
+			/*
+			 * A batch that needs a new backend is routed to the shard that its
+			 * shard key belongs to, if sharding is enabled, and otherwise to
+			 * the client's own cluster.
+			 */
+			if backend == nil {
+				part = sessionPart
+
+				if shard, ok := p.getShard(query); ok {
+					part.cluster = shard
+				}
+			}
+
+			/*
+			 * If the batch contains a query, then it can have read/write
+			 * annotations attached to it. Therefore, we need to process it and
+			 * determine which backend we need to send it to.
+			 */
+			if query != "" {
+				annotations := getAnnotations(query)
+
+				if annotations[StartAnnotation] {
+					statementBlock = true
+					primaryBlock = annotations[PrimaryAnnotation]
+				} else if annotations[EndAnnotation] {
+					statementBlock = false
+					primaryBlock = false
+				}
+
+				/*
+				 * Statements of a primary block, or of a session pinned with
+				 * 'SET proxy.route', always go to the master.
+				 */
+				primaryPinned := primaryBlock || s.pinnedToPrimary()
+
+				read = annotations[ReadAnnotation] && !primaryPinned
+
+				/* Track the session parameters changed by the query. */
+				if simple {
+					changes = getParameterChanges(query)
+				}
+
+				/*
+				 * If query analysis is enabled, then a simple query that is
+				 * not annotated can still be routed to a replica if it only
+				 * reads data and the client is not in a transaction.
+				 */
+				if !read && simple && !statementBlock && !primaryPinned &&
+					txStatus == protocol.TransactionIdle &&
+					config.GetProxyConfig().QueryAnalysis {
+					read = isReadOnlyQuery(query)
+				}
+
+				/*
+				 * The results of a read query that is annotated with a cache
+				 * TTL can be cached, if it is run outside of a transaction.
+				 */
+				if cacheTTL = getCacheTTL(query); cacheTTL > 0 && !statementBlock && !primaryPinned &&
+					txStatus == protocol.TransactionIdle &&
+					(annotations[ReadAnnotation] || isReadOnlyQuery(query)) {
+					key, cacheable = getCacheKey(part, s.parameterStatements(), messages)
+				}
+			}
+
+			/*
+			 * Answer the query from the cache if its results are there, without
+			 * using a backend at all.
+			 */
+			if cacheable {
+				if response, ok := p.getCachedResult(key); ok {
+					auditMessages(client, part, cacheNodeName, request)
+
+					sent, err := connect.SendBuffers(client, response)
+
+					if err != nil {
+						log.Debugf("Error sending response to client %s", client.RemoteAddr())
+						log.Debugf("Error: %s", err.Error())
+					}
+
+					s.addTraffic(int64(len(request)), sent)
+
+					p.updateStats(part.database, func(stats *databaseStats) {
+						stats.received += int64(len(request))
+						stats.sent += sent
+						stats.queryCount++
+						stats.xactCount++
+					})
+
+					continue
+				}
+			}
+
+			/* Clients of the read and write listeners are routed by listener. */
+			switch route.Role {
+			case common.NODE_ROLE_MASTER:
+				read = false
+			case common.NODE_ROLE_REPLICA:
+				read = true
+			}
+
+			/* Middleware has the final say in how the batch is routed. */
+			switch routing {
+			case RouteRead:
+				read = true
+			case RouteWrite:
+				read = false
+			}
+
+			/*
+			 * While the proxy is paused, batches that need a new backend are
+			 * held until it resumes, so that backends can be restarted or
+			 * switched over between transactions.
+			 */
+			if backend == nil {
+				p.waitResume(s)
+			}
+
+			/*
+			 * In read-only mode, batches that need a new backend of the master
+			 * are rejected. Clients that already hold a backend, e.g. in a
+			 * transaction, may still finish with it.
+			 */
+			if backend == nil && !read && p.isReadOnly() {
+				pgError := &protocol.Error{
+					Severity: protocol.ErrorSeverityError,
+					Code:     protocol.ErrorCodeReadOnlySQLTransaction,
+					Message:  "the proxy is in read-only mode",
+					Hint:     "Retry once maintenance of the database has finished.",
+				}
+
+				response, discarding = rejectRequest(request, pgError, txStatus)
+				pending = false
+
+				if _, err = connect.Send(client, response); err != nil {
+					log.Debugf("Error sending response to client %s", client.RemoteAddr())
+					log.Debugf("Error: %s", err.Error())
+				}
+
+				s.addTraffic(int64(len(request)), int64(len(response)))
+				continue
+			}
+
+			/*
+			 * Trace the query, as part of the application's trace if the query
+			 * carries its trace context.
+			 */
+			queryCtx, querySpan := tracing.Start(tracing.FromQuery(query), "query", attributes...)
+
+			/*
+			 * A read query sent on a backend acquired for it can be retried on
+			 * another replica if the backend fails before responding, as
+			 * nothing depends on the state of the failed backend.
+			 */
+			retryable := backend == nil && read && sync && !statementBlock &&
+				!pending && !listening
+
+			/*
+			 * If a backend is not already held by this client, then fetch a new
+			 * backend to receive the message.
+			 */
+			if backend == nil {
+				_, routeSpan := tracing.Start(queryCtx, "query.route",
+					attribute.Bool("proxy.read", read))
+
+				if cp = p.getPool(read, part, affinity, "", s.readAfter()); cp == nil {
+					pgError := noPoolError(part)
+
+					sendFatal(client, pgError)
+					log.Errorf("Client: %s - no pool available", client.RemoteAddr())
+
+					routeSpan.SetStatus(codes.Error, pgError.Message)
+					routeSpan.End()
+					querySpan.SetStatus(codes.Error, pgError.Message)
+					querySpan.End()
+					return
+				}
+
+				routeSpan.SetAttributes(attribute.String("proxy.node", cp.Name))
+				routeSpan.End()
+
+				_, acquireSpan := tracing.Start(queryCtx, "query.acquire")
+
+				s.setWaiting()
+				waitStart := time.Now()
+
+				/* Open another connection if none is idle and there is room. */
+				p.growPool(cp, part)
+
+				/*
+				 * Wait in line for a backend, and give up on the client if none
+				 * is available in time, or at once if the line is full.
+				 */
+				backend, err = p.acquireBackend(cp, part)
+
+				/*
+				 * A pool that is closed while the client waits, e.g. by a
+				 * reload, is replaced by the pool that now serves the client.
+				 */
+				if err == pool.ErrPoolClosed {
+					if next := p.getPool(read, part, affinity, "", s.readAfter()); next != nil {
+						cp = next
+						p.growPool(cp, part)
+						backend, err = p.acquireBackend(cp, part)
+					}
+				}
+
+				if err != nil {
+					pgError := exhaustedError(cp, err)
+
+					connect.Send(client, pgError.GetMessage())
+					log.Errorf("Client: %s - no connection to '%s': %s",
+						client.RemoteAddr(), cp.Name, err.Error())
+
+					events.Emit(events.Event{
+						Type:    events.TypePoolExhausted,
+						Node:    cp.Name,
+						Client:  client.RemoteAddr().String(),
+						Message: fmt.Sprintf("no connection to node '%s': %s", cp.Name, err.Error()),
+					})
+
+					p.updateStats(part.database, func(stats *databaseStats) {
+						stats.waitTime += time.Since(waitStart)
+					})
+
+					acquireSpan.SetStatus(codes.Error, pgError.Message)
+					acquireSpan.End()
+					querySpan.SetStatus(codes.Error, pgError.Message)
+					querySpan.End()
+					return
+				}
+
+				nodeName, nodeRole = cp.Name, p.nodeRole(cp.Name)
+				s.setBackend(backend)
+
+				/*
+				 * The backend may have been used by another client, so apply
+				 * this client's session parameters to it.
+				 */
+				p.replayParameters(s, backend)
+				p.reconcileStatus(s, backend)
+				p.prepareStatements(s, backend)
+
+				p.updateStats(part.database, func(stats *databaseStats) {
+					stats.waitTime += time.Since(waitStart)
+				})
+
+				acquireSpan.End()
+			}
+
+			querySpan.SetAttributes(attribute.String("proxy.node", nodeName))
+
+			/* A transaction starts with the first query sent while idle. */
+			if txStatus == protocol.TransactionIdle {
+				xactStart = time.Now()
+			}
+
+			queryStart := time.Now()
+			received := int64(len(request))
+			var sent int64
+
+			/* Update the query count for the node being used. */
+			if executed {
+				p.lock.Lock()
+				p.Stats[nodeName] += 1
+				p.lock.Unlock()
+
+				metrics.Queries.WithLabelValues(nodeName, nodeRole).Inc()
+			}
+
+			auditMessages(client, part, nodeName, request)
+
+			/*
+			 * Relay message to client and backend, using the backend's names
+			 * for the client's prepared statements. If they cannot be renamed,
+			 * then the client keeps the backend, where its statements are.
+			 */
+			batch, renamed := p.rewriteStatements(s, backend, request)
+
+			if !renamed {
+				log.Infof("Client: %s - prepared statements could not be renamed, keeping backend %s",
+					client.RemoteAddr(), backend.RemoteAddr())
+				pinned = true
+			}
+
+			/*
+			 * The query executes until the backend starts to respond, after
+			 * which the results are streamed to the client.
+			 */
+			_, executeSpan := tracing.Start(queryCtx, "query.execute")
+			var streamSpan trace.Span
+
+			/*
+			 * A backend that does not accept the batch within the relay
+			 * timeout is given up on, as it would never respond to it.
+			 */
+			relayTimeout := config.GetRelayTimeout()
+			var stalled bool
+
+			backend.SetWriteDeadline(connect.Deadline(relayTimeout))
+
+			if _, err = connect.Send(backend, batch); err != nil {
+				metrics.BackendErrors.WithLabelValues(nodeName).Inc()
+				log.Debugf("Error sending message to backend %s", backend.RemoteAddr())
+				log.Debugf("Error: %s", err.Error())
+
+				stalled = isTimeout(err)
+			}
+
+			backend.SetWriteDeadline(time.Time{})
+
+			metrics.BytesProxied.WithLabelValues(metrics.DirectionClientToBackend).Add(float64(len(request)))
+
+			/*
+			 * Continue to read from the backend until a 'ReadyForQuery' message is
+			 * is found. The backend only sends a 'ReadyForQuery' message in
+			 * response to a query or a sync, otherwise the client is expected to
+			 * send more messages first. A batch that ends with Flush instead is
+			 * answered once the backend has responded to each message sent since
+			 * the last Sync, which the client may wait for, as in pipeline mode.
+			 */
+			unanswered += countReplies(messages)
+			done = !sync && !(flush && unanswered > 0)
+
+			responses := &messageTracker{capture: protocol.ParameterStatusMessageType}
+			var failed, changed bool
+			var reported []parameterChange // parameters reported by the backend
+			var result [][]byte            // the response, if it may be cached
+			var resultSize int
+			var violation error
+			var lost bool          // the backend failed and the query could not be retried
+			var clientStalled bool // the client stopped accepting the response
+
+			if stalled {
+				log.Errorf("Client: %s - backend %s stopped accepting messages",
+					client.RemoteAddr(), backend.RemoteAddr())
+
+				s.setBackend(nil)
+				p.discardBackend(cp, backend, nodeName, part)
+				backend = nil
+				done, lost = true, true
+			}
+
+			/*
+			 * Notifications only arrive for clients that listen, and their
+			 * process IDs are replaced with those the clients know.
+			 */
+			listens := !listening && isListenQuery(query)
+
+			var notifications *notificationRewriter
+
+			if (listening || listens) && backend != nil {
+				notifications = p.newNotificationRewriter(backend)
+			}
+
+			errorContext := config.GetErrorContext()
+
+			/*
+			 * The response to a read query that may be retried after a
+			 * transient error is held back until it is known to have
+			 * succeeded, for as long as it fits in the retry buffer.
+			 */
+			policy := config.GetRetryPolicy()
+			holding := retryable && !listens && policy.Attempts > 0
+			var held [][]byte
+			var heldSize, attempts int
+			var transient string // the SQLSTATE of a transient error in the response
+
+			if holding {
+				responses.also = protocol.ErrorMessageType
+			}
+
+			/*
+			 * The response is read into the buffer that holds the batch, so a
+			 * query that may be sent again is copied before it is overwritten.
+			 */
+			var retryRequest []byte
+
+			if retryable {
+				retryRequest = append([]byte(nil), request...)
+			}
+
+			/*
+			 * If sending to the client fails, then keep reading until the
+			 * backend is ready for the next query. A client that does not
+			 * accept the response within the relay timeout is not sent the
+			 * rest of it.
+			 */
+			relay := func(response []byte) {
+				if clientStalled {
+					return
+				}
+
+				client.SetWriteDeadline(connect.Deadline(relayTimeout))
+
+				if _, err := connect.Send(client, response); err != nil {
+					log.Debugf("Error sending response to client %s", client.RemoteAddr())
+					log.Debugf("Error: %s", err.Error())
+
+					clientStalled = isTimeout(err)
+				}
+			}
+
+			/*
+			 * Cancel the query if the backend is not ready for the next one
+			 * within the query timeout. The backend then fails the query with
+			 * a query_canceled error, which is relayed to the client.
+			 */
+			var timer *time.Timer
+			canceled := make(chan struct{})
+
+			if timeout := p.queryTimeout(part); timeout > 0 && !done {
+				timer = time.AfterFunc(timeout, func() {
+					defer close(canceled)
+
+					/* The backend may have changed if the query was retried. */
+					if conn, ok := s.getBackend().(*backendConn); ok {
+						log.Infof("Client: %s - query timed out after %s, canceling on %s",
+							client.RemoteAddr(), timeout, conn.hostPort)
+						cancelBackend(s, conn)
+					}
+				})
+			}
+
+			for !done {
+				/*
+				 * The backend may take as long as it needs to start each message,
+				 * but once one has begun, the rest of it must arrive within the
+				 * relay timeout.
+				 */
+				readDeadline := time.Time{}
+
+				if responses.partial() {
+					readDeadline = connect.Deadline(relayTimeout)
+				}
+
+				backend.SetReadDeadline(readDeadline)
+
+				if message, length, err = connect.ReceiveBuffer(backend, buffer); err != nil {
+					metrics.BackendErrors.WithLabelValues(nodeName).Inc()
+					log.Debugf("Error receiving response from backend %s", backend.RemoteAddr())
+					log.Debugf("Error: %s", err.Error())
+
+					/*
+					 * Retry a read query on another replica, once, if nothing
+					 * has been sent to the client yet.
+					 */
+					if retryable && sent == 0 {
+						retryable = false
+
+						if next, nextPool := p.retryQuery(s, cp, backend, nodeName, part, affinity, retryRequest); next != nil {
+							log.Infof("Client: %s - backend %s failed, retried query on node '%s'",
+								client.RemoteAddr(), backend.RemoteAddr(), nextPool.Name)
+
+							backend, cp, nodeName = next, nextPool, nextPool.Name
+							nodeRole = p.nodeRole(nodeName)
+							continue
+						}
+
+						/* The failed backend has been discarded. */
+						backend = nil
+						lost = true
+					}
+
+					/* A backend that stalled partway through a message is given up on. */
+					if backend != nil && isTimeout(err) {
+						s.setBackend(nil)
+						p.discardBackend(cp, backend, nodeName, part)
+						backend = nil
+						lost = true
+					}
+
+					break
+				}
+
+				if streamSpan == nil {
+					executeSpan.End()
+					_, streamSpan = tracing.Start(queryCtx, "query.stream")
+				}
+
+				var copyIn bool
+				var errorEnds []int // where errors end in the chunk, if they are followed by context
+
+				/*
+				 * Examine all of the messages in the buffer and determine if any of
+				 * them are a ReadyForQuery message. Keep track of the transaction
+				 * status reported by the backend so that pooling decisions can be
+				 * made once the response is complete.
+				 */
+				responses.scan(message[:length], func(messageType byte, first byte) {
+					switch messageType {
+					case protocol.ReadyForQueryMessageType:
+						txStatus = first
+						done = true
+						unanswered = 0
+					case protocol.CopyInResponseMessageType:
+						copyIn = true
+					case protocol.ParseCompleteMessageType,
+						protocol.BindCompleteMessageType,
+						protocol.CloseCompleteMessageType,
+						protocol.NoDataMessageType,
+						protocol.RowDescriptionMessageType,
+						protocol.CommandCompleteMessageType,
+						protocol.EmptyQueryMessageType,
+						protocol.PortalSuspendedMessageType:
+						if !sync && unanswered > 0 {
+							unanswered--
+							done = unanswered == 0
+						}
+					case protocol.ErrorMessageType:
+						failed = true
+
+						/* The backend ignores the rest of the messages until Sync. */
+						if !sync {
+							done = true
+							unanswered = 0
+						}
+
+						if errorContext {
+							errorEnds = append(errorEnds, responses.end)
+						}
+
+						if holding && transient == "" {
+							if code, ok := isTransient(policy, responses.body); ok {
+								transient = code
+							}
+						}
+					case protocol.ParameterStatusMessageType:
+						recordStatus(backend, responses.body)
+						s.observeStatus(responses.body)
+
+						if change, ok := getReportedChange(responses.body); ok {
+							reported = append(reported, change)
+						}
+					}
+				})
+
+				response := message[:length]
+
+				if len(errorEnds) > 0 {
+					response = insertMessage(response, errorEnds, errorContextNotice(s, nodeName, backend))
+				}
+
+				if notifications != nil {
+					response = notifications.rewrite(response)
+				}
+
+				if holding {
+					held = append(held, append([]byte(nil), response...))
+					heldSize += len(response)
+				}
+
+				/*
+				 * Nothing of a query that failed with a transient error has
+				 * reached the client, so it is sent again, up to the number of
+				 * attempts allowed, unless it left a transaction open.
+				 */
+				if holding && done && transient != "" && attempts < policy.Attempts &&
+					txStatus == protocol.TransactionIdle {
+					attempts++
+					metrics.QueryRetries.WithLabelValues(nodeName, transient).Inc()
+
+					next, nextPool := p.retryTransient(s, cp, backend, nodeName, part, affinity, retryRequest, policy)
+
+					if next == nil {
+						/* The backend has been discarded. */
+						backend = nil
+						lost = true
+						break
+					}
+
+					log.Infof("Client: %s - query failed with %s on node '%s', retried on node '%s'",
+						client.RemoteAddr(), transient, nodeName, nextPool.Name)
+
+					backend, cp, nodeName = next, nextPool, nextPool.Name
+					nodeRole = p.nodeRole(nodeName)
+					held, heldSize, transient = nil, 0, ""
+					result, resultSize, sent = nil, 0, 0
+					done, failed, reported = false, false, nil
+					continue
+				}
+
+				/*
+				 * The held response is relayed once it is complete, or once it
+				 * no longer fits in the buffer, after which it is not retried.
+				 */
+				if holding && (done || heldSize > policy.MaxBuffer) {
+					for _, chunk := range held {
+						relay(chunk)
+					}
+
+					holding, held = false, nil
+				} else if !holding {
+					relay(response)
+				}
+
+				metrics.BytesProxied.WithLabelValues(metrics.DirectionBackendToClient).Add(float64(length))
+				sent += int64(length)
+
+				/*
+				 * Keep a copy of a response that may be cached. Each chunk is
+				 * copied once, as the buffer is reused for the next read.
+				 */
+				if cacheable {
+					if resultSize+length <= config.GetCacheConfig().MaxResultSize {
+						result = append(result, append([]byte(nil), response...))
+						resultSize += length
+					} else {
+						cacheable = false
+						result = nil
+					}
+				}
+
+				/*
+				 * The backend is waiting for COPY data from the client, which is
+				 * streamed to it until the client has finished. The backend then
+				 * completes the response as usual.
+				 */
+				if copyIn {
+					if err = copyFromClient(client, backend); err != nil {
+						log.Errorf("Client: %s - COPY failed", client.RemoteAddr())
+						log.Errorf("Error: %s", err.Error())
+
+						if _, ok := err.(*protocol.ViolationError); ok {
+							violation = err
+							break
+						}
+					}
+				}
+			}
+
+			client.SetWriteDeadline(time.Time{})
+
+			if backend != nil {
+				backend.SetReadDeadline(time.Time{})
+			}
+
+			/*
+			 * If the timeout fired, then wait for the cancel request to be sent
+			 * so that it cannot reach the backend once another query has
+			 * started on it.
+			 */
+			if timer != nil && !timer.Stop() {
+				<-canceled
+			}
+
+			executeSpan.End()
+
+			if streamSpan != nil {
+				streamSpan.SetAttributes(attribute.Int64("proxy.bytes_sent", sent))
+				streamSpan.End()
+			}
+
+			if failed {
+				querySpan.SetStatus(codes.Error, "query failed")
+			}
+
+			querySpan.End()
+
+			/*
+			 * The client is disconnected when its query could not be retried
+			 * after its backend failed, as it would be by a failed database.
+			 */
+			if lost {
+				sendFatal(client, protocol.Error{
+					Code:    protocol.ErrorCodeConnectionFailure,
+					Message: "the connection to the database was lost",
+					Detail:  fmt.Sprintf("Node '%s' failed before the query completed.", nodeName),
+					Hint:    "The query may or may not have been executed.",
+				})
+				log.Errorf("Client: %s - backend failed and the query could not be retried",
+					client.RemoteAddr())
+				return
+			}
+
+			/*
+			 * A client that stopped accepting its response has missed part of
+			 * it, so the session cannot continue.
+			 */
+			if clientStalled {
+				log.Errorf("Client: %s - stopped accepting the response", client.RemoteAddr())
+				return
+			}
+
+			/*
+			 * The backend is still waiting for the rest of the COPY data after
+			 * the client sent an invalid message, so it cannot be used again.
+			 */
+			if violation != nil {
+				terminateProtocolViolation(client, violation)
+				s.setBackend(nil)
+				p.discardBackend(cp, backend, nodeName, part)
+				backend = nil
+				return
+			}
+
+			/*
+			 * Record the parameters changed by the query once it has succeeded
+			 * and its transaction has committed, along with the fact that the
+			 * backend now has them. The values the backend reported are staged
+			 * after the changes of the query, so that a rollback in the query
+			 * does not drop the values it restored.
+			 */
+			if len(changes) > 0 && !failed {
+				s.stageParameterChanges(changes)
+			}
+
+			s.stageParameterChanges(reported)
+
+			if s.endTransaction(txStatus) {
+				changed = true
+			}
+
+			if changed {
+				s.syncParameters(backend)
+			}
+
+			/*
+			 * Notifications are delivered to the backend that ran LISTEN, so
+			 * the client keeps the backend for the rest of its session.
+			 */
+			if listens && !failed {
+				log.Infof("Client: %s - listening for notifications, keeping backend %s",
+					client.RemoteAddr(), backend.RemoteAddr())
+
+				listening = true
+
+				if conn, ok := backend.(*backendConn); ok {
+					conn.listening = true
+				}
+			}
+
+			/*
+			 * A multiplexed client that leaves state on its backend which
+			 * cannot be moved to another keeps the backend from then on.
+			 */
+			if !pinned && !failed && cp.Mode == common.POOL_MODE_SESSION && config.GetMultiplex() {
+				if reason, ok := pinsSession(query); ok {
+					log.Infof("Client: %s - %s, keeping backend %s",
+						client.RemoteAddr(), reason, backend.RemoteAddr())
+
+					pinned = true
+				}
+			}
+
+			/* Run the query on the shadow cluster too, if it is mirrored. */
+			if simple && done {
+				mirrored = p.mirrorQuery(part, read, query, txStatus, mirrored)
+			}
+
+			/*
+			 * Cache the results of the query if it succeeded and left the
+			 * session as it was.
+			 */
+			if cacheable && done && !failed && !changed &&
+				txStatus == protocol.TransactionIdle {
+				p.cacheResult(key, result, cacheTTL)
+			}
+
+			/*
+			 * The duration of a query runs from when it was sent until the
+			 * backend is ready for the next one.
+			 */
+			if executed && sync {
+				logSlowQuery(client, part, nodeName, query, time.Since(queryStart))
+				p.recordStatement(query, nodeName, time.Since(queryStart))
+				p.recordLatency(nodeName, read, time.Since(queryStart))
+
+				if read {
+					recordCohort(nodeName, failed, time.Since(queryStart))
+				}
+			}
+
+			/*
+			 * Remember when the client last wrote on the master, so that its
+			 * reads are kept away from replicas that lack the write for a while.
+			 */
+			if executed && !read && tracksWrites() && !isReadOnlyQuery(query) {
+				p.recordWrite(s, part.cluster, txStatus == protocol.TransactionIdle)
+			}
+
+			/* Update the statistics for the session and database. */
+			s.addTraffic(received, sent)
+
+			p.updateStats(part.database, func(stats *databaseStats) {
+				stats.received += received
+				stats.sent += sent
+
+				if executed {
+					stats.queryCount++
+					stats.queryTime += time.Since(queryStart)
+				}
+
+				if sync && txStatus == protocol.TransactionIdle {
+					stats.xactCount++
+					stats.xactTime += time.Since(xactStart)
+				}
+			})
+
+			/*
+			 * Return the backend to the pool it belongs to if the pool mode
+			 * allows it to be released at this point.
+			 */
+			if sync && canRelease(cp.Mode, statementBlock || pending || listening || pinned, txStatus) {
+				s.setBackend(nil)
+
+				if s.terminated() {
+					p.discardBackend(cp, backend, nodeName, part)
+					backend = nil
+					return
+				}
+
+				/* The reset query cannot be run inside a transaction. */
+				if txStatus == protocol.TransactionIdle {
+					if err = resetBackend(backend); err != nil {
+						log.Errorf("Error resetting backend %s", backend.RemoteAddr())
+						log.Errorf("Error: %s", err.Error())
+
+						p.discardBackend(cp, backend, nodeName, part)
+						backend = nil
+					}
+				}
+
+				if backend != nil {
+					cp.Return(backend)
+					backend = nil
+				}
+			}
+
+			/*
+			 * If the backend is now held for the rest of the session, then the
+			 * remaining messages may not need to be examined at all. A backend
+			 * that may still be responding afterwards is discarded, and any
+			 * transaction left open on another is rolled back when it is
+			 * released.
+			 */
+			if backend != nil && sync && p.canPassThrough(s, cp, part) {
+				var idle bool
+
+				notifications := p.newNotificationRewriter(backend)
+
+				if txStatus, idle = passThrough(client, backend, txStatus, notifications); !idle {
+					s.setBackend(nil)
+					p.discardBackend(cp, backend, nodeName, part)
+					backend = nil
+				}
+				return
+			}
+		}
+	}
+}
+
+// startClient registers a new client connection. It returns false if the
+// proxy is draining and no longer accepts clients.
+func (p *Proxy) startClient() bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.draining {
+		return false
+	}
+
+	p.active.Add(1)
+
+	return true
+}
+
+// setIdle records whether the client is idle. It returns false if the client
+// is idle and the proxy is draining, in which case the client should be
+// disconnected.
+func (p *Proxy) setIdle(client net.Conn, idle bool) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.clients[client] = idle
+
+	return !(idle && p.draining)
+}
+
+// SetReadOnly places the proxy into, or takes it out of, read-only mode, in
+// which queries that would be sent to the master are rejected.
+func (p *Proxy) SetReadOnly(readOnly bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if readOnly && !p.readOnly {
+		log.Infof("Read-only mode enabled")
+	} else if !readOnly && p.readOnly {
+		log.Infof("Read-only mode disabled")
+	}
+
+	p.readOnly = readOnly
+}
+
+/* isReadOnly returns whether the proxy is in read-only mode. */
+func (p *Proxy) isReadOnly() bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return p.readOnly
+}
+
+// admitClient reserves a slot for a new client. If the maximum number of
+// clients are connected, then the client waits up to the queue timeout for
+// another client to disconnect. It returns false if no slot became free.
+func (p *Proxy) admitClient() bool {
+	if p.slots == nil {
+		return true
+	}
+
+	select {
+	case p.slots <- struct{}{}:
+		return true
+	default:
+	}
+
+	timeout := time.Duration(config.GetProxyConfig().QueueTimeout) * time.Second
+
+	if timeout <= 0 {
+		return false
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case p.slots <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// releaseClient frees the slot of a client that has disconnected.
+func (p *Proxy) releaseClient() {
+	if p.slots != nil {
+		<-p.slots
+	}
+}
+
+func (p *Proxy) removeClient(client net.Conn) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	delete(p.clients, client)
+}
+
+// Drain gracefully shuts down the proxy. Idle clients are disconnected
+// immediately, while clients in a transaction are given until the timeout to
+// finish it. Any clients remaining after the timeout are disconnected. The
+// pools are closed once all clients are gone or the timeout has expired.
+func (p *Proxy) Drain(timeout time.Duration) {
+	log.Infof("Draining client connections, timeout: %s", timeout)
+
+	/* Clients held by a pause are let through to finish. */
+	p.Resume()
+
+	p.lock.Lock()
+
+	p.draining = true
+
+	for client, idle := range p.clients {
+		if idle {
+			terminateClient(client)
+		}
+	}
+
+	p.lock.Unlock()
+
+	done := make(chan struct{})
+
+	go func() {
+		p.active.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		log.Info("All client connections have been drained.")
+	case <-time.After(timeout):
+		log.Info("Drain timeout expired, terminating remaining clients.")
+
+		p.lock.Lock()
+
+		for client := range p.clients {
+			terminateClient(client)
+		}
+
+		p.lock.Unlock()
+	}
+
+	p.poolLock.Lock()
+	defer p.poolLock.Unlock()
+
+	for key, cp := range p.pools {
+		log.Infof("Closing pool for node '%s', database '%s', user '%s'",
+			key.node, key.database, key.username)
+		cp.Close()
+	}
+
+	for _, lc := range p.lsnConns {
+		lc.close()
+	}
+
+	if err := p.saveStats(); err != nil {
+		log.Errorf("Could not save statistics")
+		log.Errorf("Error: %s", err.Error())
+	}
+}
+
+// idleDeadline returns when a client that is waiting to send its next message
+// times out, depending on whether it is idle or in a transaction. The zero
+// time is returned if there is no timeout.
+func idleDeadline(idle bool) time.Time {
+	proxyConfig := config.GetProxyConfig()
+	timeout := proxyConfig.IdleInTransactionTimeout
+
+	if idle {
+		timeout = proxyConfig.ClientIdleTimeout
+	}
+
+	if timeout <= 0 {
+		return time.Time{}
+	}
+
+	return time.Now().Add(time.Duration(timeout) * time.Second)
+}
+
+// terminateIdleClient notifies the client that its session has timed out.
+// The connection is closed by the caller.
+func terminateIdleClient(client net.Conn, idle bool) {
+	pgError := protocol.Error{
+		Severity: protocol.ErrorSeverityFatal,
+		Code:     protocol.ErrorCodeIdleInTransactionSessionTimeout,
+		Message:  "terminating connection due to idle-in-transaction timeout",
+	}
+
+	if idle {
+		pgError.Code = protocol.ErrorCodeIdleSessionTimeout
+		pgError.Message = "terminating connection due to idle-session timeout"
+	}
+
+	connect.Send(client, pgError.GetMessage())
+}
+
+// receiveStartup reads the next startup message from a client that has not
+// been authenticated yet. It returns false if there is no valid message to be
+// had, after telling the client why if the message was invalid.
+func receiveStartup(client net.Conn) ([]byte, int, bool) {
+	message, length, err := connect.ReceiveStartup(client)
+
+	if err == nil {
+		return message, length, true
+	}
+
+	if _, ok := err.(*protocol.ViolationError); ok {
+		log.Errorf("Client: %s - invalid startup message", client.RemoteAddr())
+		log.Errorf("Error: %s", err.Error())
+		terminateProtocolViolation(client, err)
+	} else if err == io.EOF {
+		log.Info("The client closed the connection.")
+	} else if isTimeout(err) {
+		log.Infof("Client: %s - timed out sending its startup message", client.RemoteAddr())
+	} else {
+		log.Error("Error receiving startup message from client.")
+		log.Errorf("Error: %s", err.Error())
+	}
+
+	return nil, 0, false
+}
+
+// receiveRest reads from the client until the last message of a batch that
+// has been received in part is complete, and returns the whole batch. Each
+// read must arrive within the relay timeout, if there is one. Reading stops
+// early if the client sends an invalid message, which the tracker records.
+func receiveRest(client net.Conn, requests *messageTracker, received []byte) ([]byte, error) {
+	batch := append([]byte(nil), received...)
+
+	chunk := connect.GetBuffer()
+	defer connect.PutBuffer(chunk)
+
+	for requests.partial() && requests.err == nil {
+		client.SetReadDeadline(connect.Deadline(config.GetRelayTimeout()))
+
+		_, length, err := connect.ReceiveBuffer(client, chunk)
+
+		if err != nil {
+			return batch, err
+		}
+
+		requests.scan(chunk[:length], func(messageType byte, first byte) {})
+		batch = append(batch, chunk[:length]...)
+	}
+
+	return batch, nil
+}
+
+/* isTimeout returns whether an error is a connection deadline expiring. */
+func isTimeout(err error) bool {
+	netErr, ok := err.(net.Error)
+
+	return ok && netErr.Timeout()
+}
+
+// terminateProtocolViolation notifies the client that it sent a message that
+// breaks the protocol. The connection is closed by the caller.
+func terminateProtocolViolation(client net.Conn, err error) {
+	sendFatal(client, protocol.Error{
+		Code:    protocol.ErrorCodeProtocolViolation,
+		Message: err.Error(),
+	})
+}
+
+// sendFatal sends an error to a client that is about to be disconnected
+// because of a failure in the proxy, so that its driver reports the reason
+// rather than an unexpected end of the connection. The severity of the error
+// is always FATAL. The connection is closed by the caller.
+func sendFatal(client net.Conn, pgError protocol.Error) {
+	pgError.Severity = protocol.ErrorSeverityFatal
+
+	connect.Send(client, pgError.GetMessage())
+}
+
+// masterUnavailableError describes a failure to authenticate a client because
+// the master node could not be reached or stopped responding.
+func masterUnavailableError(err *connect.MasterError) protocol.Error {
+	detail := fmt.Sprintf("The connection to node '%s' failed while the client was authenticating.",
+		err.Node)
+
+	if isTimeout(err.Err) {
+		detail = fmt.Sprintf("Node '%s' did not respond within the authentication timeout.", err.Node)
+	}
+
+	return protocol.Error{
+		Code:    protocol.ErrorCodeCannotConnectNow,
+		Message: "the master node is unavailable",
+		Detail:  detail,
+		Hint:    "Retry once the master node is available.",
+	}
+}
+
+// noPoolError describes the lack of a pool for the queries of a client, e.g.
+// because its cluster has no master or the user and database are not served.
+func noPoolError(part partition) protocol.Error {
+	detail := fmt.Sprintf("No pool serves database '%s' and user '%s'", part.database, part.username)
+
+	if part.cluster != "" {
+		detail += fmt.Sprintf(" in cluster '%s'", part.cluster)
+	}
+
+	return protocol.Error{
+		Code:    protocol.ErrorCodeCannotConnectNow,
+		Message: "no pool is available for the user/database",
+		Detail:  detail + ".",
+		Hint:    "Check that a healthy master node is configured and that the user and database have pools.",
+	}
+}
+
+// terminateClient notifies the client that the proxy is shutting down and
+// closes its connection.
+func terminateClient(client net.Conn) {
+	pgError := protocol.Error{
+		Severity: protocol.ErrorSeverityFatal,
+		Code:     protocol.ErrorCodeAdminShutdown,
+		Message:  "terminating connection due to administrator command",
+	}
+
+	connect.Send(client, pgError.GetMessage())
+	client.Close()
+}
+
+// countReplies returns the number of messages in a batch that the backend
+// answers with a message of their own before the next Sync: Parse, Bind,
+// Describe, Execute and Close.
+func countReplies(messages [][]byte) int {
+	var replies int
+
+	for _, m := range messages {
+		switch protocol.GetMessageType(m) {
+		case protocol.ParseMessageType,
+			protocol.BindMessageType,
+			protocol.DescribeMessageType,
+			protocol.ExecuteMessageType,
+			protocol.CloseMessageType:
+			replies++
+		}
+	}
+
+	return replies
+}
+
+// isQueryMessage determines if the message type is one of the simple or
+// extended query protocol messages that are relayed to a backend.
+func isQueryMessage(messageType byte) bool {
+	switch messageType {
+	case protocol.QueryMessageType,
+		protocol.ParseMessageType,
+		protocol.BindMessageType,
+		protocol.DescribeMessageType,
+		protocol.ExecuteMessageType,
+		protocol.SyncMessageType,
+		protocol.FlushMessageType,
+		protocol.CloseMessageType:
+		return true
+	}
+
+	return false
+}
+
+// canRelease determines whether a backend connection can be returned to its
+// pool after a response has been relayed to the client.
+//
+// In 'statement' mode the connection is released after every statement unless
+// the client is holding on to it, e.g. inside an annotated statement block. In
+// 'transaction' mode the connection is released once the backend reports that
+// it is no longer in a transaction. In 'session' mode the connection is held
+// until the client disconnects, unless sessions are multiplexed, when it is
+// released as in 'transaction' mode.
+func canRelease(mode string, held bool, txStatus byte) bool {
+	switch mode {
+	case common.POOL_MODE_SESSION:
+		return config.GetMultiplex() && !held && txStatus == protocol.TransactionIdle
+	case common.POOL_MODE_TRANSACTION:
+		return !held && txStatus == protocol.TransactionIdle
+	default:
+		return !held
+	}
+}
+
+// releaseBackend returns a backend that is still held by a client when the
+// client disconnects. If the client left a transaction open, then it is
+// rolled back first so that the next client gets a clean connection. A
+// backend whose rollback fails, or does not finish within the reset timeout,
+// is discarded instead, as its state is unknown.
+func (p *Proxy) releaseBackend(cp *pool.Pool, backend net.Conn, part partition, txStatus byte) {
+	if txStatus != protocol.TransactionIdle {
+		log.Debugf("Rolling back open transaction on backend %s", backend.RemoteAddr())
+
+		if err := rollbackBackend(backend); err != nil {
+			log.Errorf("Error rolling back open transaction on backend %s", backend.RemoteAddr())
+			log.Errorf("Error: %s", err.Error())
+
+			p.discardBackend(cp, backend, cp.Name, part)
+			return
+		}
+	}
+
+	if err := resetBackend(backend); err != nil {
+		log.Errorf("Error resetting backend %s", backend.RemoteAddr())
+		log.Errorf("Error: %s", err.Error())
+
+		p.discardBackend(cp, backend, cp.Name, part)
+		return
+	}
+
+	cp.Return(backend)
+}
+
+/* rollbackBackend rolls back the open transaction of a backend. */
+func rollbackBackend(backend net.Conn) error {
+	timeout := time.Duration(config.GetResetConfig().Timeout) * time.Second
+
+	backend.SetDeadline(connect.Deadline(timeout))
+	defer backend.SetDeadline(time.Time{})
+
+	return execute(backend, "ROLLBACK")
+}
+
+// resetBackend runs the reset query on a backend that is being returned to its
+// pool, if enabled, so that no session state such as prepared statements,
+// temporary tables or advisory locks is left for the next client. An error is
+// returned if the backend could not be reset within the reset timeout, in
+// which case it must be discarded rather than returned to its pool.
+func resetBackend(backend net.Conn) error {
+	resetConfig := config.GetResetConfig()
+
+	backend.SetDeadline(connect.Deadline(time.Duration(resetConfig.Timeout) * time.Second))
+	defer backend.SetDeadline(time.Time{})
+
+	/* Stop the notifications of the previous client, whatever the reset. */
+	if conn, ok := backend.(*backendConn); ok && conn.listening {
+		if err := execute(backend, "UNLISTEN *"); err != nil {
+			return err
+		}
+
+		conn.listening = false
+	}
+
+	if !resetConfig.Enable {
+		return nil
+	}
+
+	log.Debugf("Resetting backend %s", backend.RemoteAddr())
+
+	if err := execute(backend, resetConfig.Query); err != nil {
+		return err
+	}
+
+	/*
+	 * DISCARD ALL returns the session parameters to their initial state. The
+	 * effect of any other reset query is not known, so the parameters of the
+	 * next client will always be replayed.
+	 */
+	if conn, ok := backend.(*backendConn); ok {
+		/*
+		 * The prepared statements may have been deallocated. They are closed
+		 * before being prepared again, so it is safe to assume so.
+		 */
+		conn.prepared = make(map[string]bool)
+
+		if strings.EqualFold(strings.TrimSpace(resetConfig.Query), discardAll) {
+			conn.parameters = ""
+		} else {
+			conn.parameters = unknownParameters
+		}
+	}
+
+	return nil
+}
+
+// execute runs a query on a backend on behalf of the proxy and discards the
+// response. An error is returned if the query fails.
+func execute(backend net.Conn, query string) error {
+	if _, err := connect.Send(backend, protocol.CreateQueryMessage(query)); err != nil {
+		return err
+	}
+
+	return awaitReady(backend)
+}
+
+// awaitReady discards the responses of a backend up to its next ReadyForQuery
+// message. An error is returned if one of them is an ErrorResponse.
+func awaitReady(backend net.Conn) error {
+	var done bool
+	var pgError *protocol.Error
+
+	responses := &messageTracker{
+		capture: protocol.ErrorMessageType,
+		also:    protocol.ParameterStatusMessageType,
+	}
+
+	buffer := connect.GetBuffer()
+	defer connect.PutBuffer(buffer)
+
+	for !done {
+		message, length, err := connect.ReceiveBuffer(backend, buffer)
+
+		if err != nil {
+			return err
+		}
+
+		responses.scan(message[:length], func(messageType byte, first byte) {
+			switch messageType {
+			case protocol.ErrorMessageType:
+				if pgError == nil {
+					pgError = protocol.ParseError(append([]byte{messageType, 0, 0, 0, 0}, responses.body...))
+				}
+			case protocol.ParameterStatusMessageType:
+				recordStatus(backend, responses.body)
+			case protocol.ReadyForQueryMessageType:
+				done = true
+			}
+		})
+	}
+
+	if pgError != nil {
+		return pgError
+	}
+
+	return nil
+}
+
+// queryValue runs a query on a backend on behalf of the proxy and returns the
+// first value of the first row of its result, in text format. An error is
+// returned if the query fails or returns no rows.
+func queryValue(backend net.Conn, query string) (string, error) {
+	if _, err := connect.Send(backend, protocol.CreateQueryMessage(query)); err != nil {
+		return "", err
+	}
+
+	var done, failed, found bool
+	var value string
+
+	responses := &messageTracker{capture: protocol.DataRowMessageType}
+
+	buffer := connect.GetBuffer()
+	defer connect.PutBuffer(buffer)
+
+	for !done {
+		message, length, err := connect.ReceiveBuffer(backend, buffer)
+
+		if err != nil {
+			return "", err
+		}
+
+		responses.scan(message[:length], func(messageType byte, first byte) {
+			switch messageType {
+			case protocol.DataRowMessageType:
+				if !found {
+					value, found = getFirstValue(responses.body)
+				}
+			case protocol.ErrorMessageType:
+				failed = true
+			case protocol.ReadyForQueryMessageType:
+				done = true
+			}
+		})
+	}
+
+	if failed {
+		return "", fmt.Errorf("query '%s' failed", query)
+	} else if !found {
+		return "", fmt.Errorf("query '%s' returned no rows", query)
+	}
+
+	return value, nil
+}
+
+/* getFirstValue reads the first value from the body of a DataRow message. */
+func getFirstValue(body []byte) (string, bool) {
+	row := protocol.NewMessageBuffer(body)
+
+	if count, err := row.ReadInt16(); err != nil || count < 1 {
+		return "", false
+	}
+
+	length, err := row.ReadInt32()
+
+	if err != nil || length < 0 {
+		return "", false
+	}
+
+	value, err := row.ReadBytes(int(length))
+
+	if err != nil {
+		return "", false
+	}
+
+	return string(value), true
+}
+
+// containsMessageType determines if any of the messages in the buffer are of
+// the provided message type.
+func containsMessageType(buffer []byte, messageType byte) bool {
+	for start := 0; start+5 <= len(buffer); {
+		if protocol.GetMessageType(buffer[start:]) == messageType {
+			return true
+		}
+
+		start = (start + int(protocol.GetMessageLength(buffer[start:])) + 1)
+	}
+
+	return false
+}
+
+// exhaustedError creates the error that a client is sent when it could not
+// get a connection from a pool, either because none became available in time
+// or because too many clients were already waiting. The hint names the pool
+// so that it is clear which node, database and user need more connections.
+func exhaustedError(cp *pool.Pool, err error) protocol.Error {
+	message := "no pool connection became available in time"
+
+	switch err {
+	case pool.ErrQueueFull:
+		message = "too many clients are waiting for a pool connection"
+	case pool.ErrPoolClosed:
+		message = "the pool was closed while waiting for a connection"
+	}
+
+	return protocol.Error{
+		Severity: protocol.ErrorSeverityFatal,
+		Code:     protocol.ErrorCodeTooManyConnections,
+		Message:  message,
+		Hint: fmt.Sprintf("The pool of node '%s' for database '%s' and user '%s' "+
+			"has no idle connections. Retry later, or raise pool.capacity.",
+			cp.Name, cp.Database, cp.Username),
+	}
+}
+
+// retryQuery discards a backend that failed before responding to a batch and
+// sends the batch to a backend of another replica instead. The new backend and
+// its pool are returned, or nil if no other backend could be used, in which
+// case the client holds no backend.
+func (p *Proxy) retryQuery(s *session, cp *pool.Pool, backend net.Conn, nodeName string, part partition, affinity string, request []byte) (net.Conn, *pool.Pool) {
+	s.setBackend(nil)
+	p.discardBackend(cp, backend, nodeName, part)
+
+	nextPool := p.getPool(true, part, affinity, nodeName, s.readAfter())
+
+	if nextPool == nil {
+		return nil, nil
+	}
+
+	next, err := p.acquireBackend(nextPool, part)
+
+	if err != nil {
+		return nil, nil
+	}
+
+	s.setBackend(next)
+	p.replayParameters(s, next)
+	p.reconcileStatus(s, next)
+	p.prepareStatements(s, next)
+
+	batch, _ := p.rewriteStatements(s, next, request)
+
+	if _, err = connect.Send(next, batch); err != nil {
+		log.Debugf("Error sending message to backend %s", next.RemoteAddr())
+		log.Debugf("Error: %s", err.Error())
+
+		s.setBackend(nil)
+		p.discardBackend(nextPool, next, nextPool.Name, part)
+
+		return nil, nil
+	}
+
+	return next, nextPool
+}
+
+/* emitRejected reports a client that was turned away. */
+func emitRejected(client net.Conn, reason string) {
+	events.Emit(events.Event{
+		Type:    events.TypeClientRejected,
+		Client:  client.RemoteAddr().String(),
+		Message: "client rejected, " + reason,
+	})
 }
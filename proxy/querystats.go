@@ -0,0 +1,127 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+import (
+	"sort"
+	"time"
+
+	"github.com/crunchydata/crunchy-proxy/config"
+)
+
+// statementKey identifies a normalized query run on a node.
+type statementKey struct {
+	query string
+	node  string
+}
+
+// statementStats holds the execution statistics of a normalized query.
+type statementStats struct {
+	calls     int64
+	totalTime time.Duration
+	maxTime   time.Duration
+}
+
+// StatementStats holds the execution statistics of a normalized query on a
+// node, as reported by the admin server and console.
+type StatementStats struct {
+	Query     string
+	Node      string
+	Calls     int64
+	TotalTime time.Duration
+	MeanTime  time.Duration
+	MaxTime   time.Duration
+}
+
+// recordStatement adds a run of the query on the node to the statistics of
+// its normalized form, if enabled. Once the configured number of statements
+// is tracked, the least called one is discarded to make room for a new one.
+func (p *Proxy) recordStatement(query string, node string, duration time.Duration) {
+	statsConfig := config.GetStatsConfig()
+
+	if !statsConfig.Statements || query == "" {
+		return
+	}
+
+	key := statementKey{query: normalizeQuery(query), node: node}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	stats, ok := p.queryStats[key]
+
+	if !ok {
+		for len(p.queryStats) >= statsConfig.MaxStatements {
+			p.evictStatement()
+		}
+
+		stats = &statementStats{}
+		p.queryStats[key] = stats
+	}
+
+	stats.calls++
+	stats.totalTime += duration
+
+	if duration > stats.maxTime {
+		stats.maxTime = duration
+	}
+}
+
+// evictStatement discards the statistics of the least called statement. The
+// caller must hold the proxy lock.
+func (p *Proxy) evictStatement() {
+	var victim statementKey
+	var fewest int64 = -1
+
+	for key, stats := range p.queryStats {
+		if fewest < 0 || stats.calls < fewest {
+			victim = key
+			fewest = stats.calls
+		}
+	}
+
+	delete(p.queryStats, victim)
+}
+
+// TopStatements returns the statistics of the statements with the largest
+// total execution time, at most limit of them if limit is positive.
+func (p *Proxy) TopStatements(limit int) []StatementStats {
+	p.lock.Lock()
+
+	statements := make([]StatementStats, 0, len(p.queryStats))
+
+	for key, stats := range p.queryStats {
+		statements = append(statements, StatementStats{
+			Query:     key.query,
+			Node:      key.node,
+			Calls:     stats.calls,
+			TotalTime: stats.totalTime,
+			MeanTime:  stats.totalTime / time.Duration(stats.calls),
+			MaxTime:   stats.maxTime,
+		})
+	}
+
+	p.lock.Unlock()
+
+	sort.Slice(statements, func(i, j int) bool {
+		return statements[i].TotalTime > statements[j].TotalTime
+	})
+
+	if limit > 0 && len(statements) > limit {
+		statements = statements[:limit]
+	}
+
+	return statements
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+import (
+	"net"
+	"time"
+
+	"github.com/crunchydata/crunchy-proxy/config"
+)
+
+/* How often the buckets of source addresses that have gone quiet are removed. */
+const bucketPruneInterval = time.Minute
+
+// tokenBucket limits the rate of an event. Tokens are added at the configured
+// rate, up to the burst, and each event takes one.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(burst int, now time.Time) *tokenBucket {
+	return &tokenBucket{tokens: float64(burst), last: now}
+}
+
+/* Add the tokens earned since the bucket was last refilled. */
+func (b *tokenBucket) refill(rate float64, burst int, now time.Time) {
+	b.tokens += now.Sub(b.last).Seconds() * rate
+
+	if b.tokens > float64(burst) {
+		b.tokens = float64(burst)
+	}
+
+	b.last = now
+}
+
+// allowConnection returns whether a new client may connect without exceeding
+// the global rate or the rate of its source address. A client that is turned
+// away does not use up tokens, so it does not count against the limit.
+func (p *Proxy) allowConnection(client net.Conn) bool {
+	rateLimit := config.GetRateLimitConfig()
+
+	if rateLimit.Rate <= 0 && rateLimit.ClientRate <= 0 {
+		return true
+	}
+
+	host, _ := splitAddr(client.RemoteAddr())
+	now := time.Now()
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	var global, source *tokenBucket
+
+	if rateLimit.Rate > 0 {
+		if p.connectionBucket == nil {
+			p.connectionBucket = newTokenBucket(rateLimit.Burst, now)
+		}
+
+		global = p.connectionBucket
+		global.refill(rateLimit.Rate, rateLimit.Burst, now)
+
+		if global.tokens < 1 {
+			return false
+		}
+	}
+
+	if rateLimit.ClientRate > 0 {
+		p.pruneClientBuckets(rateLimit, now)
+
+		source = p.clientBuckets[host]
+
+		if source == nil {
+			source = newTokenBucket(rateLimit.ClientBurst, now)
+			p.clientBuckets[host] = source
+		}
+
+		source.refill(rateLimit.ClientRate, rateLimit.ClientBurst, now)
+
+		if source.tokens < 1 {
+			return false
+		}
+
+		source.tokens--
+	}
+
+	if global != nil {
+		global.tokens--
+	}
+
+	return true
+}
+
+/*
+ * Remove the buckets of source addresses that have not connected for long
+ * enough for their buckets to be full again, as they are the same as new
+ * ones. The proxy lock must be held.
+ */
+func (p *Proxy) pruneClientBuckets(rateLimit config.RateLimitConfig, now time.Time) {
+	if now.Sub(p.bucketsPruned) < bucketPruneInterval {
+		return
+	}
+
+	for host, bucket := range p.clientBuckets {
+		bucket.refill(rateLimit.ClientRate, rateLimit.ClientBurst, now)
+
+		if bucket.tokens >= float64(rateLimit.ClientBurst) {
+			delete(p.clientBuckets, host)
+		}
+	}
+
+	p.bucketsPruned = now
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+/* A connection that only knows the address it came from. */
+type addrConn struct {
+	net.Conn
+	remote net.Addr
+}
+
+func (c addrConn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+/* Create a client connection from the host. */
+func clientFrom(host string) net.Conn {
+	return addrConn{remote: &net.TCPAddr{IP: net.ParseIP(host), Port: 50000}}
+}
+
+func TestTokenBucketRefill(t *testing.T) {
+	now := time.Now()
+	bucket := newTokenBucket(2, now)
+	bucket.tokens = 0
+
+	bucket.refill(4, 2, now.Add(250*time.Millisecond))
+
+	if bucket.tokens != 1 {
+		t.Fatalf("expected 1 token after a quarter second at 4 per second, have %v", bucket.tokens)
+	}
+
+	bucket.refill(4, 2, now.Add(10*time.Second))
+
+	if bucket.tokens != 2 {
+		t.Fatalf("expected the tokens to be capped at the burst, have %v", bucket.tokens)
+	}
+}
+
+func TestAllowConnection(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   string
+		hosts    []string
+		expected []bool
+	}{
+		{
+			name:     "no limit",
+			config:   "server:\n  proxy:\n    hostport: localhost:5432\n",
+			hosts:    []string{"10.0.0.1", "10.0.0.1", "10.0.0.1"},
+			expected: []bool{true, true, true},
+		},
+		{
+			name:     "global limit",
+			config:   "server:\n  proxy:\n    ratelimit:\n      rate: 0.001\n      burst: 2\n",
+			hosts:    []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"},
+			expected: []bool{true, true, false},
+		},
+		{
+			name:     "limit per source address",
+			config:   "server:\n  proxy:\n    ratelimit:\n      clientrate: 0.001\n",
+			hosts:    []string{"10.0.0.1", "10.0.0.1", "10.0.0.2"},
+			expected: []bool{true, false, true},
+		},
+		{
+			name: "client turned away does not use global tokens",
+			config: "server:\n  proxy:\n    ratelimit:\n      rate: 0.001\n      burst: 2\n" +
+				"      clientrate: 0.001\n",
+			hosts:    []string{"10.0.0.1", "10.0.0.1", "10.0.0.2", "10.0.0.3"},
+			expected: []bool{true, false, true, false},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			loadTestConfig(t, test.config)
+
+			p := newTestProxy()
+			p.clientBuckets = make(map[string]*tokenBucket)
+
+			for i, host := range test.hosts {
+				if allowed := p.allowConnection(clientFrom(host)); allowed != test.expected[i] {
+					t.Fatalf("connection %d from %s: expected allowed to be %v", i+1, host, test.expected[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,310 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/crunchydata/crunchy-proxy/common"
+	"github.com/crunchydata/crunchy-proxy/config"
+	"github.com/crunchydata/crunchy-proxy/connect"
+	"github.com/crunchydata/crunchy-proxy/pool"
+	"github.com/crunchydata/crunchy-proxy/protocol"
+	"github.com/crunchydata/crunchy-proxy/util/log"
+)
+
+/* The bounds of the interval at which pool connections are checked. */
+const (
+	minReapInterval = time.Second
+	maxReapInterval = 30 * time.Second
+)
+
+/* The query that checks that an idle pool connection still works. */
+const pingQuery = ";"
+
+// reap runs until the proxy is drained, maintaining the pool connections. It
+// replaces connections that have exceeded the configured 'maxlifetime' or
+// 'idletimeout' and, every 'pinginterval', connections that no longer answer
+// a query. Only idle connections are replaced; a connection that is in use is
+// replaced once it has been returned to its pool. Pools are then topped up to
+// their capacity or, if they are warmed up lazily, to 'minidle' idle
+// connections, so that connections that could not be replaced or established
+// are made up for.
+func (p *Proxy) reap() {
+	var pinged time.Time
+
+	for {
+		maxLifetime, idleTimeout := config.GetConnectionTimeouts()
+		pingInterval := config.GetPingInterval()
+
+		time.Sleep(reapInterval(maxLifetime, idleTimeout, pingInterval))
+
+		p.lock.Lock()
+		draining := p.draining
+		p.lock.Unlock()
+
+		if draining {
+			return
+		}
+
+		ping := pingInterval > 0 && time.Since(pinged) >= pingInterval
+
+		if ping {
+			pinged = time.Now()
+		}
+
+		p.reapPools(maxLifetime, idleTimeout, ping)
+	}
+}
+
+/*
+ * Check a few times within the shortest timeout, so that connections are not
+ * kept for much longer than they should be, and at least as often as the ping
+ * interval.
+ */
+func reapInterval(maxLifetime time.Duration, idleTimeout time.Duration, pingInterval time.Duration) time.Duration {
+	interval := maxReapInterval
+
+	for _, timeout := range []time.Duration{maxLifetime, idleTimeout} {
+		if timeout > 0 && timeout/4 < interval {
+			interval = timeout / 4
+		}
+	}
+
+	if pingInterval > 0 && pingInterval < interval {
+		interval = pingInterval
+	}
+
+	if interval < minReapInterval {
+		interval = minReapInterval
+	}
+
+	return interval
+}
+
+func (p *Proxy) reapPools(maxLifetime time.Duration, idleTimeout time.Duration, ping bool) {
+	p.poolLock.Lock()
+	keys := make([]poolKey, 0, len(p.pools))
+
+	for key := range p.pools {
+		keys = append(keys, key)
+	}
+	p.poolLock.Unlock()
+
+	warmUp, minIdle := config.GetPoolWarmUp()
+
+	for _, key := range keys {
+		p.poolLock.Lock()
+		cp, ok := p.pools[key]
+		node := p.nodes[key.node]
+		partitionConfig := p.partitions[key.partition]
+		p.poolLock.Unlock()
+
+		if !ok {
+			continue
+		}
+
+		expired := cp.Expire(maxLifetime, idleTimeout)
+
+		for _, connection := range expired {
+			connection.Close()
+		}
+
+		if len(expired) > 0 {
+			log.Infof("Replacing %d expired connections to node '%s'", len(expired), key.node)
+		}
+
+		if ping {
+			if broken := pingPool(cp); broken > 0 {
+				log.Infof("Replacing %d broken connections to node '%s'", broken, key.node)
+			}
+		}
+
+		missing := cp.Capacity - cp.Size()
+
+		if warmUp == common.POOL_WARMUP_LAZY && minIdle-cp.Len() < missing {
+			missing = minIdle - cp.Len()
+		}
+
+		addConnections(cp, key.node, node, partitionConfig, missing)
+	}
+}
+
+// pingPool runs a query on each of the idle connections of a pool, within the
+// health check timeout, and discards those that fail. It returns the number of
+// connections discarded.
+func pingPool(cp *pool.Pool) int {
+	var broken int
+
+	timeout := time.Duration(config.GetHealthCheckConfig().Timeout) * time.Second
+
+	for i := cp.Len(); i > 0; i-- {
+		connection := cp.Take()
+
+		if connection == nil {
+			break
+		}
+
+		if err := ping(connection, timeout); err != nil {
+			log.Debugf("Error pinging backend %s", connection.RemoteAddr())
+			log.Debugf("Error: %s", err.Error())
+
+			cp.Discard(connection)
+			broken++
+			continue
+		}
+
+		cp.Restore(connection)
+	}
+
+	return broken
+}
+
+/* ping runs the ping query on a connection within the timeout. */
+func ping(connection net.Conn, timeout time.Duration) error {
+	connection.SetDeadline(time.Now().Add(timeout))
+	defer connection.SetDeadline(time.Time{})
+
+	return execute(connection, pingQuery)
+}
+
+// acquireBackend takes a connection from a pool for a client, waiting up to
+// the acquire timeout for one, unless the pool already has 'maxwaiting'
+// clients waiting, in which case it fails at once. If validation is enabled,
+// then connections are validated first, and those that fail are discarded and
+// replaced until one passes, so that clients are not handed connections that
+// a backend restart has broken.
+func (p *Proxy) acquireBackend(cp *pool.Pool, part partition) (net.Conn, error) {
+	validation := config.GetPoolValidation()
+	timeout := config.GetAcquireTimeout()
+	maxWaiting := config.GetMaxWaiting()
+	deadline := time.Now().Add(timeout)
+
+	for {
+		backend, err := cp.Acquire(timeout, maxWaiting)
+
+		if err != nil || validation == "" {
+			return backend, err
+		}
+
+		if err = validateBackend(backend, validation); err == nil {
+			return backend, nil
+		}
+
+		log.Infof("Replacing broken connection %s to node '%s'", backend.RemoteAddr(), cp.Name)
+		log.Debugf("Error: %s", err.Error())
+
+		p.discardBackend(cp, backend, cp.Name, part)
+
+		/* Validation and replacement count towards the client's wait. */
+		if timeout > 0 {
+			if timeout = time.Until(deadline); timeout <= 0 {
+				return nil, pool.ErrAcquireTimeout
+			}
+		}
+	}
+}
+
+// validateBackend checks that a pool connection still works, within the
+// health check timeout. A 'tcp' check only detects connections that the
+// backend has closed, or on which it has sent an unexpected message such as
+// the FATAL error of a shutdown, and costs no round trip. A 'sync' check sends
+// a Sync message and a 'query' check an empty query, and both wait for the
+// backend to answer.
+func validateBackend(backend net.Conn, validation string) error {
+	timeout := time.Duration(config.GetHealthCheckConfig().Timeout) * time.Second
+
+	switch validation {
+	case common.POOL_VALIDATE_TCP:
+		return checkClosed(backend)
+	case common.POOL_VALIDATE_SYNC:
+		backend.SetDeadline(time.Now().Add(timeout))
+		defer backend.SetDeadline(time.Time{})
+
+		if _, err := connect.Send(backend, protocol.CreateSyncMessage()); err != nil {
+			return err
+		}
+
+		return awaitReady(backend)
+	case common.POOL_VALIDATE_QUERY:
+		return ping(backend, timeout)
+	}
+
+	return nil
+}
+
+/* How long checkClosed waits for a backend to report that it closed. */
+const closedCheckTimeout = time.Millisecond
+
+/*
+ * checkClosed reads from an idle backend, which should have nothing to send,
+ * and returns an error if the connection was closed or the backend sent
+ * something.
+ */
+func checkClosed(backend net.Conn) error {
+	backend.SetReadDeadline(time.Now().Add(closedCheckTimeout))
+	defer backend.SetReadDeadline(time.Time{})
+
+	var buffer [1]byte
+
+	n, err := backend.Read(buffer[:])
+
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		return nil
+	} else if err != nil {
+		return err
+	} else if n > 0 {
+		return fmt.Errorf("unexpected message '%c' from an idle backend", buffer[0])
+	}
+
+	return nil
+}
+
+// addConnections opens up to count new connections to a node for a pool, for
+// as long as the pool has room for them, and returns the number added.
+func addConnections(cp *pool.Pool, name string, node common.Node, partitionConfig config.PartitionConfig, count int) int {
+	var added int
+
+	for ; added < count && cp.Reserve(); added++ {
+		connection, err := connectBackend(name, node, partitionConfig)
+
+		if err != nil {
+			cp.Unreserve()
+			break
+		}
+
+		cp.Add(connection)
+	}
+
+	return added
+}
+
+// growPool opens a connection in the background for a pool that has no idle
+// connection, for a client that is about to wait for one, if the pool has
+// room for it.
+func (p *Proxy) growPool(cp *pool.Pool, part partition) {
+	if cp.Len() > 0 {
+		return
+	}
+
+	p.poolLock.Lock()
+	node := p.nodes[cp.Name]
+	partitionConfig := p.partitions[part]
+	p.poolLock.Unlock()
+
+	go addConnections(cp, cp.Name, node, partitionConfig, 1)
+}
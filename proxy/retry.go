@@ -0,0 +1,89 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package proxy
+
+import (
+	"net"
+
+	"github.com/crunchydata/crunchy-proxy/common"
+	"github.com/crunchydata/crunchy-proxy/config"
+	"github.com/crunchydata/crunchy-proxy/connect"
+	"github.com/crunchydata/crunchy-proxy/pool"
+	"github.com/crunchydata/crunchy-proxy/protocol"
+	"github.com/crunchydata/crunchy-proxy/util/log"
+)
+
+/*
+ * isTransient returns the SQLSTATE of an error message body and whether the
+ * policy retries queries that fail with it.
+ */
+func isTransient(policy config.RetryConfig, body []byte) (string, bool) {
+	code := protocol.ParseError(append([]byte{protocol.ErrorMessageType, 0, 0, 0, 0}, body...)).Code
+
+	for _, c := range policy.Codes {
+		if c == code {
+			return code, true
+		}
+	}
+
+	return code, false
+}
+
+// retryTransient sends a read batch that failed with a transient error again.
+// If the policy prefers another node and a backend of another replica can be
+// acquired, then the failed backend is returned to its pool and the batch is
+// sent to the new one. Otherwise the batch is sent to the same backend. The
+// backend and pool running the batch are returned, or nil if it could not be
+// sent, in which case the backend has been discarded and the client holds
+// none.
+func (p *Proxy) retryTransient(s *session, cp *pool.Pool, backend net.Conn, nodeName string, part partition, affinity string, request []byte, policy config.RetryConfig) (net.Conn, *pool.Pool) {
+	if policy.Node == common.RETRY_NODE_OTHER {
+		if nextPool := p.getPool(true, part, affinity, nodeName, s.readAfter()); nextPool != nil {
+			/* The failed backend is kept until another one is acquired. */
+			if next, err := p.acquireBackend(nextPool, part); err == nil {
+				s.setBackend(nil)
+
+				if err := resetBackend(backend); err != nil {
+					log.Errorf("Error resetting backend %s", backend.RemoteAddr())
+					log.Errorf("Error: %s", err.Error())
+
+					p.discardBackend(cp, backend, nodeName, part)
+				} else {
+					cp.Return(backend)
+				}
+
+				s.setBackend(next)
+				p.replayParameters(s, next)
+				p.reconcileStatus(s, next)
+				p.prepareStatements(s, next)
+
+				backend, cp, nodeName = next, nextPool, nextPool.Name
+			}
+		}
+	}
+
+	batch, _ := p.rewriteStatements(s, backend, request)
+
+	if _, err := connect.Send(backend, batch); err != nil {
+		log.Debugf("Error sending message to backend %s", backend.RemoteAddr())
+		log.Debugf("Error: %s", err.Error())
+
+		s.setBackend(nil)
+		p.discardBackend(cp, backend, nodeName, part)
+
+		return nil, nil
+	}
+
+	return backend, cp
+}
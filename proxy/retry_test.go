@@ -0,0 +1,86 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+import (
+	"testing"
+
+	"github.com/crunchydata/crunchy-proxy/common"
+	"github.com/crunchydata/crunchy-proxy/config"
+	"github.com/crunchydata/crunchy-proxy/protocol"
+)
+
+/* Build the body of an ErrorResponse message with the SQLSTATE. */
+func errorBody(code string) []byte {
+	return protocol.CreateErrorMessage("ERROR", code, "failed", "", "")[5:]
+}
+
+func TestIsTransient(t *testing.T) {
+	loadTestConfig(t, "pool:\n  retry:\n    attempts: 2\n")
+
+	policy := config.GetRetryPolicy()
+
+	tests := []struct {
+		name      string
+		policy    config.RetryConfig
+		code      string
+		transient bool
+	}{
+		{name: "serialization failure", policy: policy, code: protocol.ErrorCodeSerializationFailure, transient: true},
+		{name: "deadlock", policy: policy, code: protocol.ErrorCodeDeadlockDetected, transient: true},
+		{name: "cannot connect now", policy: policy, code: protocol.ErrorCodeCannotConnectNow, transient: true},
+		{name: "other error", policy: policy, code: protocol.ErrorCodeInsufficientPrivilege},
+		{
+			name:      "configured code",
+			policy:    config.RetryConfig{Codes: []string{"55P03"}},
+			code:      "55P03",
+			transient: true,
+		},
+		{
+			name:   "default code not configured",
+			policy: config.RetryConfig{Codes: []string{"55P03"}},
+			code:   protocol.ErrorCodeSerializationFailure,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code, transient := isTransient(test.policy, errorBody(test.code))
+
+			if code != test.code || transient != test.transient {
+				t.Fatalf("expected %s to be transient: %v, got %s, %v", test.code, test.transient, code, transient)
+			}
+		})
+	}
+}
+
+func TestGetRetryPolicy(t *testing.T) {
+	loadTestConfig(t, "pool:\n  retry:\n    attempts: 2\n")
+
+	policy := config.GetRetryPolicy()
+
+	if policy.Attempts != 2 || len(policy.Codes) != 3 || policy.Node != common.RETRY_NODE_OTHER ||
+		policy.MaxBuffer != 64*1024 {
+		t.Fatalf("expected the default policy, got %+v", policy)
+	}
+
+	loadTestConfig(t, "pool:\n  retry:\n    attempts: 1\n    codes: ['55P03']\n    node: same\n    maxbuffer: 1024\n")
+
+	policy = config.GetRetryPolicy()
+
+	if len(policy.Codes) != 1 || policy.Node != common.RETRY_NODE_SAME || policy.MaxBuffer != 1024 {
+		t.Fatalf("expected the configured policy, got %+v", policy)
+	}
+}
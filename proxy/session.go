@@ -0,0 +1,372 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package proxy
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/crunchydata/crunchy-proxy/common"
+	"github.com/crunchydata/crunchy-proxy/config"
+	"github.com/crunchydata/crunchy-proxy/util/log"
+)
+
+// session holds the state of a client connection that is reported by the
+// admin console and used to cancel its queries, as well as the session
+// parameters that are applied to each pool connection the client uses.
+//
+// The process ID and secret key are sent to the client in place of the key
+// data of a backend, as the client may use many backends over the life of its
+// connection.
+type session struct {
+	processID int32
+	secretKey int32
+	client    net.Conn
+	cluster   string
+	user      string
+	database  string
+	connected time.Time
+	requested time.Time // when the client last sent a request
+	waiting   time.Time // when the client started waiting for a backend
+	backend   net.Conn  // The backend currently held by the client
+	closed    bool      // whether an administrator terminated the session
+	received  int64     // bytes received from the client
+	sent      int64     // bytes sent to the client
+	lock      sync.Mutex
+
+	/*
+	 * The statements that restore each session parameter, by parameter name.
+	 * Only used by the goroutine handling the client.
+	 */
+	parameters map[string]string
+
+	/*
+	 * The changes to the session parameters made in the transaction that is
+	 * open, which are applied once it commits. Only used by the goroutine
+	 * handling the client.
+	 */
+	staged []parameterChange
+
+	/*
+	 * The parameters reported to the client, by the name the backend gave
+	 * them. Only used by the goroutine handling the client.
+	 */
+	status map[string]string
+
+	/*
+	 * The named prepared statements of the client, by the name the client
+	 * gave them. Only changed by the goroutine handling the client.
+	 */
+	statements    map[string]*preparedStatement
+	nextStatement int
+
+	/*
+	 * The last query of the client that may have written on the master. Only
+	 * used by the goroutine handling the client.
+	 */
+	lastWrite *writeMark
+}
+
+// readAfter returns the last write of the client that its reads must see, so
+// that they are only routed to nodes that have replayed it. Writes are only
+// considered within the sticky reads window, unless reads are routed by WAL
+// position. Otherwise nil is returned.
+func (s *session) readAfter() *writeMark {
+	if s.lastWrite == nil {
+		return nil
+	}
+
+	if config.GetConsistency() == common.CONSISTENCY_LSN {
+		return s.lastWrite
+	}
+
+	if window := config.GetStickyReads(); window <= 0 || time.Since(s.lastWrite.written) >= window {
+		return nil
+	}
+
+	return s.lastWrite
+}
+
+func (s *session) setBackend(backend net.Conn) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if conn, ok := backend.(*backendConn); ok {
+		atomic.StoreInt32(&conn.sessionID, s.processID)
+	}
+
+	s.backend = backend
+	s.waiting = time.Time{}
+}
+
+func (s *session) getBackend() net.Conn {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.backend
+}
+
+// terminate marks the session as terminated and returns the backend that it
+// holds, if any, which is closed by the caller.
+func (s *session) terminate() net.Conn {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.closed = true
+
+	return s.backend
+}
+
+// terminated returns whether the session was terminated. A backend that the
+// session held when it was terminated may have been closed, so it must be
+// discarded rather than returned to its pool. The backend is cleared from the
+// session before this is checked, so that a termination either sees the
+// backend or is seen here.
+func (s *session) terminated() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.closed
+}
+
+// setRequested records that the client has sent a request.
+func (s *session) setRequested() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.requested = time.Now()
+}
+
+// addTraffic records the bytes relayed for the client.
+func (s *session) addTraffic(received int64, sent int64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.received += received
+	s.sent += sent
+}
+
+// setWaiting records that the client is waiting for a backend.
+func (s *session) setWaiting() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.waiting = time.Now()
+}
+
+// clearWaiting records that the client is no longer waiting.
+func (s *session) clearWaiting() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.waiting = time.Time{}
+}
+
+// sessionState is a snapshot of a session.
+type sessionState struct {
+	processID int32
+	client    net.Conn
+	cluster   string
+	user      string
+	database  string
+	connected time.Time
+	requested time.Time
+	waiting   time.Time
+	backend   net.Conn
+	received  int64
+	sent      int64
+}
+
+func (s *session) state() sessionState {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return sessionState{
+		processID: s.processID,
+		client:    s.client,
+		cluster:   s.cluster,
+		user:      s.user,
+		database:  s.database,
+		connected: s.connected,
+		requested: s.requested,
+		waiting:   s.waiting,
+		backend:   s.backend,
+		received:  s.received,
+		sent:      s.sent,
+	}
+}
+
+// newSession registers a new client session with a unique process ID and a
+// random secret key.
+func (p *Proxy) newSession(client net.Conn, part partition) *session {
+	var secretKey int32
+
+	binary.Read(rand.Reader, binary.BigEndian, &secretKey)
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.nextSession++
+
+	s := &session{
+		processID:  p.nextSession,
+		secretKey:  secretKey,
+		client:     client,
+		cluster:    part.cluster,
+		user:       part.username,
+		database:   part.database,
+		connected:  time.Now(),
+		parameters: make(map[string]string),
+		status:     make(map[string]string),
+		statements: make(map[string]*preparedStatement),
+	}
+
+	p.sessions[s.processID] = s
+
+	return s
+}
+
+// removeSession unregisters a session when its client disconnects. Its
+// prepared statements are closed on each backend the next time the backend is
+// assigned to a client.
+func (p *Proxy) removeSession(s *session) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	delete(p.sessions, s.processID)
+
+	for _, statement := range s.statements {
+		delete(p.statements, statement.name)
+	}
+}
+
+// TerminateSession disconnects the client of a session, identified by the
+// process ID that it was given, as shown in the 'id' column of SHOW CLIENTS.
+// The client is sent an error before its connection is closed. If the client
+// holds a backend, then its query is canceled and the backend is closed, and
+// replaced in its pool by a new connection.
+func (p *Proxy) TerminateSession(processID int32) error {
+	p.lock.Lock()
+	s, ok := p.sessions[processID]
+	p.lock.Unlock()
+
+	if !ok {
+		return fmt.Errorf("unknown session %d", processID)
+	}
+
+	log.Infof("Terminating session %d of client %s", processID, s.client.RemoteAddr())
+
+	backend := s.terminate()
+
+	terminateClient(s.client)
+
+	if backend != nil {
+		if conn, ok := backend.(*backendConn); ok {
+			cancelBackend(s, conn)
+		}
+
+		backend.Close()
+	}
+
+	return nil
+}
+
+// SessionInfo describes a client session, as reported by the admin server.
+type SessionInfo struct {
+	ID          int32
+	ClientAddr  string
+	User        string
+	Database    string
+	State       string // idle, active or waiting
+	Backend     string // the address of the backend held, if any
+	Connected   time.Time
+	LastRequest time.Time
+	Received    int64 // bytes
+	Sent        int64 // bytes
+}
+
+// Sessions returns a description of each client session, ordered by ID. A
+// client is idle while it is not in a transaction, and waiting while it
+// waits for a backend.
+func (p *Proxy) Sessions() []SessionInfo {
+	states := p.getSessions()
+
+	p.lock.Lock()
+
+	sessions := make([]SessionInfo, 0, len(states))
+
+	for _, s := range states {
+		state := "active"
+
+		if !s.waiting.IsZero() {
+			state = "waiting"
+		} else if p.clients[s.client] {
+			state = "idle"
+		}
+
+		var backend string
+
+		if conn, ok := s.backend.(*backendConn); ok {
+			backend = conn.hostPort
+		}
+
+		sessions = append(sessions, SessionInfo{
+			ID:          s.processID,
+			ClientAddr:  s.client.RemoteAddr().String(),
+			User:        s.user,
+			Database:    s.database,
+			State:       state,
+			Backend:     backend,
+			Connected:   s.connected,
+			LastRequest: s.requested,
+			Received:    s.received,
+			Sent:        s.sent,
+		})
+	}
+
+	p.lock.Unlock()
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].ID < sessions[j].ID
+	})
+
+	return sessions
+}
+
+// getSessions returns a snapshot of each session.
+func (p *Proxy) getSessions() []sessionState {
+	p.lock.Lock()
+	sessions := make([]*session, 0, len(p.sessions))
+
+	for _, s := range p.sessions {
+		sessions = append(sessions, s)
+	}
+
+	p.lock.Unlock()
+
+	states := make([]sessionState, 0, len(sessions))
+
+	for _, s := range sessions {
+		states = append(states, s.state())
+	}
+
+	return states
+}
@@ -0,0 +1,126 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package proxy
+
+import (
+	"hash/fnv"
+	"reflect"
+	"sort"
+	"strconv"
+
+	"github.com/crunchydata/crunchy-proxy/config"
+)
+
+// shardRing spreads shard keys over the shard clusters by consistent hashing.
+// Each shard has a number of points on the ring, and a key belongs to the
+// shard of the first point at or after its hash, so that adding or removing a
+// shard only moves the keys between it and its neighbors.
+type shardRing struct {
+	points []uint64
+	shards map[uint64]string // the shard of each point
+}
+
+/* newShardRing places the points of each shard on a new ring. */
+func newShardRing(shards []string, virtualNodes int) *shardRing {
+	ring := &shardRing{shards: make(map[uint64]string)}
+
+	for _, shard := range shards {
+		for i := 0; i < virtualNodes; i++ {
+			point := shardHash(shard + "#" + strconv.Itoa(i))
+
+			/* The first shard listed keeps a point that collides. */
+			if _, ok := ring.shards[point]; ok {
+				continue
+			}
+
+			ring.shards[point] = shard
+			ring.points = append(ring.points, point)
+		}
+	}
+
+	sort.Slice(ring.points, func(i, j int) bool {
+		return ring.points[i] < ring.points[j]
+	})
+
+	return ring
+}
+
+/* shard returns the shard that a key belongs to. */
+func (r *shardRing) shard(key string) string {
+	hash := shardHash(key)
+
+	i := sort.Search(len(r.points), func(i int) bool {
+		return r.points[i] >= hash
+	})
+
+	if i == len(r.points) {
+		i = 0
+	}
+
+	return r.shards[r.points[i]]
+}
+
+/*
+ * shardHash hashes a key or a point onto the ring. FNV alone places similar
+ * strings, such as the points of a shard, close together, so its result is
+ * mixed to spread them over the whole ring.
+ */
+func shardHash(key string) uint64 {
+	hash := fnv.New64a()
+	hash.Write([]byte(key))
+
+	h := hash.Sum64()
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+
+	return h
+}
+
+// setShards rebuilds the shard ring if the shards have changed. The pool lock
+// must be held.
+func (p *Proxy) setShards(sharding config.ShardingConfig) {
+	if !sharding.Enable || len(sharding.Shards) == 0 {
+		p.ring, p.sharding = nil, sharding
+		return
+	}
+
+	if p.ring != nil && reflect.DeepEqual(p.sharding, sharding) {
+		return
+	}
+
+	p.ring = newShardRing(sharding.Shards, sharding.VirtualNodes)
+	p.sharding = sharding
+}
+
+// getShard returns the cluster that a query annotated with a shard key is
+// routed to, and false if the query has no shard key or sharding is disabled.
+func (p *Proxy) getShard(query string) (string, bool) {
+	key, ok := getShardKey(query)
+
+	if !ok {
+		return "", false
+	}
+
+	p.poolLock.Lock()
+	defer p.poolLock.Unlock()
+
+	if p.ring == nil {
+		return "", false
+	}
+
+	return p.ring.shard(key), true
+}
@@ -0,0 +1,113 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/crunchydata/crunchy-proxy/config"
+)
+
+func TestShardRing(t *testing.T) {
+	shards := []string{"shard1", "shard2", "shard3"}
+	ring := newShardRing(shards, 64)
+	counts := make(map[string]int)
+
+	for i := 0; i < 3000; i++ {
+		key := strconv.Itoa(i)
+		shard := ring.shard(key)
+
+		if again := newShardRing(shards, 64).shard(key); again != shard {
+			t.Fatalf("key %s: expected the same shard from a new ring, got %s and %s", key, shard, again)
+		}
+
+		counts[shard]++
+	}
+
+	/* Each shard should get a reasonable share of the keys. */
+	for _, shard := range shards {
+		if counts[shard] < 500 {
+			t.Fatalf("expected the keys to be spread over the shards, got %v", counts)
+		}
+	}
+}
+
+func TestShardRingAddShard(t *testing.T) {
+	before := newShardRing([]string{"shard1", "shard2", "shard3"}, 64)
+	after := newShardRing([]string{"shard1", "shard2", "shard3", "shard4"}, 64)
+	var moved int
+
+	for i := 0; i < 3000; i++ {
+		key := strconv.Itoa(i)
+
+		if from, to := before.shard(key), after.shard(key); from != to {
+			if to != "shard4" {
+				t.Fatalf("key %s: expected a moved key to go to the new shard, moved from %s to %s", key, from, to)
+			}
+
+			moved++
+		}
+	}
+
+	if moved == 0 || moved > 1500 {
+		t.Fatalf("expected about a quarter of the keys to move, moved %d", moved)
+	}
+}
+
+func TestGetShardKey(t *testing.T) {
+	tests := []struct {
+		query string
+		key   string
+		ok    bool
+	}{
+		{query: "/* shard_key: 42 */ select 1", key: "42", ok: true},
+		{query: "/* read, shard_key:customer-7 */ select 1", key: "customer-7", ok: true},
+		{query: "/* shard_key: */ select 1"},
+		{query: "/* read */ select 1"},
+		{query: "select 1"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			if key, ok := getShardKey(test.query); key != test.key || ok != test.ok {
+				t.Fatalf("expected %q, %v, got %q, %v", test.key, test.ok, key, ok)
+			}
+		})
+	}
+}
+
+func TestGetShard(t *testing.T) {
+	p := newTestProxy()
+	query := "/* shard_key: 42 */ select 1"
+
+	p.setShards(config.ShardingConfig{Enable: true, Shards: []string{"shard1", "shard2"}, VirtualNodes: 16})
+
+	shard, ok := p.getShard(query)
+
+	if !ok || shard != newShardRing([]string{"shard1", "shard2"}, 16).shard("42") {
+		t.Fatalf("expected the shard of the key, got %q, %v", shard, ok)
+	}
+
+	if _, ok := p.getShard("select 1"); ok {
+		t.Fatal("expected a query without a shard key not to be routed to a shard")
+	}
+
+	p.setShards(config.ShardingConfig{Shards: []string{"shard1", "shard2"}})
+
+	if _, ok := p.getShard(query); ok {
+		t.Fatal("expected no shard while sharding is disabled")
+	}
+}
@@ -0,0 +1,209 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/crunchydata/crunchy-proxy/config"
+	"github.com/crunchydata/crunchy-proxy/util/log"
+)
+
+// logSlowQuery logs a query that took longer than the configured threshold
+// to complete, along with the node that ran it and the client that sent it.
+// The text of the query is only logged if enabled, and is normalized so that
+// no literal values are logged.
+func logSlowQuery(client net.Conn, part partition, nodeName string, query string, duration time.Duration) {
+	logConfig := config.GetLogConfig()
+	threshold := time.Duration(logConfig.SlowQueryThreshold) * time.Millisecond
+
+	if threshold <= 0 || duration < threshold {
+		return
+	}
+
+	message := fmt.Sprintf("Client: %s - slow query on '%s' took %s (database '%s', user '%s')",
+		client.RemoteAddr(), nodeName, duration, part.database, part.username)
+
+	if logConfig.SlowQueryText && query != "" {
+		message += ": " + normalizeQuery(query)
+	}
+
+	log.Warn(message)
+}
+
+// normalizeQuery replaces the literal values in a query with '?' and removes
+// comments and redundant white space, so that queries that differ only in
+// their values look the same. Positional parameters such as $1 are kept.
+func normalizeQuery(query string) string {
+	normalized, _ := splitQuery(query)
+
+	return normalized
+}
+
+// splitQuery normalizes a query as normalizeQuery does, and also returns the
+// literal values that were replaced, in the order they appear in the query.
+func splitQuery(query string) (string, []string) {
+	var normalized []rune
+	var literals []string
+	var space bool // white space or a comment precedes the next token
+
+	runes := []rune(query)
+
+	/* Append a token, preceded by a single space if any was skipped. */
+	appendToken := func(token ...rune) {
+		if space && len(normalized) > 0 {
+			normalized = append(normalized, ' ')
+		}
+
+		normalized = append(normalized, token...)
+		space = false
+	}
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+
+		switch {
+		case unicode.IsSpace(r):
+			space = true
+			i++
+		case r == '-' && i+1 < len(runes) && runes[i+1] == '-':
+			/* Line comment. */
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+
+			space = true
+		case r == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			/* Block comment, which may be nested. */
+			depth := 0
+
+			for i < len(runes) {
+				if runes[i] == '/' && i+1 < len(runes) && runes[i+1] == '*' {
+					depth++
+					i += 2
+				} else if runes[i] == '*' && i+1 < len(runes) && runes[i+1] == '/' {
+					depth--
+					i += 2
+
+					if depth == 0 {
+						break
+					}
+				} else {
+					i++
+				}
+			}
+
+			space = true
+		case r == '\'' || r == '"':
+			/* String literal, which is replaced, or quoted identifier. */
+			start := i
+			escapes := r == '\'' && i > 0 && (runes[i-1] == 'e' || runes[i-1] == 'E')
+
+			for i++; i < len(runes); i++ {
+				if escapes && runes[i] == '\\' {
+					i++
+				} else if runes[i] == r {
+					if i+1 < len(runes) && runes[i+1] == r {
+						i++
+					} else {
+						i++
+						break
+					}
+				}
+			}
+
+			if r == '"' {
+				appendToken(runes[start:i]...)
+				break
+			}
+
+			/* The literal keeps the prefix of an escape string. */
+			if escapes {
+				literals = append(literals, string(runes[start-1:i]))
+			} else {
+				literals = append(literals, string(runes[start:i]))
+			}
+
+			/* Drop the prefix of an escape string along with the string. */
+			if escapes && len(normalized) > 0 && !space &&
+				(start < 2 || !unicode.IsLetter(runes[start-2])) {
+				normalized = normalized[:len(normalized)-1]
+			}
+
+			appendToken('?')
+		case r == '$' && i+1 < len(runes) && unicode.IsDigit(runes[i+1]):
+			/* Positional parameter. */
+			start := i
+
+			for i++; i < len(runes) && unicode.IsDigit(runes[i]); i++ {
+			}
+
+			appendToken(runes[start:i]...)
+		case r == '$':
+			/* Dollar quoted string, e.g. $$...$$ or $tag$...$tag$. */
+			tagEnd := indexRunes(runes, i+1, []rune{'$'})
+
+			if tagEnd < 0 {
+				appendToken(runes[i:]...)
+				i = len(runes)
+				break
+			}
+
+			tag := runes[i : tagEnd+1]
+			end := indexRunes(runes, tagEnd+1, tag)
+
+			if end < 0 {
+				end = len(runes)
+			} else {
+				end += len(tag)
+			}
+
+			literals = append(literals, string(runes[i:end]))
+			appendToken('?')
+			i = end
+		case unicode.IsDigit(r) || (r == '.' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			/* Numeric literal, including decimals and exponents. */
+			start := i
+
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.' ||
+				runes[i] == 'e' || runes[i] == 'E' ||
+				((runes[i] == '+' || runes[i] == '-') && (runes[i-1] == 'e' || runes[i-1] == 'E'))) {
+				i++
+			}
+
+			literals = append(literals, string(runes[start:i]))
+			appendToken('?')
+		case unicode.IsLetter(r) || r == '_':
+			/* Keyword or identifier, which may contain digits. */
+			start := i
+
+			for i < len(runes) && (unicode.IsLetter(runes[i]) ||
+				unicode.IsDigit(runes[i]) || runes[i] == '_' || runes[i] == '$') {
+				i++
+			}
+
+			appendToken(runes[start:i]...)
+		default:
+			appendToken(r)
+			i++
+		}
+	}
+
+	return strings.TrimSpace(string(normalized)), literals
+}
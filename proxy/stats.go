@@ -0,0 +1,60 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package proxy
+
+import (
+	"time"
+)
+
+// databaseStats holds the traffic statistics of a database, as reported by
+// the admin console.
+type databaseStats struct {
+	xactCount  int64
+	queryCount int64
+	received   int64
+	sent       int64
+	xactTime   time.Duration
+	queryTime  time.Duration
+	waitTime   time.Duration
+}
+
+// updateStats applies the update to the statistics of the database.
+func (p *Proxy) updateStats(database string, update func(stats *databaseStats)) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	stats, ok := p.databaseStats[database]
+
+	if !ok {
+		stats = &databaseStats{}
+		p.databaseStats[database] = stats
+	}
+
+	update(stats)
+}
+
+// getStats returns a copy of the statistics of each database.
+func (p *Proxy) getStats() map[string]databaseStats {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	stats := make(map[string]databaseStats, len(p.databaseStats))
+
+	for database, databaseStats := range p.databaseStats {
+		stats[database] = *databaseStats
+	}
+
+	return stats
+}
@@ -0,0 +1,154 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+import (
+	"bytes"
+	"net"
+	"strings"
+
+	"github.com/crunchydata/crunchy-proxy/connect"
+	"github.com/crunchydata/crunchy-proxy/protocol"
+	"github.com/crunchydata/crunchy-proxy/util/log"
+)
+
+// statusRecorder relays the messages sent to a client while it authenticates,
+// and keeps the parameters that the backend reported to it.
+type statusRecorder struct {
+	net.Conn
+	messages messageTracker
+	status   map[string]string
+}
+
+func newStatusRecorder(client net.Conn) *statusRecorder {
+	return &statusRecorder{
+		Conn:     client,
+		messages: messageTracker{capture: protocol.ParameterStatusMessageType},
+		status:   make(map[string]string),
+	}
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	r.messages.scan(b, func(messageType byte, first byte) {
+		if messageType != protocol.ParameterStatusMessageType {
+			return
+		}
+
+		if name, value, ok := parseParameterStatus(r.messages.body); ok {
+			r.status[name] = value
+		}
+	})
+
+	return r.Conn.Write(b)
+}
+
+/* Parse the name and value of a ParameterStatus message body. */
+func parseParameterStatus(body []byte) (string, string, bool) {
+	fields := bytes.Split(body, []byte{0})
+
+	if len(fields) < 2 {
+		return "", "", false
+	}
+
+	return string(fields[0]), string(fields[1]), true
+}
+
+/* Get the parameters reported by the ParameterStatus messages in a buffer. */
+func getParameterStatus(buffer []byte) map[string]string {
+	status := make(map[string]string)
+	messages := &messageTracker{capture: protocol.ParameterStatusMessageType}
+
+	messages.scan(buffer, func(messageType byte, first byte) {
+		if messageType != protocol.ParameterStatusMessageType {
+			return
+		}
+
+		if name, value, ok := parseParameterStatus(messages.body); ok {
+			status[name] = value
+		}
+	})
+
+	return status
+}
+
+/* Record a parameter reported by a pool connection. */
+func recordStatus(backend net.Conn, body []byte) {
+	conn, ok := backend.(*backendConn)
+
+	if !ok {
+		return
+	}
+
+	if name, value, ok := parseParameterStatus(body); ok {
+		conn.status[name] = value
+	}
+}
+
+/* Record a parameter that was reported to the client. */
+func (s *session) observeStatus(body []byte) {
+	if name, value, ok := parseParameterStatus(body); ok {
+		s.status[name] = value
+	}
+}
+
+// reconcileStatus makes sure that the client does not observe different
+// parameters when it moves to another pool connection. The client expects the
+// values it was last told of, so a parameter that the new connection reports
+// differently is set to the client's value, and from then on replayed like the
+// client's own settings. Parameters that cannot be set, such as server_version
+// or in_hot_standby, are reported to the client with their new value instead.
+func (p *Proxy) reconcileStatus(s *session, backend net.Conn) {
+	conn, ok := backend.(*backendConn)
+
+	if !ok {
+		return
+	}
+
+	var pinned bool
+	var reports []byte
+
+	for name, value := range conn.status {
+		seen, ok := s.status[name]
+
+		if !ok || seen == value {
+			continue
+		}
+
+		key := strings.ToLower(name)
+
+		if _, tracked := s.parameters[key]; !tracked && !untrackedParameters[key] &&
+			parameterName.MatchString(name) {
+			log.Debugf("Client: %s - keeping %s at '%s' on backend %s",
+				s.client.RemoteAddr(), name, seen, backend.RemoteAddr())
+			s.parameters[key] = setLiteral(name, seen)
+			pinned = true
+			continue
+		}
+
+		reports = append(reports, protocol.CreateParameterStatusMessage(name, value)...)
+		s.status[name] = value
+	}
+
+	if pinned {
+		p.replayParameters(s, backend)
+	}
+
+	if len(reports) > 0 {
+		if _, err := connect.Send(s.client, reports); err != nil {
+			log.Debugf("Error sending parameter status to client %s", s.client.RemoteAddr())
+			log.Debugf("Error: %s", err.Error())
+		}
+	}
+}
@@ -0,0 +1,33 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+import (
+	"net"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// traceAttributes describes a client's session on the spans of its
+// connection and queries.
+func traceAttributes(client net.Conn, part partition) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("db.system", "postgresql"),
+		attribute.String("db.name", part.database),
+		attribute.String("db.user", part.username),
+		attribute.String("net.peer.address", client.RemoteAddr().String()),
+		attribute.String("proxy.cluster", part.cluster),
+	}
+}
@@ -0,0 +1,203 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/crunchydata/crunchy-proxy/connect"
+	"github.com/crunchydata/crunchy-proxy/protocol"
+	"github.com/crunchydata/crunchy-proxy/util/log"
+)
+
+/* Startup parameters with this prefix are protocol options, not settings. */
+const protocolOptionPrefix = "_pq_."
+
+// negotiation is what the proxy tells a client that asked for a newer minor
+// version of protocol 3, or for protocol options, in a NegotiateProtocolVersion
+// message before it authenticates.
+type negotiation struct {
+	minor   int32    // the newest minor version that is supported
+	options []string // the protocol options that are not supported
+}
+
+// negotiateVersion checks the protocol version of a client's startup message.
+// Clients of protocol 3 are accepted. If a client asks for a newer minor
+// version, then the message is changed to ask for 3.0, which is all the proxy
+// speaks. Its protocol options are passed on to the master along with its
+// other startup parameters, but pool connections never have them, so they are
+// not supported either. The negotiation to report to the client is returned,
+// or nil if there is nothing to report.
+//
+// Clients of any other protocol are turned away, those of the obsolete
+// protocol 2.0 with an error in the format that they understand.
+func negotiateVersion(client net.Conn, message []byte) (*negotiation, bool) {
+	version := protocol.GetVersion(message)
+	major := protocol.MajorVersion(version)
+	minor := protocol.MinorVersion(version)
+
+	if major != protocol.MajorVersion(protocol.ProtocolVersion) {
+		pgError := protocol.Error{
+			Severity: protocol.ErrorSeverityFatal,
+			Code:     protocol.ErrorCodeFeatureNotSupported,
+			Message: fmt.Sprintf("unsupported frontend protocol %d.%d: server supports 3.0 to 3.0",
+				major, minor),
+		}
+
+		if major < protocol.MajorVersion(protocol.ProtocolVersion) {
+			connect.Send(client, pgError.GetLegacyMessage())
+		} else {
+			connect.Send(client, pgError.GetMessage())
+		}
+
+		log.Errorf("Client: %s - unsupported protocol %d.%d", client.RemoteAddr(), major, minor)
+		emitRejected(client, "unsupported protocol")
+		return nil, false
+	}
+
+	var options []string
+
+	for name := range connect.GetStartupParameters(message) {
+		if strings.HasPrefix(name, protocolOptionPrefix) {
+			options = append(options, name)
+		}
+	}
+
+	if minor == protocol.MinorVersion(protocol.ProtocolVersion) && len(options) == 0 {
+		return nil, true
+	}
+
+	sort.Strings(options)
+
+	log.Debugf("Client: %s - negotiating protocol 3.0 instead of 3.%d, options: %s",
+		client.RemoteAddr(), minor, strings.Join(options, ", "))
+
+	binary.BigEndian.PutUint32(message[4:8], uint32(protocol.ProtocolVersion))
+
+	return &negotiation{
+		minor:   protocol.MinorVersion(protocol.ProtocolVersion),
+		options: options,
+	}, true
+}
+
+// negotiatingConn relays the messages sent to a client while it authenticates,
+// and makes sure that they start with a NegotiateProtocolVersion message that
+// reflects both the proxy and the master. If the master answered the client's
+// protocol options with one, then the two are merged. Otherwise the proxy's
+// own message is sent first.
+type negotiatingConn struct {
+	net.Conn
+	negotiation *negotiation
+}
+
+/* Wrap the client's connection if there is a negotiation to report. */
+func negotiate(client net.Conn, negotiated *negotiation) net.Conn {
+	if negotiated == nil {
+		return client
+	}
+
+	return &negotiatingConn{Conn: client, negotiation: negotiated}
+}
+
+func (c *negotiatingConn) Write(b []byte) (int, error) {
+	if c.negotiation == nil {
+		return c.Conn.Write(b)
+	}
+
+	negotiated := c.negotiation
+	c.negotiation = nil
+
+	rest := b
+
+	if minor, options, length, ok := parseNegotiation(b); ok {
+		if minor < negotiated.minor {
+			negotiated.minor = minor
+		}
+
+		negotiated.options = mergeOptions(negotiated.options, options)
+		rest = b[length:]
+	}
+
+	message := protocol.CreateNegotiateProtocolVersionMessage(negotiated.minor, negotiated.options)
+
+	if _, err := c.Conn.Write(append(message, rest...)); err != nil {
+		return 0, err
+	}
+
+	return len(b), nil
+}
+
+/*
+ * Parse a NegotiateProtocolVersion message at the start of a buffer into the
+ * minor version and unrecognized options it holds, and its total length.
+ */
+func parseNegotiation(b []byte) (int32, []string, int, bool) {
+	if len(b) < 13 || protocol.GetMessageType(b) != protocol.NegotiateMessageType {
+		return 0, nil, 0, false
+	}
+
+	length := int(protocol.GetMessageLength(b)) + 1
+
+	if length > len(b) {
+		return 0, nil, 0, false
+	}
+
+	message := protocol.NewMessageBuffer(b[:length])
+	message.Seek(5)
+
+	minor, _ := message.ReadInt32()
+	count, _ := message.ReadInt32()
+
+	if count < 0 || int(count) > length {
+		return 0, nil, 0, false
+	}
+
+	options := make([]string, 0, count)
+
+	for i := int32(0); i < count; i++ {
+		option, err := message.ReadString()
+
+		if err != nil {
+			return 0, nil, 0, false
+		}
+
+		options = append(options, option)
+	}
+
+	return minor, options, length, true
+}
+
+/* Combine two lists of options into one sorted list without duplicates. */
+func mergeOptions(a []string, b []string) []string {
+	seen := make(map[string]bool, len(a)+len(b))
+	merged := make([]string, 0, len(a)+len(b))
+
+	for _, options := range [][]string{a, b} {
+		for _, option := range options {
+			if !seen[option] {
+				seen[option] = true
+				merged = append(merged, option)
+			}
+		}
+	}
+
+	sort.Strings(merged)
+
+	return merged
+}
@@ -16,16 +16,25 @@ package server
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
+	"sync"
 	"time"
 
 	_ "github.com/lib/pq" // required
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"gopkg.in/yaml.v2"
 
 	"github.com/crunchydata/crunchy-proxy/common"
 	"github.com/crunchydata/crunchy-proxy/config"
+	"github.com/crunchydata/crunchy-proxy/connect"
+	"github.com/crunchydata/crunchy-proxy/events"
+	"github.com/crunchydata/crunchy-proxy/metrics"
+	"github.com/crunchydata/crunchy-proxy/proxy"
 	pb "github.com/crunchydata/crunchy-proxy/server/serverpb"
 	"github.com/crunchydata/crunchy-proxy/util/grpcutil"
 	"github.com/crunchydata/crunchy-proxy/util/log"
@@ -35,6 +44,7 @@ type AdminServer struct {
 	grpc       *grpc.Server
 	server     *Server
 	nodeHealth map[string]bool
+	healthLock sync.RWMutex // guards nodeHealth
 }
 
 func NewAdminServer(s *Server) *AdminServer {
@@ -43,7 +53,23 @@ func NewAdminServer(s *Server) *AdminServer {
 		nodeHealth: make(map[string]bool, 0),
 	}
 
-	admin.grpc = grpc.NewServer()
+	options := []grpc.ServerOption{
+		grpc.UnaryInterceptor(admin.authorizeUnary),
+		grpc.StreamInterceptor(admin.authorizeStream),
+	}
+
+	if sslConfig := config.GetAdminConfig().SSL; sslConfig.Enable {
+		tlsConfig, err := connect.GetServerTLSConfig(sslConfig)
+
+		if err != nil {
+			log.Error("Error creating TLS configuration for the admin server.")
+			log.Fatal(err.Error())
+		}
+
+		options = append(options, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+
+	admin.grpc = grpc.NewServer(options...)
 
 	pb.RegisterAdminServer(admin.grpc, admin)
 
@@ -57,11 +83,123 @@ func (s *AdminServer) Nodes(ctx context.Context, req *pb.NodeRequest) (*pb.NodeR
 
 	for name, node := range config.GetNodes() {
 		response.Nodes[name] = node.HostPort
+
+		if node.Disabled {
+			response.Disabled = append(response.Disabled, name)
+		}
+	}
+
+	return &response, nil
+}
+
+// AddNode adds a node and creates its pools. If no role is given, then the
+// node is added as a replica.
+func (s *AdminServer) AddNode(ctx context.Context, req *pb.AddNodeRequest) (*pb.AddNodeResponse, error) {
+	var response pb.AddNodeResponse
+
+	if req.Name == "" || req.HostPort == "" {
+		return nil, errors.New("a node requires a name and a host:port")
+	}
+
+	for _, address := range connect.SplitHosts(req.HostPort) {
+		if err := connect.CheckAddress(address); err != nil {
+			return nil, err
+		}
+	}
+
+	node := common.Node{
+		HostPort: req.HostPort,
+		Role:     req.Role,
+		PoolMode: req.PoolMode,
+		Weight:   int(req.Weight),
+		Cluster:  req.Cluster,
+	}
+
+	if node.Role == "" {
+		node.Role = common.NODE_ROLE_REPLICA
+	}
+
+	if err := config.AddNode(req.Name, node); err != nil {
+		return nil, err
 	}
 
+	log.Infof("Added node '%s' at %s", req.Name, req.HostPort)
+	s.server.proxy.Reload()
+
+	response.Success = true
+
+	return &response, nil
+}
+
+// RemoveNode removes a node. Its pools are closed once the clients using them
+// have released their connections.
+func (s *AdminServer) RemoveNode(ctx context.Context, req *pb.RemoveNodeRequest) (*pb.RemoveNodeResponse, error) {
+	var response pb.RemoveNodeResponse
+
+	if err := config.RemoveNode(req.Name); err != nil {
+		return nil, err
+	}
+
+	log.Infof("Removed node '%s'", req.Name)
+	s.server.proxy.Reload()
+
+	response.Success = true
+
+	return &response, nil
+}
+
+// EnableNode creates the pools for a disabled node, after which it receives
+// queries again.
+func (s *AdminServer) EnableNode(ctx context.Context, req *pb.EnableNodeRequest) (*pb.EnableNodeResponse, error) {
+	var response pb.EnableNodeResponse
+
+	if err := config.SetNodeDisabled(req.Name, false); err != nil {
+		return nil, err
+	}
+
+	log.Infof("Enabled node '%s'", req.Name)
+	s.server.proxy.Reload()
+
+	response.Success = true
+
 	return &response, nil
 }
 
+// DisableNode stops a node from receiving queries, without removing it, for
+// example while it is under maintenance.
+func (s *AdminServer) DisableNode(ctx context.Context, req *pb.DisableNodeRequest) (*pb.DisableNodeResponse, error) {
+	var response pb.DisableNodeResponse
+
+	if err := config.SetNodeDisabled(req.Name, true); err != nil {
+		return nil, err
+	}
+
+	log.Infof("Disabled node '%s'", req.Name)
+	s.server.proxy.Reload()
+
+	response.Success = true
+
+	return &response, nil
+}
+
+// DrainNode disables a node and streams the progress of its clients releasing
+// their connections to it, until it has been drained.
+func (s *AdminServer) DrainNode(req *pb.DrainNodeRequest, stream pb.Admin_DrainNodeServer) error {
+	timeout := config.GetDrainTimeout()
+
+	if req.Timeout > 0 {
+		timeout = time.Duration(req.Timeout) * time.Second
+	}
+
+	return s.server.proxy.DrainNode(req.Name, timeout, func(progress proxy.DrainProgress) {
+		stream.Send(&pb.DrainNodeResponse{
+			Active:     int32(progress.Active),
+			Terminated: int32(progress.Terminated),
+			Done:       progress.Done,
+		})
+	})
+}
+
 func (s *AdminServer) Pools(ctx context.Context, req *pb.PoolRequest) (*pb.PoolResponse, error) {
 	var response pb.PoolResponse
 
@@ -70,12 +208,138 @@ func (s *AdminServer) Pools(ctx context.Context, req *pb.PoolRequest) (*pb.PoolR
 	return &response, nil
 }
 
-func (s *AdminServer) Shutdown(req *pb.ShutdownRequest, stream pb.Admin_ShutdownServer) error {
-	// Stop the Proxy Server
-	s.server.proxy.Stop()
+func (s *AdminServer) ShowPools(ctx context.Context, req *pb.ShowPoolsRequest) (*pb.ShowPoolsResponse, error) {
+	var response pb.ShowPoolsResponse
+
+	for _, stats := range s.server.proxy.PoolStats() {
+		response.Pools = append(response.Pools, &pb.PoolStatistics{
+			Node:        stats.Name,
+			Database:    stats.Database,
+			User:        stats.Username,
+			Total:       int32(stats.Total),
+			InUse:       int32(stats.InUse),
+			Idle:        int32(stats.Idle),
+			Waiting:     int32(stats.Waiting),
+			AverageWait: stats.AverageWait.Seconds(),
+			AverageAge:  stats.AverageAge.Seconds(),
+			MaxWait:     stats.MaxWait.Seconds(),
+			Timeouts:    stats.Timeouts,
+			Rejected:    stats.Rejected,
+		})
+	}
 
-	// Stop the Admin grpc Server
-	s.grpc.Stop()
+	return &response, nil
+}
+
+func (s *AdminServer) TopStatements(ctx context.Context, req *pb.TopStatementsRequest) (*pb.TopStatementsResponse, error) {
+	var response pb.TopStatementsResponse
+
+	for _, stats := range s.server.proxy.TopStatements(int(req.Limit)) {
+		response.Statements = append(response.Statements, &pb.StatementStatistics{
+			Query:     stats.Query,
+			Node:      stats.Node,
+			Calls:     stats.Calls,
+			TotalTime: stats.TotalTime.Seconds(),
+			MeanTime:  stats.MeanTime.Seconds(),
+			MaxTime:   stats.MaxTime.Seconds(),
+		})
+	}
+
+	return &response, nil
+}
+
+func (s *AdminServer) ListSessions(ctx context.Context, req *pb.ListSessionsRequest) (*pb.ListSessionsResponse, error) {
+	var response pb.ListSessionsResponse
+
+	for _, session := range s.server.proxy.Sessions() {
+		var requested int64
+
+		if !session.LastRequest.IsZero() {
+			requested = session.LastRequest.Unix()
+		}
+
+		response.Sessions = append(response.Sessions, &pb.SessionInfo{
+			Id:          session.ID,
+			ClientAddr:  session.ClientAddr,
+			User:        session.User,
+			Database:    session.Database,
+			State:       session.State,
+			Backend:     session.Backend,
+			ConnectTime: session.Connected.Unix(),
+			RequestTime: requested,
+			Received:    session.Received,
+			Sent:        session.Sent,
+		})
+	}
+
+	return &response, nil
+}
+
+// TerminateSession disconnects the client of a session, closing the backend
+// that it holds.
+func (s *AdminServer) TerminateSession(ctx context.Context, req *pb.TerminateSessionRequest) (*pb.TerminateSessionResponse, error) {
+	var response pb.TerminateSessionResponse
+
+	if err := s.server.proxy.TerminateSession(req.SessionId); err != nil {
+		return nil, err
+	}
+
+	response.Success = true
+
+	return &response, nil
+}
+
+func (s *AdminServer) CacheStatistics(ctx context.Context, req *pb.CacheStatisticsRequest) (*pb.CacheStatisticsResponse, error) {
+	stats := s.server.proxy.CacheStats()
+
+	return &pb.CacheStatisticsResponse{
+		Entries:     int32(stats.Entries),
+		Size:        stats.Size,
+		Hits:        stats.Hits,
+		Misses:      stats.Misses,
+		Evictions:   stats.Evictions,
+		Expirations: stats.Expirations,
+	}, nil
+}
+
+// SetReadOnly places the proxy into, or takes it out of, read-only mode.
+func (s *AdminServer) SetReadOnly(ctx context.Context, req *pb.ReadOnlyRequest) (*pb.ReadOnlyResponse, error) {
+	if err := s.server.proxy.SetReadOnly(req.Enable); err != nil {
+		return nil, err
+	}
+
+	return &pb.ReadOnlyResponse{ReadOnly: req.Enable}, nil
+}
+
+// Pause holds client traffic at the proxy, waiting up to the timeout of the
+// request, or the configured pause timeout, for transactions to finish.
+func (s *AdminServer) Pause(ctx context.Context, req *pb.PauseRequest) (*pb.PauseResponse, error) {
+	timeout := config.GetPauseTimeout()
+
+	if req.Timeout > 0 {
+		timeout = time.Duration(req.Timeout) * time.Second
+	}
+
+	active, err := s.server.proxy.Pause(timeout)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.PauseResponse{Active: int32(active)}, nil
+}
+
+// Resume releases the client traffic held by a pause.
+func (s *AdminServer) Resume(ctx context.Context, req *pb.ResumeRequest) (*pb.ResumeResponse, error) {
+	if err := s.server.proxy.Resume(); err != nil {
+		return nil, err
+	}
+
+	return &pb.ResumeResponse{Success: true}, nil
+}
+
+func (s *AdminServer) Shutdown(req *pb.ShutdownRequest, stream pb.Admin_ShutdownServer) error {
+	s.server.Shutdown()
 
 	return nil
 }
@@ -83,7 +347,7 @@ func (s *AdminServer) Shutdown(req *pb.ShutdownRequest, stream pb.Admin_Shutdown
 func (s *AdminServer) Health(ctx context.Context, req *pb.HealthRequest) (*pb.HealthResponse, error) {
 	var response pb.HealthResponse
 
-	response.Health = s.nodeHealth
+	response.Health = s.getNodeHealth()
 
 	return &response, nil
 }
@@ -91,7 +355,55 @@ func (s *AdminServer) Health(ctx context.Context, req *pb.HealthRequest) (*pb.He
 func (s *AdminServer) Statistics(context.Context, *pb.StatisticsRequest) (*pb.StatisticsResponse, error) {
 	var response pb.StatisticsResponse
 
+	uptime := s.server.proxy.Uptime()
+
 	response.Queries = s.server.proxy.Stats()
+	response.Starts = int32(uptime.Starts)
+	response.Uptime = int64(uptime.Total / time.Second)
+
+	if !uptime.Since.IsZero() {
+		response.Since = uptime.Since.Unix()
+	}
+
+	return &response, nil
+}
+
+func (s *AdminServer) Reload(ctx context.Context, req *pb.ReloadRequest) (*pb.ReloadResponse, error) {
+	var response pb.ReloadResponse
+
+	if err := s.server.Reload(); err != nil {
+		return nil, err
+	}
+
+	response.Success = true
+
+	return &response, nil
+}
+
+// GetConfig returns the configuration that the proxy is running with, as YAML
+// or JSON. It includes changes made at runtime, such as nodes that were added,
+// removed, disabled or discovered, and secrets are masked.
+func (s *AdminServer) GetConfig(ctx context.Context, req *pb.ConfigRequest) (*pb.ConfigResponse, error) {
+	var response pb.ConfigResponse
+	var data []byte
+	var err error
+
+	dump := config.GetDump()
+
+	switch req.Format {
+	case "", "yaml":
+		data, err = yaml.Marshal(dump)
+	case "json":
+		data, err = json.MarshalIndent(dump, "", "  ")
+	default:
+		return nil, fmt.Errorf("unsupported format '%s'", req.Format)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	response.Config = string(data)
 
 	return &response, nil
 }
@@ -104,6 +416,10 @@ func (s *AdminServer) Version(context.Context, *pb.VersionRequest) (*pb.VersionR
 	return &response, nil
 }
 
+func (s *AdminServer) Stop() {
+	s.grpc.Stop()
+}
+
 func (s *AdminServer) Serve(l net.Listener) {
 	log.Infof("Admin Server listening on: %s", l.Addr())
 	defer s.server.waitGroup.Done()
@@ -118,44 +434,218 @@ func (s *AdminServer) Serve(l net.Listener) {
 	}
 }
 
+/*
+ * The interval at which the health check loop wakes up to look for nodes that
+ * are due to be checked.
+ */
+const healthCheckTick = time.Second
+
 func (s *AdminServer) startHealthCheck() {
-	nodes := config.GetNodes()
-	hcConfig := config.GetHealthCheckConfig()
+	/* The time at which each node is next due to be checked. */
+	next := make(map[string]time.Time)
 
 	for {
+		nodes := config.GetNodes()
+		failoverConfig := config.GetFailoverConfig()
+		now := time.Now()
+
+		/* The masters of the clusters that were checked in this pass. */
+		var masters []string
+
+		/*
+		 * Check the nodes that are due concurrently, so that a node that does
+		 * not answer does not delay the checks of the others. Each check is
+		 * bounded by the node's health check timeout.
+		 */
+		var checks sync.WaitGroup
+
 		for name, node := range nodes {
-			/* Connect to node */
-			conn, err := getDBConnection(node)
+			if node.Disabled || now.Before(next[name]) {
+				continue
+			}
 
-			if err != nil {
-				log.Errorf("healthcheck: error creating connection to '%s'", name)
-				log.Errorf("healthcheck: %s", err.Error())
+			hcConfig := config.GetNodeHealthCheckConfig(name)
+			next[name] = now.Add(time.Duration(hcConfig.Delay) * time.Second)
+
+			checks.Add(1)
+			go func(name string, node common.Node) {
+				defer checks.Done()
+				s.checkNode(name, node, hcConfig)
+			}(name, node)
+
+			if node.Role == common.NODE_ROLE_MASTER {
+				masters = append(masters, name)
 			}
+		}
 
-			/* Perform Health Check Query */
-			rows, err := conn.Query(hcConfig.Query)
+		checks.Wait()
 
-			if err != nil {
-				log.Errorf("healthcheck: query failed: %s", err.Error())
-				s.nodeHealth[name] = false
-				continue
+		/* Forget nodes that have been removed or disabled. */
+		for name := range next {
+			if node, ok := nodes[name]; !ok || node.Disabled {
+				delete(next, name)
+				s.forgetNodeHealth(name)
+			}
+		}
+
+		/* Fail over to a promoted node if a master is unhealthy. */
+		for _, master := range masters {
+			if failoverConfig.Enable && !s.isHealthy(master) {
+				s.failover(master)
 			}
+		}
+
+		time.Sleep(healthCheckTick)
+	}
+}
 
-			rows.Close()
+// getNodeHealth returns a copy of the result of the last health check of each
+// node.
+func (s *AdminServer) getNodeHealth() map[string]bool {
+	s.healthLock.RLock()
+	defer s.healthLock.RUnlock()
 
-			/* Update health status */
-			s.nodeHealth[name] = true
+	health := make(map[string]bool, len(s.nodeHealth))
 
-			conn.Close()
+	for name, healthy := range s.nodeHealth {
+		health[name] = healthy
+	}
+
+	return health
+}
+
+// isHealthy reports whether the last health check of the node succeeded.
+func (s *AdminServer) isHealthy(name string) bool {
+	s.healthLock.RLock()
+	defer s.healthLock.RUnlock()
+
+	return s.nodeHealth[name]
+}
+
+/*
+ * The checks of the nodes run concurrently, so the results are only read and
+ * written while holding the lock.
+ */
+func (s *AdminServer) setNodeHealth(name string, healthy bool) {
+	s.healthLock.Lock()
+	defer s.healthLock.Unlock()
+
+	/* Report nodes going down, including at the first check, and back up. */
+	if previous, ok := s.nodeHealth[name]; healthy && ok && !previous {
+		events.Emit(events.Event{
+			Type:    events.TypeNodeUp,
+			Node:    name,
+			Message: fmt.Sprintf("node '%s' is healthy again", name),
+		})
+	} else if !healthy && (!ok || previous) {
+		events.Emit(events.Event{
+			Type:    events.TypeNodeDown,
+			Node:    name,
+			Message: fmt.Sprintf("node '%s' is unhealthy", name),
+		})
+	}
+
+	s.nodeHealth[name] = healthy
+	metrics.SetNodeHealth(name, healthy)
+}
+
+func (s *AdminServer) forgetNodeHealth(name string) {
+	s.healthLock.Lock()
+	defer s.healthLock.Unlock()
+
+	delete(s.nodeHealth, name)
+}
+
+// checkNode runs the configured probe against a node and records the result.
+func (s *AdminServer) checkNode(name string, node common.Node, hcConfig common.HealthCheckConfig) {
+	probe, err := NewProbe(hcConfig)
+
+	if err != nil {
+		log.Errorf("healthcheck: invalid configuration for '%s'", name)
+		log.Errorf("healthcheck: %s", err.Error())
+		return
+	}
+
+	start := time.Now()
+
+	if err = probe.Check(name, node); err != nil {
+		log.Errorf("healthcheck: %s probe failed for '%s'", hcConfig.Probe, name)
+		log.Errorf("healthcheck: %s", err.Error())
+		s.setNodeHealth(name, false)
+		return
+	}
+
+	/* Record the latency for the latency-aware balancer. */
+	s.server.proxy.SetLatency(name, time.Since(start))
+
+	/* Update health status */
+	s.setNodeHealth(name, true)
+
+	/* Measure the replication lag of replicas for lag-aware routing. */
+	if maxLag := config.GetMaxLag(); node.Role != common.NODE_ROLE_MASTER &&
+		(maxLag.Bytes > 0 || maxLag.Seconds > 0) {
+		lag, err := getReplicationLag(node, time.Duration(hcConfig.Timeout)*time.Second)
+
+		if err != nil {
+			log.Errorf("healthcheck: could not measure replication lag of '%s'", name)
+			log.Errorf("healthcheck: %s", err.Error())
 		}
 
-		time.Sleep(time.Duration(hcConfig.Delay) * time.Second)
+		s.server.proxy.SetLag(name, lag)
+	}
+
+	/* Measure the WAL position of each node for sticky reads. */
+	if config.GetStickyReads() > 0 || config.GetConsistency() == common.CONSISTENCY_LSN {
+		position, err := getWALPosition(node, time.Duration(hcConfig.Timeout)*time.Second)
+
+		if err != nil {
+			log.Errorf("healthcheck: could not measure the WAL position of '%s'", name)
+			log.Errorf("healthcheck: %s", err.Error())
+		}
+
+		s.server.proxy.SetPosition(name, position)
 	}
 }
 
-func getDBConnection(node common.Node) (*sql.DB, error) {
-	host, port, _ := net.SplitHostPort(node.HostPort)
-	creds := config.GetCredentials()
+// getDBConnection opens a connection to the node for the health checks. If the
+// node has several addresses, then the first of them that answers within the
+// timeout is used.
+func getDBConnection(node common.Node, timeout time.Duration) (*sql.DB, error) {
+	addresses := connect.SplitHosts(node.HostPort)
+
+	for _, address := range addresses[:len(addresses)-1] {
+		dbConn, err := openDBConnection(node, address, timeout)
+
+		if err != nil {
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		err = dbConn.PingContext(ctx)
+		cancel()
+
+		if err == nil {
+			return dbConn, nil
+		}
+
+		dbConn.Close()
+	}
+
+	return openDBConnection(node, addresses[len(addresses)-1], timeout)
+}
+
+/*
+ * openDBConnection opens a connection to a single address of the node. Making
+ * the connection gives up after the timeout, rounded up to whole seconds.
+ */
+func openDBConnection(node common.Node, address string, timeout time.Duration) (*sql.DB, error) {
+	host, port, err := net.SplitHostPort(address)
+
+	if err != nil {
+		return nil, connect.CheckAddress(address)
+	}
+
+	creds := config.GetClusterCredentials(node.Cluster)
 
 	connectionString := fmt.Sprintf("host=%s port=%s ", host, port)
 	connectionString += fmt.Sprintf(" user=%s", creds.Username)
@@ -164,6 +654,11 @@ func getDBConnection(node common.Node) (*sql.DB, error) {
 	connectionString += fmt.Sprintf(" sslmode=%s", creds.SSL.SSLMode)
 	connectionString += " application_name=proxy_healthcheck"
 
+	if timeout > 0 {
+		connectionString += fmt.Sprintf(" connect_timeout=%d",
+			int64((timeout+time.Second-1)/time.Second))
+	}
+
 	if creds.Password != "" {
 		connectionString += fmt.Sprintf(" password=%s", creds.Password)
 	}
@@ -0,0 +1,147 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"crypto/subtle"
+	"path"
+	"strings"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+
+	"github.com/crunchydata/crunchy-proxy/common"
+	"github.com/crunchydata/crunchy-proxy/config"
+	"github.com/crunchydata/crunchy-proxy/util/log"
+)
+
+/*
+ * The admin RPCs that only query the proxy. Every other method changes its
+ * state, and may only be called by admin users.
+ */
+var readOnlyMethods = map[string]bool{
+	"Nodes":           true,
+	"Pools":           true,
+	"ShowPools":       true,
+	"TopStatements":   true,
+	"ListSessions":    true,
+	"CacheStatistics": true,
+	"Health":          true,
+	"Statistics":      true,
+	"GetConfig":       true,
+	"Version":         true,
+}
+
+func (s *AdminServer) authorizeUnary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := authorize(ctx, info.FullMethod); err != nil {
+		return nil, err
+	}
+
+	return handler(ctx, req)
+}
+
+func (s *AdminServer) authorizeStream(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := authorize(stream.Context(), info.FullMethod); err != nil {
+		return err
+	}
+
+	return handler(srv, stream)
+}
+
+// authorize checks that the admin client making a request is allowed to call
+// the method. If no admin users are configured, then every request is allowed.
+// Otherwise, the client must be a configured user, and only users with the
+// 'admin' role may call methods other than those that only query the proxy.
+func authorize(ctx context.Context, method string) error {
+	users := config.GetAdminConfig().Users
+
+	if len(users) == 0 {
+		return nil
+	}
+
+	var address string
+
+	if p, ok := peer.FromContext(ctx); ok {
+		address = p.Addr.String()
+	}
+
+	user := authenticate(ctx, users)
+
+	if user == nil {
+		log.Errorf("Admin Client: %s - authentication failed", address)
+		return grpc.Errorf(codes.Unauthenticated, "authentication required")
+	}
+
+	method = path.Base(method)
+
+	if !readOnlyMethods[method] && user.Role != common.ADMIN_ROLE_ADMIN {
+		log.Errorf("Admin Client: %s - user '%s' is not allowed to call %s",
+			address, user.Name, method)
+		return grpc.Errorf(codes.PermissionDenied,
+			"user '%s' is not allowed to call %s", user.Name, method)
+	}
+
+	return nil
+}
+
+/*
+ * authenticate finds the user making a request. A bearer token in the
+ * 'authorization' metadata is checked first, then the common name of a
+ * verified client certificate.
+ */
+func authenticate(ctx context.Context, users []config.AdminUser) *config.AdminUser {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for _, value := range md["authorization"] {
+			if !strings.HasPrefix(value, "Bearer ") {
+				continue
+			}
+
+			token := []byte(strings.TrimPrefix(value, "Bearer "))
+
+			for i := range users {
+				if users[i].Token != "" &&
+					subtle.ConstantTimeCompare(token, []byte(users[i].Token)) == 1 {
+					return &users[i]
+				}
+			}
+		}
+	}
+
+	p, ok := peer.FromContext(ctx)
+
+	if !ok {
+		return nil
+	}
+
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+
+	if !ok || len(tlsInfo.State.VerifiedChains) == 0 {
+		return nil
+	}
+
+	name := tlsInfo.State.VerifiedChains[0][0].Subject.CommonName
+
+	for i := range users {
+		if users[i].Name == name {
+			return &users[i]
+		}
+	}
+
+	return nil
+}
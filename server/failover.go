@@ -0,0 +1,150 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/crunchydata/crunchy-proxy/config"
+	"github.com/crunchydata/crunchy-proxy/events"
+	"github.com/crunchydata/crunchy-proxy/util/log"
+)
+
+// PromotionDetector determines which node has been promoted to replace a
+// failed master.
+type PromotionDetector interface {
+	// Detect returns the name of the node that is now the primary. If no node
+	// has been promoted yet, then an empty name is returned.
+	Detect(failed string) (string, error)
+}
+
+// NewPromotionDetector creates the detector for the failover configuration.
+// If a hook is configured then it is used, otherwise the replicas are polled.
+func NewPromotionDetector(failoverConfig config.FailoverConfig) PromotionDetector {
+	if failoverConfig.Hook != "" {
+		return &hookDetector{command: failoverConfig.Hook}
+	}
+
+	return &recoveryDetector{}
+}
+
+// recoveryDetector finds the promoted node by checking pg_is_in_recovery() on
+// each of the remaining nodes of the failed master's cluster.
+type recoveryDetector struct{}
+
+func (d *recoveryDetector) Detect(failed string) (string, error) {
+	nodes := config.GetNodes()
+	cluster := nodes[failed].Cluster
+
+	for name, node := range nodes {
+		if name == failed || node.Disabled || node.Cluster != cluster {
+			continue
+		}
+
+		timeout := time.Duration(config.GetNodeHealthCheckConfig(name).Timeout) * time.Second
+		conn, err := getDBConnection(node, timeout)
+
+		if err != nil {
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+
+		var inRecovery bool
+		err = conn.QueryRowContext(ctx, "SELECT pg_is_in_recovery()").Scan(&inRecovery)
+		cancel()
+		conn.Close()
+
+		if err != nil {
+			log.Errorf("failover: could not check recovery status of '%s'", name)
+			log.Errorf("failover: %s", err.Error())
+			continue
+		}
+
+		if !inRecovery {
+			return name, nil
+		}
+	}
+
+	return "", nil
+}
+
+// hookDetector runs an external command to determine the promoted node. The
+// command is passed the name of the failed master and must print the name of
+// the new primary, or nothing if there isn't one yet.
+type hookDetector struct {
+	command string
+}
+
+func (d *hookDetector) Detect(failed string) (string, error) {
+	output, err := exec.Command(d.command, failed).Output()
+
+	if err != nil {
+		return "", err
+	}
+
+	name := strings.TrimSpace(string(output))
+
+	if name == "" {
+		return "", nil
+	}
+
+	nodes := config.GetNodes()
+
+	if _, ok := nodes[name]; !ok {
+		return "", errors.New("failover hook returned unknown node '" + name + "'")
+	}
+
+	if nodes[name].Cluster != nodes[failed].Cluster {
+		return "", errors.New("failover hook returned node '" + name + "' of another cluster")
+	}
+
+	return name, nil
+}
+
+// failover attempts to replace the failed master with a promoted node.
+func (s *AdminServer) failover(failed string) {
+	log.Infof("failover: master '%s' is unhealthy, looking for a promoted node", failed)
+
+	detector := NewPromotionDetector(config.GetFailoverConfig())
+
+	name, err := detector.Detect(failed)
+
+	if err != nil {
+		log.Errorf("failover: %s", err.Error())
+		return
+	}
+
+	if name == "" {
+		log.Info("failover: no promoted node found")
+		return
+	}
+
+	if err = s.server.proxy.Promote(name); err != nil {
+		log.Errorf("failover: %s", err.Error())
+		return
+	}
+
+	events.Emit(events.Event{
+		Type:    events.TypeFailover,
+		Node:    name,
+		Message: fmt.Sprintf("failed over from master '%s' to '%s'", failed, name),
+	})
+}
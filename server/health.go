@@ -0,0 +1,97 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/crunchydata/crunchy-proxy/common"
+	"github.com/crunchydata/crunchy-proxy/config"
+)
+
+// healthz reports that the proxy is alive. It succeeds for as long as the
+// process is able to answer.
+func (s *MetricsServer) healthz(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintln(w, "ok")
+}
+
+// readyz reports whether the proxy can serve clients. It fails while the
+// proxy is not accepting clients, and unless the master of every cluster
+// passed its last health check.
+func (s *MetricsServer) readyz(w http.ResponseWriter, r *http.Request) {
+	if err := s.ready(); err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	fmt.Fprintln(w, "ok")
+}
+
+func (s *MetricsServer) ready() error {
+	if !s.server.proxy.Ready() {
+		return errors.New("the proxy is not accepting clients")
+	}
+
+	health := s.server.admin.getNodeHealth()
+
+	/* Only clusters that have enabled nodes are served. */
+	clusters := make(map[string]bool)
+	var healthy int
+
+	for name, node := range config.GetNodes() {
+		if node.Disabled {
+			continue
+		}
+
+		clusters[node.Cluster] = true
+
+		if health[name] {
+			healthy++
+		}
+	}
+
+	if healthy == 0 {
+		return errors.New("no node is healthy")
+	}
+
+	var unavailable []string
+
+	for cluster := range clusters {
+		if name, _ := config.GetMasterNode(cluster); name == "" || !health[name] {
+			unavailable = append(unavailable, describeCluster(cluster))
+		}
+	}
+
+	if len(unavailable) > 0 {
+		sort.Strings(unavailable)
+		return fmt.Errorf("no healthy %s for %s", common.NODE_ROLE_MASTER,
+			strings.Join(unavailable, ", "))
+	}
+
+	return nil
+}
+
+/* describeCluster names a cluster for reporting, including the unnamed one. */
+func describeCluster(cluster string) string {
+	if cluster == "" {
+		return "the default cluster"
+	}
+
+	return fmt.Sprintf("cluster '%s'", cluster)
+}
@@ -0,0 +1,64 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/crunchydata/crunchy-proxy/util/grpcutil"
+	"github.com/crunchydata/crunchy-proxy/util/log"
+)
+
+type MetricsServer struct {
+	server   *Server
+	http     *http.Server
+	listener net.Listener
+}
+
+func NewMetricsServer(s *Server) *MetricsServer {
+	metrics := &MetricsServer{
+		server: s,
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/healthz", metrics.healthz)
+	mux.HandleFunc("/readyz", metrics.readyz)
+
+	metrics.http = &http.Server{Handler: mux}
+
+	return metrics
+}
+
+func (s *MetricsServer) Serve(l net.Listener) {
+	log.Infof("Metrics Server listening on: %s", l.Addr())
+	defer s.server.waitGroup.Done()
+	s.listener = l
+
+	err := s.http.Serve(l)
+
+	if !grpcutil.IsClosedConnection(err) {
+		log.Infof("Server Error: %s", err)
+	}
+}
+
+func (s *MetricsServer) Stop() {
+	if s.listener != nil {
+		s.listener.Close()
+	}
+}
@@ -0,0 +1,235 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net"
+	"os/exec"
+	"time"
+
+	"github.com/crunchydata/crunchy-proxy/common"
+	"github.com/crunchydata/crunchy-proxy/connect"
+	"github.com/crunchydata/crunchy-proxy/proxy"
+)
+
+// Probe checks the health of a node.
+type Probe interface {
+	// Check returns an error if the node is unhealthy.
+	Check(name string, node common.Node) error
+}
+
+// NewProbe creates the probe for the health check configuration.
+func NewProbe(hcConfig common.HealthCheckConfig) (Probe, error) {
+	timeout := time.Duration(hcConfig.Timeout) * time.Second
+
+	switch hcConfig.Probe {
+	case common.PROBE_TCP:
+		return &tcpProbe{timeout: timeout}, nil
+	case common.PROBE_SQL:
+		return &sqlProbe{query: hcConfig.Query, timeout: timeout}, nil
+	case common.PROBE_LAG:
+		return &lagProbe{maxLag: hcConfig.MaxLag, timeout: timeout}, nil
+	case common.PROBE_SCRIPT:
+		if hcConfig.Script == "" {
+			return nil, fmt.Errorf("the '%s' probe requires a script", common.PROBE_SCRIPT)
+		}
+
+		return &scriptProbe{command: hcConfig.Script, timeout: timeout}, nil
+	}
+
+	return nil, fmt.Errorf("unknown health check probe '%s'", hcConfig.Probe)
+}
+
+// tcpProbe considers a node healthy if a TCP connection can be opened to it,
+// at any of its addresses.
+type tcpProbe struct {
+	timeout time.Duration
+}
+
+func (p *tcpProbe) Check(name string, node common.Node) error {
+	var err error
+
+	for _, address := range connect.SplitHosts(node.HostPort) {
+		var conn net.Conn
+
+		if conn, err = net.DialTimeout("tcp", address, p.timeout); err == nil {
+			return conn.Close()
+		}
+	}
+
+	return err
+}
+
+// sqlProbe considers a node healthy if the configured query succeeds.
+type sqlProbe struct {
+	query   string
+	timeout time.Duration
+}
+
+func (p *sqlProbe) Check(name string, node common.Node) error {
+	conn, err := getDBConnection(node, p.timeout)
+
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+
+	rows, err := conn.QueryContext(ctx, p.query)
+
+	if err != nil {
+		return err
+	}
+
+	return rows.Close()
+}
+
+// lagProbe considers a node healthy if it has replayed all but 'maxLag' bytes
+// of the WAL that it has received. The master is never lagging.
+type lagProbe struct {
+	maxLag  int64
+	timeout time.Duration
+}
+
+func (p *lagProbe) Check(name string, node common.Node) error {
+	lag, err := getReplicationLag(node, p.timeout)
+
+	if err != nil {
+		return err
+	}
+
+	if lag.Bytes > p.maxLag {
+		return fmt.Errorf("replication lag of %d bytes exceeds %d bytes", lag.Bytes, p.maxLag)
+	}
+
+	return nil
+}
+
+/*
+ * Measure how much received WAL a node has yet to replay and how long ago the
+ * last transaction was replayed. A node that has replayed everything it has
+ * received, including the master, is not lagging.
+ */
+const lagQuery = `SELECT
+	COALESCE(pg_wal_lsn_diff(pg_last_wal_receive_lsn(), pg_last_wal_replay_lsn()), 0)::bigint,
+	CASE WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+		ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+	END::float8`
+
+func getReplicationLag(node common.Node, timeout time.Duration) (*proxy.Lag, error) {
+	conn, err := getDBConnection(node, timeout)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	query, err := walQuery(ctx, conn, lagQuery)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var bytes int64
+	var seconds float64
+
+	if err = conn.QueryRowContext(ctx, query).Scan(&bytes, &seconds); err != nil {
+		return nil, err
+	}
+
+	return &proxy.Lag{
+		Bytes: bytes,
+		Delay: time.Duration(seconds * float64(time.Second)),
+	}, nil
+}
+
+/*
+ * Measure the WAL position written on the master, or replayed on a replica, as
+ * a number of bytes so that positions can be compared.
+ */
+const positionQuery = `SELECT COALESCE(pg_wal_lsn_diff(
+	CASE WHEN pg_is_in_recovery() THEN pg_last_wal_replay_lsn()
+		ELSE pg_current_wal_lsn()
+	END, '0/0'), 0)::bigint`
+
+func getWALPosition(node common.Node, timeout time.Duration) (*proxy.WALPosition, error) {
+	conn, err := getDBConnection(node, timeout)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	query, err := walQuery(ctx, conn, positionQuery)
+
+	if err != nil {
+		return nil, err
+	}
+
+	/* Writes that finished before the query started are at or before it. */
+	measured := time.Now()
+
+	var lsn int64
+
+	if err = conn.QueryRowContext(ctx, query).Scan(&lsn); err != nil {
+		return nil, err
+	}
+
+	return &proxy.WALPosition{
+		LSN:      lsn,
+		Measured: measured,
+	}, nil
+}
+
+// walQuery returns the query with its WAL functions named as they are in the
+// node's version of PostgreSQL, since they were renamed in version 10.
+func walQuery(ctx context.Context, conn *sql.DB, query string) (string, error) {
+	var version string
+
+	if err := conn.QueryRowContext(ctx, "SHOW server_version_num").Scan(&version); err != nil {
+		return "", err
+	}
+
+	return proxy.WALQuery(query, version)
+}
+
+// scriptProbe runs an external command to check a node. The command is passed
+// the name and host:port of the node and must exit with a zero status if the
+// node is healthy.
+type scriptProbe struct {
+	command string
+	timeout time.Duration
+}
+
+func (p *scriptProbe) Check(name string, node common.Node) error {
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+
+	return exec.CommandContext(ctx, p.command, name, node.HostPort).Run()
+}
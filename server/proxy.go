@@ -15,17 +15,24 @@ limitations under the License.
 package server
 
 import (
+	"fmt"
 	"net"
+	"sync"
+	"time"
 
+	"github.com/crunchydata/crunchy-proxy/common"
+	"github.com/crunchydata/crunchy-proxy/config"
+	"github.com/crunchydata/crunchy-proxy/connect"
+	"github.com/crunchydata/crunchy-proxy/pool"
 	"github.com/crunchydata/crunchy-proxy/proxy"
 	"github.com/crunchydata/crunchy-proxy/util/log"
 )
 
 type ProxyServer struct {
-	ch       chan bool
-	server   *Server
-	p        *proxy.Proxy
-	listener net.Listener
+	ch        chan bool
+	server    *Server
+	p         *proxy.Proxy
+	listeners []net.Listener
 }
 
 func NewProxyServer(s *Server) *ProxyServer {
@@ -36,18 +43,51 @@ func NewProxyServer(s *Server) *ProxyServer {
 	return proxy
 }
 
-func (s *ProxyServer) Serve(l net.Listener) error {
-	log.Infof("Proxy Server listening on: %s", l.Addr())
+// Serve accepts clients on each of the listeners until the server is stopped.
+func (s *ProxyServer) Serve(listeners ...net.Listener) error {
 	defer s.server.waitGroup.Done()
-	s.listener = l
+	s.listeners = listeners
 
 	s.p = proxy.NewProxy()
 
+	var accepting sync.WaitGroup
+
+	for _, l := range listeners {
+		log.Infof("Proxy Server listening on: %s%s", l.Addr(), describeRoute(l))
+
+		accepting.Add(1)
+
+		go func(l net.Listener) {
+			defer accepting.Done()
+			s.accept(l)
+		}(l)
+	}
+
+	accepting.Wait()
+
+	return nil
+}
+
+// routedListener is a listener whose clients are routed to a particular
+// cluster or role, or that has its own settings.
+type routedListener struct {
+	net.Listener
+	name  string
+	route proxy.Route
+}
+
+func (s *ProxyServer) accept(l net.Listener) {
+	var route proxy.Route
+
+	if rl, ok := l.(*routedListener); ok {
+		route = rl.route
+	}
+
 	for {
 
 		select {
 		case <-s.ch:
-			return nil
+			return
 		default:
 		}
 
@@ -57,15 +97,192 @@ func (s *ProxyServer) Serve(l net.Listener) error {
 			continue
 		}
 
-		go s.p.HandleConnection(conn)
+		connect.SetTCPOptions(conn, config.GetProxyConfig().TCP)
+
+		go s.p.HandleConnection(conn, route)
 	}
 }
 
+/* describeRoute describes the routing of a listener's clients for logging. */
+func describeRoute(l net.Listener) string {
+	rl, ok := l.(*routedListener)
+
+	if !ok {
+		return ""
+	}
+
+	var description string
+
+	if rl.name != "" {
+		description += fmt.Sprintf(" as '%s'", rl.name)
+	}
+
+	if rl.route.Cluster != "" {
+		description += fmt.Sprintf(" for cluster '%s'", rl.route.Cluster)
+	}
+
+	switch rl.route.Role {
+	case common.NODE_ROLE_MASTER:
+		description += " (writes)"
+	case common.NODE_ROLE_REPLICA:
+		description += " (reads)"
+	}
+
+	if rl.route.SSL != nil && !rl.route.SSL.Enable {
+		description += " without SSL"
+	}
+
+	if rl.route.ProxyProtocol != nil && rl.route.ProxyProtocol.Enable {
+		description += " with PROXY protocol"
+	}
+
+	return description
+}
+
+// Ready returns whether the proxy is accepting clients.
+func (s *ProxyServer) Ready() bool {
+	select {
+	case <-s.ch:
+		return false
+	default:
+	}
+
+	return s.p != nil
+}
+
 func (s *ProxyServer) Stats() map[string]int32 {
-	return s.p.Stats
+	if s.p == nil {
+		return nil
+	}
+
+	return s.p.QueryCounts()
+}
+
+func (s *ProxyServer) Uptime() proxy.Uptime {
+	if s.p == nil {
+		return proxy.Uptime{}
+	}
+
+	return s.p.Uptime()
+}
+
+func (s *ProxyServer) PoolStats() []pool.Stats {
+	if s.p == nil {
+		return nil
+	}
+
+	return s.p.PoolStats()
+}
+
+func (s *ProxyServer) TopStatements(limit int) []proxy.StatementStats {
+	if s.p == nil {
+		return nil
+	}
+
+	return s.p.TopStatements(limit)
+}
+
+func (s *ProxyServer) CacheStats() proxy.CacheStats {
+	if s.p == nil {
+		return proxy.CacheStats{}
+	}
+
+	return s.p.CacheStats()
+}
+
+func (s *ProxyServer) Sessions() []proxy.SessionInfo {
+	if s.p == nil {
+		return nil
+	}
+
+	return s.p.Sessions()
+}
+
+func (s *ProxyServer) TerminateSession(processID int32) error {
+	if s.p == nil {
+		return fmt.Errorf("unknown session %d", processID)
+	}
+
+	return s.p.TerminateSession(processID)
+}
+
+func (s *ProxyServer) SetReadOnly(readOnly bool) error {
+	if s.p == nil {
+		return fmt.Errorf("the proxy is not running")
+	}
+
+	s.p.SetReadOnly(readOnly)
+
+	return nil
+}
+
+func (s *ProxyServer) Pause(timeout time.Duration) (int, error) {
+	if s.p == nil {
+		return 0, fmt.Errorf("the proxy is not running")
+	}
+
+	return s.p.Pause(timeout), nil
+}
+
+func (s *ProxyServer) Resume() error {
+	if s.p == nil {
+		return fmt.Errorf("the proxy is not running")
+	}
+
+	s.p.Resume()
+
+	return nil
+}
+
+func (s *ProxyServer) DrainNode(name string, timeout time.Duration, report func(proxy.DrainProgress)) error {
+	if s.p == nil {
+		return fmt.Errorf("the proxy is not running")
+	}
+
+	return s.p.DrainNode(name, timeout, report)
+}
+
+func (s *ProxyServer) Promote(name string) error {
+	return s.p.Promote(name)
+}
+
+func (s *ProxyServer) Reload() {
+	if s.p != nil {
+		s.p.Reload()
+	}
+}
+
+func (s *ProxyServer) SetLatency(name string, latency time.Duration) {
+	if s.p != nil {
+		s.p.SetLatency(name, latency)
+	}
+}
+
+func (s *ProxyServer) SetLag(name string, lag *proxy.Lag) {
+	if s.p != nil {
+		s.p.SetLag(name, lag)
+	}
+}
+
+func (s *ProxyServer) SetPosition(name string, position *proxy.WALPosition) {
+	if s.p != nil {
+		s.p.SetPosition(name, position)
+	}
 }
 
 func (s *ProxyServer) Stop() {
-	s.listener.Close()
 	close(s.ch)
+
+	for _, l := range s.listeners {
+		l.Close()
+	}
+}
+
+// Drain stops accepting new clients and gracefully shuts down the proxy.
+func (s *ProxyServer) Drain(timeout time.Duration) {
+	s.Stop()
+
+	if s.p != nil {
+		s.p.Drain(timeout)
+	}
 }
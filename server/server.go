@@ -15,17 +15,35 @@ limitations under the License.
 package server
 
 import (
+	"errors"
+	"fmt"
 	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
 	"sync"
+	"syscall"
+	"time"
 
+	"github.com/crunchydata/crunchy-proxy/audit"
+	"github.com/crunchydata/crunchy-proxy/capture"
+	"github.com/crunchydata/crunchy-proxy/common"
 	"github.com/crunchydata/crunchy-proxy/config"
+	"github.com/crunchydata/crunchy-proxy/discovery"
+	"github.com/crunchydata/crunchy-proxy/events"
+	"github.com/crunchydata/crunchy-proxy/proxy"
+	"github.com/crunchydata/crunchy-proxy/tracing"
 	"github.com/crunchydata/crunchy-proxy/util/log"
 )
 
+const defaultSocketMode os.FileMode = 0777
+
 type Server struct {
 	admin     *AdminServer
 	proxy     *ProxyServer
+	metrics   *MetricsServer
 	waitGroup *sync.WaitGroup
+	shutdown  sync.Once
 }
 
 func NewServer() *Server {
@@ -37,12 +55,45 @@ func NewServer() *Server {
 
 	s.proxy = NewProxyServer(s)
 
+	s.metrics = NewMetricsServer(s)
+
 	return s
 }
 
 func (s *Server) Start() {
 	proxyConfig := config.GetProxyConfig()
 	adminConfig := config.GetAdminConfig()
+	metricsConfig := config.GetMetricsConfig()
+
+	if err := setupLogging(); err != nil {
+		log.Fatal(err.Error())
+		return
+	}
+
+	if err := audit.Setup(); err != nil {
+		log.Fatal(err.Error())
+		return
+	}
+
+	if err := capture.Setup(); err != nil {
+		log.Fatal(err.Error())
+		return
+	}
+
+	if err := events.Setup(); err != nil {
+		log.Fatal(err.Error())
+		return
+	}
+
+	if err := tracing.Setup(); err != nil {
+		log.Fatal(err.Error())
+		return
+	}
+
+	if err := proxy.LoadPlugins(); err != nil {
+		log.Fatal(err.Error())
+		return
+	}
 
 	log.Info("Admin Server Starting...")
 	adminListener, err := net.Listen("tcp", adminConfig.HostPort)
@@ -55,6 +106,19 @@ func (s *Server) Start() {
 	s.waitGroup.Add(1)
 	go s.admin.Serve(adminListener)
 
+	if metricsConfig.HostPort != "" {
+		log.Info("Metrics Server Starting...")
+		metricsListener, err := net.Listen("tcp", metricsConfig.HostPort)
+
+		if err != nil {
+			log.Fatal(err.Error())
+			return
+		}
+
+		s.waitGroup.Add(1)
+		go s.metrics.Serve(metricsListener)
+	}
+
 	log.Info("Proxy Server Starting...")
 	proxyListener, err := net.Listen("tcp", proxyConfig.HostPort)
 	if err != nil {
@@ -62,10 +126,298 @@ func (s *Server) Start() {
 		return
 	}
 
+	listeners := []net.Listener{proxyListener}
+
+	if proxyConfig.Socket.Path != "" {
+		socketListener, err := listenUnix(proxyConfig.Socket, proxyConfig.HostPort)
+
+		if err != nil {
+			log.Fatal(err.Error())
+			return
+		}
+
+		listeners = append(listeners, socketListener)
+	}
+
+	/* Clusters with their own listener. */
+	for _, cluster := range config.GetClusters() {
+		clusterConfig, ok := config.GetClusterConfig(cluster)
+
+		if !ok || clusterConfig.HostPort == "" {
+			continue
+		}
+
+		clusterListener, err := listenRouted(clusterConfig.HostPort, proxy.Route{Cluster: cluster})
+
+		if err != nil {
+			log.Fatal(err.Error())
+			return
+		}
+
+		listeners = append(listeners, clusterListener)
+	}
+
+	/* Listeners that route every query to the master or to the replicas. */
+	roles := map[string]string{
+		common.NODE_ROLE_MASTER:  proxyConfig.WriteHostPort,
+		common.NODE_ROLE_REPLICA: proxyConfig.ReadHostPort,
+	}
+
+	for role, hostPort := range roles {
+		if hostPort == "" {
+			continue
+		}
+
+		roleListener, err := listenRouted(hostPort, proxy.Route{Role: role})
+
+		if err != nil {
+			log.Fatal(err.Error())
+			return
+		}
+
+		listeners = append(listeners, roleListener)
+	}
+
+	/* Additional listeners, each with its own settings. */
+	for _, listenerConfig := range proxyConfig.Listeners {
+		listener, err := listenConfigured(listenerConfig, proxyConfig.HostPort)
+
+		if err != nil {
+			log.Fatal(err.Error())
+			return
+		}
+
+		listeners = append(listeners, listener)
+	}
+
 	s.waitGroup.Add(1)
-	go s.proxy.Serve(proxyListener)
+	go s.proxy.Serve(listeners...)
+
+	if discovery.Enabled() {
+		go discovery.Run(s.proxy.Reload)
+	}
+
+	go s.watchConfig()
+
+	go s.handleSignals()
 
 	s.waitGroup.Wait()
 
 	log.Info("Server Exiting...")
 }
+
+// listenRouted listens for clients that are routed to a particular cluster or
+// role.
+func listenRouted(hostPort string, route proxy.Route) (net.Listener, error) {
+	listener, err := net.Listen("tcp", hostPort)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &routedListener{Listener: listener, route: route}, nil
+}
+
+// listenConfigured listens as configured by an entry of the listeners of the
+// proxy, on its address or Unix socket, routing its clients and overriding
+// the SSL and PROXY protocol settings of the proxy as configured.
+func listenConfigured(listenerConfig config.ListenerConfig, proxyHostPort string) (net.Listener, error) {
+	name := listenerConfig.Name
+
+	if name == "" {
+		name = listenerConfig.HostPort + listenerConfig.Socket.Path
+	}
+
+	route := proxy.Route{
+		Cluster:       listenerConfig.Cluster,
+		Role:          listenerConfig.Role,
+		SSL:           listenerConfig.SSL,
+		ProxyProtocol: listenerConfig.ProxyProtocol,
+	}
+
+	if route.Cluster != "" {
+		if _, ok := config.GetClusterConfig(route.Cluster); !ok {
+			return nil, fmt.Errorf("listener '%s': unknown cluster '%s'", name, route.Cluster)
+		}
+	}
+
+	if route.Role != "" && route.Role != common.NODE_ROLE_MASTER && route.Role != common.NODE_ROLE_REPLICA {
+		return nil, fmt.Errorf("listener '%s': unknown role '%s'", name, route.Role)
+	}
+
+	var listener net.Listener
+	var err error
+
+	if listenerConfig.Socket.Path != "" {
+		/* A socket in a directory is named after the listener's port. */
+		hostPort := listenerConfig.HostPort
+
+		if hostPort == "" {
+			hostPort = proxyHostPort
+		}
+
+		listener, err = listenUnix(listenerConfig.Socket, hostPort)
+	} else {
+		listener, err = net.Listen("tcp", listenerConfig.HostPort)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("listener '%s': %s", name, err.Error())
+	}
+
+	return &routedListener{Listener: listener, name: listenerConfig.Name, route: route}, nil
+}
+
+// listenUnix listens on a Unix socket. If the path is a directory, then the
+// socket is created in it with the name that PostgreSQL clients expect for the
+// port of the given address, e.g. '.s.PGSQL.5432'. A socket left behind by a
+// previous run is removed.
+func listenUnix(socket config.SocketConfig, hostPort string) (net.Listener, error) {
+	path := socket.Path
+
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		_, port, err := net.SplitHostPort(hostPort)
+
+		if err != nil {
+			return nil, err
+		}
+
+		path = filepath.Join(path, ".s.PGSQL."+port)
+	}
+
+	if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		os.Remove(path)
+	}
+
+	listener, err := net.Listen("unix", path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	/* Allow anyone to connect by default, as PostgreSQL does. */
+	mode := socket.Mode
+
+	if mode == 0 {
+		mode = defaultSocketMode
+	}
+
+	if err = os.Chmod(path, mode); err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	return listener, nil
+}
+
+// setupLogging applies the output and component levels configured in the
+// 'log' section.
+func setupLogging() error {
+	logConfig := config.GetLogConfig()
+
+	if err := log.SetComponentLevels(logConfig.Levels); err != nil {
+		return err
+	}
+
+	switch logConfig.Output {
+	case common.LOG_OUTPUT_STDOUT:
+		log.SetStdout()
+	case common.LOG_OUTPUT_FILE:
+		if logConfig.File == "" {
+			return errors.New("log: a file path is required")
+		}
+
+		return log.SetFile(log.FileOptions{
+			Path:           logConfig.File,
+			MaxSize:        logConfig.MaxSize,
+			RotateInterval: time.Duration(logConfig.RotateInterval) * time.Hour,
+			MaxBackups:     logConfig.MaxBackups,
+			Compress:       logConfig.Compress,
+		})
+	case common.LOG_OUTPUT_SYSLOG:
+		return log.SetSyslog(log.SyslogOptions{
+			Network:  logConfig.Syslog.Network,
+			Address:  logConfig.Syslog.Address,
+			Facility: logConfig.Syslog.Facility,
+			Tag:      logConfig.Tag,
+		})
+	case common.LOG_OUTPUT_JOURNALD:
+		return log.SetJournald(logConfig.Tag)
+	default:
+		return fmt.Errorf("log: unknown output '%s'", logConfig.Output)
+	}
+
+	return nil
+}
+
+// Reload re-reads the configuration file and applies it to the running
+// server. Changes to the listen addresses require a restart.
+func (s *Server) Reload() error {
+	log.Info("Reloading configuration...")
+
+	if err := config.Reload(); err != nil {
+		log.Errorf("Error reloading configuration: %s", err.Error())
+		return err
+	}
+
+	s.proxy.Reload()
+
+	if err := setupLogging(); err != nil {
+		log.Errorf("Error configuring logging: %s", err.Error())
+	}
+
+	if err := audit.Setup(); err != nil {
+		log.Errorf("Error reopening audit log: %s", err.Error())
+	}
+
+	if err := capture.Setup(); err != nil {
+		log.Errorf("Error reopening capture file: %s", err.Error())
+	}
+
+	if err := events.Setup(); err != nil {
+		log.Errorf("Error configuring events: %s", err.Error())
+	}
+
+	if err := tracing.Setup(); err != nil {
+		log.Errorf("Error configuring tracing: %s", err.Error())
+	}
+
+	log.Info("Configuration reloaded.")
+
+	return nil
+}
+
+// Shutdown gracefully stops the server. The proxy stops accepting clients and
+// drains the existing ones before the metrics and admin servers are stopped.
+func (s *Server) Shutdown() {
+	s.shutdown.Do(func() {
+		log.Info("Server Shutting Down...")
+
+		s.proxy.Drain(config.GetDrainTimeout())
+
+		s.metrics.Stop()
+
+		s.admin.Stop()
+
+		audit.Close()
+
+		capture.Close()
+
+		tracing.Close()
+	})
+}
+
+func (s *Server) handleSignals() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP, syscall.SIGTERM)
+
+	for sig := range signals {
+		switch sig {
+		case syscall.SIGHUP:
+			s.Reload()
+		case syscall.SIGTERM:
+			s.Shutdown()
+			return
+		}
+	}
+}
@@ -11,14 +11,47 @@ It is generated from these files:
 It has these top-level messages:
 	NodeRequest
 	NodeResponse
+	AddNodeRequest
+	AddNodeResponse
+	RemoveNodeRequest
+	RemoveNodeResponse
+	EnableNodeRequest
+	EnableNodeResponse
+	DisableNodeRequest
+	DisableNodeResponse
+	DrainNodeRequest
+	DrainNodeResponse
 	PoolRequest
 	PoolResponse
+	ShowPoolsRequest
+	PoolStatistics
+	ShowPoolsResponse
+	TopStatementsRequest
+	StatementStatistics
+	TopStatementsResponse
+	ListSessionsRequest
+	SessionInfo
+	ListSessionsResponse
+	TerminateSessionRequest
+	TerminateSessionResponse
+	CacheStatisticsRequest
+	CacheStatisticsResponse
+	ReadOnlyRequest
+	ReadOnlyResponse
+	PauseRequest
+	PauseResponse
+	ResumeRequest
+	ResumeResponse
 	HealthRequest
 	HealthResponse
 	StatisticsRequest
 	StatisticsResponse
 	ShutdownRequest
 	ShutdownResponse
+	ReloadRequest
+	ReloadResponse
+	ConfigRequest
+	ConfigResponse
 	VersionRequest
 	VersionResponse
 */
@@ -56,7 +89,8 @@ func (*NodeRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int
 
 // NodeResponse contains a list of nodes.
 type NodeResponse struct {
-	Nodes map[string]string `protobuf:"bytes,1,rep,name=nodes" json:"nodes,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Nodes    map[string]string `protobuf:"bytes,1,rep,name=nodes" json:"nodes,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Disabled []string          `protobuf:"bytes,2,rep,name=disabled" json:"disabled,omitempty"`
 }
 
 func (m *NodeResponse) Reset()                    { *m = NodeResponse{} }
@@ -71,39 +105,812 @@ func (m *NodeResponse) GetNodes() map[string]string {
 	return nil
 }
 
+func (m *NodeResponse) GetDisabled() []string {
+	if m != nil {
+		return m.Disabled
+	}
+	return nil
+}
+
+// AddNodeRequest requests a node to be added.
+type AddNodeRequest struct {
+	Name     string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	HostPort string `protobuf:"bytes,2,opt,name=host_port,json=hostPort" json:"host_port,omitempty"`
+	Role     string `protobuf:"bytes,3,opt,name=role" json:"role,omitempty"`
+	PoolMode string `protobuf:"bytes,4,opt,name=pool_mode,json=poolMode" json:"pool_mode,omitempty"`
+	Weight   int32  `protobuf:"varint,5,opt,name=weight" json:"weight,omitempty"`
+	Cluster  string `protobuf:"bytes,6,opt,name=cluster" json:"cluster,omitempty"`
+}
+
+func (m *AddNodeRequest) Reset()                    { *m = AddNodeRequest{} }
+func (m *AddNodeRequest) String() string            { return proto.CompactTextString(m) }
+func (*AddNodeRequest) ProtoMessage()               {}
+func (*AddNodeRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
+
+func (m *AddNodeRequest) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *AddNodeRequest) GetHostPort() string {
+	if m != nil {
+		return m.HostPort
+	}
+	return ""
+}
+
+func (m *AddNodeRequest) GetRole() string {
+	if m != nil {
+		return m.Role
+	}
+	return ""
+}
+
+func (m *AddNodeRequest) GetPoolMode() string {
+	if m != nil {
+		return m.PoolMode
+	}
+	return ""
+}
+
+func (m *AddNodeRequest) GetWeight() int32 {
+	if m != nil {
+		return m.Weight
+	}
+	return 0
+}
+
+func (m *AddNodeRequest) GetCluster() string {
+	if m != nil {
+		return m.Cluster
+	}
+	return ""
+}
+
+// AddNodeResponse contains the result of adding a node.
+type AddNodeResponse struct {
+	Success bool `protobuf:"varint,1,opt,name=success" json:"success,omitempty"`
+}
+
+func (m *AddNodeResponse) Reset()                    { *m = AddNodeResponse{} }
+func (m *AddNodeResponse) String() string            { return proto.CompactTextString(m) }
+func (*AddNodeResponse) ProtoMessage()               {}
+func (*AddNodeResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
+
+func (m *AddNodeResponse) GetSuccess() bool {
+	if m != nil {
+		return m.Success
+	}
+	return false
+}
+
+// RemoveNodeRequest requests a node to be removed.
+type RemoveNodeRequest struct {
+	Name string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+}
+
+func (m *RemoveNodeRequest) Reset()                    { *m = RemoveNodeRequest{} }
+func (m *RemoveNodeRequest) String() string            { return proto.CompactTextString(m) }
+func (*RemoveNodeRequest) ProtoMessage()               {}
+func (*RemoveNodeRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
+
+func (m *RemoveNodeRequest) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+// RemoveNodeResponse contains the result of removing a node.
+type RemoveNodeResponse struct {
+	Success bool `protobuf:"varint,1,opt,name=success" json:"success,omitempty"`
+}
+
+func (m *RemoveNodeResponse) Reset()                    { *m = RemoveNodeResponse{} }
+func (m *RemoveNodeResponse) String() string            { return proto.CompactTextString(m) }
+func (*RemoveNodeResponse) ProtoMessage()               {}
+func (*RemoveNodeResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
+
+func (m *RemoveNodeResponse) GetSuccess() bool {
+	if m != nil {
+		return m.Success
+	}
+	return false
+}
+
+// EnableNodeRequest requests a disabled node to be enabled.
+type EnableNodeRequest struct {
+	Name string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+}
+
+func (m *EnableNodeRequest) Reset()                    { *m = EnableNodeRequest{} }
+func (m *EnableNodeRequest) String() string            { return proto.CompactTextString(m) }
+func (*EnableNodeRequest) ProtoMessage()               {}
+func (*EnableNodeRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
+
+func (m *EnableNodeRequest) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+// EnableNodeResponse contains the result of enabling a node.
+type EnableNodeResponse struct {
+	Success bool `protobuf:"varint,1,opt,name=success" json:"success,omitempty"`
+}
+
+func (m *EnableNodeResponse) Reset()                    { *m = EnableNodeResponse{} }
+func (m *EnableNodeResponse) String() string            { return proto.CompactTextString(m) }
+func (*EnableNodeResponse) ProtoMessage()               {}
+func (*EnableNodeResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
+
+func (m *EnableNodeResponse) GetSuccess() bool {
+	if m != nil {
+		return m.Success
+	}
+	return false
+}
+
+// DisableNodeRequest requests a node to be disabled.
+type DisableNodeRequest struct {
+	Name string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+}
+
+func (m *DisableNodeRequest) Reset()                    { *m = DisableNodeRequest{} }
+func (m *DisableNodeRequest) String() string            { return proto.CompactTextString(m) }
+func (*DisableNodeRequest) ProtoMessage()               {}
+func (*DisableNodeRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8} }
+
+func (m *DisableNodeRequest) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+// DisableNodeResponse contains the result of disabling a node.
+type DisableNodeResponse struct {
+	Success bool `protobuf:"varint,1,opt,name=success" json:"success,omitempty"`
+}
+
+func (m *DisableNodeResponse) Reset()                    { *m = DisableNodeResponse{} }
+func (m *DisableNodeResponse) String() string            { return proto.CompactTextString(m) }
+func (*DisableNodeResponse) ProtoMessage()               {}
+func (*DisableNodeResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{9} }
+
+func (m *DisableNodeResponse) GetSuccess() bool {
+	if m != nil {
+		return m.Success
+	}
+	return false
+}
+
 // PoolRequest requests a list of pools.
+// DrainNodeRequest requests a node to be drained for maintenance.
+type DrainNodeRequest struct {
+	Name    string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Timeout int32  `protobuf:"varint,2,opt,name=timeout" json:"timeout,omitempty"`
+}
+
+func (m *DrainNodeRequest) Reset()                    { *m = DrainNodeRequest{} }
+func (m *DrainNodeRequest) String() string            { return proto.CompactTextString(m) }
+func (*DrainNodeRequest) ProtoMessage()               {}
+func (*DrainNodeRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{10} }
+
+func (m *DrainNodeRequest) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *DrainNodeRequest) GetTimeout() int32 {
+	if m != nil {
+		return m.Timeout
+	}
+	return 0
+}
+
+// DrainNodeResponse reports the progress of draining a node.
+type DrainNodeResponse struct {
+	Active     int32 `protobuf:"varint,1,opt,name=active" json:"active,omitempty"`
+	Terminated int32 `protobuf:"varint,2,opt,name=terminated" json:"terminated,omitempty"`
+	Done       bool  `protobuf:"varint,3,opt,name=done" json:"done,omitempty"`
+}
+
+func (m *DrainNodeResponse) Reset()                    { *m = DrainNodeResponse{} }
+func (m *DrainNodeResponse) String() string            { return proto.CompactTextString(m) }
+func (*DrainNodeResponse) ProtoMessage()               {}
+func (*DrainNodeResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{11} }
+
+func (m *DrainNodeResponse) GetActive() int32 {
+	if m != nil {
+		return m.Active
+	}
+	return 0
+}
+
+func (m *DrainNodeResponse) GetTerminated() int32 {
+	if m != nil {
+		return m.Terminated
+	}
+	return 0
+}
+
+func (m *DrainNodeResponse) GetDone() bool {
+	if m != nil {
+		return m.Done
+	}
+	return false
+}
+
 type PoolRequest struct {
 }
 
-func (m *PoolRequest) Reset()                    { *m = PoolRequest{} }
-func (m *PoolRequest) String() string            { return proto.CompactTextString(m) }
-func (*PoolRequest) ProtoMessage()               {}
-func (*PoolRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
+func (m *PoolRequest) Reset()                    { *m = PoolRequest{} }
+func (m *PoolRequest) String() string            { return proto.CompactTextString(m) }
+func (*PoolRequest) ProtoMessage()               {}
+func (*PoolRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{12} }
+
+// PoolResponse contains a list of pools.
+type PoolResponse struct {
+	Pools []string `protobuf:"bytes,1,rep,name=pools" json:"pools,omitempty"`
+}
+
+func (m *PoolResponse) Reset()                    { *m = PoolResponse{} }
+func (m *PoolResponse) String() string            { return proto.CompactTextString(m) }
+func (*PoolResponse) ProtoMessage()               {}
+func (*PoolResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{13} }
+
+func (m *PoolResponse) GetPools() []string {
+	if m != nil {
+		return m.Pools
+	}
+	return nil
+}
+
+// ShowPoolsRequest requests the statistics of each pool.
+type ShowPoolsRequest struct {
+}
+
+func (m *ShowPoolsRequest) Reset()                    { *m = ShowPoolsRequest{} }
+func (m *ShowPoolsRequest) String() string            { return proto.CompactTextString(m) }
+func (*ShowPoolsRequest) ProtoMessage()               {}
+func (*ShowPoolsRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{14} }
+
+// PoolStatistics contains the statistics of a single pool.
+type PoolStatistics struct {
+	Node        string  `protobuf:"bytes,1,opt,name=node" json:"node,omitempty"`
+	Database    string  `protobuf:"bytes,2,opt,name=database" json:"database,omitempty"`
+	User        string  `protobuf:"bytes,3,opt,name=user" json:"user,omitempty"`
+	Total       int32   `protobuf:"varint,4,opt,name=total" json:"total,omitempty"`
+	InUse       int32   `protobuf:"varint,5,opt,name=in_use,json=inUse" json:"in_use,omitempty"`
+	Idle        int32   `protobuf:"varint,6,opt,name=idle" json:"idle,omitempty"`
+	Waiting     int32   `protobuf:"varint,7,opt,name=waiting" json:"waiting,omitempty"`
+	AverageWait float64 `protobuf:"fixed64,8,opt,name=average_wait,json=averageWait" json:"average_wait,omitempty"`
+	AverageAge  float64 `protobuf:"fixed64,9,opt,name=average_age,json=averageAge" json:"average_age,omitempty"`
+	MaxWait     float64 `protobuf:"fixed64,10,opt,name=max_wait,json=maxWait" json:"max_wait,omitempty"`
+	Timeouts    int64   `protobuf:"varint,11,opt,name=timeouts" json:"timeouts,omitempty"`
+	Rejected    int64   `protobuf:"varint,12,opt,name=rejected" json:"rejected,omitempty"`
+}
+
+func (m *PoolStatistics) Reset()                    { *m = PoolStatistics{} }
+func (m *PoolStatistics) String() string            { return proto.CompactTextString(m) }
+func (*PoolStatistics) ProtoMessage()               {}
+func (*PoolStatistics) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{15} }
+
+func (m *PoolStatistics) GetNode() string {
+	if m != nil {
+		return m.Node
+	}
+	return ""
+}
+
+func (m *PoolStatistics) GetDatabase() string {
+	if m != nil {
+		return m.Database
+	}
+	return ""
+}
+
+func (m *PoolStatistics) GetUser() string {
+	if m != nil {
+		return m.User
+	}
+	return ""
+}
+
+func (m *PoolStatistics) GetTotal() int32 {
+	if m != nil {
+		return m.Total
+	}
+	return 0
+}
+
+func (m *PoolStatistics) GetInUse() int32 {
+	if m != nil {
+		return m.InUse
+	}
+	return 0
+}
+
+func (m *PoolStatistics) GetIdle() int32 {
+	if m != nil {
+		return m.Idle
+	}
+	return 0
+}
+
+func (m *PoolStatistics) GetWaiting() int32 {
+	if m != nil {
+		return m.Waiting
+	}
+	return 0
+}
+
+func (m *PoolStatistics) GetAverageWait() float64 {
+	if m != nil {
+		return m.AverageWait
+	}
+	return 0
+}
+
+func (m *PoolStatistics) GetAverageAge() float64 {
+	if m != nil {
+		return m.AverageAge
+	}
+	return 0
+}
+
+func (m *PoolStatistics) GetMaxWait() float64 {
+	if m != nil {
+		return m.MaxWait
+	}
+	return 0
+}
+
+func (m *PoolStatistics) GetTimeouts() int64 {
+	if m != nil {
+		return m.Timeouts
+	}
+	return 0
+}
+
+func (m *PoolStatistics) GetRejected() int64 {
+	if m != nil {
+		return m.Rejected
+	}
+	return 0
+}
+
+// ShowPoolsResponse contains the statistics of each pool.
+type ShowPoolsResponse struct {
+	Pools []*PoolStatistics `protobuf:"bytes,1,rep,name=pools" json:"pools,omitempty"`
+}
+
+func (m *ShowPoolsResponse) Reset()                    { *m = ShowPoolsResponse{} }
+func (m *ShowPoolsResponse) String() string            { return proto.CompactTextString(m) }
+func (*ShowPoolsResponse) ProtoMessage()               {}
+func (*ShowPoolsResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{16} }
+
+func (m *ShowPoolsResponse) GetPools() []*PoolStatistics {
+	if m != nil {
+		return m.Pools
+	}
+	return nil
+}
+
+// TopStatementsRequest requests the statistics of the statements with the
+// largest total execution time, at most limit of them if set.
+type TopStatementsRequest struct {
+	Limit int32 `protobuf:"varint,1,opt,name=limit" json:"limit,omitempty"`
+}
+
+func (m *TopStatementsRequest) Reset()                    { *m = TopStatementsRequest{} }
+func (m *TopStatementsRequest) String() string            { return proto.CompactTextString(m) }
+func (*TopStatementsRequest) ProtoMessage()               {}
+func (*TopStatementsRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{17} }
+
+func (m *TopStatementsRequest) GetLimit() int32 {
+	if m != nil {
+		return m.Limit
+	}
+	return 0
+}
+
+// StatementStatistics contains the statistics of a normalized query on a node.
+type StatementStatistics struct {
+	Query     string  `protobuf:"bytes,1,opt,name=query" json:"query,omitempty"`
+	Node      string  `protobuf:"bytes,2,opt,name=node" json:"node,omitempty"`
+	Calls     int64   `protobuf:"varint,3,opt,name=calls" json:"calls,omitempty"`
+	TotalTime float64 `protobuf:"fixed64,4,opt,name=total_time,json=totalTime" json:"total_time,omitempty"`
+	MeanTime  float64 `protobuf:"fixed64,5,opt,name=mean_time,json=meanTime" json:"mean_time,omitempty"`
+	MaxTime   float64 `protobuf:"fixed64,6,opt,name=max_time,json=maxTime" json:"max_time,omitempty"`
+}
+
+func (m *StatementStatistics) Reset()                    { *m = StatementStatistics{} }
+func (m *StatementStatistics) String() string            { return proto.CompactTextString(m) }
+func (*StatementStatistics) ProtoMessage()               {}
+func (*StatementStatistics) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{18} }
+
+func (m *StatementStatistics) GetQuery() string {
+	if m != nil {
+		return m.Query
+	}
+	return ""
+}
+
+func (m *StatementStatistics) GetNode() string {
+	if m != nil {
+		return m.Node
+	}
+	return ""
+}
+
+func (m *StatementStatistics) GetCalls() int64 {
+	if m != nil {
+		return m.Calls
+	}
+	return 0
+}
+
+func (m *StatementStatistics) GetTotalTime() float64 {
+	if m != nil {
+		return m.TotalTime
+	}
+	return 0
+}
+
+func (m *StatementStatistics) GetMeanTime() float64 {
+	if m != nil {
+		return m.MeanTime
+	}
+	return 0
+}
+
+func (m *StatementStatistics) GetMaxTime() float64 {
+	if m != nil {
+		return m.MaxTime
+	}
+	return 0
+}
+
+// TopStatementsResponse contains the statistics of the top statements.
+type TopStatementsResponse struct {
+	Statements []*StatementStatistics `protobuf:"bytes,1,rep,name=statements" json:"statements,omitempty"`
+}
+
+func (m *TopStatementsResponse) Reset()                    { *m = TopStatementsResponse{} }
+func (m *TopStatementsResponse) String() string            { return proto.CompactTextString(m) }
+func (*TopStatementsResponse) ProtoMessage()               {}
+func (*TopStatementsResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{19} }
+
+func (m *TopStatementsResponse) GetStatements() []*StatementStatistics {
+	if m != nil {
+		return m.Statements
+	}
+	return nil
+}
+
+// ListSessionsRequest requests a description of each client session.
+type ListSessionsRequest struct {
+}
+
+func (m *ListSessionsRequest) Reset()                    { *m = ListSessionsRequest{} }
+func (m *ListSessionsRequest) String() string            { return proto.CompactTextString(m) }
+func (*ListSessionsRequest) ProtoMessage()               {}
+func (*ListSessionsRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{20} }
+
+// SessionInfo describes a client session. The times are in seconds since the
+// Unix epoch, and the request time is zero until the client sends a request.
+type SessionInfo struct {
+	Id          int32  `protobuf:"varint,1,opt,name=id" json:"id,omitempty"`
+	ClientAddr  string `protobuf:"bytes,2,opt,name=client_addr,json=clientAddr" json:"client_addr,omitempty"`
+	User        string `protobuf:"bytes,3,opt,name=user" json:"user,omitempty"`
+	Database    string `protobuf:"bytes,4,opt,name=database" json:"database,omitempty"`
+	State       string `protobuf:"bytes,5,opt,name=state" json:"state,omitempty"`
+	Backend     string `protobuf:"bytes,6,opt,name=backend" json:"backend,omitempty"`
+	ConnectTime int64  `protobuf:"varint,7,opt,name=connect_time,json=connectTime" json:"connect_time,omitempty"`
+	RequestTime int64  `protobuf:"varint,8,opt,name=request_time,json=requestTime" json:"request_time,omitempty"`
+	Received    int64  `protobuf:"varint,9,opt,name=received" json:"received,omitempty"`
+	Sent        int64  `protobuf:"varint,10,opt,name=sent" json:"sent,omitempty"`
+}
+
+func (m *SessionInfo) Reset()                    { *m = SessionInfo{} }
+func (m *SessionInfo) String() string            { return proto.CompactTextString(m) }
+func (*SessionInfo) ProtoMessage()               {}
+func (*SessionInfo) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{21} }
+
+func (m *SessionInfo) GetId() int32 {
+	if m != nil {
+		return m.Id
+	}
+	return 0
+}
+
+func (m *SessionInfo) GetClientAddr() string {
+	if m != nil {
+		return m.ClientAddr
+	}
+	return ""
+}
+
+func (m *SessionInfo) GetUser() string {
+	if m != nil {
+		return m.User
+	}
+	return ""
+}
+
+func (m *SessionInfo) GetDatabase() string {
+	if m != nil {
+		return m.Database
+	}
+	return ""
+}
+
+func (m *SessionInfo) GetState() string {
+	if m != nil {
+		return m.State
+	}
+	return ""
+}
+
+func (m *SessionInfo) GetBackend() string {
+	if m != nil {
+		return m.Backend
+	}
+	return ""
+}
 
-// PoolResponse contains a list of pools.
-type PoolResponse struct {
-	Pools []string `protobuf:"bytes,1,rep,name=pools" json:"pools,omitempty"`
+func (m *SessionInfo) GetConnectTime() int64 {
+	if m != nil {
+		return m.ConnectTime
+	}
+	return 0
 }
 
-func (m *PoolResponse) Reset()                    { *m = PoolResponse{} }
-func (m *PoolResponse) String() string            { return proto.CompactTextString(m) }
-func (*PoolResponse) ProtoMessage()               {}
-func (*PoolResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
+func (m *SessionInfo) GetRequestTime() int64 {
+	if m != nil {
+		return m.RequestTime
+	}
+	return 0
+}
 
-func (m *PoolResponse) GetPools() []string {
+func (m *SessionInfo) GetReceived() int64 {
 	if m != nil {
-		return m.Pools
+		return m.Received
+	}
+	return 0
+}
+
+func (m *SessionInfo) GetSent() int64 {
+	if m != nil {
+		return m.Sent
+	}
+	return 0
+}
+
+// ListSessionsResponse contains a description of each client session.
+type ListSessionsResponse struct {
+	Sessions []*SessionInfo `protobuf:"bytes,1,rep,name=sessions" json:"sessions,omitempty"`
+}
+
+func (m *ListSessionsResponse) Reset()                    { *m = ListSessionsResponse{} }
+func (m *ListSessionsResponse) String() string            { return proto.CompactTextString(m) }
+func (*ListSessionsResponse) ProtoMessage()               {}
+func (*ListSessionsResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{22} }
+
+func (m *ListSessionsResponse) GetSessions() []*SessionInfo {
+	if m != nil {
+		return m.Sessions
 	}
 	return nil
 }
 
+// TerminateSessionRequest requests the client of a session to be disconnected.
+type TerminateSessionRequest struct {
+	SessionId int32 `protobuf:"varint,1,opt,name=session_id,json=sessionId" json:"session_id,omitempty"`
+}
+
+func (m *TerminateSessionRequest) Reset()                    { *m = TerminateSessionRequest{} }
+func (m *TerminateSessionRequest) String() string            { return proto.CompactTextString(m) }
+func (*TerminateSessionRequest) ProtoMessage()               {}
+func (*TerminateSessionRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{23} }
+
+func (m *TerminateSessionRequest) GetSessionId() int32 {
+	if m != nil {
+		return m.SessionId
+	}
+	return 0
+}
+
+// TerminateSessionResponse contains the result of terminating a session.
+type TerminateSessionResponse struct {
+	Success bool `protobuf:"varint,1,opt,name=success" json:"success,omitempty"`
+}
+
+func (m *TerminateSessionResponse) Reset()                    { *m = TerminateSessionResponse{} }
+func (m *TerminateSessionResponse) String() string            { return proto.CompactTextString(m) }
+func (*TerminateSessionResponse) ProtoMessage()               {}
+func (*TerminateSessionResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{24} }
+
+func (m *TerminateSessionResponse) GetSuccess() bool {
+	if m != nil {
+		return m.Success
+	}
+	return false
+}
+
+type CacheStatisticsRequest struct {
+}
+
+func (m *CacheStatisticsRequest) Reset()                    { *m = CacheStatisticsRequest{} }
+func (m *CacheStatisticsRequest) String() string            { return proto.CompactTextString(m) }
+func (*CacheStatisticsRequest) ProtoMessage()               {}
+func (*CacheStatisticsRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{25} }
+
+// CacheStatisticsResponse contains the statistics of the query result cache.
+type CacheStatisticsResponse struct {
+	Entries     int32 `protobuf:"varint,1,opt,name=entries" json:"entries,omitempty"`
+	Size        int64 `protobuf:"varint,2,opt,name=size" json:"size,omitempty"`
+	Hits        int64 `protobuf:"varint,3,opt,name=hits" json:"hits,omitempty"`
+	Misses      int64 `protobuf:"varint,4,opt,name=misses" json:"misses,omitempty"`
+	Evictions   int64 `protobuf:"varint,5,opt,name=evictions" json:"evictions,omitempty"`
+	Expirations int64 `protobuf:"varint,6,opt,name=expirations" json:"expirations,omitempty"`
+}
+
+func (m *CacheStatisticsResponse) Reset()                    { *m = CacheStatisticsResponse{} }
+func (m *CacheStatisticsResponse) String() string            { return proto.CompactTextString(m) }
+func (*CacheStatisticsResponse) ProtoMessage()               {}
+func (*CacheStatisticsResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{26} }
+
+func (m *CacheStatisticsResponse) GetEntries() int32 {
+	if m != nil {
+		return m.Entries
+	}
+	return 0
+}
+
+func (m *CacheStatisticsResponse) GetSize() int64 {
+	if m != nil {
+		return m.Size
+	}
+	return 0
+}
+
+func (m *CacheStatisticsResponse) GetHits() int64 {
+	if m != nil {
+		return m.Hits
+	}
+	return 0
+}
+
+func (m *CacheStatisticsResponse) GetMisses() int64 {
+	if m != nil {
+		return m.Misses
+	}
+	return 0
+}
+
+func (m *CacheStatisticsResponse) GetEvictions() int64 {
+	if m != nil {
+		return m.Evictions
+	}
+	return 0
+}
+
+func (m *CacheStatisticsResponse) GetExpirations() int64 {
+	if m != nil {
+		return m.Expirations
+	}
+	return 0
+}
+
+// ReadOnlyRequest requests the proxy to enter or leave read-only mode.
+type ReadOnlyRequest struct {
+	Enable bool `protobuf:"varint,1,opt,name=enable" json:"enable,omitempty"`
+}
+
+func (m *ReadOnlyRequest) Reset()                    { *m = ReadOnlyRequest{} }
+func (m *ReadOnlyRequest) String() string            { return proto.CompactTextString(m) }
+func (*ReadOnlyRequest) ProtoMessage()               {}
+func (*ReadOnlyRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{27} }
+
+func (m *ReadOnlyRequest) GetEnable() bool {
+	if m != nil {
+		return m.Enable
+	}
+	return false
+}
+
+// ReadOnlyResponse contains whether the proxy is in read-only mode.
+type ReadOnlyResponse struct {
+	ReadOnly bool `protobuf:"varint,1,opt,name=read_only,json=readOnly" json:"read_only,omitempty"`
+}
+
+func (m *ReadOnlyResponse) Reset()                    { *m = ReadOnlyResponse{} }
+func (m *ReadOnlyResponse) String() string            { return proto.CompactTextString(m) }
+func (*ReadOnlyResponse) ProtoMessage()               {}
+func (*ReadOnlyResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{28} }
+
+func (m *ReadOnlyResponse) GetReadOnly() bool {
+	if m != nil {
+		return m.ReadOnly
+	}
+	return false
+}
+
+// PauseRequest requests the proxy to hold client traffic.
+type PauseRequest struct {
+	Timeout int32 `protobuf:"varint,1,opt,name=timeout" json:"timeout,omitempty"`
+}
+
+func (m *PauseRequest) Reset()                    { *m = PauseRequest{} }
+func (m *PauseRequest) String() string            { return proto.CompactTextString(m) }
+func (*PauseRequest) ProtoMessage()               {}
+func (*PauseRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{29} }
+
+func (m *PauseRequest) GetTimeout() int32 {
+	if m != nil {
+		return m.Timeout
+	}
+	return 0
+}
+
+// PauseResponse contains the number of clients that still hold a backend.
+type PauseResponse struct {
+	Active int32 `protobuf:"varint,1,opt,name=active" json:"active,omitempty"`
+}
+
+func (m *PauseResponse) Reset()                    { *m = PauseResponse{} }
+func (m *PauseResponse) String() string            { return proto.CompactTextString(m) }
+func (*PauseResponse) ProtoMessage()               {}
+func (*PauseResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{30} }
+
+func (m *PauseResponse) GetActive() int32 {
+	if m != nil {
+		return m.Active
+	}
+	return 0
+}
+
+// ResumeRequest requests the proxy to release held client traffic.
+type ResumeRequest struct {
+}
+
+func (m *ResumeRequest) Reset()                    { *m = ResumeRequest{} }
+func (m *ResumeRequest) String() string            { return proto.CompactTextString(m) }
+func (*ResumeRequest) ProtoMessage()               {}
+func (*ResumeRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{31} }
+
+// ResumeResponse contains the result of resuming.
+type ResumeResponse struct {
+	Success bool `protobuf:"varint,1,opt,name=success" json:"success,omitempty"`
+}
+
+func (m *ResumeResponse) Reset()                    { *m = ResumeResponse{} }
+func (m *ResumeResponse) String() string            { return proto.CompactTextString(m) }
+func (*ResumeResponse) ProtoMessage()               {}
+func (*ResumeResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{32} }
+
+func (m *ResumeResponse) GetSuccess() bool {
+	if m != nil {
+		return m.Success
+	}
+	return false
+}
+
 type HealthRequest struct {
 }
 
 func (m *HealthRequest) Reset()                    { *m = HealthRequest{} }
 func (m *HealthRequest) String() string            { return proto.CompactTextString(m) }
 func (*HealthRequest) ProtoMessage()               {}
-func (*HealthRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
+func (*HealthRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{33} }
 
 type HealthResponse struct {
 	Health map[string]bool `protobuf:"bytes,1,rep,name=health" json:"health,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
@@ -112,7 +919,7 @@ type HealthResponse struct {
 func (m *HealthResponse) Reset()                    { *m = HealthResponse{} }
 func (m *HealthResponse) String() string            { return proto.CompactTextString(m) }
 func (*HealthResponse) ProtoMessage()               {}
-func (*HealthResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
+func (*HealthResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{34} }
 
 func (m *HealthResponse) GetHealth() map[string]bool {
 	if m != nil {
@@ -127,16 +934,23 @@ type StatisticsRequest struct {
 func (m *StatisticsRequest) Reset()                    { *m = StatisticsRequest{} }
 func (m *StatisticsRequest) String() string            { return proto.CompactTextString(m) }
 func (*StatisticsRequest) ProtoMessage()               {}
-func (*StatisticsRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
+func (*StatisticsRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{35} }
 
+// StatisticsResponse contains the number of queries sent to each node. If the
+// statistics are saved to a file, the counts and uptime include the earlier
+// runs of the proxy since the first start time, in seconds since the Unix
+// epoch.
 type StatisticsResponse struct {
 	Queries map[string]int32 `protobuf:"bytes,1,rep,name=queries" json:"queries,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	Since   int64            `protobuf:"varint,2,opt,name=since" json:"since,omitempty"`
+	Uptime  int64            `protobuf:"varint,3,opt,name=uptime" json:"uptime,omitempty"`
+	Starts  int32            `protobuf:"varint,4,opt,name=starts" json:"starts,omitempty"`
 }
 
 func (m *StatisticsResponse) Reset()                    { *m = StatisticsResponse{} }
 func (m *StatisticsResponse) String() string            { return proto.CompactTextString(m) }
 func (*StatisticsResponse) ProtoMessage()               {}
-func (*StatisticsResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
+func (*StatisticsResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{36} }
 
 func (m *StatisticsResponse) GetQueries() map[string]int32 {
 	if m != nil {
@@ -145,6 +959,27 @@ func (m *StatisticsResponse) GetQueries() map[string]int32 {
 	return nil
 }
 
+func (m *StatisticsResponse) GetSince() int64 {
+	if m != nil {
+		return m.Since
+	}
+	return 0
+}
+
+func (m *StatisticsResponse) GetUptime() int64 {
+	if m != nil {
+		return m.Uptime
+	}
+	return 0
+}
+
+func (m *StatisticsResponse) GetStarts() int32 {
+	if m != nil {
+		return m.Starts
+	}
+	return 0
+}
+
 // ShutdownRequest requests the server to shutdown.
 type ShutdownRequest struct {
 }
@@ -152,7 +987,7 @@ type ShutdownRequest struct {
 func (m *ShutdownRequest) Reset()                    { *m = ShutdownRequest{} }
 func (m *ShutdownRequest) String() string            { return proto.CompactTextString(m) }
 func (*ShutdownRequest) ProtoMessage()               {}
-func (*ShutdownRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8} }
+func (*ShutdownRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{37} }
 
 // ShutdownResponse contains the the state of the proxy.
 type ShutdownResponse struct {
@@ -162,7 +997,7 @@ type ShutdownResponse struct {
 func (m *ShutdownResponse) Reset()                    { *m = ShutdownResponse{} }
 func (m *ShutdownResponse) String() string            { return proto.CompactTextString(m) }
 func (*ShutdownResponse) ProtoMessage()               {}
-func (*ShutdownResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{9} }
+func (*ShutdownResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{38} }
 
 func (m *ShutdownResponse) GetSuccess() bool {
 	if m != nil {
@@ -171,13 +1006,73 @@ func (m *ShutdownResponse) GetSuccess() bool {
 	return false
 }
 
+// ReloadRequest requests the server to reload its configuration.
+type ReloadRequest struct {
+}
+
+func (m *ReloadRequest) Reset()                    { *m = ReloadRequest{} }
+func (m *ReloadRequest) String() string            { return proto.CompactTextString(m) }
+func (*ReloadRequest) ProtoMessage()               {}
+func (*ReloadRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{39} }
+
+// ReloadResponse contains the result of the reload.
+type ReloadResponse struct {
+	Success bool `protobuf:"varint,1,opt,name=success" json:"success,omitempty"`
+}
+
+func (m *ReloadResponse) Reset()                    { *m = ReloadResponse{} }
+func (m *ReloadResponse) String() string            { return proto.CompactTextString(m) }
+func (*ReloadResponse) ProtoMessage()               {}
+func (*ReloadResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{40} }
+
+func (m *ReloadResponse) GetSuccess() bool {
+	if m != nil {
+		return m.Success
+	}
+	return false
+}
+
+// ConfigRequest requests the configuration that the proxy is running with.
+type ConfigRequest struct {
+	Format string `protobuf:"bytes,1,opt,name=format" json:"format,omitempty"`
+}
+
+func (m *ConfigRequest) Reset()                    { *m = ConfigRequest{} }
+func (m *ConfigRequest) String() string            { return proto.CompactTextString(m) }
+func (*ConfigRequest) ProtoMessage()               {}
+func (*ConfigRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{41} }
+
+func (m *ConfigRequest) GetFormat() string {
+	if m != nil {
+		return m.Format
+	}
+	return ""
+}
+
+// ConfigResponse contains the configuration, with secrets masked.
+type ConfigResponse struct {
+	Config string `protobuf:"bytes,1,opt,name=config" json:"config,omitempty"`
+}
+
+func (m *ConfigResponse) Reset()                    { *m = ConfigResponse{} }
+func (m *ConfigResponse) String() string            { return proto.CompactTextString(m) }
+func (*ConfigResponse) ProtoMessage()               {}
+func (*ConfigResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{42} }
+
+func (m *ConfigResponse) GetConfig() string {
+	if m != nil {
+		return m.Config
+	}
+	return ""
+}
+
 type VersionRequest struct {
 }
 
 func (m *VersionRequest) Reset()                    { *m = VersionRequest{} }
 func (m *VersionRequest) String() string            { return proto.CompactTextString(m) }
 func (*VersionRequest) ProtoMessage()               {}
-func (*VersionRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{10} }
+func (*VersionRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{43} }
 
 type VersionResponse struct {
 	Version string `protobuf:"bytes,1,opt,name=version" json:"version,omitempty"`
@@ -186,7 +1081,7 @@ type VersionResponse struct {
 func (m *VersionResponse) Reset()                    { *m = VersionResponse{} }
 func (m *VersionResponse) String() string            { return proto.CompactTextString(m) }
 func (*VersionResponse) ProtoMessage()               {}
-func (*VersionResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{11} }
+func (*VersionResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{44} }
 
 func (m *VersionResponse) GetVersion() string {
 	if m != nil {
@@ -198,14 +1093,47 @@ func (m *VersionResponse) GetVersion() string {
 func init() {
 	proto.RegisterType((*NodeRequest)(nil), "crunchyproxy.server.serverpb.NodeRequest")
 	proto.RegisterType((*NodeResponse)(nil), "crunchyproxy.server.serverpb.NodeResponse")
+	proto.RegisterType((*AddNodeRequest)(nil), "crunchyproxy.server.serverpb.AddNodeRequest")
+	proto.RegisterType((*AddNodeResponse)(nil), "crunchyproxy.server.serverpb.AddNodeResponse")
+	proto.RegisterType((*RemoveNodeRequest)(nil), "crunchyproxy.server.serverpb.RemoveNodeRequest")
+	proto.RegisterType((*RemoveNodeResponse)(nil), "crunchyproxy.server.serverpb.RemoveNodeResponse")
+	proto.RegisterType((*EnableNodeRequest)(nil), "crunchyproxy.server.serverpb.EnableNodeRequest")
+	proto.RegisterType((*EnableNodeResponse)(nil), "crunchyproxy.server.serverpb.EnableNodeResponse")
+	proto.RegisterType((*DisableNodeRequest)(nil), "crunchyproxy.server.serverpb.DisableNodeRequest")
+	proto.RegisterType((*DisableNodeResponse)(nil), "crunchyproxy.server.serverpb.DisableNodeResponse")
+	proto.RegisterType((*DrainNodeRequest)(nil), "crunchyproxy.server.serverpb.DrainNodeRequest")
+	proto.RegisterType((*DrainNodeResponse)(nil), "crunchyproxy.server.serverpb.DrainNodeResponse")
 	proto.RegisterType((*PoolRequest)(nil), "crunchyproxy.server.serverpb.PoolRequest")
 	proto.RegisterType((*PoolResponse)(nil), "crunchyproxy.server.serverpb.PoolResponse")
+	proto.RegisterType((*ShowPoolsRequest)(nil), "crunchyproxy.server.serverpb.ShowPoolsRequest")
+	proto.RegisterType((*PoolStatistics)(nil), "crunchyproxy.server.serverpb.PoolStatistics")
+	proto.RegisterType((*ShowPoolsResponse)(nil), "crunchyproxy.server.serverpb.ShowPoolsResponse")
+	proto.RegisterType((*TopStatementsRequest)(nil), "crunchyproxy.server.serverpb.TopStatementsRequest")
+	proto.RegisterType((*StatementStatistics)(nil), "crunchyproxy.server.serverpb.StatementStatistics")
+	proto.RegisterType((*TopStatementsResponse)(nil), "crunchyproxy.server.serverpb.TopStatementsResponse")
+	proto.RegisterType((*ListSessionsRequest)(nil), "crunchyproxy.server.serverpb.ListSessionsRequest")
+	proto.RegisterType((*SessionInfo)(nil), "crunchyproxy.server.serverpb.SessionInfo")
+	proto.RegisterType((*ListSessionsResponse)(nil), "crunchyproxy.server.serverpb.ListSessionsResponse")
+	proto.RegisterType((*TerminateSessionRequest)(nil), "crunchyproxy.server.serverpb.TerminateSessionRequest")
+	proto.RegisterType((*TerminateSessionResponse)(nil), "crunchyproxy.server.serverpb.TerminateSessionResponse")
+	proto.RegisterType((*CacheStatisticsRequest)(nil), "crunchyproxy.server.serverpb.CacheStatisticsRequest")
+	proto.RegisterType((*CacheStatisticsResponse)(nil), "crunchyproxy.server.serverpb.CacheStatisticsResponse")
+	proto.RegisterType((*ReadOnlyRequest)(nil), "crunchyproxy.server.serverpb.ReadOnlyRequest")
+	proto.RegisterType((*ReadOnlyResponse)(nil), "crunchyproxy.server.serverpb.ReadOnlyResponse")
+	proto.RegisterType((*PauseRequest)(nil), "crunchyproxy.server.serverpb.PauseRequest")
+	proto.RegisterType((*PauseResponse)(nil), "crunchyproxy.server.serverpb.PauseResponse")
+	proto.RegisterType((*ResumeRequest)(nil), "crunchyproxy.server.serverpb.ResumeRequest")
+	proto.RegisterType((*ResumeResponse)(nil), "crunchyproxy.server.serverpb.ResumeResponse")
 	proto.RegisterType((*HealthRequest)(nil), "crunchyproxy.server.serverpb.HealthRequest")
 	proto.RegisterType((*HealthResponse)(nil), "crunchyproxy.server.serverpb.HealthResponse")
 	proto.RegisterType((*StatisticsRequest)(nil), "crunchyproxy.server.serverpb.StatisticsRequest")
 	proto.RegisterType((*StatisticsResponse)(nil), "crunchyproxy.server.serverpb.StatisticsResponse")
 	proto.RegisterType((*ShutdownRequest)(nil), "crunchyproxy.server.serverpb.ShutdownRequest")
 	proto.RegisterType((*ShutdownResponse)(nil), "crunchyproxy.server.serverpb.ShutdownResponse")
+	proto.RegisterType((*ReloadRequest)(nil), "crunchyproxy.server.serverpb.ReloadRequest")
+	proto.RegisterType((*ReloadResponse)(nil), "crunchyproxy.server.serverpb.ReloadResponse")
+	proto.RegisterType((*ConfigRequest)(nil), "crunchyproxy.server.serverpb.ConfigRequest")
+	proto.RegisterType((*ConfigResponse)(nil), "crunchyproxy.server.serverpb.ConfigResponse")
 	proto.RegisterType((*VersionRequest)(nil), "crunchyproxy.server.serverpb.VersionRequest")
 	proto.RegisterType((*VersionResponse)(nil), "crunchyproxy.server.serverpb.VersionResponse")
 }
@@ -222,10 +1150,25 @@ const _ = grpc.SupportPackageIsVersion4
 
 type AdminClient interface {
 	Nodes(ctx context.Context, in *NodeRequest, opts ...grpc.CallOption) (*NodeResponse, error)
+	AddNode(ctx context.Context, in *AddNodeRequest, opts ...grpc.CallOption) (*AddNodeResponse, error)
+	RemoveNode(ctx context.Context, in *RemoveNodeRequest, opts ...grpc.CallOption) (*RemoveNodeResponse, error)
+	EnableNode(ctx context.Context, in *EnableNodeRequest, opts ...grpc.CallOption) (*EnableNodeResponse, error)
+	DisableNode(ctx context.Context, in *DisableNodeRequest, opts ...grpc.CallOption) (*DisableNodeResponse, error)
+	DrainNode(ctx context.Context, in *DrainNodeRequest, opts ...grpc.CallOption) (Admin_DrainNodeClient, error)
 	Pools(ctx context.Context, in *PoolRequest, opts ...grpc.CallOption) (*PoolResponse, error)
+	ShowPools(ctx context.Context, in *ShowPoolsRequest, opts ...grpc.CallOption) (*ShowPoolsResponse, error)
+	TopStatements(ctx context.Context, in *TopStatementsRequest, opts ...grpc.CallOption) (*TopStatementsResponse, error)
+	ListSessions(ctx context.Context, in *ListSessionsRequest, opts ...grpc.CallOption) (*ListSessionsResponse, error)
+	TerminateSession(ctx context.Context, in *TerminateSessionRequest, opts ...grpc.CallOption) (*TerminateSessionResponse, error)
+	CacheStatistics(ctx context.Context, in *CacheStatisticsRequest, opts ...grpc.CallOption) (*CacheStatisticsResponse, error)
+	SetReadOnly(ctx context.Context, in *ReadOnlyRequest, opts ...grpc.CallOption) (*ReadOnlyResponse, error)
+	Pause(ctx context.Context, in *PauseRequest, opts ...grpc.CallOption) (*PauseResponse, error)
+	Resume(ctx context.Context, in *ResumeRequest, opts ...grpc.CallOption) (*ResumeResponse, error)
 	Health(ctx context.Context, in *HealthRequest, opts ...grpc.CallOption) (*HealthResponse, error)
 	Statistics(ctx context.Context, in *StatisticsRequest, opts ...grpc.CallOption) (*StatisticsResponse, error)
 	Shutdown(ctx context.Context, in *ShutdownRequest, opts ...grpc.CallOption) (Admin_ShutdownClient, error)
+	Reload(ctx context.Context, in *ReloadRequest, opts ...grpc.CallOption) (*ReloadResponse, error)
+	GetConfig(ctx context.Context, in *ConfigRequest, opts ...grpc.CallOption) (*ConfigResponse, error)
 	Version(ctx context.Context, in *VersionRequest, opts ...grpc.CallOption) (*VersionResponse, error)
 }
 
@@ -233,22 +1176,162 @@ type adminClient struct {
 	cc *grpc.ClientConn
 }
 
-func NewAdminClient(cc *grpc.ClientConn) AdminClient {
-	return &adminClient{cc}
+func NewAdminClient(cc *grpc.ClientConn) AdminClient {
+	return &adminClient{cc}
+}
+
+func (c *adminClient) Nodes(ctx context.Context, in *NodeRequest, opts ...grpc.CallOption) (*NodeResponse, error) {
+	out := new(NodeResponse)
+	err := grpc.Invoke(ctx, "/crunchyproxy.server.serverpb.Admin/Nodes", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) AddNode(ctx context.Context, in *AddNodeRequest, opts ...grpc.CallOption) (*AddNodeResponse, error) {
+	out := new(AddNodeResponse)
+	err := grpc.Invoke(ctx, "/crunchyproxy.server.serverpb.Admin/AddNode", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) RemoveNode(ctx context.Context, in *RemoveNodeRequest, opts ...grpc.CallOption) (*RemoveNodeResponse, error) {
+	out := new(RemoveNodeResponse)
+	err := grpc.Invoke(ctx, "/crunchyproxy.server.serverpb.Admin/RemoveNode", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) EnableNode(ctx context.Context, in *EnableNodeRequest, opts ...grpc.CallOption) (*EnableNodeResponse, error) {
+	out := new(EnableNodeResponse)
+	err := grpc.Invoke(ctx, "/crunchyproxy.server.serverpb.Admin/EnableNode", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) DisableNode(ctx context.Context, in *DisableNodeRequest, opts ...grpc.CallOption) (*DisableNodeResponse, error) {
+	out := new(DisableNodeResponse)
+	err := grpc.Invoke(ctx, "/crunchyproxy.server.serverpb.Admin/DisableNode", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) DrainNode(ctx context.Context, in *DrainNodeRequest, opts ...grpc.CallOption) (Admin_DrainNodeClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_Admin_serviceDesc.Streams[0], c.cc, "/crunchyproxy.server.serverpb.Admin/DrainNode", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &adminDrainNodeClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Admin_DrainNodeClient interface {
+	Recv() (*DrainNodeResponse, error)
+	grpc.ClientStream
+}
+
+type adminDrainNodeClient struct {
+	grpc.ClientStream
+}
+
+func (x *adminDrainNodeClient) Recv() (*DrainNodeResponse, error) {
+	m := new(DrainNodeResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *adminClient) Pools(ctx context.Context, in *PoolRequest, opts ...grpc.CallOption) (*PoolResponse, error) {
+	out := new(PoolResponse)
+	err := grpc.Invoke(ctx, "/crunchyproxy.server.serverpb.Admin/Pools", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) ShowPools(ctx context.Context, in *ShowPoolsRequest, opts ...grpc.CallOption) (*ShowPoolsResponse, error) {
+	out := new(ShowPoolsResponse)
+	err := grpc.Invoke(ctx, "/crunchyproxy.server.serverpb.Admin/ShowPools", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) TopStatements(ctx context.Context, in *TopStatementsRequest, opts ...grpc.CallOption) (*TopStatementsResponse, error) {
+	out := new(TopStatementsResponse)
+	err := grpc.Invoke(ctx, "/crunchyproxy.server.serverpb.Admin/TopStatements", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) ListSessions(ctx context.Context, in *ListSessionsRequest, opts ...grpc.CallOption) (*ListSessionsResponse, error) {
+	out := new(ListSessionsResponse)
+	err := grpc.Invoke(ctx, "/crunchyproxy.server.serverpb.Admin/ListSessions", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) TerminateSession(ctx context.Context, in *TerminateSessionRequest, opts ...grpc.CallOption) (*TerminateSessionResponse, error) {
+	out := new(TerminateSessionResponse)
+	err := grpc.Invoke(ctx, "/crunchyproxy.server.serverpb.Admin/TerminateSession", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) CacheStatistics(ctx context.Context, in *CacheStatisticsRequest, opts ...grpc.CallOption) (*CacheStatisticsResponse, error) {
+	out := new(CacheStatisticsResponse)
+	err := grpc.Invoke(ctx, "/crunchyproxy.server.serverpb.Admin/CacheStatistics", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) SetReadOnly(ctx context.Context, in *ReadOnlyRequest, opts ...grpc.CallOption) (*ReadOnlyResponse, error) {
+	out := new(ReadOnlyResponse)
+	err := grpc.Invoke(ctx, "/crunchyproxy.server.serverpb.Admin/SetReadOnly", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
 }
 
-func (c *adminClient) Nodes(ctx context.Context, in *NodeRequest, opts ...grpc.CallOption) (*NodeResponse, error) {
-	out := new(NodeResponse)
-	err := grpc.Invoke(ctx, "/crunchyproxy.server.serverpb.Admin/Nodes", in, out, c.cc, opts...)
+func (c *adminClient) Pause(ctx context.Context, in *PauseRequest, opts ...grpc.CallOption) (*PauseResponse, error) {
+	out := new(PauseResponse)
+	err := grpc.Invoke(ctx, "/crunchyproxy.server.serverpb.Admin/Pause", in, out, c.cc, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func (c *adminClient) Pools(ctx context.Context, in *PoolRequest, opts ...grpc.CallOption) (*PoolResponse, error) {
-	out := new(PoolResponse)
-	err := grpc.Invoke(ctx, "/crunchyproxy.server.serverpb.Admin/Pools", in, out, c.cc, opts...)
+func (c *adminClient) Resume(ctx context.Context, in *ResumeRequest, opts ...grpc.CallOption) (*ResumeResponse, error) {
+	out := new(ResumeResponse)
+	err := grpc.Invoke(ctx, "/crunchyproxy.server.serverpb.Admin/Resume", in, out, c.cc, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -274,7 +1357,7 @@ func (c *adminClient) Statistics(ctx context.Context, in *StatisticsRequest, opt
 }
 
 func (c *adminClient) Shutdown(ctx context.Context, in *ShutdownRequest, opts ...grpc.CallOption) (Admin_ShutdownClient, error) {
-	stream, err := grpc.NewClientStream(ctx, &_Admin_serviceDesc.Streams[0], c.cc, "/crunchyproxy.server.serverpb.Admin/Shutdown", opts...)
+	stream, err := grpc.NewClientStream(ctx, &_Admin_serviceDesc.Streams[1], c.cc, "/crunchyproxy.server.serverpb.Admin/Shutdown", opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -305,6 +1388,24 @@ func (x *adminShutdownClient) Recv() (*ShutdownResponse, error) {
 	return m, nil
 }
 
+func (c *adminClient) Reload(ctx context.Context, in *ReloadRequest, opts ...grpc.CallOption) (*ReloadResponse, error) {
+	out := new(ReloadResponse)
+	err := grpc.Invoke(ctx, "/crunchyproxy.server.serverpb.Admin/Reload", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) GetConfig(ctx context.Context, in *ConfigRequest, opts ...grpc.CallOption) (*ConfigResponse, error) {
+	out := new(ConfigResponse)
+	err := grpc.Invoke(ctx, "/crunchyproxy.server.serverpb.Admin/GetConfig", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *adminClient) Version(ctx context.Context, in *VersionRequest, opts ...grpc.CallOption) (*VersionResponse, error) {
 	out := new(VersionResponse)
 	err := grpc.Invoke(ctx, "/crunchyproxy.server.serverpb.Admin/Version", in, out, c.cc, opts...)
@@ -318,10 +1419,25 @@ func (c *adminClient) Version(ctx context.Context, in *VersionRequest, opts ...g
 
 type AdminServer interface {
 	Nodes(context.Context, *NodeRequest) (*NodeResponse, error)
+	AddNode(context.Context, *AddNodeRequest) (*AddNodeResponse, error)
+	RemoveNode(context.Context, *RemoveNodeRequest) (*RemoveNodeResponse, error)
+	EnableNode(context.Context, *EnableNodeRequest) (*EnableNodeResponse, error)
+	DisableNode(context.Context, *DisableNodeRequest) (*DisableNodeResponse, error)
+	DrainNode(*DrainNodeRequest, Admin_DrainNodeServer) error
 	Pools(context.Context, *PoolRequest) (*PoolResponse, error)
+	ShowPools(context.Context, *ShowPoolsRequest) (*ShowPoolsResponse, error)
+	TopStatements(context.Context, *TopStatementsRequest) (*TopStatementsResponse, error)
+	ListSessions(context.Context, *ListSessionsRequest) (*ListSessionsResponse, error)
+	TerminateSession(context.Context, *TerminateSessionRequest) (*TerminateSessionResponse, error)
+	CacheStatistics(context.Context, *CacheStatisticsRequest) (*CacheStatisticsResponse, error)
+	SetReadOnly(context.Context, *ReadOnlyRequest) (*ReadOnlyResponse, error)
+	Pause(context.Context, *PauseRequest) (*PauseResponse, error)
+	Resume(context.Context, *ResumeRequest) (*ResumeResponse, error)
 	Health(context.Context, *HealthRequest) (*HealthResponse, error)
 	Statistics(context.Context, *StatisticsRequest) (*StatisticsResponse, error)
 	Shutdown(*ShutdownRequest, Admin_ShutdownServer) error
+	Reload(context.Context, *ReloadRequest) (*ReloadResponse, error)
+	GetConfig(context.Context, *ConfigRequest) (*ConfigResponse, error)
 	Version(context.Context, *VersionRequest) (*VersionResponse, error)
 }
 
@@ -347,6 +1463,99 @@ func _Admin_Nodes_Handler(srv interface{}, ctx context.Context, dec func(interfa
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Admin_AddNode_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddNodeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).AddNode(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/crunchyproxy.server.serverpb.Admin/AddNode",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).AddNode(ctx, req.(*AddNodeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_RemoveNode_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RemoveNodeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).RemoveNode(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/crunchyproxy.server.serverpb.Admin/RemoveNode",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).RemoveNode(ctx, req.(*RemoveNodeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_EnableNode_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EnableNodeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).EnableNode(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/crunchyproxy.server.serverpb.Admin/EnableNode",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).EnableNode(ctx, req.(*EnableNodeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_DisableNode_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DisableNodeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).DisableNode(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/crunchyproxy.server.serverpb.Admin/DisableNode",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).DisableNode(ctx, req.(*DisableNodeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_DrainNode_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(DrainNodeRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(AdminServer).DrainNode(m, &adminDrainNodeServer{stream})
+}
+
+type Admin_DrainNodeServer interface {
+	Send(*DrainNodeResponse) error
+	grpc.ServerStream
+}
+
+type adminDrainNodeServer struct {
+	grpc.ServerStream
+}
+
+func (x *adminDrainNodeServer) Send(m *DrainNodeResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 func _Admin_Pools_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(PoolRequest)
 	if err := dec(in); err != nil {
@@ -365,6 +1574,150 @@ func _Admin_Pools_Handler(srv interface{}, ctx context.Context, dec func(interfa
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Admin_ShowPools_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ShowPoolsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).ShowPools(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/crunchyproxy.server.serverpb.Admin/ShowPools",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).ShowPools(ctx, req.(*ShowPoolsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_TopStatements_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TopStatementsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).TopStatements(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/crunchyproxy.server.serverpb.Admin/TopStatements",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).TopStatements(ctx, req.(*TopStatementsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_ListSessions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListSessionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).ListSessions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/crunchyproxy.server.serverpb.Admin/ListSessions",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).ListSessions(ctx, req.(*ListSessionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_TerminateSession_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TerminateSessionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).TerminateSession(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/crunchyproxy.server.serverpb.Admin/TerminateSession",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).TerminateSession(ctx, req.(*TerminateSessionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_CacheStatistics_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CacheStatisticsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).CacheStatistics(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/crunchyproxy.server.serverpb.Admin/CacheStatistics",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).CacheStatistics(ctx, req.(*CacheStatisticsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_SetReadOnly_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReadOnlyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).SetReadOnly(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/crunchyproxy.server.serverpb.Admin/SetReadOnly",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).SetReadOnly(ctx, req.(*ReadOnlyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_Pause_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PauseRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).Pause(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/crunchyproxy.server.serverpb.Admin/Pause",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).Pause(ctx, req.(*PauseRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_Resume_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ResumeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).Resume(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/crunchyproxy.server.serverpb.Admin/Resume",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).Resume(ctx, req.(*ResumeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _Admin_Health_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(HealthRequest)
 	if err := dec(in); err != nil {
@@ -422,6 +1775,42 @@ func (x *adminShutdownServer) Send(m *ShutdownResponse) error {
 	return x.ServerStream.SendMsg(m)
 }
 
+func _Admin_Reload_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReloadRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).Reload(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/crunchyproxy.server.serverpb.Admin/Reload",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).Reload(ctx, req.(*ReloadRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_GetConfig_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ConfigRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).GetConfig(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/crunchyproxy.server.serverpb.Admin/GetConfig",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).GetConfig(ctx, req.(*ConfigRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _Admin_Version_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(VersionRequest)
 	if err := dec(in); err != nil {
@@ -448,10 +1837,58 @@ var _Admin_serviceDesc = grpc.ServiceDesc{
 			MethodName: "Nodes",
 			Handler:    _Admin_Nodes_Handler,
 		},
+		{
+			MethodName: "AddNode",
+			Handler:    _Admin_AddNode_Handler,
+		},
+		{
+			MethodName: "RemoveNode",
+			Handler:    _Admin_RemoveNode_Handler,
+		},
+		{
+			MethodName: "EnableNode",
+			Handler:    _Admin_EnableNode_Handler,
+		},
+		{
+			MethodName: "DisableNode",
+			Handler:    _Admin_DisableNode_Handler,
+		},
 		{
 			MethodName: "Pools",
 			Handler:    _Admin_Pools_Handler,
 		},
+		{
+			MethodName: "ShowPools",
+			Handler:    _Admin_ShowPools_Handler,
+		},
+		{
+			MethodName: "TopStatements",
+			Handler:    _Admin_TopStatements_Handler,
+		},
+		{
+			MethodName: "ListSessions",
+			Handler:    _Admin_ListSessions_Handler,
+		},
+		{
+			MethodName: "TerminateSession",
+			Handler:    _Admin_TerminateSession_Handler,
+		},
+		{
+			MethodName: "CacheStatistics",
+			Handler:    _Admin_CacheStatistics_Handler,
+		},
+		{
+			MethodName: "SetReadOnly",
+			Handler:    _Admin_SetReadOnly_Handler,
+		},
+		{
+			MethodName: "Pause",
+			Handler:    _Admin_Pause_Handler,
+		},
+		{
+			MethodName: "Resume",
+			Handler:    _Admin_Resume_Handler,
+		},
 		{
 			MethodName: "Health",
 			Handler:    _Admin_Health_Handler,
@@ -460,12 +1897,25 @@ var _Admin_serviceDesc = grpc.ServiceDesc{
 			MethodName: "Statistics",
 			Handler:    _Admin_Statistics_Handler,
 		},
+		{
+			MethodName: "Reload",
+			Handler:    _Admin_Reload_Handler,
+		},
+		{
+			MethodName: "GetConfig",
+			Handler:    _Admin_GetConfig_Handler,
+		},
 		{
 			MethodName: "Version",
 			Handler:    _Admin_Version_Handler,
 		},
 	},
 	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "DrainNode",
+			Handler:       _Admin_DrainNode_Handler,
+			ServerStreams: true,
+		},
 		{
 			StreamName:    "Shutdown",
 			Handler:       _Admin_Shutdown_Handler,
@@ -478,40 +1928,126 @@ var _Admin_serviceDesc = grpc.ServiceDesc{
 func init() { proto.RegisterFile("admin.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 559 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0x94, 0x94, 0x4f, 0x8b, 0xd3, 0x40,
-	0x14, 0xc0, 0x99, 0x2e, 0x69, 0xbb, 0xaf, 0x7f, 0x92, 0x8e, 0xbb, 0x5a, 0xe2, 0x1e, 0x96, 0xe0,
-	0x61, 0xdd, 0x3f, 0xc9, 0xb2, 0x22, 0xd4, 0x82, 0x07, 0x05, 0x41, 0x10, 0x64, 0xcd, 0x82, 0x82,
-	0x17, 0xc9, 0xa6, 0xc3, 0x36, 0x18, 0x33, 0xd9, 0xcc, 0xa4, 0x5a, 0x3c, 0x08, 0x1e, 0xd4, 0xbb,
-	0x78, 0xf4, 0x03, 0xf8, 0x79, 0xfc, 0x0a, 0x7e, 0x10, 0xc9, 0xfc, 0x69, 0x53, 0x0a, 0x4d, 0xf6,
-	0xd4, 0xbe, 0xd7, 0x79, 0xef, 0xfd, 0x5e, 0xf2, 0x9b, 0x42, 0x27, 0x98, 0x7c, 0x88, 0x12, 0x37,
-	0xcd, 0x28, 0xa7, 0x78, 0x2f, 0xcc, 0xf2, 0x24, 0x9c, 0xce, 0xd3, 0x8c, 0x7e, 0x9a, 0xbb, 0x8c,
-	0x64, 0x33, 0x92, 0xa9, 0x8f, 0xf4, 0xd2, 0xde, 0xbb, 0xa2, 0xf4, 0x2a, 0x26, 0x5e, 0x90, 0x46,
-	0x5e, 0x90, 0x24, 0x94, 0x07, 0x3c, 0xa2, 0x09, 0x93, 0xb5, 0x4e, 0x0f, 0x3a, 0x2f, 0xe9, 0x84,
-	0xf8, 0xe4, 0x3a, 0x27, 0x8c, 0x3b, 0xbf, 0x10, 0x74, 0x65, 0xcc, 0x52, 0x9a, 0x30, 0x82, 0x5f,
-	0x80, 0x91, 0xd0, 0x09, 0x61, 0x43, 0xb4, 0xbf, 0x75, 0xd0, 0x39, 0x7b, 0xe8, 0x6e, 0x9a, 0xe5,
-	0x96, 0x4b, 0x45, 0xc0, 0x9e, 0x25, 0x3c, 0x9b, 0xfb, 0xb2, 0x87, 0x3d, 0x02, 0x58, 0x26, 0xb1,
-	0x05, 0x5b, 0xef, 0xc9, 0x7c, 0x88, 0xf6, 0xd1, 0xc1, 0xb6, 0x5f, 0x7c, 0xc5, 0x3b, 0x60, 0xcc,
-	0x82, 0x38, 0x27, 0xc3, 0x86, 0xc8, 0xc9, 0x60, 0xdc, 0x18, 0xa1, 0x02, 0xf3, 0x9c, 0xd2, 0x58,
-	0x63, 0xde, 0x83, 0xae, 0x0c, 0x15, 0xe5, 0x0e, 0x18, 0x29, 0xa5, 0xb1, 0xa4, 0xdc, 0xf6, 0x65,
-	0xe0, 0x98, 0xd0, 0x7b, 0x4e, 0x82, 0x98, 0x4f, 0x75, 0xd9, 0x6f, 0x04, 0x7d, 0x9d, 0x51, 0x95,
-	0xe7, 0xd0, 0x9c, 0x8a, 0x8c, 0x5a, 0x70, 0xb4, 0x79, 0xc1, 0xd5, 0x6a, 0x15, 0xca, 0x1d, 0x55,
-	0x1f, 0xfb, 0x11, 0x74, 0x4a, 0xe9, 0xaa, 0x2d, 0xdb, 0xe5, 0x2d, 0x6f, 0xc1, 0xe0, 0xa2, 0x78,
-	0x3d, 0x8c, 0x47, 0x21, 0xd3, 0xd0, 0x7f, 0x10, 0xe0, 0x72, 0x56, 0x81, 0xbf, 0x81, 0xd6, 0x75,
-	0x4e, 0xb2, 0x68, 0xf1, 0x6a, 0x1e, 0x6f, 0x26, 0x5f, 0x6f, 0xe1, 0xbe, 0x92, 0xf5, 0x12, 0x5f,
-	0x77, 0xb3, 0xc7, 0xd0, 0x2d, 0xff, 0x50, 0xb5, 0x80, 0x51, 0x5e, 0x60, 0x00, 0xe6, 0xc5, 0x34,
-	0xe7, 0x13, 0xfa, 0x31, 0xd1, 0xf8, 0xc7, 0x60, 0x2d, 0x53, 0x8a, 0x7d, 0x08, 0x2d, 0x96, 0x87,
-	0x21, 0x61, 0x4c, 0xb4, 0x6d, 0xfb, 0x3a, 0x74, 0x2c, 0xe8, 0xbf, 0x26, 0x19, 0x8b, 0xe8, 0xa2,
-	0xfe, 0x08, 0xcc, 0x45, 0x66, 0x59, 0x3e, 0x93, 0x29, 0x45, 0xa5, 0xc3, 0xb3, 0xef, 0x4d, 0x30,
-	0x9e, 0x14, 0x37, 0x03, 0xe7, 0x60, 0x08, 0xd5, 0xf0, 0xfd, 0x3a, 0xc6, 0x8a, 0x51, 0xf6, 0x61,
-	0x7d, 0xb9, 0x9d, 0xdd, 0xaf, 0x7f, 0xff, 0xfd, 0x6c, 0x98, 0xb8, 0xe7, 0xbd, 0x13, 0x57, 0xd1,
-	0x13, 0x86, 0x17, 0x63, 0x0b, 0x31, 0x2b, 0xc7, 0x96, 0x64, 0xae, 0x1a, 0x5b, 0x16, 0x7d, 0x7d,
-	0xac, 0x30, 0x1d, 0x7f, 0x86, 0xa6, 0x74, 0x0e, 0x1f, 0xd5, 0xf3, 0x57, 0x4e, 0x3e, 0xbe, 0x89,
-	0xec, 0xce, 0x6d, 0x31, 0xdb, 0xc2, 0x7d, 0x3d, 0x5b, 0x0a, 0x8f, 0xbf, 0x21, 0x80, 0xa5, 0x5d,
-	0xd8, 0xab, 0xef, 0xa1, 0xa4, 0x38, 0xbd, 0xa9, 0xb8, 0xeb, 0x4f, 0x81, 0xf1, 0x80, 0x33, 0xfc,
-	0x03, 0x41, 0x5b, 0xbb, 0x86, 0x4f, 0x2a, 0xba, 0xae, 0x6a, 0x6a, 0xbb, 0x75, 0x8f, 0x2b, 0x84,
-	0xbb, 0x02, 0x61, 0xd7, 0xb1, 0x16, 0x08, 0xea, 0xc4, 0x18, 0x1d, 0x9e, 0x22, 0xfc, 0x05, 0x5a,
-	0xca, 0x5a, 0x5c, 0xf1, 0x90, 0x57, 0x75, 0xb7, 0x4f, 0x6a, 0x9e, 0x56, 0x18, 0x77, 0x04, 0xc6,
-	0x00, 0x9b, 0x1a, 0x43, 0xdd, 0x84, 0xa7, 0xf0, 0xb6, 0xad, 0x8b, 0x2e, 0x9b, 0xe2, 0xaf, 0xfe,
-	0xc1, 0xff, 0x00, 0x00, 0x00, 0xff, 0xff, 0xe1, 0x79, 0x2d, 0xa5, 0x35, 0x06, 0x00, 0x00,
+	// 1929 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0x94, 0x59, 0xcd, 0x6f, 0x1c, 0x49,
+	0x15, 0x57, 0xcf, 0xa4, 0xe7, 0xe3, 0x8d, 0x3f, 0xc6, 0xe5, 0x8f, 0xf4, 0x76, 0x1c, 0x76, 0xd2,
+	0x02, 0x32, 0x71, 0x9c, 0x99, 0xac, 0x77, 0x17, 0x85, 0x48, 0x48, 0x64, 0x77, 0x23, 0x58, 0xf1,
+	0x95, 0x6d, 0x07, 0x22, 0x21, 0xa1, 0x51, 0xb9, 0xbb, 0xe2, 0xa9, 0xdd, 0x99, 0xae, 0xd9, 0xae,
+	0x1a, 0xc7, 0x4e, 0xb4, 0x80, 0x38, 0x20, 0x38, 0x20, 0x0e, 0x80, 0x90, 0x40, 0xdc, 0x38, 0x70,
+	0xe0, 0x82, 0xf8, 0x1f, 0xf8, 0x07, 0xf8, 0x17, 0xb8, 0x73, 0xe3, 0x8c, 0xea, 0xab, 0xa7, 0xdb,
+	0x1e, 0xbb, 0xdb, 0x27, 0xf7, 0x7b, 0xf5, 0x5e, 0xbd, 0x57, 0xef, 0xfd, 0xaa, 0xea, 0x57, 0x1e,
+	0xe8, 0xe0, 0x78, 0x4a, 0x93, 0xc1, 0x2c, 0x65, 0x82, 0xa1, 0xdd, 0x28, 0x9d, 0x27, 0xd1, 0xf8,
+	0x6c, 0x96, 0xb2, 0xd3, 0xb3, 0x01, 0x27, 0xe9, 0x09, 0x49, 0xcd, 0x9f, 0xd9, 0x91, 0xbf, 0x7b,
+	0xcc, 0xd8, 0xf1, 0x84, 0x0c, 0xf1, 0x8c, 0x0e, 0x71, 0x92, 0x30, 0x81, 0x05, 0x65, 0x09, 0xd7,
+	0xbe, 0xc1, 0x2a, 0x74, 0xbe, 0xcf, 0x62, 0x12, 0x92, 0xcf, 0xe7, 0x84, 0x8b, 0xe0, 0x1f, 0x0e,
+	0xac, 0x68, 0x99, 0xcf, 0x58, 0xc2, 0x09, 0xfa, 0x0e, 0xb8, 0x09, 0x8b, 0x09, 0xf7, 0x9c, 0x5e,
+	0xbd, 0xdf, 0x39, 0x78, 0x7f, 0x70, 0x55, 0xac, 0x41, 0xde, 0x55, 0x09, 0xfc, 0x69, 0x22, 0xd2,
+	0xb3, 0x50, 0xcf, 0x81, 0x7c, 0x68, 0xc5, 0x94, 0xe3, 0xa3, 0x09, 0x89, 0xbd, 0x5a, 0xaf, 0xde,
+	0x6f, 0x87, 0x99, 0xec, 0x3f, 0x02, 0x58, 0x38, 0xa0, 0x2e, 0xd4, 0x3f, 0x23, 0x67, 0x9e, 0xd3,
+	0x73, 0xfa, 0xed, 0x50, 0x7e, 0xa2, 0x2d, 0x70, 0x4f, 0xf0, 0x64, 0x4e, 0xbc, 0x9a, 0xd2, 0x69,
+	0xe1, 0x71, 0xed, 0x91, 0x13, 0xfc, 0xd5, 0x81, 0xb5, 0x27, 0x71, 0x9c, 0x5b, 0x06, 0x42, 0x70,
+	0x23, 0xc1, 0x53, 0x62, 0xfc, 0xd5, 0x37, 0xba, 0x05, 0xed, 0x31, 0xe3, 0x62, 0x34, 0x63, 0xa9,
+	0x30, 0x93, 0xb4, 0xa4, 0xe2, 0x19, 0x4b, 0x95, 0x43, 0xca, 0x26, 0xc4, 0xab, 0x6b, 0x07, 0xf9,
+	0x2d, 0x1d, 0x66, 0x8c, 0x4d, 0x46, 0x53, 0x16, 0x13, 0xef, 0x86, 0x76, 0x90, 0x8a, 0xef, 0xb1,
+	0x98, 0xa0, 0x1d, 0x68, 0xbc, 0x22, 0xf4, 0x78, 0x2c, 0x3c, 0xb7, 0xe7, 0xf4, 0xdd, 0xd0, 0x48,
+	0xc8, 0x83, 0x66, 0x34, 0x99, 0x73, 0x41, 0x52, 0xaf, 0xa1, 0x5c, 0xac, 0x18, 0xdc, 0x87, 0xf5,
+	0x2c, 0x4b, 0x53, 0x5c, 0x0f, 0x9a, 0x7c, 0x1e, 0x45, 0x84, 0x73, 0x95, 0x69, 0x2b, 0xb4, 0x62,
+	0x70, 0x17, 0x36, 0x42, 0x32, 0x65, 0x27, 0xa4, 0x64, 0x55, 0xc1, 0x00, 0x50, 0xde, 0xb0, 0xca,
+	0xc4, 0x4f, 0x13, 0x59, 0xf1, 0x0a, 0x13, 0xe7, 0x0d, 0x4b, 0x27, 0xee, 0x03, 0xfa, 0x88, 0xf2,
+	0x85, 0xc3, 0xe5, 0x33, 0x0f, 0x61, 0xb3, 0x60, 0x59, 0x3a, 0xf5, 0x37, 0xa1, 0xfb, 0x51, 0x8a,
+	0x69, 0x52, 0xd6, 0x61, 0x0f, 0x9a, 0x82, 0x4e, 0x09, 0x9b, 0xeb, 0xfe, 0xba, 0xa1, 0x15, 0x83,
+	0x11, 0x6c, 0xe4, 0x66, 0x30, 0x01, 0x77, 0xa0, 0x81, 0x23, 0x41, 0x4f, 0xf4, 0x24, 0x6e, 0x68,
+	0x24, 0xf4, 0x25, 0x00, 0x41, 0xd2, 0x29, 0x4d, 0xb0, 0x50, 0x38, 0x95, 0x63, 0x39, 0x8d, 0x0c,
+	0x1d, 0xb3, 0x44, 0x63, 0xa5, 0x15, 0xaa, 0x6f, 0xb9, 0x8d, 0x9e, 0x31, 0x36, 0xb1, 0xdb, 0xe8,
+	0xcb, 0xb0, 0xa2, 0x45, 0x13, 0x6a, 0x0b, 0x5c, 0x89, 0x1c, 0xbd, 0x8b, 0xda, 0xa1, 0x16, 0x02,
+	0x04, 0xdd, 0xc3, 0x31, 0x7b, 0x25, 0x2d, 0xb9, 0xf5, 0xfc, 0x57, 0x0d, 0xd6, 0xa4, 0xe2, 0x50,
+	0x6e, 0x53, 0x2e, 0x68, 0xc4, 0xd5, 0x52, 0x59, 0xac, 0xb3, 0x94, 0x4b, 0x95, 0xf0, 0x93, 0x3b,
+	0x09, 0x0b, 0x7c, 0x84, 0xb9, 0xdd, 0x10, 0x99, 0x2c, 0xed, 0xe7, 0x9c, 0xa4, 0x16, 0xcb, 0xf2,
+	0x5b, 0x26, 0x20, 0x98, 0xc0, 0x13, 0x85, 0x63, 0x37, 0xd4, 0x02, 0xda, 0x86, 0x06, 0x4d, 0x46,
+	0x73, 0x4e, 0x0c, 0x88, 0x5d, 0x9a, 0xfc, 0x50, 0x4f, 0x40, 0xe3, 0x09, 0x51, 0x00, 0x76, 0x43,
+	0xf5, 0x2d, 0x6b, 0xfb, 0x0a, 0x53, 0x41, 0x93, 0x63, 0xaf, 0xa9, 0x6b, 0x6b, 0x44, 0x74, 0x07,
+	0x56, 0xf0, 0x09, 0x49, 0xf1, 0x31, 0x19, 0x49, 0x95, 0xd7, 0xea, 0x39, 0x7d, 0x27, 0xec, 0x18,
+	0xdd, 0x0b, 0x4c, 0x05, 0x7a, 0x1b, 0xac, 0x38, 0xc2, 0xc7, 0xc4, 0x6b, 0x2b, 0x0b, 0x30, 0xaa,
+	0x27, 0xc7, 0x04, 0xbd, 0x05, 0xad, 0x29, 0x3e, 0xd5, 0xfe, 0xa0, 0x46, 0x9b, 0x53, 0x7c, 0xaa,
+	0x7c, 0x7d, 0x68, 0x99, 0x2e, 0x72, 0xaf, 0xd3, 0x73, 0xfa, 0xf5, 0x30, 0x93, 0xe5, 0x58, 0x4a,
+	0x3e, 0x25, 0x91, 0xec, 0xd3, 0x8a, 0x1e, 0xb3, 0x72, 0xf0, 0x02, 0x36, 0x72, 0xc5, 0x35, 0x7d,
+	0xf8, 0x20, 0xdf, 0x87, 0xce, 0xc1, 0xfe, 0xd5, 0xa7, 0x59, 0xb1, 0x0f, 0xb6, 0x6b, 0xfb, 0xb0,
+	0xf5, 0x9c, 0xcd, 0xa4, 0x9e, 0x4c, 0x49, 0x22, 0x6c, 0xe7, 0x64, 0x89, 0x27, 0x74, 0x4a, 0x85,
+	0x41, 0x93, 0x16, 0x82, 0xbf, 0x3b, 0xb0, 0x99, 0xd9, 0xe6, 0x9a, 0xba, 0x05, 0xee, 0xe7, 0x73,
+	0x92, 0xda, 0x23, 0x4e, 0x0b, 0x59, 0xab, 0x6b, 0xb9, 0x56, 0x6f, 0x81, 0x1b, 0xe1, 0xc9, 0x84,
+	0xab, 0x7e, 0xd6, 0x43, 0x2d, 0xa0, 0xdb, 0x00, 0xaa, 0x87, 0x23, 0x59, 0x0c, 0xd5, 0x55, 0x27,
+	0x6c, 0x2b, 0xcd, 0x73, 0xaa, 0x0f, 0xbb, 0x29, 0xc1, 0x89, 0x1e, 0x75, 0xd5, 0x68, 0x4b, 0x2a,
+	0xd4, 0xa0, 0xa9, 0xb6, 0x1a, 0x6b, 0x64, 0xd5, 0x96, 0x43, 0xc1, 0xa7, 0xb0, 0x7d, 0x6e, 0x71,
+	0xa6, 0x72, 0x9f, 0x00, 0xf0, 0x4c, 0x6b, 0xca, 0xf7, 0xce, 0xd5, 0xe5, 0x5b, 0xb2, 0xec, 0x30,
+	0x37, 0x49, 0xb0, 0x0d, 0x9b, 0xdf, 0xa5, 0x5c, 0x1c, 0x12, 0xce, 0xe5, 0x85, 0x64, 0x77, 0xc0,
+	0xef, 0x6b, 0xd0, 0x31, 0xba, 0x8f, 0x93, 0x97, 0x0c, 0xad, 0x41, 0x8d, 0xc6, 0xa6, 0xa8, 0x35,
+	0x1a, 0x4b, 0x30, 0x45, 0x13, 0x4a, 0x12, 0x31, 0xc2, 0x71, 0x9c, 0x9a, 0x52, 0x81, 0x56, 0x3d,
+	0x89, 0xe3, 0x74, 0x29, 0xfe, 0xf3, 0xfb, 0xe5, 0xc6, 0xb9, 0xfd, 0xb2, 0x05, 0xae, 0xca, 0x4a,
+	0xd5, 0xa9, 0x1d, 0x6a, 0x41, 0x02, 0xfe, 0x08, 0x47, 0x9f, 0x91, 0x24, 0xb6, 0x07, 0xb9, 0x11,
+	0x25, 0xe0, 0x23, 0x96, 0x24, 0x24, 0x12, 0xba, 0x84, 0x4d, 0xd5, 0x97, 0x8e, 0xd1, 0xa9, 0x0a,
+	0xdf, 0x81, 0x95, 0x54, 0x2f, 0x47, 0x9b, 0xb4, 0xb4, 0x89, 0xd1, 0x29, 0x13, 0x85, 0xdd, 0x88,
+	0xd0, 0x13, 0x12, 0x7b, 0x6d, 0x8b, 0x5d, 0x2d, 0xcb, 0x15, 0x70, 0x92, 0xe8, 0xad, 0x50, 0x0f,
+	0xd5, 0x77, 0xf0, 0x13, 0xd8, 0x2a, 0x56, 0xcb, 0x34, 0xe6, 0x29, 0xb4, 0xb8, 0xd1, 0x99, 0xb6,
+	0xdc, 0x2b, 0x69, 0xcb, 0xa2, 0xb6, 0x61, 0xe6, 0x1a, 0x3c, 0x82, 0x9b, 0xcf, 0xed, 0x11, 0x67,
+	0x2c, 0x2c, 0xb0, 0x6f, 0x03, 0x18, 0xb3, 0x51, 0xd6, 0x88, 0xb6, 0xd1, 0x7c, 0x1c, 0x07, 0xef,
+	0x81, 0x77, 0xd1, 0xb3, 0xf4, 0x4c, 0xf7, 0x60, 0xe7, 0x43, 0x1c, 0x8d, 0x49, 0x0e, 0x1b, 0xa6,
+	0xff, 0xff, 0x74, 0xe0, 0xe6, 0x85, 0xa1, 0xc5, 0x7c, 0x24, 0x11, 0x29, 0x25, 0xdc, 0xe4, 0x61,
+	0x45, 0x55, 0x32, 0xfa, 0x5a, 0xef, 0x1c, 0x59, 0x32, 0xfa, 0x5a, 0x9d, 0x63, 0x63, 0x2a, 0xec,
+	0xc6, 0x51, 0xdf, 0xf2, 0xd0, 0x9f, 0x52, 0xce, 0x09, 0x57, 0x30, 0xa8, 0x87, 0x46, 0x42, 0xbb,
+	0xd0, 0x26, 0x27, 0x34, 0x52, 0xd4, 0x48, 0x01, 0xa1, 0x1e, 0x2e, 0x14, 0xa8, 0x07, 0x1d, 0x72,
+	0x3a, 0xa3, 0xa9, 0xa6, 0x4e, 0x0a, 0x10, 0xf5, 0x30, 0xaf, 0x0a, 0xee, 0xc1, 0x7a, 0x48, 0x70,
+	0xfc, 0x83, 0x64, 0x72, 0x66, 0xeb, 0xb6, 0x03, 0x0d, 0xa2, 0x6e, 0x50, 0xb3, 0x76, 0x23, 0x05,
+	0x43, 0xe8, 0x2e, 0x4c, 0xcd, 0xc2, 0x6e, 0x41, 0x3b, 0x25, 0x38, 0x1e, 0xb1, 0x64, 0x72, 0x66,
+	0xcc, 0x5b, 0xa9, 0x31, 0x0a, 0xfa, 0xb0, 0xf2, 0x0c, 0xcf, 0x79, 0x76, 0xf7, 0xe5, 0xee, 0x39,
+	0xa7, 0x78, 0xcf, 0xdd, 0x85, 0x55, 0x63, 0x79, 0xf5, 0x1d, 0x17, 0xac, 0xc3, 0x6a, 0x48, 0xf8,
+	0x7c, 0x9a, 0x11, 0xbf, 0x3d, 0x58, 0xb3, 0x8a, 0xd2, 0xde, 0xad, 0xc3, 0xea, 0xb7, 0x09, 0x9e,
+	0x88, 0xb1, 0x75, 0xfe, 0x8b, 0x03, 0x6b, 0x56, 0x63, 0xbc, 0x9f, 0x41, 0x63, 0xac, 0x34, 0x06,
+	0x94, 0x8f, 0xae, 0x06, 0x65, 0xd1, 0xdb, 0x88, 0x9a, 0x3b, 0x9a, 0x79, 0xfc, 0xaf, 0x43, 0x27,
+	0xa7, 0x2e, 0x63, 0x88, 0xad, 0x3c, 0x43, 0xdc, 0x84, 0x8d, 0x8b, 0x38, 0xfb, 0xaf, 0x03, 0x68,
+	0x09, 0xc4, 0x5e, 0x40, 0x53, 0x9e, 0xc5, 0x34, 0xa3, 0xbc, 0xdf, 0x28, 0x3f, 0xe5, 0x8a, 0x53,
+	0x0c, 0x3e, 0xd1, 0xfe, 0x3a, 0x7d, 0x3b, 0x9b, 0x3a, 0x66, 0x68, 0x12, 0x59, 0x88, 0x6a, 0x41,
+	0x36, 0x68, 0x3e, 0x53, 0x67, 0x84, 0x46, 0xa9, 0x91, 0xa4, 0x9e, 0x0b, 0x9c, 0x0a, 0x6e, 0x6e,
+	0x6c, 0x23, 0xf9, 0x8f, 0x61, 0x25, 0x3f, 0x7d, 0x59, 0x19, 0xdc, 0x7c, 0x19, 0x36, 0x60, 0xfd,
+	0x70, 0x3c, 0x17, 0x31, 0x7b, 0x65, 0xf7, 0x76, 0xb0, 0x0f, 0xdd, 0x85, 0xaa, 0x4a, 0xe3, 0x43,
+	0x32, 0x61, 0x38, 0x2e, 0xa0, 0x46, 0x2b, 0x2a, 0x30, 0xcf, 0xd5, 0x0f, 0x59, 0xf2, 0x92, 0x1e,
+	0xe7, 0xf6, 0xc7, 0x4b, 0x96, 0x4e, 0xb1, 0x30, 0xd9, 0x1b, 0x29, 0xe8, 0xc3, 0x9a, 0x35, 0x5c,
+	0xa0, 0x38, 0x52, 0x1a, 0x6b, 0xa9, 0xa5, 0xa0, 0x0b, 0x6b, 0x3f, 0x22, 0x69, 0xee, 0xac, 0x92,
+	0x24, 0x3b, 0xd3, 0x2c, 0x32, 0x3a, 0xd1, 0x2a, 0xe3, 0x6d, 0xc5, 0x83, 0xff, 0xed, 0x80, 0xfb,
+	0x44, 0xbe, 0xa3, 0xd0, 0x1c, 0x5c, 0xf5, 0xf8, 0x40, 0xf7, 0xaa, 0xbc, 0x6f, 0x54, 0x28, 0x7f,
+	0xaf, 0xfa, 0x53, 0x28, 0xd8, 0xfe, 0xc5, 0xbf, 0xff, 0xf3, 0xbb, 0xda, 0x3a, 0x5a, 0x1d, 0x8e,
+	0xd4, 0xc3, 0x6d, 0xa8, 0xdf, 0x43, 0x3f, 0x77, 0xa0, 0x69, 0xde, 0x04, 0xa8, 0x84, 0x8b, 0x14,
+	0x1f, 0x38, 0xfe, 0x83, 0x8a, 0xd6, 0x26, 0xbe, 0xa7, 0xe2, 0xa3, 0xa0, 0x18, 0xff, 0xb1, 0xb3,
+	0x87, 0x7e, 0xe3, 0x00, 0x2c, 0x1e, 0x10, 0x68, 0x78, 0xf5, 0xbc, 0x17, 0xde, 0x24, 0xfe, 0xc3,
+	0xea, 0x0e, 0x26, 0x97, 0x5d, 0x95, 0xcb, 0xce, 0xde, 0x56, 0x21, 0x97, 0xe1, 0x1b, 0x49, 0xe1,
+	0xbf, 0x40, 0x7f, 0x74, 0x00, 0x16, 0xef, 0x8e, 0xb2, 0x7c, 0x2e, 0x3c, 0x65, 0xfc, 0x87, 0xd5,
+	0x1d, 0x4c, 0x3e, 0x5f, 0x55, 0xf9, 0xf4, 0x82, 0x5b, 0xcb, 0xf2, 0x19, 0xea, 0x33, 0x5b, 0x56,
+	0xea, 0xcf, 0x0e, 0x74, 0x72, 0xef, 0x16, 0x54, 0x12, 0xe9, 0xe2, 0x63, 0xc8, 0x7f, 0xe7, 0x1a,
+	0x1e, 0x26, 0xb9, 0xbb, 0x2a, 0xb9, 0x3b, 0xc1, 0xee, 0xd2, 0xe4, 0xcc, 0xe3, 0x59, 0x66, 0xf7,
+	0x07, 0x07, 0xda, 0xd9, 0x13, 0x07, 0x0d, 0x4a, 0x22, 0x9d, 0x7b, 0x4d, 0xf9, 0xc3, 0xca, 0xf6,
+	0x26, 0xaf, 0xaf, 0xa8, 0xbc, 0xde, 0x0e, 0xfc, 0xe5, 0x79, 0x49, 0xfb, 0xc7, 0xce, 0xde, 0x43,
+	0x47, 0xee, 0x2c, 0x45, 0xc1, 0xcb, 0x76, 0x56, 0xee, 0xf5, 0xe4, 0xef, 0x55, 0x31, 0xbd, 0x6c,
+	0x67, 0x29, 0x92, 0x8e, 0x7e, 0xed, 0x40, 0x3b, 0xa3, 0xff, 0x65, 0xe5, 0x38, 0xff, 0x08, 0xf3,
+	0x87, 0x95, 0xed, 0x4d, 0x16, 0xb7, 0x54, 0x16, 0xdb, 0x68, 0xb3, 0x90, 0xc5, 0x50, 0x32, 0x49,
+	0x8e, 0xfe, 0xe4, 0xc0, 0x6a, 0x81, 0x54, 0xa3, 0x83, 0xab, 0xe7, 0x5f, 0xf6, 0xbc, 0xf0, 0xdf,
+	0xbd, 0x96, 0x8f, 0xc9, 0xab, 0xa7, 0xf2, 0xf2, 0x91, 0x67, 0xf3, 0x52, 0x19, 0x0d, 0x17, 0x24,
+	0x1c, 0xfd, 0xd6, 0x81, 0x95, 0x3c, 0xaf, 0x44, 0x25, 0x20, 0x5d, 0xc2, 0xd8, 0xfd, 0x83, 0xeb,
+	0xb8, 0x14, 0x4f, 0x24, 0xd4, 0xcd, 0x32, 0xb3, 0x09, 0xfc, 0xcd, 0x81, 0xee, 0x79, 0x42, 0x89,
+	0x4a, 0xfe, 0xef, 0x74, 0x09, 0x75, 0xf5, 0xbf, 0x76, 0x5d, 0xb7, 0x22, 0xbc, 0xf7, 0x6e, 0x9f,
+	0xcf, 0x6e, 0xf8, 0x66, 0x41, 0x85, 0xbf, 0x90, 0x47, 0xc2, 0xfa, 0x39, 0xaa, 0x8a, 0xde, 0xbb,
+	0x3a, 0xe4, 0x72, 0xd2, 0xeb, 0xbf, 0x7f, 0x4d, 0xaf, 0xcb, 0x70, 0xa7, 0xfb, 0x1b, 0x49, 0x73,
+	0xb9, 0x07, 0x3a, 0x87, 0x44, 0x58, 0xae, 0x89, 0x1e, 0x94, 0x1d, 0xd5, 0x05, 0xfa, 0xea, 0x0f,
+	0xaa, 0x9a, 0x17, 0x73, 0x09, 0xb2, 0x8e, 0x4a, 0xfe, 0x2a, 0xf9, 0xac, 0x3c, 0x9e, 0x5e, 0x83,
+	0xab, 0x88, 0x29, 0x2a, 0xdb, 0xdb, 0x39, 0x9e, 0xeb, 0xdf, 0xaf, 0x64, 0x7b, 0xd9, 0x15, 0x37,
+	0x93, 0xc3, 0x32, 0xf6, 0x4f, 0xa1, 0xa1, 0xa9, 0x2d, 0xba, 0x5f, 0xb6, 0xa4, 0x1c, 0x23, 0xf6,
+	0xf7, 0xab, 0x19, 0x9b, 0xf0, 0x6f, 0xa9, 0xf0, 0x9b, 0xc1, 0xda, 0x62, 0xf5, 0x72, 0x5c, 0xc6,
+	0x7f, 0x03, 0x0d, 0x4d, 0x5c, 0xcb, 0xe2, 0x17, 0x48, 0xb5, 0xbf, 0x5f, 0xcd, 0xd8, 0xc4, 0xdf,
+	0x51, 0xf1, 0xbb, 0x28, 0x8b, 0xaf, 0x59, 0x33, 0xfa, 0xa5, 0x03, 0x90, 0x43, 0xe7, 0xb0, 0x3a,
+	0x99, 0xad, 0x74, 0x9f, 0x2e, 0xc1, 0xe4, 0x85, 0x13, 0x59, 0x9f, 0x82, 0xbf, 0x72, 0xa0, 0x65,
+	0xa9, 0x66, 0x19, 0x14, 0xcf, 0xb1, 0x54, 0x7f, 0x50, 0xd5, 0xfc, 0x32, 0x28, 0x72, 0x63, 0xa1,
+	0xef, 0x24, 0x05, 0x08, 0xc9, 0x5a, 0xcb, 0x01, 0x91, 0x23, 0xbb, 0xfe, 0x7e, 0x35, 0xe3, 0xcb,
+	0x01, 0x21, 0xc7, 0x35, 0x20, 0xdb, 0xdf, 0x22, 0x42, 0x73, 0xdc, 0xb2, 0x14, 0x0a, 0x94, 0xd9,
+	0xdf, 0xaf, 0x66, 0x7c, 0x19, 0x26, 0x34, 0x6d, 0x46, 0x3f, 0x83, 0xa6, 0x21, 0xc9, 0x65, 0xac,
+	0xb3, 0xc8, 0xae, 0xfd, 0x07, 0x15, 0xad, 0x4d, 0xfc, 0x9b, 0x2a, 0xfe, 0x06, 0x5a, 0xb7, 0xf1,
+	0x0d, 0xf1, 0xfe, 0x00, 0x7e, 0xdc, 0xb2, 0x4e, 0x47, 0x0d, 0xf5, 0x3b, 0xc4, 0xbb, 0xff, 0x1f,
+	0x00, 0xd1, 0x12, 0xf6, 0x70, 0xd2, 0x18, 0x00, 0x00,
 }
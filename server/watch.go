@@ -0,0 +1,58 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"os"
+	"time"
+
+	"github.com/crunchydata/crunchy-proxy/config"
+	"github.com/crunchydata/crunchy-proxy/util/log"
+)
+
+// watchConfig reloads the configuration whenever the configuration file
+// changes, so that rotated credentials are used without a restart. The file
+// is checked periodically rather than through file system events, since
+// Kubernetes and Vault agents replace files by swapping symlinks. Changes are
+// only applied while watching is enabled, so that it can be enabled by a
+// reload.
+func (s *Server) watchConfig() {
+	path := config.GetConfigPath()
+	last, _ := os.Stat(path)
+
+	for {
+		time.Sleep(time.Duration(config.GetWatchConfig().Interval) * time.Second)
+
+		info, err := os.Stat(path)
+
+		if err != nil {
+			log.Errorf("Error checking configuration file: %s", err.Error())
+			continue
+		}
+
+		if last != nil && info.ModTime().Equal(last.ModTime()) && info.Size() == last.Size() {
+			continue
+		}
+
+		last = info
+
+		if !config.GetWatchConfig().Enable {
+			continue
+		}
+
+		log.Infof("Configuration file '%s' changed", path)
+		s.Reload()
+	}
+}
@@ -19,8 +19,8 @@ import (
 	"flag"
 	_ "github.com/lib/pq"
 	"log"
+	"net"
 	"os"
-	"strings"
 	"time"
 )
 
@@ -41,9 +41,8 @@ func main() {
 	var conn *sql.DB
 	var err error
 	//os.Setenv("PGCONNECTION_TIMEOUT", "20")
-	var hostportarr = strings.Split(hostport, ":")
-	var dbHost = hostportarr[0]
-	var dbPort = hostportarr[1]
+	dbHost, dbPort, err := net.SplitHostPort(hostport)
+	checkError(err)
 
 	log.Println("connecting to host:" + dbHost +
 		" port:" + dbPort +
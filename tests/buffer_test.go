@@ -0,0 +1,70 @@
+/*
+Copyright 2016 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tests
+
+import (
+	"net"
+	"testing"
+
+	"github.com/crunchydata/crunchy-proxy/connect"
+	"github.com/crunchydata/crunchy-proxy/protocol"
+)
+
+/*
+ * The receive benchmarks relay a stream of queries over an in-memory
+ * connection, so they do not need a running proxy. Run them with
+ * 'go test -run NONE -bench Receive -benchmem'.
+ */
+func benchmarkReceive(b *testing.B, receive func(connection net.Conn) error) {
+	client, server := net.Pipe()
+	defer server.Close()
+
+	message := protocol.CreateQueryMessage("select 1")
+
+	go func() {
+		defer client.Close()
+
+		for {
+			if _, err := connect.Send(client, message); err != nil {
+				return
+			}
+		}
+	}()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if err := receive(server); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkReceive(b *testing.B) {
+	benchmarkReceive(b, func(connection net.Conn) error {
+		_, _, err := connect.Receive(connection)
+		return err
+	})
+}
+
+func BenchmarkReceiveBuffer(b *testing.B) {
+	buffer := connect.GetBuffer()
+	defer connect.PutBuffer(buffer)
+
+	benchmarkReceive(b, func(connection net.Conn) error {
+		_, _, err := connect.ReceiveBuffer(connection, buffer)
+		return err
+	})
+}
@@ -17,7 +17,7 @@ import (
 	"database/sql"
 	_ "github.com/lib/pq"
 	"log"
-	"strings"
+	"net"
 )
 
 func Connect() (*sql.DB, error) {
@@ -26,9 +26,11 @@ func Connect() (*sql.DB, error) {
 
 	var err error
 	//os.Setenv("PGCONNECTION_TIMEOUT", "20")
-	var hostportarr = strings.Split(HostPort, ":")
-	var dbHost = hostportarr[0]
-	var dbPort = hostportarr[1]
+	dbHost, dbPort, err := net.SplitHostPort(HostPort)
+
+	if err != nil {
+		return nil, err
+	}
 
 	log.Println("connecting to host:" + dbHost + " port:" + dbPort + " user:" + userid + " password:" + password + " database:" + database)
 	conn, err = GetDBConnection(dbHost, userid, dbPort, database, password)
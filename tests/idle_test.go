@@ -0,0 +1,155 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tests
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+/* The connections that are opened at the same time. */
+const idleOpeners = 100
+
+/* How long the proxy is given to settle once the connections are open. */
+const idleSettle = 10 * time.Second
+
+/*
+ * BenchmarkIdleConnections opens -idleclients connections through the proxy,
+ * leaves them idle and reports the memory and goroutines that the proxy uses
+ * for each of them, as read from its metrics endpoint at -metrics. Run it
+ * once with each server.proxy.idlewait, after raising the open file limits of
+ * both the proxy and the test:
+ * 'go test -run NONE -bench IdleConnections -benchtime 1x -idleclients 50000'.
+ */
+func BenchmarkIdleConnections(b *testing.B) {
+	db, err := Connect()
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer db.Close()
+
+	db.SetMaxIdleConns(0)
+
+	for i := 0; i < b.N; i++ {
+		before, err := proxyMetrics()
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		conns, err := openIdle(db, idleClients)
+		if err != nil {
+			closeIdle(conns)
+			b.Fatal(err)
+		}
+
+		time.Sleep(idleSettle)
+
+		after, err := proxyMetrics()
+		closeIdle(conns)
+
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		per := func(name string) float64 {
+			return (after[name] - before[name]) / float64(len(conns))
+		}
+
+		b.ReportMetric(per("process_resident_memory_bytes"), "rss-B/conn")
+		b.ReportMetric(per("go_memstats_heap_inuse_bytes"), "heap-B/conn")
+		b.ReportMetric(per("go_memstats_stack_inuse_bytes"), "stack-B/conn")
+		b.ReportMetric(per("go_goroutines"), "goroutines/conn")
+	}
+}
+
+/* openIdle opens count connections through the proxy and leaves them idle. */
+func openIdle(db *sql.DB, count int) ([]*sql.Conn, error) {
+	var lock sync.Mutex
+	var firstErr error
+
+	conns := make([]*sql.Conn, 0, count)
+	openers := make(chan struct{}, idleOpeners)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < count; i++ {
+		openers <- struct{}{}
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			defer func() { <-openers }()
+
+			conn, err := db.Conn(context.Background())
+
+			lock.Lock()
+			defer lock.Unlock()
+
+			if conn != nil {
+				conns = append(conns, conn)
+			}
+
+			if err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	return conns, firstErr
+}
+
+/* closeIdle closes the connections opened by openIdle. */
+func closeIdle(conns []*sql.Conn) {
+	for _, conn := range conns {
+		conn.Close()
+	}
+}
+
+/*
+ * proxyMetrics reads the unlabelled metrics of the proxy, which include the
+ * memory and goroutines of its process.
+ */
+func proxyMetrics() (map[string]float64, error) {
+	resp, err := http.Get("http://" + metricsHostPort + "/metrics")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	metrics := make(map[string]float64)
+	scanner := bufio.NewScanner(resp.Body)
+
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+
+		if len(fields) != 2 || strings.HasPrefix(fields[0], "#") || strings.Contains(fields[0], "{") {
+			continue
+		}
+
+		if value, err := strconv.ParseFloat(fields[1], 64); err == nil {
+			metrics[fields[0]] = value
+		}
+	}
+
+	return metrics, scanner.Err()
+}
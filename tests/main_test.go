@@ -21,6 +21,8 @@ import (
 
 var HostPort string
 var rows, userid, password, database string
+var metricsHostPort string
+var idleClients int
 
 func TestMain(m *testing.M) {
 	flag.StringVar(&rows, "rows", "onerow", "onerow or tworows")
@@ -28,6 +30,8 @@ func TestMain(m *testing.M) {
 	flag.StringVar(&userid, "userid", "postgres", "postgres userid")
 	flag.StringVar(&password, "password", "password", "postgres password")
 	flag.StringVar(&database, "database", "postgres", "database")
+	flag.StringVar(&metricsHostPort, "metrics", "localhost:9090", "proxy metrics host:port")
+	flag.IntVar(&idleClients, "idleclients", 50000, "idle connections opened by BenchmarkIdleConnections")
 	flag.Parse()
 	os.Exit(m.Run())
 
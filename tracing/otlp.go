@@ -0,0 +1,308 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+
+	"github.com/crunchydata/crunchy-proxy/config"
+)
+
+/*
+ * The OTLP status codes, which differ from the values of the codes package.
+ */
+const (
+	statusUnset = 0
+	statusOK    = 1
+	statusError = 2
+)
+
+// exporter sends spans to an OpenTelemetry collector using OTLP over HTTP,
+// encoded as JSON.
+type exporter struct {
+	endpoint string
+	headers  map[string]string
+	client   *http.Client
+}
+
+func newExporter(tracingConfig config.TracingConfig) (*exporter, error) {
+	request, err := http.NewRequest(http.MethodPost, tracingConfig.Endpoint, nil)
+
+	if err != nil {
+		return nil, fmt.Errorf("tracing: invalid endpoint: %s", err.Error())
+	}
+
+	if request.URL.Scheme != "http" && request.URL.Scheme != "https" {
+		return nil, fmt.Errorf("tracing: endpoint '%s' is not an HTTP URL", tracingConfig.Endpoint)
+	}
+
+	return &exporter{
+		endpoint: tracingConfig.Endpoint,
+		headers:  tracingConfig.Headers,
+		client: &http.Client{
+			Timeout: time.Duration(tracingConfig.Timeout) * time.Second,
+		},
+	}, nil
+}
+
+/* The OTLP JSON encoding of a batch of spans. */
+type exportRequest struct {
+	ResourceSpans []resourceSpans `json:"resourceSpans"`
+}
+
+type resourceSpans struct {
+	Resource   resourceJSON `json:"resource"`
+	ScopeSpans []scopeSpans `json:"scopeSpans"`
+}
+
+type resourceJSON struct {
+	Attributes []keyValue `json:"attributes"`
+}
+
+type scopeSpans struct {
+	Scope scope      `json:"scope"`
+	Spans []spanJSON `json:"spans"`
+}
+
+type scope struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type spanJSON struct {
+	TraceID           string      `json:"traceId"`
+	SpanID            string      `json:"spanId"`
+	TraceState        string      `json:"traceState,omitempty"`
+	ParentSpanID      string      `json:"parentSpanId,omitempty"`
+	Name              string      `json:"name"`
+	Kind              int         `json:"kind"`
+	StartTimeUnixNano string      `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string      `json:"endTimeUnixNano"`
+	Attributes        []keyValue  `json:"attributes,omitempty"`
+	Events            []eventJSON `json:"events,omitempty"`
+	Status            statusJSON  `json:"status"`
+}
+
+type eventJSON struct {
+	TimeUnixNano string     `json:"timeUnixNano"`
+	Name         string     `json:"name"`
+	Attributes   []keyValue `json:"attributes,omitempty"`
+}
+
+type statusJSON struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type keyValue struct {
+	Key   string   `json:"key"`
+	Value anyValue `json:"value"`
+}
+
+type anyValue struct {
+	StringValue *string     `json:"stringValue,omitempty"`
+	BoolValue   *bool       `json:"boolValue,omitempty"`
+	IntValue    *string     `json:"intValue,omitempty"`
+	DoubleValue *float64    `json:"doubleValue,omitempty"`
+	ArrayValue  *arrayValue `json:"arrayValue,omitempty"`
+}
+
+type arrayValue struct {
+	Values []anyValue `json:"values"`
+}
+
+// ExportSpans sends a batch of spans to the collector.
+func (e *exporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	if len(spans) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(encodeSpans(spans))
+
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequest(http.MethodPost, e.endpoint, bytes.NewReader(body))
+
+	if err != nil {
+		return err
+	}
+
+	request = request.WithContext(ctx)
+	request.Header.Set("Content-Type", "application/json")
+
+	for name, value := range e.headers {
+		request.Header.Set(name, value)
+	}
+
+	response, err := e.client.Do(request)
+
+	if err != nil {
+		return err
+	}
+
+	response.Body.Close()
+
+	if response.StatusCode/100 != 2 {
+		return fmt.Errorf("tracing: collector returned '%s'", response.Status)
+	}
+
+	return nil
+}
+
+// Shutdown does nothing, as no connection is kept open between exports.
+func (e *exporter) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+/*
+ * Group the spans by the scope that created them. All spans share the
+ * resource of the proxy's provider.
+ */
+func encodeSpans(spans []sdktrace.ReadOnlySpan) exportRequest {
+	var scopes []scopeSpans
+	index := make(map[string]int)
+
+	for _, span := range spans {
+		name := span.InstrumentationScope().Name
+
+		i, ok := index[name]
+
+		if !ok {
+			i = len(scopes)
+			index[name] = i
+			scopes = append(scopes, scopeSpans{
+				Scope: scope{
+					Name:    name,
+					Version: span.InstrumentationScope().Version,
+				},
+			})
+		}
+
+		scopes[i].Spans = append(scopes[i].Spans, encodeSpan(span))
+	}
+
+	return exportRequest{
+		ResourceSpans: []resourceSpans{
+			{
+				Resource: resourceJSON{
+					Attributes: encodeAttributes(spans[0].Resource().Attributes()),
+				},
+				ScopeSpans: scopes,
+			},
+		},
+	}
+}
+
+func encodeSpan(span sdktrace.ReadOnlySpan) spanJSON {
+	spanContext := span.SpanContext()
+	traceID := spanContext.TraceID()
+	spanID := spanContext.SpanID()
+
+	encoded := spanJSON{
+		TraceID:           hex.EncodeToString(traceID[:]),
+		SpanID:            hex.EncodeToString(spanID[:]),
+		TraceState:        spanContext.TraceState().String(),
+		Name:              span.Name(),
+		Kind:              int(span.SpanKind()),
+		StartTimeUnixNano: encodeTime(span.StartTime()),
+		EndTimeUnixNano:   encodeTime(span.EndTime()),
+		Attributes:        encodeAttributes(span.Attributes()),
+	}
+
+	if parent := span.Parent(); parent.HasSpanID() {
+		parentID := parent.SpanID()
+		encoded.ParentSpanID = hex.EncodeToString(parentID[:])
+	}
+
+	for _, event := range span.Events() {
+		encoded.Events = append(encoded.Events, eventJSON{
+			TimeUnixNano: encodeTime(event.Time),
+			Name:         event.Name,
+			Attributes:   encodeAttributes(event.Attributes),
+		})
+	}
+
+	switch span.Status().Code {
+	case codes.Ok:
+		encoded.Status.Code = statusOK
+	case codes.Error:
+		encoded.Status.Code = statusError
+		encoded.Status.Message = span.Status().Description
+	default:
+		encoded.Status.Code = statusUnset
+	}
+
+	return encoded
+}
+
+/* Times are encoded as strings, as they may not fit in a JSON number. */
+func encodeTime(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+func encodeAttributes(attributes []attribute.KeyValue) []keyValue {
+	encoded := make([]keyValue, 0, len(attributes))
+
+	for _, kv := range attributes {
+		encoded = append(encoded, keyValue{
+			Key:   string(kv.Key),
+			Value: encodeValue(kv.Value),
+		})
+	}
+
+	return encoded
+}
+
+func encodeValue(value attribute.Value) anyValue {
+	var encoded anyValue
+
+	switch value.Type() {
+	case attribute.BOOL:
+		b := value.AsBool()
+		encoded.BoolValue = &b
+	case attribute.INT64:
+		i := strconv.FormatInt(value.AsInt64(), 10)
+		encoded.IntValue = &i
+	case attribute.FLOAT64:
+		f := value.AsFloat64()
+		encoded.DoubleValue = &f
+	case attribute.STRINGSLICE:
+		values := make([]anyValue, 0, len(value.AsStringSlice()))
+
+		for _, s := range value.AsStringSlice() {
+			values = append(values, encodeValue(attribute.StringValue(s)))
+		}
+
+		encoded.ArrayValue = &arrayValue{Values: values}
+	default:
+		s := value.Emit()
+		encoded.StringValue = &s
+	}
+
+	return encoded
+}
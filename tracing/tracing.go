@@ -0,0 +1,154 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tracing
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+
+	"github.com/crunchydata/crunchy-proxy/config"
+	"github.com/crunchydata/crunchy-proxy/util/log"
+)
+
+/* The name that the proxy's spans are attributed to. */
+const tracerName = "github.com/crunchydata/crunchy-proxy"
+
+/* How long to wait for the remaining spans to be exported on shutdown. */
+const shutdownTimeout = 5 * time.Second
+
+var (
+	provider *sdktrace.TracerProvider
+	lock     sync.Mutex
+	disabled = noop.NewTracerProvider().Tracer(tracerName)
+)
+
+/*
+ * The trace context that an application may attach to a query in an SQL
+ * comment, in the format used by sqlcommenter.
+ */
+var (
+	traceParentPattern = regexp.MustCompile(`traceparent='([^']*)'`)
+	traceStatePattern  = regexp.MustCompile(`tracestate='([^']*)'`)
+)
+
+// Setup starts exporting spans to the collector configured in the 'tracing'
+// section, replacing any previous exporter. If tracing is disabled, then the
+// current exporter is shut down and spans are no longer recorded.
+func Setup() error {
+	tracingConfig := config.GetTracingConfig()
+
+	var newProvider *sdktrace.TracerProvider
+
+	if tracingConfig.Enable {
+		exporter, err := newExporter(tracingConfig)
+
+		if err != nil {
+			return err
+		}
+
+		sampler := sdktrace.ParentBased(sdktrace.TraceIDRatioBased(tracingConfig.SampleRatio))
+
+		newProvider = sdktrace.NewTracerProvider(
+			sdktrace.WithBatcher(exporter),
+			sdktrace.WithSampler(sampler),
+			sdktrace.WithResource(resource.NewSchemaless(
+				attribute.String("service.name", tracingConfig.ServiceName))),
+		)
+	}
+
+	lock.Lock()
+	oldProvider := provider
+	provider = newProvider
+	lock.Unlock()
+
+	if oldProvider != nil {
+		shutdown(oldProvider)
+	}
+
+	return nil
+}
+
+// Close exports the remaining spans and stops tracing.
+func Close() {
+	lock.Lock()
+	oldProvider := provider
+	provider = nil
+	lock.Unlock()
+
+	if oldProvider != nil {
+		shutdown(oldProvider)
+	}
+}
+
+func shutdown(p *sdktrace.TracerProvider) {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := p.Shutdown(ctx); err != nil {
+		log.Error("tracing: could not export the remaining spans")
+		log.Errorf("tracing: %s", err.Error())
+	}
+}
+
+func tracer() trace.Tracer {
+	lock.Lock()
+	defer lock.Unlock()
+
+	if provider == nil {
+		return disabled
+	}
+
+	return provider.Tracer(tracerName)
+}
+
+// Start starts a span as a child of the span in the context, if any. The span
+// is not recorded if tracing is disabled, but must still be ended.
+func Start(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer().Start(ctx, name, trace.WithAttributes(attributes...))
+}
+
+// FromQuery returns a context holding the trace context that the application
+// attached to the query in an SQL comment, such as
+// /*traceparent='00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01'*/,
+// so that the proxy's spans become part of the application's trace.
+func FromQuery(query string) context.Context {
+	ctx := context.Background()
+
+	if !strings.Contains(query, "traceparent=") {
+		return ctx
+	}
+
+	carrier := propagation.MapCarrier{}
+
+	if match := traceParentPattern.FindStringSubmatch(query); match != nil {
+		carrier["traceparent"] = match[1]
+	}
+
+	if match := traceStatePattern.FindStringSubmatch(query); match != nil {
+		carrier["tracestate"] = match[1]
+	}
+
+	return propagation.TraceContext{}.Extract(ctx, carrier)
+}
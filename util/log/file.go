@@ -0,0 +1,231 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package log
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+const megabyte = 1024 * 1024
+
+// FileOptions configures the file that the log is written to.
+type FileOptions struct {
+	Path           string
+	MaxSize        int           // megabytes, 0 for no limit
+	RotateInterval time.Duration // 0 to rotate by size only
+	MaxBackups     int
+	Compress       bool
+}
+
+// rotatingFile writes the log to a file and rotates it when it reaches its
+// maximum size or age: the file is renamed with the suffix '.1', any previous
+// backups are shifted up by one and the oldest is removed. Backups may be
+// compressed with gzip, which is done in the background.
+type rotatingFile struct {
+	options     FileOptions
+	maxSize     int64
+	lock        sync.Mutex
+	file        *os.File
+	size        int64
+	opened      time.Time
+	compressing sync.WaitGroup
+}
+
+func newRotatingFile(options FileOptions) (*rotatingFile, error) {
+	f := &rotatingFile{
+		options: options,
+		maxSize: int64(options.MaxSize) * megabyte,
+	}
+
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+func (f *rotatingFile) open() error {
+	file, err := os.OpenFile(f.options.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	f.file = file
+	f.size = info.Size()
+	f.opened = time.Now()
+
+	return nil
+}
+
+func (f *rotatingFile) Write(line []byte) (int, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+
+	full := f.maxSize > 0 && f.size > 0 && f.size+int64(len(line)) > f.maxSize
+	expired := f.options.RotateInterval > 0 && time.Since(f.opened) >= f.options.RotateInterval
+
+	if full || expired {
+		if err := f.rotate(); err != nil {
+			/* Keep logging to the current file rather than losing messages. */
+			fmt.Fprintf(os.Stderr, "Error rotating log file: %s\n", err.Error())
+
+			if f.file == nil {
+				return 0, err
+			}
+		}
+	}
+
+	n, err := f.file.Write(line)
+	f.size += int64(n)
+
+	return n, err
+}
+
+/* Rotate the file. Without any backups, the file is simply truncated. */
+func (f *rotatingFile) rotate() error {
+	path := f.options.Path
+
+	if f.options.MaxBackups <= 0 {
+		if err := f.file.Truncate(0); err != nil {
+			return err
+		}
+
+		f.size = 0
+		f.opened = time.Now()
+
+		return nil
+	}
+
+	/* The previous backup must be compressed before it is shifted. */
+	f.compressing.Wait()
+
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+
+	f.file = nil
+
+	os.Remove(f.backupName(f.options.MaxBackups))
+
+	for i := f.options.MaxBackups - 1; i > 0; i-- {
+		os.Rename(f.backupName(i), f.backupName(i+1))
+	}
+
+	renameErr := os.Rename(path, backupName(path, 1))
+
+	if err := f.open(); err != nil {
+		return err
+	}
+
+	if renameErr != nil {
+		return renameErr
+	}
+
+	if f.options.Compress {
+		f.compressing.Add(1)
+
+		go func() {
+			defer f.compressing.Done()
+
+			if err := compressFile(backupName(path, 1)); err != nil {
+				fmt.Fprintf(os.Stderr, "Error compressing log file: %s\n", err.Error())
+			}
+		}()
+	}
+
+	return nil
+}
+
+/* The name of a backup, which has the suffix '.gz' if it is compressed. */
+func (f *rotatingFile) backupName(index int) string {
+	name := backupName(f.options.Path, index)
+
+	if f.options.Compress {
+		name += ".gz"
+	}
+
+	return name
+}
+
+func backupName(path string, index int) string {
+	return fmt.Sprintf("%s.%d", path, index)
+}
+
+/* Compress a file with gzip, replacing it with a file with the suffix '.gz'. */
+func compressFile(path string) error {
+	source, err := os.Open(path)
+
+	if err != nil {
+		return err
+	}
+
+	defer source.Close()
+
+	target, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+
+	if err != nil {
+		return err
+	}
+
+	writer := gzip.NewWriter(target)
+
+	if _, err = io.Copy(writer, source); err == nil {
+		err = writer.Close()
+	}
+
+	if closeErr := target.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		os.Remove(path + ".gz")
+		return err
+	}
+
+	return os.Remove(path)
+}
+
+func (f *rotatingFile) Close() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.compressing.Wait()
+
+	if f.file == nil {
+		return nil
+	}
+
+	err := f.file.Close()
+	f.file = nil
+
+	return err
+}
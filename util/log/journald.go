@@ -0,0 +1,89 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package log
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+)
+
+/* The socket of the native protocol of the systemd journal. */
+const journaldSocket = "/run/systemd/journal/socket"
+
+// journaldFormatter formats entries as messages of the native protocol of the
+// systemd journal, which carry the priority and identifier as fields.
+type journaldFormatter struct {
+	identifier string
+	pid        int
+}
+
+func (f *journaldFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	var message bytes.Buffer
+
+	writeJournalField(&message, "MESSAGE", entry.Message)
+	writeJournalField(&message, "PRIORITY", strconv.Itoa(severity(entry.Level)))
+	writeJournalField(&message, "SYSLOG_IDENTIFIER", f.identifier)
+	writeJournalField(&message, "SYSLOG_PID", strconv.Itoa(f.pid))
+
+	return message.Bytes(), nil
+}
+
+/*
+ * Append a field to a journal message. Values that span several lines are
+ * written with their length instead of being terminated by a newline.
+ */
+func writeJournalField(message *bytes.Buffer, name, value string) {
+	message.WriteString(name)
+
+	if !strings.Contains(value, "\n") {
+		message.WriteByte('=')
+		message.WriteString(value)
+		message.WriteByte('\n')
+		return
+	}
+
+	message.WriteByte('\n')
+	binary.Write(message, binary.LittleEndian, uint64(len(value)))
+	message.WriteString(value)
+	message.WriteByte('\n')
+}
+
+// journaldWriter sends each formatted message to the journal as a datagram.
+type journaldWriter struct {
+	conn *net.UnixConn
+}
+
+func newJournaldWriter() (*journaldWriter, error) {
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: journaldSocket, Net: "unixgram"})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &journaldWriter{conn: conn}, nil
+}
+
+func (w *journaldWriter) Write(message []byte) (int, error) {
+	return w.conn.Write(message)
+}
+
+func (w *journaldWriter) Close() error {
+	return w.conn.Close()
+}
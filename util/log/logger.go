@@ -15,7 +15,12 @@ limitations under the License.
 package log
 
 import (
+	"fmt"
+	"io"
 	"os"
+	"runtime"
+	"strings"
+	"sync"
 
 	"github.com/Sirupsen/logrus"
 )
@@ -23,10 +28,19 @@ import (
 var levels = []string{
 	"debug",
 	"info",
+	"warn",
 	"error",
 	"fatal",
 }
 
+var (
+	lock            sync.RWMutex
+	baseLevel       = logrus.InfoLevel
+	componentLevels map[string]logrus.Level
+	components      sync.Map // component of each calling function, by pc
+	output          io.WriteCloser
+)
+
 func init() {
 	logrus.SetFormatter(&logrus.TextFormatter{
 		ForceColors:   true,
@@ -35,28 +49,104 @@ func init() {
 	logrus.SetOutput(os.Stdout)
 }
 
+/*
+ * Determine whether a message at the given level should be logged by the
+ * caller of the logging function. Without any component levels, logrus does
+ * all of the filtering.
+ */
+func enabled(level logrus.Level) bool {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	if len(componentLevels) == 0 {
+		return true
+	}
+
+	componentLevel, ok := componentLevels[caller()]
+
+	if !ok {
+		componentLevel = baseLevel
+	}
+
+	return level <= componentLevel
+}
+
+/*
+ * Return the component of the function that called the logging function,
+ * which is the last element of its package path, such as 'pool' or 'proxy'.
+ */
+func caller() string {
+	pcs := make([]uintptr, 1)
+
+	if runtime.Callers(4, pcs) == 0 {
+		return ""
+	}
+
+	if component, ok := components.Load(pcs[0]); ok {
+		return component.(string)
+	}
+
+	var component string
+
+	if fn := runtime.FuncForPC(pcs[0]); fn != nil {
+		component = fn.Name()[strings.LastIndex(fn.Name(), "/")+1:]
+
+		if i := strings.Index(component, "."); i >= 0 {
+			component = component[:i]
+		}
+	}
+
+	components.Store(pcs[0], component)
+
+	return component
+}
+
 func Debug(msg string) {
-	logrus.Debug(msg)
+	if enabled(logrus.DebugLevel) {
+		logrus.Debug(msg)
+	}
 }
 
 func Debugf(format string, args ...interface{}) {
-	logrus.Debugf(format, args...)
+	if enabled(logrus.DebugLevel) {
+		logrus.Debugf(format, args...)
+	}
 }
 
 func Info(msg string) {
-	logrus.Info(msg)
+	if enabled(logrus.InfoLevel) {
+		logrus.Info(msg)
+	}
 }
 
 func Infof(format string, args ...interface{}) {
-	logrus.Infof(format, args...)
+	if enabled(logrus.InfoLevel) {
+		logrus.Infof(format, args...)
+	}
+}
+
+func Warn(msg string) {
+	if enabled(logrus.WarnLevel) {
+		logrus.Warn(msg)
+	}
+}
+
+func Warnf(format string, args ...interface{}) {
+	if enabled(logrus.WarnLevel) {
+		logrus.Warnf(format, args...)
+	}
 }
 
 func Error(msg string) {
-	logrus.Error(msg)
+	if enabled(logrus.ErrorLevel) {
+		logrus.Error(msg)
+	}
 }
 
 func Errorf(format string, args ...interface{}) {
-	logrus.Errorf(format, args...)
+	if enabled(logrus.ErrorLevel) {
+		logrus.Errorf(format, args...)
+	}
 }
 
 func Fatal(msg string) {
@@ -74,5 +164,135 @@ func SetLevel(level string) {
 		logrus.Fatalf("\"%s\" is not a valid logging level", level)
 	}
 
-	logrus.SetLevel(logrusLevel)
+	lock.Lock()
+	defer lock.Unlock()
+
+	baseLevel = logrusLevel
+	applyLevels()
+}
+
+// SetComponentLevels overrides the logging level of the given components,
+// such as 'pool' or 'proxy', which are named after their packages. The other
+// components log at the level set by SetLevel. Any previous overrides are
+// replaced.
+func SetComponentLevels(levels map[string]string) error {
+	parsed := make(map[string]logrus.Level, len(levels))
+
+	for component, level := range levels {
+		logrusLevel, err := logrus.ParseLevel(level)
+
+		if err != nil {
+			return fmt.Errorf("\"%s\" is not a valid logging level for '%s'", level, component)
+		}
+
+		parsed[strings.ToLower(component)] = logrusLevel
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	componentLevels = parsed
+	applyLevels()
+
+	return nil
+}
+
+/*
+ * Let logrus through the messages of the most verbose component, since the
+ * components are filtered before calling it.
+ */
+func applyLevels() {
+	level := baseLevel
+
+	for _, componentLevel := range componentLevels {
+		if componentLevel > level {
+			level = componentLevel
+		}
+	}
+
+	logrus.SetLevel(level)
+}
+
+// SetStdout writes the log to stdout, which is the default. Any file or
+// connection that was previously being written is closed.
+func SetStdout() {
+	setOutput(&logrus.TextFormatter{
+		ForceColors:   true,
+		FullTimestamp: true,
+	}, nil)
+}
+
+// SetFile writes the log to the file with the given options, rotating it as
+// it grows or ages. Any file or connection that was previously being written
+// is closed.
+func SetFile(options FileOptions) error {
+	file, err := newRotatingFile(options)
+
+	if err != nil {
+		return err
+	}
+
+	setOutput(&logrus.TextFormatter{
+		DisableColors: true,
+		FullTimestamp: true,
+	}, file)
+
+	return nil
+}
+
+// SetSyslog sends the log to a syslog server in the RFC 5424 format. Any file
+// or connection that was previously being written is closed.
+func SetSyslog(options SyslogOptions) error {
+	formatter, err := newSyslogFormatter(options)
+
+	if err != nil {
+		return err
+	}
+
+	writer, err := newSyslogWriter(options.Network, options.Address)
+
+	if err != nil {
+		return err
+	}
+
+	setOutput(formatter, writer)
+
+	return nil
+}
+
+// SetJournald sends the log to the systemd journal with the given identifier.
+// Any file or connection that was previously being written is closed.
+func SetJournald(identifier string) error {
+	writer, err := newJournaldWriter()
+
+	if err != nil {
+		return err
+	}
+
+	setOutput(&journaldFormatter{identifier: identifier, pid: os.Getpid()}, writer)
+
+	return nil
+}
+
+/*
+ * Replace the formatter and destination of the log, closing the previous
+ * destination. A nil writer means stdout.
+ */
+func setOutput(formatter logrus.Formatter, writer io.WriteCloser) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	logrus.SetFormatter(formatter)
+
+	if writer != nil {
+		logrus.SetOutput(writer)
+	} else {
+		logrus.SetOutput(os.Stdout)
+	}
+
+	if output != nil {
+		output.Close()
+	}
+
+	output = writer
 }
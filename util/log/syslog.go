@@ -0,0 +1,205 @@
+/*
+Copyright 2017 Crunchy Data Solutions, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package log
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+/* The paths of the local syslog socket on the supported platforms. */
+var syslogSockets = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+var facilities = map[string]int{
+	"kern":     0,
+	"user":     1,
+	"mail":     2,
+	"daemon":   3,
+	"auth":     4,
+	"syslog":   5,
+	"lpr":      6,
+	"news":     7,
+	"uucp":     8,
+	"cron":     9,
+	"authpriv": 10,
+	"ftp":      11,
+	"local0":   16,
+	"local1":   17,
+	"local2":   18,
+	"local3":   19,
+	"local4":   20,
+	"local5":   21,
+	"local6":   22,
+	"local7":   23,
+}
+
+// SyslogOptions configures the syslog server that the log is sent to. If no
+// address is given, then the local syslog server is used.
+type SyslogOptions struct {
+	Network  string
+	Address  string
+	Facility string
+	Tag      string
+}
+
+// syslogFormatter formats entries as RFC 5424 syslog messages.
+type syslogFormatter struct {
+	facility int
+	tag      string
+	hostname string
+	pid      int
+}
+
+func newSyslogFormatter(options SyslogOptions) (*syslogFormatter, error) {
+	facility, ok := facilities[strings.ToLower(options.Facility)]
+
+	if !ok {
+		return nil, fmt.Errorf("log: unknown syslog facility '%s'", options.Facility)
+	}
+
+	hostname, err := os.Hostname()
+
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+
+	return &syslogFormatter{
+		facility: facility,
+		tag:      options.Tag,
+		hostname: hostname,
+		pid:      os.Getpid(),
+	}, nil
+}
+
+func (f *syslogFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	priority := f.facility*8 + severity(entry.Level)
+
+	return []byte(fmt.Sprintf("<%d>1 %s %s %s %d - - %s", priority,
+		entry.Time.Format("2006-01-02T15:04:05.000000Z07:00"), f.hostname,
+		f.tag, f.pid, entry.Message)), nil
+}
+
+/* The syslog severity of a logging level. */
+func severity(level logrus.Level) int {
+	switch level {
+	case logrus.PanicLevel, logrus.FatalLevel:
+		return 2
+	case logrus.ErrorLevel:
+		return 3
+	case logrus.WarnLevel:
+		return 4
+	case logrus.InfoLevel:
+		return 6
+	default:
+		return 7
+	}
+}
+
+// syslogWriter sends each formatted message to a syslog server. Messages
+// sent over a stream are framed with their length, as in RFC 6587. If the
+// connection fails, then it is reopened for the next message.
+type syslogWriter struct {
+	network string
+	address string
+	lock    sync.Mutex
+	conn    net.Conn
+}
+
+func newSyslogWriter(network, address string) (*syslogWriter, error) {
+	w := &syslogWriter{network: network, address: address}
+
+	if err := w.connect(); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+func (w *syslogWriter) connect() error {
+	if w.address != "" {
+		conn, err := net.DialTimeout(w.network, w.address, 5*time.Second)
+
+		if err != nil {
+			return err
+		}
+
+		w.conn = conn
+
+		return nil
+	}
+
+	for _, network := range []string{"unixgram", "unix"} {
+		for _, path := range syslogSockets {
+			if conn, err := net.Dial(network, path); err == nil {
+				w.network = network
+				w.conn = conn
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("log: the local syslog server is not available")
+}
+
+func (w *syslogWriter) Write(message []byte) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	framed := message
+
+	switch w.network {
+	case "tcp", "tcp4", "tcp6", "unix":
+		framed = append([]byte(fmt.Sprintf("%d ", len(message))), message...)
+	}
+
+	if w.conn != nil {
+		if _, err := w.conn.Write(framed); err == nil {
+			return len(message), nil
+		}
+
+		w.conn.Close()
+		w.conn = nil
+	}
+
+	if err := w.connect(); err != nil {
+		return 0, err
+	}
+
+	if _, err := w.conn.Write(framed); err != nil {
+		return 0, err
+	}
+
+	return len(message), nil
+}
+
+func (w *syslogWriter) Close() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.conn == nil {
+		return nil
+	}
+
+	err := w.conn.Close()
+	w.conn = nil
+
+	return err
+}